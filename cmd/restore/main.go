@@ -0,0 +1,55 @@
+// Package main restores the critical tables from a backup in the configured backup store
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+)
+
+func main() {
+	key := flag.String("key", "", "key of the backup in the backup store, the latest backup when blank")
+	dryRun := flag.Bool("dry-run", false, "only read the backup and report the rows that would be restored")
+	timeout := flag.Duration("timeout", 5*time.Minute, "how long the download of the backup may take")
+	flag.Parse()
+
+	cfg, err := config.Get()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	db, err := repository.ConnectPostgres(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to Postgres: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	result, err := service.NewBackupService(db).Restore(ctx, *key, *dryRun)
+	if err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+
+	fmt.Println(config.DoubleLine)
+	fmt.Printf("backup            : %s\n", result.Location)
+	fmt.Printf("created at        : %s\n", result.CreatedAt.Format(time.RFC3339))
+	if *dryRun {
+		fmt.Printf("dry run           : nothing was written\n")
+	}
+	fmt.Println(config.SingleLine)
+	tables := make([]string, 0, len(result.Rows))
+	for table := range result.Rows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("%-26s %10d rows\n", table, result.Rows[table])
+	}
+	fmt.Println(config.DoubleLine)
+}
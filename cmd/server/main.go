@@ -2,8 +2,15 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nsvirk/moneybotsapi/internal/api"
@@ -11,6 +18,7 @@ import (
 	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/repository"
 	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 )
 
@@ -21,8 +29,22 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Print the configuration
-	fmt.Println(cfg.String())
+	// Market time logic and cron schedules use the exchange time zone, not the server's
+	if err := service.SetMarketLocation(cfg.MarketTimezone); err != nil {
+		log.Fatalf("Failed to set market time zone: %v", err)
+	}
+
+	// Redis keys and channels carry the environment prefix
+	service.SetRedisKeyPrefix(cfg.RedisKeyPrefix)
+
+	// The scheduler and trading hour checks run on a simulated clock when a start time is configured
+	if cfg.ClockSimulatedStart != "" {
+		// validated when the config is loaded
+		start, _ := time.ParseInLocation("2006-01-02 15:04:05", cfg.ClockSimulatedStart, service.MarketLocation)
+		speed, _ := strconv.ParseFloat(cfg.ClockSimulatedSpeed, 64)
+		service.SetSimulatedClock(start, speed)
+		log.Printf("Simulated market clock from %s at %gx speed", cfg.ClockSimulatedStart, speed)
+	}
 
 	// Connect to Postgres
 	db, err := repository.ConnectPostgres(cfg)
@@ -46,10 +68,56 @@ func main() {
 	defer zaplogger.Sync()
 	zaplogger.SetLogLevel(cfg.ServerLogLevel)
 
+	// Report recovered panics to Sentry when configured
+	if cfg.SentryDSN != "" {
+		sentryReporter, err := recovery.NewSentryReporter(cfg.SentryDSN, cfg.SentryEnvironment, cfg.APIVersion)
+		if err != nil {
+			log.Fatalf("Failed to configure Sentry: %v", err)
+		}
+		recovery.AddReporter(sentryReporter)
+	}
+
 	// startUpMessage
-	zaplogger.Info(cfg.APIName + " - " + cfg.APIVersion + " initialized")
-	zaplogger.Info("Postgres initialized")
-	zaplogger.Info("Redis initialized")
+	versionInfo := service.NewVersionService(db, redisClient).GetVersionInfo()
+	zaplogger.Info(cfg.APIName+" - "+cfg.APIVersion+" initialized", zaplogger.Fields{
+		"build_version":      versionInfo.BuildVersion,
+		"commit":             versionInfo.Commit,
+		"build_time":         versionInfo.BuildTime,
+		"modified":           versionInfo.Modified,
+		"go_version":         versionInfo.GoVersion,
+		"kiteticker_version": versionInfo.KitetickerVersion,
+	})
+	zaplogger.Info("Postgres initialized", zaplogger.Fields{"server_version": versionInfo.PostgresVersion})
+	zaplogger.Info("Redis initialized", zaplogger.Fields{"server_version": versionInfo.RedisVersion})
+	zaplogger.Info("Configuration", cfg.Fields())
+
+	// Jobs left active by a previous process will never finish
+	if failedCount, err := service.NewJobService(db).FailInterruptedJobs(); err != nil {
+		zaplogger.Error("Failed to fail interrupted jobs", zaplogger.Fields{"error": err.Error()})
+	} else if failedCount > 0 {
+		zaplogger.Info("Interrupted jobs failed", zaplogger.Fields{"count": failedCount})
+	}
+
+	// Kill switches stay engaged across restarts
+	if engagedCount, err := service.NewKillSwitchService(db).Load(); err != nil {
+		log.Fatalf("Failed to load kill switches: %v", err)
+	} else if engagedCount > 0 {
+		zaplogger.Warn("Kill switches engaged", zaplogger.Fields{"count": engagedCount})
+	}
+
+	// Users restricted to delayed market data stay restricted across restarts
+	if delayedCount, err := service.NewDataDelayService(db).Load(); err != nil {
+		log.Fatalf("Failed to load data delays: %v", err)
+	} else if delayedCount > 0 {
+		zaplogger.Info("Data delays loaded", zaplogger.Fields{"count": delayedCount})
+	}
+
+	// Blacklisted instruments stay blacklisted across restarts
+	if blacklistedCount, err := service.NewInstrumentBlacklistService(db).Load(); err != nil {
+		log.Fatalf("Failed to load the instrument blacklist: %v", err)
+	} else if blacklistedCount > 0 {
+		zaplogger.Info("Instrument blacklist loaded", zaplogger.Fields{"count": blacklistedCount})
+	}
 
 	// Create a new Echo instance
 	e := echo.New()
@@ -57,19 +125,36 @@ func main() {
 	e.HidePort = true
 
 	// Setup middleware
+	middleware.SetupProxyMiddleware(e, cfg)
 	middleware.SetupLoggerMiddleware(e)
+	middleware.SetupSecurityMiddleware(e, cfg)
+
+	// Setup cron jobs, the cron routes run them on the same scheduler
+	cronService := service.NewCronService(e, cfg, db, redisClient)
 
 	// Setup routes
-	api.SetupRoutes(e, cfg, db, redisClient)
+	api.SetupRoutes(e, cfg, db, redisClient, cronService)
 
-	// Setup and start cron jobs
-	cronService := service.NewCronService(e, cfg, db, redisClient)
 	// start cron jobs
 	cronService.Start()
 
 	// Setup and start ticks
 	publishService := service.NewPublishService(db, redisClient, cfg.PostgresDsn)
-	go publishService.PublishTicksToRedisChannel()
+	recovery.Go("publish.PublishTicksToRedisChannel", publishService.PublishTicksToRedisChannel)
+
+	// Persist the instrument access counters
+	recovery.Go("instrumentAccess.PersistAccessCounters", service.NewInstrumentAccessService(db).PersistAccessCounters)
+
+	// Self test the critical endpoints once the routes are set up, a broken deploy is reported before the market opens
+	// validated when the config is loaded
+	if selfTestOnStartup, _ := strconv.ParseBool(cfg.SelfTestOnStartup); selfTestOnStartup {
+		if cfg.KitetickerUserID == "" {
+			zaplogger.Warn("Self test skipped, MB_API_KITETICKER_USER_ID is not set", zaplogger.Fields{})
+		} else {
+			selfTestService := service.NewSelfTestService(e, db)
+			recovery.Go("selfTest.RunStartup", func() { selfTestService.RunStartup(cfg.KitetickerUserID) })
+		}
+	}
 
 	// Start the server
 	startServer(e, cfg)
@@ -82,7 +167,43 @@ func startServer(e *echo.Echo, cfg *config.Config) {
 	if port == "" {
 		port = "3007"
 	}
-	zaplogger.Info("SERVER STARTED ON PORT " + port)
-	e.Logger.Fatal(e.Start(":" + port))
 
+	if cfg.ServerTLSCertFile == "" {
+		zaplogger.Info("SERVER STARTED ON PORT " + port)
+		e.Logger.Fatal(e.Start(":" + port))
+	}
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+	server := &http.Server{
+		Addr:      ":" + port,
+		Handler:   e,
+		TLSConfig: tlsConfig,
+	}
+	zaplogger.Info("SERVER STARTED ON PORT " + port + " (TLS)")
+	e.Logger.Fatal(server.ListenAndServeTLS(cfg.ServerTLSCertFile, cfg.ServerTLSKeyFile))
+
+}
+
+// newTLSConfig creates the server TLS config, client certificates are verified
+// when offered and enforced per route group by the access middleware
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ServerTLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	caPEM, err := os.ReadFile(cfg.ServerTLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in client CA file")
+	}
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
 }
@@ -0,0 +1,95 @@
+// Package main is a load generator pushing synthetic ticks through the tick pipeline
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"gorm.io/gorm"
+)
+
+func main() {
+	instruments := flag.Int("instruments", 1000, "number of synthetic instruments")
+	rate := flag.Int("rate", 0, "ticks per second, 0 pushes ticks as fast as possible")
+	duration := flag.Duration("duration", 10*time.Second, "how long ticks are pushed")
+	clients := flag.Int("clients", 10, "number of stream clients subscribed to every instrument")
+	deltas := flag.Bool("deltas", false, "make the stream clients delta clients")
+	flush := flag.Bool("flush", false, "flush the ticks to the Postgres of -dsn, the ticks are written to its ticker_data")
+	dsn := flag.String("dsn", "", "DSN of the bench Postgres the ticks are flushed to, its database name must contain \"bench\"")
+	flag.Parse()
+
+	var db *gorm.DB
+	if *flush {
+		cfg, err := config.Get()
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		if err := checkBenchDSN(*dsn, cfg.PostgresDsn); err != nil {
+			log.Fatalf("Refusing to flush: %v", err)
+		}
+		benchCfg := *cfg
+		benchCfg.PostgresDsn = *dsn
+		db, err = repository.ConnectPostgres(&benchCfg)
+		if err != nil {
+			log.Fatalf("Failed to connect to Postgres: %v", err)
+		}
+	}
+
+	report, err := service.RunTickBench(service.TickBenchOptions{
+		Instruments: *instruments,
+		Rate:        *rate,
+		Duration:    *duration,
+		Clients:     *clients,
+		Deltas:      *deltas,
+		DB:          db,
+	})
+	if err != nil {
+		log.Fatalf("Tick bench failed: %v", err)
+	}
+
+	fmt.Println(config.DoubleLine)
+	fmt.Printf("ticks             : %d in %s\n", report.Ticks, report.Elapsed.Round(time.Millisecond))
+	fmt.Printf("throughput        : %.0f ticks/s\n", report.TicksPerSecond)
+	fmt.Printf("stream messages   : %d sent, %d received\n", report.MessagesSent, report.MessagesReceived)
+	fmt.Println(config.SingleLine)
+	fmt.Printf("%-10s %10s %12s %12s %12s %12s\n", "stage", "count", "p50", "p90", "p99", "max")
+	for _, stage := range []struct {
+		name  string
+		stage service.TickBenchStage
+	}{
+		{"process", report.Process},
+		{"broadcast", report.Broadcast},
+		{"flush", report.Flush},
+	} {
+		fmt.Printf("%-10s %10d %12s %12s %12s %12s\n", stage.name, stage.stage.Count,
+			stage.stage.P50, stage.stage.P90, stage.stage.P99, stage.stage.Max)
+	}
+	fmt.Println(config.DoubleLine)
+}
+
+// checkBenchDSN checks that the flush target is a bench database and not the configured one, the synthetic
+// ticks would otherwise land in the production ticker_data
+func checkBenchDSN(dsn, configuredDSN string) error {
+	if dsn == "" {
+		return fmt.Errorf("-flush requires the -dsn of a bench database")
+	}
+	bench, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return fmt.Errorf("invalid -dsn: %v", err)
+	}
+	if !strings.Contains(strings.ToLower(bench.Database), "bench") {
+		return fmt.Errorf("database %q of -dsn is not a bench database, its name must contain \"bench\"", bench.Database)
+	}
+	if configured, err := pgconn.ParseConfig(configuredDSN); err == nil &&
+		configured.Host == bench.Host && configured.Port == bench.Port && configured.Database == bench.Database {
+		return fmt.Errorf("-dsn is the configured database %q", bench.Database)
+	}
+	return nil
+}
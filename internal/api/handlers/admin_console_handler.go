@@ -0,0 +1,186 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// Admin console settings
+const (
+	adminConsoleWriteTimeout   = 10 * time.Second
+	adminConsolePingInterval   = 30 * time.Second
+	adminConsoleReadLimit      = 4096
+	adminConsoleRepliesBufSize = 16
+)
+
+// Admin console commands
+const (
+	adminConsoleRestartTicker = "restart_ticker"
+	adminConsoleRunJob        = "run_job"
+	adminConsoleListJobs      = "list_jobs"
+)
+
+// adminConsoleUpgrader upgrades the console requests, they are authenticated by the header like any admin
+// request so the origin is not checked
+var adminConsoleUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// AdminConsoleCommand is a command sent by an admin console client, the id is echoed in its reply
+type AdminConsoleCommand struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	Job     string `json:"job"`
+}
+
+// AdminConsoleReply is the reply to a command
+type AdminConsoleReply struct {
+	ID      string      `json:"id"`
+	Command string      `json:"command"`
+	OK      bool        `json:"ok"`
+	Error   string      `json:"error,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
+}
+
+// AdminConsoleHandler is the handler for the admin console websocket
+type AdminConsoleHandler struct {
+	tickerService *service.TickerService
+	cronService   *service.CronService
+	jobService    *service.JobService
+}
+
+// NewAdminConsoleHandler creates a new handler for the admin console websocket
+func NewAdminConsoleHandler(tickerService *service.TickerService, cronService *service.CronService, jobService *service.JobService) *AdminConsoleHandler {
+	return &AdminConsoleHandler{
+		tickerService: tickerService,
+		cronService:   cronService,
+		jobService:    jobService,
+	}
+}
+
+// Console upgrades the request to a websocket sending the operational events, job runs, ticker state changes
+// and channel utilization, and accepting the restart_ticker, run_job and list_jobs commands
+func (h *AdminConsoleHandler) Console(c echo.Context) error {
+	userId, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	conn, err := adminConsoleUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// the upgrader has already written the error response
+		return nil
+	}
+	defer conn.Close()
+
+	clientID := userId + ":" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	events := service.GetAdminEvents().Subscribe(clientID)
+	defer service.GetAdminEvents().Unsubscribe(clientID)
+
+	replies := make(chan []byte, adminConsoleRepliesBufSize)
+	done := make(chan struct{})
+	recovery.Go("admin.console.read", func() {
+		defer close(done)
+		h.readCommands(conn, userId, enctoken, replies)
+	})
+
+	ping := time.NewTicker(adminConsolePingInterval)
+	defer ping.Stop()
+	for {
+		var message []byte
+		select {
+		case <-done:
+			return nil
+		case message = <-events:
+		case message = <-replies:
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(adminConsoleWriteTimeout)); err != nil {
+				return nil
+			}
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(adminConsoleWriteTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			return nil
+		}
+	}
+}
+
+// readCommands runs the commands of the client until its connection is closed, a command is replied to
+// once it has run. A client that does not read its replies within the write timeout is disconnected
+// instead of losing them
+func (h *AdminConsoleHandler) readCommands(conn *websocket.Conn, userId, enctoken string, replies chan<- []byte) {
+	conn.SetReadLimit(adminConsoleReadLimit)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var command AdminConsoleCommand
+		var reply AdminConsoleReply
+		if err := json.Unmarshal(data, &command); err != nil {
+			reply.Error = "invalid JSON command"
+		} else {
+			reply = h.runCommand(command, userId, enctoken)
+		}
+
+		message := service.MarshalAdminEvent(service.AdminEventReply, reply)
+		timeout := time.NewTimer(adminConsoleWriteTimeout)
+		select {
+		case replies <- message:
+			timeout.Stop()
+		case <-timeout.C:
+			closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "replies are not read")
+			conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(adminConsoleWriteTimeout))
+			return
+		}
+	}
+}
+
+// runCommand runs a console command
+func (h *AdminConsoleHandler) runCommand(command AdminConsoleCommand, userId, enctoken string) AdminConsoleReply {
+	reply := AdminConsoleReply{ID: command.ID, Command: command.Command}
+	switch command.Command {
+	case adminConsoleRestartTicker:
+		if err := h.tickerService.Restart(userId, enctoken); err != nil {
+			reply.Error = err.Error()
+			return reply
+		}
+	case adminConsoleRunJob:
+		if !h.cronService.HasJob(command.Job) {
+			reply.Error = fmt.Sprintf("unknown job %q", command.Job)
+			return reply
+		}
+		if service.GetKillSwitch().IsGlobalEngaged() {
+			reply.Error = "cron jobs are paused by the kill switch"
+			return reply
+		}
+		job, err := h.jobService.Submit(userId, command.Job, func() (interface{}, error) {
+			return nil, h.cronService.RunJob(command.Job)
+		})
+		if err != nil {
+			reply.Error = err.Error()
+			return reply
+		}
+		reply.Result = job
+	case adminConsoleListJobs:
+		reply.Result = h.cronService.JobNames()
+	default:
+		reply.Error = fmt.Sprintf("unknown command %q, must be %s, %s or %s", command.Command, adminConsoleRestartTicker, adminConsoleRunJob, adminConsoleListJobs)
+		return reply
+	}
+	reply.OK = true
+	return reply
+}
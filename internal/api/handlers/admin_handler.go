@@ -0,0 +1,472 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"gorm.io/gorm"
+)
+
+// tickerLogsMaxLimit is the maximum number of ticker logs returned by a request
+const tickerLogsMaxLimit = 1000
+
+// AdminHandler is the handler for the admin API
+type AdminHandler struct {
+	tickerService           *service.TickerService
+	storageService          *service.StorageService
+	killSwitchService       *service.KillSwitchService
+	instrumentAccessService *service.InstrumentAccessService
+	dataTrimService         *service.DataTrimService
+	dataDelayService        *service.DataDelayService
+	closeReconcileService   *service.CloseReconcileService
+	redisKeyService         *service.RedisKeyService
+	blacklistService        *service.InstrumentBlacklistService
+}
+
+// NewAdminHandler creates a new handler for the admin API
+func NewAdminHandler(tickerService *service.TickerService, storageService *service.StorageService, killSwitchService *service.KillSwitchService, instrumentAccessService *service.InstrumentAccessService, dataTrimService *service.DataTrimService, dataDelayService *service.DataDelayService, closeReconcileService *service.CloseReconcileService, redisKeyService *service.RedisKeyService, blacklistService *service.InstrumentBlacklistService) *AdminHandler {
+	return &AdminHandler{
+		tickerService:           tickerService,
+		storageService:          storageService,
+		killSwitchService:       killSwitchService,
+		instrumentAccessService: instrumentAccessService,
+		dataTrimService:         dataTrimService,
+		dataDelayService:        dataDelayService,
+		closeReconcileService:   closeReconcileService,
+		redisKeyService:         redisKeyService,
+		blacklistService:        blacklistService,
+	}
+}
+
+// KillSwitchRequestBody is the body of a kill switch engage or release, a blank user id is global
+type KillSwitchRequestBody struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+// DataDelayRequestBody is the body of a data delay request, a delay of 0 is the configured default delay
+type DataDelayRequestBody struct {
+	UserID       string `json:"user_id"`
+	DelayMinutes int    `json:"delay_minutes"`
+	Reason       string `json:"reason"`
+}
+
+// InstrumentBlacklistRequestBody is the body of an instrument blacklist request, the instruments are in the
+// EXCHANGE:TRADINGSYMBOL format
+type InstrumentBlacklistRequestBody struct {
+	Instruments []string `json:"instruments"`
+	Reason      string   `json:"reason"`
+}
+
+// ReconcileTickerInstruments fixes ticker instruments with stale tokens and removes orphaned subscriptions
+func (h *AdminHandler) ReconcileTickerInstruments(c echo.Context) error {
+	dryRun := false
+	if dryRunStr := c.QueryParam("dry_run"); dryRunStr != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `dry_run` value, must be `true` or `false`")
+		}
+	}
+
+	result, err := h.tickerService.ReconcileTickerInstruments(dryRun)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+
+	return response.SuccessResponse(c, result)
+}
+
+// GetStorage returns the table sizes, their growth and the projected days until the disk thresholds
+func (h *AdminHandler) GetStorage(c echo.Context) error {
+	report, err := h.storageService.GetStorageReport()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, report)
+}
+
+// GetPopularInstruments returns the most queried and streamed instruments over the last `days`, today by default,
+// sorted by `sort`: total (default), queries or streams
+func (h *AdminHandler) GetPopularInstruments(c echo.Context) error {
+	days := 1
+	if daysStr := c.QueryParam("days"); daysStr != "" {
+		var err error
+		days, err = strconv.Atoi(daysStr)
+		if err != nil || days <= 0 || days > service.PopularInstrumentsMaxDays {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, fmt.Sprintf("Invalid `days`, must be between 1 and %d", service.PopularInstrumentsMaxDays))
+		}
+	}
+
+	sortBy := c.QueryParam("sort")
+	switch sortBy {
+	case "", "total", "queries", "streams":
+	default:
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `sort`, must be total, queries or streams")
+	}
+
+	limit := 100
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 1000 {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `limit`, must be between 1 and 1000")
+		}
+	}
+
+	instruments, err := h.instrumentAccessService.GetPopularInstruments(days, sortBy, limit)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, instruments)
+}
+
+// TrimData deletes the rows of a historical table before the `before` date. Without `confirm` it is a dry run
+// counting the rows and returning the confirmation token, with the token the counted rows are deleted
+func (h *AdminHandler) TrimData(c echo.Context) error {
+	actor, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	table := c.QueryParam("table")
+	if !service.IsDataTrimTable(table) {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, fmt.Sprintf("Invalid `table`, must be one of %s", strings.Join(service.DataTrimTables(), ", ")))
+	}
+	before, err := time.ParseInLocation("2006-01-02", c.QueryParam("before"), service.MarketLocation)
+	if err != nil || before.Format("2006-01-02") > service.MarketToday() {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `before`, must be a date in YYYY-MM-DD format not after today")
+	}
+
+	token := c.QueryParam("confirm")
+	if token == "" {
+		result, err := h.dataTrimService.DryRun(table, before)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+		}
+		return response.SuccessResponse(c, result)
+	}
+
+	result, err := h.dataTrimService.Trim(table, before, token, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrDataTrimInvalidToken):
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+		case errors.Is(err, service.ErrDataTrimRowsChanged):
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, result)
+}
+
+// GetDBMetrics returns the database connection pool and the statement metrics per table
+func (h *AdminHandler) GetDBMetrics(c echo.Context) error {
+	metrics, err := h.storageService.GetDBMetrics()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, metrics)
+}
+
+// GetRedisKeys returns the redis keys under the key prefix with their TTL and key policy
+func (h *AdminHandler) GetRedisKeys(c echo.Context) error {
+	report, err := h.redisKeyService.GetRedisKeysReport(c.Request().Context(), false)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, report)
+}
+
+// ExpireRedisKeys gives the redis keys missing the TTL of their key policy the TTL
+func (h *AdminHandler) ExpireRedisKeys(c echo.Context) error {
+	report, err := h.redisKeyService.GetRedisKeysReport(c.Request().Context(), true)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, report)
+}
+
+// GetTickerLogs returns the ticker logs newest first, filtered by the `level` (repeatable), `event_type`,
+// `message` substring, `from` and `to` times and paged with the `before_id` of the last log returned
+func (h *AdminHandler) GetTickerLogs(c echo.Context) error {
+	filter := models.TickerLogFilter{
+		EventType: c.QueryParam("event_type"),
+		Message:   c.QueryParam("message"),
+		Limit:     100,
+	}
+	for _, level := range c.QueryParams()["level"] {
+		switch logLevel := models.LogLevel(strings.ToUpper(level)); logLevel {
+		case models.DEBUG, models.INFO, models.WARN, models.ERROR, models.FATAL:
+			filter.Levels = append(filter.Levels, logLevel)
+		default:
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `level`, must be DEBUG, INFO, WARN, ERROR or FATAL")
+		}
+	}
+	var message string
+	if filter.From, filter.To, message = parseQueryTimeRange(c, time.Time{}, time.Time{}); message != "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, message)
+	}
+	if beforeIdStr := c.QueryParam("before_id"); len(beforeIdStr) > 0 {
+		beforeId, err := strconv.ParseUint(beforeIdStr, 10, 32)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `before_id`")
+		}
+		filter.BeforeID = uint32(beforeId)
+	}
+	if limitStr := c.QueryParam("limit"); len(limitStr) > 0 {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > tickerLogsMaxLimit {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `limit`, must be between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+
+	logs, err := h.tickerService.GetTickerLogs(filter)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, logs)
+}
+
+// GetHistoricalQueue returns the queued kite historical requests per priority with the request counters
+func (h *AdminHandler) GetHistoricalQueue(c echo.Context) error {
+	return response.SuccessResponse(c, service.GetHistoricalScheduler().Status())
+}
+
+// GetTokenBudget returns the tokens subscribed by the ticker and stream connections with the headroom left
+func (h *AdminHandler) GetTokenBudget(c echo.Context) error {
+	return response.SuccessResponse(c, service.GetTokenBudget().Status())
+}
+
+// GetKillSwitch returns the engaged kill switches and the latest engage and release events
+func (h *AdminHandler) GetKillSwitch(c echo.Context) error {
+	status, err := h.killSwitchService.GetStatus()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, status)
+}
+
+// EngageKillSwitch immediately stops the ticker and the streams of a user, or of everyone and pauses
+// the cron jobs when no user is given
+func (h *AdminHandler) EngageKillSwitch(c echo.Context) error {
+	actor, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	req, status, message := decodeKillSwitchRequest(c)
+	if message != "" {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+
+	event, err := h.killSwitchService.Engage(req.UserID, actor, req.Reason)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, event)
+}
+
+// ReleaseKillSwitch re-enables a user, or everyone when no user is given, the reason is recorded in the audit trail
+func (h *AdminHandler) ReleaseKillSwitch(c echo.Context) error {
+	actor, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	req, status, message := decodeKillSwitchRequest(c)
+	if message != "" {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+
+	event, err := h.killSwitchService.Release(req.UserID, actor, req.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("Kill switch %s is not engaged", event.Scope))
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, event)
+}
+
+// decodeKillSwitchRequest decodes the body of a kill switch request, a non empty message describes the
+// invalid body along with its status
+func decodeKillSwitchRequest(c echo.Context) (KillSwitchRequestBody, int, string) {
+	var req KillSwitchRequestBody
+	if status, message := decodeJSONBody(c, &req, true); status != 0 {
+		return req, status, message
+	}
+	req.UserID = strings.ToUpper(strings.TrimSpace(req.UserID))
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return req, http.StatusBadRequest, "`reason` is required"
+	}
+	return req, 0, ""
+}
+
+// GetDataDelays returns the users restricted to delayed market data
+func (h *AdminHandler) GetDataDelays(c echo.Context) error {
+	delays, err := h.dataDelayService.GetDataDelays()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, delays)
+}
+
+// SetDataDelay restricts a user to delayed market data, e.g. where real-time redistribution is not permitted
+func (h *AdminHandler) SetDataDelay(c echo.Context) error {
+	actor, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	req, status, message := decodeDataDelayRequest(c)
+	if message != "" {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	if req.Reason == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`reason` is required")
+	}
+	if req.DelayMinutes < 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `delay_minutes` value, must not be negative")
+	}
+
+	delay, err := h.dataDelayService.SetDataDelay(req.UserID, req.DelayMinutes, req.Reason, actor)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, delay)
+}
+
+// RemoveDataDelay gives a user real-time market data again
+func (h *AdminHandler) RemoveDataDelay(c echo.Context) error {
+	actor, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	req, status, message := decodeDataDelayRequest(c)
+	if message != "" {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+
+	if err := h.dataDelayService.RemoveDataDelay(req.UserID, actor); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("User %s has no data delay", req.UserID))
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, map[string]string{"user_id": req.UserID})
+}
+
+// GetDataQuality returns the close reconciliation of a date, today by default, with the instruments whose
+// recorded last price diverged from the official bhavcopy close
+func (h *AdminHandler) GetDataQuality(c echo.Context) error {
+	date := c.QueryParam("date")
+	if len(date) == 0 {
+		date = service.MarketToday()
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `date` format")
+	}
+
+	report, err := h.closeReconcileService.GetReport(date)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("No close reconciliation for %s", date))
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, report)
+}
+
+// GetInstrumentBlacklist returns the instruments that are never subscribed or streamed
+func (h *AdminHandler) GetInstrumentBlacklist(c echo.Context) error {
+	instruments, err := h.blacklistService.GetBlacklistedInstruments()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, instruments)
+}
+
+// BlacklistInstruments blacklists instruments, e.g. illiquid contracts polluting the data or compliance
+// restricted symbols
+func (h *AdminHandler) BlacklistInstruments(c echo.Context) error {
+	actor, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	req, status, message := decodeInstrumentBlacklistRequest(c)
+	if message != "" {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	if req.Reason == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`reason` is required")
+	}
+
+	instruments, err := h.blacklistService.BlacklistInstruments(req.Instruments, req.Reason, actor)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, instruments)
+}
+
+// RemoveBlacklistedInstruments allows blacklisted instruments again
+func (h *AdminHandler) RemoveBlacklistedInstruments(c echo.Context) error {
+	actor, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	req, status, message := decodeInstrumentBlacklistRequest(c)
+	if message != "" {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+
+	removed, err := h.blacklistService.RemoveBlacklistedInstruments(req.Instruments, actor)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, map[string]int64{"removed": removed})
+}
+
+// decodeInstrumentBlacklistRequest decodes the body of an instrument blacklist request, a non empty message
+// describes the invalid body along with its status
+func decodeInstrumentBlacklistRequest(c echo.Context) (InstrumentBlacklistRequestBody, int, string) {
+	var req InstrumentBlacklistRequestBody
+	if status, message := decodeJSONBody(c, &req, true); status != 0 {
+		return req, status, message
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Instruments) == 0 {
+		return req, http.StatusBadRequest, "`instruments` is required"
+	}
+	for i, instrument := range req.Instruments {
+		instrument = strings.ToUpper(strings.TrimSpace(instrument))
+		if parts := strings.Split(instrument, ":"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return req, http.StatusBadRequest, fmt.Sprintf("Invalid instrument `%s`, must be EXCHANGE:TRADINGSYMBOL", req.Instruments[i])
+		}
+		req.Instruments[i] = instrument
+	}
+	return req, 0, ""
+}
+
+// decodeDataDelayRequest decodes the body of a data delay request, a non empty message describes the
+// invalid body along with its status
+func decodeDataDelayRequest(c echo.Context) (DataDelayRequestBody, int, string) {
+	var req DataDelayRequestBody
+	if status, message := decodeJSONBody(c, &req, true); status != 0 {
+		return req, status, message
+	}
+	req.UserID = strings.ToUpper(strings.TrimSpace(req.UserID))
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.UserID == "" {
+		return req, http.StatusBadRequest, "`user_id` is required"
+	}
+	return req, 0, ""
+}
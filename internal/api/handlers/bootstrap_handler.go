@@ -0,0 +1,42 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// BootstrapHandler is the handler for the bootstrap API
+type BootstrapHandler struct {
+	service *service.BootstrapService
+	apiURL  string
+}
+
+// NewBootstrapHandler creates a new handler for the bootstrap API, the stream urls are absolute urls under the
+// api url or, when it is blank, under the url the client reached the API at through the reverse proxies
+func NewBootstrapHandler(service *service.BootstrapService, apiURL string) *BootstrapHandler {
+	return &BootstrapHandler{service: service, apiURL: apiURL}
+}
+
+// GetBootstrap returns the version, profile, settings, exchanges, indices, ticker subscriptions and
+// stream urls of the user in a single call
+func (h *BootstrapHandler) GetBootstrap(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	baseURL := h.apiURL
+	if baseURL == "" {
+		baseURL = middleware.ExternalBaseURL(c)
+	}
+	bundle, err := h.service.GetBootstrap(userId, baseURL)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, bundle)
+}
@@ -0,0 +1,142 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"gorm.io/gorm"
+)
+
+// CandleHandler is the handler for the candles API
+type CandleHandler struct {
+	service *service.CandleService
+}
+
+// NewCandleHandler creates a new handler for the candles API
+func NewCandleHandler(service *service.CandleService) *CandleHandler {
+	return &CandleHandler{service: service}
+}
+
+// GetCandles returns the candles of an instrument resampled to the interval, today's candles by default
+func (h *CandleHandler) GetCandles(c echo.Context) error {
+	instrument, from, to, message := parseCandleQuery(c)
+	if message != "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, message)
+	}
+	interval, err := service.ParseCandleInterval(c.QueryParam("interval"))
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `interval` value, must be minute, <n>m, <n>h or day")
+	}
+	// users restricted to delayed data only get the candles completed the delay ago
+	if delay := middleware.GetDataDelayFromEchoContext(c); delay > 0 {
+		if cutoff := service.MarketNow().Add(-delay).Truncate(time.Minute); to.After(cutoff) {
+			to = cutoff
+		}
+	}
+
+	series, err := h.service.GetCandles(instrument, interval, from, to)
+	if err != nil {
+		return candleErrorResponse(c, instrument, err)
+	}
+	// the lineage is only returned when requested
+	if !wantsLineage(c) {
+		series.Meta = nil
+	}
+	return response.SuccessResponse(c, series)
+}
+
+// GetCandleGaps returns the runs of missing 1 minute candles of an instrument, today's gaps by default
+func (h *CandleHandler) GetCandleGaps(c echo.Context) error {
+	instrument, from, to, message := parseCandleQuery(c)
+	if message != "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, message)
+	}
+	minMinutes, err := parseCandleGapMinMinutes(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `min_minutes` value, must be a positive integer")
+	}
+
+	report, err := h.service.GetCandleGaps(instrument, from, to, minMinutes)
+	if err != nil {
+		return candleErrorResponse(c, instrument, err)
+	}
+	return response.SuccessResponse(c, report)
+}
+
+// BackfillCandles fills the gaps in the 1 minute candles of an instrument from the kite historical API
+// using the caller's session, today's gaps by default
+func (h *CandleHandler) BackfillCandles(c echo.Context) error {
+	userId, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	instrument, from, to, message := parseCandleQuery(c)
+	if message != "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, message)
+	}
+	minMinutes, err := parseCandleGapMinMinutes(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `min_minutes` value, must be a positive integer")
+	}
+
+	result, err := h.service.BackfillCandles(c.Request().Context(), userId, enctoken, instrument, from, to, minMinutes, service.HistoricalPriorityInteractive)
+	if err != nil {
+		return candleErrorResponse(c, instrument, err)
+	}
+	return response.SuccessResponse(c, result)
+}
+
+// parseCandleQuery parses the instrument and the range of a candles request, the range defaults to today.
+// A non empty message describes the invalid parameter
+func parseCandleQuery(c echo.Context) (string, time.Time, time.Time, string) {
+	instrument := strings.ToUpper(strings.TrimSpace(c.QueryParam("i")))
+	if instrument == "" {
+		return "", time.Time{}, time.Time{}, "`i` is required"
+	}
+	if !strings.Contains(instrument, ":") {
+		return "", time.Time{}, time.Time{}, "Invalid `i` format, must be exchange:tradingsymbol"
+	}
+
+	from, to := todayRange()
+	from, to, message := parseQueryTimeRange(c, from, to)
+	if message != "" {
+		return "", time.Time{}, time.Time{}, message
+	}
+	return instrument, from, to, ""
+}
+
+// parseCandleGapMinMinutes parses the optional min_minutes, 0 when absent for the configured default
+func parseCandleGapMinMinutes(c echo.Context) (int, error) {
+	value := c.QueryParam("min_minutes")
+	if value == "" {
+		return 0, nil
+	}
+	minMinutes, err := strconv.Atoi(value)
+	if err != nil || minMinutes <= 0 {
+		return 0, fmt.Errorf("invalid min_minutes %s", value)
+	}
+	return minMinutes, nil
+}
+
+// candleErrorResponse maps the errors of the candle service to responses
+func candleErrorResponse(c echo.Context, instrument string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("Instrument %s not found", instrument))
+	}
+	if errors.Is(err, service.ErrInvalidCandleRange) {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+	}
+	if errors.Is(err, service.ErrHistoricalQueueFull) {
+		return response.ErrorResponse(c, http.StatusServiceUnavailable, response.ServerException, err.Error())
+	}
+	return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+}
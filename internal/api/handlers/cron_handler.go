@@ -2,51 +2,123 @@
 package handlers
 
 import (
+	"net/http"
+
 	"github.com/labstack/echo/v4"
-	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
 	"github.com/nsvirk/moneybotsapi/internal/service"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 type CronHandler struct {
 	DB          *gorm.DB
 	CronService *service.CronService
+	JobService  *service.JobService
 }
 
-func NewCronHandler(e *echo.Echo, cfg *config.Config, db *gorm.DB, redisClient *redis.Client) *CronHandler {
+// NewCronHandler creates a new handler for the cron API, the jobs run on the scheduler of the cron service
+// so the dependencies, the timeout and the kill switch apply as for the scheduled runs
+func NewCronHandler(cronService *service.CronService, db *gorm.DB) *CronHandler {
 	return &CronHandler{
 		DB:          db,
-		CronService: service.NewCronService(e, cfg, db, redisClient),
+		CronService: cronService,
+		JobService:  service.NewJobService(db),
 	}
 }
 
-// UpdateInstruments updates the instruments
+// UpdateInstruments starts the instruments update job
 func (h *CronHandler) UpdateInstruments(c echo.Context) error {
-	h.CronService.ApiInstrumentsUpdateJob()
-	return response.SuccessResponse(c, "Instruments updated")
+	return h.submitJob(c, "instruments_update", service.JobApiInstrumentsUpdate)
+}
+
+// SyncNewInstruments starts the intraday instruments delta sync job
+func (h *CronHandler) SyncNewInstruments(c echo.Context) error {
+	return h.submitJob(c, "instruments_delta_sync", service.JobApiInstrumentsDeltaSync)
 }
 
+// UpdateIndices starts the indices update job
 func (h *CronHandler) UpdateIndices(c echo.Context) error {
-	h.CronService.ApiIndicesUpdateJob()
-	return response.SuccessResponse(c, "Indices updated")
+	return h.submitJob(c, "indices_update", service.JobApiIndicesUpdate)
 }
 
-// TickerInstrumentsUpdateJob updates the ticker instruments
+// TickerInstrumentsUpdateJob starts the ticker instruments update job
 func (h *CronHandler) TickerInstrumentsUpdateJob(c echo.Context) error {
-	h.CronService.TickerInstrumentsUpdateJob()
-	return response.SuccessResponse(c, "Ticker instruments updated")
+	return h.submitJob(c, "ticker_instruments_update", service.JobTickerInstrumentsUpdate)
+}
+
+// UpdatePriceBands starts the price bands update job
+func (h *CronHandler) UpdatePriceBands(c echo.Context) error {
+	return h.submitJob(c, "price_bands_update", service.JobPriceBandsUpdate)
+}
+
+// UpdateDeals starts the block and bulk deals update job
+func (h *CronHandler) UpdateDeals(c echo.Context) error {
+	return h.submitJob(c, "deals_update", service.JobDealsUpdate)
+}
+
+// RunCloseReconcile starts the close reconciliation job
+func (h *CronHandler) RunCloseReconcile(c echo.Context) error {
+	return h.submitJob(c, "close_reconcile", service.JobCloseReconcile)
+}
+
+// RunBackup starts the backup job of the critical tables
+func (h *CronHandler) RunBackup(c echo.Context) error {
+	return h.submitJob(c, "backup", service.JobBackup)
+}
+
+// RunDatabaseMaintenance starts the database maintenance job
+func (h *CronHandler) RunDatabaseMaintenance(c echo.Context) error {
+	return h.submitJob(c, "database_maintenance", service.JobDatabaseMaintenance)
+}
+
+// RunCandleBackfill starts the candle backfill job
+func (h *CronHandler) RunCandleBackfill(c echo.Context) error {
+	return h.submitJob(c, "candle_backfill", service.JobCandleBackfill)
+}
+
+// RunGreeksBackfill starts the historical greeks backfill job
+func (h *CronHandler) RunGreeksBackfill(c echo.Context) error {
+	return h.submitJob(c, "greeks_backfill", service.JobGreeksBackfill)
+}
+
+// RunSessionKeepAlive starts the session keep-alive job
+func (h *CronHandler) RunSessionKeepAlive(c echo.Context) error {
+	return h.submitJob(c, "session_keep_alive", service.JobSessionKeepAlive)
+}
+
+// submitJob runs the registered cron job in the background and returns the job tracking it, its progress is
+// available at /jobs/:id
+func (h *CronHandler) submitJob(c echo.Context, name string, cronJob string) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	if service.GetKillSwitch().IsGlobalEngaged() {
+		return response.ErrorResponse(c, http.StatusServiceUnavailable, response.ServerException, "Cron jobs are paused by the kill switch")
+	}
+
+	submittedJob, err := h.JobService.Submit(userId, name, func() (interface{}, error) {
+		return nil, h.CronService.RunJob(cronJob)
+	})
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, submittedJob)
 }
 
 // TickerStartJob starts the ticker
 func (h *CronHandler) TickerStartJob(c echo.Context) error {
-	h.CronService.TickerStartJob()
+	if err := h.CronService.TickerStartJob(); err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+	}
 	return response.SuccessResponse(c, "Ticker started")
 }
 
 // TickerStopJob stops the ticker
 func (h *CronHandler) TickerStopJob(c echo.Context) error {
-	h.CronService.TickerStopJob()
+	if err := h.CronService.TickerStopJob(); err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+	}
 	return response.SuccessResponse(c, "Ticker stopped")
 }
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -36,9 +37,9 @@ func NewIndexHandler(db *gorm.DB) *IndexHandler {
 
 // UpdateIndices updates the indices in the database
 func (h *IndexHandler) UpdateIndices(c echo.Context) error {
-	totalInserted, err := h.IndexService.UpdateIndices()
+	totalInserted, err := h.IndexService.UpdateIndices(c.Request().Context())
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 	}
 	responseData := UpdateIndexResponseData{
 		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
@@ -51,7 +52,7 @@ func (h *IndexHandler) UpdateIndices(c echo.Context) error {
 func (h *IndexHandler) GetAllIndices(c echo.Context) error {
 	indices, err := h.IndexService.GetAllIndices()
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 	}
 	result := make(map[string][]models.IndexModel, len(indices))
 	for _, index := range indices {
@@ -64,28 +65,51 @@ func (h *IndexHandler) GetAllIndices(c echo.Context) error {
 func (h *IndexHandler) GetIndicesByExchange(c echo.Context) error {
 	exchange := c.Param("exchange")
 	if exchange == "" || exchange == ":exchange" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`exchange` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`exchange` is required")
 	}
 	indices, err := h.IndexService.GetIndicesByExchange(exchange)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 	}
 	return response.SuccessResponse(c, indices)
 }
 
+// GetIndexLTP returns the last price of an index from the index ticks
+func (h *IndexHandler) GetIndexLTP(c echo.Context) error {
+	exchange := c.Param("exchange")
+	index := c.Param("index")
+	if exchange == "" || exchange == ":exchange" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`exchange` is required")
+	}
+	if index == "" || index == ":index" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`index` is required")
+	}
+	ltp, err := h.IndexService.GetIndexLTP(exchange, index)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("No ticks found for index %s:%s", exchange, index))
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, ltp)
+}
+
 // GetIndexInstruments returns a list of instruments for a given list of index names
 func (h *IndexHandler) GetIndexInstruments(c echo.Context) error {
 	exchange := c.Param("exchange")
 	index := c.Param("index")
 	if exchange == "" || exchange == ":exchange" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`exchange` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`exchange` is required")
 	}
 	if index == "" || index == ":index" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`index` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`index` is required")
 	}
 	instruments, err := h.IndexService.GetIndexInstruments(exchange, index)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Error fetching instruments for index %s: %v", index, err))
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, fmt.Sprintf("Error fetching instruments for index %s: %v", index, err))
+	}
+	if response.WantsCSV(c) {
+		return response.CSV(c, index+".csv", instruments)
 	}
 	return response.SuccessResponse(c, instruments)
 }
@@ -2,10 +2,13 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -19,6 +22,9 @@ type InstrumentHandler struct {
 	DB                *gorm.DB
 	InstrumentService *service.InstrumentService
 	IndexService      *service.IndexService
+	BasisService      *service.BasisService
+	BandService       *service.BandService
+	GreeksService     *service.GreeksService
 }
 
 func NewInstrumentHandler(db *gorm.DB) *InstrumentHandler {
@@ -26,6 +32,9 @@ func NewInstrumentHandler(db *gorm.DB) *InstrumentHandler {
 		DB:                db,
 		InstrumentService: service.NewInstrumentService(db),
 		IndexService:      service.NewIndexService(db),
+		BasisService:      service.NewBasisService(db),
+		BandService:       service.NewBandService(db),
+		GreeksService:     service.NewGreeksService(db),
 	}
 }
 
@@ -37,9 +46,9 @@ type UpdateInstrumentsResponseData struct {
 
 // UpdateInstruments updates the instruments in the database
 func (h *InstrumentHandler) UpdateInstruments(c echo.Context) error {
-	totalInserted, err := h.InstrumentService.UpdateInstruments()
+	totalInserted, err := h.InstrumentService.UpdateInstruments(c.Request().Context())
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 	}
 
 	responseData := UpdateInstrumentsResponseData{
@@ -50,17 +59,96 @@ func (h *InstrumentHandler) UpdateInstruments(c echo.Context) error {
 	return response.SuccessResponse(c, responseData)
 }
 
+// GetInstrumentBadRows returns the rows rejected by the last instruments load
+func (h *InstrumentHandler) GetInstrumentBadRows(c echo.Context) error {
+	badRows, err := h.InstrumentService.GetInstrumentBadRows()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+	}
+	return response.SuccessResponse(c, badRows)
+}
+
+// GetInstrumentStats returns the instrument counts by exchange, segment and instrument type with the last update time
+func (h *InstrumentHandler) GetInstrumentStats(c echo.Context) error {
+	stats, err := h.InstrumentService.GetInstrumentStats()
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, stats)
+}
+
+// GetInstrumentTokenMap returns the token to exchange:tradingsymbol mapping, of the instruments the ticker
+// streams with `subscribed=true`. The ETag lets consumers revalidate without downloading the mapping again
+func (h *InstrumentHandler) GetInstrumentTokenMap(c echo.Context) error {
+	subscribed := false
+	if subscribedStr := c.QueryParam("subscribed"); subscribedStr != "" {
+		var err error
+		subscribed, err = strconv.ParseBool(subscribedStr)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `subscribed` value, must be `true` or `false`")
+		}
+	}
+
+	tokenMap, err := h.InstrumentService.GetInstrumentTokenMap(subscribed)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+
+	c.Response().Header().Set("ETag", tokenMap.ETag)
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	if c.Request().Header.Get("If-None-Match") == tokenMap.ETag {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return response.SuccessResponse(c, tokenMap)
+}
+
+// GetInstrumentTokenHistory returns the stable id of an instrument and the tokens it had
+func (h *InstrumentHandler) GetInstrumentTokenHistory(c echo.Context) error {
+	instrument := c.QueryParam("i")
+	if len(instrument) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`i` is required")
+	}
+
+	history, err := h.InstrumentService.GetInstrumentTokenHistory(instrument)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("No instrument id found for %s", instrument))
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, history)
+}
+
+// GetInstrumentSync returns the instruments added, changed and removed since the `since_version` of the
+// client's last sync, all instruments when it is omitted. The response version is the client's next `since_version`
+func (h *InstrumentHandler) GetInstrumentSync(c echo.Context) error {
+	var sinceVersion uint64
+	if sinceVersionStr := c.QueryParam("since_version"); sinceVersionStr != "" {
+		var err error
+		sinceVersion, err = strconv.ParseUint(sinceVersionStr, 10, 64)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `since_version` value, must be a non-negative integer")
+		}
+	}
+
+	sync, err := h.InstrumentService.GetInstrumentSync(sinceVersion)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, sync)
+}
+
 // GetInstrumentsInfo returns instruments by symbols or tokens
 func (h *InstrumentHandler) GetInstrumentsInfo(c echo.Context) error {
 	symbols := c.QueryParams()["s"]
 	tokensStr := c.QueryParams()["t"]
 	// check if symbols or tokensStr is provided
 	if len(symbols) == 0 && len(tokensStr) == 0 {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`s` or `t` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`s` or `t` is required")
 	}
 	// check if both symbols and tokensStr is provided
 	if len(symbols) > 0 && len(tokensStr) > 0 {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Either `s` or `t` is required, not both")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Either `s` or `t` is required, not both")
 	}
 	// create a map to store the result
 	result := make(map[string]interface{})
@@ -68,7 +156,7 @@ func (h *InstrumentHandler) GetInstrumentsInfo(c echo.Context) error {
 	if len(symbols) > 0 {
 		symbolInstruments, err := h.InstrumentService.GetInstrumentsInfoBySymbols(symbols)
 		if err != nil {
-			return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+			return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 		}
 		for _, instrument := range symbolInstruments {
 			result[fmt.Sprintf("%s:%s", instrument.Exchange, instrument.Tradingsymbol)] = instrument
@@ -79,13 +167,13 @@ func (h *InstrumentHandler) GetInstrumentsInfo(c echo.Context) error {
 		for _, tokenStr := range tokensStr {
 			token, err := strconv.ParseUint(tokenStr, 10, 32)
 			if err != nil {
-				return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `instrument_token`, must be digits")
+				return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `instrument_token`, must be digits")
 			}
 			tokens = append(tokens, uint32(token))
 		}
 		tokenInstruments, err := h.InstrumentService.GetInstrumentsInfoByTokens(tokens)
 		if err != nil {
-			return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+			return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 		}
 		for _, instrument := range tokenInstruments {
 			result[fmt.Sprintf("%d", instrument.InstrumentToken)] = instrument
@@ -94,6 +182,31 @@ func (h *InstrumentHandler) GetInstrumentsInfo(c echo.Context) error {
 	return response.SuccessResponse(c, result)
 }
 
+// instrumentsResolveMaxIdentifiers is the maximum number of identifiers per resolve request
+const instrumentsResolveMaxIdentifiers = 5000
+
+// ResolveInstruments resolves a mixed list of instrument tokens and exchange:tradingsymbol identifiers
+func (h *InstrumentHandler) ResolveInstruments(c echo.Context) error {
+	var req struct {
+		Instruments []string `json:"instruments"`
+	}
+	if status, message := decodeJSONBody(c, &req, false); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	if len(req.Instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Instruments array cannot be empty")
+	}
+	if len(req.Instruments) > instrumentsResolveMaxIdentifiers {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, fmt.Sprintf("Too many instruments, maximum is %d", instrumentsResolveMaxIdentifiers))
+	}
+
+	resolutions, err := h.InstrumentService.ResolveInstruments(req.Instruments)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+	}
+	return response.SuccessResponse(c, resolutions)
+}
+
 // GetInstrumentsQuery returns a list of instruments for a given exchange, tradingsymbol, expiry, strike and segment
 func (h *InstrumentHandler) GetInstrumentsQuery(c echo.Context) error {
 	// get the exchange, tradingsymbol, instrument_token, name, expiry, strike and segment from the request
@@ -105,24 +218,43 @@ func (h *InstrumentHandler) GetInstrumentsQuery(c echo.Context) error {
 	strike := c.QueryParam("strike")
 	segment := c.QueryParam("segment")
 	instrumentType := c.QueryParam("instrument_type")
+	isWeeklyExpiry := c.QueryParam("is_weekly_expiry")
+	expirySeries := c.QueryParam("expiry_series")
+	asOf := c.QueryParam("as_of")
 	// check instrumentToken is all digits
 	if len(instrumentToken) > 0 && !regexp.MustCompile(`^\d+$`).MatchString(instrumentToken) {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `instrument_token` value, must be digits")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `instrument_token` value, must be digits")
 	}
 	// check if expiry is input and is a valid date
 	if len(expiry) > 0 {
 		_, err := time.Parse("2006-01-02", expiry)
 		if err != nil {
-			return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `expiry` value, must be a valid date")
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `expiry` value, must be a valid date")
 		}
 	}
 	// check if strike is just digits if not blank
 	if len(strike) > 0 && !regexp.MustCompile(`^\d+$`).MatchString(strike) {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `strike` value, must be digits")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `strike` value, must be digits")
 	}
 	// Check if instrument_type is one of FUT, CE, PE, EQ or include % anywhere in the string
 	if len(instrumentType) > 0 && !regexp.MustCompile(`^(FUT|CE|PE|EQ)$|%`).MatchString(instrumentType) {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `instrument_type` value, must be `FUT`, `CE`, `PE` or `EQ` or include `%`")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `instrument_type` value, must be `FUT`, `CE`, `PE` or `EQ` or include `%`")
+	}
+	// check is_weekly_expiry is a boolean
+	if len(isWeeklyExpiry) > 0 {
+		if _, err := strconv.ParseBool(isWeeklyExpiry); err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `is_weekly_expiry` value, must be `true` or `false`")
+		}
+	}
+	// check expiry_series is one of weekly, monthly
+	if len(expirySeries) > 0 && expirySeries != models.ExpirySeriesWeekly && expirySeries != models.ExpirySeriesMonthly {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `expiry_series` value, must be `weekly` or `monthly`")
+	}
+	// check as_of is a valid date
+	if len(asOf) > 0 {
+		if _, err := time.Parse("2006-01-02", asOf); err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `as_of` value, must be a valid date")
+		}
 	}
 	// Create the query instruments params
 	queryInstrumentsParams := models.QueryInstrumentsParams{
@@ -134,11 +266,17 @@ func (h *InstrumentHandler) GetInstrumentsQuery(c echo.Context) error {
 		Strike:          strike,
 		Segment:         segment,
 		InstrumentType:  instrumentType,
+		IsWeeklyExpiry:  isWeeklyExpiry,
+		ExpirySeries:    expirySeries,
+		AsOf:            asOf,
 	}
 	// get the instruments
 	instruments, err := h.InstrumentService.GetInstrumentsQuery(queryInstrumentsParams)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+	}
+	if response.WantsCSV(c) {
+		return response.CSV(c, "instruments.csv", instruments)
 	}
 	return response.SuccessResponse(c, instruments)
 }
@@ -147,18 +285,18 @@ func (h *InstrumentHandler) GetInstrumentsQuery(c echo.Context) error {
 func (h *InstrumentHandler) GetFNOSegmentWiseName(c echo.Context) error {
 	expiry := c.Param("expiry")
 	if len(expiry) == 0 || expiry == ":expiry" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`expiry` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`expiry` is required")
 	}
 
 	// check if expiry is valid date
 	_, err := time.Parse("2006-01-02", expiry)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid `expiry` format")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `expiry` format")
 	}
 
 	instruments, err := h.InstrumentService.GetFNOSegmentWiseName(expiry)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 	}
 
 	// create a map of segment to names
@@ -181,12 +319,12 @@ func (h *InstrumentHandler) GetFNOSegmentWiseExpiry(c echo.Context) error {
 	var offset int = 0
 
 	if len(name) == 0 || name == ":name" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`name` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`name` is required")
 	}
 
 	instruments, err := h.InstrumentService.GetFNOSegmentWiseExpiry(name, limit, offset)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 	}
 
 	// create a map of names to segments
@@ -201,3 +339,194 @@ func (h *InstrumentHandler) GetFNOSegmentWiseExpiry(c echo.Context) error {
 		return response.SuccessResponse(c, instruments)
 	}
 }
+
+// GetFNOStrikeInterval returns the strike interval per expiry for a given name
+func (h *InstrumentHandler) GetFNOStrikeInterval(c echo.Context) error {
+	name := c.QueryParam("name")
+	expiry := c.QueryParam("expiry")
+
+	if len(name) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`name` is required")
+	}
+	if len(expiry) > 0 {
+		if _, err := time.Parse("2006-01-02", expiry); err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `expiry` format")
+		}
+	}
+
+	strikeIntervals, err := h.InstrumentService.GetFNOStrikeIntervals(name, expiry)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+	}
+	return response.SuccessResponse(c, strikeIntervals)
+}
+
+// GetFNOOptionChain returns the option chain for a given name and option expiry,
+// optionally windowed around the ATM strike and paginated
+func (h *InstrumentHandler) GetFNOOptionChain(c echo.Context) error {
+	exchange := c.QueryParam("exchange")
+	name := c.QueryParam("name")
+	futExpiry := c.QueryParam("fut_expiry")
+	optExpiry := c.QueryParam("opt_expiry")
+
+	if len(exchange) == 0 {
+		exchange = "NFO"
+	}
+	if len(name) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`name` is required")
+	}
+	if len(optExpiry) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`opt_expiry` is required")
+	}
+	for param, value := range map[string]string{"fut_expiry": futExpiry, "opt_expiry": optExpiry} {
+		if len(value) > 0 {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, fmt.Sprintf("Invalid `%s` format", param))
+			}
+		}
+	}
+
+	// window and pagination params, -1 means not set. The window may be 0 strikes, pages start at 1
+	intParams := map[string]int{"strikes_above": -1, "strikes_below": -1, "page": -1, "page_size": -1}
+	for param := range intParams {
+		if value := c.QueryParam(param); len(value) > 0 {
+			intValue, err := strconv.Atoi(value)
+			if param == "page" || param == "page_size" {
+				if err != nil || intValue <= 0 {
+					return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, fmt.Sprintf("Invalid `%s` value, must be a positive number", param))
+				}
+			} else if err != nil || intValue < 0 {
+				return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, fmt.Sprintf("Invalid `%s` value, must be a non-negative number", param))
+			}
+			intParams[param] = intValue
+		}
+	}
+
+	chain, err := h.InstrumentService.GetFNOOptionChain(exchange, name, futExpiry, optExpiry)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+	}
+
+	if intParams["strikes_above"] >= 0 || intParams["strikes_below"] >= 0 {
+		if err := h.InstrumentService.ResolveOptionChainATM(&chain); err != nil {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("Unable to resolve ATM strike: %v", err))
+		}
+	} else {
+		// ATM is informational without a window
+		_ = h.InstrumentService.ResolveOptionChainATM(&chain)
+	}
+
+	chain = service.WindowOptionChain(chain, intParams["strikes_below"], intParams["strikes_above"], intParams["page"], intParams["page_size"])
+	return response.SuccessResponse(c, chain)
+}
+
+// GetFNORollover returns the futures rollover of a name
+func (h *InstrumentHandler) GetFNORollover(c echo.Context) error {
+	exchange := c.QueryParam("exchange")
+	name := c.QueryParam("name")
+
+	if len(exchange) == 0 {
+		exchange = "NFO"
+	}
+	if len(name) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`name` is required")
+	}
+
+	rollover, err := h.InstrumentService.GetFNORollover(exchange, name)
+	if err != nil {
+		if errors.Is(err, service.ErrNoActiveFutures) {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, err.Error())
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
+	}
+	return response.SuccessResponse(c, rollover)
+}
+
+// GetFNOBasis returns the basis (future minus spot) snapshots of the futures of a name
+func (h *InstrumentHandler) GetFNOBasis(c echo.Context) error {
+	name := c.QueryParam("name")
+	expiry := c.QueryParam("expiry")
+
+	if len(name) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`name` is required")
+	}
+	if len(expiry) > 0 {
+		if _, err := time.Parse("2006-01-02", expiry); err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `expiry` format")
+		}
+	}
+
+	// defaults to today in the market time zone
+	from, to := todayRange()
+	from, to, message := parseQueryTimeRange(c, from, to)
+	if message != "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, message)
+	}
+	if to.Before(from) {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`to` must not be before `from`")
+	}
+
+	basis, err := h.BasisService.GetFuturesBasis(name, expiry, from, to)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, basis)
+}
+
+// GetFNOGreeksHistory returns the historical implied volatility and greeks of an option, or of the options of a
+// name optionally for an expiry
+func (h *InstrumentHandler) GetFNOGreeksHistory(c echo.Context) error {
+	instrument := strings.ToUpper(strings.TrimSpace(c.QueryParam("instrument")))
+	name := strings.ToUpper(strings.TrimSpace(c.QueryParam("name")))
+	expiry := c.QueryParam("expiry")
+
+	if len(instrument) == 0 && len(name) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`instrument` or `name` is required")
+	}
+	if len(instrument) > 0 && !strings.Contains(instrument, ":") {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`instrument` must be exchange:tradingsymbol")
+	}
+	if len(expiry) > 0 {
+		if _, err := time.Parse("2006-01-02", expiry); err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `expiry` format")
+		}
+	}
+
+	// defaults to today in the market time zone
+	from, to := todayRange()
+	from, to, message := parseQueryTimeRange(c, from, to)
+	if message != "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, message)
+	}
+	if to.Before(from) {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`to` must not be before `from`")
+	}
+
+	greeks, err := h.GreeksService.GetOptionGreeks(instrument, name, expiry, from, to)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, greeks)
+}
+
+// GetPriceBands returns the price band and circuit limits of an instrument, the exchange defaults to NSE
+func (h *InstrumentHandler) GetPriceBands(c echo.Context) error {
+	symbol, err := url.PathUnescape(c.Param("symbol"))
+	if err != nil || len(strings.TrimSpace(symbol)) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `symbol`")
+	}
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if !strings.Contains(symbol, ":") {
+		symbol = "NSE:" + symbol
+	}
+
+	bands, err := h.BandService.GetPriceBands([]string{symbol})
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	band, ok := bands[symbol]
+	if !ok {
+		return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("No price band found for %s", symbol))
+	}
+	return response.SuccessResponse(c, band)
+}
@@ -0,0 +1,155 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// InstrumentTagHandler is the handler for the instrument tags API
+type InstrumentTagHandler struct {
+	service *service.InstrumentTagService
+}
+
+// NewInstrumentTagHandler creates a new handler for the instrument tags API
+func NewInstrumentTagHandler(service *service.InstrumentTagService) *InstrumentTagHandler {
+	return &InstrumentTagHandler{service: service}
+}
+
+// InstrumentTagRequestBody is the body of a tag or untag request, the instruments are in the
+// EXCHANGE:TRADINGSYMBOL format
+type InstrumentTagRequestBody struct {
+	Instruments []string `json:"instruments"`
+}
+
+// GetInstrumentTags returns the tags of the user and the global tags with their number of instruments
+func (h *InstrumentTagHandler) GetInstrumentTags(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	tags, err := h.service.GetTags(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, tags)
+}
+
+// GetTaggedInstruments returns the instruments carrying the tag of the user or the global tag
+func (h *InstrumentTagHandler) GetTaggedInstruments(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	tag, err := service.NormalizeInstrumentTag(c.Param("tag"))
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+	}
+	instruments, err := h.service.ResolveTags(userId, []string{tag})
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	if len(instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("No instruments tagged %s", tag))
+	}
+	return response.SuccessResponse(c, map[string]interface{}{
+		"tag":         tag,
+		"instruments": instruments,
+	})
+}
+
+// TagInstruments tags the instruments with a tag of the user
+func (h *InstrumentTagHandler) TagInstruments(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	return h.tagInstruments(c, userId)
+}
+
+// UntagInstruments removes a tag of the user from the instruments, the tag is removed when no instruments are given
+func (h *InstrumentTagHandler) UntagInstruments(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	return h.untagInstruments(c, userId)
+}
+
+// TagGlobalInstruments tags the instruments with a global tag visible to all users
+func (h *InstrumentTagHandler) TagGlobalInstruments(c echo.Context) error {
+	return h.tagInstruments(c, service.GlobalTagOwner)
+}
+
+// UntagGlobalInstruments removes a global tag from the instruments, the tag is removed when no instruments are given
+func (h *InstrumentTagHandler) UntagGlobalInstruments(c echo.Context) error {
+	return h.untagInstruments(c, service.GlobalTagOwner)
+}
+
+// tagInstruments tags the instruments of the request with the tag of the owner
+func (h *InstrumentTagHandler) tagInstruments(c echo.Context, owner string) error {
+	tag, req, status, message := decodeInstrumentTagRequest(c)
+	if message != "" {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	if len(req.Instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`instruments` is required")
+	}
+	tagged, err := h.service.TagInstruments(owner, tag, req.Instruments)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, map[string]interface{}{
+		"tag":    tag,
+		"tagged": tagged,
+	})
+}
+
+// untagInstruments removes the tag of the owner from the instruments of the request, or from all its instruments
+func (h *InstrumentTagHandler) untagInstruments(c echo.Context, owner string) error {
+	tag, req, status, message := decodeInstrumentTagRequest(c)
+	if message != "" {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	removed, err := h.service.UntagInstruments(owner, tag, req.Instruments)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, map[string]interface{}{
+		"tag":     tag,
+		"removed": removed,
+	})
+}
+
+// decodeInstrumentTagRequest decodes the tag of the path and the optional body of a tag request, a non empty
+// message describes the invalid request along with its status
+func decodeInstrumentTagRequest(c echo.Context) (string, InstrumentTagRequestBody, int, string) {
+	var req InstrumentTagRequestBody
+	tag, err := service.NormalizeInstrumentTag(c.Param("tag"))
+	if err != nil {
+		return "", req, http.StatusBadRequest, err.Error()
+	}
+	decoder := json.NewDecoder(c.Request().Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		status, message := bodyError(err, "Invalid JSON body")
+		return tag, req, status, message
+	}
+	for i, instrument := range req.Instruments {
+		instrument = strings.ToUpper(strings.TrimSpace(instrument))
+		if parts := strings.Split(instrument, ":"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return tag, req, http.StatusBadRequest, fmt.Sprintf("Invalid instrument `%s`, must be EXCHANGE:TRADINGSYMBOL", req.Instruments[i])
+		}
+		req.Instruments[i] = instrument
+	}
+	return tag, req, 0, ""
+}
@@ -0,0 +1,50 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"gorm.io/gorm"
+)
+
+// JobHandler is the handler for the jobs API
+type JobHandler struct {
+	service *service.JobService
+}
+
+// NewJobHandler creates a new handler for the jobs API
+func NewJobHandler(service *service.JobService) *JobHandler {
+	return &JobHandler{service: service}
+}
+
+// GetJob returns the status and result of a job
+func (h *JobHandler) GetJob(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `id`")
+	}
+
+	job, err := h.service.GetJob(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, "Job not found")
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	if job.UserID != userId {
+		return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, "Job not found")
+	}
+
+	return response.SuccessResponse(c, job)
+}
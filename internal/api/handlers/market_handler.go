@@ -0,0 +1,44 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// MarketHandler is the handler for the market API
+type MarketHandler struct {
+	dealService *service.DealService
+}
+
+// NewMarketHandler creates a new handler for the market API
+func NewMarketHandler(dealService *service.DealService) *MarketHandler {
+	return &MarketHandler{dealService: dealService}
+}
+
+// GetDeals returns the block and bulk deals of a date, today by default
+func (h *MarketHandler) GetDeals(c echo.Context) error {
+	date := c.QueryParam("date")
+	symbol := c.QueryParam("symbol")
+	dealType := c.QueryParam("type")
+
+	if len(date) == 0 {
+		date = time.Now().In(service.MarketLocation).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `date` format")
+	}
+	if len(dealType) > 0 && dealType != models.DealTypeBulk && dealType != models.DealTypeBlock {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`type` must be bulk or block")
+	}
+
+	deals, err := h.dealService.GetDeals(date, symbol, dealType)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, deals)
+}
@@ -0,0 +1,56 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// activityMaxLimit is the maximum number of activity events returned by a request
+const activityMaxLimit = 1000
+
+// MeHandler is the handler for the API of the authenticated user
+type MeHandler struct {
+	activityService *service.ActivityService
+}
+
+// NewMeHandler creates a new handler for the API of the authenticated user
+func NewMeHandler(activityService *service.ActivityService) *MeHandler {
+	return &MeHandler{activityService: activityService}
+}
+
+// GetActivity returns the user's own activity events, the last 7 days by default
+func (h *MeHandler) GetActivity(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	since := time.Now().AddDate(0, 0, -7)
+	if sinceStr := c.QueryParam("since"); len(sinceStr) > 0 {
+		t, err := parseQueryTime(sinceStr)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `since` format")
+		}
+		since = t
+	}
+	limit := 100
+	if limitStr := c.QueryParam("limit"); len(limitStr) > 0 {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > activityMaxLimit {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `limit`, must be between 1 and 1000")
+		}
+	}
+
+	events, err := h.activityService.GetActivityEvents(userId, c.QueryParam("action"), since, limit)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, events)
+}
@@ -0,0 +1,92 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// NewsHandler is the handler for the news API
+type NewsHandler struct {
+	service *service.NewsService
+}
+
+// NewNewsHandler creates a new handler for the news API
+func NewNewsHandler(service *service.NewsService) *NewsHandler {
+	return &NewsHandler{service: service}
+}
+
+// GetNews returns the announcements published since, today by default, optionally for a symbol
+func (h *NewsHandler) GetNews(c echo.Context) error {
+	symbol := c.QueryParam("symbol")
+	sinceStr := c.QueryParam("since")
+
+	now := time.Now().In(service.MarketLocation)
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, service.MarketLocation)
+	if len(sinceStr) > 0 {
+		t, err := parseQueryTime(sinceStr)
+		if err != nil {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `since` format")
+		}
+		since = t
+	}
+
+	announcements, err := h.service.GetAnnouncements(symbol, since)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, announcements)
+}
+
+// StreamNews streams new announcements as server-sent events, optionally for a symbol
+func (h *NewsHandler) StreamNews(c echo.Context) error {
+	symbol := strings.ToUpper(c.QueryParam("symbol"))
+	ctx := c.Request().Context()
+
+	announcements, unsubscribe := service.GetNewsHub().Subscribe()
+	defer unsubscribe()
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
+	c.Response().WriteHeader(http.StatusOK)
+	if _, err := c.Response().Write([]byte("data: connected\n\n")); err != nil {
+		return nil
+	}
+	c.Response().Flush()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case announcement := <-announcements:
+			if symbol != "" && announcement.Symbol != symbol {
+				continue
+			}
+			data, err := json.Marshal(announcement)
+			if err != nil {
+				continue
+			}
+			if _, err := c.Response().Write([]byte(fmt.Sprintf("event: announcement\ndata: %s\n\n", data))); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+		case <-ticker.C:
+			// Send a keep-alive message every 30 seconds
+			if _, err := c.Response().Write([]byte(": keep-alive\n\n")); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"gorm.io/gorm"
+)
+
+// NotificationHandler is the handler for the notification preferences API
+type NotificationHandler struct {
+	service *service.NotifierService
+}
+
+// NewNotificationHandler creates a new handler for the notification preferences API
+func NewNotificationHandler(service *service.NotifierService) *NotificationHandler {
+	return &NotificationHandler{service: service}
+}
+
+// GetNotificationPreferences returns the notification preferences of the user
+func (h *NotificationHandler) GetNotificationPreferences(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	preferences, err := h.service.GetNotificationPreferences(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, preferences)
+}
+
+// SaveNotificationPreferences replaces the notification preferences of the user
+func (h *NotificationHandler) SaveNotificationPreferences(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	var preferences models.NotificationPreferences
+	if status, message := decodeJSONBody(c, &preferences, true); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	if err := service.ValidateNotificationPreferences(preferences); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+	}
+
+	if err := h.service.SaveNotificationPreferences(userId, preferences); err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	if preferences.Categories == nil {
+		preferences.Categories = make([]string, 0)
+	}
+	return response.SuccessResponse(c, preferences)
+}
+
+// DeleteNotificationPreferences deletes the notification preferences of the user
+func (h *NotificationHandler) DeleteNotificationPreferences(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	deletedCount, err := h.service.DeleteNotificationPreferences(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, deletedCount > 0)
+}
+
+// SendTestNotification sends a test notification over the configured channels of the user
+func (h *NotificationHandler) SendTestNotification(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	results, err := h.service.SendTestNotification(userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, "No notification preferences found")
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, results)
+}
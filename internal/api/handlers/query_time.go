@@ -0,0 +1,43 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+)
+
+// parseQueryTime parses a date or a date time in the market time zone, or an RFC 3339 time
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, service.MarketLocation); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, service.MarketLocation); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseQueryTimeRange parses the optional `from` and `to` query params, an absent param keeps its default.
+// A non empty message describes the invalid param
+func parseQueryTimeRange(c echo.Context, from, to time.Time) (time.Time, time.Time, string) {
+	var err error
+	if fromStr := c.QueryParam("from"); len(fromStr) > 0 {
+		if from, err = parseQueryTime(fromStr); err != nil {
+			return from, to, "Invalid `from` format"
+		}
+	}
+	if toStr := c.QueryParam("to"); len(toStr) > 0 {
+		if to, err = parseQueryTime(toStr); err != nil {
+			return from, to, "Invalid `to` format"
+		}
+	}
+	return from, to, ""
+}
+
+// todayRange returns the start of today and now in the market time zone, the default range of the queries
+func todayRange() (time.Time, time.Time) {
+	now := time.Now().In(service.MarketLocation)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, service.MarketLocation), now
+}
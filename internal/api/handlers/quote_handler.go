@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
 )
 
@@ -22,32 +26,191 @@ func NewQuoteHandler(service *service.QuoteService) *QuoteHandler {
 	return &QuoteHandler{service: service}
 }
 
-// GetQuote gets the quote for the given instruments
+// GetQuote gets the quote for the given instruments, with the circuit limits of instruments with a price band
 func (h *QuoteHandler) GetQuote(c echo.Context) error {
-	return h.handleRequest(c, mapTickToQuoteData)
+	// users restricted to delayed data get the quotes from the candles
+	if delay := middleware.GetDataDelayFromEchoContext(c); delay > 0 {
+		return h.handleDelayedRequest(c, delay, true)
+	}
+	// price bands are optional, quotes without a band have zero limits
+	bandMap, err := h.service.GetPriceBands(c.QueryParams()["i"])
+	if err != nil {
+		log.Printf("Error fetching price bands: %v", err)
+	}
+	return h.handleRequest(c, func(tick *models.TickerData, prevClose decimal.Decimal) interface{} {
+		quote := mapTickToQuoteData(tick, prevClose).(models.QuoteData)
+		if band, ok := bandMap[quote.Instrument]; ok {
+			quote.LowerCircuitLimit, quote.UpperCircuitLimit = service.PriceBandLimits(band.BandPercent, quote.PreviousClose)
+		}
+		return quote
+	})
 }
 
 // GetOHLC gets the OHLC data for the given instruments
 func (h *QuoteHandler) GetOHLC(c echo.Context) error {
+	if delay := middleware.GetDataDelayFromEchoContext(c); delay > 0 {
+		return h.handleDelayedRequest(c, delay, true)
+	}
 	return h.handleRequest(c, mapTickToOHLCData)
 }
 
 // GetLTP gets the LTP data for the given instruments
 func (h *QuoteHandler) GetLTP(c echo.Context) error {
+	if delay := middleware.GetDataDelayFromEchoContext(c); delay > 0 {
+		return h.handleDelayedRequest(c, delay, false)
+	}
 	return h.handleRequest(c, mapTickToLTPData)
 }
 
+// GetDepth gets the latest 5 level market depth of the given instruments, depth is real-time only and delayed
+// users are rejected by the RealTimeDataMiddleware
+func (h *QuoteHandler) GetDepth(c echo.Context) error {
+	return h.handleRequest(c, mapTickToDepthData)
+}
+
+// GetPrevClose gets the previous close for the given instruments
+func (h *QuoteHandler) GetPrevClose(c echo.Context) error {
+	instruments := c.QueryParams()["i"]
+	if len(instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "No instruments specified")
+	}
+
+	prevCloseMap, err := h.service.GetPrevCloses(instruments)
+	if err != nil {
+		log.Printf("Error fetching previous closes: %v", err)
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, fmt.Sprintf("Error fetching previous closes: %v", err))
+	}
+
+	quoteResponse := models.QuoteResponse{
+		Status: "success",
+		Data:   make(map[string]interface{}),
+	}
+
+	meta := wantsLineage(c)
+	for _, instrument := range instruments {
+		if prevClose, ok := prevCloseMap[instrument]; ok {
+			quoteResponse.Data[instrument] = models.PrevCloseData{
+				InstrumentToken: prevClose.InstrumentToken,
+				PreviousClose:   prevClose.PrevClose,
+				Date:            prevClose.Date,
+				Source:          prevClose.Source,
+			}
+			if meta {
+				addLineage(&quoteResponse, instrument, models.DataLineage{
+					Source:          models.PrevCloseLineageSource(prevClose.Source),
+					SourceTimestamp: prevClose.UpdatedAt.In(service.MarketLocation).Format("2006-01-02 15:04:05"),
+				})
+			}
+		}
+	}
+
+	if len(quoteResponse.Data) == 0 {
+		return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("No data found for instruments: %v", instruments))
+	}
+
+	if response.WantsCSV(c) {
+		return response.CSVKeyed(c, "prev_close.csv", "instrument", instruments, quoteResponse.Data)
+	}
+	return response.JSON(c, http.StatusOK, quoteResponse)
+}
+
+// GetDelayedLTP gets the delayed last price of the given instruments, the close of the last 1 minute candle
+// completed the public quote delay ago
+func (h *QuoteHandler) GetDelayedLTP(c echo.Context) error {
+	return h.handleDelayedRequest(c, service.GetPublicQuoteDelay(), false)
+}
+
+// handleDelayedRequest answers a quote request with the quotes as they were the delay ago, from the 1 minute
+// candles instead of the ticker data. Quotes include the day's OHLC, LTPs only the last price
+func (h *QuoteHandler) handleDelayedRequest(c echo.Context, delay time.Duration, quote bool) error {
+	instruments := c.QueryParams()["i"]
+	if len(instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "No instruments specified")
+	}
+
+	candleMap, err := h.service.GetDelayedCandles(instruments, delay)
+	if err != nil {
+		log.Printf("Error fetching delayed candles: %v", err)
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, fmt.Sprintf("Error fetching delayed quotes: %v", err))
+	}
+
+	// previous closes are optional, the change is 0 without one
+	prevCloseMap, err := h.service.GetPrevCloses(instruments)
+	if err != nil {
+		log.Printf("Error fetching previous closes: %v", err)
+	}
+
+	quoteResponse := models.QuoteResponse{
+		Status: "success",
+		Data:   make(map[string]interface{}),
+	}
+	meta := wantsLineage(c)
+	for _, instrument := range instruments {
+		candle, ok := candleMap[instrument]
+		if !ok {
+			continue
+		}
+		lastPrice := candle.Close
+		prevClose := prevCloseMap[instrument].PrevClose
+		timestamp := candle.Timestamp.Add(time.Minute).In(service.MarketLocation).Format("2006-01-02 15:04:05")
+		if meta {
+			addLineage(&quoteResponse, instrument, models.DataLineage{
+				Source:          models.CandleLineageSource(candle.Source),
+				SourceTimestamp: timestamp,
+				PrevCloseSource: prevCloseLineageSource(prevCloseMap[instrument]),
+			})
+		}
+		if quote {
+			quoteResponse.Data[instrument] = models.DelayedQuoteData{
+				InstrumentToken: candle.InstrumentToken,
+				LastPrice:       lastPrice,
+				PreviousClose:   prevClose,
+				ChangePercent:   changePercent(lastPrice, prevClose),
+				OHLC:            models.OHLC{Open: candle.Open, High: candle.High, Low: candle.Low, Close: candle.Close},
+				Volume:          candle.Volume,
+				OI:              candle.OI,
+				Timestamp:       timestamp,
+				DelayMinutes:    int(delay.Minutes()),
+			}
+			continue
+		}
+		quoteResponse.Data[instrument] = models.DelayedLTPData{
+			InstrumentToken: candle.InstrumentToken,
+			LastPrice:       lastPrice,
+			PreviousClose:   prevClose,
+			ChangePercent:   changePercent(lastPrice, prevClose),
+			Timestamp:       timestamp,
+			DelayMinutes:    int(delay.Minutes()),
+		}
+	}
+
+	if len(quoteResponse.Data) == 0 {
+		return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("No data found for instruments: %v", instruments))
+	}
+
+	if response.WantsCSV(c) {
+		return response.CSVKeyed(c, "quote_delayed.csv", "instrument", instruments, quoteResponse.Data)
+	}
+	return response.JSON(c, http.StatusOK, quoteResponse)
+}
+
 // handleRequest is the common function to handle the request for the quote API
-func (h *QuoteHandler) handleRequest(c echo.Context, mapper func(*models.TickerData) interface{}) error {
+func (h *QuoteHandler) handleRequest(c echo.Context, mapper func(*models.TickerData, decimal.Decimal) interface{}) error {
 	instruments := c.QueryParams()["i"]
 	if len(instruments) == 0 {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "No instruments specified")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "No instruments specified")
 	}
 
 	tickDataMap, err := h.service.GetTickData(instruments)
 	if err != nil {
 		log.Printf("Error fetching tick data: %v", err)
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", fmt.Sprintf("Error fetching tick data: %v", err))
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, fmt.Sprintf("Error fetching tick data: %v", err))
+	}
+
+	// previous closes are optional, mappers fall back to the tick's OHLC close
+	prevCloseMap, err := h.service.GetPrevCloses(instruments)
+	if err != nil {
+		log.Printf("Error fetching previous closes: %v", err)
 	}
 
 	quoteResponse := models.QuoteResponse{
@@ -55,15 +218,52 @@ func (h *QuoteHandler) handleRequest(c echo.Context, mapper func(*models.TickerD
 		Data:   make(map[string]interface{}),
 	}
 
+	meta := wantsLineage(c)
+	found := make([]string, 0, len(instruments))
 	for _, instrument := range instruments {
 		if tickData, ok := tickDataMap[instrument]; ok {
-			quoteResponse.Data[instrument] = mapper(tickData)
+			quoteResponse.Data[instrument] = mapper(tickData, prevCloseMap[instrument].PrevClose)
+			found = append(found, instrument)
+			if meta {
+				addLineage(&quoteResponse, instrument, models.DataLineage{
+					Source:          models.LineageLiveTick,
+					SourceTimestamp: quoteAsOf(tickData).Format("2006-01-02 15:04:05"),
+					PrevCloseSource: prevCloseLineageSource(prevCloseMap[instrument]),
+				})
+			}
 		}
 	}
+	service.GetInstrumentAccessTracker().RecordQuery(found...)
 
 	if len(quoteResponse.Data) == 0 {
-		return response.ErrorResponse(c, http.StatusNotFound, "DataNotFound", fmt.Sprintf("No data found for instruments: %v", instruments))
+		return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, fmt.Sprintf("No data found for instruments: %v", instruments))
 	}
 
-	return c.JSON(http.StatusOK, quoteResponse)
+	if response.WantsCSV(c) {
+		return response.CSVKeyed(c, "quote.csv", "instrument", instruments, quoteResponse.Data)
+	}
+	return response.JSON(c, http.StatusOK, quoteResponse)
+}
+
+// wantsLineage returns true if the request asks for the lineage of the data with meta=true
+func wantsLineage(c echo.Context) bool {
+	meta, _ := strconv.ParseBool(c.QueryParam("meta"))
+	return meta
+}
+
+// addLineage adds the lineage of the data of the instrument to the response
+func addLineage(quoteResponse *models.QuoteResponse, instrument string, lineage models.DataLineage) {
+	if quoteResponse.Meta == nil {
+		quoteResponse.Meta = make(map[string]models.DataLineage)
+	}
+	quoteResponse.Meta[instrument] = lineage
+}
+
+// prevCloseLineageSource returns the lineage source of the previous close of a quote, the previous close falls
+// back to the close of the live tick when none is persisted
+func prevCloseLineageSource(prevClose models.PrevCloseModel) string {
+	if prevClose.PrevClose.Sign() > 0 {
+		return models.PrevCloseLineageSource(prevClose.Source)
+	}
+	return models.LineageLiveTick
 }
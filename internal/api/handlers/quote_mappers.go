@@ -3,11 +3,14 @@ package handlers
 
 import (
 	"log"
+	"time"
 
 	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
 )
 
-func mapTickToQuoteData(tick *models.TickerData) interface{} {
+func mapTickToQuoteData(tick *models.TickerData, prevClose decimal.Decimal) interface{} {
 	ohlc, err := tick.GetOHLC()
 	if err != nil {
 		log.Printf("Error getting OHLC data: %v", err)
@@ -40,13 +43,17 @@ func mapTickToQuoteData(tick *models.TickerData) interface{} {
 		OIDayHigh:         tick.OIDayHigh,
 		OIDayLow:          tick.OIDayLow,
 		NetChange:         tick.NetChange,
+		PreviousClose:     resolvePrevClose(prevClose, ohlc),
+		ChangePercent:     changePercent(tick.LastPrice, resolvePrevClose(prevClose, ohlc)),
 		OHLC:              mapOHLC(ohlc),
 		Depth:             mapDepth(depth),
+		AsOf:              quoteAsOf(tick).Format("2006-01-02 15:04:05"),
+		IsStale:           service.IsQuoteStale(tick.Instrument, quoteAsOf(tick), service.MarketNow()),
 		UpdatedAt:         tick.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}
 }
 
-func mapTickToOHLCData(tick *models.TickerData) interface{} {
+func mapTickToOHLCData(tick *models.TickerData, prevClose decimal.Decimal) interface{} {
 	ohlc, err := tick.GetOHLC()
 	if err != nil {
 		log.Printf("Error getting OHLC data: %v", err)
@@ -57,22 +64,82 @@ func mapTickToOHLCData(tick *models.TickerData) interface{} {
 		LastPrice:         tick.LastPrice,
 		VolumeTraded:      tick.VolumeTraded,
 		AverageTradePrice: tick.AverageTradePrice,
+		PreviousClose:     resolvePrevClose(prevClose, ohlc),
+		ChangePercent:     changePercent(tick.LastPrice, resolvePrevClose(prevClose, ohlc)),
 		Timestamp:         tick.Timestamp.Format("2006-01-02 15:04:05"),
 		LastTradeTime:     tick.LastTradeTime.Format("2006-01-02 15:04:05"),
 		OHLC:              mapOHLC(ohlc),
+		AsOf:              quoteAsOf(tick).Format("2006-01-02 15:04:05"),
+		IsStale:           service.IsQuoteStale(tick.Instrument, quoteAsOf(tick), service.MarketNow()),
 		UpdatedAt:         tick.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}
 }
 
-func mapTickToLTPData(tick *models.TickerData) interface{} {
+func mapTickToLTPData(tick *models.TickerData, prevClose decimal.Decimal) interface{} {
+	// OHLC is only needed as a fallback for the previous close
+	ohlc, _ := tick.GetOHLC()
+
 	return models.LTPData{
 		InstrumentToken: tick.InstrumentToken,
 		LastPrice:       tick.LastPrice,
+		PreviousClose:   resolvePrevClose(prevClose, ohlc),
+		ChangePercent:   changePercent(tick.LastPrice, resolvePrevClose(prevClose, ohlc)),
 		Timestamp:       tick.Timestamp.Format("2006-01-02 15:04:05"),
+		AsOf:            quoteAsOf(tick).Format("2006-01-02 15:04:05"),
+		IsStale:         service.IsQuoteStale(tick.Instrument, quoteAsOf(tick), service.MarketNow()),
 		UpdatedAt:       tick.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}
 }
 
+func mapTickToDepthData(tick *models.TickerData, _ decimal.Decimal) interface{} {
+	depth, err := tick.GetDepth()
+	if err != nil {
+		log.Printf("Error getting Depth data: %v", err)
+		depth = models.TickerDataDepth{} // Use default Depth
+	}
+	levels := 1
+	if tick.HasFullDepth() {
+		levels = len(depth.Buy)
+	}
+
+	return models.DepthData{
+		InstrumentToken:   tick.InstrumentToken,
+		LastPrice:         tick.LastPrice,
+		TotalBuyQuantity:  tick.TotalBuyQuantity,
+		TotalSellQuantity: tick.TotalSellQuantity,
+		Levels:            levels,
+		Depth:             depth,
+		Timestamp:         tick.Timestamp.Format("2006-01-02 15:04:05"),
+		AsOf:              quoteAsOf(tick).Format("2006-01-02 15:04:05"),
+		IsStale:           service.IsQuoteStale(tick.Instrument, quoteAsOf(tick), service.MarketNow()),
+		UpdatedAt:         tick.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// quoteAsOf returns the time the tick data is valid for, the exchange timestamp when present
+func quoteAsOf(tick *models.TickerData) time.Time {
+	if !tick.Timestamp.IsZero() {
+		return tick.Timestamp
+	}
+	return tick.UpdatedAt
+}
+
+// resolvePrevClose returns the persisted previous close, falling back to the tick's OHLC close
+func resolvePrevClose(prevClose decimal.Decimal, ohlc models.TickerDataOHLC) decimal.Decimal {
+	if prevClose.Sign() > 0 {
+		return prevClose
+	}
+	return ohlc.Close
+}
+
+// changePercent returns the day-over-day change in percent rounded to 2 decimal points
+func changePercent(lastPrice, prevClose decimal.Decimal) decimal.Decimal {
+	if prevClose.IsZero() {
+		return decimal.Zero
+	}
+	return lastPrice.Sub(prevClose).Mul(decimal.New(100, 0)).Div(prevClose).Round(2)
+}
+
 func mapOHLC(ohlc models.TickerDataOHLC) models.OHLC {
 	return models.OHLC(ohlc)
 }
@@ -0,0 +1,32 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+)
+
+// bodyError returns the status and message of a request body that could not be read or decoded, 413 when it
+// is over the body limit and 400 with the message otherwise
+func bodyError(err error, message string) (int, string) {
+	if middleware.IsBodyTooLarge(err) {
+		return http.StatusRequestEntityTooLarge, "Request body too large"
+	}
+	return http.StatusBadRequest, message
+}
+
+// decodeJSONBody decodes the JSON request body into v, unknown fields are rejected when strict. The status is 0
+// when the body was decoded, otherwise the status and message describe the invalid body
+func decodeJSONBody(c echo.Context, v interface{}, strict bool) (int, string) {
+	decoder := json.NewDecoder(c.Request().Body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return bodyError(err, "Invalid JSON body")
+	}
+	return 0, ""
+}
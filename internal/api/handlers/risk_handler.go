@@ -0,0 +1,88 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// RiskHandler is the handler for the risk limits API
+type RiskHandler struct {
+	service *service.RiskService
+}
+
+// NewRiskHandler creates a new handler for the risk limits API
+func NewRiskHandler(service *service.RiskService) *RiskHandler {
+	return &RiskHandler{service: service}
+}
+
+// GetRiskLimits returns the order and loss limits of the user
+func (h *RiskHandler) GetRiskLimits(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	limits, err := h.service.GetLimits(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, limits)
+}
+
+// SaveRiskLimits replaces the order and loss limits of the user in the `user_id` query param, a zero limit
+// is unlimited. The limits are set by an admin, a user can not lift their own
+func (h *RiskHandler) SaveRiskLimits(c echo.Context) error {
+	userId := strings.ToUpper(strings.TrimSpace(c.QueryParam("user_id")))
+	if userId == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`user_id` is required")
+	}
+
+	var limits models.RiskLimitsModel
+	if status, message := decodeJSONBody(c, &limits, true); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	if err := service.ValidateRiskLimits(limits); err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+	}
+
+	limits, err := h.service.SaveLimits(userId, limits)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, limits)
+}
+
+// DeleteRiskLimits removes the limits of the user in the `user_id` query param
+func (h *RiskHandler) DeleteRiskLimits(c echo.Context) error {
+	userId := strings.ToUpper(strings.TrimSpace(c.QueryParam("user_id")))
+	if userId == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`user_id` is required")
+	}
+
+	deletedCount, err := h.service.DeleteLimits(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, deletedCount > 0)
+}
+
+// GetRiskState returns the limits of the user with the orders, quantities and P&L of the day and the breached limits
+func (h *RiskHandler) GetRiskState(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	state, err := h.service.GetState(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, state)
+}
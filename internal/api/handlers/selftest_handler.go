@@ -0,0 +1,31 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// SelfTestHandler is the handler for the self test API
+type SelfTestHandler struct {
+	service *service.SelfTestService
+}
+
+// NewSelfTestHandler creates a new handler for the self test API
+func NewSelfTestHandler(service *service.SelfTestService) *SelfTestHandler {
+	return &SelfTestHandler{service: service}
+}
+
+// RunSelfTest runs the self test of the critical endpoints with the session of the caller and returns the
+// pass or fail of every check
+func (h *SelfTestHandler) RunSelfTest(c echo.Context) error {
+	userId, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	return response.SuccessResponse(c, h.service.Run(userId, enctoken))
+}
@@ -2,11 +2,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
 	"github.com/nsvirk/moneybotsapi/internal/service"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
 )
@@ -31,13 +34,13 @@ func (h *SessionHandler) GenerateSession(c echo.Context) error {
 
 	// check if all fields are present in the request
 	if userid == "" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`user_id` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`user_id` is required")
 	}
 	if password == "" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`password` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`password` is required")
 	}
 	if totpValue == "" && totpSecret == "" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Either `totp_value` or `totp_secret` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Either `totp_value` or `totp_secret` is required")
 	}
 
 	// generate the totp value, if top_secret is provided
@@ -45,7 +48,7 @@ func (h *SessionHandler) GenerateSession(c echo.Context) error {
 		totpValueGenerated, err := h.service.GenerateTOTP(totpSecret)
 		if err != nil {
 			// if unable to generate totp value, return unauthorized
-			return response.ErrorResponse(c, http.StatusUnauthorized, "AuthenticationException", err.Error())
+			return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthenticationException, err.Error())
 		}
 		totpValue = totpValueGenerated
 	}
@@ -53,7 +56,7 @@ func (h *SessionHandler) GenerateSession(c echo.Context) error {
 	// generate the session
 	sessionData, err := h.service.GenerateSession(userid, password, totpValue)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthenticationException", err.Error())
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthenticationException, err.Error())
 	}
 
 	// set the cookies
@@ -106,13 +109,13 @@ func (h *SessionHandler) GenerateTOTP(c echo.Context) error {
 	// get the totp_secret from the request
 	totpSecret := c.FormValue("totp_secret")
 	if totpSecret == "" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`totp_secret` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`totp_secret` is required")
 	}
 
 	// generate the totp value
 	totpValue, err := h.service.GenerateTOTP(totpSecret)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
 	}
 
 	return response.SuccessResponse(c, totpValue)
@@ -125,22 +128,22 @@ func (h *SessionHandler) DeleteSession(c echo.Context) error {
 	enctokenUrlEncoded := c.QueryParam("enctoken")
 	enctoken, err := url.QueryUnescape(enctokenUrlEncoded)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
 	}
 	if userId == "" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`user_id` is a required field")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`user_id` is a required field")
 	}
 	if enctoken == "" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`enctoken` is a required field")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`enctoken` is a required field")
 	}
 
 	// delete the session
 	rowsAffected, err := h.service.DeleteSession(userId, enctoken)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 	}
 	if rowsAffected == 0 {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Session not found")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Session not found")
 	}
 	// Clear user_id cookie
 	c.SetCookie(&http.Cookie{
@@ -189,17 +192,60 @@ func (h *SessionHandler) DeleteSession(c echo.Context) error {
 	return response.SuccessResponse(c, true)
 }
 
+// sessionChecksMaxLimit is the maximum number of session checks returned by a request
+const sessionChecksMaxLimit = 1000
+
+// GetSessionChecks returns the validity transitions of the user's session recorded by the keep-alive pinger
+func (h *SessionHandler) GetSessionChecks(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	limit := 100
+	if limitStr := c.QueryParam("limit"); len(limitStr) > 0 {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > sessionChecksMaxLimit {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `limit`, must be between 1 and 1000")
+		}
+	}
+
+	checks, err := h.service.GetSessionChecks(userId, limit)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, checks)
+}
+
+// GetSessionInfo returns the login time, estimated expiry and validity of the session in the authorization
+// header. It is not behind the auth middleware so an expired session can still be inspected, the enctoken
+// only has to match the stored session
+func (h *SessionHandler) GetSessionInfo(c echo.Context) error {
+	userId, enctoken, err := middleware.ExtractUserIDEnctokenFromAuthHeader(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	info, err := h.service.GetSessionInfo(userId, enctoken)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionMismatch) {
+			return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+		}
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, info)
+}
+
 // CheckEnctokenValid checks if the enctoken is valid
 func (h *SessionHandler) CheckEnctokenValid(c echo.Context) error {
 	// get the enctoken from the request form body
 	enctoken := c.FormValue("enctoken")
 	if enctoken == "" {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "`enctoken` is required")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`enctoken` is required")
 	}
 	// check if the enctoken is valid
 	enctokenValid, err := h.service.CheckEnctokenValid(enctoken)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, err.Error())
 	}
 	return response.SuccessResponse(c, enctokenValid)
 }
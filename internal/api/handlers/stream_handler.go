@@ -2,8 +2,11 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
@@ -14,41 +17,135 @@ import (
 
 // StreamHandler is the handler for the stream API
 type StreamHandler struct {
-	service *service.StreamService
+	service    *service.StreamService
+	tagService *service.InstrumentTagService
 }
 
 // NewStreamHandler creates a new handler for the stream API
 func NewStreamHandler(db *gorm.DB) *StreamHandler {
 	return &StreamHandler{
-		service: service.NewStreamService(db),
+		service:    service.NewStreamService(db),
+		tagService: service.NewInstrumentTagService(db),
 	}
 }
 
 type StreamRequestBody struct {
 	Instruments []string `json:"instruments"`
+	// Tags streams the instruments carrying the tags of the user or the global tags too
+	Tags []string `json:"tags"`
+	// Mode is compact (default) or full
+	Mode string `json:"mode"`
+	// Deltas sends a snapshot per instrument followed by the changed fields only
+	Deltas bool `json:"deltas"`
+	// ResnapshotSeconds is how often a delta stream resends full snapshots
+	ResnapshotSeconds int `json:"resnapshot_seconds"`
 }
 
 // StreamTickerData streams the ticker data for the given instruments
 func (h *StreamHandler) StreamTickerData(c echo.Context) error {
 	userId, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 	}
 
 	var req StreamRequestBody
 	if err := c.Bind(&req); err != nil {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid request body")
+		status, message := bodyError(err, "Invalid request body")
+		return response.ErrorResponse(c, status, response.InputException, message)
 	}
 
-	ctx := c.Request().Context()
+	if req.Mode != "" && req.Mode != service.StreamModeCompact && req.Mode != service.StreamModeFull {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `mode`, must be compact or full")
+	}
+	if req.ResnapshotSeconds < 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `resnapshot_seconds`, must be a positive number")
+	}
+	if len(req.Tags) > 0 {
+		for i := range req.Tags {
+			if req.Tags[i], err = service.NormalizeInstrumentTag(req.Tags[i]); err != nil {
+				return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+			}
+		}
+		if req.Instruments, err = h.tagService.ExpandInstrumentTags(userId, req.Instruments, req.Tags); err != nil {
+			return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+		}
+	}
+	options := service.StreamOptions{
+		Mode:               req.Mode,
+		Deltas:             req.Deltas,
+		ResnapshotInterval: time.Duration(req.ResnapshotSeconds) * time.Second,
+	}
+
+	// the kill switch disconnects the stream by cancelling its context
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+	defer service.GetKillSwitch().Register(userId, "stream", cancel)()
 	errChan := make(chan error, 1)
 
-	go h.service.RunTickerStream(ctx, c, userId, enctoken, req.Instruments, errChan)
+	// the stream ending on its own, e.g. a client dropped for not acking, also ends the request
+	go func() {
+		h.service.RunTickerStream(ctx, c, userId, enctoken, req.Instruments, options, errChan)
+		cancel()
+	}()
 
 	select {
 	case <-ctx.Done():
-		return nil
+		select {
+		case err := <-errChan:
+			return h.streamErrorResponse(c, err)
+		default:
+			return nil
+		}
 	case err := <-errChan:
-		return response.ErrorResponse(c, http.StatusInternalServerError, "ServerError", fmt.Sprintf("Ticker error: %v", err))
+		return h.streamErrorResponse(c, err)
+	}
+}
+
+// streamErrorResponse returns the error response for an error that stopped the stream from starting
+func (h *StreamHandler) streamErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, service.ErrStreamQuotaExceeded) || errors.Is(err, service.ErrTokenBudgetExceeded) {
+		return response.ErrorResponse(c, http.StatusTooManyRequests, response.QuotaException, err.Error())
+	}
+	return response.ErrorResponse(c, http.StatusInternalServerError, response.TickerException, fmt.Sprintf("Ticker error: %v", err))
+}
+
+// StreamAckRequestBody is the heartbeat acknowledgement of a stream client
+type StreamAckRequestBody struct {
+	ClientID string `json:"client_id"`
+}
+
+// AckStream acknowledges a ping of the user's stream client, clients that stop acking are dropped
+func (h *StreamHandler) AckStream(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	var req StreamAckRequestBody
+	if err := c.Bind(&req); err != nil {
+		status, message := bodyError(err, "Invalid request body")
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	if req.ClientID == "" {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "`client_id` is required")
+	}
+
+	if err := h.service.AckClient(userId, req.ClientID); err != nil {
+		return response.ErrorResponse(c, http.StatusNotFound, response.DataNotFound, err.Error())
+	}
+	return response.SuccessResponse(c, map[string]interface{}{"client_id": req.ClientID, "acked": true})
+}
+
+// GetStreamUsage returns the stream usage of the user for today along with the daily quota
+func (h *StreamHandler) GetStreamUsage(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	usage, err := h.service.GetStreamUsage(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
 	}
+	return response.SuccessResponse(c, usage)
 }
@@ -4,38 +4,41 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
 	"github.com/nsvirk/moneybotsapi/internal/service"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"gorm.io/datatypes"
 )
 
 // TickerHandler is the handler for the ticker API
 type TickerHandler struct {
-	service *service.TickerService
+	service    *service.TickerService
+	tagService *service.InstrumentTagService
 }
 
 // NewTickerHandler creates a new handler for the ticker API
-func NewTickerHandler(service *service.TickerService) *TickerHandler {
-	return &TickerHandler{service: service}
+func NewTickerHandler(service *service.TickerService, tagService *service.InstrumentTagService) *TickerHandler {
+	return &TickerHandler{service: service, tagService: tagService}
 }
 
 // TickerStart starts the ticker for the given user
 func (h *TickerHandler) TickerStart(c echo.Context) error {
 	userId, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 	}
 
 	if err := h.service.Start(userId, enctoken); err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "TickerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.TickerException, err.Error())
 	}
 
 	instruments, err := h.service.GetTickerInstruments(userId)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "DatabaseException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
 	}
 
 	return response.SuccessResponse(c, map[string]interface{}{
@@ -49,11 +52,11 @@ func (h *TickerHandler) TickerStart(c echo.Context) error {
 func (h *TickerHandler) TickerStop(c echo.Context) error {
 	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 	}
 
 	if err := h.service.Stop(userId); err != nil {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", err.Error())
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
 	}
 
 	return response.SuccessResponse(c, map[string]interface{}{
@@ -66,16 +69,16 @@ func (h *TickerHandler) TickerStop(c echo.Context) error {
 func (h *TickerHandler) TickerRestart(c echo.Context) error {
 	userId, enctoken, err := middleware.GetUserIdEnctokenFromEchoContext(c)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 	}
 
 	if err := h.service.Restart(userId, enctoken); err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "TickerException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.TickerException, err.Error())
 	}
 
 	instruments, err := h.service.GetTickerInstruments(userId)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "DatabaseException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
 	}
 
 	return response.SuccessResponse(c, map[string]interface{}{
@@ -94,16 +97,37 @@ func (h *TickerHandler) TickerStatus(c echo.Context) error {
 	})
 }
 
+// GetTickStats returns the tick statistics of the requested instruments, or of all instruments ticked today
+func (h *TickerHandler) GetTickStats(c echo.Context) error {
+	instruments := c.QueryParams()["instrument"]
+	for _, instrument := range instruments {
+		if !strings.Contains(instrument, ":") {
+			return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `instrument` format, must be exchange:tradingsymbol")
+		}
+	}
+
+	stats, err := h.service.GetTickStats(instruments)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+	}
+
+	return response.SuccessResponse(c, map[string]interface{}{
+		"timestamp": time.Now().Format(time.RFC3339),
+		"records":   len(stats),
+		"stats":     stats,
+	})
+}
+
 // GetTickerInstruments returns the instruments for the given user
 func (h *TickerHandler) GetTickerInstruments(c echo.Context) error {
 	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 	}
 
 	tickerInstruments, err := h.service.GetTickerInstruments(userId)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "DatabaseException", "Failed to fetch instruments")
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, "Failed to fetch instruments")
 	}
 
 	respTickerInstruments := make([]string, len(tickerInstruments))
@@ -122,18 +146,36 @@ func (h *TickerHandler) GetTickerInstruments(c echo.Context) error {
 func (h *TickerHandler) AddTickerInstruments(c echo.Context) error {
 	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 	}
 	var req struct {
 		Instruments []string `json:"instruments"`
+		// Tags adds the instruments carrying the tags of the user or the global tags
+		Tags []string `json:"tags"`
+		Mode string   `json:"mode"`
+	}
+	if status, message := decodeJSONBody(c, &req, false); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
 	}
-	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid JSON body")
+
+	if req.Mode != "" && !service.IsValidTickerMode(req.Mode) {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `mode` value, must be ltp, quote or full")
 	}
 
-	instruments, err := h.service.AddTickerInstruments(userId, req.Instruments)
+	if len(req.Tags) > 0 {
+		for i := range req.Tags {
+			if req.Tags[i], err = service.NormalizeInstrumentTag(req.Tags[i]); err != nil {
+				return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+			}
+		}
+		if req.Instruments, err = h.tagService.ExpandInstrumentTags(userId, req.Instruments, req.Tags); err != nil {
+			return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+		}
+	}
+
+	instruments, err := h.service.AddTickerInstruments(userId, req.Instruments, req.Mode)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "DatabaseException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
 	}
 
 	totalCount, _ := h.service.GetTickerInstrumentCount(userId)
@@ -149,23 +191,23 @@ func (h *TickerHandler) AddTickerInstruments(c echo.Context) error {
 func (h *TickerHandler) DeleteTickerInstruments(c echo.Context) error {
 	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 	}
 	var req struct {
 		Instruments []string `json:"instruments"`
 	}
-	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Invalid JSON body")
+	if status, message := decodeJSONBody(c, &req, false); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
 	}
 
 	// Add validation for empty instruments array
 	if len(req.Instruments) == 0 {
-		return response.ErrorResponse(c, http.StatusBadRequest, "InputException", "Instruments array cannot be empty")
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Instruments array cannot be empty")
 	}
 
 	deletedCount, err := h.service.DeleteTickerInstruments(userId, req.Instruments)
 	if err != nil {
-		return response.ErrorResponse(c, http.StatusInternalServerError, "DatabaseException", err.Error())
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
 	}
 
 	return response.SuccessResponse(c, map[string]interface{}{
@@ -173,3 +215,128 @@ func (h *TickerHandler) DeleteTickerInstruments(c echo.Context) error {
 		"timestamp": time.Now().Format(time.RFC3339),
 	})
 }
+
+// PinTickerInstruments pins the given ticker instruments, optionally attaching metadata
+func (h *TickerHandler) PinTickerInstruments(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	var req struct {
+		Instruments []string        `json:"instruments"`
+		Metadata    json.RawMessage `json:"metadata"`
+	}
+	if status, message := decodeJSONBody(c, &req, false); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+
+	if len(req.Instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Instruments array cannot be empty")
+	}
+
+	var metadata datatypes.JSON
+	if len(req.Metadata) > 0 && string(req.Metadata) != "null" {
+		metadata = datatypes.JSON(req.Metadata)
+	}
+
+	pinnedCount, err := h.service.PinTickerInstruments(userId, req.Instruments, metadata)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+
+	return response.SuccessResponse(c, map[string]interface{}{
+		"pinned":    pinnedCount,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// SetTickerInstrumentsPriority marks the given ticker instruments as priority, their ticks skip the processing backlog
+func (h *TickerHandler) SetTickerInstrumentsPriority(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	var req struct {
+		Instruments []string `json:"instruments"`
+		Priority    bool     `json:"priority"`
+	}
+	if status, message := decodeJSONBody(c, &req, false); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+
+	if len(req.Instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Instruments array cannot be empty")
+	}
+
+	updatedCount, err := h.service.SetTickerInstrumentsPriority(userId, req.Instruments, req.Priority)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+
+	return response.SuccessResponse(c, map[string]interface{}{
+		"updated":   updatedCount,
+		"priority":  req.Priority,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// SetTickerInstrumentsMode sets the ticker mode of the given ticker instruments, a blank mode reverts to full
+func (h *TickerHandler) SetTickerInstrumentsMode(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	var req struct {
+		Instruments []string `json:"instruments"`
+		Mode        string   `json:"mode"`
+	}
+	if status, message := decodeJSONBody(c, &req, false); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+
+	if len(req.Instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Instruments array cannot be empty")
+	}
+	if req.Mode != "" && !service.IsValidTickerMode(req.Mode) {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Invalid `mode` value, must be ltp, quote or full")
+	}
+
+	updatedCount, err := h.service.SetTickerInstrumentsMode(userId, req.Instruments, req.Mode)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+
+	return response.SuccessResponse(c, map[string]interface{}{
+		"updated":   updatedCount,
+		"mode":      req.Mode,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// UnpinTickerInstruments unpins the given ticker instruments
+func (h *TickerHandler) UnpinTickerInstruments(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+	var req struct {
+		Instruments []string `json:"instruments"`
+	}
+	if status, message := decodeJSONBody(c, &req, false); status != 0 {
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+
+	if len(req.Instruments) == 0 {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, "Instruments array cannot be empty")
+	}
+
+	unpinnedCount, err := h.service.UnpinTickerInstruments(userId, req.Instruments)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+
+	return response.SuccessResponse(c, map[string]interface{}{
+		"unpinned":  unpinnedCount,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,73 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/api/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// UserHandler is the handler for the user API
+type UserHandler struct {
+	service *service.UserService
+}
+
+// NewUserHandler creates a new handler for the user API
+func NewUserHandler(service *service.UserService) *UserHandler {
+	return &UserHandler{service: service}
+}
+
+// GetUserSettings returns the settings of the user
+func (h *UserHandler) GetUserSettings(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	settings, err := h.service.GetUserSettings(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, settings)
+}
+
+// SaveUserSettings replaces the settings of the user
+func (h *UserHandler) SaveUserSettings(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		status, message := bodyError(err, "Invalid request body")
+		return response.ErrorResponse(c, status, response.InputException, message)
+	}
+	settings, err := service.ParseUserSettings(body)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusBadRequest, response.InputException, err.Error())
+	}
+
+	if err := h.service.SaveUserSettings(userId, settings); err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, settings)
+}
+
+// DeleteUserSettings deletes the settings of the user
+func (h *UserHandler) DeleteUserSettings(c echo.Context) error {
+	userId, _, err := middleware.GetUserIdEnctokenFromEchoContext(c)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+	}
+
+	deletedCount, err := h.service.DeleteUserSettings(userId)
+	if err != nil {
+		return response.ErrorResponse(c, http.StatusInternalServerError, response.DatabaseException, err.Error())
+	}
+	return response.SuccessResponse(c, deletedCount > 0)
+}
@@ -0,0 +1,23 @@
+// Package handlers contains the handlers for the API
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// VersionHandler is the handler for the version API
+type VersionHandler struct {
+	service *service.VersionService
+}
+
+// NewVersionHandler creates a new handler for the version API
+func NewVersionHandler(service *service.VersionService) *VersionHandler {
+	return &VersionHandler{service: service}
+}
+
+// GetVersion returns the build and dependency versions
+func (h *VersionHandler) GetVersion(c echo.Context) error {
+	return response.SuccessResponse(c, h.service.GetVersionInfo())
+}
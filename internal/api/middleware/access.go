@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// AccessMiddleware restricts sensitive route groups to allowed IPs and,
+// when a client CA is configured, to clients presenting a verified certificate
+func AccessMiddleware(cfg *config.Config) echo.MiddlewareFunc {
+	// validated when the config is loaded
+	allowedIPNets, _ := config.ParseIPNets(cfg.AdminAllowedIPs)
+	requireClientCert := cfg.ServerTLSClientCAFile != ""
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(allowedIPNets) > 0 {
+				if ip := clientIP(c); !ipAllowed(ip, allowedIPNets) {
+					return response.ErrorResponse(c, http.StatusForbidden, response.AuthorizationException, "Access denied for IP "+ip)
+				}
+			}
+
+			if requireClientCert {
+				tlsState := c.Request().TLS
+				if tlsState == nil || len(tlsState.VerifiedChains) == 0 {
+					return response.ErrorResponse(c, http.StatusForbidden, response.AuthorizationException, "Client certificate required")
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// clientIP returns the IP of the client with the IP extractor of the proxy setup, the peer address when no
+// extractor is set. Echo falls back to the X-Forwarded-For and X-Real-IP headers without an extractor and any
+// client can set them, so they must not decide access or rate limits
+func clientIP(c echo.Context) string {
+	if c.Echo().IPExtractor != nil {
+		return c.RealIP()
+	}
+	return echo.ExtractIPDirect()(c.Request())
+}
+
+// ipAllowed returns true if the ip is in one of the networks
+func ipAllowed(ipStr string, ipNets []*net.IPNet) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/requestid"
+	"gorm.io/gorm"
+)
+
+// activityActions maps the audited routes to their action names
+var activityActions = map[string]string{
+	"POST /session/token":            "session.generate",
+	"DELETE /session/token":          "session.delete",
+	"GET /ticker/start":              "ticker.start",
+	"GET /ticker/stop":               "ticker.stop",
+	"GET /ticker/restart":            "ticker.restart",
+	"POST /ticker/instruments":       "ticker.instruments.add",
+	"DELETE /ticker/instruments":     "ticker.instruments.delete",
+	"PUT /ticker/instruments/pin":    "ticker.instruments.pin",
+	"DELETE /ticker/instruments/pin": "ticker.instruments.unpin",
+	"POST /stream/ticks":             "stream.subscribe",
+	"PUT /user/settings":             "user.settings.save",
+	"DELETE /user/settings":          "user.settings.delete",
+}
+
+// activitySecretParams are the query parameters carrying credentials
+var activitySecretParams = map[string]bool{
+	"enctoken":    true,
+	"password":    true,
+	"totp_value":  true,
+	"totp_secret": true,
+}
+
+// ActivityMiddleware records the audited actions of a user once the request has been handled,
+// unauthenticated session requests are only recorded when they succeed so nobody can write to another user's activity
+func ActivityMiddleware(db *gorm.DB) echo.MiddlewareFunc {
+	activityService := service.NewActivityService(db)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+
+			action, ok := activityActions[c.Request().Method+" "+RoutePath(c)]
+			if !ok {
+				return err
+			}
+			status := c.Response().Status
+			userID, _, authErr := GetUserIdEnctokenFromEchoContext(c)
+			if authErr != nil {
+				if status >= 400 {
+					return err
+				}
+				userID = c.FormValue("user_id")
+			}
+			if userID == "" {
+				return err
+			}
+
+			details := map[string]interface{}{}
+			for name, values := range c.QueryParams() {
+				// credentials never end up in the activity
+				if !activitySecretParams[name] {
+					details[name] = values
+				}
+			}
+			if err != nil {
+				details["error"] = err.Error()
+			}
+			activityService.Record(models.ActivityEventModel{
+				UserID:    userID,
+				Action:    action,
+				Method:    c.Request().Method,
+				Path:      c.Request().URL.Path,
+				Status:    status,
+				IP:        c.RealIP(),
+				RequestID: requestid.FromContext(c.Request().Context()),
+			}, details)
+			return err
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// AdminMiddleware restricts a route group to the configured admin users and denies every user when none is
+// configured. It runs after AuthMiddleware so the user is the verified session user
+func AdminMiddleware(cfg *config.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, _, err := GetUserIdEnctokenFromEchoContext(c)
+			if err != nil {
+				return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+			}
+			if !cfg.IsAdmin(userID) {
+				return response.ErrorResponse(c, http.StatusForbidden, response.AuthorizationException, "Admin access required")
+			}
+			return next(c)
+		}
+	}
+}
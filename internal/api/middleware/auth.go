@@ -19,14 +19,14 @@ func AuthMiddleware(db *gorm.DB) echo.MiddlewareFunc {
 			// Get the userId and enctoken from the authorization header
 			userID, enctoken, err := ExtractUserIDEnctokenFromAuthHeader(c)
 			if err != nil {
-				return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+				return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 			}
 
 			// Verify the session
 			sessionService := service.NewSessionService(db)
 			userSession, err := sessionService.VerifyUserAuthorization(userID, enctoken)
 			if err != nil {
-				return response.ErrorResponse(c, http.StatusUnauthorized, "AuthorizationException", err.Error())
+				return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
 			}
 
 			// Add session data to context for use in handlers
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// BodyLimitMiddleware rejects request bodies larger than limit bytes, bodies with
+// a declared length are rejected upfront, other bodies fail when read past the limit
+func BodyLimitMiddleware(limit int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.ContentLength > limit {
+				return BodyTooLargeResponse(c, limit)
+			}
+			req.Body = http.MaxBytesReader(c.Response(), req.Body, limit)
+			return next(c)
+		}
+	}
+}
+
+// IsBodyTooLarge returns true if the error was caused by reading past the body limit
+func IsBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// BodyTooLargeResponse sends the 413 error response for an oversized request body
+func BodyTooLargeResponse(c echo.Context, limit int64) error {
+	return response.ErrorResponse(c, http.StatusRequestEntityTooLarge, response.InputException,
+		fmt.Sprintf("Request body exceeds the limit of %d bytes", limit))
+}
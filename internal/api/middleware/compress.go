@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+// CompressMiddleware gzips responses of the route group when the group is listed in the
+// config, event streams and websocket upgrades are never compressed as gzip buffers writes
+func CompressMiddleware(cfg *config.Config, group string) echo.MiddlewareFunc {
+	for _, enabledGroup := range splitList(cfg.CompressionGroups) {
+		if enabledGroup == group {
+			// level is validated when the config is loaded
+			level, _ := strconv.Atoi(cfg.CompressionLevel)
+			return middleware.GzipWithConfig(middleware.GzipConfig{
+				Skipper:   isStreamingRequest,
+				Level:     level,
+				MinLength: 1024,
+			})
+		}
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return next
+	}
+}
+
+// isStreamingRequest returns true for server-sent events and websocket requests
+func isStreamingRequest(c echo.Context) bool {
+	req := c.Request()
+	if strings.EqualFold(req.Header.Get(echo.HeaderUpgrade), "websocket") {
+		return true
+	}
+	if strings.Contains(req.Header.Get(echo.HeaderAccept), "text/event-stream") {
+		return true
+	}
+	return strings.HasPrefix(RoutePath(c), "/stream")
+}
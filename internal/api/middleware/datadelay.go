@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// DataDelayMiddleware sets the market data delay of a user restricted to delayed data in the context,
+// the handlers then serve the data from the archive. It must run after the AuthMiddleware
+func DataDelayMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, _ := c.Get("user_id").(string)
+			if delay := service.GetDataDelays().Delay(userID); delay > 0 {
+				c.Set("data_delay", delay)
+			}
+			return next(c)
+		}
+	}
+}
+
+// RealTimeDataMiddleware rejects the requests of users restricted to delayed data, it must run after
+// the AuthMiddleware and guards the routes streaming real-time data
+func RealTimeDataMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, _ := c.Get("user_id").(string)
+			if service.GetDataDelays().Delay(userID) > 0 {
+				return response.ErrorResponse(c, http.StatusForbidden, response.AuthorizationException, service.ErrRealTimeDataRestricted.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// GetDataDelayFromEchoContext gets the market data delay of the user, 0 for real-time data
+func GetDataDelayFromEchoContext(c echo.Context) time.Duration {
+	delay, _ := c.Get("data_delay").(time.Duration)
+	return delay
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/service"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// KillSwitchMiddleware rejects the requests of users covered by an engaged kill switch, it must run after
+// the AuthMiddleware and guards the routes that trade or open market data connections
+func KillSwitchMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, _ := c.Get("user_id").(string)
+			if err := service.GetKillSwitch().Check(userID); err != nil {
+				return response.ErrorResponse(c, http.StatusForbidden, response.AuthorizationException, err.Error())
+			}
+			return next(c)
+		}
+	}
+}
@@ -8,8 +8,9 @@ import (
 
 // SetupLoggerMiddleware configures and adds middleware to the Echo instance
 func SetupLoggerMiddleware(e *echo.Echo) {
+	e.Use(RequestIDMiddleware())
 	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-		Format: "${time_rfc3339}: ip=${remote_ip}, req=${method}, uri=${uri}, status=${status}, error=${error}, latency=${latency_human}\n",
+		Format: "${time_rfc3339}: id=${id}, ip=${remote_ip}, req=${method}, uri=${uri}, status=${status}, error=${error}, latency=${latency_human}\n",
 	}))
-	e.Use(middleware.Recover())
+	e.Use(RecoverMiddleware())
 }
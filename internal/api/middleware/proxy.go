@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+var (
+	// basePath is the path prefix the routes are mounted under
+	basePath string
+	// trustedProxies are the networks of the reverse proxies whose X-Forwarded headers are trusted
+	trustedProxies []*net.IPNet
+)
+
+// SetupProxyMiddleware sets how the client IP and the external url of a request are found. Requests from a
+// trusted proxy take the client IP from X-Forwarded-For and the external scheme, host and prefix from the
+// X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Prefix headers, other requests ignore the headers as
+// any client can set them. It must be called before the routes are set up
+func SetupProxyMiddleware(e *echo.Echo, cfg *config.Config) {
+	basePath = cfg.BasePath
+	// validated when the config is loaded
+	trustedProxies, _ = config.ParseIPNets(cfg.TrustedProxies)
+
+	if len(trustedProxies) == 0 {
+		e.IPExtractor = echo.ExtractIPDirect()
+		return
+	}
+	// only the configured proxies are trusted, not the private networks echo trusts by default
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, ipNet := range trustedProxies {
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	e.IPExtractor = echo.ExtractIPFromXFFHeader(options...)
+}
+
+// RoutePath returns the route of the request without the base path, e.g. /stream/ticks
+func RoutePath(c echo.Context) string {
+	return strings.TrimPrefix(c.Path(), basePath)
+}
+
+// ExternalBaseURL returns the url the client reached the API at, the scheme, host and base path of the
+// request as seen in front of the trusted proxies
+func ExternalBaseURL(c echo.Context) string {
+	req := c.Request()
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	host := req.Host
+	prefix := ""
+	if isTrustedProxy(req.RemoteAddr) {
+		if proto := forwardedHeader(c, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := forwardedHeader(c, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+		// set by proxies stripping the prefix before forwarding the request
+		prefix = strings.TrimRight(forwardedHeader(c, "X-Forwarded-Prefix"), "/")
+	}
+	return scheme + "://" + host + prefix + basePath
+}
+
+// forwardedHeader returns the first value of a forwarded header, the one set by the proxy nearest the client
+func forwardedHeader(c echo.Context, name string) string {
+	value, _, _ := strings.Cut(c.Request().Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
+
+// isTrustedProxy returns true if the peer address is a trusted proxy
+func isTrustedProxy(remoteAddr string) bool {
+	if len(trustedProxies) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return ipAllowed(host, trustedProxies)
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"golang.org/x/time/rate"
+)
+
+// PublicRateLimitMiddleware limits the unauthenticated requests of the public routes per client IP
+// to the configured rate and burst, the IP is the peer address unless an IP extractor is set
+func PublicRateLimitMiddleware(cfg *config.Config) echo.MiddlewareFunc {
+	// values are validated when the config is loaded
+	limit, _ := strconv.ParseFloat(cfg.PublicRateLimit, 64)
+	burst, _ := strconv.Atoi(cfg.PublicRateBurst)
+	retryAfter := strconv.Itoa(int(1/limit) + 1)
+
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Limit(limit),
+			Burst:     burst,
+			ExpiresIn: 3 * time.Minute,
+		}),
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			return clientIP(c), nil
+		},
+		ErrorHandler: func(c echo.Context, err error) error {
+			return response.ErrorResponse(c, http.StatusForbidden, response.AuthorizationException, "Unable to identify the client")
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			c.Response().Header().Set("Retry-After", retryAfter)
+			return response.ErrorResponse(c, http.StatusTooManyRequests, response.RateLimitException, "Rate limit exceeded for public requests, authenticate for higher limits")
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"golang.org/x/time/rate"
+)
+
+// UserRateLimitMiddleware limits the requests of a route per user to one per interval after a burst, it runs
+// after AuthMiddleware so the limit is keyed by the verified user
+func UserRateLimitMiddleware(interval time.Duration, burst int) echo.MiddlewareFunc {
+	retryAfter := strconv.Itoa(int(interval.Seconds()) + 1)
+
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Every(interval),
+			Burst:     burst,
+			ExpiresIn: interval * time.Duration(burst+1),
+		}),
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			userID, _, err := GetUserIdEnctokenFromEchoContext(c)
+			return userID, err
+		},
+		ErrorHandler: func(c echo.Context, err error) error {
+			return response.ErrorResponse(c, http.StatusUnauthorized, response.AuthorizationException, err.Error())
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			c.Response().Header().Set("Retry-After", retryAfter)
+			return response.ErrorResponse(c, http.StatusTooManyRequests, response.RateLimitException, "Rate limit exceeded, retry after "+retryAfter+" seconds")
+		},
+	})
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// RecoverMiddleware recovers panics in handlers, reports them and responds with a server error
+func RecoverMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// the server handles aborted handlers itself
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				recovery.Report(c.Request().Method+" "+c.Path(), recovered, debug.Stack())
+				if !c.Response().Committed {
+					err = response.ErrorResponse(c, http.StatusInternalServerError, response.ServerException, "Internal server error")
+				}
+			}()
+			return next(c)
+		}
+	}
+}
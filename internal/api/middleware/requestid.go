@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/requestid"
+)
+
+// RequestIDMiddleware gives every request an id, the X-Request-ID of the client when it is valid and a new
+// one otherwise. The id is returned in the response header and carried in the request context, from where
+// it is sent with outbound calls and recorded with the activity
+func RequestIDMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			id := req.Header.Get(requestid.Header)
+			if !requestid.IsValid(id) {
+				id = requestid.New()
+				req.Header.Set(requestid.Header, id)
+			}
+			c.Response().Header().Set(requestid.Header, id)
+			c.SetRequest(req.WithContext(requestid.NewContext(req.Context(), id)))
+			return next(c)
+		}
+	}
+}
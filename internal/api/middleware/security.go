@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+// SetupSecurityMiddleware adds the CORS and security headers middleware to the Echo instance
+func SetupSecurityMiddleware(e *echo.Echo, cfg *config.Config) {
+	if origins := splitList(cfg.CORSAllowOrigins); len(origins) > 0 {
+		// values are validated when the config is loaded
+		allowCredentials, _ := strconv.ParseBool(cfg.CORSAllowCredentials)
+		e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+			AllowOrigins:     origins,
+			AllowHeaders:     splitList(cfg.CORSAllowHeaders),
+			AllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+			AllowCredentials: allowCredentials,
+		}))
+	}
+
+	if enabled, _ := strconv.ParseBool(cfg.SecurityHeaders); enabled {
+		hstsMaxAge, _ := strconv.Atoi(cfg.SecurityHSTSMaxAge)
+		e.Use(middleware.SecureWithConfig(middleware.SecureConfig{
+			XSSProtection:         "0",
+			ContentTypeNosniff:    "nosniff",
+			XFrameOptions:         cfg.SecurityFrameOptions,
+			HSTSMaxAge:            hstsMaxAge,
+			ContentSecurityPolicy: cfg.SecurityContentPolicy,
+			ReferrerPolicy:        "no-referrer",
+		}))
+	}
+}
+
+// splitList splits a comma separated list, dropping blank entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
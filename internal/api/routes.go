@@ -4,6 +4,8 @@ package api
 import (
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
@@ -17,79 +19,271 @@ import (
 )
 
 // SetupRoutes configures the routes for the API
-func SetupRoutes(e *echo.Echo, cfg *config.Config, db *gorm.DB, redisClient *redis.Client) {
+func SetupRoutes(e *echo.Echo, cfg *config.Config, db *gorm.DB, redisClient redis.UniversalClient, cronService *service.CronService) {
 
-	// Create a group for all API routes
-	api := e.Group("")
+	// Request body limits, validated when the config is loaded
+	bodyLimit, _ := strconv.ParseInt(cfg.BodyLimit, 10, 64)
+	bodyLimitInstruments, _ := strconv.ParseInt(cfg.BodyLimitInstruments, 10, 64)
+
+	// Create a group for all API routes, under the base path when the API is mounted behind a path prefix
+	api := e.Group(cfg.BasePath)
 
 	// Index route
 	api.GET("/", indexRoute)
 
+	// Error catalog route
+	api.GET("/errors", errorsRoute)
+
+	// Version route
+	versionHandler := handlers.NewVersionHandler(service.NewVersionService(db, redisClient))
+	api.GET("/version", versionHandler.GetVersion)
+
+	// Bootstrap route (protected)
+	bootstrapHandler := handlers.NewBootstrapHandler(service.NewBootstrapService(db, redisClient), cfg.APIUrl)
+	api.GET("/bootstrap", bootstrapHandler.GetBootstrap,
+		middleware.BodyLimitMiddleware(bodyLimit),
+		middleware.CompressMiddleware(cfg, "bootstrap"),
+		middleware.AuthMiddleware(db))
+
 	// Session routes (unprotected)
 	sessionService := service.NewSessionService(db)
 	sessionHandler := handlers.NewSessionHandler(sessionService)
 	sessionGroup := api.Group("/session")
+	sessionGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	sessionGroup.Use(middleware.ActivityMiddleware(db))
 	sessionGroup.POST("/token", sessionHandler.GenerateSession)
 	sessionGroup.DELETE("/token", sessionHandler.DeleteSession)
 	sessionGroup.POST("/totp", sessionHandler.GenerateTOTP)
 	sessionGroup.POST("/valid", sessionHandler.CheckEnctokenValid)
+	sessionGroup.GET("/info", sessionHandler.GetSessionInfo)
 
 	// Instrument routes (protected)
 	instrumentHandler := handlers.NewInstrumentHandler(db)
 	instrumentGroup := api.Group("/instruments")
+	instrumentGroup.Use(middleware.BodyLimitMiddleware(bodyLimitInstruments))
+	instrumentGroup.Use(middleware.CompressMiddleware(cfg, "instruments"))
 	instrumentGroup.Use(middleware.AuthMiddleware(db))
 	// instrument routes
 	instrumentGroup.GET("/info", instrumentHandler.GetInstrumentsInfo)
 	instrumentGroup.GET("/query", instrumentHandler.GetInstrumentsQuery)
+	instrumentGroup.POST("/resolve", instrumentHandler.ResolveInstruments)
+	instrumentGroup.GET("/bad_rows", instrumentHandler.GetInstrumentBadRows)
+	instrumentGroup.GET("/stats", instrumentHandler.GetInstrumentStats)
+	instrumentGroup.GET("/token_history", instrumentHandler.GetInstrumentTokenHistory)
+	instrumentGroup.GET("/tokenmap", instrumentHandler.GetInstrumentTokenMap)
+	instrumentGroup.GET("/sync", instrumentHandler.GetInstrumentSync)
+	// instrument tag routes
+	instrumentTagHandler := handlers.NewInstrumentTagHandler(service.NewInstrumentTagService(db))
+	instrumentGroup.GET("/tags", instrumentTagHandler.GetInstrumentTags)
+	instrumentGroup.GET("/tags/:tag", instrumentTagHandler.GetTaggedInstruments)
+	instrumentGroup.PUT("/tags/:tag", instrumentTagHandler.TagInstruments)
+	instrumentGroup.DELETE("/tags/:tag", instrumentTagHandler.UntagInstruments)
+	instrumentGroup.GET("/:symbol/bands", instrumentHandler.GetPriceBands)
 	// instrument fno routes
 	instrumentGroup.GET("/fno/segment_expiries/:name", instrumentHandler.GetFNOSegmentWiseExpiry)
 	instrumentGroup.GET("/fno/segment_names/:expiry", instrumentHandler.GetFNOSegmentWiseName)
+	instrumentGroup.GET("/fno/strike_interval", instrumentHandler.GetFNOStrikeInterval)
+	instrumentGroup.GET("/fno/option_chain", instrumentHandler.GetFNOOptionChain, middleware.RealTimeDataMiddleware())
+	instrumentGroup.GET("/fno/basis", instrumentHandler.GetFNOBasis, middleware.RealTimeDataMiddleware())
+	instrumentGroup.GET("/fno/greeks/history", instrumentHandler.GetFNOGreeksHistory)
+	instrumentGroup.GET("/fno/rollover", instrumentHandler.GetFNORollover, middleware.RealTimeDataMiddleware())
 
 	// Indices routes (protected)
 	indexHandler := handlers.NewIndexHandler(db)
 	indexGroup := api.Group("/indices")
+	indexGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	indexGroup.Use(middleware.CompressMiddleware(cfg, "indices"))
 	indexGroup.Use(middleware.AuthMiddleware(db))
 	indexGroup.GET("/all", indexHandler.GetAllIndices)
 	indexGroup.GET("/:exchange/info", indexHandler.GetIndicesByExchange)
 	indexGroup.GET("/:exchange/:index/instruments", indexHandler.GetIndexInstruments)
+	indexGroup.GET("/:exchange/:index/ltp", indexHandler.GetIndexLTP, middleware.RealTimeDataMiddleware())
+
+	// Public routes (unprotected, rate limited), read-only market data when the public mode is enabled
+	// validated when the config is loaded
+	if publicReadOnly, _ := strconv.ParseBool(cfg.PublicReadOnly); publicReadOnly {
+		publicQuoteHandler := handlers.NewQuoteHandler(service.NewQuoteService(db))
+		publicGroup := api.Group("/public")
+		publicGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+		publicGroup.Use(middleware.PublicRateLimitMiddleware(cfg))
+		publicGroup.Use(middleware.CompressMiddleware(cfg, "public"))
+		publicGroup.GET("/indices/all", indexHandler.GetAllIndices)
+		publicGroup.GET("/indices/:exchange/info", indexHandler.GetIndicesByExchange)
+		publicGroup.GET("/instruments/query", instrumentHandler.GetInstrumentsQuery)
+		publicGroup.GET("/quote/ltp", publicQuoteHandler.GetDelayedLTP)
+	}
 
 	// Ticker routes (protected)
 	tickerService := service.NewTickerService(db, redisClient)
-	tickerHandler := handlers.NewTickerHandler(tickerService)
+	tickerHandler := handlers.NewTickerHandler(tickerService, service.NewInstrumentTagService(db))
 	tickerGroup := api.Group("/ticker")
+	tickerGroup.Use(middleware.BodyLimitMiddleware(bodyLimitInstruments))
+	tickerGroup.Use(middleware.CompressMiddleware(cfg, "ticker"))
 	tickerGroup.Use(middleware.AuthMiddleware(db))
+	tickerGroup.Use(middleware.ActivityMiddleware(db))
 	tickerGroup.GET("/instruments", tickerHandler.GetTickerInstruments)
 	tickerGroup.POST("/instruments", tickerHandler.AddTickerInstruments)
 	tickerGroup.DELETE("/instruments", tickerHandler.DeleteTickerInstruments)
+	tickerGroup.PUT("/instruments/pin", tickerHandler.PinTickerInstruments)
+	tickerGroup.DELETE("/instruments/pin", tickerHandler.UnpinTickerInstruments)
+	tickerGroup.PUT("/instruments/mode", tickerHandler.SetTickerInstrumentsMode)
+	tickerGroup.PUT("/instruments/priority", tickerHandler.SetTickerInstrumentsPriority)
 	tickerGroup.GET("/start", tickerHandler.TickerStart)
 	tickerGroup.GET("/stop", tickerHandler.TickerStop)
 	tickerGroup.GET("/restart", tickerHandler.TickerRestart)
 	tickerGroup.GET("/status", tickerHandler.TickerStatus)
+	tickerGroup.GET("/stats", tickerHandler.GetTickStats)
 
 	// Quote routes (protected)
 	quoteService := service.NewQuoteService(db)
 	quoteHandler := handlers.NewQuoteHandler(quoteService)
 	quoteGroup := api.Group("/quote")
+	quoteGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	quoteGroup.Use(middleware.CompressMiddleware(cfg, "quote"))
 	quoteGroup.Use(middleware.AuthMiddleware(db))
+	quoteGroup.Use(middleware.DataDelayMiddleware())
 	quoteGroup.GET("", quoteHandler.GetQuote)
 	quoteGroup.GET("/ohlc", quoteHandler.GetOHLC)
 	quoteGroup.GET("/ltp", quoteHandler.GetLTP)
+	quoteGroup.GET("/depth", quoteHandler.GetDepth, middleware.RealTimeDataMiddleware())
+	quoteGroup.GET("/prevclose", quoteHandler.GetPrevClose)
+
+	// Candle routes (protected)
+	candleHandler := handlers.NewCandleHandler(service.NewCandleService(db))
+	candleGroup := api.Group("/candles")
+	candleGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	candleGroup.Use(middleware.CompressMiddleware(cfg, "candles"))
+	candleGroup.Use(middleware.AuthMiddleware(db))
+	candleGroup.Use(middleware.DataDelayMiddleware())
+	candleGroup.GET("", candleHandler.GetCandles)
+	candleGroup.GET("/gaps", candleHandler.GetCandleGaps)
+	candleGroup.POST("/backfill", candleHandler.BackfillCandles)
 
 	// Stream routes (protected)
 	streamHandler := handlers.NewStreamHandler(db)
 	streamGroup := api.Group("/stream")
+	streamGroup.Use(middleware.BodyLimitMiddleware(bodyLimitInstruments))
+	streamGroup.Use(middleware.CompressMiddleware(cfg, "stream"))
 	streamGroup.Use(middleware.AuthMiddleware(db))
-	streamGroup.POST("/ticks", streamHandler.StreamTickerData)
+	streamGroup.Use(middleware.ActivityMiddleware(db))
+	streamGroup.Use(middleware.KillSwitchMiddleware())
+	streamGroup.POST("/ticks", streamHandler.StreamTickerData, middleware.RealTimeDataMiddleware())
+	streamGroup.GET("/usage", streamHandler.GetStreamUsage)
+	streamGroup.POST("/ack", streamHandler.AckStream)
+
+	// Market routes (protected)
+	marketHandler := handlers.NewMarketHandler(service.NewDealService(db))
+	marketGroup := api.Group("/market")
+	marketGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	marketGroup.Use(middleware.CompressMiddleware(cfg, "market"))
+	marketGroup.Use(middleware.AuthMiddleware(db))
+	marketGroup.GET("/deals", marketHandler.GetDeals)
+
+	// News routes (protected)
+	newsHandler := handlers.NewNewsHandler(service.NewNewsService(db))
+	newsGroup := api.Group("/news")
+	newsGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	newsGroup.Use(middleware.CompressMiddleware(cfg, "news"))
+	newsGroup.Use(middleware.AuthMiddleware(db))
+	newsGroup.GET("", newsHandler.GetNews)
+	newsGroup.GET("/stream", newsHandler.StreamNews)
+
+	// User routes (protected)
+	userHandler := handlers.NewUserHandler(service.NewUserService(db))
+	userGroup := api.Group("/user")
+	userGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	userGroup.Use(middleware.CompressMiddleware(cfg, "user"))
+	userGroup.Use(middleware.AuthMiddleware(db))
+	userGroup.Use(middleware.ActivityMiddleware(db))
+	userGroup.GET("/settings", userHandler.GetUserSettings)
+	userGroup.PUT("/settings", userHandler.SaveUserSettings)
+	userGroup.DELETE("/settings", userHandler.DeleteUserSettings)
+	notificationHandler := handlers.NewNotificationHandler(service.NewNotifierService(db))
+	userGroup.GET("/notifications", notificationHandler.GetNotificationPreferences)
+	userGroup.PUT("/notifications", notificationHandler.SaveNotificationPreferences)
+	userGroup.DELETE("/notifications", notificationHandler.DeleteNotificationPreferences)
+	// a test posts to the user's channels on demand
+	userGroup.POST("/notifications/test", notificationHandler.SendTestNotification, middleware.UserRateLimitMiddleware(time.Minute, 3))
+	riskHandler := handlers.NewRiskHandler(service.NewRiskService(db))
+	userGroup.GET("/limits", riskHandler.GetRiskLimits)
+	userGroup.GET("/limits/state", riskHandler.GetRiskState)
+
+	// Me routes (protected)
+	meHandler := handlers.NewMeHandler(service.NewActivityService(db))
+	meGroup := api.Group("/me")
+	meGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	meGroup.Use(middleware.CompressMiddleware(cfg, "me"))
+	meGroup.Use(middleware.AuthMiddleware(db))
+	meGroup.GET("/activity", meHandler.GetActivity)
+	meGroup.GET("/session/checks", sessionHandler.GetSessionChecks)
 
 	// Cron routes (protected)
-	cronHandler := handlers.NewCronHandler(e, cfg, db, redisClient)
+	cronHandler := handlers.NewCronHandler(cronService, db)
 	cronGroup := api.Group("/cron")
+	cronGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	cronGroup.Use(middleware.CompressMiddleware(cfg, "cron"))
+	cronGroup.Use(middleware.AccessMiddleware(cfg))
 	cronGroup.Use(middleware.AuthMiddleware(db))
+	cronGroup.Use(middleware.AdminMiddleware(cfg))
 	cronGroup.PUT("/indices", cronHandler.UpdateIndices)
 	cronGroup.PUT("/instruments", cronHandler.UpdateInstruments)
+	cronGroup.PUT("/instruments_delta", cronHandler.SyncNewInstruments)
 	cronGroup.PUT("/ticker_instruments", cronHandler.TickerInstrumentsUpdateJob)
+	cronGroup.PUT("/price_bands", cronHandler.UpdatePriceBands)
+	cronGroup.PUT("/deals", cronHandler.UpdateDeals)
+	cronGroup.PUT("/close_reconcile", cronHandler.RunCloseReconcile)
+	cronGroup.PUT("/db_maintenance", cronHandler.RunDatabaseMaintenance)
+	cronGroup.PUT("/candle_backfill", cronHandler.RunCandleBackfill)
+	cronGroup.PUT("/greeks_backfill", cronHandler.RunGreeksBackfill)
+	cronGroup.PUT("/session_keep_alive", cronHandler.RunSessionKeepAlive)
+	cronGroup.PUT("/backup", cronHandler.RunBackup)
 	// cronGroup.GET("/ticker_start", cronHandler.TickerStartJob)
 	// cronGroup.GET("/ticker_stop", cronHandler.TickerStopJob)
+
+	// Job routes (protected)
+	jobHandler := handlers.NewJobHandler(service.NewJobService(db))
+	jobGroup := api.Group("/jobs")
+	jobGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	jobGroup.Use(middleware.CompressMiddleware(cfg, "jobs"))
+	jobGroup.Use(middleware.AuthMiddleware(db))
+	jobGroup.GET("/:id", jobHandler.GetJob)
+
+	// Admin routes (protected)
+	adminHandler := handlers.NewAdminHandler(tickerService, service.NewStorageService(db), service.NewKillSwitchService(db), service.NewInstrumentAccessService(db), service.NewDataTrimService(db), service.NewDataDelayService(db), service.NewCloseReconcileService(db), service.NewRedisKeyService(redisClient), service.NewInstrumentBlacklistService(db))
+	adminGroup := api.Group("/admin")
+	adminGroup.Use(middleware.BodyLimitMiddleware(bodyLimit))
+	adminGroup.Use(middleware.CompressMiddleware(cfg, "admin"))
+	adminGroup.Use(middleware.AccessMiddleware(cfg))
+	adminGroup.Use(middleware.AuthMiddleware(db))
+	adminGroup.Use(middleware.AdminMiddleware(cfg))
+	adminGroup.POST("/reconcile", adminHandler.ReconcileTickerInstruments)
+	adminGroup.GET("/storage", adminHandler.GetStorage)
+	adminGroup.GET("/db_metrics", adminHandler.GetDBMetrics)
+	adminGroup.GET("/ticker_logs", adminHandler.GetTickerLogs)
+	adminGroup.GET("/redis_keys", adminHandler.GetRedisKeys)
+	adminGroup.POST("/redis_keys/expire", adminHandler.ExpireRedisKeys)
+	adminGroup.GET("/killswitch", adminHandler.GetKillSwitch)
+	adminGroup.POST("/killswitch", adminHandler.EngageKillSwitch)
+	adminGroup.DELETE("/killswitch", adminHandler.ReleaseKillSwitch)
+	adminGroup.GET("/token_budget", adminHandler.GetTokenBudget)
+	adminGroup.GET("/popular_instruments", adminHandler.GetPopularInstruments)
+	adminGroup.GET("/historical_queue", adminHandler.GetHistoricalQueue)
+	adminGroup.DELETE("/data", adminHandler.TrimData)
+	adminGroup.GET("/data_delays", adminHandler.GetDataDelays)
+	adminGroup.PUT("/data_delays", adminHandler.SetDataDelay)
+	adminGroup.DELETE("/data_delays", adminHandler.RemoveDataDelay)
+	adminGroup.PUT("/limits", riskHandler.SaveRiskLimits)
+	adminGroup.DELETE("/limits", riskHandler.DeleteRiskLimits)
+	adminGroup.GET("/data_quality", adminHandler.GetDataQuality)
+	adminGroup.GET("/instrument_blacklist", adminHandler.GetInstrumentBlacklist)
+	adminGroup.PUT("/instrument_blacklist", adminHandler.BlacklistInstruments)
+	adminGroup.DELETE("/instrument_blacklist", adminHandler.RemoveBlacklistedInstruments)
+	adminGroup.PUT("/instrument_tags/:tag", instrumentTagHandler.TagGlobalInstruments)
+	adminGroup.DELETE("/instrument_tags/:tag", instrumentTagHandler.UntagGlobalInstruments)
+	adminConsoleHandler := handlers.NewAdminConsoleHandler(tickerService, cronHandler.CronService, service.NewJobService(db))
+	adminGroup.GET("/console", adminConsoleHandler.Console)
+	selfTestHandler := handlers.NewSelfTestHandler(service.NewSelfTestService(e, db))
+	adminGroup.POST("/selftest", selfTestHandler.RunSelfTest)
 }
 
 // indexRoute sets up the index route for the API
@@ -101,3 +295,8 @@ func indexRoute(c echo.Context) error {
 	message := fmt.Sprintf("%s %s", cfg.APIName, cfg.APIVersion)
 	return response.SuccessResponse(c, message)
 }
+
+// errorsRoute returns the catalog of error types returned by the API
+func errorsRoute(c echo.Context) error {
+	return response.SuccessResponse(c, response.ErrorCatalog)
+}
@@ -2,11 +2,17 @@
 package config
 
 import (
+	"encoding/hex"
 	"fmt"
+	"net"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
 )
 
 // Config represents the application configuration
@@ -22,11 +28,233 @@ type Config struct {
 	RedisHost            string `env:"MB_API_REDIS_HOST"`
 	RedisPort            string `env:"MB_API_REDIS_PORT"`
 	RedisPassword        string `env:"MB_API_REDIS_PASSWORD"`
-	TelegramBotToken     string `env:"MB_API_TELEGRAM_BOT_TOKEN"`
-	TelegramChatID       string `env:"MB_API_TELEGRAM_CHAT_ID"`
+	TelegramBotToken     string `env:"MB_API_TELEGRAM_BOT_TOKEN" default:""`
+	TelegramChatID       string `env:"MB_API_TELEGRAM_CHAT_ID" default:""`
+	SMTPHost             string `env:"MB_API_SMTP_HOST" default:""`
+	SMTPPort             string `env:"MB_API_SMTP_PORT" default:"587" validate:"int"`
+	SMTPUsername         string `env:"MB_API_SMTP_USERNAME" default:""`
+	SMTPPassword         string `env:"MB_API_SMTP_PASSWORD" default:""`
+	EmailFrom            string `env:"MB_API_EMAIL_FROM" default:""`
 	KitetickerUserID     string `env:"MB_API_KITETICKER_USER_ID"`
 	KitetickerPassword   string `env:"MB_API_KITETICKER_PASSWORD"`
-	KitetickerTotpSecret string `env:"MB_API_KITETICKER_TOTP_SECRET"`
+	KitetickerTotpSecret string `env:"MB_API_KITETICKER_TOTP_SECRET" default:""`
+
+	// Source of the TOTP code for the ticker login, secret generates it from the TOTP secret, http fetches it
+	// from the url ({user_id} is replaced) and telegram asks for it in the telegram chat and waits for the reply
+	KitetickerTotpProvider       string `env:"MB_API_KITETICKER_TOTP_PROVIDER" default:"secret" validate:"totp_provider"`
+	KitetickerTotpURL            string `env:"MB_API_KITETICKER_TOTP_URL" default:""`
+	KitetickerTotpURLToken       string `env:"MB_API_KITETICKER_TOTP_URL_TOKEN" default:""`
+	KitetickerTotpTimeoutSeconds string `env:"MB_API_KITETICKER_TOTP_TIMEOUT_SECONDS" default:"120" validate:"int"`
+
+	// Public read-only mode, the indices, instrument search and delayed quotes are served under /public without
+	// authentication, limited per client IP to the rate (requests per second) and burst. Public quotes are the
+	// close of the last 1 minute candle completed the delay ago
+	PublicReadOnly          string `env:"MB_API_PUBLIC_READ_ONLY" default:"false" validate:"bool"`
+	PublicRateLimit         string `env:"MB_API_PUBLIC_RATE_LIMIT" default:"1" validate:"float"`
+	PublicRateBurst         string `env:"MB_API_PUBLIC_RATE_BURST" default:"5" validate:"int"`
+	PublicQuoteDelayMinutes string `env:"MB_API_PUBLIC_QUOTE_DELAY_MINUTES" default:"15" validate:"int"`
+
+	// Delay of the market data of a user restricted to delayed data when no delay is given for the user
+	DataDelayMinutes string `env:"MB_API_DATA_DELAY_MINUTES" default:"15" validate:"int"`
+
+	// Statements slower than this many milliseconds are logged as slow by the database logger and counted
+	// as slow in the database metrics, 0 disables
+	PostgresSlowQueryMs string `env:"MB_API_PG_SLOW_QUERY_MS" default:"200" validate:"int"`
+
+	// Redis topology, standalone, sentinel or cluster. Sentinel and cluster use the comma separated
+	// addresses of the sentinels or cluster nodes and fall back to the host and port
+	RedisMode             string `env:"MB_API_REDIS_MODE" default:"standalone" validate:"redis_mode"`
+	RedisAddrs            string `env:"MB_API_REDIS_ADDRS" default:""`
+	RedisSentinelMaster   string `env:"MB_API_REDIS_SENTINEL_MASTER" default:"mymaster"`
+	RedisSentinelPassword string `env:"MB_API_REDIS_SENTINEL_PASSWORD" default:""`
+
+	// Redis database and the prefix of every key and channel written, so environments can share a redis.
+	// Cluster mode only has database 0
+	RedisDB        string `env:"MB_API_REDIS_DB" default:"0" validate:"int"`
+	RedisKeyPrefix string `env:"MB_API_REDIS_KEY_PREFIX" default:""`
+
+	// Hours the previous closes primed into redis live before they expire, they are primed again every morning
+	RedisCloseTTLHours string `env:"MB_API_REDIS_CLOSE_TTL_HOURS" default:"36" validate:"int"`
+
+	// Path prefix the routes are mounted under, e.g. /moneybots behind a reverse proxy forwarding the prefix,
+	// blank mounts them at the root
+	BasePath string `env:"MB_API_BASE_PATH" default:"" validate:"base_path"`
+
+	// Reverse proxies trusted for the X-Forwarded-For, X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Prefix
+	// headers, comma separated IPs or CIDRs. Blank trusts no proxy, the client IP is the peer address
+	TrustedProxies string `env:"MB_API_TRUSTED_PROXIES" default:"" validate:"cidrs"`
+
+	// Access control for the admin and cron routes, comma separated IPs or CIDRs, blank allows all
+	AdminAllowedIPs string `env:"MB_API_ADMIN_ALLOWED_IPS" default:"" validate:"cidrs"`
+
+	// Users allowed on the admin and cron routes, comma separated user IDs. Blank denies the routes to every user
+	AdminUserIDs string `env:"MB_API_ADMIN_USER_IDS" default:""`
+
+	// TLS, the server listens with TLS when a certificate is set and requires
+	// client certificates signed by the client CA on the admin and cron routes
+	ServerTLSCertFile     string `env:"MB_API_SERVER_TLS_CERT_FILE" default:""`
+	ServerTLSKeyFile      string `env:"MB_API_SERVER_TLS_KEY_FILE" default:""`
+	ServerTLSClientCAFile string `env:"MB_API_SERVER_TLS_CLIENT_CA_FILE" default:""`
+
+	// CORS, comma separated lists, blank origins disables CORS
+	CORSAllowOrigins     string `env:"MB_API_CORS_ALLOW_ORIGINS" default:""`
+	CORSAllowHeaders     string `env:"MB_API_CORS_ALLOW_HEADERS" default:"Authorization,Content-Type,Accept,Last-Event-ID"`
+	CORSAllowCredentials string `env:"MB_API_CORS_ALLOW_CREDENTIALS" default:"false" validate:"bool"`
+
+	// Security headers, HSTS max age in seconds, 0 disables the HSTS header
+	SecurityHeaders       string `env:"MB_API_SECURITY_HEADERS" default:"true" validate:"bool"`
+	SecurityHSTSMaxAge    string `env:"MB_API_SECURITY_HSTS_MAX_AGE" default:"0" validate:"int"`
+	SecurityFrameOptions  string `env:"MB_API_SECURITY_FRAME_OPTIONS" default:"DENY"`
+	SecurityContentPolicy string `env:"MB_API_SECURITY_CONTENT_POLICY" default:"default-src 'none'; frame-ancestors 'none'"`
+
+	// Gzip compression, comma separated route groups (e.g. instruments,quote) and gzip level 1-9
+	CompressionGroups string `env:"MB_API_COMPRESSION_GROUPS" default:"instruments,indices,quote"`
+	CompressionLevel  string `env:"MB_API_COMPRESSION_LEVEL" default:"5" validate:"int"`
+
+	// Request body limits in bytes, the instruments limit applies to routes posting instrument lists
+	BodyLimit            string `env:"MB_API_BODY_LIMIT" default:"65536" validate:"int"`
+	BodyLimitInstruments string `env:"MB_API_BODY_LIMIT_INSTRUMENTS" default:"1048576" validate:"int"`
+
+	// Daily stream quotas per user, 0 is unlimited
+	StreamQuotaDailyMinutes  string `env:"MB_API_STREAM_QUOTA_DAILY_MINUTES" default:"0" validate:"int"`
+	StreamQuotaDailyMessages string `env:"MB_API_STREAM_QUOTA_DAILY_MESSAGES" default:"0" validate:"int"`
+	StreamQuotaDailyBytes    string `env:"MB_API_STREAM_QUOTA_DAILY_BYTES" default:"0" validate:"int"`
+
+	// Tokens a Kite connection may subscribe, shared by the ticker and stream budgets, 0 is unlimited
+	KiteMaxTokensPerConnection string `env:"MB_API_KITE_MAX_TOKENS_PER_CONNECTION" default:"3000" validate:"int"`
+
+	// Seconds a stream client may go without acknowledging a ping before it is dropped, 0 disables the pings
+	StreamAckTimeoutSeconds string `env:"MB_API_STREAM_ACK_TIMEOUT_SECONDS" default:"0" validate:"int"`
+
+	// Embed the exchange, tradingsymbol, segment and lot size of the instrument in the ticks published to redis
+	RedisTickEnrichment string `env:"MB_API_REDIS_TICK_ENRICHMENT" default:"false" validate:"bool"`
+
+	// Storage of the tick OHLC and depth in the ticker data, jsonb blobs, typed columns (OHLC and best bid/ask) or both
+	TickerDataStorage string `env:"MB_API_TICKER_DATA_STORAGE" default:"jsonb" validate:"ticker_data_storage"`
+
+	// Exchange session calendar of the out of session tick alarm, ticks timestamped outside the session of their exchange
+	// are tagged and alerted at most once per exchange every alert minutes. The windows are EXCHANGE=HH:MM-HH:MM pairs
+	// overriding the default windows, from the pre-open to half an hour past the close. The holidays are YYYY-MM-DD
+	// dates all exchanges are closed on, weekends are always closed
+	TickSessionWindows      string `env:"MB_API_TICK_SESSION_WINDOWS" default:"" validate:"session_windows"`
+	TickSessionHolidays     string `env:"MB_API_TICK_SESSION_HOLIDAYS" default:"" validate:"dates"`
+	TickSessionAlertMinutes string `env:"MB_API_TICK_SESSION_ALERT_MINUTES" default:"30" validate:"int"`
+
+	// Decimal places the tick prices are rounded to before they are stored and streamed, SEGMENT=DECIMALS pairs
+	// (e.g. CDS-FUT=4,NSE=2) overriding the precision of the instrument tick size, at most 4
+	TickPrecisions string `env:"MB_API_TICK_PRECISIONS" default:"" validate:"precisions"`
+
+	// Historical greeks backfill, the option candles are sampled every interval minutes and priced with the
+	// Black-Scholes model at the annual risk free rate. Sessions of the last backfill days without greeks are computed
+	GreeksIntervalMinutes string `env:"MB_API_GREEKS_INTERVAL_MINUTES" default:"15" validate:"int"`
+	GreeksRiskFreeRate    string `env:"MB_API_GREEKS_RISK_FREE_RATE" default:"0.065" validate:"float"`
+	GreeksBackfillDays    string `env:"MB_API_GREEKS_BACKFILL_DAYS" default:"30" validate:"int"`
+
+	// Self test of the critical endpoints, also run once the server starts when enabled, with the session of the
+	// ticker user. The quote and stream checks use the instrument, the stream is subscribed for the seconds
+	SelfTestOnStartup     string `env:"MB_API_SELFTEST_ON_STARTUP" default:"false" validate:"bool"`
+	SelfTestInstrument    string `env:"MB_API_SELFTEST_INSTRUMENT" default:"NSE:INFY"`
+	SelfTestStreamSeconds string `env:"MB_API_SELFTEST_STREAM_SECONDS" default:"3" validate:"int"`
+
+	// Age in seconds after which a quote is flagged stale during trading hours
+	QuoteStaleSeconds string `env:"MB_API_QUOTE_STALE_SECONDS" default:"60" validate:"int"`
+
+	// Time to live in seconds of cached option chains, 0 disables the cache
+	OptionChainCacheSeconds string `env:"MB_API_OPTION_CHAIN_CACHE_SECONDS" default:"30" validate:"int"`
+
+	// Time to live in seconds of cached resampled candles, 0 disables the cache
+	CandleCacheSeconds string `env:"MB_API_CANDLE_CACHE_SECONDS" default:"60" validate:"int"`
+
+	// Shortest run of missing 1 minute candles reported as a gap and backfilled, illiquid instruments skip minutes
+	CandleGapMinMinutes string `env:"MB_API_CANDLE_GAP_MIN_MINUTES" default:"1" validate:"int"`
+
+	// Pace of the kite historical API requests, kite blocks clients going over 3 requests a second
+	KiteHistoricalRequestsPerSecond string `env:"MB_API_KITE_HISTORICAL_REQUESTS_PER_SECOND" default:"3" validate:"float"`
+
+	// Sentry error reporting of recovered panics, blank DSN disables reporting
+	SentryDSN         string `env:"MB_API_SENTRY_DSN" default:""`
+	SentryEnvironment string `env:"MB_API_SENTRY_ENVIRONMENT" default:"production"`
+
+	// Directory of the write-ahead journal of the received ticks, replayed when the ticker starts after a crash.
+	// Blank disables the journal, a crash loses at most the ticks of one sync interval
+	TickerJournalDir    string `env:"MB_API_TICKER_JOURNAL_DIR" default:""`
+	TickerJournalSyncMs string `env:"MB_API_TICKER_JOURNAL_SYNC_MS" default:"50" validate:"int"`
+
+	// Fraction of unparsable rows tolerated in the instruments dump before the load is aborted
+	InstrumentsBadRowsTolerance string `env:"MB_API_INSTRUMENTS_BAD_ROWS_TOLERANCE" default:"0.001" validate:"float"`
+
+	// Subscribe instruments added by the intraday delta sync when they match a ticker instrument preset
+	InstrumentsDeltaAutoSubscribe string `env:"MB_API_INSTRUMENTS_DELTA_AUTO_SUBSCRIBE" default:"false" validate:"bool"`
+
+	// NSE daily price band file, blank disables the price bands update
+	PriceBandsURL string `env:"MB_API_PRICE_BANDS_URL" default:"https://nsearchives.nseindia.com/content/equities/sec_list.csv"`
+
+	// NSE bulk and block deal files, a blank url skips the file
+	BulkDealsURL  string `env:"MB_API_BULK_DEALS_URL" default:"https://nsearchives.nseindia.com/content/equities/bulk.csv"`
+	BlockDealsURL string `env:"MB_API_BLOCK_DEALS_URL" default:"https://nsearchives.nseindia.com/content/equities/block.csv"`
+
+	// NSE corporate announcements RSS feed, blank disables the announcements update
+	AnnouncementsURL string `env:"MB_API_ANNOUNCEMENTS_URL" default:"https://nsearchives.nseindia.com/content/RSS/Online_announcements.xml"`
+
+	// NSE full bhavcopy with {date} for the trading date as DDMMYYYY, blank disables the close reconciliation.
+	// Recorded last prices diverging from the official close by more than the tolerance percent are flagged
+	BhavcopyURL                    string `env:"MB_API_BHAVCOPY_URL" default:"https://nsearchives.nseindia.com/products/content/sec_bhavdata_full_{date}.csv"`
+	CloseReconcileTolerancePercent string `env:"MB_API_CLOSE_RECONCILE_TOLERANCE_PERCENT" default:"0.5" validate:"float"`
+
+	// Object storage of the backups of the critical tables, s3://bucket/prefix or file:///directory, blank disables
+	// the backup. S3 compatible stores are reached at the endpoint, the AWS endpoint of the region when blank
+	BackupURL         string `env:"MB_API_BACKUP_URL" default:""`
+	BackupS3Endpoint  string `env:"MB_API_BACKUP_S3_ENDPOINT" default:""`
+	BackupS3Region    string `env:"MB_API_BACKUP_S3_REGION" default:"us-east-1"`
+	BackupS3AccessKey string `env:"MB_API_BACKUP_S3_ACCESS_KEY" default:""`
+	BackupS3SecretKey string `env:"MB_API_BACKUP_S3_SECRET_KEY" default:""`
+	// 256 bit key the backups are encrypted with before they leave the server, 64 hex characters
+	BackupEncryptionSecret string `env:"MB_API_BACKUP_ENCRYPTION_SECRET" default:""`
+
+	// IANA time zone of the exchanges, cron schedules and all market time logic use it regardless of the server time zone
+	MarketTimezone string `env:"MB_API_MARKET_TIMEZONE" default:"Asia/Kolkata" validate:"timezone"`
+
+	// Simulated market clock for testing the market lifecycle off hours, the scheduler and trading hour checks run
+	// from the start time (YYYY-MM-DD HH:MM:SS in the market time zone) at the speed, a blank start uses the real clock
+	ClockSimulatedStart string `env:"MB_API_CLOCK_SIMULATED_START" default:"" validate:"datetime"`
+	ClockSimulatedSpeed string `env:"MB_API_CLOCK_SIMULATED_SPEED" default:"1" validate:"float"`
+
+	// Cron schedules, standard 5 field cron expressions in the market time zone, blank disables the job
+	CronInstrumentsUpdate          string `env:"MB_API_CRON_INSTRUMENTS_UPDATE" default:"0 8 * * 1-5" validate:"cron"`
+	CronIndicesUpdate              string `env:"MB_API_CRON_INDICES_UPDATE" default:"1 8 * * 1-5" validate:"cron"`
+	CronInstrumentsDeltaSync       string `env:"MB_API_CRON_INSTRUMENTS_DELTA_SYNC" default:"*/30 9-15 * * 1-5" validate:"cron"`
+	CronTickerInstrumentsUpdate    string `env:"MB_API_CRON_TICKER_INSTRUMENTS_UPDATE" default:"2 8 * * 1-5" validate:"cron"`
+	CronTickerInstrumentsReconcile string `env:"MB_API_CRON_TICKER_INSTRUMENTS_RECONCILE" default:"5 8 * * 1-5" validate:"cron"`
+	CronTickerDataTruncate         string `env:"MB_API_CRON_TICKER_DATA_TRUNCATE" default:"" validate:"cron"`
+	CronTickerStart                string `env:"MB_API_CRON_TICKER_START" default:"55 8 * * 1-5" validate:"cron"`
+	CronMarketWarmup               string `env:"MB_API_CRON_MARKET_WARMUP" default:"45 8 * * 1-5" validate:"cron"`
+	CronTickerStop                 string `env:"MB_API_CRON_TICKER_STOP" default:"59 23 * * 1-5" validate:"cron"`
+	CronFuturesBasisSnapshot       string `env:"MB_API_CRON_FUTURES_BASIS_SNAPSHOT" default:"* 9-15 * * 1-5" validate:"cron"`
+	CronPriceBandsUpdate           string `env:"MB_API_CRON_PRICE_BANDS_UPDATE" default:"15 8 * * 1-5" validate:"cron"`
+	CronDealsUpdate                string `env:"MB_API_CRON_DEALS_UPDATE" default:"30 18 * * 1-5" validate:"cron"`
+	CronCloseReconcile             string `env:"MB_API_CRON_CLOSE_RECONCILE" default:"45 18 * * 1-5" validate:"cron"`
+	CronAnnouncementsUpdate        string `env:"MB_API_CRON_ANNOUNCEMENTS_UPDATE" default:"* 7-20 * * 1-5" validate:"cron"`
+	CronEODReport                  string `env:"MB_API_CRON_EOD_REPORT" default:"0 16 * * 1-5" validate:"cron"`
+	CronDatabaseMaintenance        string `env:"MB_API_CRON_DATABASE_MAINTENANCE" default:"30 1 * * *" validate:"cron"`
+	CronCandleBackfill             string `env:"MB_API_CRON_CANDLE_BACKFILL" default:"40 15 * * 1-5" validate:"cron"`
+	CronSessionKeepAlive           string `env:"MB_API_CRON_SESSION_KEEP_ALIVE" default:"" validate:"cron"`
+	CronBackup                     string `env:"MB_API_CRON_BACKUP" default:"0 2 * * *" validate:"cron"`
+	CronGreeksBackfill             string `env:"MB_API_CRON_GREEKS_BACKFILL" default:"15 19 * * 1-5" validate:"cron"`
+
+	// Days of rows kept by the database maintenance, 0 keeps all rows. ERROR and FATAL ticker logs are kept for the
+	// error days, the other levels for the ticker logs days
+	RetentionTickerLogsDays      string `env:"MB_API_RETENTION_TICKER_LOGS_DAYS" default:"30" validate:"int"`
+	RetentionTickerLogsErrorDays string `env:"MB_API_RETENTION_TICKER_LOGS_ERROR_DAYS" default:"180" validate:"int"`
+	RetentionJobsDays            string `env:"MB_API_RETENTION_JOBS_DAYS" default:"30" validate:"int"`
+	RetentionActivityDays        string `env:"MB_API_RETENTION_ACTIVITY_DAYS" default:"90" validate:"int"`
+
+	// Archive the ticker logs to the backup store as gzipped JSON before the maintenance prunes them
+	RetentionTickerLogsArchive string `env:"MB_API_RETENTION_TICKER_LOGS_ARCHIVE" default:"false" validate:"bool"`
+
+	// Disk provisioned for the database and the usage percentages projected by /admin/storage, 0 capacity skips the projection
+	StorageCapacityBytes    string `env:"MB_API_STORAGE_CAPACITY_BYTES" default:"0" validate:"int"`
+	StorageThresholdPercent string `env:"MB_API_STORAGE_THRESHOLD_PERCENT" default:"80,90" validate:"percents"`
+	StorageGrowthWindowDays string `env:"MB_API_STORAGE_GROWTH_WINDOW_DAYS" default:"7" validate:"int"`
 }
 
 var (
@@ -54,6 +282,9 @@ func loadConfig() (*Config, error) {
 	if err := cfg.loadFromEnv(); err != nil {
 		return nil, err
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
@@ -69,9 +300,14 @@ func (c *Config) loadFromEnv() error {
 			return fmt.Errorf("missing env tag for field %s", field.Name)
 		}
 
+		// fields with a default tag are optional
 		value := os.Getenv(envTag)
 		if value == "" {
-			return fmt.Errorf("env variable %s is required but not set", envTag)
+			defaultValue, ok := field.Tag.Lookup("default")
+			if !ok {
+				return fmt.Errorf("env variable %s is required but not set", envTag)
+			}
+			value = defaultValue
 		}
 
 		v.Field(i).SetString(value)
@@ -80,6 +316,313 @@ func (c *Config) loadFromEnv() error {
 	return nil
 }
 
+// validate validates the configuration values against their validate tag
+func (c *Config) validate() error {
+	t := reflect.TypeOf(*c)
+	v := reflect.ValueOf(*c)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i).String()
+
+		switch field.Tag.Get("validate") {
+		case "cron":
+			if value == "" {
+				continue
+			}
+			if _, err := cron.ParseStandard(value); err != nil {
+				return fmt.Errorf("env variable %s has an invalid cron schedule %q: %v", field.Tag.Get("env"), value, err)
+			}
+		case "cidrs":
+			if _, err := ParseIPNets(value); err != nil {
+				return fmt.Errorf("env variable %s is invalid: %v", field.Tag.Get("env"), err)
+			}
+		case "base_path":
+			if value != "" && (!strings.HasPrefix(value, "/") || strings.HasSuffix(value, "/")) {
+				return fmt.Errorf("env variable %s must start with / and not end with /, got %q", field.Tag.Get("env"), value)
+			}
+		case "session_windows":
+			if _, err := ParseSessionWindows(value); err != nil {
+				return fmt.Errorf("env variable %s is invalid: %v", field.Tag.Get("env"), err)
+			}
+		case "precisions":
+			if _, err := ParsePrecisions(value); err != nil {
+				return fmt.Errorf("env variable %s is invalid: %v", field.Tag.Get("env"), err)
+			}
+		case "dates":
+			if _, err := ParseDates(value); err != nil {
+				return fmt.Errorf("env variable %s is invalid: %v", field.Tag.Get("env"), err)
+			}
+		case "redis_mode":
+			if value != "standalone" && value != "sentinel" && value != "cluster" {
+				return fmt.Errorf("env variable %s must be standalone, sentinel or cluster, got %q", field.Tag.Get("env"), value)
+			}
+		case "totp_provider":
+			if value != "secret" && value != "http" && value != "telegram" {
+				return fmt.Errorf("env variable %s must be secret, http or telegram, got %q", field.Tag.Get("env"), value)
+			}
+		case "ticker_data_storage":
+			if value != "jsonb" && value != "columns" && value != "both" {
+				return fmt.Errorf("env variable %s must be jsonb, columns or both, got %q", field.Tag.Get("env"), value)
+			}
+		case "datetime":
+			if value == "" {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02 15:04:05", value); err != nil {
+				return fmt.Errorf("env variable %s must be a YYYY-MM-DD HH:MM:SS time, got %q", field.Tag.Get("env"), value)
+			}
+		case "timezone":
+			if _, err := time.LoadLocation(value); err != nil {
+				return fmt.Errorf("env variable %s must be an IANA time zone, got %q", field.Tag.Get("env"), value)
+			}
+		case "bool":
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("env variable %s must be a boolean, got %q", field.Tag.Get("env"), value)
+			}
+		case "int":
+			if _, err := strconv.Atoi(value); err != nil {
+				return fmt.Errorf("env variable %s must be an integer, got %q", field.Tag.Get("env"), value)
+			}
+		case "float":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("env variable %s must be a number, got %q", field.Tag.Get("env"), value)
+			}
+		case "percents":
+			if _, err := ParsePercents(value); err != nil {
+				return fmt.Errorf("env variable %s is invalid: %v", field.Tag.Get("env"), err)
+			}
+		}
+	}
+
+	if (c.ServerTLSCertFile == "") != (c.ServerTLSKeyFile == "") {
+		return fmt.Errorf("env variables MB_API_SERVER_TLS_CERT_FILE and MB_API_SERVER_TLS_KEY_FILE must be set together")
+	}
+	if c.ServerTLSClientCAFile != "" && c.ServerTLSCertFile == "" {
+		return fmt.Errorf("env variable MB_API_SERVER_TLS_CLIENT_CA_FILE requires MB_API_SERVER_TLS_CERT_FILE")
+	}
+	if c.KitetickerTotpProvider == "secret" && c.KitetickerTotpSecret == "" {
+		return fmt.Errorf("env variable MB_API_KITETICKER_TOTP_PROVIDER secret requires MB_API_KITETICKER_TOTP_SECRET")
+	}
+	if c.KitetickerTotpProvider == "http" && c.KitetickerTotpURL == "" {
+		return fmt.Errorf("env variable MB_API_KITETICKER_TOTP_PROVIDER http requires MB_API_KITETICKER_TOTP_URL")
+	}
+	if c.KitetickerTotpProvider == "telegram" && (c.TelegramBotToken == "" || c.TelegramChatID == "") {
+		return fmt.Errorf("env variable MB_API_KITETICKER_TOTP_PROVIDER telegram requires MB_API_TELEGRAM_BOT_TOKEN and MB_API_TELEGRAM_CHAT_ID")
+	}
+	if db, _ := strconv.Atoi(c.RedisDB); db < 0 {
+		return fmt.Errorf("env variable MB_API_REDIS_DB must not be negative")
+	} else if db != 0 && c.RedisMode == "cluster" {
+		return fmt.Errorf("env variable MB_API_REDIS_DB must be 0 in cluster mode")
+	}
+	if syncMs, _ := strconv.Atoi(c.TickerJournalSyncMs); syncMs <= 0 {
+		return fmt.Errorf("env variable MB_API_TICKER_JOURNAL_SYNC_MS must be greater than 0")
+	}
+	if hours, _ := strconv.Atoi(c.RedisCloseTTLHours); hours <= 0 {
+		return fmt.Errorf("env variable MB_API_REDIS_CLOSE_TTL_HOURS must be greater than 0")
+	}
+	if rate, _ := strconv.ParseFloat(c.KiteHistoricalRequestsPerSecond, 64); rate <= 0 {
+		return fmt.Errorf("env variable MB_API_KITE_HISTORICAL_REQUESTS_PER_SECOND must be greater than 0")
+	}
+	if limit, _ := strconv.ParseFloat(c.PublicRateLimit, 64); limit <= 0 {
+		return fmt.Errorf("env variable MB_API_PUBLIC_RATE_LIMIT must be greater than 0")
+	}
+	if delay, _ := strconv.Atoi(c.PublicQuoteDelayMinutes); delay < 0 {
+		return fmt.Errorf("env variable MB_API_PUBLIC_QUOTE_DELAY_MINUTES must not be negative")
+	}
+	if delay, _ := strconv.Atoi(c.DataDelayMinutes); delay <= 0 {
+		return fmt.Errorf("env variable MB_API_DATA_DELAY_MINUTES must be greater than 0")
+	}
+	if tolerance, _ := strconv.ParseFloat(c.CloseReconcileTolerancePercent, 64); tolerance < 0 {
+		return fmt.Errorf("env variable MB_API_CLOSE_RECONCILE_TOLERANCE_PERCENT must not be negative")
+	}
+	if strings.HasPrefix(c.BackupURL, "s3://") && (c.BackupS3AccessKey == "" || c.BackupS3SecretKey == "") {
+		return fmt.Errorf("env variable MB_API_BACKUP_URL s3 requires MB_API_BACKUP_S3_ACCESS_KEY and MB_API_BACKUP_S3_SECRET_KEY")
+	}
+	if c.BackupURL != "" && !strings.HasPrefix(c.BackupURL, "s3://") && !strings.HasPrefix(c.BackupURL, "file://") {
+		return fmt.Errorf("env variable MB_API_BACKUP_URL must start with s3:// or file://, got %q", c.BackupURL)
+	}
+	if c.BackupURL != "" && c.BackupEncryptionSecret == "" {
+		return fmt.Errorf("env variable MB_API_BACKUP_URL requires MB_API_BACKUP_ENCRYPTION_SECRET")
+	}
+	if key, err := hex.DecodeString(c.BackupEncryptionSecret); c.BackupEncryptionSecret != "" && (err != nil || len(key) != 32) {
+		return fmt.Errorf("env variable MB_API_BACKUP_ENCRYPTION_SECRET must be 64 hex characters")
+	}
+	if archive, _ := strconv.ParseBool(c.RetentionTickerLogsArchive); archive && c.BackupURL == "" {
+		return fmt.Errorf("env variable MB_API_RETENTION_TICKER_LOGS_ARCHIVE requires MB_API_BACKUP_URL")
+	}
+	if speed, _ := strconv.ParseFloat(c.ClockSimulatedSpeed, 64); speed <= 0 {
+		return fmt.Errorf("env variable MB_API_CLOCK_SIMULATED_SPEED must be greater than 0")
+	}
+	if minutes, _ := strconv.Atoi(c.TickSessionAlertMinutes); minutes <= 0 {
+		return fmt.Errorf("env variable MB_API_TICK_SESSION_ALERT_MINUTES must be greater than 0")
+	}
+	if minutes, _ := strconv.Atoi(c.GreeksIntervalMinutes); minutes <= 0 {
+		return fmt.Errorf("env variable MB_API_GREEKS_INTERVAL_MINUTES must be greater than 0")
+	}
+	if days, _ := strconv.Atoi(c.GreeksBackfillDays); days <= 0 {
+		return fmt.Errorf("env variable MB_API_GREEKS_BACKFILL_DAYS must be greater than 0")
+	}
+	if !strings.Contains(c.SelfTestInstrument, ":") {
+		return fmt.Errorf("env variable MB_API_SELFTEST_INSTRUMENT must be an exchange:tradingsymbol")
+	}
+	if seconds, _ := strconv.Atoi(c.SelfTestStreamSeconds); seconds <= 0 {
+		return fmt.Errorf("env variable MB_API_SELFTEST_STREAM_SECONDS must be greater than 0")
+	}
+
+	return nil
+}
+
+// ParsePercents parses a comma separated list of percentages between 1 and 100
+func ParsePercents(value string) ([]int, error) {
+	var percents []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		percent, err := strconv.Atoi(part)
+		if err != nil || percent < 1 || percent > 100 {
+			return nil, fmt.Errorf("invalid percentage %q", part)
+		}
+		percents = append(percents, percent)
+	}
+	return percents, nil
+}
+
+// SessionWindow is a daily session in minutes after midnight, the end is exclusive
+type SessionWindow struct {
+	Start int
+	End   int
+}
+
+// ParseSessionWindows parses a comma separated list of EXCHANGE=HH:MM-HH:MM session windows, an end of 24:00
+// closes the window at midnight
+func ParseSessionWindows(value string) (map[string]SessionWindow, error) {
+	windows := make(map[string]SessionWindow)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		exchange, window, ok := strings.Cut(part, "=")
+		start, end, ok2 := strings.Cut(window, "-")
+		if !ok || !ok2 || strings.TrimSpace(exchange) == "" {
+			return nil, fmt.Errorf("invalid session window %q, must be EXCHANGE=HH:MM-HH:MM", part)
+		}
+		startMinutes, err := parseClockMinutes(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session window %q: %v", part, err)
+		}
+		endMinutes, err := parseClockMinutes(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session window %q: %v", part, err)
+		}
+		if endMinutes <= startMinutes {
+			return nil, fmt.Errorf("invalid session window %q, the end must be after the start", part)
+		}
+		windows[strings.ToUpper(strings.TrimSpace(exchange))] = SessionWindow{Start: startMinutes, End: endMinutes}
+	}
+	return windows, nil
+}
+
+// parseClockMinutes parses an HH:MM time of day into minutes after midnight, 24:00 is the end of the day
+func parseClockMinutes(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, must be HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseDates parses a comma separated list of YYYY-MM-DD dates
+func ParseDates(value string) ([]string, error) {
+	var dates []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", part); err != nil {
+			return nil, fmt.Errorf("invalid date %q, must be YYYY-MM-DD", part)
+		}
+		dates = append(dates, part)
+	}
+	return dates, nil
+}
+
+// maxPrecision is the most decimal places of a price, those of the fixed point decimal
+const maxPrecision = 4
+
+// ParsePrecisions parses a comma separated list of SEGMENT=DECIMALS precisions
+func ParsePrecisions(value string) (map[string]int, error) {
+	precisions := make(map[string]int)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		segment, decimals, ok := strings.Cut(part, "=")
+		if !ok || strings.TrimSpace(segment) == "" {
+			return nil, fmt.Errorf("invalid precision %q, must be SEGMENT=DECIMALS", part)
+		}
+		places, err := strconv.Atoi(strings.TrimSpace(decimals))
+		if err != nil || places < 0 || places > maxPrecision {
+			return nil, fmt.Errorf("invalid precision %q, the decimals must be 0 to %d", part, maxPrecision)
+		}
+		precisions[strings.ToUpper(strings.TrimSpace(segment))] = places
+	}
+	return precisions, nil
+}
+
+// ParseIPNets parses a comma separated list of IPs and CIDRs, a plain IP is a single host network
+func ParseIPNets(value string) ([]*net.IPNet, error) {
+	var ipNets []*net.IPNet
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			ip := net.ParseIP(part)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", part)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			ipNets = append(ipNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", part)
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets, nil
+}
+
+// IsAdmin returns true if the user is one of the admin users, no user is an admin when none is configured
+func (c *Config) IsAdmin(userID string) bool {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return false
+	}
+	for _, admin := range strings.Split(c.AdminUserIDs, ",") {
+		if strings.EqualFold(strings.TrimSpace(admin), userID) {
+			return true
+		}
+	}
+	return false
+}
+
 // String returns the configuration as a string
 func (c *Config) String() string {
 	var sb strings.Builder
@@ -105,8 +648,20 @@ func (c *Config) String() string {
 	return sb.String()
 }
 
+// Fields returns the configuration with sensitive values masked, keyed by env variable
+func (c *Config) Fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	t := reflect.TypeOf(*c)
+	v := reflect.ValueOf(*c)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fields[field.Tag.Get("env")] = maskSensitiveField(field.Name, v.Field(i).String())
+	}
+	return fields
+}
+
 func maskSensitiveField(fieldName, value string) string {
-	sensitiveFields := []string{"token", "dsn", "secret", "password", "url"}
+	sensitiveFields := []string{"token", "dsn", "secret", "password", "url", "access"}
 
 	fieldNameLower := strings.ToLower(fieldName)
 	for _, sensitive := range sensitiveFields {
@@ -0,0 +1,30 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ActivityEventsTableName is the name of the table for the user activity events
+const ActivityEventsTableName = "activity_events"
+
+// ActivityEventModel is an action taken by a user through the API
+type ActivityEventModel struct {
+	ID        uint64         `gorm:"primaryKey" json:"id"`
+	UserID    string         `gorm:"index:idx_activity_user_created,priority:1;type:varchar(10)" json:"user_id"`
+	Action    string         `gorm:"index;type:varchar(50)" json:"action"`
+	Method    string         `gorm:"type:varchar(10)" json:"method"`
+	Path      string         `json:"path"`
+	Status    int            `json:"status"`
+	IP        string         `gorm:"type:varchar(45)" json:"ip"`
+	RequestID string         `gorm:"index;type:varchar(64)" json:"request_id,omitempty"`
+	Details   datatypes.JSON `gorm:"type:jsonb" json:"details,omitempty"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;index:idx_activity_user_created,priority:2" json:"created_at"`
+}
+
+// TableName specifies the table name for the ActivityEvent model
+func (ActivityEventModel) TableName() string {
+	return ActivityEventsTableName
+}
@@ -0,0 +1,25 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// AnnouncementsTableName is the name of the table for the exchange announcements
+const AnnouncementsTableName = "announcements"
+
+// AnnouncementModel is a corporate announcement published by the exchange, tagged to an instrument when it could be matched
+type AnnouncementModel struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	GUID        string    `gorm:"uniqueIndex" json:"-"`
+	Symbol      string    `gorm:"index" json:"symbol"`
+	Instrument  string    `json:"instrument"`
+	Company     string    `json:"company"`
+	Subject     string    `json:"subject"`
+	Link        string    `json:"link"`
+	PublishedAt time.Time `gorm:"index" json:"published_at"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"-"`
+}
+
+// TableName specifies the table name for the Announcement model
+func (AnnouncementModel) TableName() string {
+	return AnnouncementsTableName
+}
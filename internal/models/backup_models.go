@@ -0,0 +1,23 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Backup is a backup of the critical tables, the rows of each table are a JSON array of the table's rows
+type Backup struct {
+	Version   string                     `json:"version"`
+	CreatedAt time.Time                  `json:"created_at"`
+	Tables    map[string]json.RawMessage `json:"tables"`
+}
+
+// BackupResult is the outcome of a backup or a restore
+type BackupResult struct {
+	Key       string           `json:"key"`
+	Location  string           `json:"location"`
+	CreatedAt time.Time        `json:"created_at"`
+	Bytes     int              `json:"bytes"`
+	Rows      map[string]int64 `json:"rows"`
+}
@@ -0,0 +1,39 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// PriceBandsTableName is the name of the table for the price bands
+const PriceBandsTableName = "price_bands"
+
+// PriceBandModel is the daily price band of an instrument as published by the exchange
+type PriceBandModel struct {
+	Instrument  string    `gorm:"primaryKey" json:"instrument"`
+	Symbol      string    `gorm:"index" json:"symbol"`
+	Series      string    `gorm:"type:varchar(5)" json:"series"`
+	Band        string    `gorm:"type:varchar(10)" json:"band"`
+	BandPercent float64   `json:"band_percent"`
+	Date        string    `gorm:"type:varchar(10)" json:"date"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name for the PriceBand model
+func (PriceBandModel) TableName() string {
+	return PriceBandsTableName
+}
+
+// PriceBand is the price band of an instrument with its circuit limits, limits are zero for instruments without a band
+type PriceBand struct {
+	Instrument        string          `json:"instrument"`
+	Series            string          `json:"series"`
+	Band              string          `json:"band"`
+	BandPercent       float64         `json:"band_percent"`
+	Date              string          `json:"date"`
+	PreviousClose     decimal.Decimal `json:"previous_close"`
+	LowerCircuitLimit decimal.Decimal `json:"lower_circuit_limit"`
+	UpperCircuitLimit decimal.Decimal `json:"upper_circuit_limit"`
+}
@@ -0,0 +1,29 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// FuturesBasisTableName is the name of the table for the futures basis snapshots
+const FuturesBasisTableName = "futures_basis"
+
+// FuturesBasisModel is the basis (future minus spot) of a future at a minute
+type FuturesBasisModel struct {
+	Name           string          `gorm:"primaryKey;type:varchar(30)" json:"name"`
+	Expiry         string          `gorm:"primaryKey;type:varchar(10)" json:"expiry"`
+	Timestamp      time.Time       `gorm:"primaryKey" json:"timestamp"`
+	FutInstrument  string          `json:"fut_instrument"`
+	FutPrice       decimal.Decimal `gorm:"type:decimal(14,4)" json:"fut_price"`
+	SpotInstrument string          `json:"spot_instrument"`
+	SpotPrice      decimal.Decimal `gorm:"type:decimal(14,4)" json:"spot_price"`
+	Basis          decimal.Decimal `gorm:"type:decimal(14,4)" json:"basis"`
+	BasisPercent   decimal.Decimal `gorm:"type:decimal(10,4)" json:"basis_percent"`
+}
+
+// TableName specifies the table name for the FuturesBasis model
+func (FuturesBasisModel) TableName() string {
+	return FuturesBasisTableName
+}
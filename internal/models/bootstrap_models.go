@@ -0,0 +1,30 @@
+// Package models contains the models for the Moneybots API
+package models
+
+// BootstrapProfile is the profile of the authenticated user
+type BootstrapProfile struct {
+	UserID        string `json:"user_id"`
+	UserName      string `json:"user_name"`
+	UserShortname string `json:"user_shortname"`
+	AvatarURL     string `json:"avatar_url"`
+	LoginTime     string `json:"login_time"`
+}
+
+// BootstrapExchange is an exchange with its current market phase and the number of its instruments loaded
+type BootstrapExchange struct {
+	Exchange    string `json:"exchange"`
+	Phase       string `json:"phase"`
+	Instruments int64  `json:"instruments"`
+}
+
+// BootstrapBundle is everything a client needs at startup, in one response
+type BootstrapBundle struct {
+	Version          VersionInfo             `json:"version"`
+	Profile          BootstrapProfile        `json:"profile"`
+	Settings         UserSettings            `json:"settings"`
+	DataDelayMinutes int                     `json:"data_delay_minutes"`
+	Exchanges        []BootstrapExchange     `json:"exchanges"`
+	Indices          map[string][]IndexModel `json:"indices"`
+	Subscriptions    []string                `json:"subscriptions"`
+	Streams          map[string]string       `json:"streams"`
+}
@@ -0,0 +1,92 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// CandlesTableName is the name of the table for the 1 minute candles built from the ticks
+const CandlesTableName = "candles"
+
+// Candle sources
+const (
+	CandleSourceTick     = "tick"
+	CandleSourceBackfill = "backfill"
+)
+
+// CandleModel is the 1 minute candle of an instrument, Timestamp is the start of the minute. InstrumentID is the
+// stable id of the instrument, its candles are read by it so they survive a reassignment of the token
+type CandleModel struct {
+	InstrumentToken uint32          `gorm:"primaryKey" json:"instrument_token"`
+	InstrumentID    uint64          `gorm:"index:idx_candles_instrument_id_ts,priority:1" json:"instrument_id"`
+	Timestamp       time.Time       `gorm:"primaryKey;index;index:idx_candles_instrument_id_ts,priority:2" json:"timestamp"`
+	Instrument      string          `gorm:"index" json:"instrument"`
+	Open            decimal.Decimal `gorm:"type:decimal(14,4)" json:"open"`
+	High            decimal.Decimal `gorm:"type:decimal(14,4)" json:"high"`
+	Low             decimal.Decimal `gorm:"type:decimal(14,4)" json:"low"`
+	Close           decimal.Decimal `gorm:"type:decimal(14,4)" json:"close"`
+	Volume          int64           `json:"volume"`
+	OI              int64           `gorm:"column:oi" json:"oi"`
+	Source          string          `gorm:"type:varchar(10)" json:"source"`
+	UpdatedAt       time.Time       `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name for the Candle model
+func (CandleModel) TableName() string {
+	return CandlesTableName
+}
+
+// Candle is a candle of any interval, Timestamp is the start of the interval
+type Candle struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Open      decimal.Decimal `json:"open"`
+	High      decimal.Decimal `json:"high"`
+	Low       decimal.Decimal `json:"low"`
+	Close     decimal.Decimal `json:"close"`
+	Volume    int64           `json:"volume"`
+	OI        int64           `json:"oi"`
+	// Source is the candle source of the 1 minute candles, backfill if any of them was backfilled
+	Source string `json:"-"`
+	// SourceTimestamp is the end of the last 1 minute candle
+	SourceTimestamp time.Time `json:"-"`
+}
+
+// CandleSeries is the candles of an instrument resampled to an interval
+type CandleSeries struct {
+	Instrument      string    `json:"instrument"`
+	InstrumentToken uint32    `json:"instrument_token"`
+	Interval        string    `json:"interval"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Candles         []Candle  `json:"candles"`
+	// Meta is the lineage of the candles, returned when requested
+	Meta *DataLineage `json:"meta,omitempty"`
+}
+
+// CandleGap is a run of missing 1 minute candles within a trading session, To is exclusive
+type CandleGap struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Minutes int       `json:"minutes"`
+}
+
+// CandleGapReport is the gaps in the 1 minute candles of an instrument
+type CandleGapReport struct {
+	Instrument      string      `json:"instrument"`
+	InstrumentToken uint32      `json:"instrument_token"`
+	From            time.Time   `json:"from"`
+	To              time.Time   `json:"to"`
+	MinMinutes      int         `json:"min_minutes"`
+	MissingMinutes  int         `json:"missing_minutes"`
+	Gaps            []CandleGap `json:"gaps"`
+}
+
+// CandleBackfillResult is the outcome of backfilling the gaps of an instrument
+type CandleBackfillResult struct {
+	Instrument string `json:"instrument"`
+	Gaps       int    `json:"gaps"`
+	Fetched    int    `json:"fetched"`
+	Inserted   int64  `json:"inserted"`
+}
@@ -0,0 +1,53 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// CloseReconciliationsTableName is the name of the table for the daily close reconciliation summaries
+const CloseReconciliationsTableName = "close_reconciliations"
+
+// CloseDivergencesTableName is the name of the table for the instruments flagged by a close reconciliation
+const CloseDivergencesTableName = "close_divergences"
+
+// CloseReconciliationModel is the summary of the comparison of a day's recorded last prices with the bhavcopy closes
+type CloseReconciliationModel struct {
+	Date             string    `gorm:"primaryKey;type:varchar(10)" json:"date"`
+	TolerancePercent float64   `json:"tolerance_percent"`
+	Compared         int       `json:"compared"`
+	Unmatched        int       `json:"unmatched"`
+	Divergent        int       `json:"divergent"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for the CloseReconciliation model
+func (CloseReconciliationModel) TableName() string {
+	return CloseReconciliationsTableName
+}
+
+// CloseDivergenceModel is an instrument whose recorded last price diverged from the official close beyond the tolerance
+type CloseDivergenceModel struct {
+	ID              uint64          `gorm:"primaryKey;autoIncrement" json:"-"`
+	Date            string          `gorm:"type:varchar(10);index" json:"date"`
+	Instrument      string          `gorm:"index" json:"instrument"`
+	InstrumentToken uint32          `json:"instrument_token"`
+	RecordedPrice   decimal.Decimal `gorm:"type:decimal(14,4)" json:"recorded_price"`
+	RecordedAt      time.Time       `json:"recorded_at"`
+	OfficialClose   decimal.Decimal `gorm:"type:decimal(14,4)" json:"official_close"`
+	OfficialLast    decimal.Decimal `gorm:"type:decimal(14,4)" json:"official_last"`
+	DiffPercent     float64         `json:"diff_percent"`
+}
+
+// TableName specifies the table name for the CloseDivergence model
+func (CloseDivergenceModel) TableName() string {
+	return CloseDivergencesTableName
+}
+
+// CloseReconciliationReport is the data quality report of a day, the summary with its divergences
+type CloseReconciliationReport struct {
+	CloseReconciliationModel
+	Divergences []CloseDivergenceModel `json:"divergences"`
+}
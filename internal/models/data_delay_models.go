@@ -0,0 +1,22 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// DataDelaysTableName is the name of the table for the users restricted to delayed market data
+const DataDelaysTableName = "data_delays"
+
+// DataDelayModel restricts a user to market data delayed by the minutes, e.g. where real-time redistribution
+// is not permitted. Quotes are served from the 1 minute candles and real-time streams are refused
+type DataDelayModel struct {
+	UserID       string    `gorm:"primaryKey;type:varchar(10)" json:"user_id"`
+	DelayMinutes int       `json:"delay_minutes"`
+	Reason       string    `json:"reason"`
+	SetBy        string    `gorm:"type:varchar(10)" json:"set_by"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for the DataDelay model
+func (DataDelayModel) TableName() string {
+	return DataDelaysTableName
+}
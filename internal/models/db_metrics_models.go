@@ -0,0 +1,40 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// DBConnectionStats is the state of the Postgres connection pool
+type DBConnectionStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+// DBStatementMetrics is the statements of one kind run on a table, raw statements without a table are
+// counted under the table "raw". Record not found is not counted as an error
+type DBStatementMetrics struct {
+	Table       string     `json:"table"`
+	Operation   string     `json:"operation"`
+	Count       int64      `json:"count"`
+	Errors      int64      `json:"errors"`
+	ErrorRate   float64    `json:"error_rate"`
+	SlowCount   int64      `json:"slow_count"`
+	TotalMs     float64    `json:"total_ms"`
+	AvgMs       float64    `json:"avg_ms"`
+	MaxMs       float64    `json:"max_ms"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// DBMetrics is the connection pool and the statements run since the start
+type DBMetrics struct {
+	Since                time.Time            `json:"since"`
+	SlowQueryThresholdMs int64                `json:"slow_query_threshold_ms"`
+	Connections          DBConnectionStats    `json:"connections"`
+	Statements           []DBStatementMetrics `json:"statements"`
+}
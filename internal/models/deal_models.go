@@ -0,0 +1,33 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// DealsTableName is the name of the table for the block and bulk deals
+const DealsTableName = "deals"
+
+// Deal types
+const (
+	DealTypeBulk  = "bulk"
+	DealTypeBlock = "block"
+)
+
+// DealModel is a block or bulk deal disclosed by the exchange
+type DealModel struct {
+	ID           uint64    `gorm:"primaryKey;autoIncrement" json:"-"`
+	Type         string    `gorm:"type:varchar(5);index:idx_deals_type_date" json:"type"`
+	Date         string    `gorm:"type:varchar(10);index:idx_deals_type_date;index" json:"date"`
+	Symbol       string    `gorm:"index" json:"symbol"`
+	SecurityName string    `json:"security_name"`
+	ClientName   string    `json:"client_name"`
+	Side         string    `gorm:"type:varchar(4)" json:"side"`
+	Quantity     int64     `json:"quantity"`
+	Price        float64   `json:"price"`
+	Remarks      string    `json:"remarks"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"-"`
+}
+
+// TableName specifies the table name for the Deal model
+func (DealModel) TableName() string {
+	return DealsTableName
+}
@@ -0,0 +1,57 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// OptionGreeksTableName is the name of the table for the historical greeks of the options
+const OptionGreeksTableName = "option_greeks"
+
+// OptionGreeksModel is the implied volatility and greeks of an option at the close of a sampled candle, computed
+// from the option and underlying candles. Theta is per calendar day and vega per volatility point
+type OptionGreeksModel struct {
+	InstrumentToken      uint32          `gorm:"primaryKey" json:"instrument_token"`
+	Timestamp            time.Time       `gorm:"primaryKey;index" json:"timestamp"`
+	Instrument           string          `gorm:"index" json:"instrument"`
+	Name                 string          `gorm:"index:idx_og_name_expiry,priority:1;type:varchar(30)" json:"name"`
+	Expiry               string          `gorm:"index:idx_og_name_expiry,priority:2;type:varchar(10)" json:"expiry"`
+	Strike               decimal.Decimal `gorm:"type:decimal(14,4)" json:"strike"`
+	OptionType           string          `gorm:"type:varchar(2)" json:"option_type"`
+	UnderlyingInstrument string          `json:"underlying_instrument"`
+	UnderlyingPrice      decimal.Decimal `gorm:"type:decimal(14,4)" json:"underlying_price"`
+	OptionPrice          decimal.Decimal `gorm:"type:decimal(14,4)" json:"option_price"`
+	IV                   float64         `gorm:"column:iv;type:decimal(10,6)" json:"iv"`
+	Delta                float64         `gorm:"type:decimal(10,6)" json:"delta"`
+	Gamma                float64         `gorm:"type:decimal(12,8)" json:"gamma"`
+	Theta                float64         `gorm:"type:decimal(12,4)" json:"theta"`
+	Vega                 float64         `gorm:"type:decimal(12,4)" json:"vega"`
+	ComputedAt           time.Time       `gorm:"autoUpdateTime" json:"computed_at"`
+}
+
+// TableName specifies the table name for the OptionGreeks model
+func (OptionGreeksModel) TableName() string {
+	return OptionGreeksTableName
+}
+
+// OptionContract is an option definition on a past date with the token its candles are stored under
+type OptionContract struct {
+	InstrumentToken uint32          `json:"instrument_token"`
+	Instrument      string          `json:"instrument"`
+	Exchange        string          `json:"exchange"`
+	Name            string          `json:"name"`
+	Expiry          string          `json:"expiry"`
+	Strike          decimal.Decimal `json:"strike"`
+	InstrumentType  string          `json:"instrument_type"`
+}
+
+// GreeksBackfillResult is the outcome of computing the greeks of a session
+type GreeksBackfillResult struct {
+	Date      string `json:"date"`
+	Contracts int    `json:"contracts"`
+	Samples   int    `json:"samples"`
+	Computed  int    `json:"computed"`
+	Skipped   int    `json:"skipped"`
+}
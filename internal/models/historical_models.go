@@ -0,0 +1,15 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// HistoricalQueueStatus is the state of the kite historical request scheduler
+type HistoricalQueueStatus struct {
+	RequestsPerSecond float64          `json:"requests_per_second"`
+	Queued            map[string]int   `json:"queued"`
+	Processed         map[string]int64 `json:"processed"`
+	Failed            map[string]int64 `json:"failed"`
+	Throttled         int64            `json:"throttled"`
+	LastRequestAt     *time.Time       `json:"last_request_at"`
+	PausedUntil       *time.Time       `json:"paused_until"`
+}
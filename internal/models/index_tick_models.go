@@ -0,0 +1,67 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// IndexTicksTableName is the name of the table for the latest tick of each index
+const IndexTicksTableName = "index_ticks"
+
+// IndexTickModel is the latest tick of an index, indices carry no depth, volume or open interest
+type IndexTickModel struct {
+	Instrument      string          `gorm:"index" json:"instrument"`
+	InstrumentToken uint32          `gorm:"primaryKey" json:"instrument_token"`
+	InstrumentID    uint64          `gorm:"index" json:"instrument_id"`
+	Mode            string          `gorm:"type:varchar(10)" json:"mode"`
+	Timestamp       time.Time       `json:"timestamp"`
+	LastPrice       decimal.Decimal `gorm:"type:decimal(14,4)" json:"last_price"`
+	Open            decimal.Decimal `gorm:"type:decimal(14,4)" json:"open"`
+	High            decimal.Decimal `gorm:"type:decimal(14,4)" json:"high"`
+	Low             decimal.Decimal `gorm:"type:decimal(14,4)" json:"low"`
+	Close           decimal.Decimal `gorm:"type:decimal(14,4)" json:"close"`
+	NetChange       decimal.Decimal `gorm:"type:decimal(14,4)" json:"net_change"`
+	// OutOfSession tags a tick timestamped outside the session of the exchange
+	OutOfSession bool      `json:"out_of_session"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime:nano" json:"updated_at"`
+}
+
+// TableName specifies the table name for the IndexTick model
+func (IndexTickModel) TableName() string {
+	return IndexTicksTableName
+}
+
+// ToTickerData returns the index tick in the ticker data shape, for the readers serving both
+func (t IndexTickModel) ToTickerData() TickerData {
+	ohlc, _ := json.Marshal(TickerDataOHLC{Open: t.Open, High: t.High, Low: t.Low, Close: t.Close})
+	depth, _ := json.Marshal(TickerDataDepth{})
+	return TickerData{
+		Instrument:      t.Instrument,
+		InstrumentToken: t.InstrumentToken,
+		InstrumentID:    t.InstrumentID,
+		Mode:            t.Mode,
+		IsIndex:         true,
+		Timestamp:       t.Timestamp,
+		LastPrice:       t.LastPrice,
+		NetChange:       t.NetChange,
+		Open:            t.Open,
+		High:            t.High,
+		Low:             t.Low,
+		Close:           t.Close,
+		OHLC:            ohlc,
+		Depth:           depth,
+		UpdatedAt:       t.UpdatedAt,
+	}
+}
+
+// IndexLTP is the last traded price of an index
+type IndexLTP struct {
+	Instrument      string          `json:"instrument"`
+	InstrumentToken uint32          `json:"instrument_token"`
+	LastPrice       decimal.Decimal `json:"last_price"`
+	NetChange       decimal.Decimal `json:"net_change"`
+	Timestamp       time.Time       `json:"timestamp"`
+}
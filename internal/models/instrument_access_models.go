@@ -0,0 +1,30 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// InstrumentAccessTableName is the name of the table for the daily per instrument access counters
+const InstrumentAccessTableName = "instrument_access"
+
+// InstrumentAccessModel is how often an instrument was queried and streamed on a day
+type InstrumentAccessModel struct {
+	Date       string    `gorm:"primaryKey;type:varchar(10)" json:"date"`
+	Instrument string    `gorm:"primaryKey" json:"instrument"`
+	Queries    int64     `json:"queries"`
+	Streams    int64     `json:"streams"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for the InstrumentAccess model
+func (InstrumentAccessModel) TableName() string {
+	return InstrumentAccessTableName
+}
+
+// PopularInstrument is the access counters of an instrument summed over a range of days
+type PopularInstrument struct {
+	Instrument string `json:"instrument"`
+	Queries    int64  `json:"queries"`
+	Streams    int64  `json:"streams"`
+	Total      int64  `json:"total"`
+	Days       int64  `json:"days"`
+}
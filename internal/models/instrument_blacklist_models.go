@@ -0,0 +1,21 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// InstrumentBlacklistTableName is the name of the table for the instruments that are never subscribed or served
+const InstrumentBlacklistTableName = "instrument_blacklist"
+
+// InstrumentBlacklistModel is an instrument that must never be subscribed or streamed, e.g. an illiquid contract
+// polluting the data or a compliance restricted symbol. The instrument is in the EXCHANGE:TRADINGSYMBOL format
+type InstrumentBlacklistModel struct {
+	Instrument string    `gorm:"primaryKey;type:varchar(100)" json:"instrument"`
+	Reason     string    `json:"reason"`
+	AddedBy    string    `gorm:"type:varchar(10)" json:"added_by"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for the InstrumentBlacklist model
+func (InstrumentBlacklistModel) TableName() string {
+	return InstrumentBlacklistTableName
+}
@@ -0,0 +1,52 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// Tables of the stable instrument ids
+const (
+	InstrumentIDsTableName          = "instrument_ids"
+	InstrumentTokenHistoryTableName = "instrument_token_history"
+)
+
+// InstrumentIDModel is the stable internal id of an exchange:tradingsymbol, it never changes when kite reassigns tokens
+type InstrumentIDModel struct {
+	ID            uint64    `gorm:"primaryKey" json:"instrument_id"`
+	Instrument    string    `gorm:"uniqueIndex" json:"instrument"`
+	Exchange      string    `gorm:"type:varchar(10)" json:"exchange"`
+	Tradingsymbol string    `json:"tradingsymbol"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for the InstrumentID model
+func (InstrumentIDModel) TableName() string {
+	return InstrumentIDsTableName
+}
+
+// InstrumentTokenHistoryModel is the instrument token an instrument id had between two dates, ValidTo is nil for the current token
+type InstrumentTokenHistoryModel struct {
+	ID              uint64  `gorm:"primaryKey" json:"-"`
+	InstrumentID    uint64  `gorm:"index" json:"instrument_id"`
+	InstrumentToken uint32  `gorm:"index" json:"instrument_token"`
+	ValidFrom       string  `gorm:"type:varchar(10)" json:"valid_from"`
+	ValidTo         *string `gorm:"type:varchar(10)" json:"valid_to"`
+}
+
+// TableName specifies the table name for the InstrumentTokenHistory model
+func (InstrumentTokenHistoryModel) TableName() string {
+	return InstrumentTokenHistoryTableName
+}
+
+// InstrumentIDSyncResult is the outcome of syncing the instrument ids with the instruments
+type InstrumentIDSyncResult struct {
+	NewIDs     int64 `json:"new_ids"`
+	Remapped   int64 `json:"remapped"`
+	Backfilled int64 `json:"backfilled"`
+}
+
+// InstrumentTokenHistory is the stable id of an instrument with the tokens it had
+type InstrumentTokenHistory struct {
+	InstrumentID uint64                        `json:"instrument_id"`
+	Instrument   string                        `json:"instrument"`
+	Tokens       []InstrumentTokenHistoryModel `json:"tokens"`
+}
@@ -1,26 +1,32 @@
 // Package models contains the models for the Moneybots API
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
 
 // TableName is the name of the table for instruments
 var InstrumentsTableName = "instruments"
 
 // Instrument represents a trading instrument
 type InstrumentModel struct {
-	InstrumentToken uint32    `gorm:"primaryKey;uniqueIndex;index" csv:"instrument_token" json:"instrument_token"`
-	ExchangeToken   uint32    `csv:"exchange_token" json:"exchange_token"`
-	Tradingsymbol   string    `gorm:"index:idx_ex_ts,priority:2;index:idx_ex_ts_xp,priority:2;index:idx_ex_ts_xp_st,priority:2" csv:"tradingsymbol" json:"tradingsymbol"`
-	Name            string    `gorm:"index:idx_ex_nm_xp,priority:2;" csv:"name" json:"name"`
-	LastPrice       float64   `csv:"last_price" json:"last_price"`
-	Expiry          string    `gorm:"index:idx_ex_nm_xp,priority:3;index:idx_ex_ts_xp,priority:3;index:idx_ex_ts_xp_st,priority:3" csv:"expiry" json:"expiry"`
-	Strike          float64   `gorm:"index:idx_ex_ts_xp_st,priority:4" csv:"strike" json:"strike"`
-	TickSize        float64   `csv:"tick_size" json:"tick_size"`
-	LotSize         uint      `csv:"lot_size" json:"lot_size"`
-	InstrumentType  string    `gorm:"index" csv:"instrument_type" json:"instrument_type"`
-	Segment         string    `gorm:"index" csv:"segment" json:"segment"`
-	Exchange        string    `gorm:"index:idx_ex_nm_xp,priority:1;index:idx_ex_ts,priority:1;index:idx_ex_ts_xp,priority:1;index:idx_ex_ts_xp_st,priority:1" csv:"exchange" json:"exchange"`
-	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"-"`
+	InstrumentToken uint32          `gorm:"primaryKey;uniqueIndex;index" csv:"instrument_token" json:"instrument_token"`
+	ExchangeToken   uint32          `csv:"exchange_token" json:"exchange_token"`
+	Tradingsymbol   string          `gorm:"index:idx_ex_ts,priority:2;index:idx_ex_ts_xp,priority:2;index:idx_ex_ts_xp_st,priority:2" csv:"tradingsymbol" json:"tradingsymbol"`
+	Name            string          `gorm:"index:idx_ex_nm_xp,priority:2;" csv:"name" json:"name"`
+	LastPrice       decimal.Decimal `gorm:"type:decimal(14,4)" csv:"last_price" json:"last_price"`
+	Expiry          string          `gorm:"index:idx_ex_nm_xp,priority:3;index:idx_ex_ts_xp,priority:3;index:idx_ex_ts_xp_st,priority:3" csv:"expiry" json:"expiry"`
+	Strike          decimal.Decimal `gorm:"index:idx_ex_ts_xp_st,priority:4;type:decimal(14,4)" csv:"strike" json:"strike"`
+	TickSize        decimal.Decimal `gorm:"type:decimal(14,4)" csv:"tick_size" json:"tick_size"`
+	LotSize         uint            `csv:"lot_size" json:"lot_size"`
+	InstrumentType  string          `gorm:"index" csv:"instrument_type" json:"instrument_type"`
+	Segment         string          `gorm:"index" csv:"segment" json:"segment"`
+	Exchange        string          `gorm:"index:idx_ex_nm_xp,priority:1;index:idx_ex_ts,priority:1;index:idx_ex_ts_xp,priority:1;index:idx_ex_ts_xp_st,priority:1" csv:"exchange" json:"exchange"`
+	IsWeeklyExpiry  bool            `gorm:"index" csv:"-" json:"is_weekly_expiry"`
+	ExpirySeries    string          `gorm:"type:varchar(10)" csv:"-" json:"expiry_series"`
+	UpdatedAt       time.Time       `gorm:"autoUpdateTime" json:"-"`
 }
 
 // TableName specifies the table name for the Instrument model
@@ -28,6 +34,30 @@ func (InstrumentModel) TableName() string {
 	return InstrumentsTableName
 }
 
+// Expiry series of derivative instruments, derived during the instruments load
+const (
+	ExpirySeriesWeekly  = "weekly"
+	ExpirySeriesMonthly = "monthly"
+)
+
+// InstrumentBadRowsTableName is the name of the table for rows rejected by the instruments load
+var InstrumentBadRowsTableName = "instrument_bad_rows"
+
+// InstrumentBadRow is a value in the instruments dump which failed to parse
+type InstrumentBadRow struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Row       int       `json:"row"`
+	Column    string    `json:"column"`
+	Value     string    `json:"value"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for the InstrumentBadRow model
+func (InstrumentBadRow) TableName() string {
+	return InstrumentBadRowsTableName
+}
+
 // QueryInstrumentsParams is the parameters for the QueryInstruments endpoint
 type QueryInstrumentsParams struct {
 	Exchange        string
@@ -38,4 +68,146 @@ type QueryInstrumentsParams struct {
 	Strike          string
 	Segment         string
 	InstrumentType  string
+	IsWeeklyExpiry  string
+	ExpirySeries    string
+	// AsOf queries the definitions valid on the date instead of the current instruments
+	AsOf string
+}
+
+// StrikeInterval is the strike interval of the options of an underlying for an expiry
+type StrikeInterval struct {
+	Segment        string          `json:"segment"`
+	Expiry         string          `json:"expiry"`
+	StrikeInterval decimal.Decimal `json:"strike_interval"`
+	Strikes        int             `json:"strikes"`
+}
+
+// OptionChainStrike is a strike of an option chain with its call and put
+type OptionChainStrike struct {
+	Strike decimal.Decimal  `json:"strike"`
+	CE     *InstrumentModel `json:"ce,omitempty"`
+	PE     *InstrumentModel `json:"pe,omitempty"`
+}
+
+// OptionChain is the option chain of an underlying for an option expiry
+type OptionChain struct {
+	Exchange             string              `json:"exchange"`
+	Name                 string              `json:"name"`
+	FutExpiry            string              `json:"fut_expiry"`
+	OptExpiry            string              `json:"opt_expiry"`
+	UnderlyingInstrument string              `json:"underlying_instrument"`
+	UnderlyingLastPrice  decimal.Decimal     `json:"underlying_last_price"`
+	ATMStrike            decimal.Decimal     `json:"atm_strike"`
+	TotalStrikes         int                 `json:"total_strikes"`
+	Page                 int                 `json:"page"`
+	PageSize             int                 `json:"page_size"`
+	Strikes              []OptionChainStrike `json:"strikes"`
+}
+
+// FutureOI is the open interest of a future
+type FutureOI struct {
+	Instrument string          `json:"instrument"`
+	Expiry     string          `json:"expiry"`
+	LastPrice  decimal.Decimal `json:"last_price"`
+	OI         uint32          `json:"oi"`
+}
+
+// FuturesRollover is the share of the futures open interest of an underlying held beyond the near expiry
+type FuturesRollover struct {
+	Exchange        string     `json:"exchange"`
+	Name            string     `json:"name"`
+	NearExpiry      string     `json:"near_expiry"`
+	NearOI          uint64     `json:"near_oi"`
+	TotalOI         uint64     `json:"total_oi"`
+	RolloverPercent float64    `json:"rollover_percent"`
+	Futures         []FutureOI `json:"futures"`
+}
+
+// Instrument identifier types accepted by the resolve API
+const (
+	IdentifierTypeToken   = "token"
+	IdentifierTypeSymbol  = "symbol"
+	IdentifierTypeISIN    = "isin"
+	IdentifierTypeUnknown = "unknown"
+)
+
+// InstrumentResolution is the result of resolving an instrument identifier, Error is set when it did not resolve
+type InstrumentResolution struct {
+	Type       string           `json:"type"`
+	Instrument *InstrumentModel `json:"instrument,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// InstrumentHistoryTableName is the name of the table for the instrument definition history
+var InstrumentHistoryTableName = "instrument_history"
+
+// InstrumentHistoryModel is an instrument definition valid from a date until the day before ValidTo,
+// ValidTo is nil for the current definition. The id of a definition is the history version it was opened at
+// and ClosedVersion the version it was closed at, both come from the same sequence
+type InstrumentHistoryModel struct {
+	ID              uint64          `gorm:"primaryKey" json:"-"`
+	InstrumentToken uint32          `gorm:"index" json:"instrument_token"`
+	ExchangeToken   uint32          `json:"exchange_token"`
+	Tradingsymbol   string          `gorm:"index:idx_ih_ex_ts,priority:2" json:"tradingsymbol"`
+	Name            string          `gorm:"index:idx_ih_ex_nm,priority:2" json:"name"`
+	LastPrice       decimal.Decimal `gorm:"type:decimal(14,4)" json:"last_price"`
+	Expiry          string          `json:"expiry"`
+	Strike          decimal.Decimal `gorm:"type:decimal(14,4)" json:"strike"`
+	TickSize        decimal.Decimal `gorm:"type:decimal(14,4)" json:"tick_size"`
+	LotSize         uint            `json:"lot_size"`
+	InstrumentType  string          `json:"instrument_type"`
+	Segment         string          `json:"segment"`
+	Exchange        string          `gorm:"index:idx_ih_ex_ts,priority:1;index:idx_ih_ex_nm,priority:1" json:"exchange"`
+	IsWeeklyExpiry  bool            `json:"is_weekly_expiry"`
+	ExpirySeries    string          `gorm:"type:varchar(10)" json:"expiry_series"`
+	ValidFrom       string          `gorm:"type:varchar(10);index" json:"valid_from"`
+	ValidTo         *string         `gorm:"type:varchar(10);index" json:"valid_to"`
+	ClosedVersion   *uint64         `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for the InstrumentHistory model
+func (InstrumentHistoryModel) TableName() string {
+	return InstrumentHistoryTableName
+}
+
+// InstrumentSync is the change of the instruments since a version of the instrument history, the client passes
+// the version back on its next sync. Full is set when every instrument is returned as added because no version was given
+type InstrumentSync struct {
+	SinceVersion uint64              `json:"since_version"`
+	Version      uint64              `json:"version"`
+	Full         bool                `json:"full"`
+	Added        []InstrumentModel   `json:"added"`
+	Changed      []InstrumentModel   `json:"changed"`
+	Removed      []InstrumentRemoval `json:"removed"`
+}
+
+// InstrumentRemoval is an instrument no longer listed
+type InstrumentRemoval struct {
+	InstrumentToken uint32 `json:"instrument_token"`
+	Exchange        string `json:"exchange"`
+	Tradingsymbol   string `json:"tradingsymbol"`
+}
+
+// InstrumentStatsGroup is the number of instruments of an exchange, segment and instrument type
+type InstrumentStatsGroup struct {
+	Exchange       string    `json:"exchange"`
+	Segment        string    `json:"segment"`
+	InstrumentType string    `json:"instrument_type"`
+	Count          int64     `json:"count"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// InstrumentTokenMap maps instrument tokens to their exchange:tradingsymbol, ETag is a hash of the mapping
+type InstrumentTokenMap struct {
+	Tokens map[string]string `json:"tokens"`
+	Count  int               `json:"count"`
+	ETag   string            `json:"-"`
+}
+
+// InstrumentStats is the number of instruments in total, by exchange and by exchange, segment and instrument type
+type InstrumentStats struct {
+	Total     int64                  `json:"total"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	Exchanges map[string]int64       `json:"exchanges"`
+	Segments  []InstrumentStatsGroup `json:"segments"`
 }
@@ -0,0 +1,28 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// InstrumentTagsTableName is the name of the table for the tags grouping instruments
+const InstrumentTagsTableName = "instrument_tags"
+
+// InstrumentTagModel is an instrument in the EXCHANGE:TRADINGSYMBOL format carrying a tag of a user, a blank user
+// id is a global tag defined by an admin and visible to all users
+type InstrumentTagModel struct {
+	UserId     string    `gorm:"primaryKey;type:varchar(20)" json:"user_id"`
+	Tag        string    `gorm:"primaryKey;type:varchar(50)" json:"tag"`
+	Instrument string    `gorm:"primaryKey;type:varchar(100);index" json:"instrument"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for the InstrumentTag model
+func (InstrumentTagModel) TableName() string {
+	return InstrumentTagsTableName
+}
+
+// InstrumentTagSummary is a tag with the number of instruments carrying it, Global is set for the admin defined tags
+type InstrumentTagSummary struct {
+	Tag         string `json:"tag"`
+	Global      bool   `json:"global"`
+	Instruments int64  `json:"instruments"`
+}
@@ -0,0 +1,35 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const JobsTableName = "jobs"
+
+// Job statuses
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// JobModel tracks a long running operation executed in the background, at most one job of a name is active
+type JobModel struct {
+	ID         uint64         `gorm:"primaryKey" json:"job_id"`
+	Name       string         `gorm:"index;uniqueIndex:idx_jobs_active_name,where:status = 'pending' OR status = 'running';type:varchar(50)" json:"name"`
+	UserID     string         `gorm:"index;type:varchar(10)" json:"user_id"`
+	Status     string         `gorm:"index;type:varchar(10)" json:"status"`
+	Result     datatypes.JSON `gorm:"type:jsonb" json:"result,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+func (JobModel) TableName() string {
+	return JobsTableName
+}
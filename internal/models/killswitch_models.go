@@ -0,0 +1,54 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// KillSwitchesTableName is the name of the table for the engaged kill switches
+const KillSwitchesTableName = "kill_switches"
+
+// KillSwitchEventsTableName is the name of the table for the kill switch audit trail
+const KillSwitchEventsTableName = "kill_switch_events"
+
+// KillSwitchGlobal is the scope of the kill switch covering all users
+const KillSwitchGlobal = "*"
+
+// Kill switch actions
+const (
+	KillSwitchActionEngage  = "engage"
+	KillSwitchActionRelease = "release"
+)
+
+// KillSwitchModel is an engaged kill switch, Scope is a user id or KillSwitchGlobal
+type KillSwitchModel struct {
+	Scope     string    `gorm:"primaryKey;type:varchar(20)" json:"scope"`
+	Reason    string    `json:"reason"`
+	EngagedBy string    `gorm:"type:varchar(10)" json:"engaged_by"`
+	EngagedAt time.Time `json:"engaged_at"`
+}
+
+// TableName specifies the table name for the KillSwitch model
+func (KillSwitchModel) TableName() string {
+	return KillSwitchesTableName
+}
+
+// KillSwitchEventModel is an engage or release of a kill switch
+type KillSwitchEventModel struct {
+	ID        uint64    `gorm:"primaryKey" json:"id"`
+	Scope     string    `gorm:"index;type:varchar(20)" json:"scope"`
+	Action    string    `gorm:"type:varchar(10)" json:"action"`
+	Reason    string    `json:"reason"`
+	Actor     string    `gorm:"type:varchar(10)" json:"actor"`
+	Stopped   int       `json:"stopped"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName specifies the table name for the KillSwitchEvent model
+func (KillSwitchEventModel) TableName() string {
+	return KillSwitchEventsTableName
+}
+
+// KillSwitchStatus is the engaged kill switches with the latest audit events
+type KillSwitchStatus struct {
+	Engaged []KillSwitchModel      `json:"engaged"`
+	Events  []KillSwitchEventModel `json:"events"`
+}
@@ -0,0 +1,38 @@
+// Package models contains the models for the Moneybots API
+package models
+
+// Data lineage sources, the provenance of the data of a response
+const (
+	// LineageLiveTick is data recorded from the live ticks
+	LineageLiveTick = "live_tick"
+	// LineageBackfilled is data fetched after the fact from the kite historical API
+	LineageBackfilled = "backfilled"
+	// LineageBhavcopy is data from the official exchange bhavcopy
+	LineageBhavcopy = "bhavcopy"
+)
+
+// DataLineage is the provenance of the data of a response, returned when requested with meta=true so downstream
+// systems can weigh the data. Source is the least trusted source the data was built from and SourceTimestamp
+// the time of the newest data. Cached is set when the response was served from an in-memory cache
+type DataLineage struct {
+	Source          string `json:"source"`
+	SourceTimestamp string `json:"source_timestamp,omitempty"`
+	Cached          bool   `json:"cached"`
+	PrevCloseSource string `json:"prev_close_source,omitempty"`
+}
+
+// CandleLineageSource returns the lineage source of a candle source
+func CandleLineageSource(candleSource string) string {
+	if candleSource == CandleSourceBackfill {
+		return LineageBackfilled
+	}
+	return LineageLiveTick
+}
+
+// PrevCloseLineageSource returns the lineage source of a previous close source
+func PrevCloseLineageSource(prevCloseSource string) string {
+	if prevCloseSource == PrevCloseSourceBhavcopy {
+		return LineageBhavcopy
+	}
+	return LineageLiveTick
+}
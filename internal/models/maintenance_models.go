@@ -0,0 +1,38 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// MaintenanceTableResult is the outcome of the maintenance of one table
+type MaintenanceTableResult struct {
+	Table          string `json:"table"`
+	Pruned         int64  `json:"pruned"`
+	Archived       int64  `json:"archived"`
+	Vacuumed       bool   `json:"vacuumed"`
+	Reindexed      bool   `json:"reindexed"`
+	SizeBefore     int64  `json:"size_before"`
+	SizeAfter      int64  `json:"size_after"`
+	BytesReclaimed int64  `json:"bytes_reclaimed"`
+	Error          string `json:"error,omitempty"`
+}
+
+// MaintenanceResult is the outcome of a database maintenance run
+type MaintenanceResult struct {
+	Tables         []MaintenanceTableResult `json:"tables"`
+	Pruned         int64                    `json:"pruned"`
+	BytesReclaimed int64                    `json:"bytes_reclaimed"`
+	DurationMs     int64                    `json:"duration_ms"`
+}
+
+// DataTrimResult is the outcome of trimming the rows of a table before a date, a dry run only counts
+// the rows and returns the token confirming the deletion of exactly that many rows
+type DataTrimResult struct {
+	Table        string     `json:"table"`
+	Column       string     `json:"column"`
+	Before       string     `json:"before"`
+	DryRun       bool       `json:"dry_run"`
+	Rows         int64      `json:"rows"`
+	Deleted      int64      `json:"deleted"`
+	ConfirmToken string     `json:"confirm_token,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,60 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// NotificationPreferencesTableName is the name of the table for the notification preferences
+const NotificationPreferencesTableName = "notification_preferences"
+
+// Notification categories a user can subscribe to
+const (
+	NotificationCategoryAlerts        = "alerts"
+	NotificationCategoryJobFailures   = "job_failures"
+	NotificationCategorySessionExpiry = "session_expiry"
+	NotificationCategoryReports       = "reports"
+)
+
+// Notification channels
+const (
+	NotificationChannelTelegram = "telegram"
+	NotificationChannelWebhook  = "webhook"
+	NotificationChannelEmail    = "email"
+)
+
+// NotificationPreferencesModel is the channels and categories a user receives notifications for,
+// a blank channel address disables the channel
+type NotificationPreferencesModel struct {
+	UserID         string         `gorm:"primaryKey;type:varchar(10)" json:"user_id"`
+	TelegramChatID string         `gorm:"type:varchar(64)" json:"telegram_chat_id"`
+	WebhookURL     string         `json:"webhook_url"`
+	Email          string         `json:"email"`
+	Categories     datatypes.JSON `gorm:"type:jsonb" json:"categories"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for the NotificationPreferences model
+func (NotificationPreferencesModel) TableName() string {
+	return NotificationPreferencesTableName
+}
+
+// NotificationPreferences is the schema of the notification preferences document
+type NotificationPreferences struct {
+	TelegramChatID string   `json:"telegram_chat_id"`
+	WebhookURL     string   `json:"webhook_url"`
+	Email          string   `json:"email"`
+	Categories     []string `json:"categories"`
+}
+
+// Notification is an outbound message to a user
+type Notification struct {
+	UserID    string    `json:"user_id"`
+	Category  string    `json:"category"`
+	Subject   string    `json:"subject"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,33 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// PrevClosesTableName is the name of the table for previous closes
+const PrevClosesTableName = "prev_closes"
+
+// Previous close sources
+const (
+	PrevCloseSourceTick     = "tick"
+	PrevCloseSourceBhavcopy = "bhavcopy"
+)
+
+// PrevCloseModel is the official previous close of an instrument for a trading date
+type PrevCloseModel struct {
+	Instrument      string          `gorm:"primaryKey" json:"instrument"`
+	Date            string          `gorm:"primaryKey;type:varchar(10)" json:"date"`
+	InstrumentToken uint32          `gorm:"index" json:"instrument_token"`
+	InstrumentID    uint64          `gorm:"index" json:"instrument_id"`
+	PrevClose       decimal.Decimal `gorm:"type:decimal(14,4)" json:"previous_close"`
+	Source          string          `gorm:"type:varchar(10)" json:"source"`
+	UpdatedAt       time.Time       `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name for the PrevClose model
+func (PrevCloseModel) TableName() string {
+	return PrevClosesTableName
+}
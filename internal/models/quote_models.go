@@ -1,18 +1,22 @@
 // Package models contains the models for the Moneybots API
 package models
 
+import "github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+
 // QuoteResponse is the response for the quote API
 type QuoteResponse struct {
 	Status string                 `json:"status"`
 	Data   map[string]interface{} `json:"data"`
+	// Meta is the lineage of the data of each instrument, returned when requested
+	Meta map[string]DataLineage `json:"meta,omitempty"`
 }
 
 // OHLC is the OHLC data for a given instrument
 type OHLC struct {
-	Open  float64 `json:"open"`
-	High  float64 `json:"high"`
-	Low   float64 `json:"low"`
-	Close float64 `json:"close"`
+	Open  decimal.Decimal `json:"open"`
+	High  decimal.Decimal `json:"high"`
+	Low   decimal.Decimal `json:"low"`
+	Close decimal.Decimal `json:"close"`
 }
 
 // Depth is the depth data for a given instrument
@@ -23,53 +27,115 @@ type Depth struct {
 
 // DepthItem is the depth item for a given instrument
 type DepthItem struct {
-	Price    float64 `json:"price"`
-	Quantity uint32  `json:"quantity"`
-	Orders   uint32  `json:"orders"`
+	Price    decimal.Decimal `json:"price"`
+	Quantity uint32          `json:"quantity"`
+	Orders   uint32          `json:"orders"`
 }
 
 // QuoteData is the quote data for a given instrument
 type QuoteData struct {
-	Instrument         string  `json:"instrument"`
-	Mode               string  `json:"mode"`
-	InstrumentToken    uint32  `json:"instrument_token"`
-	IsTradable         bool    `json:"is_tradable"`
-	IsIndex            bool    `json:"is_index"`
-	Timestamp          string  `json:"timestamp"`
-	LastTradeTime      string  `json:"last_trade_time"`
-	LastPrice          float64 `json:"last_price"`
-	LastTradedQuantity uint32  `json:"last_traded_quantity"`
-	TotalBuyQuantity   uint32  `json:"total_buy_quantity"`
-	TotalSellQuantity  uint32  `json:"total_sell_quantity"`
-	VolumeTraded       uint32  `json:"volume"`
+	Instrument         string          `json:"instrument"`
+	Mode               string          `json:"mode"`
+	InstrumentToken    uint32          `json:"instrument_token"`
+	IsTradable         bool            `json:"is_tradable"`
+	IsIndex            bool            `json:"is_index"`
+	Timestamp          string          `json:"timestamp"`
+	LastTradeTime      string          `json:"last_trade_time"`
+	LastPrice          decimal.Decimal `json:"last_price"`
+	LastTradedQuantity uint32          `json:"last_traded_quantity"`
+	TotalBuyQuantity   uint32          `json:"total_buy_quantity"`
+	TotalSellQuantity  uint32          `json:"total_sell_quantity"`
+	VolumeTraded       uint32          `json:"volume"`
 	// TotalBuy           uint32  `json:"total_buy"`
 	// TotalSell          uint32  `json:"total_sell"`
-	AverageTradePrice float64 `json:"average_price"`
-	OI                uint32  `json:"oi"`
-	OIDayHigh         uint32  `json:"oi_day_high"`
-	OIDayLow          uint32  `json:"oi_day_low"`
-	NetChange         float64 `json:"net_change"`
-	OHLC              OHLC    `json:"ohlc"`
-	Depth             Depth   `json:"depth"`
-	UpdatedAt         string  `json:"-"`
+	AverageTradePrice decimal.Decimal `json:"average_price"`
+	OI                uint32          `json:"oi"`
+	OIDayHigh         uint32          `json:"oi_day_high"`
+	OIDayLow          uint32          `json:"oi_day_low"`
+	NetChange         decimal.Decimal `json:"net_change"`
+	PreviousClose     decimal.Decimal `json:"previous_close"`
+	ChangePercent     decimal.Decimal `json:"change_percent"`
+	OHLC              OHLC            `json:"ohlc"`
+	Depth             Depth           `json:"depth"`
+	LowerCircuitLimit decimal.Decimal `json:"lower_circuit_limit"`
+	UpperCircuitLimit decimal.Decimal `json:"upper_circuit_limit"`
+	AsOf              string          `json:"as_of"`
+	IsStale           bool            `json:"is_stale"`
+	UpdatedAt         string          `json:"-"`
 }
 
 // OHLCData is the OHLC data for a given instrument
 type OHLCData struct {
-	InstrumentToken   uint32  `json:"-"`
-	LastPrice         float64 `json:"last_price"`
-	VolumeTraded      uint32  `json:"volume"`
-	AverageTradePrice float64 `json:"average_price"`
-	Timestamp         string  `json:"timestamp"`
-	LastTradeTime     string  `json:"last_trade_time"`
-	OHLC              OHLC    `json:"ohlc"`
-	UpdatedAt         string  `json:"-"`
+	InstrumentToken   uint32          `json:"-"`
+	LastPrice         decimal.Decimal `json:"last_price"`
+	VolumeTraded      uint32          `json:"volume"`
+	AverageTradePrice decimal.Decimal `json:"average_price"`
+	PreviousClose     decimal.Decimal `json:"previous_close"`
+	ChangePercent     decimal.Decimal `json:"change_percent"`
+	Timestamp         string          `json:"timestamp"`
+	LastTradeTime     string          `json:"last_trade_time"`
+	OHLC              OHLC            `json:"ohlc"`
+	AsOf              string          `json:"as_of"`
+	IsStale           bool            `json:"is_stale"`
+	UpdatedAt         string          `json:"-"`
+}
+
+// DepthData is the market depth of an instrument, the 5 best bids and offers. Levels is 1 when the ticker data
+// only stores the best bid and offer
+type DepthData struct {
+	InstrumentToken   uint32          `json:"instrument_token"`
+	LastPrice         decimal.Decimal `json:"last_price"`
+	TotalBuyQuantity  uint32          `json:"total_buy_quantity"`
+	TotalSellQuantity uint32          `json:"total_sell_quantity"`
+	Levels            int             `json:"levels"`
+	Depth             TickerDataDepth `json:"depth"`
+	Timestamp         string          `json:"timestamp"`
+	AsOf              string          `json:"as_of"`
+	IsStale           bool            `json:"is_stale"`
+	UpdatedAt         string          `json:"-"`
 }
 
 // LTPData is the LTP data for a given instrument
 type LTPData struct {
-	InstrumentToken uint32  `json:"-"`
-	LastPrice       float64 `json:"last_price"`
-	Timestamp       string  `json:"timestamp"`
-	UpdatedAt       string  `json:"-"`
+	InstrumentToken uint32          `json:"-"`
+	LastPrice       decimal.Decimal `json:"last_price"`
+	PreviousClose   decimal.Decimal `json:"previous_close"`
+	ChangePercent   decimal.Decimal `json:"change_percent"`
+	Timestamp       string          `json:"timestamp"`
+	AsOf            string          `json:"as_of"`
+	IsStale         bool            `json:"is_stale"`
+	UpdatedAt       string          `json:"-"`
+}
+
+// DelayedLTPData is the delayed last price of an instrument, the close of the last 1 minute candle completed
+// the delay ago. The timestamp is the end of that candle
+type DelayedLTPData struct {
+	InstrumentToken uint32          `json:"-"`
+	LastPrice       decimal.Decimal `json:"last_price"`
+	PreviousClose   decimal.Decimal `json:"previous_close"`
+	ChangePercent   decimal.Decimal `json:"change_percent"`
+	Timestamp       string          `json:"timestamp"`
+	DelayMinutes    int             `json:"delay_minutes"`
+}
+
+// DelayedQuoteData is the delayed quote of an instrument, the day's OHLC and volume aggregated from the
+// 1 minute candles completed the delay ago. The timestamp is the end of the last of them
+type DelayedQuoteData struct {
+	InstrumentToken uint32          `json:"instrument_token"`
+	LastPrice       decimal.Decimal `json:"last_price"`
+	PreviousClose   decimal.Decimal `json:"previous_close"`
+	ChangePercent   decimal.Decimal `json:"change_percent"`
+	OHLC            OHLC            `json:"ohlc"`
+	Volume          int64           `json:"volume"`
+	OI              int64           `json:"oi"`
+	Timestamp       string          `json:"timestamp"`
+	DelayMinutes    int             `json:"delay_minutes"`
+}
+
+// PrevCloseData is the previous close data for a given instrument
+type PrevCloseData struct {
+	InstrumentToken uint32          `json:"instrument_token"`
+	PreviousClose   decimal.Decimal `json:"previous_close"`
+	Date            string          `json:"date"`
+	Source          string          `json:"source"`
 }
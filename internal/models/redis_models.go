@@ -0,0 +1,31 @@
+// Package models contains the models for the Moneybots API
+package models
+
+// RedisKeyPolicy is the lifecycle of a redis key or channel written by the API, a TTL of 0 never expires
+type RedisKeyPolicy struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	TTLSeconds  int64  `json:"ttl_seconds"`
+	Description string `json:"description"`
+}
+
+// RedisKeyInfo is a key found in redis, a TTL of -1 is a key without expiry
+type RedisKeyInfo struct {
+	Key        string `json:"key"`
+	Type       string `json:"type"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+	Policy     string `json:"policy,omitempty"`
+	MissingTTL bool   `json:"missing_ttl,omitempty"`
+}
+
+// RedisKeysReport is the report of the redis keys under the key prefix against the key policies
+type RedisKeysReport struct {
+	Prefix     string           `json:"prefix"`
+	Policies   []RedisKeyPolicy `json:"policies"`
+	Keys       []RedisKeyInfo   `json:"keys"`
+	Unmanaged  int              `json:"unmanaged"`
+	MissingTTL int              `json:"missing_ttl"`
+	Expired    int              `json:"expired"`
+	Truncated  bool             `json:"truncated"`
+}
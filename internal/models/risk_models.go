@@ -0,0 +1,54 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// RiskLimitsTableName is the name of the table for the per user order and loss limits
+const RiskLimitsTableName = "risk_limits"
+
+// RiskUsageTableName is the name of the table for the daily usage of the limits by instrument
+const RiskUsageTableName = "risk_usage"
+
+// RiskLimitsModel is the order and loss limits of a user, a zero limit is unlimited
+type RiskLimitsModel struct {
+	UserID                   string          `gorm:"primaryKey;type:varchar(10)" json:"-"`
+	MaxOrdersPerDay          int             `json:"max_orders_per_day"`
+	MaxQuantityPerInstrument int64           `json:"max_quantity_per_instrument"`
+	MaxDailyLoss             decimal.Decimal `gorm:"type:decimal(14,4)" json:"max_daily_loss"`
+	UpdatedAt                time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for the RiskLimits model
+func (RiskLimitsModel) TableName() string {
+	return RiskLimitsTableName
+}
+
+// RiskUsageModel is the orders, the quantity and the realised P&L of a user in an instrument on a day
+type RiskUsageModel struct {
+	UserID     string          `gorm:"primaryKey;type:varchar(10)" json:"-"`
+	Date       string          `gorm:"primaryKey;type:varchar(10)" json:"-"`
+	Instrument string          `gorm:"primaryKey" json:"instrument"`
+	Orders     int             `json:"orders"`
+	Quantity   int64           `json:"quantity"`
+	PnL        decimal.Decimal `gorm:"column:pnl;type:decimal(14,4)" json:"pnl"`
+	UpdatedAt  time.Time       `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name for the RiskUsage model
+func (RiskUsageModel) TableName() string {
+	return RiskUsageTableName
+}
+
+// RiskState is the limits of a user with the usage of the day and the limits currently breached
+type RiskState struct {
+	Date        string           `json:"date"`
+	Limits      RiskLimitsModel  `json:"limits"`
+	Orders      int              `json:"orders"`
+	PnL         decimal.Decimal  `json:"pnl"`
+	Instruments []RiskUsageModel `json:"instruments"`
+	Breaches    []string         `json:"breaches"`
+}
@@ -0,0 +1,24 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// SelfTestCheck is the outcome of a request of the self test to an endpoint of the running instance
+type SelfTestCheck struct {
+	Name       string `json:"name"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Passed     bool   `json:"passed"`
+	Status     int    `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SelfTestReport is the outcome of a self test run, it passed when every check passed
+type SelfTestReport struct {
+	UserId     string          `json:"user_id"`
+	Passed     bool            `json:"passed"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Checks     []SelfTestCheck `json:"checks"`
+}
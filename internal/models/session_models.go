@@ -24,3 +24,31 @@ type SessionModel struct {
 func (SessionModel) TableName() string {
 	return SessionsTableName
 }
+
+const SessionChecksTableName = "session_checks"
+
+// SessionCheckModel is a change in the validity of a stored session seen by the keep-alive pinger,
+// only the transitions are recorded
+type SessionCheckModel struct {
+	ID        uint64    `gorm:"primaryKey" json:"-"`
+	UserId    string    `gorm:"index;type:varchar(10)" json:"user_id"`
+	Valid     bool      `json:"valid"`
+	LoginTime string    `json:"login_time"`
+	CheckedAt time.Time `gorm:"index" json:"checked_at"`
+}
+
+func (SessionCheckModel) TableName() string {
+	return SessionChecksTableName
+}
+
+// SessionInfo is the validity of a session and the estimated time kite expires it. Valid is nil when kite
+// could not be asked, CheckError then holds the reason
+type SessionInfo struct {
+	UserId           string    `json:"user_id"`
+	LoginTime        string    `json:"login_time"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	ExpiresInSeconds int64     `json:"expires_in_seconds"`
+	Valid            *bool     `json:"valid"`
+	CheckError       string    `json:"check_error,omitempty"`
+	CheckedAt        time.Time `json:"checked_at"`
+}
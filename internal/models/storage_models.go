@@ -0,0 +1,53 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// StorageSnapshotsTableName is the name of the table for the daily table size snapshots
+const StorageSnapshotsTableName = "storage_snapshots"
+
+// StorageSnapshotModel is the size of a table on a day
+type StorageSnapshotModel struct {
+	Date       string    `gorm:"primaryKey;type:varchar(10)" json:"date"`
+	Table      string    `gorm:"primaryKey;column:table_name;type:varchar(64)" json:"table"`
+	TableBytes int64     `json:"table_bytes"`
+	IndexBytes int64     `json:"index_bytes"`
+	TotalBytes int64     `json:"total_bytes"`
+	Rows       int64     `json:"rows"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name for the StorageSnapshot model
+func (StorageSnapshotModel) TableName() string {
+	return StorageSnapshotsTableName
+}
+
+// StorageTable is the size and growth of a table, the row count is the planner estimate
+type StorageTable struct {
+	Table            string  `json:"table"`
+	TableBytes       int64   `json:"table_bytes"`
+	IndexBytes       int64   `json:"index_bytes"`
+	TotalBytes       int64   `json:"total_bytes"`
+	Rows             int64   `json:"rows"`
+	DailyGrowthBytes float64 `json:"daily_growth_bytes"`
+}
+
+// StorageThreshold is a disk usage threshold and the projected days until it is reached,
+// DaysUntil is omitted when the database is not growing
+type StorageThreshold struct {
+	Percent   int      `json:"percent"`
+	Bytes     int64    `json:"bytes"`
+	Reached   bool     `json:"reached"`
+	DaysUntil *float64 `json:"days_until,omitempty"`
+}
+
+// StorageReport is the storage usage, growth and projection of the database
+type StorageReport struct {
+	DatabaseBytes    int64              `json:"database_bytes"`
+	CapacityBytes    int64              `json:"capacity_bytes"`
+	DailyGrowthBytes float64            `json:"daily_growth_bytes"`
+	GrowthWindowDays float64            `json:"growth_window_days"`
+	Tables           []StorageTable     `json:"tables"`
+	Thresholds       []StorageThreshold `json:"thresholds"`
+}
@@ -0,0 +1,35 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import "time"
+
+// StreamUsageTableName is the name of the table for the daily stream usage
+const StreamUsageTableName = "stream_usage"
+
+// StreamUsageModel is the stream usage of a user for a day
+type StreamUsageModel struct {
+	UserID    string    `gorm:"primaryKey;type:varchar(10)" json:"user_id"`
+	Date      string    `gorm:"primaryKey;type:varchar(10)" json:"date"`
+	Seconds   int64     `json:"seconds"`
+	Messages  int64     `json:"messages"`
+	Bytes     int64     `json:"bytes"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for the StreamUsage model
+func (StreamUsageModel) TableName() string {
+	return StreamUsageTableName
+}
+
+// StreamQuota is the daily stream quota of a user, 0 is unlimited
+type StreamQuota struct {
+	Minutes  int64 `json:"minutes"`
+	Messages int64 `json:"messages"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// StreamUsageData is the stream usage of a user for a day along with the quota
+type StreamUsageData struct {
+	Usage StreamUsageModel `json:"usage"`
+	Quota StreamQuota      `json:"quota"`
+}
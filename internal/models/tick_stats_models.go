@@ -0,0 +1,39 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// TickStatsTableName is the name of the table for the daily per instrument tick statistics
+const TickStatsTableName = "tick_stats"
+
+// TickStatsModel is the tick statistics of an instrument for a day
+type TickStatsModel struct {
+	Date            string          `gorm:"primaryKey;type:varchar(10)" json:"date"`
+	InstrumentToken uint32          `gorm:"primaryKey" json:"instrument_token"`
+	Instrument      string          `gorm:"index" json:"instrument"`
+	Ticks           int64           `json:"ticks"`
+	FirstTickAt     time.Time       `json:"first_tick_at"`
+	LastTickAt      time.Time       `json:"last_tick_at"`
+	MaxSpread       decimal.Decimal `gorm:"type:decimal(14,4)" json:"max_spread"`
+	// OutOfSessionTicks are the ticks timestamped outside the session of the exchange
+	OutOfSessionTicks int64     `json:"out_of_session_ticks"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for the TickStats model
+func (TickStatsModel) TableName() string {
+	return TickStatsTableName
+}
+
+// TickStats is the tick statistics of an instrument along with its recent update rate
+type TickStats struct {
+	TickStatsModel
+	Subscribed           bool    `json:"subscribed"`
+	LastMinuteTicks      int64   `json:"last_minute_ticks"`
+	UpdatesPerMinute     float64 `json:"updates_per_minute"`
+	SecondsSinceLastTick float64 `json:"seconds_since_last_tick"`
+}
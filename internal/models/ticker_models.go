@@ -7,6 +7,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
 	"gorm.io/datatypes"
 )
 
@@ -16,52 +17,94 @@ const (
 	TickerLogTableName         = "_ticker_logs"
 )
 
+// Ticker data storage formats of the OHLC and depth, as JSONB blobs, as typed columns or as both
+const (
+	TickerDataStorageJSONB   = "jsonb"
+	TickerDataStorageColumns = "columns"
+	TickerDataStorageBoth    = "both"
+)
+
 // TICKER INSTRUMENTS -------------------------------------------------
 // TickerInstrument represents the instruments for which tick data is subscribed
 type TickerInstrument struct {
-	UserID          string    `gorm:"uniqueIndex:idx_userId_instrument,priority:1;type:varchar(10)" json:"user_id"`
-	Instrument      string    `gorm:"uniqueIndex:idx_userId_instrument,priority:2" json:"instrument"`
-	InstrumentToken uint32    `json:"instrument_token"`
-	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	UserID          string         `gorm:"uniqueIndex:idx_userId_instrument,priority:1;type:varchar(10)" json:"user_id"`
+	Instrument      string         `gorm:"uniqueIndex:idx_userId_instrument,priority:2" json:"instrument"`
+	InstrumentToken uint32         `json:"instrument_token"`
+	InstrumentID    uint64         `gorm:"index" json:"instrument_id"`
+	Pinned          bool           `gorm:"not null;default:false" json:"pinned"`
+	Priority        bool           `gorm:"not null;default:false" json:"priority"`
+	Mode            string         `gorm:"type:varchar(5);not null;default:''" json:"mode,omitempty"`
+	Metadata        datatypes.JSON `gorm:"type:jsonb" json:"metadata,omitempty"`
+	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (TickerInstrument) TableName() string {
 	return TickerInstrumentsTableName
 }
 
+// TickerInstrumentMismatch is a ticker instrument whose token no longer matches the instruments table,
+// InstrumentToken is 0 when the instrument no longer exists
+type TickerInstrumentMismatch struct {
+	UserID          string `json:"user_id"`
+	Instrument      string `json:"instrument"`
+	TickerToken     uint32 `json:"ticker_token"`
+	InstrumentToken uint32 `json:"instrument_token"`
+	Pinned          bool   `json:"pinned"`
+}
+
+// TickerInstrumentsReconcileResult is the outcome of reconciling ticker instruments with instruments
+type TickerInstrumentsReconcileResult struct {
+	DryRun         bool                       `json:"dry_run"`
+	Checked        int64                      `json:"checked"`
+	TokensUpdated  []TickerInstrumentMismatch `json:"tokens_updated"`
+	OrphansRemoved []TickerInstrumentMismatch `json:"orphans_removed"`
+	PinnedOrphans  []TickerInstrumentMismatch `json:"pinned_orphans"`
+}
+
 // TICKER DATA --------------------------------------------------------
 // TickerData represents the tick data for an instrument
 type TickerData struct {
-	Instrument         string         `gorm:"index" json:"instrument"`
-	InstrumentToken    uint32         `gorm:"primaryKey"  json:"instrument_token"`
-	Mode               string         `gorm:"type:varchar(10)" json:"mode"`
-	IsTradable         bool           `json:"is_tradable"`
-	IsIndex            bool           `json:"is_index"`
-	Timestamp          time.Time      `json:"timestamp"`
-	LastTradeTime      time.Time      `json:"last_trade_time"`
-	LastPrice          float64        `gorm:"type:decimal(10,2);column:last_price" json:"last_price"`
-	LastTradedQuantity uint32         `gorm:"type:bigint;column:last_traded_quantity" json:"last_traded_quantity"`
-	TotalBuyQuantity   uint32         `gorm:"type:bigint;column:total_buy_quantity" json:"total_buy_quantity"`
-	TotalSellQuantity  uint32         `gorm:"type:bigint;column:total_sell_quantity" json:"total_sell_quantity"`
-	VolumeTraded       uint32         `gorm:"type:bigint;column:volume" json:"volume"`
-	AverageTradePrice  float64        `gorm:"type:decimal(10,2);column:average_price" json:"average_price"`
-	OI                 uint32         `gorm:"type:bigint;column:oi" json:"oi"`
-	OIDayHigh          uint32         `gorm:"type:bigint;column:oi_day_high" json:"oi_day_high"`
-	OIDayLow           uint32         `gorm:"type:bigint;column:oi_day_low" json:"oi_day_low"`
-	NetChange          float64        `gorm:"type:decimal(10,2)" json:"net_change"`
-	OHLC               datatypes.JSON `gorm:"type:jsonb;column:ohlc" json:"ohlc"`
-	Depth              datatypes.JSON `gorm:"type:jsonb;column:depth" json:"depth"`
-	UpdatedAt          time.Time      `gorm:"autoUpdateTime:nano"  json:"updated_at"`
+	Instrument         string          `gorm:"index" json:"instrument"`
+	InstrumentToken    uint32          `gorm:"primaryKey"  json:"instrument_token"`
+	InstrumentID       uint64          `gorm:"index" json:"instrument_id"`
+	Mode               string          `gorm:"type:varchar(10)" json:"mode"`
+	IsTradable         bool            `json:"is_tradable"`
+	IsIndex            bool            `json:"is_index"`
+	Timestamp          time.Time       `json:"timestamp"`
+	LastTradeTime      time.Time       `json:"last_trade_time"`
+	LastPrice          decimal.Decimal `gorm:"type:decimal(14,4);column:last_price" json:"last_price"`
+	LastTradedQuantity uint32          `gorm:"type:bigint;column:last_traded_quantity" json:"last_traded_quantity"`
+	TotalBuyQuantity   uint32          `gorm:"type:bigint;column:total_buy_quantity" json:"total_buy_quantity"`
+	TotalSellQuantity  uint32          `gorm:"type:bigint;column:total_sell_quantity" json:"total_sell_quantity"`
+	VolumeTraded       uint32          `gorm:"type:bigint;column:volume" json:"volume"`
+	AverageTradePrice  decimal.Decimal `gorm:"type:decimal(14,4);column:average_price" json:"average_price"`
+	OI                 uint32          `gorm:"type:bigint;column:oi" json:"oi"`
+	OIDayHigh          uint32          `gorm:"type:bigint;column:oi_day_high" json:"oi_day_high"`
+	OIDayLow           uint32          `gorm:"type:bigint;column:oi_day_low" json:"oi_day_low"`
+	NetChange          decimal.Decimal `gorm:"type:decimal(14,4)" json:"net_change"`
+	OHLC               datatypes.JSON  `gorm:"type:jsonb;column:ohlc" json:"ohlc"`
+	Depth              datatypes.JSON  `gorm:"type:jsonb;column:depth" json:"depth"`
+	Open               decimal.Decimal `gorm:"type:decimal(14,4);column:open" json:"open"`
+	High               decimal.Decimal `gorm:"type:decimal(14,4);column:high" json:"high"`
+	Low                decimal.Decimal `gorm:"type:decimal(14,4);column:low" json:"low"`
+	Close              decimal.Decimal `gorm:"type:decimal(14,4);column:close" json:"close"`
+	BidPrice           decimal.Decimal `gorm:"type:decimal(14,4);column:bid_price" json:"bid_price"`
+	BidQuantity        uint32          `gorm:"type:bigint;column:bid_quantity" json:"bid_quantity"`
+	AskPrice           decimal.Decimal `gorm:"type:decimal(14,4);column:ask_price" json:"ask_price"`
+	AskQuantity        uint32          `gorm:"type:bigint;column:ask_quantity" json:"ask_quantity"`
+	// OutOfSession tags a tick timestamped outside the session of the exchange
+	OutOfSession bool      `json:"out_of_session"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime:nano"  json:"updated_at"`
 	// TotalBuy           uint32         `gorm:"type:bigint" json:"total_buy"`
 	// TotalSell          uint32         `gorm:"type:bigint" json:"total_sell"`
 }
 
 type TickerDataOHLC struct {
-	Open  float64 `json:"open"`
-	High  float64 `json:"high"`
-	Low   float64 `json:"low"`
-	Close float64 `json:"close"`
+	Open  decimal.Decimal `json:"open"`
+	High  decimal.Decimal `json:"high"`
+	Low   decimal.Decimal `json:"low"`
+	Close decimal.Decimal `json:"close"`
 }
 
 type TickerDataDepth struct {
@@ -70,23 +113,44 @@ type TickerDataDepth struct {
 }
 
 type TickerDataDepthItem struct {
-	Price    float64 `json:"price"`
-	Quantity uint32  `json:"quantity"`
-	Orders   uint32  `json:"orders"`
+	Price    decimal.Decimal `json:"price"`
+	Quantity uint32          `json:"quantity"`
+	Orders   uint32          `json:"orders"`
 }
 
+// GetOHLC returns the OHLC of the JSONB blob, or of the typed columns when the blob is not stored
 func (t *TickerData) GetOHLC() (TickerDataOHLC, error) {
 	var ohlc TickerDataOHLC
+	if !hasJSON(t.OHLC) {
+		ohlc = TickerDataOHLC{Open: t.Open, High: t.High, Low: t.Low, Close: t.Close}
+		return ohlc, nil
+	}
 	err := json.Unmarshal(t.OHLC, &ohlc)
 	return ohlc, err
 }
 
+// HasFullDepth returns true if the 5 level depth is stored, otherwise only the best bid and ask are
+func (t *TickerData) HasFullDepth() bool {
+	return hasJSON(t.Depth)
+}
+
+// GetDepth returns the depth of the JSONB blob, or the best bid and ask of the typed columns when the blob is not stored
 func (t *TickerData) GetDepth() (TickerDataDepth, error) {
 	var depth TickerDataDepth
+	if !hasJSON(t.Depth) {
+		depth.Buy[0] = TickerDataDepthItem{Price: t.BidPrice, Quantity: t.BidQuantity}
+		depth.Sell[0] = TickerDataDepthItem{Price: t.AskPrice, Quantity: t.AskQuantity}
+		return depth, nil
+	}
 	err := json.Unmarshal(t.Depth, &depth)
 	return depth, err
 }
 
+// hasJSON returns true if the JSONB column holds a value
+func hasJSON(data datatypes.JSON) bool {
+	return len(data) > 0 && string(data) != "null"
+}
+
 func (TickerData) TableName() string {
 	return TickerDataTableName
 }
@@ -116,12 +180,26 @@ const (
 	FATAL LogLevel = "FATAL"
 )
 
+// TickerLog is an event of the ticker, the level and event type indexes serve the filtered queries of the
+// admin API and the severity based retention
 type TickerLog struct {
-	ID        uint32     `gorm:"primaryKey"`
-	Timestamp *time.Time `gorm:"index"`
-	Level     *LogLevel
-	EventType *string
-	Message   *string
+	ID        uint32     `gorm:"primaryKey" json:"id"`
+	Timestamp *time.Time `gorm:"index;index:idx_ticker_logs_level_timestamp,priority:2;index:idx_ticker_logs_event_type_timestamp,priority:2" json:"timestamp"`
+	Level     *LogLevel  `gorm:"index:idx_ticker_logs_level_timestamp,priority:1" json:"level"`
+	EventType *string    `gorm:"index:idx_ticker_logs_event_type_timestamp,priority:1" json:"event_type"`
+	Message   *string    `json:"message"`
+}
+
+// TickerLogFilter filters the ticker logs, blank or zero fields do not filter. Logs are returned newest first,
+// before the id when set
+type TickerLogFilter struct {
+	Levels    []LogLevel
+	EventType string
+	Message   string
+	From      time.Time
+	To        time.Time
+	BeforeID  uint32
+	Limit     int
 }
 
 func (TickerLog) TableName() string {
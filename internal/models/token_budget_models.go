@@ -0,0 +1,26 @@
+// Package models contains the models for the Moneybots API
+package models
+
+// Token budget sources, each source subscribes on its own Kite connection
+const (
+	TokenBudgetSourceTicker = "ticker"
+	TokenBudgetSourceStream = "stream"
+)
+
+// TokenBudgetSource is the subscriptions of one Kite connection
+type TokenBudgetSource struct {
+	Source   string `json:"source"`
+	Tokens   int    `json:"tokens"`
+	Headroom int    `json:"headroom"`
+}
+
+// TokenBudgetStatus is the subscriptions across the Kite connections,
+// overlapping tokens are subscribed on more than one connection
+type TokenBudgetStatus struct {
+	LimitPerConnection int                 `json:"limit_per_connection"`
+	Sources            []TokenBudgetSource `json:"sources"`
+	Subscriptions      int                 `json:"subscriptions"`
+	UniqueTokens       int                 `json:"unique_tokens"`
+	OverlappingTokens  int                 `json:"overlapping_tokens"`
+	Headroom           int                 `json:"headroom"`
+}
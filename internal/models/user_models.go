@@ -0,0 +1,40 @@
+// Package models contains the models for the Moneybots API
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const UserSettingsTableName = "user_settings"
+
+// Number formats of the reports and notifications
+const (
+	NumberFormatPlain         = "plain"         // 1234567.5
+	NumberFormatIndian        = "indian"        // 12,34,567.5
+	NumberFormatInternational = "international" // 1,234,567.5
+)
+
+// UserSettingsModel holds the client preferences of a user as a JSON document
+type UserSettingsModel struct {
+	UserID    string         `gorm:"primaryKey;type:varchar(10)" json:"user_id"`
+	Settings  datatypes.JSON `gorm:"type:jsonb" json:"settings"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (UserSettingsModel) TableName() string {
+	return UserSettingsTableName
+}
+
+// UserSettings is the schema of the user settings document
+type UserSettings struct {
+	DefaultExchange     string   `json:"default_exchange,omitempty"`
+	StreamModes         []string `json:"stream_modes,omitempty"`
+	FavoriteInstruments []string `json:"favorite_instruments,omitempty"`
+	// Timezone is the IANA time zone the reports and notifications are shown in, the market time zone when blank
+	Timezone string `json:"timezone,omitempty"`
+	// NumberFormat is the number format of the reports and notifications, plain when blank
+	NumberFormat string `json:"number_format,omitempty"`
+}
@@ -0,0 +1,16 @@
+// Package models contains the models for the Moneybots API
+package models
+
+// VersionInfo is the version of the API, its build and the services it is connected to
+type VersionInfo struct {
+	APIName           string `json:"api_name"`
+	APIVersion        string `json:"api_version"`
+	BuildVersion      string `json:"build_version"`
+	Commit            string `json:"commit"`
+	BuildTime         string `json:"build_time"`
+	Modified          bool   `json:"modified"`
+	GoVersion         string `json:"go_version"`
+	PostgresVersion   string `json:"postgres_version"`
+	RedisVersion      string `json:"redis_version"`
+	KitetickerVersion string `json:"kiteticker_version"`
+}
@@ -0,0 +1,41 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// ActivityRepository is the database repository for the user activity events
+type ActivityRepository struct {
+	DB *gorm.DB
+}
+
+// NewActivityRepository creates a new activity repository
+func NewActivityRepository(db *gorm.DB) *ActivityRepository {
+	return &ActivityRepository{DB: db}
+}
+
+// InsertActivityEvent inserts an activity event
+func (r *ActivityRepository) InsertActivityEvent(event *models.ActivityEventModel) error {
+	if err := r.DB.Create(event).Error; err != nil {
+		return fmt.Errorf("failed to insert activity event: %v", err)
+	}
+	return nil
+}
+
+// GetActivityEvents gets the activity events of a user since, optionally for an action, newest first
+func (r *ActivityRepository) GetActivityEvents(userID, action string, since time.Time, limit int) ([]models.ActivityEventModel, error) {
+	var events []models.ActivityEventModel
+	query := r.DB.Where("user_id = ? AND created_at >= ?", userID, since)
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to get activity events: %v", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,55 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AnnouncementRepository is the database repository for the exchange announcements
+type AnnouncementRepository struct {
+	DB *gorm.DB
+}
+
+// NewAnnouncementRepository creates a new announcement repository
+func NewAnnouncementRepository(db *gorm.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{DB: db}
+}
+
+// InsertAnnouncements inserts the announcements and returns the ones which were not stored before
+func (r *AnnouncementRepository) InsertAnnouncements(announcements []models.AnnouncementModel) ([]models.AnnouncementModel, error) {
+	inserted := make([]models.AnnouncementModel, 0)
+	err := withRetry("insert announcements", func() error {
+		inserted = inserted[:0]
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			for _, announcement := range announcements {
+				result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&announcement)
+				if result.Error != nil {
+					return fmt.Errorf("failed to insert announcement: %w", result.Error)
+				}
+				if result.RowsAffected > 0 {
+					inserted = append(inserted, announcement)
+				}
+			}
+			return nil
+		})
+	})
+	return inserted, err
+}
+
+// GetAnnouncements gets the announcements published since, optionally for a symbol, newest first
+func (r *AnnouncementRepository) GetAnnouncements(symbol string, since time.Time, limit int) ([]models.AnnouncementModel, error) {
+	var announcements []models.AnnouncementModel
+	query := r.DB.Where("published_at >= ?", since)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	if err := query.Order("published_at DESC, id DESC").Limit(limit).Find(&announcements).Error; err != nil {
+		return nil, fmt.Errorf("failed to get announcements: %v", err)
+	}
+	return announcements, nil
+}
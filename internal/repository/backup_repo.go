@@ -0,0 +1,83 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// BackupRepository is the database repository for the backups of the critical tables
+type BackupRepository struct {
+	DB *gorm.DB
+}
+
+// NewBackupRepository creates a new backup repository
+func NewBackupRepository(db *gorm.DB) *BackupRepository {
+	return &BackupRepository{DB: db}
+}
+
+// DumpTables reads the rows of the tables as JSON arrays, all tables are read from the same snapshot. The
+// blanked columns of a table are dumped as empty strings
+func (r *BackupRepository) DumpTables(tables []string, blanked map[string][]string) (map[string]json.RawMessage, map[string]int64, error) {
+	rows := make(map[string]json.RawMessage, len(tables))
+	counts := make(map[string]int64, len(tables))
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		for _, table := range tables {
+			var dump struct {
+				Rows  string
+				Count int64
+			}
+			row := "to_jsonb(t)"
+			if columns := blanked[table]; len(columns) > 0 {
+				blanks := make(map[string]string, len(columns))
+				for _, column := range columns {
+					blanks[column] = ""
+				}
+				data, _ := json.Marshal(blanks)
+				row = "to_jsonb(t) || '" + string(data) + "'::jsonb"
+			}
+			err := tx.Table(table + " AS t").
+				Select("COALESCE(json_agg(" + row + "), '[]'::json)::text AS rows, count(*) AS count").
+				Scan(&dump).Error
+			if err != nil {
+				return fmt.Errorf("failed to dump table %s: %v", table, err)
+			}
+			rows[table] = json.RawMessage(dump.Rows)
+			counts[table] = dump.Count
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, counts, nil
+}
+
+// RestoreTables replaces the rows of the tables with the JSON arrays of rows in one transaction, columns
+// missing from the rows are left null and fields without a column are ignored
+func (r *BackupRepository) RestoreTables(tables []string, rows map[string]json.RawMessage) (map[string]int64, error) {
+	var counts map[string]int64
+	err := withRetry("RestoreTables", func() error {
+		counts = make(map[string]int64, len(tables))
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			for _, table := range tables {
+				if err := tx.Exec("DELETE FROM " + table).Error; err != nil {
+					return fmt.Errorf("failed to clear table %s: %v", table, err)
+				}
+				result := tx.Exec("INSERT INTO "+table+" SELECT * FROM json_populate_recordset(NULL::"+table+", ?::json)", string(rows[table]))
+				if result.Error != nil {
+					return fmt.Errorf("failed to restore table %s: %v", table, result.Error)
+				}
+				counts[table] = result.RowsAffected
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
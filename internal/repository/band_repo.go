@@ -0,0 +1,51 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// BandRepository is the database repository for the price bands
+type BandRepository struct {
+	DB *gorm.DB
+}
+
+// NewBandRepository creates a new price band repository
+func NewBandRepository(db *gorm.DB) *BandRepository {
+	return &BandRepository{DB: db}
+}
+
+// ReplacePriceBands replaces all price bands in a single transaction
+func (r *BandRepository) ReplacePriceBands(bands []models.PriceBandModel) (int64, error) {
+	var totalInserted int64
+	err := withRetry("replace price bands", func() error {
+		totalInserted = 0
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM " + models.PriceBandsTableName).Error; err != nil {
+				return fmt.Errorf("failed to delete price bands: %w", err)
+			}
+			if len(bands) == 0 {
+				return nil
+			}
+			result := tx.CreateInBatches(bands, 1000)
+			if result.Error != nil {
+				return fmt.Errorf("failed to insert price bands: %w", result.Error)
+			}
+			totalInserted = result.RowsAffected
+			return nil
+		})
+	})
+	return totalInserted, err
+}
+
+// GetPriceBands gets the price bands of the given instruments
+func (r *BandRepository) GetPriceBands(instruments []string) ([]models.PriceBandModel, error) {
+	var bands []models.PriceBandModel
+	if err := r.DB.Where("instrument IN ?", instruments).Find(&bands).Error; err != nil {
+		return nil, fmt.Errorf("failed to get price bands: %v", err)
+	}
+	return bands, nil
+}
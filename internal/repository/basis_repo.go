@@ -0,0 +1,46 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BasisRepository is the database repository for the futures basis
+type BasisRepository struct {
+	DB *gorm.DB
+}
+
+// NewBasisRepository creates a new basis repository
+func NewBasisRepository(db *gorm.DB) *BasisRepository {
+	return &BasisRepository{DB: db}
+}
+
+// InsertFuturesBasis inserts the basis snapshots, snapshots already taken for the minute are kept
+func (r *BasisRepository) InsertFuturesBasis(basis []models.FuturesBasisModel) error {
+	if len(basis) == 0 {
+		return nil
+	}
+	err := r.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(basis, 500).Error
+	if err != nil {
+		return fmt.Errorf("failed to insert futures basis: %v", err)
+	}
+	return nil
+}
+
+// GetFuturesBasis gets the basis snapshots of the futures of name, optionally for an expiry, between from and to
+func (r *BasisRepository) GetFuturesBasis(name, expiry string, from, to time.Time) ([]models.FuturesBasisModel, error) {
+	var basis []models.FuturesBasisModel
+	query := r.DB.Where("name = ? AND timestamp >= ? AND timestamp <= ?", name, from, to)
+	if expiry != "" {
+		query = query.Where("expiry = ?", expiry)
+	}
+	if err := query.Order("expiry, timestamp").Find(&basis).Error; err != nil {
+		return nil, fmt.Errorf("failed to get futures basis: %v", err)
+	}
+	return basis, nil
+}
@@ -0,0 +1,173 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CandleRepository is the database repository for the candles
+type CandleRepository struct {
+	DB *gorm.DB
+}
+
+// NewCandleRepository creates a new candle repository
+func NewCandleRepository(db *gorm.DB) *CandleRepository {
+	return &CandleRepository{DB: db}
+}
+
+// candleUpdates are the columns updated by a candle upsert, a candle without an instrument id keeps the stored one
+var candleUpdates = append(
+	clause.AssignmentColumns([]string{"instrument", "open", "high", "low", "close", "volume", "oi", "source", "updated_at"}),
+	clause.Assignment{
+		Column: clause.Column{Name: "instrument_id"},
+		Value:  gorm.Expr("COALESCE(NULLIF(excluded.instrument_id, 0), " + models.CandlesTableName + ".instrument_id)"),
+	},
+)
+
+// UpsertCandles inserts or replaces the 1 minute candles
+func (r *CandleRepository) UpsertCandles(candles []models.CandleModel) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	return withRetry("upsert candles", func() error {
+		err := r.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "instrument_token"}, {Name: "timestamp"}},
+			DoUpdates: candleUpdates,
+		}).CreateInBatches(candles, 1000).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert candles: %w", err)
+		}
+		return nil
+	})
+}
+
+// candlesOf scopes a candle query to an instrument, by its stable id when it has one so the candles of its
+// earlier tokens are included, by its token otherwise
+func candlesOf(db *gorm.DB, instrumentToken uint32, instrumentID uint64) *gorm.DB {
+	if instrumentID == 0 {
+		return db.Where("instrument_token = ?", instrumentToken)
+	}
+	return db.Where("instrument_id = ?", instrumentID)
+}
+
+// GetCandles gets the 1 minute candles of the instrument from the start of from up to before to, oldest first
+func (r *CandleRepository) GetCandles(instrumentToken uint32, instrumentID uint64, from, to time.Time) ([]models.CandleModel, error) {
+	var candles []models.CandleModel
+	err := candlesOf(r.DB, instrumentToken, instrumentID).
+		Where("timestamp >= ? AND timestamp < ?", from, to).
+		Order("timestamp").
+		Find(&candles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candles: %v", err)
+	}
+	return candles, nil
+}
+
+// GetLastCandles gets the latest 1 minute candle of each instrument with a timestamp from from up to and
+// including upTo
+func (r *CandleRepository) GetLastCandles(instruments []string, from, upTo time.Time) ([]models.CandleModel, error) {
+	var candles []models.CandleModel
+	err := r.DB.Select("DISTINCT ON (instrument) *").
+		Where("instrument IN ? AND timestamp >= ? AND timestamp <= ?", instruments, from, upTo).
+		Order("instrument, timestamp DESC").
+		Find(&candles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last candles: %v", err)
+	}
+	return candles, nil
+}
+
+// GetDayCandles aggregates the 1 minute candles of each instrument with a timestamp from from up to and
+// including upTo into one candle, its timestamp is the one of the last 1 minute candle and its source backfill
+// if any of them was backfilled
+func (r *CandleRepository) GetDayCandles(instruments []string, from, upTo time.Time) ([]models.CandleModel, error) {
+	var candles []models.CandleModel
+	err := r.DB.Model(&models.CandleModel{}).
+		Select(`instrument, MAX(instrument_token) AS instrument_token, MAX(timestamp) AS timestamp,
+			(ARRAY_AGG(open ORDER BY timestamp))[1] AS open, MAX(high) AS high, MIN(low) AS low,
+			(ARRAY_AGG(close ORDER BY timestamp DESC))[1] AS close, SUM(volume) AS volume,
+			(ARRAY_AGG(oi ORDER BY timestamp DESC))[1] AS oi,
+			CASE WHEN BOOL_OR(source = ?) THEN ? ELSE ? END AS source`, models.CandleSourceBackfill, models.CandleSourceBackfill, models.CandleSourceTick).
+		Where("instrument IN ? AND timestamp >= ? AND timestamp <= ?", instruments, from, upTo).
+		Group("instrument").
+		Scan(&candles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get day candles: %v", err)
+	}
+	return candles, nil
+}
+
+// InsertCandles inserts the candles which do not exist yet, candles built from the ticks are kept
+func (r *CandleRepository) InsertCandles(candles []models.CandleModel) (int64, error) {
+	if len(candles) == 0 {
+		return 0, nil
+	}
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(candles, 1000)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to insert candles: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetCandleTimestamps gets the start of the 1 minute candles of the instrument from the start of from up to before to
+func (r *CandleRepository) GetCandleTimestamps(instrumentToken uint32, instrumentID uint64, from, to time.Time) ([]time.Time, error) {
+	var timestamps []time.Time
+	err := candlesOf(r.DB.Model(&models.CandleModel{}), instrumentToken, instrumentID).
+		Where("timestamp >= ? AND timestamp < ?", from, to).
+		Order("timestamp").
+		Pluck("timestamp", &timestamps).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candle timestamps: %v", err)
+	}
+	return timestamps, nil
+}
+
+// GetCandleDays gets the days between from and to with candles of any instrument, as days since the unix
+// epoch in the time zone offset by offsetSeconds from UTC. Each day is probed on the timestamp index
+// instead of scanning the candles of the range
+func (r *CandleRepository) GetCandleDays(from, to time.Time, offsetSeconds int) ([]int64, error) {
+	if !to.After(from) {
+		return nil, nil
+	}
+	firstDay := floorDiv(from.Unix()+int64(offsetSeconds), 86400)
+	lastDay := floorDiv(to.Unix()-1+int64(offsetSeconds), 86400)
+
+	var days []int64
+	query := fmt.Sprintf(`SELECT day FROM generate_series(?::bigint, ?::bigint) AS day WHERE EXISTS (
+		SELECT 1 FROM %s WHERE timestamp >= GREATEST(to_timestamp(day * 86400 - ?), ?) AND timestamp < LEAST(to_timestamp((day + 1) * 86400 - ?), ?))`,
+		models.CandlesTableName)
+	err := r.DB.Raw(query, firstDay, lastDay, offsetSeconds, from, offsetSeconds, to).Scan(&days).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candle days: %v", err)
+	}
+	return days, nil
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// GetSampledCandles gets the last 1 minute candle of each instrument in every interval of intervalMinutes, with
+// a timestamp from from up to before to, ordered by instrument and timestamp
+func (r *CandleRepository) GetSampledCandles(instruments []string, from, to time.Time, intervalMinutes int) ([]models.CandleModel, error) {
+	var candles []models.CandleModel
+	bucket := fmt.Sprintf("FLOOR(EXTRACT(EPOCH FROM timestamp) / %d)", intervalMinutes*60)
+	err := r.DB.Select("DISTINCT ON (instrument, "+bucket+") *").
+		Where("instrument IN ? AND timestamp >= ? AND timestamp < ?", instruments, from, to).
+		Order("instrument, " + bucket + ", timestamp DESC").
+		Find(&candles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sampled candles: %v", err)
+	}
+	return candles, nil
+}
@@ -0,0 +1,58 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CloseReconcileRepository is the database repository for the close reconciliations
+type CloseReconcileRepository struct {
+	DB *gorm.DB
+}
+
+// NewCloseReconcileRepository creates a new close reconciliation repository
+func NewCloseReconcileRepository(db *gorm.DB) *CloseReconcileRepository {
+	return &CloseReconcileRepository{DB: db}
+}
+
+// SaveReconciliation replaces the reconciliation of its date and the divergences flagged by it
+func (r *CloseReconcileRepository) SaveReconciliation(summary models.CloseReconciliationModel, divergences []models.CloseDivergenceModel) error {
+	return withRetry("save close reconciliation", func() error {
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("date = ?", summary.Date).Delete(&models.CloseDivergenceModel{}).Error; err != nil {
+				return fmt.Errorf("failed to delete close divergences: %w", err)
+			}
+			if len(divergences) > 0 {
+				if err := tx.CreateInBatches(divergences, 1000).Error; err != nil {
+					return fmt.Errorf("failed to insert close divergences: %w", err)
+				}
+			}
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&summary).Error; err != nil {
+				return fmt.Errorf("failed to save close reconciliation: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// GetReconciliation gets the reconciliation of a date, gorm.ErrRecordNotFound when the date was not reconciled
+func (r *CloseReconcileRepository) GetReconciliation(date string) (models.CloseReconciliationModel, error) {
+	var summary models.CloseReconciliationModel
+	if err := r.DB.Where("date = ?", date).First(&summary).Error; err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// GetDivergences gets the divergences of a date, the largest first
+func (r *CloseReconcileRepository) GetDivergences(date string) ([]models.CloseDivergenceModel, error) {
+	var divergences []models.CloseDivergenceModel
+	if err := r.DB.Where("date = ?", date).Order("diff_percent DESC, instrument").Find(&divergences).Error; err != nil {
+		return nil, fmt.Errorf("failed to get close divergences: %v", err)
+	}
+	return divergences, nil
+}
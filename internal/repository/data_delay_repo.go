@@ -0,0 +1,50 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DataDelayRepository is the database repository for the users restricted to delayed market data
+type DataDelayRepository struct {
+	DB *gorm.DB
+}
+
+// NewDataDelayRepository creates a new data delay repository
+func NewDataDelayRepository(db *gorm.DB) *DataDelayRepository {
+	return &DataDelayRepository{DB: db}
+}
+
+// GetDataDelays gets the data delays of all users
+func (r *DataDelayRepository) GetDataDelays() ([]models.DataDelayModel, error) {
+	var delays []models.DataDelayModel
+	if err := r.DB.Order("user_id").Find(&delays).Error; err != nil {
+		return nil, fmt.Errorf("failed to get data delays: %v", err)
+	}
+	return delays, nil
+}
+
+// UpsertDataDelay sets the data delay of the user
+func (r *DataDelayRepository) UpsertDataDelay(delay *models.DataDelayModel) error {
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"delay_minutes", "reason", "set_by", "updated_at"}),
+	}).Create(delay).Error
+	if err != nil {
+		return fmt.Errorf("failed to set data delay: %v", err)
+	}
+	return nil
+}
+
+// DeleteDataDelay removes the data delay of the user, it returns false when the user had none
+func (r *DataDelayRepository) DeleteDataDelay(userID string) (bool, error) {
+	result := r.DB.Where("user_id = ?", userID).Delete(&models.DataDelayModel{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete data delay: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,189 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// dbMetricsStartKey is the statement instance key holding the start time of a statement
+const dbMetricsStartKey = "metrics:started_at"
+
+// dbStatementKey is a table and the kind of statement run on it
+type dbStatementKey struct {
+	table     string
+	operation string
+}
+
+// dbStatementCounters are the counters of the statements of one key
+type dbStatementCounters struct {
+	count       int64
+	errors      int64
+	slow        int64
+	total       time.Duration
+	max         time.Duration
+	lastErrorAt time.Time
+}
+
+// dbMetrics counts the statements run through GORM per table and kind, it is filled by the callbacks
+// registered on the connection
+var dbMetrics = struct {
+	mu            sync.Mutex
+	since         time.Time
+	slowThreshold time.Duration
+	statements    map[dbStatementKey]*dbStatementCounters
+}{statements: make(map[dbStatementKey]*dbStatementCounters)}
+
+// registerDBMetrics registers the callbacks timing every statement, statements slower than the threshold
+// are also counted as slow
+func registerDBMetrics(db *gorm.DB, slowThreshold time.Duration) error {
+	dbMetrics.mu.Lock()
+	dbMetrics.since = time.Now()
+	dbMetrics.slowThreshold = slowThreshold
+	dbMetrics.mu.Unlock()
+
+	callbacks := db.Callback()
+	processors := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("*").Register, callbacks.Create().After("*").Register},
+		{"query", callbacks.Query().Before("*").Register, callbacks.Query().After("*").Register},
+		{"update", callbacks.Update().Before("*").Register, callbacks.Update().After("*").Register},
+		{"delete", callbacks.Delete().Before("*").Register, callbacks.Delete().After("*").Register},
+		{"row", callbacks.Row().Before("*").Register, callbacks.Row().After("*").Register},
+		{"raw", callbacks.Raw().Before("*").Register, callbacks.Raw().After("*").Register},
+	}
+	for _, processor := range processors {
+		if err := processor.before("metrics:before_"+processor.operation, startDBStatement); err != nil {
+			return fmt.Errorf("failed to register db metrics callback: %v", err)
+		}
+		if err := processor.after("metrics:after_"+processor.operation, finishDBStatement(processor.operation)); err != nil {
+			return fmt.Errorf("failed to register db metrics callback: %v", err)
+		}
+	}
+	return nil
+}
+
+// startDBStatement records the start time of the statement
+func startDBStatement(db *gorm.DB) {
+	db.InstanceSet(dbMetricsStartKey, time.Now())
+}
+
+// finishDBStatement returns the callback counting a finished statement of the operation
+func finishDBStatement(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(dbMetricsStartKey)
+		if !ok {
+			return
+		}
+		startedAt, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(startedAt)
+
+		// tables are counted without the schema they were addressed with
+		table := db.Statement.Table
+		if i := strings.LastIndex(table, "."); i >= 0 {
+			table = table[i+1:]
+		}
+		if table == "" {
+			table = "raw"
+		}
+		failed := db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound)
+
+		dbMetrics.mu.Lock()
+		defer dbMetrics.mu.Unlock()
+		key := dbStatementKey{table: table, operation: operation}
+		counters, ok := dbMetrics.statements[key]
+		if !ok {
+			counters = &dbStatementCounters{}
+			dbMetrics.statements[key] = counters
+		}
+		counters.count++
+		counters.total += elapsed
+		if elapsed > counters.max {
+			counters.max = elapsed
+		}
+		if dbMetrics.slowThreshold > 0 && elapsed > dbMetrics.slowThreshold {
+			counters.slow++
+		}
+		if failed {
+			counters.errors++
+			counters.lastErrorAt = time.Now()
+		}
+	}
+}
+
+// GetDBMetrics returns the connection pool stats and the statement counters, the busiest tables first
+func GetDBMetrics(db *gorm.DB) (models.DBMetrics, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return models.DBMetrics{}, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	stats := sqlDB.Stats()
+
+	dbMetrics.mu.Lock()
+	defer dbMetrics.mu.Unlock()
+	metrics := models.DBMetrics{
+		Since:                dbMetrics.since,
+		SlowQueryThresholdMs: dbMetrics.slowThreshold.Milliseconds(),
+		Connections: models.DBConnectionStats{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+			MaxIdleClosed:      stats.MaxIdleClosed,
+			MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		},
+		Statements: make([]models.DBStatementMetrics, 0, len(dbMetrics.statements)),
+	}
+	for key, counters := range dbMetrics.statements {
+		statement := models.DBStatementMetrics{
+			Table:     key.table,
+			Operation: key.operation,
+			Count:     counters.count,
+			Errors:    counters.errors,
+			SlowCount: counters.slow,
+			TotalMs:   durationMs(counters.total),
+			MaxMs:     durationMs(counters.max),
+		}
+		if counters.count > 0 {
+			statement.ErrorRate = float64(counters.errors) / float64(counters.count)
+			statement.AvgMs = durationMs(counters.total / time.Duration(counters.count))
+		}
+		if !counters.lastErrorAt.IsZero() {
+			lastErrorAt := counters.lastErrorAt
+			statement.LastErrorAt = &lastErrorAt
+		}
+		metrics.Statements = append(metrics.Statements, statement)
+	}
+	sort.Slice(metrics.Statements, func(i, j int) bool {
+		a, b := metrics.Statements[i], metrics.Statements[j]
+		if a.TotalMs != b.TotalMs {
+			return a.TotalMs > b.TotalMs
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Operation < b.Operation
+	})
+	return metrics, nil
+}
+
+// durationMs returns the duration in milliseconds with microsecond precision
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
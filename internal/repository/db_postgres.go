@@ -3,6 +3,10 @@ package repository
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/models"
@@ -28,8 +32,16 @@ func ConnectPostgres(cfg *config.Config) (*gorm.DB, error) {
 		logLevel = logger.Info // Default to Info level
 	}
 
+	// Statements slower than the threshold are logged as slow at the warn level, validated when the config is loaded
+	slowQueryMs, _ := strconv.Atoi(cfg.PostgresSlowQueryMs)
+	slowThreshold := time.Duration(slowQueryMs) * time.Millisecond
+
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold: slowThreshold,
+			LogLevel:      logLevel,
+			Colorful:      true,
+		}),
 	}
 
 	// Open database connection
@@ -39,6 +51,11 @@ func ConnectPostgres(cfg *config.Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to Postgres: %v", err)
 	}
 
+	// Count and time the statements per table for the database metrics
+	if err := registerDBMetrics(db, slowThreshold); err != nil {
+		return nil, err
+	}
+
 	// Create the schema if it doesn't exist
 	createSchemaSql := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", cfg.PostgresSchema)
 	if err := db.Exec(createSchemaSql).Error; err != nil {
@@ -50,7 +67,7 @@ func ConnectPostgres(cfg *config.Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to auto migrate: %v", err)
 	}
 
-	// Set the ticker data table as unlogged
+	// Set the ticker data tables as unlogged
 	err = setTickerDataTableAsUnlogged(db, cfg)
 	if err != nil {
 		return nil, err
@@ -65,10 +82,40 @@ func autoMigrate(db *gorm.DB, cfg *config.Config) error {
 	}{
 		{models.SessionsTableName, &models.SessionModel{}},
 		{models.InstrumentsTableName, &models.InstrumentModel{}},
+		{models.InstrumentBadRowsTableName, &models.InstrumentBadRow{}},
 		{models.IndexTableName, &models.IndexModel{}},
 		{models.TickerInstrumentsTableName, &models.TickerInstrument{}},
 		{models.TickerLogTableName, &models.TickerLog{}},
 		{models.TickerDataTableName, &models.TickerData{}},
+		{models.PrevClosesTableName, &models.PrevCloseModel{}},
+		{models.UserSettingsTableName, &models.UserSettingsModel{}},
+		{models.JobsTableName, &models.JobModel{}},
+		{models.StreamUsageTableName, &models.StreamUsageModel{}},
+		{models.FuturesBasisTableName, &models.FuturesBasisModel{}},
+		{models.PriceBandsTableName, &models.PriceBandModel{}},
+		{models.DealsTableName, &models.DealModel{}},
+		{models.AnnouncementsTableName, &models.AnnouncementModel{}},
+		{models.ActivityEventsTableName, &models.ActivityEventModel{}},
+		{models.InstrumentIDsTableName, &models.InstrumentIDModel{}},
+		{models.InstrumentTokenHistoryTableName, &models.InstrumentTokenHistoryModel{}},
+		{models.InstrumentHistoryTableName, &models.InstrumentHistoryModel{}},
+		{models.TickStatsTableName, &models.TickStatsModel{}},
+		{models.NotificationPreferencesTableName, &models.NotificationPreferencesModel{}},
+		{models.StorageSnapshotsTableName, &models.StorageSnapshotModel{}},
+		{models.CandlesTableName, &models.CandleModel{}},
+		{models.SessionChecksTableName, &models.SessionCheckModel{}},
+		{models.KillSwitchesTableName, &models.KillSwitchModel{}},
+		{models.KillSwitchEventsTableName, &models.KillSwitchEventModel{}},
+		{models.RiskLimitsTableName, &models.RiskLimitsModel{}},
+		{models.RiskUsageTableName, &models.RiskUsageModel{}},
+		{models.InstrumentAccessTableName, &models.InstrumentAccessModel{}},
+		{models.IndexTicksTableName, &models.IndexTickModel{}},
+		{models.DataDelaysTableName, &models.DataDelayModel{}},
+		{models.CloseReconciliationsTableName, &models.CloseReconciliationModel{}},
+		{models.CloseDivergencesTableName, &models.CloseDivergenceModel{}},
+		{models.InstrumentBlacklistTableName, &models.InstrumentBlacklistModel{}},
+		{models.OptionGreeksTableName, &models.OptionGreeksModel{}},
+		{models.InstrumentTagsTableName, &models.InstrumentTagModel{}},
 	}
 
 	for _, table := range tables {
@@ -81,10 +128,11 @@ func autoMigrate(db *gorm.DB, cfg *config.Config) error {
 }
 
 func setTickerDataTableAsUnlogged(db *gorm.DB, cfg *config.Config) error {
-	// Set the table as unlogged
-	table := models.TickerDataTableName
-	if err := db.Table(cfg.PostgresSchema + "." + table).Exec("ALTER TABLE " + table + " SET UNLOGGED").Error; err != nil {
-		return fmt.Errorf("failed to set table as unlogged: %v", err)
+	// Set the tables holding the latest ticks as unlogged
+	for _, table := range []string{models.TickerDataTableName, models.IndexTicksTableName} {
+		if err := db.Table(cfg.PostgresSchema + "." + table).Exec("ALTER TABLE " + table + " SET UNLOGGED").Error; err != nil {
+			return fmt.Errorf("failed to set table as unlogged: %v", err)
+		}
 	}
 	return nil
 }
@@ -4,18 +4,68 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/redis/go-redis/v9"
 )
 
-func ConnectRedis(cfg *config.Config) (*redis.Client, error) {
-	// Setup Redis
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
-		Password: cfg.RedisPassword,
-	})
+// Redis topologies
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+// redisMaxRetries is the number of retries of a command, covering a sentinel failover or a cluster resharding
+const redisMaxRetries = 5
+
+func ConnectRedis(cfg *config.Config) (redis.UniversalClient, error) {
+	// Setup Redis, sentinel and cluster use the address list and fall back to host:port
+	addrs := []string{fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort)}
+	if cfg.RedisAddrs != "" {
+		addrs = addrs[:0]
+		for _, addr := range strings.Split(cfg.RedisAddrs, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	// validated when the config is loaded
+	redisDB, _ := strconv.Atoi(cfg.RedisDB)
+
+	var redisClient redis.UniversalClient
+	switch cfg.RedisMode {
+	case RedisModeSentinel:
+		redisClient = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.RedisSentinelMaster,
+			SentinelAddrs:    addrs,
+			SentinelPassword: cfg.RedisSentinelPassword,
+			Password:         cfg.RedisPassword,
+			DB:               redisDB,
+			MaxRetries:       redisMaxRetries,
+			MinRetryBackoff:  100 * time.Millisecond,
+			MaxRetryBackoff:  2 * time.Second,
+		})
+	case RedisModeCluster:
+		redisClient = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:           addrs,
+			Password:        cfg.RedisPassword,
+			MaxRetries:      redisMaxRetries,
+			MinRetryBackoff: 100 * time.Millisecond,
+			MaxRetryBackoff: 2 * time.Second,
+		})
+	default:
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     addrs[0],
+			Password: cfg.RedisPassword,
+			DB:       redisDB,
+		})
+	}
+
 	// Check Redis connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,67 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// DealRepository is the database repository for the block and bulk deals
+type DealRepository struct {
+	DB *gorm.DB
+}
+
+// NewDealRepository creates a new deal repository
+func NewDealRepository(db *gorm.DB) *DealRepository {
+	return &DealRepository{DB: db}
+}
+
+// ReplaceDeals replaces the deals of the type for the dates present in deals, a republished file never duplicates rows
+func (r *DealRepository) ReplaceDeals(dealType string, deals []models.DealModel) (int64, error) {
+	if len(deals) == 0 {
+		return 0, nil
+	}
+	dateSet := make(map[string]bool)
+	dates := make([]string, 0)
+	for _, deal := range deals {
+		if !dateSet[deal.Date] {
+			dateSet[deal.Date] = true
+			dates = append(dates, deal.Date)
+		}
+	}
+
+	var totalInserted int64
+	err := withRetry("replace "+dealType+" deals", func() error {
+		totalInserted = 0
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("type = ? AND date IN ?", dealType, dates).Delete(&models.DealModel{}).Error; err != nil {
+				return fmt.Errorf("failed to delete %s deals: %w", dealType, err)
+			}
+			result := tx.CreateInBatches(deals, 1000)
+			if result.Error != nil {
+				return fmt.Errorf("failed to insert %s deals: %w", dealType, result.Error)
+			}
+			totalInserted = result.RowsAffected
+			return nil
+		})
+	})
+	return totalInserted, err
+}
+
+// GetDeals gets the deals of a date, optionally for a symbol and a type
+func (r *DealRepository) GetDeals(date, symbol, dealType string) ([]models.DealModel, error) {
+	var deals []models.DealModel
+	query := r.DB.Where("date = ?", date)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	if dealType != "" {
+		query = query.Where("type = ?", dealType)
+	}
+	if err := query.Order("symbol, type, id").Find(&deals).Error; err != nil {
+		return nil, fmt.Errorf("failed to get deals: %v", err)
+	}
+	return deals, nil
+}
@@ -0,0 +1,87 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GreeksRepository is the database repository for the historical option greeks
+type GreeksRepository struct {
+	DB *gorm.DB
+}
+
+// NewGreeksRepository creates a new greeks repository
+func NewGreeksRepository(db *gorm.DB) *GreeksRepository {
+	return &GreeksRepository{DB: db}
+}
+
+// GetOptionContracts gets the options with candles from the start of from up to before to, with their definition
+// on the date (YYYY-MM-DD) from the instrument history
+func (r *GreeksRepository) GetOptionContracts(date string, from, to time.Time) ([]models.OptionContract, error) {
+	var contracts []models.OptionContract
+	query := fmt.Sprintf("SELECT DISTINCT ON (c.instrument_token) c.instrument_token, c.instrument, h.exchange, h.name, "+
+		"h.expiry, h.strike, h.instrument_type "+
+		"FROM (SELECT DISTINCT instrument_token, instrument FROM %s WHERE timestamp >= ? AND timestamp < ?) c "+
+		"JOIN %s h ON h.exchange = split_part(c.instrument, ':', 1) AND h.tradingsymbol = substr(c.instrument, strpos(c.instrument, ':') + 1) "+
+		"WHERE h.instrument_type IN ('CE', 'PE') AND h.valid_from <= ? AND (h.valid_to IS NULL OR h.valid_to > ?) "+
+		"ORDER BY c.instrument_token, h.valid_from DESC",
+		models.CandlesTableName, models.InstrumentHistoryTableName)
+	if err := r.DB.Raw(query, from, to, date, date).Scan(&contracts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get option contracts: %v", err)
+	}
+	return contracts, nil
+}
+
+// UpsertOptionGreeks inserts or replaces the option greeks
+func (r *GreeksRepository) UpsertOptionGreeks(greeks []models.OptionGreeksModel) error {
+	if len(greeks) == 0 {
+		return nil
+	}
+	return withRetry("upsert option greeks", func() error {
+		err := r.DB.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "instrument_token"}, {Name: "timestamp"}},
+			DoUpdates: clause.AssignmentColumns([]string{"instrument", "name", "expiry", "strike", "option_type",
+				"underlying_instrument", "underlying_price", "option_price", "iv", "delta", "gamma", "theta", "vega", "computed_at"}),
+		}).CreateInBatches(greeks, 1000).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert option greeks: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetGreeksDays gets the days between from and to with greeks of any option, as days since the unix epoch
+// in the time zone offset by offsetSeconds from UTC
+func (r *GreeksRepository) GetGreeksDays(from, to time.Time, offsetSeconds int) ([]int64, error) {
+	var days []int64
+	query := fmt.Sprintf("SELECT DISTINCT FLOOR((EXTRACT(EPOCH FROM timestamp) + ?) / 86400)::bigint FROM %s WHERE timestamp >= ? AND timestamp < ?", models.OptionGreeksTableName)
+	err := r.DB.Raw(query, offsetSeconds, from, to).Scan(&days).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get greeks days: %v", err)
+	}
+	return days, nil
+}
+
+// GetOptionGreeks gets the greeks of an option, or of the options of name optionally for an expiry, between
+// from and to
+func (r *GreeksRepository) GetOptionGreeks(instrument, name, expiry string, from, to time.Time) ([]models.OptionGreeksModel, error) {
+	var greeks []models.OptionGreeksModel
+	query := r.DB.Where("timestamp >= ? AND timestamp <= ?", from, to)
+	if instrument != "" {
+		query = query.Where("instrument = ?", instrument)
+	} else {
+		query = query.Where("name = ?", name)
+		if expiry != "" {
+			query = query.Where("expiry = ?", expiry)
+		}
+	}
+	if err := query.Order("expiry, strike, option_type, timestamp").Find(&greeks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get option greeks: %v", err)
+	}
+	return greeks, nil
+}
@@ -0,0 +1,67 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InstrumentAccessRepository is the database repository for the instrument access counters
+type InstrumentAccessRepository struct {
+	DB *gorm.DB
+}
+
+// NewInstrumentAccessRepository creates a new instrument access repository
+func NewInstrumentAccessRepository(db *gorm.DB) *InstrumentAccessRepository {
+	return &InstrumentAccessRepository{DB: db}
+}
+
+// IncrementInstrumentAccess adds the counters to the day's counters of the instruments,
+// the counters are increments so every process can add its own
+func (r *InstrumentAccessRepository) IncrementInstrumentAccess(counters []models.InstrumentAccessModel) error {
+	if len(counters) == 0 {
+		return nil
+	}
+	return withRetry("increment instrument access", func() error {
+		err := r.DB.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "date"}, {Name: "instrument"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"queries":    gorm.Expr(models.InstrumentAccessTableName + ".queries + excluded.queries"),
+				"streams":    gorm.Expr(models.InstrumentAccessTableName + ".streams + excluded.streams"),
+				"updated_at": gorm.Expr("excluded.updated_at"),
+			}),
+		}).CreateInBatches(counters, 1000).Error
+		if err != nil {
+			return fmt.Errorf("failed to increment instrument access: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetPopularInstruments gets the instruments with the most accesses between the dates, inclusive,
+// sorted by the given counter: queries, streams or total
+func (r *InstrumentAccessRepository) GetPopularInstruments(fromDate, toDate, sortBy string, limit int) ([]models.PopularInstrument, error) {
+	order := "total DESC"
+	switch sortBy {
+	case "queries":
+		order = "queries DESC"
+	case "streams":
+		order = "streams DESC"
+	}
+
+	var instruments []models.PopularInstrument
+	err := r.DB.Model(&models.InstrumentAccessModel{}).
+		Select("instrument, SUM(queries) AS queries, SUM(streams) AS streams, SUM(queries + streams) AS total, COUNT(*) AS days").
+		Where("date BETWEEN ? AND ?", fromDate, toDate).
+		Group("instrument").
+		Order(order + ", instrument").
+		Limit(limit).
+		Scan(&instruments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get popular instruments: %v", err)
+	}
+	return instruments, nil
+}
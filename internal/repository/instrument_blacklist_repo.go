@@ -0,0 +1,50 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InstrumentBlacklistRepository is the database repository for the blacklisted instruments
+type InstrumentBlacklistRepository struct {
+	DB *gorm.DB
+}
+
+// NewInstrumentBlacklistRepository creates a new instrument blacklist repository
+func NewInstrumentBlacklistRepository(db *gorm.DB) *InstrumentBlacklistRepository {
+	return &InstrumentBlacklistRepository{DB: db}
+}
+
+// GetBlacklistedInstruments gets the blacklisted instruments
+func (r *InstrumentBlacklistRepository) GetBlacklistedInstruments() ([]models.InstrumentBlacklistModel, error) {
+	var instruments []models.InstrumentBlacklistModel
+	if err := r.DB.Order("instrument").Find(&instruments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get blacklisted instruments: %v", err)
+	}
+	return instruments, nil
+}
+
+// UpsertBlacklistedInstruments blacklists the instruments, the reason of instruments already blacklisted is replaced
+func (r *InstrumentBlacklistRepository) UpsertBlacklistedInstruments(instruments []models.InstrumentBlacklistModel) error {
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instrument"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "added_by"}),
+	}).Create(&instruments).Error
+	if err != nil {
+		return fmt.Errorf("failed to blacklist instruments: %v", err)
+	}
+	return nil
+}
+
+// DeleteBlacklistedInstruments removes the instruments from the blacklist, it returns the number removed
+func (r *InstrumentBlacklistRepository) DeleteBlacklistedInstruments(instruments []string) (int64, error) {
+	result := r.DB.Where("instrument IN ?", instruments).Delete(&models.InstrumentBlacklistModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete blacklisted instruments: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,133 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// instrumentHistoryColumns are the columns copied into the instrument history
+var instrumentHistoryColumns = []string{
+	"instrument_token", "exchange_token", "tradingsymbol", "name", "last_price", "expiry", "strike",
+	"tick_size", "lot_size", "instrument_type", "segment", "exchange", "is_weekly_expiry", "expiry_series",
+}
+
+// instrumentHistoryTrackedColumns are the columns whose change starts a new definition, the last price changes daily and is not tracked
+var instrumentHistoryTrackedColumns = []string{
+	"instrument_token", "exchange_token", "tradingsymbol", "name", "expiry", "strike",
+	"tick_size", "lot_size", "instrument_type", "segment", "exchange",
+}
+
+// SyncInstrumentHistory closes the current definitions which are no longer in the instruments table and
+// opens a definition for every instrument without an identical current definition. The closed definitions
+// take a version from the id sequence so the opened definitions are always of a later version
+func (r *InstrumentRepository) SyncInstrumentHistory(date string) (int64, int64, error) {
+	conditions := make([]string, 0, len(instrumentHistoryTrackedColumns))
+	for _, column := range instrumentHistoryTrackedColumns {
+		conditions = append(conditions, fmt.Sprintf("n.%s IS NOT DISTINCT FROM h.%s", column, column))
+	}
+	identical := strings.Join(conditions, " AND ")
+	columns := strings.Join(instrumentHistoryColumns, ", ")
+
+	var closed, opened int64
+	err := withRetry("SyncInstrumentHistory", func() error {
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			res := tx.Exec(fmt.Sprintf(
+				"UPDATE %s h SET valid_to = ?, closed_version = nextval(pg_get_serial_sequence('%s', 'id')) "+
+					"WHERE h.valid_to IS NULL AND NOT EXISTS (SELECT 1 FROM %s n WHERE %s)",
+				models.InstrumentHistoryTableName, models.InstrumentHistoryTableName, models.InstrumentsTableName, identical), date)
+			if res.Error != nil {
+				return fmt.Errorf("failed to close instrument definitions: %w", res.Error)
+			}
+			closed = res.RowsAffected
+
+			res = tx.Exec(fmt.Sprintf(
+				"INSERT INTO %s (%s, valid_from) SELECT %s, ? FROM %s n "+
+					"WHERE NOT EXISTS (SELECT 1 FROM %s h WHERE h.valid_to IS NULL AND %s)",
+				models.InstrumentHistoryTableName, columns, columns, models.InstrumentsTableName,
+				models.InstrumentHistoryTableName, identical), date)
+			if res.Error != nil {
+				return fmt.Errorf("failed to open instrument definitions: %w", res.Error)
+			}
+			opened = res.RowsAffected
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return closed, opened, nil
+}
+
+// GetInstrumentSync returns the instruments added, changed and removed after the history version, every
+// instrument as added when the version is 0. It is read from one snapshot so the returned version matches the rows
+func (r *InstrumentRepository) GetInstrumentSync(since uint64) (models.InstrumentSync, error) {
+	sync := models.InstrumentSync{
+		SinceVersion: since,
+		Full:         since == 0,
+		Added:        make([]models.InstrumentModel, 0),
+		Changed:      make([]models.InstrumentModel, 0),
+		Removed:      make([]models.InstrumentRemoval, 0),
+	}
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(models.InstrumentHistoryTableName).
+			Select("GREATEST(COALESCE(MAX(id), 0), COALESCE(MAX(closed_version), 0))").
+			Scan(&sync.Version).Error
+		if err != nil {
+			return fmt.Errorf("failed to get instrument history version: %v", err)
+		}
+
+		if sync.Full {
+			if err := tx.Order("instrument_token").Find(&sync.Added).Error; err != nil {
+				return fmt.Errorf("failed to get instruments: %v", err)
+			}
+			return nil
+		}
+		if since >= sync.Version {
+			return nil
+		}
+
+		// an instrument whose definition was opened in the window is changed when a previous definition was closed in it
+		var opened []struct {
+			models.InstrumentModel
+			Changed bool
+		}
+		err = tx.Raw(fmt.Sprintf(
+			"SELECT n.*, EXISTS (SELECT 1 FROM %s o WHERE o.exchange = n.exchange AND o.tradingsymbol = n.tradingsymbol "+
+				"AND o.closed_version > ? AND o.closed_version <= ?) AS changed "+
+				"FROM %s n JOIN %s h ON h.exchange = n.exchange AND h.tradingsymbol = n.tradingsymbol AND h.valid_to IS NULL "+
+				"WHERE h.id > ? AND h.id <= ? ORDER BY n.instrument_token",
+			models.InstrumentHistoryTableName, models.InstrumentsTableName, models.InstrumentHistoryTableName),
+			since, sync.Version, since, sync.Version).Scan(&opened).Error
+		if err != nil {
+			return fmt.Errorf("failed to get changed instruments: %v", err)
+		}
+		for _, instrument := range opened {
+			if instrument.Changed {
+				sync.Changed = append(sync.Changed, instrument.InstrumentModel)
+			} else {
+				sync.Added = append(sync.Added, instrument.InstrumentModel)
+			}
+		}
+
+		err = tx.Raw(fmt.Sprintf(
+			"SELECT DISTINCT ON (h.exchange, h.tradingsymbol) h.instrument_token, h.exchange, h.tradingsymbol FROM %s h "+
+				"WHERE h.closed_version > ? AND h.closed_version <= ? "+
+				"AND NOT EXISTS (SELECT 1 FROM %s n WHERE n.exchange = h.exchange AND n.tradingsymbol = h.tradingsymbol) "+
+				"ORDER BY h.exchange, h.tradingsymbol, h.closed_version DESC",
+			models.InstrumentHistoryTableName, models.InstrumentsTableName),
+			since, sync.Version).Scan(&sync.Removed).Error
+		if err != nil {
+			return fmt.Errorf("failed to get removed instruments: %v", err)
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return sync, err
+	}
+	return sync, nil
+}
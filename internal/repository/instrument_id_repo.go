@@ -0,0 +1,117 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// instrumentIDReferencingTables are the tables keyed on exchange:tradingsymbol which carry the instrument id
+var instrumentIDReferencingTables = []string{
+	models.TickerInstrumentsTableName,
+	models.TickerDataTableName,
+	models.IndexTicksTableName,
+	models.PrevClosesTableName,
+	models.CandlesTableName,
+}
+
+// SyncInstrumentIDs assigns ids to new instruments, closes the token mapping of instruments whose token changed,
+// opens the mapping of their new token and backfills the ids of the referencing tables
+func (r *InstrumentRepository) SyncInstrumentIDs(date string) (models.InstrumentIDSyncResult, error) {
+	var result models.InstrumentIDSyncResult
+	err := withRetry("SyncInstrumentIDs", func() error {
+		result = models.InstrumentIDSyncResult{}
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			res := tx.Exec(fmt.Sprintf(
+				"INSERT INTO %s (instrument, exchange, tradingsymbol, created_at) "+
+					"SELECT exchange || ':' || tradingsymbol, exchange, tradingsymbol, NOW() FROM %s "+
+					"ON CONFLICT (instrument) DO NOTHING",
+				models.InstrumentIDsTableName, models.InstrumentsTableName))
+			if res.Error != nil {
+				return fmt.Errorf("failed to insert instrument ids: %w", res.Error)
+			}
+			result.NewIDs = res.RowsAffected
+
+			res = tx.Exec(fmt.Sprintf(
+				"UPDATE %s h SET valid_to = ? FROM %s i, %s n "+
+					"WHERE h.instrument_id = i.id AND n.exchange = i.exchange AND n.tradingsymbol = i.tradingsymbol "+
+					"AND h.valid_to IS NULL AND h.instrument_token <> n.instrument_token",
+				models.InstrumentTokenHistoryTableName, models.InstrumentIDsTableName, models.InstrumentsTableName), date)
+			if res.Error != nil {
+				return fmt.Errorf("failed to close instrument token mappings: %w", res.Error)
+			}
+			result.Remapped = res.RowsAffected
+
+			res = tx.Exec(fmt.Sprintf(
+				"INSERT INTO %s (instrument_id, instrument_token, valid_from) "+
+					"SELECT i.id, n.instrument_token, ? FROM %s n JOIN %s i ON i.exchange = n.exchange AND i.tradingsymbol = n.tradingsymbol "+
+					"WHERE NOT EXISTS (SELECT 1 FROM %s h WHERE h.instrument_id = i.id AND h.valid_to IS NULL)",
+				models.InstrumentTokenHistoryTableName, models.InstrumentsTableName, models.InstrumentIDsTableName,
+				models.InstrumentTokenHistoryTableName), date)
+			if res.Error != nil {
+				return fmt.Errorf("failed to open instrument token mappings: %w", res.Error)
+			}
+
+			for _, table := range instrumentIDReferencingTables {
+				backfilled, err := backfillInstrumentIDs(tx, table, "")
+				if err != nil {
+					return err
+				}
+				result.Backfilled += backfilled
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+// backfillInstrumentIDs sets the instrument id of the rows of a table from their exchange:tradingsymbol,
+// the optional user id limits the backfill to the rows of a user
+func backfillInstrumentIDs(tx *gorm.DB, table, userID string) (int64, error) {
+	stmt := fmt.Sprintf("UPDATE %s t SET instrument_id = i.id FROM %s i "+
+		"WHERE t.instrument = i.instrument AND t.instrument_id IS DISTINCT FROM i.id",
+		table, models.InstrumentIDsTableName)
+	args := []interface{}{}
+	if userID != "" {
+		stmt += " AND t.user_id = ?"
+		args = append(args, userID)
+	}
+	res := tx.Exec(stmt, args...)
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to backfill instrument ids of %s: %w", table, res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// GetCurrentInstrumentIDs gets the instrument id of each current instrument token
+func (r *InstrumentRepository) GetCurrentInstrumentIDs() (map[uint32]uint64, error) {
+	var mappings []models.InstrumentTokenHistoryModel
+	if err := r.DB.Where("valid_to IS NULL").Find(&mappings).Error; err != nil {
+		return nil, fmt.Errorf("failed to get instrument ids: %v", err)
+	}
+	ids := make(map[uint32]uint64, len(mappings))
+	for _, mapping := range mappings {
+		ids[mapping.InstrumentToken] = mapping.InstrumentID
+	}
+	return ids, nil
+}
+
+// GetInstrumentID gets the instrument id of an exchange:tradingsymbol
+func (r *InstrumentRepository) GetInstrumentID(instrument string) (models.InstrumentIDModel, error) {
+	var instrumentID models.InstrumentIDModel
+	if err := r.DB.Where("instrument = ?", instrument).First(&instrumentID).Error; err != nil {
+		return instrumentID, err
+	}
+	return instrumentID, nil
+}
+
+// GetInstrumentTokenHistory gets the token mappings of an instrument id, oldest first
+func (r *InstrumentRepository) GetInstrumentTokenHistory(instrumentID uint64) ([]models.InstrumentTokenHistoryModel, error) {
+	var history []models.InstrumentTokenHistoryModel
+	if err := r.DB.Where("instrument_id = ?", instrumentID).Order("id").Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("failed to get instrument token history: %v", err)
+	}
+	return history, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
 	"gorm.io/gorm"
 )
 
@@ -27,52 +28,173 @@ func (r *InstrumentRepository) TruncateInstrumentsTable() error {
 }
 
 // InsertInstruments inserts a batch of instruments into the database
-func (r *InstrumentRepository) InsertInstruments(records [][]string) (int64, error) {
-	valueStrings := make([]string, 0, len(records))
-	valueArgs := make([]interface{}, 0, len(records)*13)
+func (r *InstrumentRepository) InsertInstruments(instruments []models.InstrumentModel) (int64, error) {
+	return insertInstruments(r.DB, instruments)
+}
 
-	now := time.Now().Format("2006-01-02 15:04:05")
+// ReplaceExchangeInstruments replaces all instruments of an exchange in a single transaction,
+// the transaction is rolled back if the inserted row count does not match the number of instruments
+func (r *InstrumentRepository) ReplaceExchangeInstruments(exchange string, instruments []models.InstrumentModel, batchSize int) (int64, error) {
+	var totalInserted int64
+	err := withRetry("ReplaceExchangeInstruments "+exchange, func() error {
+		totalInserted = 0
+		return r.replaceExchangeInstruments(exchange, instruments, batchSize, &totalInserted)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return totalInserted, nil
+}
 
-	for _, record := range records {
-		valueStrings = append(valueStrings, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+// replaceExchangeInstruments replaces the instruments of an exchange in one transaction
+func (r *InstrumentRepository) replaceExchangeInstruments(exchange string, instruments []models.InstrumentModel, batchSize int, totalInserted *int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("exchange = ?", exchange).Delete(&models.InstrumentModel{}).Error; err != nil {
+			return fmt.Errorf("failed to delete %s instruments: %w", exchange, err)
+		}
 
-		instrumentToken, _ := strconv.ParseUint(record[0], 10, 32)
-		exchangeToken, _ := strconv.ParseUint(record[1], 10, 32)
-		lastPrice, _ := strconv.ParseFloat(record[4], 64)
-		strike, _ := strconv.ParseFloat(record[6], 64)
-		tickSize, _ := strconv.ParseFloat(record[7], 64)
-		lotSize, _ := strconv.ParseUint(record[8], 10, 32)
+		for i := 0; i < len(instruments); i += batchSize {
+			end := i + batchSize
+			if end > len(instruments) {
+				end = len(instruments)
+			}
+			inserted, err := insertInstruments(tx, instruments[i:end])
+			if err != nil {
+				return fmt.Errorf("failed to insert %s batch starting at index %d: %w", exchange, i, err)
+			}
+			*totalInserted += inserted
+		}
+
+		// verify the rows in the table against the instruments
+		var count int64
+		if err := tx.Model(&models.InstrumentModel{}).Where("exchange = ?", exchange).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count %s instruments: %w", exchange, err)
+		}
+		if count != int64(len(instruments)) || *totalInserted != int64(len(instruments)) {
+			return fmt.Errorf("%s row count mismatch, instruments: %d, inserted: %d, in table: %d", exchange, len(instruments), *totalInserted, count)
+		}
+		return nil
+	})
+}
 
+// DeleteInstrumentsNotInExchanges deletes the instruments of exchanges not in the given list
+func (r *InstrumentRepository) DeleteInstrumentsNotInExchanges(exchanges []string) (int64, error) {
+	result := r.DB.Where("exchange NOT IN ?", exchanges).Delete(&models.InstrumentModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete instruments of removed exchanges: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// insertInstruments inserts a batch of instruments using the given connection
+func insertInstruments(db *gorm.DB, instruments []models.InstrumentModel) (int64, error) {
+	valueStrings := make([]string, 0, len(instruments))
+	valueArgs := make([]interface{}, 0, len(instruments)*15)
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+
+	for _, instrument := range instruments {
+		valueStrings = append(valueStrings, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 		valueArgs = append(valueArgs,
-			uint(instrumentToken),
-			uint(exchangeToken),
-			record[2],
-			record[3],
-			lastPrice,
-			record[5],
-			strike,
-			tickSize,
-			uint(lotSize),
-			record[9],
-			record[10],
-			record[11],
+			uint(instrument.InstrumentToken),
+			uint(instrument.ExchangeToken),
+			instrument.Tradingsymbol,
+			instrument.Name,
+			instrument.LastPrice,
+			instrument.Expiry,
+			instrument.Strike,
+			instrument.TickSize,
+			instrument.LotSize,
+			instrument.InstrumentType,
+			instrument.Segment,
+			instrument.Exchange,
+			instrument.IsWeeklyExpiry,
+			instrument.ExpirySeries,
 			now,
 		)
 	}
 
-	stmt := fmt.Sprintf("INSERT INTO %s (instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange, updated_at) VALUES %s",
+	stmt := fmt.Sprintf("INSERT INTO %s (instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange, is_weekly_expiry, expiry_series, updated_at) VALUES %s",
 		models.InstrumentsTableName,
 		strings.Join(valueStrings, ","),
 	)
 
-	result := r.DB.Exec(stmt, valueArgs...)
+	result := db.Exec(stmt, valueArgs...)
 	if result.Error != nil {
-		return 0, fmt.Errorf("failed to insert batch into %s: %v", models.InstrumentsTableName, result.Error)
+		return 0, fmt.Errorf("failed to insert batch into %s: %w", models.InstrumentsTableName, result.Error)
 	}
 
 	return result.RowsAffected, nil
 }
 
+// InsertNewInstruments inserts the instruments whose token is not in the table yet and returns them,
+// existing instruments are left untouched
+func (r *InstrumentRepository) InsertNewInstruments(instruments []models.InstrumentModel, batchSize int) ([]models.InstrumentModel, error) {
+	var existing []uint32
+	if err := r.DB.Model(&models.InstrumentModel{}).Pluck("instrument_token", &existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to get instrument tokens: %v", err)
+	}
+	existingTokens := make(map[uint32]bool, len(existing))
+	for _, token := range existing {
+		existingTokens[token] = true
+	}
+
+	newInstruments := make([]models.InstrumentModel, 0)
+	for _, instrument := range instruments {
+		if !existingTokens[instrument.InstrumentToken] {
+			existingTokens[instrument.InstrumentToken] = true
+			newInstruments = append(newInstruments, instrument)
+		}
+	}
+	if len(newInstruments) == 0 {
+		return newInstruments, nil
+	}
+
+	err := withRetry("InsertNewInstruments", func() error {
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			for i := 0; i < len(newInstruments); i += batchSize {
+				end := i + batchSize
+				if end > len(newInstruments) {
+					end = len(newInstruments)
+				}
+				if _, err := insertInstruments(tx, newInstruments[i:end]); err != nil {
+					return fmt.Errorf("failed to insert new instruments batch starting at index %d: %w", i, err)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newInstruments, nil
+}
+
+// ReplaceInstrumentBadRows replaces the bad rows report of the last instruments load
+func (r *InstrumentRepository) ReplaceInstrumentBadRows(badRows []models.InstrumentBadRow) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("TRUNCATE TABLE %s", models.InstrumentBadRowsTableName)).Error; err != nil {
+			return fmt.Errorf("failed to truncate table %s: %v", models.InstrumentBadRowsTableName, err)
+		}
+		if len(badRows) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(badRows, 500).Error; err != nil {
+			return fmt.Errorf("failed to insert batch into %s: %v", models.InstrumentBadRowsTableName, err)
+		}
+		return nil
+	})
+}
+
+// GetInstrumentBadRows returns the bad rows report of the last instruments load
+func (r *InstrumentRepository) GetInstrumentBadRows() ([]models.InstrumentBadRow, error) {
+	var badRows []models.InstrumentBadRow
+	if err := r.DB.Order("row ASC, id ASC").Find(&badRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get instrument bad rows: %v", err)
+	}
+	return badRows, nil
+}
+
 // GetInstrumentsRecordCount returns the number of records in the instruments table
 func (r *InstrumentRepository) GetInstrumentsRecordCount() (int64, error) {
 	var count int64
@@ -83,10 +205,37 @@ func (r *InstrumentRepository) GetInstrumentsRecordCount() (int64, error) {
 	return count, nil
 }
 
+// GetInstrumentStatsGroups returns the number of instruments and their last update by exchange, segment and instrument type
+func (r *InstrumentRepository) GetInstrumentStatsGroups() ([]models.InstrumentStatsGroup, error) {
+	var groups []models.InstrumentStatsGroup
+	err := r.DB.Model(&models.InstrumentModel{}).
+		Select("exchange, segment, instrument_type, COUNT(*) AS count, MAX(updated_at) AS updated_at").
+		Group("exchange, segment, instrument_type").
+		Order("exchange, segment, instrument_type").
+		Scan(&groups).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument stats: %v", err)
+	}
+	return groups, nil
+}
+
+// GetAllInstruments returns all instruments
+func (r *InstrumentRepository) GetAllInstruments() ([]models.InstrumentModel, error) {
+	var instruments []models.InstrumentModel
+	if err := r.DB.Find(&instruments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get all instruments: %v", err)
+	}
+	return instruments, nil
+}
+
 // GetInstrumentsQuery queries the instruments table
 func (r *InstrumentRepository) GetInstrumentsQuery(qip models.QueryInstrumentsParams) ([]models.InstrumentModel, error) {
 
 	query := r.DB.Model(&models.InstrumentModel{})
+	if qip.AsOf != "" {
+		query = r.DB.Table(models.InstrumentHistoryTableName).
+			Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", qip.AsOf, qip.AsOf)
+	}
 
 	if qip.Exchange != "" {
 		query = query.Where("exchange = ?", qip.Exchange)
@@ -113,11 +262,11 @@ func (r *InstrumentRepository) GetInstrumentsQuery(qip models.QueryInstrumentsPa
 	}
 
 	if qip.Strike != "" {
-		strikeFloat, err := strconv.ParseFloat(qip.Strike, 64)
+		strike, err := decimal.NewFromString(qip.Strike)
 		if err != nil {
 			return nil, err
 		}
-		query = query.Where("strike = ?", strikeFloat)
+		query = query.Where("strike = ?", strike)
 	}
 
 	if qip.Segment != "" {
@@ -128,6 +277,18 @@ func (r *InstrumentRepository) GetInstrumentsQuery(qip models.QueryInstrumentsPa
 		query = query.Where("instrument_type = ?", qip.InstrumentType)
 	}
 
+	if qip.IsWeeklyExpiry != "" {
+		isWeeklyExpiry, err := strconv.ParseBool(qip.IsWeeklyExpiry)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("is_weekly_expiry = ?", isWeeklyExpiry)
+	}
+
+	if qip.ExpirySeries != "" {
+		query = query.Where("expiry_series = ?", qip.ExpirySeries)
+	}
+
 	var instruments []models.InstrumentModel
 	if err := query.Find(&instruments).Error; err != nil {
 		return nil, err
@@ -220,3 +381,52 @@ func (r *InstrumentRepository) GetFNOSegmentWiseExpiry(name string, limit, offse
 		Error
 	return instruments, err
 }
+
+// GetOptionStrikes returns the distinct option strikes per segment and expiry for a given name,
+// optionally limited to one expiry
+func (r *InstrumentRepository) GetOptionStrikes(name, expiry string) ([]models.InstrumentModel, error) {
+	var instruments []models.InstrumentModel
+	query := r.DB.Model(&models.InstrumentModel{}).
+		Select("DISTINCT segment, expiry, strike").
+		Where("name = ? AND instrument_type IN ?", name, []string{"CE", "PE"})
+	if expiry != "" {
+		query = query.Where("expiry = ?", expiry)
+	}
+	err := query.Order("segment ASC, expiry ASC, strike ASC").
+		Find(&instruments).
+		Error
+	return instruments, err
+}
+
+// GetNearestFuture returns the future of a name for the given expiry, or the nearest one unexpired on today if expiry is blank
+func (r *InstrumentRepository) GetNearestFuture(exchange, name, expiry, today string) (models.InstrumentModel, error) {
+	var instrument models.InstrumentModel
+	query := r.DB.Where("exchange = ? AND name = ? AND instrument_type = ?", exchange, name, "FUT")
+	if expiry != "" {
+		query = query.Where("expiry = ?", expiry)
+	} else {
+		query = query.Where("expiry >= ?", today)
+	}
+	err := query.Order("expiry ASC").First(&instrument).Error
+	return instrument, err
+}
+
+// GetActiveFutures returns the futures of a name unexpired on today ordered by expiry
+func (r *InstrumentRepository) GetActiveFutures(exchange, name, today string) ([]models.InstrumentModel, error) {
+	var instruments []models.InstrumentModel
+	err := r.DB.Where("exchange = ? AND name = ? AND instrument_type = ? AND expiry >= ?", exchange, name, "FUT", today).
+		Order("expiry ASC").
+		Find(&instruments).
+		Error
+	return instruments, err
+}
+
+// GetOptionChainInstruments returns the calls and puts of a name for an expiry ordered by strike
+func (r *InstrumentRepository) GetOptionChainInstruments(exchange, name, expiry string) ([]models.InstrumentModel, error) {
+	var instruments []models.InstrumentModel
+	err := r.DB.Where("exchange = ? AND name = ? AND expiry = ? AND instrument_type IN ?", exchange, name, expiry, []string{"CE", "PE"}).
+		Order("strike ASC").
+		Find(&instruments).
+		Error
+	return instruments, err
+}
@@ -0,0 +1,75 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InstrumentTagRepository is the database repository for the instrument tags
+type InstrumentTagRepository struct {
+	DB *gorm.DB
+}
+
+// NewInstrumentTagRepository creates a new instrument tag repository
+func NewInstrumentTagRepository(db *gorm.DB) *InstrumentTagRepository {
+	return &InstrumentTagRepository{DB: db}
+}
+
+// InsertInstrumentTags tags the instruments, instruments already carrying the tag are kept
+func (r *InstrumentTagRepository) InsertInstrumentTags(tags []models.InstrumentTagModel) (int64, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(tags, 1000)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to insert instrument tags: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// DeleteInstrumentTags removes the tag of the user from the instruments, from all its instruments when none are
+// given. It returns the number removed
+func (r *InstrumentTagRepository) DeleteInstrumentTags(userId, tag string, instruments []string) (int64, error) {
+	query := r.DB.Where("user_id = ? AND tag = ?", userId, tag)
+	if len(instruments) > 0 {
+		query = query.Where("instrument IN ?", instruments)
+	}
+	result := query.Delete(&models.InstrumentTagModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete instrument tags: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetInstrumentTagSummaries gets the tags of the user and the global tags with their number of instruments
+func (r *InstrumentTagRepository) GetInstrumentTagSummaries(userId string) ([]models.InstrumentTagSummary, error) {
+	var summaries []models.InstrumentTagSummary
+	err := r.DB.Model(&models.InstrumentTagModel{}).
+		Select("tag, user_id = '' AS global, COUNT(*) AS instruments").
+		Where("user_id IN ?", []string{userId, ""}).
+		Group("tag, user_id").
+		Order("tag, global").
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument tags: %v", err)
+	}
+	return summaries, nil
+}
+
+// GetTaggedInstruments gets the instruments carrying one of the tags of the user or the global tags
+func (r *InstrumentTagRepository) GetTaggedInstruments(userId string, tags []string) ([]string, error) {
+	var instruments []string
+	err := r.DB.Model(&models.InstrumentTagModel{}).
+		Distinct("instrument").
+		Where("user_id IN ? AND tag IN ?", []string{userId, ""}, tags).
+		Order("instrument").
+		Pluck("instrument", &instruments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tagged instruments: %v", err)
+	}
+	return instruments, nil
+}
@@ -0,0 +1,77 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrJobActive is returned when a job is created while a job of the same name is pending or running
+var ErrJobActive = errors.New("job already active")
+
+// JobRepository is the database repository for background jobs
+type JobRepository struct {
+	DB *gorm.DB
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{DB: db}
+}
+
+// CreateJob creates a job, ErrJobActive if a job of the same name is active
+func (r *JobRepository) CreateJob(job *models.JobModel) error {
+	if err := r.DB.Create(job).Error; err != nil {
+		// unique_violation of the index of the active job names
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrJobActive
+		}
+		return fmt.Errorf("failed to create job: %v", err)
+	}
+	return nil
+}
+
+// UpdateJob updates the given columns of a job
+func (r *JobRepository) UpdateJob(id uint64, updates map[string]interface{}) error {
+	if err := r.DB.Model(&models.JobModel{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update job %d: %v", id, err)
+	}
+	return nil
+}
+
+// GetJob gets a job by id
+func (r *JobRepository) GetJob(id uint64) (*models.JobModel, error) {
+	var job models.JobModel
+	if err := r.DB.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetActiveJob gets the pending or running job with the given name
+func (r *JobRepository) GetActiveJob(name string) (*models.JobModel, error) {
+	var job models.JobModel
+	err := r.DB.Where("name = ? AND status IN ?", name, []string{models.JobStatusPending, models.JobStatusRunning}).
+		Order("id DESC").
+		First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FailActiveJobs marks all pending and running jobs as failed
+func (r *JobRepository) FailActiveJobs(message string) (int64, error) {
+	result := r.DB.Model(&models.JobModel{}).
+		Where("status IN ?", []string{models.JobStatusPending, models.JobStatusRunning}).
+		Updates(map[string]interface{}{"status": models.JobStatusFailed, "error": message})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to fail active jobs: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
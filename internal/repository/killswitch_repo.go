@@ -0,0 +1,76 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KillSwitchRepository is the database repository for the kill switches
+type KillSwitchRepository struct {
+	DB *gorm.DB
+}
+
+// NewKillSwitchRepository creates a new kill switch repository
+func NewKillSwitchRepository(db *gorm.DB) *KillSwitchRepository {
+	return &KillSwitchRepository{DB: db}
+}
+
+// GetKillSwitches gets the engaged kill switches
+func (r *KillSwitchRepository) GetKillSwitches() ([]models.KillSwitchModel, error) {
+	var killSwitches []models.KillSwitchModel
+	if err := r.DB.Order("scope").Find(&killSwitches).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kill switches: %v", err)
+	}
+	return killSwitches, nil
+}
+
+// EngageKillSwitch stores the engaged kill switch and its audit event in one transaction
+func (r *KillSwitchRepository) EngageKillSwitch(killSwitch *models.KillSwitchModel, event *models.KillSwitchEventModel) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "scope"}},
+			DoUpdates: clause.AssignmentColumns([]string{"reason", "engaged_by", "engaged_at"}),
+		}).Create(killSwitch).Error
+		if err != nil {
+			return fmt.Errorf("failed to engage kill switch: %v", err)
+		}
+		if err := tx.Create(event).Error; err != nil {
+			return fmt.Errorf("failed to record kill switch event: %v", err)
+		}
+		return nil
+	})
+}
+
+// ReleaseKillSwitch removes the engaged kill switch and records its audit event in one transaction,
+// it returns false when the kill switch was not engaged
+func (r *KillSwitchRepository) ReleaseKillSwitch(event *models.KillSwitchEventModel) (bool, error) {
+	released := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("scope = ?", event.Scope).Delete(&models.KillSwitchModel{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to release kill switch: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		released = true
+		if err := tx.Create(event).Error; err != nil {
+			return fmt.Errorf("failed to record kill switch event: %v", err)
+		}
+		return nil
+	})
+	return released, err
+}
+
+// GetKillSwitchEvents gets the latest kill switch events, newest first
+func (r *KillSwitchRepository) GetKillSwitchEvents(limit int) ([]models.KillSwitchEventModel, error) {
+	var events []models.KillSwitchEventModel
+	if err := r.DB.Order("created_at DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kill switch events: %v", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,95 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// pruneBatchSize is the number of rows deleted per statement when pruning, short statements keep locks brief
+const pruneBatchSize = 10000
+
+// MaintenanceRepository is the database repository for the database housekeeping
+type MaintenanceRepository struct {
+	DB *gorm.DB
+}
+
+// NewMaintenanceRepository creates a new maintenance repository
+func NewMaintenanceRepository(db *gorm.DB) *MaintenanceRepository {
+	return &MaintenanceRepository{DB: db}
+}
+
+// GetTableSize returns the size in bytes of the table including its indexes and toast data
+func (r *MaintenanceRepository) GetTableSize(table string) (int64, error) {
+	var size int64
+	if err := r.DB.Raw("SELECT pg_total_relation_size(?::regclass)", table).Scan(&size).Error; err != nil {
+		return 0, fmt.Errorf("failed to get size of %s: %v", table, err)
+	}
+	return size, nil
+}
+
+// CountOlderThan returns the number of rows of the table whose column is older than the cutoff
+func (r *MaintenanceRepository) CountOlderThan(table, column string, cutoff interface{}) (int64, error) {
+	var count int64
+	stmt := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s < ?", table, column)
+	if err := r.DB.Raw(stmt, cutoff).Scan(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count rows of %s: %v", table, err)
+	}
+	return count, nil
+}
+
+// PruneOlderThan deletes the rows of the table whose column is older than the cutoff in batches,
+// returning the number of deleted rows. The cutoff is a time or a date string for the date columns
+func (r *MaintenanceRepository) PruneOlderThan(table, column string, cutoff interface{}) (int64, error) {
+	return r.PruneOlderThanWhere(table, column, "TRUE", cutoff)
+}
+
+// PruneOlderThanWhere deletes the rows of the table matching the SQL condition whose column is older than the
+// cutoff in batches, returning the number of deleted rows
+func (r *MaintenanceRepository) PruneOlderThanWhere(table, column, condition string, cutoff interface{}) (int64, error) {
+	stmt := fmt.Sprintf("DELETE FROM %[1]s WHERE ctid IN (SELECT ctid FROM %[1]s WHERE %[2]s < ? AND (%[4]s) LIMIT %[3]d)", table, column, pruneBatchSize, condition)
+	var total int64
+	for {
+		result := r.DB.Exec(stmt, cutoff)
+		if result.Error != nil {
+			return total, fmt.Errorf("failed to prune %s: %v", table, result.Error)
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < pruneBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// DumpOlderThanWhere returns a page of the rows of the table matching the SQL condition whose column is older
+// than the cutoff as a JSON array, ordered by the column and the primary key id, with the number of rows
+func (r *MaintenanceRepository) DumpOlderThanWhere(table, column, condition string, cutoff interface{}, offset, limit int) (json.RawMessage, int64, error) {
+	var dump struct {
+		Rows  string
+		Count int64
+	}
+	stmt := fmt.Sprintf("SELECT COALESCE(json_agg(t), '[]'::json)::text AS rows, count(*) AS count FROM "+
+		"(SELECT * FROM %s WHERE %s < ? AND (%s) ORDER BY %[2]s, id LIMIT ? OFFSET ?) t", table, column, condition)
+	if err := r.DB.Raw(stmt, cutoff, limit, offset).Scan(&dump).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to dump %s: %v", table, err)
+	}
+	return json.RawMessage(dump.Rows), dump.Count, nil
+}
+
+// VacuumAnalyze vacuums the table and refreshes its planner statistics
+func (r *MaintenanceRepository) VacuumAnalyze(table string) error {
+	if err := r.DB.Exec("VACUUM (ANALYZE) " + table).Error; err != nil {
+		return fmt.Errorf("failed to vacuum %s: %v", table, err)
+	}
+	return nil
+}
+
+// Reindex rebuilds the indexes of the table without blocking writes
+func (r *MaintenanceRepository) Reindex(table string) error {
+	if err := r.DB.Exec("REINDEX TABLE CONCURRENTLY " + table).Error; err != nil {
+		return fmt.Errorf("failed to reindex %s: %v", table, err)
+	}
+	return nil
+}
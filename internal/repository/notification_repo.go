@@ -0,0 +1,60 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationRepository is the database repository for the notification preferences
+type NotificationRepository struct {
+	DB *gorm.DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{DB: db}
+}
+
+// GetNotificationPreferences gets the notification preferences of a user
+func (r *NotificationRepository) GetNotificationPreferences(userID string) (*models.NotificationPreferencesModel, error) {
+	var preferences models.NotificationPreferencesModel
+	if err := r.DB.Where("user_id = ?", userID).First(&preferences).Error; err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// GetNotificationPreferencesByCategory gets the notification preferences of all users subscribed to the category
+func (r *NotificationRepository) GetNotificationPreferencesByCategory(category string) ([]models.NotificationPreferencesModel, error) {
+	var preferences []models.NotificationPreferencesModel
+	err := r.DB.Where("categories @> ?::jsonb", fmt.Sprintf("[%q]", category)).Find(&preferences).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %v", err)
+	}
+	return preferences, nil
+}
+
+// UpsertNotificationPreferences upserts the notification preferences of a user
+func (r *NotificationRepository) UpsertNotificationPreferences(preferences *models.NotificationPreferencesModel) error {
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"telegram_chat_id", "webhook_url", "email", "categories", "updated_at"}),
+	}).Create(preferences).Error
+	if err != nil {
+		return fmt.Errorf("failed to upsert notification preferences: %v", err)
+	}
+	return nil
+}
+
+// DeleteNotificationPreferences deletes the notification preferences of a user
+func (r *NotificationRepository) DeleteNotificationPreferences(userID string) (int64, error) {
+	result := r.DB.Where("user_id = ?", userID).Delete(&models.NotificationPreferencesModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete notification preferences: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
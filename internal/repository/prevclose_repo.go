@@ -0,0 +1,75 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PrevCloseRepository is the database repository for previous closes
+type PrevCloseRepository struct {
+	DB *gorm.DB
+}
+
+// NewPrevCloseRepository creates a new previous close repository
+func NewPrevCloseRepository(db *gorm.DB) *PrevCloseRepository {
+	return &PrevCloseRepository{DB: db}
+}
+
+// InsertPrevCloses inserts previous closes, tick derived closes never overwrite existing rows
+func (r *PrevCloseRepository) InsertPrevCloses(prevCloses []models.PrevCloseModel) (int64, error) {
+	if len(prevCloses) == 0 {
+		return 0, nil
+	}
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&prevCloses)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to insert batch into %s: %v", models.PrevClosesTableName, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// UpsertPrevCloses upserts previous closes, used for official sources
+func (r *PrevCloseRepository) UpsertPrevCloses(prevCloses []models.PrevCloseModel) (int64, error) {
+	if len(prevCloses) == 0 {
+		return 0, nil
+	}
+	result := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instrument"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"instrument_token", "prev_close", "source", "updated_at"}),
+	}).Create(&prevCloses)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to upsert batch into %s: %v", models.PrevClosesTableName, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetAllLatestPrevCloses gets the most recent previous close of every instrument dated up to the date
+func (r *PrevCloseRepository) GetAllLatestPrevCloses(upToDate string) ([]models.PrevCloseModel, error) {
+	var prevCloses []models.PrevCloseModel
+	err := r.DB.Model(&models.PrevCloseModel{}).
+		Select("DISTINCT ON (instrument) *").
+		Where("date <= ?", upToDate).
+		Order("instrument, date DESC").
+		Find(&prevCloses).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous closes: %v", err)
+	}
+	return prevCloses, nil
+}
+
+// GetLatestPrevCloses gets the most recent previous close for each of the given instruments
+func (r *PrevCloseRepository) GetLatestPrevCloses(instruments []string) ([]models.PrevCloseModel, error) {
+	var prevCloses []models.PrevCloseModel
+	err := r.DB.Model(&models.PrevCloseModel{}).
+		Select("DISTINCT ON (instrument) *").
+		Where("instrument IN ?", instruments).
+		Order("instrument, date DESC").
+		Find(&prevCloses).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous closes: %v", err)
+	}
+	return prevCloses, nil
+}
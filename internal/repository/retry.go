@@ -0,0 +1,78 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// Retry policy for transient Postgres errors in the write path
+const (
+	pgRetryMaxAttempts    = 4
+	pgRetryInitialBackoff = 100 * time.Millisecond
+	pgRetryMaxBackoff     = 2 * time.Second
+)
+
+// retryablePgErrorCodes are the SQLSTATE codes of errors which may succeed when retried
+var retryablePgErrorCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// withRetry runs fn, retrying it with capped exponential backoff while it fails with a transient error,
+// fn must be safe to repeat, i.e. a single statement or a whole transaction
+func withRetry(operation string, fn func() error) error {
+	backoff := pgRetryInitialBackoff
+	var err error
+	for attempt := 1; attempt <= pgRetryMaxAttempts; attempt++ {
+		err = fn()
+		if err == nil || !isRetryablePgError(err) || attempt == pgRetryMaxAttempts {
+			return err
+		}
+		zaplogger.Warn("Retrying transient Postgres error", zaplogger.Fields{
+			"operation": operation,
+			"attempt":   attempt,
+			"backoff":   backoff.String(),
+			"error":     err.Error(),
+		})
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > pgRetryMaxBackoff {
+			backoff = pgRetryMaxBackoff
+		}
+	}
+	return err
+}
+
+// isRetryablePgError returns true for connection failures and for errors caused by concurrent transactions
+func isRetryablePgError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// class 08 is connection_exception
+		return strings.HasPrefix(pgErr.Code, "08") || retryablePgErrorCodes[pgErr.Code]
+	}
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	message := err.Error()
+	return strings.Contains(message, "connection reset by peer") || strings.Contains(message, "broken pipe")
+}
@@ -0,0 +1,104 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RiskRepository is the database repository for the risk limits
+type RiskRepository struct {
+	DB *gorm.DB
+}
+
+// NewRiskRepository creates a new risk repository
+func NewRiskRepository(db *gorm.DB) *RiskRepository {
+	return &RiskRepository{DB: db}
+}
+
+// GetRiskLimits gets the limits of the user, gorm.ErrRecordNotFound when the user has none
+func (r *RiskRepository) GetRiskLimits(userID string) (*models.RiskLimitsModel, error) {
+	var limits models.RiskLimitsModel
+	if err := r.DB.Where("user_id = ?", userID).First(&limits).Error; err != nil {
+		return nil, err
+	}
+	return &limits, nil
+}
+
+// UpsertRiskLimits inserts or replaces the limits of the user
+func (r *RiskRepository) UpsertRiskLimits(limits *models.RiskLimitsModel) error {
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_orders_per_day", "max_quantity_per_instrument", "max_daily_loss", "updated_at"}),
+	}).Create(limits).Error
+	if err != nil {
+		return fmt.Errorf("failed to save risk limits: %v", err)
+	}
+	return nil
+}
+
+// DeleteRiskLimits deletes the limits of the user
+func (r *RiskRepository) DeleteRiskLimits(userID string) (int64, error) {
+	result := r.DB.Where("user_id = ?", userID).Delete(&models.RiskLimitsModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete risk limits: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetRiskUsage gets the usage of the user on the date by instrument
+func (r *RiskRepository) GetRiskUsage(userID, date string) ([]models.RiskUsageModel, error) {
+	return getRiskUsage(r.DB, userID, date)
+}
+
+// AddRiskUsage adds the orders, the quantity and the P&L to the usage of the user in the instrument on the date
+func (r *RiskRepository) AddRiskUsage(usage models.RiskUsageModel) error {
+	return addRiskUsage(r.DB, usage)
+}
+
+// ReserveRiskUsage adds the usage if check accepts the usage of the day so far, the checks of a user are
+// serialised by an advisory lock so concurrent orders can not both pass a limit
+func (r *RiskRepository) ReserveRiskUsage(usage models.RiskUsageModel, check func([]models.RiskUsageModel) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", models.RiskUsageTableName+":"+usage.UserID).Error; err != nil {
+			return fmt.Errorf("failed to lock risk usage: %v", err)
+		}
+		current, err := getRiskUsage(tx, usage.UserID, usage.Date)
+		if err != nil {
+			return err
+		}
+		if err := check(current); err != nil {
+			return err
+		}
+		return addRiskUsage(tx, usage)
+	})
+}
+
+// getRiskUsage gets the usage of the user on the date by instrument
+func getRiskUsage(tx *gorm.DB, userID, date string) ([]models.RiskUsageModel, error) {
+	var usage []models.RiskUsageModel
+	if err := tx.Where("user_id = ? AND date = ?", userID, date).Order("instrument").Find(&usage).Error; err != nil {
+		return nil, fmt.Errorf("failed to get risk usage: %v", err)
+	}
+	return usage, nil
+}
+
+// addRiskUsage adds the usage to the stored usage of the user in the instrument on the date
+func addRiskUsage(tx *gorm.DB, usage models.RiskUsageModel) error {
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "date"}, {Name: "instrument"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"orders":     gorm.Expr(models.RiskUsageTableName + ".orders + EXCLUDED.orders"),
+			"quantity":   gorm.Expr(models.RiskUsageTableName + ".quantity + EXCLUDED.quantity"),
+			"pnl":        gorm.Expr(models.RiskUsageTableName + ".pnl + EXCLUDED.pnl"),
+			"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+		}),
+	}).Create(&usage).Error
+	if err != nil {
+		return fmt.Errorf("failed to add risk usage: %v", err)
+	}
+	return nil
+}
@@ -52,6 +52,44 @@ func (r *SessionRepository) GetSessionByEnctoken(enctoken string) (*models.Sessi
 	return &session, nil
 }
 
+// GetAllSessions gets all stored sessions
+func (r *SessionRepository) GetAllSessions() ([]models.SessionModel, error) {
+	var sessions []models.SessionModel
+	if err := r.DB.Order("user_id").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %v", err)
+	}
+	return sessions, nil
+}
+
+// InsertSessionCheck inserts a session validity transition
+func (r *SessionRepository) InsertSessionCheck(check *models.SessionCheckModel) error {
+	if err := r.DB.Create(check).Error; err != nil {
+		return fmt.Errorf("failed to insert session check: %v", err)
+	}
+	return nil
+}
+
+// GetLastSessionChecks gets the last recorded validity transition of each user
+func (r *SessionRepository) GetLastSessionChecks() ([]models.SessionCheckModel, error) {
+	var checks []models.SessionCheckModel
+	err := r.DB.Raw(fmt.Sprintf("SELECT DISTINCT ON (user_id) * FROM %s ORDER BY user_id, checked_at DESC", models.SessionChecksTableName)).
+		Scan(&checks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last session checks: %v", err)
+	}
+	return checks, nil
+}
+
+// GetSessionChecks gets the validity transitions of the user, newest first
+func (r *SessionRepository) GetSessionChecks(userId string, limit int) ([]models.SessionCheckModel, error) {
+	var checks []models.SessionCheckModel
+	err := r.DB.Where("user_id = ?", userId).Order("checked_at DESC").Limit(limit).Find(&checks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session checks: %v", err)
+	}
+	return checks, nil
+}
+
 // DeleteSession deletes a session
 func (r *SessionRepository) DeleteSession(userId, enctoken string) (int64, error) {
 	// Delete the session
@@ -0,0 +1,89 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StorageRepository is the database repository for the storage statistics
+type StorageRepository struct {
+	DB *gorm.DB
+}
+
+// NewStorageRepository creates a new storage repository
+func NewStorageRepository(db *gorm.DB) *StorageRepository {
+	return &StorageRepository{DB: db}
+}
+
+// GetDatabaseSize returns the size in bytes of the current database
+func (r *StorageRepository) GetDatabaseSize() (int64, error) {
+	var size int64
+	if err := r.DB.Raw("SELECT pg_database_size(current_database())").Scan(&size).Error; err != nil {
+		return 0, fmt.Errorf("failed to get database size: %v", err)
+	}
+	return size, nil
+}
+
+// GetTableSizes returns the sizes of the tables in the current schema, largest first
+func (r *StorageRepository) GetTableSizes() ([]models.StorageTable, error) {
+	var tables []models.StorageTable
+	err := r.DB.Raw(`SELECT c.relname AS "table",
+			pg_table_size(c.oid) AS table_bytes,
+			pg_indexes_size(c.oid) AS index_bytes,
+			pg_total_relation_size(c.oid) AS total_bytes,
+			GREATEST(c.reltuples, 0)::bigint AS rows
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND n.nspname = current_schema()
+		ORDER BY total_bytes DESC`).Scan(&tables).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table sizes: %v", err)
+	}
+	return tables, nil
+}
+
+// UpsertStorageSnapshots inserts or replaces the table size snapshots of the day
+func (r *StorageRepository) UpsertStorageSnapshots(snapshots []models.StorageSnapshotModel) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "date"}, {Name: "table_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"table_bytes", "index_bytes", "total_bytes", "rows", "updated_at"}),
+	}).Create(&snapshots).Error
+	if err != nil {
+		return fmt.Errorf("failed to upsert storage snapshots: %v", err)
+	}
+	return nil
+}
+
+// GetStorageSnapshots gets the table size snapshots of the date
+func (r *StorageRepository) GetStorageSnapshots(date string) ([]models.StorageSnapshotModel, error) {
+	var snapshots []models.StorageSnapshotModel
+	if err := r.DB.Where("date = ?", date).Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("failed to get storage snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+// GetOldestStorageSnapshotDate gets the date of the oldest snapshot on or after the date, blank when there is none
+func (r *StorageRepository) GetOldestStorageSnapshotDate(since string) (string, error) {
+	var date string
+	err := r.DB.Model(&models.StorageSnapshotModel{}).
+		Select("COALESCE(MIN(date), '')").
+		Where("date >= ?", since).
+		Scan(&date).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to get oldest storage snapshot: %v", err)
+	}
+	return date, nil
+}
+
+// GetDBMetrics returns the connection pool stats and the statements run per table since the start
+func (r *StorageRepository) GetDBMetrics() (models.DBMetrics, error) {
+	return GetDBMetrics(r.DB)
+}
@@ -0,0 +1,48 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StreamUsageRepository is the database repository for stream usage
+type StreamUsageRepository struct {
+	DB *gorm.DB
+}
+
+// NewStreamUsageRepository creates a new stream usage repository
+func NewStreamUsageRepository(db *gorm.DB) *StreamUsageRepository {
+	return &StreamUsageRepository{DB: db}
+}
+
+// AddStreamUsage adds the usage to the day's usage of the user
+func (r *StreamUsageRepository) AddStreamUsage(usage models.StreamUsageModel) error {
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "date"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"seconds":    gorm.Expr(models.StreamUsageTableName + ".seconds + EXCLUDED.seconds"),
+			"messages":   gorm.Expr(models.StreamUsageTableName + ".messages + EXCLUDED.messages"),
+			"bytes":      gorm.Expr(models.StreamUsageTableName + ".bytes + EXCLUDED.bytes"),
+			"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+		}),
+	}).Create(&usage).Error
+	if err != nil {
+		return fmt.Errorf("failed to add stream usage: %v", err)
+	}
+	return nil
+}
+
+// GetStreamUsage gets the usage of the user for the date, no usage is returned as zero usage
+func (r *StreamUsageRepository) GetStreamUsage(userID, date string) (models.StreamUsageModel, error) {
+	usage := models.StreamUsageModel{UserID: userID, Date: date}
+	err := r.DB.Where("user_id = ? AND date = ?", userID, date).First(&usage).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return usage, fmt.Errorf("failed to get stream usage: %v", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,46 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TickStatsRepository is the database repository for the tick statistics
+type TickStatsRepository struct {
+	DB *gorm.DB
+}
+
+// NewTickStatsRepository creates a new tick statistics repository
+func NewTickStatsRepository(db *gorm.DB) *TickStatsRepository {
+	return &TickStatsRepository{DB: db}
+}
+
+// UpsertTickStats inserts or replaces the tick statistics of the day
+func (r *TickStatsRepository) UpsertTickStats(stats []models.TickStatsModel) error {
+	if len(stats) == 0 {
+		return nil
+	}
+	return withRetry("upsert tick stats", func() error {
+		err := r.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "date"}, {Name: "instrument_token"}},
+			DoUpdates: clause.AssignmentColumns([]string{"instrument", "ticks", "first_tick_at", "last_tick_at", "max_spread", "out_of_session_ticks", "updated_at"}),
+		}).CreateInBatches(stats, 1000).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert tick stats: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetTickStats gets the tick statistics of all instruments for the date
+func (r *TickStatsRepository) GetTickStats(date string) ([]models.TickStatsModel, error) {
+	var stats []models.TickStatsModel
+	if err := r.DB.Where("date = ?", date).Find(&stats).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tick stats: %v", err)
+	}
+	return stats, nil
+}
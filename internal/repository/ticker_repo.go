@@ -2,11 +2,14 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/nsvirk/moneybotsapi/internal/models"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -57,37 +60,131 @@ func (r *TickerRepository) TruncateTickerInstruments() (int64, error) {
 	return count, nil
 }
 
-// UpsertTickerInstruments upserts the instruments
-func (r *TickerRepository) UpsertTickerInstruments(userID string, instruments []models.InstrumentModel) (int64, int64, error) {
+// DeleteUnpinnedTickerInstruments deletes all ticker instruments which are not pinned
+func (r *TickerRepository) DeleteUnpinnedTickerInstruments() (int64, error) {
+	result := r.DB.Where("pinned = ?", false).Delete(&models.TickerInstrument{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete unpinned ticker instruments: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// SetTickerInstrumentsPinned pins or unpins the ticker instruments, metadata is only set when not nil
+func (r *TickerRepository) SetTickerInstrumentsPinned(userID string, instruments []string, pinned bool, metadata datatypes.JSON) (int64, error) {
+	updates := map[string]interface{}{"pinned": pinned, "updated_at": time.Now()}
+	if metadata != nil {
+		updates["metadata"] = metadata
+	}
+	result := r.DB.Model(&models.TickerInstrument{}).
+		Where("user_id = ? AND instrument IN ?", userID, instruments).
+		Updates(updates)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to update pinned ticker instruments: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// SetTickerInstrumentsPriority marks the instruments as priority or not, priority ticks are written ahead of the batch
+func (r *TickerRepository) SetTickerInstrumentsPriority(userID string, instruments []string, priority bool) (int64, error) {
+	result := r.DB.Model(&models.TickerInstrument{}).
+		Where("user_id = ? AND instrument IN ?", userID, instruments).
+		Updates(map[string]interface{}{"priority": priority, "updated_at": time.Now()})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to update ticker instruments priority: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// SetTickerInstrumentsMode sets the ticker mode of the instruments, a blank mode reverts them to the default mode
+func (r *TickerRepository) SetTickerInstrumentsMode(userID string, instruments []string, mode string) (int64, error) {
+	result := r.DB.Model(&models.TickerInstrument{}).
+		Where("user_id = ? AND instrument IN ?", userID, instruments).
+		Updates(map[string]interface{}{"mode": mode, "updated_at": time.Now()})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to update ticker instruments mode: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// tickerInstrumentsUpsertBatchSize is the number of rows per multi-row upsert statement
+const tickerInstrumentsUpsertBatchSize = 1000
+
+// UpsertTickerInstruments upserts the instruments in batched multi-row statements within one
+// transaction, returning the inserted and updated counts. A blank mode keeps the mode of existing rows
+func (r *TickerRepository) UpsertTickerInstruments(userID string, instruments []models.InstrumentModel, mode string) (int64, int64, error) {
+	// a statement cannot touch the same row twice, keep the last occurrence of each instrument
+	tokens := make(map[string]uint32, len(instruments))
+	keys := make([]string, 0, len(instruments))
+	for _, instrument := range instruments {
+		key := instrument.Exchange + ":" + instrument.Tradingsymbol
+		if _, ok := tokens[key]; !ok {
+			keys = append(keys, key)
+		}
+		tokens[key] = uint32(instrument.InstrumentToken)
+	}
+
 	var insertedCount int64
 	var updatedCount int64
 
-	for _, instrument := range instruments {
-		result := r.DB.Clauses(clause.OnConflict{
-			Columns: []clause.Column{
-				{Name: "user_id"},
-				{Name: "instrument"},
-			},
-			DoUpdates: clause.AssignmentColumns([]string{"instrument_token", "updated_at"}),
-		}).Create(&models.TickerInstrument{
-			UserID:          userID,
-			Instrument:      instrument.Exchange + ":" + instrument.Tradingsymbol,
-			InstrumentToken: uint32(instrument.InstrumentToken),
-			UpdatedAt:       time.Now(),
+	err := withRetry("UpsertTickerInstruments", func() error {
+		insertedCount, updatedCount = 0, 0
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			for i := 0; i < len(keys); i += tickerInstrumentsUpsertBatchSize {
+				end := i + tickerInstrumentsUpsertBatchSize
+				if end > len(keys) {
+					end = len(keys)
+				}
+				inserted, updated, err := upsertTickerInstruments(tx, userID, keys[i:end], tokens, mode)
+				if err != nil {
+					return err
+				}
+				insertedCount += inserted
+				updatedCount += updated
+			}
+			_, err := backfillInstrumentIDs(tx, models.TickerInstrumentsTableName, userID)
+			return err
 		})
+	})
+	if err != nil {
+		return 0, 0, err
+	}
 
-		if result.Error != nil {
-			return 0, 0, fmt.Errorf("error upserting instrument: %v", result.Error)
-		}
+	return insertedCount, updatedCount, nil
+}
+
+// upsertTickerInstruments upserts one batch, xmax is 0 only for rows inserted by the statement
+func upsertTickerInstruments(tx *gorm.DB, userID string, keys []string, tokens map[string]uint32, mode string) (int64, int64, error) {
+	valueStrings := make([]string, 0, len(keys))
+	valueArgs := make([]interface{}, 0, len(keys)*6)
+
+	now := time.Now()
+	for _, key := range keys {
+		valueStrings = append(valueStrings, "(?, ?, ?, ?, ?, ?)")
+		valueArgs = append(valueArgs, userID, key, tokens[key], mode, now, now)
+	}
 
-		if result.RowsAffected == 1 {
+	stmt := fmt.Sprintf("INSERT INTO %[1]s (user_id, instrument, instrument_token, mode, created_at, updated_at) VALUES %[2]s "+
+		"ON CONFLICT (user_id, instrument) DO UPDATE SET instrument_token = EXCLUDED.instrument_token, "+
+		"mode = COALESCE(NULLIF(EXCLUDED.mode, ''), %[1]s.mode), updated_at = EXCLUDED.updated_at "+
+		"RETURNING (xmax = 0) AS inserted",
+		models.TickerInstrumentsTableName,
+		strings.Join(valueStrings, ","),
+	)
+
+	var results []struct {
+		Inserted bool
+	}
+	if err := tx.Raw(stmt, valueArgs...).Scan(&results).Error; err != nil {
+		return 0, 0, fmt.Errorf("error upserting instruments: %w", err)
+	}
+
+	var insertedCount int64
+	for _, result := range results {
+		if result.Inserted {
 			insertedCount++
-		} else {
-			updatedCount++
 		}
 	}
-
-	return insertedCount, updatedCount, nil
+	return insertedCount, int64(len(results)) - insertedCount, nil
 }
 
 // GetTickerInstruments gets the ticker instruments
@@ -110,18 +207,171 @@ func (r *TickerRepository) DeleteTickerInstruments(userID string, instruments []
 	return result.RowsAffected, result.Error
 }
 
+// GetAllTickerInstrumentCount gets the ticker instrument count across all users
+func (r *TickerRepository) GetAllTickerInstrumentCount() (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.TickerInstrument{}).Count(&count).Error
+	return count, err
+}
+
+// GetTickerInstrumentMismatches gets the ticker instruments whose token differs from the instruments table
+// or which are no longer present in the instruments table
+func (r *TickerRepository) GetTickerInstrumentMismatches() ([]models.TickerInstrumentMismatch, error) {
+	var mismatches []models.TickerInstrumentMismatch
+	err := r.DB.Table(models.TickerInstrumentsTableName + " AS ti").
+		Select("ti.user_id, ti.instrument, ti.instrument_token AS ticker_token, COALESCE(i.instrument_token, 0) AS instrument_token, ti.pinned").
+		Joins("LEFT JOIN " + models.InstrumentsTableName + " AS i ON i.exchange || ':' || i.tradingsymbol = ti.instrument").
+		Where("i.instrument_token IS NULL OR i.instrument_token <> ti.instrument_token").
+		Order("ti.user_id, ti.instrument").
+		Scan(&mismatches).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticker instrument mismatches: %v", err)
+	}
+	return mismatches, nil
+}
+
+// UpdateTickerInstrumentToken updates the token of a ticker instrument
+func (r *TickerRepository) UpdateTickerInstrumentToken(userID, instrument string, instrumentToken uint32) error {
+	return r.DB.Model(&models.TickerInstrument{}).
+		Where("user_id = ? AND instrument = ?", userID, instrument).
+		Updates(map[string]interface{}{"instrument_token": instrumentToken, "updated_at": time.Now()}).Error
+}
+
 // --------------------------------------------
 // TickerData func's grouped together
 // --------------------------------------------
 // TruncateTickerData truncates the ticker data
 func (r *TickerRepository) TruncateTickerData() error {
-	result := r.DB.Exec(fmt.Sprintf("TRUNCATE TABLE %s", models.TickerDataTableName))
-	if result.Error != nil {
-		return fmt.Errorf("failed to truncate table %s: %v", models.TickerDataTableName, result.Error)
+	for _, table := range []string{models.TickerDataTableName, models.IndexTicksTableName} {
+		result := r.DB.Exec(fmt.Sprintf("TRUNCATE TABLE %s", table))
+		if result.Error != nil {
+			return fmt.Errorf("failed to truncate table %s: %v", table, result.Error)
+		}
 	}
 	return nil
 }
 
+// GetTickerDataByInstrument gets the ticker data of an instrument, an index is read from the index ticks
+func (r *TickerRepository) GetTickerDataByInstrument(instrument string) (models.TickerData, error) {
+	var tickerData models.TickerData
+	err := r.DB.Where("instrument = ?", instrument).First(&tickerData).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return tickerData, err
+	}
+	var indexTick models.IndexTickModel
+	if err := r.DB.Where("instrument = ?", instrument).First(&indexTick).Error; err != nil {
+		return tickerData, err
+	}
+	return indexTick.ToTickerData(), nil
+}
+
+// GetTickerDataByInstruments gets the ticker data of the instruments, indices are read from the index ticks
+func (r *TickerRepository) GetTickerDataByInstruments(instruments []string) ([]models.TickerData, error) {
+	var tickerData []models.TickerData
+	err := r.DB.Where("instrument IN ?", instruments).Find(&tickerData).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticker data: %v", err)
+	}
+	indexTicks, err := r.GetIndexTicksByInstruments(instruments)
+	if err != nil {
+		return nil, err
+	}
+	for _, indexTick := range indexTicks {
+		tickerData = append(tickerData, indexTick.ToTickerData())
+	}
+	return tickerData, nil
+}
+
+// GetTickerDataLastPrices gets the last price of every instrument in the ticker data and of every index
+func (r *TickerRepository) GetTickerDataLastPrices() ([]models.TickerData, error) {
+	var tickerData []models.TickerData
+	err := r.DB.Model(&models.TickerData{}).
+		Select("instrument, instrument_token, last_price, timestamp").
+		Where("last_price > 0").
+		Find(&tickerData).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticker data last prices: %v", err)
+	}
+
+	var indexTicks []models.IndexTickModel
+	err = r.DB.Select("instrument, instrument_token, last_price, timestamp").
+		Where("last_price > 0").
+		Find(&indexTicks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index ticks last prices: %v", err)
+	}
+	for _, indexTick := range indexTicks {
+		tickerData = append(tickerData, models.TickerData{
+			Instrument:      indexTick.Instrument,
+			InstrumentToken: indexTick.InstrumentToken,
+			IsIndex:         true,
+			LastPrice:       indexTick.LastPrice,
+			Timestamp:       indexTick.Timestamp,
+		})
+	}
+	return tickerData, nil
+}
+
+// GetIndexTick gets the latest tick of an index
+func (r *TickerRepository) GetIndexTick(instrument string) (models.IndexTickModel, error) {
+	var indexTick models.IndexTickModel
+	err := r.DB.Where("instrument = ?", instrument).First(&indexTick).Error
+	return indexTick, err
+}
+
+// GetIndexTicksByInstruments gets the latest ticks of the indices
+func (r *TickerRepository) GetIndexTicksByInstruments(instruments []string) ([]models.IndexTickModel, error) {
+	var indexTicks []models.IndexTickModel
+	if err := r.DB.Where("instrument IN ?", instruments).Find(&indexTicks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get index ticks: %v", err)
+	}
+	return indexTicks, nil
+}
+
+// UpsertIndexTicks upserts the latest ticks of the indices, a tick without an instrument id keeps the stored one
+func (r *TickerRepository) UpsertIndexTicks(indexTicks []models.IndexTickModel) error {
+	if len(indexTicks) == 0 {
+		return nil
+	}
+
+	latest := make(map[uint32]models.IndexTickModel, len(indexTicks))
+	for _, indexTick := range indexTicks {
+		if existing, ok := latest[indexTick.InstrumentToken]; !ok || existing.UpdatedAt.Before(indexTick.UpdatedAt) {
+			latest[indexTick.InstrumentToken] = indexTick
+		}
+	}
+	unique := make([]models.IndexTickModel, 0, len(latest))
+	for _, indexTick := range latest {
+		unique = append(unique, indexTick)
+	}
+
+	return withRetry("UpsertIndexTicks", func() error {
+		err := r.DB.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "instrument_token"}},
+			DoUpdates: append(
+				clause.AssignmentColumns([]string{"instrument", "mode", "timestamp", "last_price", "open", "high", "low", "close", "net_change", "out_of_session", "updated_at"}),
+				clause.Assignment{
+					Column: clause.Column{Name: "instrument_id"},
+					Value:  gorm.Expr("COALESCE(NULLIF(excluded.instrument_id, 0), " + models.IndexTicksTableName + ".instrument_id)"),
+				},
+			),
+		}).Create(&unique).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert index ticks: %w", err)
+		}
+		return nil
+	})
+}
+
+// tickerDataUpdates are the columns updated by a ticker data upsert, a tick without an instrument id keeps the stored one
+var tickerDataUpdates = append(
+	clause.AssignmentColumns([]string{"timestamp", "last_trade_time", "last_price", "last_traded_quantity", "total_buy_quantity", "total_sell_quantity", "volume", "average_price", "oi", "oi_day_high", "oi_day_low", "net_change", "ohlc", "depth", "open", "high", "low", "close", "bid_price", "bid_quantity", "ask_price", "ask_quantity", "out_of_session", "updated_at"}),
+	clause.Assignment{
+		Column: clause.Column{Name: "instrument_id"},
+		Value:  gorm.Expr("COALESCE(NULLIF(excluded.instrument_id, 0), " + models.TickerDataTableName + ".instrument_id)"),
+	},
+)
+
 // UpsertTickerData upserts the ticker data
 func (r *TickerRepository) UpsertTickerData(tickerData []models.TickerData) error {
 	if len(tickerData) == 0 {
@@ -140,19 +390,21 @@ func (r *TickerRepository) UpsertTickerData(tickerData []models.TickerData) erro
 		uniqueTickerData = append(uniqueTickerData, data)
 	}
 
-	// upsert in each field
-	err := r.DB.Transaction(func(tx *gorm.DB) error {
-		for _, data := range uniqueTickerData {
-			result := tx.Clauses(clause.OnConflict{
-				Columns:   []clause.Column{{Name: "instrument_token"}},
-				DoUpdates: clause.AssignmentColumns([]string{"timestamp", "last_trade_time", "last_price", "last_traded_quantity", "total_buy_quantity", "total_sell_quantity", "volume", "average_price", "oi", "oi_day_high", "oi_day_low", "net_change", "ohlc", "depth", "updated_at"}),
-			}).Create(&data)
-
-			if result.Error != nil {
-				return fmt.Errorf("failed to upsert ticker data for instrument %s: %v", data.Instrument, result.Error)
+	// upsert in each field, the transaction is retried as a whole on transient errors
+	err := withRetry("UpsertTickerData", func() error {
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			for _, data := range uniqueTickerData {
+				result := tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "instrument_token"}},
+					DoUpdates: tickerDataUpdates,
+				}).Create(&data)
+
+				if result.Error != nil {
+					return fmt.Errorf("failed to upsert ticker data for instrument %s: %w", data.Instrument, result.Error)
+				}
 			}
-		}
-		return nil
+			return nil
+		})
 	})
 
 	if err != nil {
@@ -203,6 +455,34 @@ func (r *TickerRepository) Fatal(eventType, message string) error {
 	return r.log(models.FATAL, eventType, message)
 }
 
+// GetTickerLogs gets the ticker logs matching the filter, newest first
+func (r *TickerRepository) GetTickerLogs(filter models.TickerLogFilter) ([]models.TickerLog, error) {
+	var logs []models.TickerLog
+	query := r.DB.Model(&models.TickerLog{})
+	if len(filter.Levels) > 0 {
+		query = query.Where("level IN ?", filter.Levels)
+	}
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.Message != "" {
+		query = query.Where("message ILIKE ?", "%"+filter.Message+"%")
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp <= ?", filter.To)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+	if err := query.Order("id DESC").Limit(filter.Limit).Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get ticker logs: %v", err)
+	}
+	return logs, nil
+}
+
 // --------------------------------------------
 // Other funcs
 // --------------------------------------------
@@ -0,0 +1,42 @@
+// Package repository contains the repository layer for the Moneybots API
+package repository
+
+import (
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserRepository is the database repository for user data
+type UserRepository struct {
+	DB *gorm.DB
+}
+
+// NewUserRepository creates a new user repository
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{DB: db}
+}
+
+// GetUserSettings gets the settings of a user
+func (r *UserRepository) GetUserSettings(userID string) (*models.UserSettingsModel, error) {
+	var userSettings models.UserSettingsModel
+	err := r.DB.Where("user_id = ?", userID).First(&userSettings).Error
+	if err != nil {
+		return nil, err
+	}
+	return &userSettings, nil
+}
+
+// UpsertUserSettings upserts the settings of a user
+func (r *UserRepository) UpsertUserSettings(userSettings *models.UserSettingsModel) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"settings", "updated_at"}),
+	}).Create(userSettings).Error
+}
+
+// DeleteUserSettings deletes the settings of a user
+func (r *UserRepository) DeleteUserSettings(userID string) (int64, error) {
+	result := r.DB.Where("user_id = ?", userID).Delete(&models.UserSettingsModel{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,45 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// ActivityService is the service for the user activity events
+type ActivityService struct {
+	repo *repository.ActivityRepository
+}
+
+// NewActivityService creates a new activity service
+func NewActivityService(db *gorm.DB) *ActivityService {
+	return &ActivityService{
+		repo: repository.NewActivityRepository(db),
+	}
+}
+
+// Record stores an activity event, failures are logged and never fail the request
+func (s *ActivityService) Record(event models.ActivityEventModel, details map[string]interface{}) {
+	if len(details) > 0 {
+		if data, err := json.Marshal(details); err == nil {
+			event.Details = data
+		}
+	}
+	if err := s.repo.InsertActivityEvent(&event); err != nil {
+		zaplogger.Warn("Failed to record activity event", zaplogger.Fields{
+			"user_id": event.UserID,
+			"action":  event.Action,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// GetActivityEvents gets the activity events of a user since, optionally for an action
+func (s *ActivityService) GetActivityEvents(userID, action string, since time.Time, limit int) ([]models.ActivityEventModel, error) {
+	return s.repo.GetActivityEvents(userID, action, since, limit)
+}
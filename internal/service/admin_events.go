@@ -0,0 +1,108 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// adminEventsBufferSize is the number of events buffered for each admin console client
+const adminEventsBufferSize = 256
+
+// Admin event types
+const (
+	AdminEventJob     = "job"
+	AdminEventTicker  = "ticker"
+	AdminEventChannel = "channel"
+	AdminEventReply   = "reply"
+)
+
+// AdminEvent is an operational event sent to the admin console clients
+type AdminEvent struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// AdminEvents broadcasts the operational events, job runs, ticker state changes and channel utilization,
+// to the admin console clients. A client too slow to keep up misses events instead of blocking the publisher
+type AdminEvents struct {
+	mu          sync.RWMutex
+	subscribers map[string]chan []byte
+}
+
+var (
+	adminEvents     *AdminEvents
+	adminEventsOnce sync.Once
+)
+
+// GetAdminEvents returns the process wide admin event broadcaster
+func GetAdminEvents() *AdminEvents {
+	adminEventsOnce.Do(func() {
+		adminEvents = &AdminEvents{subscribers: make(map[string]chan []byte)}
+	})
+	return adminEvents
+}
+
+// Subscribe returns the channel the events are sent to for the client
+func (e *AdminEvents) Subscribe(clientID string) <-chan []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	channel := make(chan []byte, adminEventsBufferSize)
+	e.subscribers[clientID] = channel
+	return channel
+}
+
+// Unsubscribe stops sending events to the client
+func (e *AdminEvents) Unsubscribe(clientID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.subscribers, clientID)
+}
+
+// HasSubscribers returns true if an admin console client is connected
+func (e *AdminEvents) HasSubscribers() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.subscribers) > 0
+}
+
+// Publish sends the event to the connected clients, nothing is done without clients
+func (e *AdminEvents) Publish(eventType string, data interface{}) {
+	if !e.HasSubscribers() {
+		return
+	}
+	message := MarshalAdminEvent(eventType, data)
+	if message == nil {
+		return
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, channel := range e.subscribers {
+		select {
+		case channel <- message:
+		default:
+		}
+	}
+}
+
+// MarshalAdminEvent returns the JSON message of an event, nil if the data can not be marshaled
+func MarshalAdminEvent(eventType string, data interface{}) []byte {
+	message, err := json.Marshal(AdminEvent{
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		zaplogger.Error("failed to marshal admin event", zaplogger.Fields{
+			"type":  eventType,
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return message
+}
@@ -0,0 +1,266 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/state"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// backupLatestKey is the object holding the key of the latest backup
+const backupLatestKey = "latest"
+
+// backupEncryptedSuffix is the suffix of the keys of the encrypted backups, older backups are plain gzip
+const backupEncryptedSuffix = ".enc"
+
+// backupTables are the small tables holding the operational configuration, the sessions, the ticker
+// subscriptions, the user settings, preferences and instrument tags, the kill switches, limits and restrictions
+// and the state. The market data tables are rebuilt by the jobs and are not backed up
+var backupTables = []string{
+	models.SessionsTableName,
+	models.TickerInstrumentsTableName,
+	models.UserSettingsTableName,
+	models.NotificationPreferencesTableName,
+	models.KillSwitchesTableName,
+	models.RiskLimitsTableName,
+	models.DataDelaysTableName,
+	models.InstrumentBlacklistTableName,
+	models.InstrumentTagsTableName,
+	state.StateTableName,
+}
+
+// backupBlankedColumns are the credentials of the sessions, they are never written to the backup store. The
+// restored sessions need a new login, a restored session would have expired anyway
+var backupBlankedColumns = map[string][]string{
+	models.SessionsTableName: {"public_token", "kf_session", "enctoken", "hashed_password"},
+}
+
+// BackupService is the service for the backups of the critical tables to object storage
+type BackupService struct {
+	db   *gorm.DB
+	repo *repository.BackupRepository
+}
+
+// NewBackupService creates a new backup service
+func NewBackupService(db *gorm.DB) *BackupService {
+	return &BackupService{
+		db:   db,
+		repo: repository.NewBackupRepository(db),
+	}
+}
+
+// objectStore returns the configured backup store
+func (s *BackupService) objectStore() (*config.Config, objectStore, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.BackupURL == "" {
+		return nil, nil, fmt.Errorf("backup url is not configured")
+	}
+	store, err := newObjectStore(cfg, cfg.BackupURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, store, nil
+}
+
+// Backup writes the rows of the critical tables, read from one snapshot, as a gzipped JSON object encrypted with
+// the backup secret to the backup store and points the latest object at it. Old backups are expired by the
+// lifecycle rules of the store
+func (s *BackupService) Backup(ctx context.Context) (models.BackupResult, error) {
+	var result models.BackupResult
+	cfg, store, err := s.objectStore()
+	if err != nil {
+		return result, err
+	}
+
+	rows, counts, err := s.repo.DumpTables(backupTables, backupBlankedColumns)
+	if err != nil {
+		return result, err
+	}
+	backup := models.Backup{
+		Version:   cfg.APIVersion,
+		CreatedAt: time.Now().UTC(),
+		Tables:    rows,
+	}
+
+	var data bytes.Buffer
+	writer := gzip.NewWriter(&data)
+	if err := json.NewEncoder(writer).Encode(backup); err != nil {
+		return result, fmt.Errorf("failed to encode backup: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return result, fmt.Errorf("failed to compress backup: %v", err)
+	}
+	encrypted, err := encryptBackup(cfg.BackupEncryptionSecret, data.Bytes())
+	if err != nil {
+		return result, err
+	}
+
+	key := "moneybots-" + backup.CreatedAt.Format("20060102T150405Z") + ".json.gz" + backupEncryptedSuffix
+	if err := store.put(ctx, key, encrypted); err != nil {
+		return result, err
+	}
+	// the latest object is only moved once the backup is complete
+	if err := store.put(ctx, backupLatestKey, []byte(key)); err != nil {
+		return result, err
+	}
+
+	result = models.BackupResult{
+		Key:       key,
+		Location:  store.location(key),
+		CreatedAt: backup.CreatedAt,
+		Bytes:     len(encrypted),
+		Rows:      counts,
+	}
+	zaplogger.Info("Backup written", zaplogger.Fields{
+		"location": result.Location,
+		"bytes":    result.Bytes,
+	})
+	return result, nil
+}
+
+// Restore replaces the rows of the critical tables with those of the backup, the latest backup when the key
+// is blank. Tables missing from the backup are left untouched. A dry run only reads the backup and reports
+// the rows it would restore
+func (s *BackupService) Restore(ctx context.Context, key string, dryRun bool) (models.BackupResult, error) {
+	var result models.BackupResult
+	cfg, store, err := s.objectStore()
+	if err != nil {
+		return result, err
+	}
+
+	if key == "" {
+		latest, err := store.get(ctx, backupLatestKey)
+		if err != nil {
+			return result, err
+		}
+		key = strings.TrimSpace(string(latest))
+	}
+	data, err := store.get(ctx, key)
+	if err != nil {
+		return result, err
+	}
+	if strings.HasSuffix(key, backupEncryptedSuffix) {
+		if data, err = decryptBackup(cfg.BackupEncryptionSecret, data); err != nil {
+			return result, fmt.Errorf("failed to decrypt backup %s: %v", key, err)
+		}
+	}
+	backup, err := decodeBackup(data)
+	if err != nil {
+		return result, fmt.Errorf("failed to decode backup %s: %v", key, err)
+	}
+
+	result = models.BackupResult{
+		Key:       key,
+		Location:  store.location(key),
+		CreatedAt: backup.CreatedAt,
+		Bytes:     len(data),
+		Rows:      make(map[string]int64),
+	}
+	tables := make([]string, 0, len(backupTables))
+	for _, table := range backupTables {
+		rows, ok := backup.Tables[table]
+		if !ok {
+			zaplogger.Warn("Table missing from the backup, left untouched", zaplogger.Fields{"table": table, "key": key})
+			continue
+		}
+		var count []json.RawMessage
+		if err := json.Unmarshal(rows, &count); err != nil {
+			return result, fmt.Errorf("failed to decode backup table %s: %v", table, err)
+		}
+		tables = append(tables, table)
+		result.Rows[table] = int64(len(count))
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	// the state table is created by the services using it rather than by the migrations
+	if _, err := state.NewState(s.db); err != nil {
+		return result, fmt.Errorf("failed to create state table: %v", err)
+	}
+	restored, err := s.repo.RestoreTables(tables, backup.Tables)
+	if err != nil {
+		return result, err
+	}
+	result.Rows = restored
+	zaplogger.Info("Backup restored", zaplogger.Fields{
+		"location":   result.Location,
+		"created_at": result.CreatedAt,
+	})
+	return result, nil
+}
+
+// backupCipher returns the AES-GCM cipher of the hex encoded backup secret
+func backupCipher(secret string) (cipher.AEAD, error) {
+	key, err := hex.DecodeString(secret)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("backup encryption secret must be 64 hex characters")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptBackup encrypts the backup with the secret, the random nonce is prepended to the sealed data
+func encryptBackup(secret string, data []byte) ([]byte, error) {
+	gcm, err := backupCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate backup nonce: %v", err)
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decryptBackup decrypts a backup encrypted by encryptBackup, a wrong secret or altered data fail
+func decryptBackup(secret string, data []byte) ([]byte, error) {
+	gcm, err := backupCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("backup is too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+// decodeBackup decodes a gzipped backup
+func decodeBackup(data []byte) (models.Backup, error) {
+	var backup models.Backup
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return backup, err
+	}
+	defer reader.Close()
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return backup, err
+	}
+	if err := json.Unmarshal(decoded, &backup); err != nil {
+		return backup, err
+	}
+	return backup, nil
+}
@@ -0,0 +1,174 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"gorm.io/gorm"
+)
+
+// priceTick is the 0.05 tick the circuit limits are rounded to
+var priceTick = decimal.New(5, 2)
+
+// BandService is the service for the price bands
+type BandService struct {
+	repo          *repository.BandRepository
+	prevCloseRepo *repository.PrevCloseRepository
+	tickerRepo    *repository.TickerRepository
+}
+
+// NewBandService creates a new price band service
+func NewBandService(db *gorm.DB) *BandService {
+	return &BandService{
+		repo:          repository.NewBandRepository(db),
+		prevCloseRepo: repository.NewPrevCloseRepository(db),
+		tickerRepo:    repository.NewTickerRepository(db),
+	}
+}
+
+// UpdatePriceBands downloads the NSE daily price band file and replaces the stored bands
+func (s *BandService) UpdatePriceBands() (int64, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return 0, err
+	}
+	if cfg.PriceBandsURL == "" {
+		return 0, fmt.Errorf("price bands url is not configured")
+	}
+
+	records, err := fetchExchangeCSV(cfg.PriceBandsURL)
+	if err != nil {
+		return 0, err
+	}
+	bands, err := parsePriceBandRecords(records, MarketToday())
+	if err != nil {
+		return 0, err
+	}
+	if len(bands) == 0 {
+		return 0, fmt.Errorf("price bands file has no rows")
+	}
+	return s.repo.ReplacePriceBands(bands)
+}
+
+// parsePriceBandRecords parses the rows of the price band file, the columns are located by their header
+func parsePriceBandRecords(records [][]string, date string) ([]models.PriceBandModel, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := csvColumns(records[0])
+	for _, name := range []string{"symbol", "series", "band"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("price bands file has no %s column", name)
+		}
+	}
+
+	bands := make([]models.PriceBandModel, 0, len(records)-1)
+	seen := make(map[string]bool)
+	for _, record := range records[1:] {
+		if len(record) < len(records[0]) {
+			continue
+		}
+		symbol := strings.TrimSpace(record[columns["symbol"]])
+		series := strings.TrimSpace(record[columns["series"]])
+		band := strings.TrimSpace(record[columns["band"]])
+		if symbol == "" {
+			continue
+		}
+		instrument := nseInstrument(symbol, series)
+		if seen[instrument] {
+			continue
+		}
+		seen[instrument] = true
+
+		// "No Band" and other non numeric bands have no circuit limits
+		bandPercent, err := strconv.ParseFloat(band, 64)
+		if err != nil {
+			bandPercent = 0
+		}
+		bands = append(bands, models.PriceBandModel{
+			Instrument:  instrument,
+			Symbol:      symbol,
+			Series:      series,
+			Band:        band,
+			BandPercent: bandPercent,
+			Date:        date,
+		})
+	}
+	return bands, nil
+}
+
+// GetPriceBands gets the price bands of the given instruments with their circuit limits,
+// the limits are derived from the official previous close or the close of the last tick
+func (s *BandService) GetPriceBands(instruments []string) (map[string]models.PriceBand, error) {
+	bandModels, err := s.repo.GetPriceBands(instruments)
+	if err != nil {
+		return nil, err
+	}
+	if len(bandModels) == 0 {
+		return map[string]models.PriceBand{}, nil
+	}
+
+	bandInstruments := make([]string, 0, len(bandModels))
+	for _, band := range bandModels {
+		bandInstruments = append(bandInstruments, band.Instrument)
+	}
+	prevCloses, err := s.prevCloseRepo.GetLatestPrevCloses(bandInstruments)
+	if err != nil {
+		return nil, err
+	}
+	closes := make(map[string]decimal.Decimal, len(bandInstruments))
+	for _, prevClose := range prevCloses {
+		closes[prevClose.Instrument] = prevClose.PrevClose
+	}
+	tickerData, err := s.tickerRepo.GetTickerDataByInstruments(bandInstruments)
+	if err != nil {
+		return nil, err
+	}
+	for _, td := range tickerData {
+		if closes[td.Instrument].Sign() > 0 {
+			continue
+		}
+		if ohlc, err := td.GetOHLC(); err == nil {
+			closes[td.Instrument] = ohlc.Close
+		}
+	}
+
+	bands := make(map[string]models.PriceBand, len(bandModels))
+	for _, band := range bandModels {
+		bands[band.Instrument] = NewPriceBand(band, closes[band.Instrument])
+	}
+	return bands, nil
+}
+
+// NewPriceBand returns the price band with its circuit limits for the previous close
+func NewPriceBand(band models.PriceBandModel, prevClose decimal.Decimal) models.PriceBand {
+	lower, upper := PriceBandLimits(band.BandPercent, prevClose)
+	return models.PriceBand{
+		Instrument:        band.Instrument,
+		Series:            band.Series,
+		Band:              band.Band,
+		BandPercent:       band.BandPercent,
+		Date:              band.Date,
+		PreviousClose:     prevClose,
+		LowerCircuitLimit: lower,
+		UpperCircuitLimit: upper,
+	}
+}
+
+// PriceBandLimits returns the circuit limits of a band around the previous close rounded to the 0.05 tick,
+// both limits are zero without a band or a previous close
+func PriceBandLimits(bandPercent float64, prevClose decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	if bandPercent <= 0 || prevClose.Sign() <= 0 {
+		return decimal.Zero, decimal.Zero
+	}
+	band := prevClose.Mul(decimal.NewFromFloat(bandPercent)).Div(decimal.New(100, 0))
+	lower := prevClose.Sub(band).CeilTo(priceTick)
+	upper := prevClose.Add(band).FloorTo(priceTick)
+	return lower, upper
+}
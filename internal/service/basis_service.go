@@ -0,0 +1,111 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"gorm.io/gorm"
+)
+
+// indexSpotInstruments maps the underlying name of index futures to the index instrument
+var indexSpotInstruments = map[string]string{
+	"NIFTY":      "NSE:NIFTY 50",
+	"BANKNIFTY":  "NSE:NIFTY BANK",
+	"FINNIFTY":   "NSE:NIFTY FIN SERVICE",
+	"MIDCPNIFTY": "NSE:NIFTY MID SELECT",
+	"NIFTYNXT50": "NSE:NIFTY NEXT 50",
+	"SENSEX":     "BSE:SENSEX",
+	"BANKEX":     "BSE:BANKEX",
+	"SENSEX50":   "BSE:SENSEX50",
+}
+
+// BasisService is the service for the futures basis
+type BasisService struct {
+	repo           *repository.BasisRepository
+	tickerRepo     *repository.TickerRepository
+	instrumentRepo *repository.InstrumentRepository
+	cache          *InstrumentCache
+}
+
+// NewBasisService creates a new basis service
+func NewBasisService(db *gorm.DB) *BasisService {
+	return &BasisService{
+		repo:           repository.NewBasisRepository(db),
+		tickerRepo:     repository.NewTickerRepository(db),
+		instrumentRepo: repository.NewInstrumentRepository(db),
+		cache:          GetInstrumentCache(),
+	}
+}
+
+// SnapshotFuturesBasis stores the current basis of every ticking future whose spot is ticking too
+func (s *BasisService) SnapshotFuturesBasis() (int, error) {
+	if !s.cache.IsLoaded() {
+		if _, err := s.cache.Load(s.instrumentRepo); err != nil {
+			return 0, err
+		}
+	}
+
+	tickerData, err := s.tickerRepo.GetTickerDataLastPrices()
+	if err != nil {
+		return 0, err
+	}
+	lastPrices := make(map[string]decimal.Decimal, len(tickerData))
+	for _, td := range tickerData {
+		lastPrices[td.Instrument] = td.LastPrice
+	}
+
+	timestamp := time.Now().Truncate(time.Minute)
+	basis := make([]models.FuturesBasisModel, 0)
+	for instrument, futPrice := range lastPrices {
+		if !strings.HasPrefix(instrument, "NFO:") && !strings.HasPrefix(instrument, "BFO:") {
+			continue
+		}
+		future, ok := s.cache.GetBySymbol(instrument)
+		if !ok || future.InstrumentType != "FUT" {
+			continue
+		}
+		spotInstrument := futuresSpotInstrument(future)
+		spotPrice, ok := lastPrices[spotInstrument]
+		if !ok {
+			continue
+		}
+
+		basis = append(basis, models.FuturesBasisModel{
+			Name:           future.Name,
+			Expiry:         future.Expiry,
+			Timestamp:      timestamp,
+			FutInstrument:  instrument,
+			FutPrice:       futPrice,
+			SpotInstrument: spotInstrument,
+			SpotPrice:      spotPrice,
+			Basis:          futPrice.Sub(spotPrice).Round(2),
+			BasisPercent:   futPrice.Sub(spotPrice).Mul(decimal.New(100, 0)).Div(spotPrice),
+		})
+	}
+
+	if err := s.repo.InsertFuturesBasis(basis); err != nil {
+		return 0, err
+	}
+	return len(basis), nil
+}
+
+// GetFuturesBasis gets the basis snapshots of the futures of name between from and to
+func (s *BasisService) GetFuturesBasis(name, expiry string, from, to time.Time) ([]models.FuturesBasisModel, error) {
+	return s.repo.GetFuturesBasis(name, expiry, from, to)
+}
+
+// futuresSpotInstrument returns the spot instrument of a future, the index for index futures
+// and the equity on the cash segment of the exchange for stock futures
+func futuresSpotInstrument(future models.InstrumentModel) string {
+	if spot, ok := indexSpotInstruments[future.Name]; ok {
+		return spot
+	}
+	if future.Exchange == "BFO" {
+		return "BSE:" + future.Name
+	}
+	return "NSE:" + future.Name
+}
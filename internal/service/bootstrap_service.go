@@ -0,0 +1,100 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// BootstrapService is the service for the bootstrap bundle, it collects what clients used to fetch
+// with separate calls at startup
+type BootstrapService struct {
+	versionService    *VersionService
+	userService       *UserService
+	instrumentService *InstrumentService
+	indexRepo         *repository.IndexRepository
+	tickerRepo        *repository.TickerRepository
+	sessionRepo       *repository.SessionRepository
+}
+
+// NewBootstrapService creates a new bootstrap service
+func NewBootstrapService(db *gorm.DB, redisClient redis.UniversalClient) *BootstrapService {
+	return &BootstrapService{
+		versionService:    NewVersionService(db, redisClient),
+		userService:       NewUserService(db),
+		instrumentService: NewInstrumentService(db),
+		indexRepo:         repository.NewIndexRepository(db),
+		tickerRepo:        repository.NewTickerRepository(db),
+		sessionRepo:       repository.NewSessionRepository(db),
+	}
+}
+
+// GetBootstrap returns the bootstrap bundle of the user, the stream urls are relative to the base url
+func (s *BootstrapService) GetBootstrap(userID, baseURL string) (models.BootstrapBundle, error) {
+	bundle := models.BootstrapBundle{
+		Version:          s.versionService.GetVersionInfo(),
+		DataDelayMinutes: int(GetDataDelays().Delay(userID).Minutes()),
+	}
+
+	session, err := s.sessionRepo.GetSessionByUserId(userID)
+	if err != nil {
+		return bundle, err
+	}
+	bundle.Profile = models.BootstrapProfile{
+		UserID:        session.UserId,
+		UserName:      session.UserName,
+		UserShortname: session.UserShortname,
+		AvatarURL:     session.AvatarUrl,
+		LoginTime:     session.LoginTime,
+	}
+
+	if bundle.Settings, err = s.userService.GetUserSettings(userID); err != nil {
+		return bundle, err
+	}
+
+	stats, err := s.instrumentService.GetInstrumentStats()
+	if err != nil {
+		return bundle, err
+	}
+	now := MarketNow()
+	bundle.Exchanges = make([]models.BootstrapExchange, 0, len(tradingSessions))
+	for exchange := range tradingSessions {
+		bundle.Exchanges = append(bundle.Exchanges, models.BootstrapExchange{
+			Exchange:    exchange,
+			Phase:       GetMarketPhase(exchange, now),
+			Instruments: stats.Exchanges[exchange],
+		})
+	}
+	sort.Slice(bundle.Exchanges, func(i, j int) bool { return bundle.Exchanges[i].Exchange < bundle.Exchanges[j].Exchange })
+
+	indices, err := s.indexRepo.GetAllIndices()
+	if err != nil {
+		return bundle, err
+	}
+	bundle.Indices = make(map[string][]models.IndexModel)
+	for _, index := range indices {
+		bundle.Indices[index.Exchange] = append(bundle.Indices[index.Exchange], index)
+	}
+
+	tickerInstruments, err := s.tickerRepo.GetTickerInstruments(userID)
+	if err != nil {
+		return bundle, fmt.Errorf("failed to get ticker instruments: %v", err)
+	}
+	bundle.Subscriptions = make([]string, len(tickerInstruments))
+	for i, tickerInstrument := range tickerInstruments {
+		bundle.Subscriptions[i] = tickerInstrument.Instrument
+	}
+
+	baseURL = strings.TrimRight(baseURL, "/")
+	bundle.Streams = map[string]string{
+		"ticks": baseURL + "/stream/ticks",
+		"news":  baseURL + "/news/stream",
+	}
+	return bundle, nil
+}
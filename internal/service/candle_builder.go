@@ -0,0 +1,105 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// candleFlushInterval is how often the changed candles are written to the database
+const candleFlushInterval = time.Second
+
+// candleBar is the candle being built for an instrument
+type candleBar struct {
+	candle      models.CandleModel
+	startVolume uint32
+	lastVolume  uint32
+	dirty       bool
+}
+
+// candleBuilder builds 1 minute candles from the ticks, it is used by the tick processing goroutine only
+type candleBuilder struct {
+	bars   map[uint32]*candleBar
+	closed []models.CandleModel
+}
+
+// newCandleBuilder creates a new candle builder
+func newCandleBuilder() *candleBuilder {
+	return &candleBuilder{bars: make(map[uint32]*candleBar)}
+}
+
+// update adds the tick to the candle of its minute, a tick of a new minute closes the previous candle.
+// The volume of a candle is the change of the day's cumulative volume over the minute
+func (b *candleBuilder) update(tick kiteticker.Tick, instrument string) {
+	if tick.LastPrice <= 0 {
+		return
+	}
+	at := tick.Timestamp.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	minute := at.Truncate(time.Minute)
+	price := decimal.NewFromFloat(tick.LastPrice)
+
+	bar, ok := b.bars[tick.InstrumentToken]
+	if ok && minute.Before(bar.candle.Timestamp) {
+		// a late tick of a closed minute is dropped
+		return
+	}
+	if ok && minute.After(bar.candle.Timestamp) {
+		if bar.dirty {
+			b.closed = append(b.closed, bar.candle)
+		}
+		startVolume := bar.lastVolume
+		if tick.VolumeTraded < startVolume {
+			// the cumulative volume restarts with the trading day
+			startVolume = 0
+		}
+		bar = &candleBar{startVolume: startVolume, lastVolume: startVolume}
+		b.bars[tick.InstrumentToken] = bar
+		ok = false
+	}
+	if !ok {
+		if bar == nil {
+			bar = &candleBar{startVolume: tick.VolumeTraded, lastVolume: tick.VolumeTraded}
+			b.bars[tick.InstrumentToken] = bar
+		}
+		bar.candle = models.CandleModel{
+			InstrumentToken: tick.InstrumentToken,
+			InstrumentID:    GetInstrumentCache().GetInstrumentID(tick.InstrumentToken),
+			Timestamp:       minute,
+			Instrument:      instrument,
+			Open:            price,
+			High:            price,
+			Low:             price,
+			Source:          models.CandleSourceTick,
+		}
+	}
+
+	candle := &bar.candle
+	candle.High = decimal.Max(candle.High, price)
+	candle.Low = decimal.Min(candle.Low, price)
+	candle.Close = price
+	if tick.VolumeTraded >= bar.startVolume {
+		bar.lastVolume = tick.VolumeTraded
+		candle.Volume = int64(tick.VolumeTraded - bar.startVolume)
+	}
+	candle.OI = int64(tick.OI)
+	bar.dirty = true
+}
+
+// flush returns the closed candles and the open candles changed since the last flush
+func (b *candleBuilder) flush() []models.CandleModel {
+	candles := b.closed
+	b.closed = nil
+	for _, bar := range b.bars {
+		if bar.dirty {
+			candles = append(candles, bar.candle)
+			bar.dirty = false
+		}
+	}
+	return candles
+}
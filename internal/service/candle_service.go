@@ -0,0 +1,509 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"gorm.io/gorm"
+)
+
+// candleMaxRangeDays is the longest range of candles returned by a query
+const candleMaxRangeDays = 100
+
+// candleMaxIntervalMinutes is the longest intraday interval, a full day of the longest trading session
+const candleMaxIntervalMinutes = 24 * 60
+
+// candleCacheCapacity is the maximum number of resampled candle series kept in the cache
+const candleCacheCapacity = 256
+
+// candleWarmupIntervals are the intervals of the previous trading day's candles cached by the market warmup
+var candleWarmupIntervals = []CandleInterval{{Minutes: 5}, {Minutes: 15}, {Minutes: 60}}
+
+// ErrInvalidCandleRange is returned when the requested candle range is empty or too long
+var ErrInvalidCandleRange = errors.New("invalid candle range")
+
+// CandleInterval is a candle interval, either a number of minutes or a trading day
+type CandleInterval struct {
+	Minutes int
+	Daily   bool
+}
+
+// String returns the canonical name of the interval
+func (i CandleInterval) String() string {
+	switch {
+	case i.Daily:
+		return "day"
+	case i.Minutes%60 == 0:
+		return strconv.Itoa(i.Minutes/60) + "h"
+	default:
+		return strconv.Itoa(i.Minutes) + "m"
+	}
+}
+
+// ParseCandleInterval parses an interval such as minute, 3m, 75m, 1h or day
+func ParseCandleInterval(value string) (CandleInterval, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", "minute":
+		return CandleInterval{Minutes: 1}, nil
+	case "day", "1d":
+		return CandleInterval{Daily: true}, nil
+	}
+	unit := 1
+	switch {
+	case strings.HasSuffix(value, "minute"):
+		value = strings.TrimSuffix(value, "minute")
+	case strings.HasSuffix(value, "m"):
+		value = strings.TrimSuffix(value, "m")
+	case strings.HasSuffix(value, "h"):
+		value = strings.TrimSuffix(value, "h")
+		unit = 60
+	default:
+		return CandleInterval{}, fmt.Errorf("invalid interval %s", value)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 || n*unit > candleMaxIntervalMinutes {
+		return CandleInterval{}, fmt.Errorf("invalid interval %s", value)
+	}
+	return CandleInterval{Minutes: n * unit}, nil
+}
+
+// CandleService is the service for the candles
+type CandleService struct {
+	repo              *repository.CandleRepository
+	instrumentService *InstrumentService
+}
+
+// NewCandleService creates a new candle service
+func NewCandleService(db *gorm.DB) *CandleService {
+	return &CandleService{
+		repo:              repository.NewCandleRepository(db),
+		instrumentService: NewInstrumentService(db),
+	}
+}
+
+// GetCandles returns the candles of the instrument between from and to resampled from the 1 minute candles
+func (s *CandleService) GetCandles(instrument string, interval CandleInterval, from, to time.Time) (models.CandleSeries, error) {
+	series := models.CandleSeries{Instrument: instrument, Interval: interval.String(), From: from, To: to, Candles: make([]models.Candle, 0)}
+	if err := validateCandleRange(from, to); err != nil {
+		return series, err
+	}
+	info, err := s.resolveInstrument(instrument)
+	if err != nil {
+		return series, err
+	}
+	series.InstrumentToken = info.InstrumentToken
+
+	// the current interval keeps changing, only ranges in the past are cached for long
+	key := fmt.Sprintf("%d|%s|%d|%d", series.InstrumentToken, series.Interval, from.Unix(), to.Unix())
+	cacheable := interval.Daily || interval.Minutes > 1
+	if cacheable {
+		if candles, ok := GetCandleCache().Get(key); ok {
+			series.Candles = candles
+			series.Meta = candleSeriesLineage(candles, true)
+			return series, nil
+		}
+	}
+
+	base, err := s.repo.GetCandles(series.InstrumentToken, GetInstrumentCache().GetInstrumentID(series.InstrumentToken), from, to)
+	if err != nil {
+		return series, err
+	}
+	series.Candles = resampleCandles(base, interval, info.Exchange)
+	series.Meta = candleSeriesLineage(series.Candles, false)
+
+	if cacheable {
+		GetCandleCache().Set(key, series.Candles, to.Before(time.Now()))
+	}
+	return series, nil
+}
+
+// WarmCandleCache caches the previous trading day's candles of the instruments at the common intervals until
+// the end of today, a query of that day by date is then served without reading the 1 minute candles. It returns
+// the number of series cached
+func (s *CandleService) WarmCandleCache(instruments []string) (int, error) {
+	cache := GetCandleCache()
+	if cache.ttl <= 0 {
+		return 0, nil
+	}
+	now := MarketNow()
+	from := getTickSessionCalendar().previousTradingDay(now)
+	to := from.AddDate(0, 0, 1)
+	expiresAt := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, MarketLocation).AddDate(0, 0, 1)
+
+	cached := 0
+	for _, instrument := range instruments {
+		info, err := s.resolveInstrument(instrument)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return cached, err
+		}
+		base, err := s.repo.GetCandles(info.InstrumentToken, GetInstrumentCache().GetInstrumentID(info.InstrumentToken), from, to)
+		if err != nil {
+			return cached, err
+		}
+		if len(base) == 0 {
+			continue
+		}
+		for _, interval := range candleWarmupIntervals {
+			key := fmt.Sprintf("%d|%s|%d|%d", info.InstrumentToken, interval.String(), from.Unix(), to.Unix())
+			cache.setUntil(key, resampleCandles(base, interval, info.Exchange), expiresAt)
+			cached++
+		}
+	}
+	return cached, nil
+}
+
+// validateCandleRange checks that the range is not empty and not longer than the maximum range
+func validateCandleRange(from, to time.Time) error {
+	if !to.After(from) {
+		return fmt.Errorf("%w, `to` must be after `from`", ErrInvalidCandleRange)
+	}
+	if to.Sub(from) > candleMaxRangeDays*24*time.Hour {
+		return fmt.Errorf("%w, must not exceed %d days", ErrInvalidCandleRange, candleMaxRangeDays)
+	}
+	return nil
+}
+
+// resolveInstrument returns the instrument of the exchange:tradingsymbol, gorm.ErrRecordNotFound if it has no token
+func (s *CandleService) resolveInstrument(instrument string) (models.InstrumentModel, error) {
+	instruments, err := s.instrumentService.GetInstrumentsInfoBySymbols([]string{instrument})
+	if err != nil {
+		return models.InstrumentModel{}, err
+	}
+	if len(instruments) == 0 || instruments[0].InstrumentToken == 0 {
+		return models.InstrumentModel{}, gorm.ErrRecordNotFound
+	}
+	return instruments[0], nil
+}
+
+// GetCandleGaps returns the runs of at least minMinutes missing 1 minute candles of the instrument within the
+// trading sessions between from and to, 0 minMinutes uses the configured minimum. The exchange holidays are
+// those of the tick session calendar
+func (s *CandleService) GetCandleGaps(instrument string, from, to time.Time, minMinutes int) (models.CandleGapReport, error) {
+	if minMinutes <= 0 {
+		minMinutes = getCandleGapMinMinutes()
+	}
+	report := models.CandleGapReport{Instrument: instrument, From: from, To: to, MinMinutes: minMinutes, Gaps: make([]models.CandleGap, 0)}
+	if err := validateCandleRange(from, to); err != nil {
+		return report, err
+	}
+	info, err := s.resolveInstrument(instrument)
+	if err != nil {
+		return report, err
+	}
+	report.InstrumentToken = info.InstrumentToken
+
+	if now := time.Now().Truncate(time.Minute); to.After(now) {
+		to = now
+	}
+	if !to.After(from) {
+		return report, nil
+	}
+	timestamps, err := s.repo.GetCandleTimestamps(info.InstrumentToken, GetInstrumentCache().GetInstrumentID(info.InstrumentToken), from, to)
+	if err != nil {
+		return report, err
+	}
+
+	session, ok := tradingSessions[info.Exchange]
+	if !ok {
+		session = tradingSessions["NSE"]
+	}
+	report.Gaps = findCandleGaps(timestamps, getTickSessionCalendar(), session, from, to, minMinutes)
+	for _, gap := range report.Gaps {
+		report.MissingMinutes += gap.Minutes
+	}
+	return report, nil
+}
+
+// getCandleGapMinMinutes returns the configured shortest gap, 1 when the config can not be loaded
+func getCandleGapMinMinutes() int {
+	cfg, err := config.Get()
+	if err != nil {
+		return 1
+	}
+	// validated when the config is loaded
+	minMinutes, _ := strconv.Atoi(cfg.CandleGapMinMinutes)
+	if minMinutes <= 0 {
+		return 1
+	}
+	return minMinutes
+}
+
+// findCandleGaps returns the runs of at least minMinutes session minutes between from and to without a
+// candle, on the trading days of the calendar
+func findCandleGaps(timestamps []time.Time, calendar *tickSessionCalendar, session tradingSession, from, to time.Time, minMinutes int) []models.CandleGap {
+	present := make(map[int64]bool, len(timestamps))
+	for _, timestamp := range timestamps {
+		present[timestamp.Unix()] = true
+	}
+
+	gaps := make([]models.CandleGap, 0)
+	addGap := func(start, end time.Time) {
+		minutes := int(end.Sub(start) / time.Minute)
+		if minutes > 0 && minutes >= minMinutes {
+			gaps = append(gaps, models.CandleGap{From: start, To: end, Minutes: minutes})
+		}
+	}
+
+	local := from.In(MarketLocation)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, MarketLocation); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !calendar.isTradingDay(day) {
+			continue
+		}
+		start := day.Add(time.Duration(session.open) * time.Minute)
+		end := day.Add(time.Duration(session.close) * time.Minute)
+		if start.Before(from) {
+			start = from.Truncate(time.Minute)
+			if start.Before(from) {
+				start = start.Add(time.Minute)
+			}
+		}
+		if end.After(to) {
+			end = to
+		}
+
+		var gapStart time.Time
+		for minute := start; minute.Before(end); minute = minute.Add(time.Minute) {
+			if present[minute.Unix()] {
+				if !gapStart.IsZero() {
+					addGap(gapStart, minute)
+					gapStart = time.Time{}
+				}
+				continue
+			}
+			if gapStart.IsZero() {
+				gapStart = minute
+			}
+		}
+		if !gapStart.IsZero() {
+			addGap(gapStart, end)
+		}
+	}
+	return gaps
+}
+
+// BackfillCandles fills the gaps in the 1 minute candles of the instrument between from and to with candles
+// from the kite historical API, backfilled candles never replace candles built from the ticks.
+// The requests are paced by the historical scheduler at the given priority
+func (s *CandleService) BackfillCandles(ctx context.Context, userID, enctoken, instrument string, from, to time.Time, minMinutes int, priority string) (models.CandleBackfillResult, error) {
+	result := models.CandleBackfillResult{Instrument: instrument}
+	report, err := s.GetCandleGaps(instrument, from, to, minMinutes)
+	if err != nil {
+		return result, err
+	}
+	result.Gaps = len(report.Gaps)
+
+	// one request per day covering all the gaps of the day
+	scheduler := GetHistoricalScheduler()
+	for i := 0; i < len(report.Gaps); {
+		day := report.Gaps[i].From.In(MarketLocation).Format("2006-01-02")
+		j := i
+		for j < len(report.Gaps) && report.Gaps[j].From.In(MarketLocation).Format("2006-01-02") == day {
+			j++
+		}
+		dayGaps := report.Gaps[i:j]
+		i = j
+
+		// the kite range is inclusive of to
+		var candles []models.CandleModel
+		err := scheduler.Do(priority, func() error {
+			var err error
+			candles, err = fetchKiteMinuteCandles(ctx, userID, enctoken, report.InstrumentToken, instrument, dayGaps[0].From, dayGaps[len(dayGaps)-1].To.Add(-time.Minute))
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+
+		missing := make([]models.CandleModel, 0, len(candles))
+		for _, candle := range candles {
+			for _, gap := range dayGaps {
+				if !candle.Timestamp.Before(gap.From) && candle.Timestamp.Before(gap.To) {
+					missing = append(missing, candle)
+					break
+				}
+			}
+		}
+		result.Fetched += len(missing)
+		inserted, err := s.repo.InsertCandles(missing)
+		if err != nil {
+			return result, err
+		}
+		result.Inserted += inserted
+	}
+	return result, nil
+}
+
+// resampleCandles aggregates the 1 minute candles into the interval, intraday intervals are aligned
+// to the session open of the exchange and daily candles to the market date
+func resampleCandles(base []models.CandleModel, interval CandleInterval, exchange string) []models.Candle {
+	session, ok := tradingSessions[exchange]
+	if !ok {
+		session = tradingSessions["NSE"]
+	}
+
+	candles := make([]models.Candle, 0)
+	var current *models.Candle
+	for _, bar := range base {
+		start := candleIntervalStart(bar.Timestamp, interval, session.open)
+		if current == nil || !start.Equal(current.Timestamp) {
+			candles = append(candles, models.Candle{
+				Timestamp: start,
+				Open:      bar.Open,
+				High:      bar.High,
+				Low:       bar.Low,
+				Source:    models.CandleSourceTick,
+			})
+			current = &candles[len(candles)-1]
+		}
+		if bar.Source == models.CandleSourceBackfill {
+			current.Source = models.CandleSourceBackfill
+		}
+		current.SourceTimestamp = bar.Timestamp.Add(time.Minute)
+		current.High = decimal.Max(current.High, bar.High)
+		current.Low = decimal.Min(current.Low, bar.Low)
+		current.Close = bar.Close
+		current.Volume += bar.Volume
+		current.OI = bar.OI
+	}
+	return candles
+}
+
+// candleSeriesLineage returns the lineage of the candles, backfilled if any of them was backfilled
+func candleSeriesLineage(candles []models.Candle, cached bool) *models.DataLineage {
+	lineage := &models.DataLineage{Source: models.LineageLiveTick, Cached: cached}
+	for _, candle := range candles {
+		if candle.Source == models.CandleSourceBackfill {
+			lineage.Source = models.LineageBackfilled
+		}
+	}
+	if len(candles) > 0 {
+		lineage.SourceTimestamp = candles[len(candles)-1].SourceTimestamp.In(MarketLocation).Format("2006-01-02 15:04:05")
+	}
+	return lineage
+}
+
+// candleIntervalStart returns the start of the interval containing t in the market time zone
+func candleIntervalStart(t time.Time, interval CandleInterval, sessionOpen int) time.Time {
+	t = t.In(MarketLocation)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, MarketLocation)
+	if interval.Daily {
+		return midnight
+	}
+	minutes := t.Hour()*60 + t.Minute() - sessionOpen
+	index := minutes / interval.Minutes
+	if minutes < 0 && minutes%interval.Minutes != 0 {
+		index--
+	}
+	return midnight.Add(time.Duration(sessionOpen+index*interval.Minutes) * time.Minute)
+}
+
+// CandleCache is an in-memory LRU of resampled candles, ranges ending in the past are kept for the
+// configured time to live and ranges still being built for a few seconds. The cached candles must not be modified
+type CandleCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// candleCacheEntry is a cached candle series with its expiry
+type candleCacheEntry struct {
+	key       string
+	candles   []models.Candle
+	expiresAt time.Time
+}
+
+// candleCacheOpenTTL is the time to live of cached ranges which include the current interval
+const candleCacheOpenTTL = 5 * time.Second
+
+var (
+	candleCache     *CandleCache
+	candleCacheOnce sync.Once
+)
+
+// GetCandleCache returns the process wide candle cache
+func GetCandleCache() *CandleCache {
+	candleCacheOnce.Do(func() {
+		candleCache = &CandleCache{
+			ttl:     getCandleCacheTTL(),
+			entries: make(map[string]*list.Element),
+			lru:     list.New(),
+		}
+	})
+	return candleCache
+}
+
+// getCandleCacheTTL returns the configured time to live of cached candles, 0 disables the cache
+func getCandleCacheTTL() time.Duration {
+	cfg, err := config.Get()
+	if err != nil {
+		return 0
+	}
+	// validated when the config is loaded
+	seconds, _ := strconv.Atoi(cfg.CandleCacheSeconds)
+	return time.Duration(seconds) * time.Second
+}
+
+// Get returns the cached candles of the key if they have not expired
+func (c *CandleCache) Get(key string) ([]models.Candle, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*candleCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return entry.candles, true
+}
+
+// Set caches the candles of the key, evicting the least recently used series when full
+func (c *CandleCache) Set(key string, candles []models.Candle, complete bool) {
+	if c.ttl <= 0 {
+		return
+	}
+	ttl := c.ttl
+	if !complete && ttl > candleCacheOpenTTL {
+		ttl = candleCacheOpenTTL
+	}
+	c.setUntil(key, candles, time.Now().Add(ttl))
+}
+
+// setUntil caches the candles of the key until expiresAt, evicting the least recently used series when full
+func (c *CandleCache) setUntil(key string, candles []models.Candle, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*candleCacheEntry)
+		entry.candles = candles
+		entry.expiresAt = expiresAt
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&candleCacheEntry{key: key, candles: candles, expiresAt: expiresAt})
+	if c.lru.Len() > candleCacheCapacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*candleCacheEntry).key)
+	}
+}
@@ -0,0 +1,200 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"gorm.io/gorm"
+)
+
+// bhavcopyPrices are the official closing prices of an instrument in the bhavcopy
+type bhavcopyPrices struct {
+	close decimal.Decimal
+	last  decimal.Decimal
+}
+
+// CloseReconcileService is the service comparing the recorded last prices with the official bhavcopy closes,
+// a divergence beyond the tolerance points at a silently corrupted feed
+type CloseReconcileService struct {
+	repo          *repository.CloseReconcileRepository
+	tickerRepo    *repository.TickerRepository
+	prevCloseRepo *repository.PrevCloseRepository
+}
+
+// NewCloseReconcileService creates a new close reconciliation service
+func NewCloseReconcileService(db *gorm.DB) *CloseReconcileService {
+	return &CloseReconcileService{
+		repo:          repository.NewCloseReconcileRepository(db),
+		tickerRepo:    repository.NewTickerRepository(db),
+		prevCloseRepo: repository.NewPrevCloseRepository(db),
+	}
+}
+
+// Reconcile downloads the bhavcopy of the date, compares it with the NSE last prices recorded that day
+// and replaces the stored reconciliation of the date. The official closes are stored as the previous closes
+// of the next trading day
+func (s *CloseReconcileService) Reconcile(date string) (models.CloseReconciliationReport, error) {
+	var report models.CloseReconciliationReport
+	cfg, err := config.Get()
+	if err != nil {
+		return report, err
+	}
+	if cfg.BhavcopyURL == "" {
+		return report, fmt.Errorf("bhavcopy url is not configured")
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, MarketLocation)
+	if err != nil {
+		return report, fmt.Errorf("invalid date %q", date)
+	}
+	// validated when the config is loaded
+	tolerance, _ := strconv.ParseFloat(cfg.CloseReconcileTolerancePercent, 64)
+
+	records, err := fetchExchangeCSV(strings.ReplaceAll(cfg.BhavcopyURL, "{date}", day.Format("02012006")))
+	if err != nil {
+		return report, err
+	}
+	closes, err := parseBhavcopyRecords(records)
+	if err != nil {
+		return report, err
+	}
+	if len(closes) == 0 {
+		return report, fmt.Errorf("bhavcopy has no rows")
+	}
+
+	lastPrices, err := s.tickerRepo.GetTickerDataLastPrices()
+	if err != nil {
+		return report, err
+	}
+
+	report.Date = date
+	report.TolerancePercent = tolerance
+	report.Divergences = make([]models.CloseDivergenceModel, 0)
+	nextDay := getTickSessionCalendar().nextTradingDay(day).Format("2006-01-02")
+	var prevCloses []models.PrevCloseModel
+	for _, tickerData := range lastPrices {
+		if tickerData.IsIndex || !strings.HasPrefix(tickerData.Instrument, "NSE:") {
+			continue
+		}
+		// instruments that did not tick on the date have nothing recorded to compare
+		if tickerData.Timestamp.In(MarketLocation).Format("2006-01-02") != date {
+			continue
+		}
+		official, ok := closes[tickerData.Instrument]
+		if !ok {
+			report.Unmatched++
+			continue
+		}
+		report.Compared++
+		if official.close.Sign() > 0 {
+			prevCloses = append(prevCloses, models.PrevCloseModel{
+				Instrument:      tickerData.Instrument,
+				Date:            nextDay,
+				InstrumentToken: tickerData.InstrumentToken,
+				InstrumentID:    GetInstrumentCache().GetInstrumentID(tickerData.InstrumentToken),
+				PrevClose:       official.close,
+				Source:          models.PrevCloseSourceBhavcopy,
+			})
+		}
+
+		diff := closeDiffPercent(tickerData.LastPrice, official)
+		if diff <= tolerance {
+			continue
+		}
+		report.Divergences = append(report.Divergences, models.CloseDivergenceModel{
+			Date:            date,
+			Instrument:      tickerData.Instrument,
+			InstrumentToken: tickerData.InstrumentToken,
+			RecordedPrice:   tickerData.LastPrice,
+			RecordedAt:      tickerData.Timestamp,
+			OfficialClose:   official.close,
+			OfficialLast:    official.last,
+			DiffPercent:     diff,
+		})
+	}
+	report.Divergent = len(report.Divergences)
+
+	if err := s.repo.SaveReconciliation(report.CloseReconciliationModel, report.Divergences); err != nil {
+		return report, err
+	}
+	if _, err := s.prevCloseRepo.UpsertPrevCloses(prevCloses); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// closeDiffPercent returns the divergence of the recorded price from the official prices as a percentage of the close.
+// The official close is an average of the closing session, so a recorded price matching the last traded price is not flagged
+func closeDiffPercent(recorded decimal.Decimal, official bhavcopyPrices) float64 {
+	if official.close.Sign() <= 0 {
+		return 0
+	}
+	diff := recorded.Sub(official.close).Abs()
+	if official.last.Sign() > 0 {
+		diff = decimal.Min(diff, recorded.Sub(official.last).Abs())
+	}
+	return diff.Mul(decimal.New(100, 0)).Div(official.close).Round(2).Float64()
+}
+
+// parseBhavcopyRecords parses the rows of the full bhavcopy into the official prices by instrument,
+// the columns are located by their header
+func parseBhavcopyRecords(records [][]string) (map[string]bhavcopyPrices, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := csvColumns(records[0])
+	for _, name := range []string{"symbol", "series", "close_price", "last_price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("bhavcopy has no %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i := columns[name]
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	closes := make(map[string]bhavcopyPrices, len(records)-1)
+	for _, record := range records[1:] {
+		symbol := field(record, "symbol")
+		if symbol == "" {
+			continue
+		}
+		closePrice, err := decimal.NewFromString(field(record, "close_price"))
+		if err != nil {
+			continue
+		}
+		// the last price is "-" for instruments that did not trade
+		lastPrice, err := decimal.NewFromString(field(record, "last_price"))
+		if err != nil {
+			lastPrice = decimal.Zero
+		}
+		closes[nseInstrument(symbol, field(record, "series"))] = bhavcopyPrices{close: closePrice, last: lastPrice}
+	}
+	return closes, nil
+}
+
+// GetReport gets the stored reconciliation of a date with its divergences,
+// gorm.ErrRecordNotFound when the date was not reconciled
+func (s *CloseReconcileService) GetReport(date string) (models.CloseReconciliationReport, error) {
+	var report models.CloseReconciliationReport
+	summary, err := s.repo.GetReconciliation(date)
+	if err != nil {
+		return report, err
+	}
+	divergences, err := s.repo.GetDivergences(date)
+	if err != nil {
+		return report, err
+	}
+	report.CloseReconciliationModel = summary
+	report.Divergences = divergences
+	return report, nil
+}
@@ -2,50 +2,144 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"runtime/debug"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	kiteticker "github.com/nsvirk/gokiteticker"
 	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 	"github.com/redis/go-redis/v9"
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
 )
 
+// Job names, the cron routes run the jobs by name
+const (
+	JobApiInstrumentsUpdate       = "API Instruments UPDATE Job"
+	JobApiIndicesUpdate           = "API Indices UPDATE Job"
+	JobApiInstrumentsDeltaSync    = "API Instruments DELTA SYNC Job"
+	JobTickerInstrumentsUpdate    = "TickerInstruments UPDATE Job"
+	JobTickerInstrumentsReconcile = "TickerInstruments RECONCILE Job"
+	JobMarketWarmup               = "Market WARMUP Job"
+	JobTickerDataTruncate         = "TickerData TRUNCATE Job"
+	JobTickerStart                = "Ticker START Job"
+	JobTickerStop                 = "Ticker STOP Job"
+	JobFuturesBasisSnapshot       = "FuturesBasis SNAPSHOT Job"
+	JobPriceBandsUpdate           = "PriceBands UPDATE Job"
+	JobDealsUpdate                = "Deals UPDATE Job"
+	JobCloseReconcile             = "Close RECONCILE Job"
+	JobAnnouncementsUpdate        = "Announcements UPDATE Job"
+	JobEODReport                  = "EOD REPORT Job"
+	JobDatabaseMaintenance        = "Database MAINTENANCE Job"
+	JobCandleBackfill             = "Candle BACKFILL Job"
+	JobSessionKeepAlive           = "Session KEEP ALIVE Job"
+	JobBackup                     = "Tables BACKUP Job"
+	JobGreeksBackfill             = "Greeks BACKFILL Job"
+)
+
+// cronJobTimeout is how long a job, or a wait for its dependencies, may take
+const cronJobTimeout = 15 * time.Minute
+
+// candleBackfillSessions is the number of trading sessions before today whose candle gaps are backfilled
+const candleBackfillSessions = 4
+
+// marketWarmupCandleInstruments is the number of the most queried instruments of the last marketWarmupPopularDays
+// whose candles are cached by the market warmup
+const (
+	marketWarmupCandleInstruments = 50
+	marketWarmupPopularDays       = 5
+)
+
+// cronJob is a job with the jobs that must complete successfully before it runs
+type cronJob struct {
+	name      string
+	run       func() error
+	dependsOn []string
+	timeout   time.Duration
+}
+
+// cronJobRun is a single run of a job, done is closed once the run has finished
+type cronJobRun struct {
+	startedAt  time.Time
+	finishedAt time.Time
+	err        error
+	done       chan struct{}
+}
+
 // CronService is the service for the cron jobs
 type CronService struct {
 	e                 *echo.Echo
 	cfg               *config.Config
 	db                *gorm.DB
-	redisClient       *redis.Client
+	redisClient       redis.UniversalClient
 	c                 *cron.Cron
 	sessionService    *SessionService
 	instrumentService *InstrumentService
 	indexService      *IndexService
 	tickerService     *TickerService
+	basisService      *BasisService
+	bandService       *BandService
+	dealService       *DealService
+	closeReconcile    *CloseReconcileService
+	newsService       *NewsService
+	notifier          *NotifierService
+	maintenance       *MaintenanceService
+	candleService     *CandleService
+	instrumentAccess  *InstrumentAccessService
+	backupService     *BackupService
+	greeksService     *GreeksService
+	totpProvider      TOTPProvider
+	jobs              map[string]*cronJob
+	runs              map[string]*cronJobRun
+	runsMu            sync.Mutex
+	simulatedJobs     []*simulatedCronJob
 }
 
 // NewCronService creates a new CronService
-func NewCronService(e *echo.Echo, cfg *config.Config, db *gorm.DB, redisClient *redis.Client) *CronService {
+func NewCronService(e *echo.Echo, cfg *config.Config, db *gorm.DB, redisClient redis.UniversalClient) *CronService {
 	// Initialize services
 	sessionService := NewSessionService(db)
 	instrumentService := NewInstrumentService(db)
 	indexService := NewIndexService(db)
 	tickerService := NewTickerService(db, redisClient)
 
-	return &CronService{
+	cs := &CronService{
 		e:                 e,
 		cfg:               cfg,
 		db:                db,
 		redisClient:       redisClient,
-		c:                 cron.New(),
+		c:                 cron.New(cron.WithLocation(MarketLocation)),
 		sessionService:    sessionService,
 		instrumentService: instrumentService,
 		tickerService:     tickerService,
 		indexService:      indexService,
+		basisService:      NewBasisService(db),
+		bandService:       NewBandService(db),
+		dealService:       NewDealService(db),
+		closeReconcile:    NewCloseReconcileService(db),
+		newsService:       NewNewsService(db),
+		notifier:          NewNotifierService(db),
+		maintenance:       NewMaintenanceService(db),
+		candleService:     NewCandleService(db),
+		instrumentAccess:  NewInstrumentAccessService(db),
+		backupService:     NewBackupService(db),
+		greeksService:     NewGreeksService(db),
+		totpProvider:      NewTOTPProvider(cfg),
+		jobs:              make(map[string]*cronJob),
+		runs:              make(map[string]*cronJobRun),
 	}
+	cs.registerJobs()
+	return cs
 }
 
 // Start starts the cron service
@@ -56,52 +150,141 @@ func (cs *CronService) Start() {
 	// ------------------------------------------------------------
 	// Add your SCHEDULED jobs here
 	// ------------------------------------------------------------
-	cs.addScheduledJob("API Instruments UPDATE Job", cs.ApiInstrumentsUpdateJob, "0 8 * * 1-5")      // Once at 08:00am, Mon-Fri
-	cs.addScheduledJob("API Indices UPDATE Job", cs.ApiIndicesUpdateJob, "1 8 * * 1-5")              // Once at 08:01am, Mon-Fri
-	cs.addScheduledJob("TickerInstruments UPDATE Job", cs.TickerInstrumentsUpdateJob, "2 8 * * 1-5") // Once at 08:02am, Mon-Fri
-	cs.addScheduledJob("Ticker START Job", cs.TickerStartJob, "55 8	* * 1-5")                        // Once at 08:55am, Mon-Fri
-	cs.addScheduledJob("Ticker STOP Job", cs.TickerStopJob, "59 23 * * 1-5")                         // Once at 11:59pm, Mon-Fri
+	cs.addScheduledJob(JobApiInstrumentsUpdate, cs.cfg.CronInstrumentsUpdate)
+	cs.addScheduledJob(JobApiIndicesUpdate, cs.cfg.CronIndicesUpdate)
+	cs.addScheduledJob(JobApiInstrumentsDeltaSync, cs.cfg.CronInstrumentsDeltaSync)
+	cs.addScheduledJob(JobTickerInstrumentsUpdate, cs.cfg.CronTickerInstrumentsUpdate)
+	cs.addScheduledJob(JobTickerInstrumentsReconcile, cs.cfg.CronTickerInstrumentsReconcile)
+	cs.addScheduledJob(JobTickerDataTruncate, cs.cfg.CronTickerDataTruncate)
+	cs.addScheduledJob(JobTickerStart, cs.cfg.CronTickerStart)
+	cs.addScheduledJob(JobMarketWarmup, cs.cfg.CronMarketWarmup)
+	cs.addScheduledJob(JobTickerStop, cs.cfg.CronTickerStop)
+	cs.addScheduledJob(JobFuturesBasisSnapshot, cs.cfg.CronFuturesBasisSnapshot)
+	cs.addScheduledJob(JobPriceBandsUpdate, cs.cfg.CronPriceBandsUpdate)
+	cs.addScheduledJob(JobDealsUpdate, cs.cfg.CronDealsUpdate)
+	cs.addScheduledJob(JobCloseReconcile, cs.cfg.CronCloseReconcile)
+	cs.addScheduledJob(JobAnnouncementsUpdate, cs.cfg.CronAnnouncementsUpdate)
+	cs.addScheduledJob(JobEODReport, cs.cfg.CronEODReport)
+	cs.addScheduledJob(JobDatabaseMaintenance, cs.cfg.CronDatabaseMaintenance)
+	cs.addScheduledJob(JobCandleBackfill, cs.cfg.CronCandleBackfill)
+	cs.addScheduledJob(JobSessionKeepAlive, cs.cfg.CronSessionKeepAlive)
+	cs.addScheduledJob(JobBackup, cs.cfg.CronBackup)
+	cs.addScheduledJob(JobGreeksBackfill, cs.cfg.CronGreeksBackfill)
 
 	// ------------------------------------------------------------
-	// Add your STARTUP jobs here
+	// Add your STARTUP jobs here, they run one after the other in this order
 	// ------------------------------------------------------------
-	cs.addStartupJob("API Instruments UPDATE Job", cs.ApiInstrumentsUpdateJob, 1*time.Second)
-	cs.addStartupJob("API Indices UPDATE Job", cs.ApiIndicesUpdateJob, 5*time.Second)
-	cs.addStartupJob("TickerInstruments UPDATE Job", cs.TickerInstrumentsUpdateJob, 19*time.Second)
-	cs.addStartupJob("TickerData TRUNCATE Job", cs.TickerDataTruncateJob, 25*time.Second)
-	cs.addStartupJob("Ticker START Job", cs.TickerStartJob, 28*time.Second)
+	cs.addStartupJobs(1*time.Second,
+		JobApiInstrumentsUpdate,
+		JobApiIndicesUpdate,
+		JobTickerInstrumentsUpdate,
+		JobTickerInstrumentsReconcile,
+		JobMarketWarmup,
+		JobTickerDataTruncate,
+		JobTickerStart,
+	)
 	// ------------------------------------------------------------
 
+	if IsSimulatedClock() {
+		zaplogger.Info("Scheduling jobs on the simulated clock", zaplogger.Fields{
+			"now": MarketNow().Format("2006-01-02 15:04:05"),
+		})
+		recovery.Go("cron.runSimulatedSchedule", cs.runSimulatedSchedule)
+		return
+	}
 	cs.c.Start()
 }
 
-// addStartupJob adds a startup job to the cron service
-func (cs *CronService) addStartupJob(name string, job func(), delay time.Duration) {
+// registerJobs registers all jobs with the jobs they depend on
+func (cs *CronService) registerJobs() {
+	cs.addJob(JobApiInstrumentsUpdate, cs.ApiInstrumentsUpdateJob)
+	cs.addJob(JobApiIndicesUpdate, cs.ApiIndicesUpdateJob)
+	cs.addJob(JobApiInstrumentsDeltaSync, cs.ApiInstrumentsDeltaSyncJob, JobApiInstrumentsUpdate)
+	cs.addJob(JobTickerInstrumentsUpdate, cs.TickerInstrumentsUpdateJob, JobApiInstrumentsUpdate, JobApiIndicesUpdate)
+	cs.addJob(JobTickerInstrumentsReconcile, cs.TickerInstrumentsReconcileJob, JobTickerInstrumentsUpdate)
+	cs.addJob(JobMarketWarmup, cs.MarketWarmupJob, JobApiInstrumentsUpdate)
+	cs.addJob(JobTickerDataTruncate, cs.TickerDataTruncateJob)
+	cs.addJob(JobTickerStart, cs.TickerStartJob, JobTickerInstrumentsUpdate)
+	cs.addJob(JobTickerStop, cs.TickerStopJob)
+	cs.addJob(JobFuturesBasisSnapshot, cs.FuturesBasisSnapshotJob)
+	cs.addJob(JobPriceBandsUpdate, cs.PriceBandsUpdateJob)
+	cs.addJob(JobDealsUpdate, cs.DealsUpdateJob)
+	cs.addJob(JobCloseReconcile, cs.CloseReconcileJob)
+	cs.addJob(JobAnnouncementsUpdate, cs.AnnouncementsUpdateJob)
+	cs.addJob(JobEODReport, cs.EODReportJob)
+	cs.addJob(JobDatabaseMaintenance, cs.DatabaseMaintenanceJob)
+	cs.addJob(JobCandleBackfill, cs.CandleBackfillJob)
+	cs.addJob(JobSessionKeepAlive, cs.SessionKeepAliveJob)
+	cs.addJob(JobBackup, cs.BackupJob)
+	cs.addJob(JobGreeksBackfill, cs.GreeksBackfillJob)
+}
+
+// addJob registers a job and the jobs which must have completed successfully before it runs
+func (cs *CronService) addJob(name string, run func() error, dependsOn ...string) {
+	cs.jobs[name] = &cronJob{
+		name:      name,
+		run:       run,
+		dependsOn: dependsOn,
+		timeout:   cronJobTimeout,
+	}
+}
+
+// addStartupJobs runs the given jobs one after the other after the delay
+func (cs *CronService) addStartupJobs(delay time.Duration, names ...string) {
 	go func() {
 		time.Sleep(delay)
-		zaplogger.Info("STARTED STARTUP job", zaplogger.Fields{
-			"job": name,
-		})
-		job()
-		zaplogger.Info("COMPLETED STARTUP job", zaplogger.Fields{
+		for _, name := range names {
+			zaplogger.Info("STARTED STARTUP job", zaplogger.Fields{
+				"job": name,
+			})
+			if err := cs.RunJob(name); err != nil {
+				zaplogger.Error("FAILED STARTUP job", zaplogger.Fields{
+					"job":   name,
+					"error": err.Error(),
+				})
+				continue
+			}
+			zaplogger.Info("COMPLETED STARTUP job", zaplogger.Fields{
+				"job": name,
+			})
+		}
+	}()
+	for _, name := range names {
+		zaplogger.Info("QUEUED STARTUP job", zaplogger.Fields{
 			"job": name,
 		})
-	}()
-	zaplogger.Info("QUEUED STARTUP job", zaplogger.Fields{
-		"job": name,
-	})
+	}
 }
 
-func (cs *CronService) addScheduledJob(name string, job func(), schedule string) {
-	_, err := cs.c.AddFunc(schedule, func() {
+// addScheduledJob schedules a registered job, a blank schedule disables the job
+func (cs *CronService) addScheduledJob(name string, schedule string) {
+	if schedule == "" {
+		zaplogger.Info("DISABLED SCHEDULED job", zaplogger.Fields{
+			"job": name,
+		})
+		return
+	}
+	job := func() {
 		zaplogger.Info("STARTED SCHEDULED JOB", zaplogger.Fields{
 			"job": name,
 		})
-		job()
+		if err := cs.RunJob(name); err != nil {
+			zaplogger.Error("FAILED SCHEDULED JOB", zaplogger.Fields{
+				"job":   name,
+				"error": err.Error(),
+			})
+			return
+		}
 		zaplogger.Info("COMPLETED SCHEDULED JOB", zaplogger.Fields{
 			"job": name,
 		})
-	})
+	}
+	var err error
+	if IsSimulatedClock() {
+		err = cs.addSimulatedJob(schedule, job)
+	} else {
+		_, err = cs.c.AddFunc(schedule, job)
+	}
 	if err != nil {
 		zaplogger.Error("FAILED TO QUEUE SCHEDULED JOB", zaplogger.Fields{
 			"job":   name,
@@ -110,70 +293,198 @@ func (cs *CronService) addScheduledJob(name string, job func(), schedule string)
 		return
 	}
 	zaplogger.Info("QUEUED SCHEDULED job", zaplogger.Fields{
-		"job": name,
+		"job":      name,
+		"schedule": schedule,
 	})
 }
 
+// RunJob runs a registered job once its dependencies have completed successfully,
+// a job whose dependency failed, timed out or never ran is skipped with an error
+func (cs *CronService) RunJob(name string) error {
+	job, ok := cs.jobs[name]
+	if !ok {
+		return fmt.Errorf("unknown job %s", name)
+	}
+	// jobs are paused, not failed, while the global kill switch is engaged
+	if GetKillSwitch().IsGlobalEngaged() {
+		zaplogger.Info("PAUSED job", zaplogger.Fields{
+			"job":    name,
+			"reason": ErrKillSwitchEngaged.Error(),
+		})
+		return nil
+	}
+
+	for _, dependency := range job.dependsOn {
+		if err := cs.waitForJob(dependency, job.timeout); err != nil {
+			err = fmt.Errorf("skipped, dependency %s: %v", dependency, err)
+			cs.finishJobRun(cs.startJobRun(name), err)
+			cs.publishJobEvent(name, "skipped", 0, err)
+			cs.notifyJobFailure(name, err)
+			return err
+		}
+	}
+
+	run := cs.startJobRun(name)
+	cs.publishJobEvent(name, "started", 0, nil)
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				recovery.Report("cron."+name, recovered, debug.Stack())
+				errChan <- fmt.Errorf("panic: %v", recovered)
+			}
+		}()
+		errChan <- job.run()
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-time.After(job.timeout):
+		err = fmt.Errorf("timed out after %v", job.timeout)
+	}
+	cs.finishJobRun(run, err)
+	if err != nil {
+		cs.publishJobEvent(name, "failed", run.finishedAt.Sub(run.startedAt), err)
+		cs.notifyJobFailure(name, err)
+	} else {
+		cs.publishJobEvent(name, "completed", run.finishedAt.Sub(run.startedAt), nil)
+	}
+	return err
+}
+
+// publishJobEvent sends the status of a job run to the admin console clients
+func (cs *CronService) publishJobEvent(name, status string, duration time.Duration, err error) {
+	data := map[string]interface{}{
+		"job":         name,
+		"status":      status,
+		"duration_ms": duration.Milliseconds(),
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	GetAdminEvents().Publish(AdminEventJob, data)
+}
+
+// JobNames returns the names of the registered jobs
+func (cs *CronService) JobNames() []string {
+	names := make([]string, 0, len(cs.jobs))
+	for name := range cs.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasJob returns true if the job is registered
+func (cs *CronService) HasJob(name string) bool {
+	_, ok := cs.jobs[name]
+	return ok
+}
+
+// notifyJobFailure notifies the users subscribed to job failures
+func (cs *CronService) notifyJobFailure(name string, err error) {
+	cs.notifier.Notify(models.NotificationCategoryJobFailures, name+" failed", err.Error())
+}
+
+// waitForJob waits for the latest run of a job to finish and returns its error
+func (cs *CronService) waitForJob(name string, timeout time.Duration) error {
+	cs.runsMu.Lock()
+	run, ok := cs.runs[name]
+	cs.runsMu.Unlock()
+	if !ok {
+		return fmt.Errorf("has not run")
+	}
+
+	select {
+	case <-run.done:
+		return run.err
+	case <-time.After(timeout):
+		return fmt.Errorf("still running after %v", timeout)
+	}
+}
+
+// startJobRun records the start of a job run
+func (cs *CronService) startJobRun(name string) *cronJobRun {
+	run := &cronJobRun{
+		startedAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	cs.runsMu.Lock()
+	cs.runs[name] = run
+	cs.runsMu.Unlock()
+	return run
+}
+
+// finishJobRun records the result of a job run
+func (cs *CronService) finishJobRun(run *cronJobRun, err error) {
+	run.err = err
+	run.finishedAt = time.Now()
+	close(run.done)
+}
+
 // ApiInstrumentsUpdateJob updates the instruments from the API
-func (cs *CronService) ApiInstrumentsUpdateJob() {
+func (cs *CronService) ApiInstrumentsUpdateJob() error {
 	jobName := "API Instruments UPDATE Job "
 
-	rowsInserted, err := cs.instrumentService.UpdateInstruments()
+	rowsInserted, err := cs.instrumentService.UpdateInstruments(context.Background())
 	if err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"rows_inserted": strconv.FormatInt(rowsInserted, 10),
 	})
+	return nil
 }
 
 // ApiIndicesUpdateJob updates the indices from the APIx
-func (cs *CronService) ApiIndicesUpdateJob() {
+func (cs *CronService) ApiIndicesUpdateJob() error {
 	jobName := "API Indices UPDATE Job "
-	rowsInserted, err := cs.indexService.UpdateIndices()
+	rowsInserted, err := cs.indexService.UpdateIndices(context.Background())
 	if err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"rows_inserted": strconv.FormatInt(rowsInserted, 10),
 	})
+	return nil
 }
 
 // TickerStartJob starts the ticker
-func (cs *CronService) TickerStartJob() {
+func (cs *CronService) TickerStartJob() error {
 	jobName := "Ticker START Job "
 	// Generate the session
 	userId := cs.cfg.KitetickerUserID
 	password := cs.cfg.KitetickerPassword
-	totpSecret := cs.cfg.KitetickerTotpSecret
 
-	// generate totp value
-	totpValue, err := cs.sessionService.GenerateTOTP(totpSecret)
+	// get the totp value from the configured provider
+	totpValue, err := cs.totpProvider.GetTOTP(userId)
 	if err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
-			"step":  "GenerateTOTP",
-			"error": err.Error(),
+			"step":     "GetTOTP",
+			"provider": cs.totpProvider.Name(),
+			"error":    err.Error(),
 		})
-		return
+		return err
 	}
 
 	// Generate a new session
 	sessionData, err := cs.sessionService.GenerateSession(userId, password, totpValue)
 	if err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
-			"step":        "GenerateSession",
-			"user_id":     userId,
-			"password":    password[:2] + "..." + password[len(password)-2:],
-			"totp_secret": totpSecret[:8] + "..." + totpSecret[len(totpSecret)-8:],
-			"error":       err.Error(),
+			"step":          "GenerateSession",
+			"user_id":       userId,
+			"password":      password[:2] + "..." + password[len(password)-2:],
+			"totp_provider": cs.totpProvider.Name(),
+			"error":         err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"step":       "GenerateSession",
@@ -189,15 +500,16 @@ func (cs *CronService) TickerStartJob() {
 			"step":  "TickerStart",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"step": "TickerStart",
 	})
+	return nil
 }
 
 // TickerStopJob stops the ticker
-func (cs *CronService) TickerStopJob() {
+func (cs *CronService) TickerStopJob() error {
 	jobName := "Ticker STOP Job "
 	// Stop the ticker
 	userId := cs.cfg.KitetickerUserID
@@ -207,43 +519,107 @@ func (cs *CronService) TickerStopJob() {
 			"step":  "TickerStop",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"step": "TickerStop",
 	})
+	return nil
+}
+
+// MarketWarmupJob pre-loads the instrument cache, primes redis with the previous closes and caches the previous
+// trading day's candles of the popular instruments, so the first requests after the open don't pay for building
+// them lazily
+func (cs *CronService) MarketWarmupJob() error {
+	jobName := "Market WARMUP Job "
+
+	// Load the instrument cache
+	cachedCount, err := cs.instrumentService.WarmInstrumentCache()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "WarmInstrumentCache",
+			"error": err.Error(),
+		})
+	} else {
+		zaplogger.Info(jobName, zaplogger.Fields{
+			"step":               "WarmInstrumentCache",
+			"cached_instruments": cachedCount,
+		})
+	}
+
+	// Prime redis with the previous closes
+	primedCount, err := cs.tickerService.PrimeRedisCloses()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "PrimeRedisCloses",
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"step":          "PrimeRedisCloses",
+		"primed_closes": primedCount,
+	})
+
+	// Cache the candles of the popular instruments
+	popular, err := cs.instrumentAccess.GetPopularInstruments(marketWarmupPopularDays, "queries", marketWarmupCandleInstruments)
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "WarmCandleCache",
+			"error": err.Error(),
+		})
+		return err
+	}
+	instruments := make([]string, 0, len(popular))
+	for _, instrument := range popular {
+		instruments = append(instruments, instrument.Instrument)
+	}
+	seriesCount, err := cs.candleService.WarmCandleCache(instruments)
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "WarmCandleCache",
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"step":          "WarmCandleCache",
+		"cached_series": seriesCount,
+	})
+	return nil
 }
 
 // TickerDataTruncateJob truncates the ticker data
-func (cs *CronService) TickerDataTruncateJob() {
+func (cs *CronService) TickerDataTruncateJob() error {
 	jobName := "TickerData TRUNCATE Job "
 	// Truncate the table
 	if err := cs.tickerService.TruncateTickerData(); err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
+	return nil
 }
 
 // TickerInstrumentsUpdateJob updates the ticker instruments
-func (cs *CronService) TickerInstrumentsUpdateJob() {
+func (cs *CronService) TickerInstrumentsUpdateJob() error {
 	jobName := "TickerInstruments UPDATE Job "
 	userId := cs.cfg.KitetickerUserID
 	var grandTotalInserted int64 = 0
 
-	// Truncate the table
-	truncatedCount, err := cs.tickerService.TruncateTickerInstruments()
+	// Delete the unpinned instruments, pinned instruments and their metadata survive the refresh
+	deletedCount, err := cs.tickerService.DeleteUnpinnedTickerInstruments()
 	if err != nil {
 		zaplogger.Error(jobName, zaplogger.Fields{
-			"step":  "TruncateTickerInstruments",
+			"step":  "DeleteUnpinnedTickerInstruments",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	zaplogger.Info(jobName, zaplogger.Fields{
-		"step":            "TruncateTickerInstruments",
-		"truncated_count": strconv.FormatInt(truncatedCount, 10),
+		"step":          "DeleteUnpinnedTickerInstruments",
+		"deleted_count": strconv.FormatInt(deletedCount, 10),
 	})
 
 	// -----------------------------------
@@ -271,39 +647,13 @@ func (cs *CronService) TickerInstrumentsUpdateJob() {
 	// // m2NFOFinNiftyOptFilter := "FINNIFTY" + m2NFO + "%00_E"
 	// // m2NFOFinMidcapNiftyOptFilter := "MIDCPNIFTY" + m2NFO + "%00_E"
 
-	// Define instrument queries
-	queries := []struct {
-		exchange       string
-		tradingsymbol  string
-		name           string
-		expiry         string
-		strike         string
-		segment        string
-		instrumentType string
-		description    string
-	}{
-		{"", "", "", "", "", "INDICES", "", "ALL:INDICES"}, // ALL:INDICES - ~144
-		{"NFO", "", "", "", "", "", "FUT", "NFO:FUTURES"},  // NFO All Futures - ~553
-		{"MCX", "", "", "", "", "", "FUT", "MCX:FUTURES"},  // MCX All Futures - ~118
-
-		// // NIFTY and BANKNIFTY Options for the next 3 months
-		// {"NFO", m0NFOFutFilter, "", "", "", "NFO ALL FUT - m0 [" + m0NFO + "]"},
-		// {"NFO", m0NFONiftyOptFilter, "", "", "", "NFO NIFTY OPT - m0 [" + m0NFO + "]"},
-		// {"NFO", m0NFOBankNiftyOptFilter, "", "", "", "NFO BANKNIFTY OPT - m0 [" + m0NFO + "]"},
-
-		// {"NFO", m1NFOFutFilter, "", "", "", "NFO ALL FUT - m1 [" + m1NFO + "]"},
-		// {"NFO", m1NFONiftyOptFilter, "", "", "", "NFO NIFTY OPT - m1 [" + m1NFO + "]"},
-		// {"NFO", m1NFOBankNiftyOptFilter, "", "", "", "NFO BANKNIFTY OPT - m1 [" + m1NFO + "]"},
-
-		// {"NFO", m2NFOFutFilter, "", "", "", "NFO ALL FUT - m2 [" + m2NFO + "]"},
-		// {"NFO", m2NFONiftyOptFilter, "", "", "", "NFO NIFTY OPT - m2 [" + m2NFO + "]"},
-		// {"NFO", m2NFOBankNiftyOptFilter, "", "", "", "NFO BANKNIFTY OPT - m2 [" + m2NFO + "]"},
-	}
+	// Ticker instrument presets
+	queries := tickerInstrumentPresets
 
 	// Process each query
 	for _, q := range queries {
 
-		result, err := cs.tickerService.UpsertQueriedInstruments(userId, q.exchange, q.tradingsymbol, q.name, q.expiry, q.strike, q.segment, q.instrumentType)
+		result, err := cs.tickerService.UpsertQueriedInstruments(userId, q.exchange, q.tradingsymbol, q.name, q.expiry, q.strike, q.segment, q.instrumentType, q.mode)
 		if err != nil {
 			zaplogger.Error(jobName, zaplogger.Fields{
 				"step":  "UpsertQueriedInstruments-Instruments",
@@ -315,10 +665,11 @@ func (cs *CronService) TickerInstrumentsUpdateJob() {
 		grandTotalInserted += result.Inserted + result.Updated
 
 		zaplogger.Info(q.description+" added", zaplogger.Fields{
-			"queried":  result.Queried,
-			"inserted": result.Inserted,
-			"updated":  result.Updated,
-			"total":    result.Total,
+			"queried":     result.Queried,
+			"inserted":    result.Inserted,
+			"updated":     result.Updated,
+			"total":       result.Total,
+			"blacklisted": result.Blacklisted,
 		})
 	}
 
@@ -331,7 +682,7 @@ func (cs *CronService) TickerInstrumentsUpdateJob() {
 			"step":  "GetIndexNames",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 	var idxCount int64 = 0
 	var idxQueried, idxInserted, idxUpdated, idxTotal int64 = 0, 0, 0, 0
@@ -358,7 +709,7 @@ func (cs *CronService) TickerInstrumentsUpdateJob() {
 		for _, instrument := range indexInstruments {
 			exchange := instrument.Exchange
 			tradingsymbol := instrument.Tradingsymbol
-			result, err := cs.tickerService.UpsertQueriedInstruments(userId, exchange, tradingsymbol, "", "", "", "", "")
+			result, err := cs.tickerService.UpsertQueriedInstruments(userId, exchange, tradingsymbol, "", "", "", "", "", string(kiteticker.ModeFull))
 			if err != nil {
 				zaplogger.Error(indexName, zaplogger.Fields{
 					"step":       "UpsertQueriedInstruments-Indices",
@@ -401,12 +752,459 @@ func (cs *CronService) TickerInstrumentsUpdateJob() {
 			"step":  "GetTickerInstrumentCount",
 			"error": err.Error(),
 		})
-		return
+		return err
 	}
 
 	zaplogger.Info(jobName, zaplogger.Fields{
 		"total_ticker_instruments": strconv.FormatInt(totalTickerInstruments, 10),
 	})
+	return nil
+}
+
+// TickerInstrumentsReconcileJob fixes ticker instruments left stale by the instruments reload
+func (cs *CronService) TickerInstrumentsReconcileJob() error {
+	jobName := "TickerInstruments RECONCILE Job "
+	result, err := cs.tickerService.ReconcileTickerInstruments(false)
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"checked":         result.Checked,
+		"tokens_updated":  len(result.TokensUpdated),
+		"orphans_removed": len(result.OrphansRemoved),
+		"pinned_orphans":  len(result.PinnedOrphans),
+	})
+	return nil
+}
+
+// FuturesBasisSnapshotJob stores the basis of the ticking futures while the F&O market is open
+func (cs *CronService) FuturesBasisSnapshotJob() error {
+	jobName := "FuturesBasis SNAPSHOT Job "
+	if !IsMarketOpen("NFO", MarketNow()) {
+		return nil
+	}
+	count, err := cs.basisService.SnapshotFuturesBasis()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Debug(jobName, zaplogger.Fields{
+		"snapshots": count,
+	})
+	return nil
+}
+
+// PriceBandsUpdateJob replaces the price bands with the exchange's daily price band file
+func (cs *CronService) PriceBandsUpdateJob() error {
+	jobName := "PriceBands UPDATE Job "
+	if cs.cfg.PriceBandsURL == "" {
+		return nil
+	}
+	count, err := cs.bandService.UpdatePriceBands()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"records": count,
+	})
+	return nil
+}
+
+// DealsUpdateJob stores the block and bulk deals disclosed by the exchange for the day
+func (cs *CronService) DealsUpdateJob() error {
+	jobName := "Deals UPDATE Job "
+	counts, err := cs.dealService.UpdateDeals()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"bulk":  counts["bulk"],
+		"block": counts["block"],
+	})
+	return nil
+}
+
+// CloseReconcileJob compares the day's recorded last prices with the bhavcopy closes and notifies the divergences
+func (cs *CronService) CloseReconcileJob() error {
+	jobName := "Close RECONCILE Job "
+	report, err := cs.closeReconcile.Reconcile(MarketToday())
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	if report.Divergent > 0 {
+		cs.notifier.NotifyReport(models.NotificationCategoryReports, "Close reconciliation "+report.Date, func(f reportFormat) string {
+			var message strings.Builder
+			fmt.Fprintf(&message, "Instruments compared: %s\n", f.Int(int64(report.Compared)))
+			fmt.Fprintf(&message, "Instruments not in the bhavcopy: %s\n", f.Int(int64(report.Unmatched)))
+			fmt.Fprintf(&message, "Divergences above %v%%: %s\n", report.TolerancePercent, f.Int(int64(report.Divergent)))
+			for i, divergence := range report.Divergences {
+				if i == eodReportMaxSilent {
+					fmt.Fprintf(&message, "  ... and %d more\n", len(report.Divergences)-eodReportMaxSilent)
+					break
+				}
+				fmt.Fprintf(&message, "  %s recorded %s close %s (%v%%)\n", divergence.Instrument,
+					f.Decimal(divergence.RecordedPrice), f.Decimal(divergence.OfficialClose), divergence.DiffPercent)
+			}
+			return message.String()
+		})
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"compared":  report.Compared,
+		"unmatched": report.Unmatched,
+		"divergent": report.Divergent,
+	})
+	return nil
+}
+
+// AnnouncementsUpdateJob stores the new exchange announcements and publishes them to the news stream
+func (cs *CronService) AnnouncementsUpdateJob() error {
+	jobName := "Announcements UPDATE Job "
+	count, err := cs.newsService.UpdateAnnouncements()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Debug(jobName, zaplogger.Fields{
+		"new": count,
+	})
+	return nil
+}
+
+// ApiInstrumentsDeltaSyncJob inserts the instruments listed since the morning load and,
+// when enabled, subscribes the ones matching the ticker instrument presets
+func (cs *CronService) ApiInstrumentsDeltaSyncJob() error {
+	jobName := "API Instruments DELTA SYNC Job "
+	newInstruments, err := cs.instrumentService.SyncNewInstruments(context.Background())
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "SyncNewInstruments",
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"step": "SyncNewInstruments",
+		"new":  len(newInstruments),
+	})
+
+	autoSubscribe, _ := strconv.ParseBool(cs.cfg.InstrumentsDeltaAutoSubscribe)
+	if len(newInstruments) == 0 || !autoSubscribe {
+		return nil
+	}
+
+	userId := cs.cfg.KitetickerUserID
+	for mode, instruments := range matchTickerInstrumentPresets(newInstruments) {
+		result, err := cs.tickerService.SubscribeInstruments(userId, instruments, mode)
+		if err != nil {
+			zaplogger.Error(jobName, zaplogger.Fields{
+				"step":  "SubscribeInstruments",
+				"mode":  mode,
+				"error": err.Error(),
+			})
+			return err
+		}
+		zaplogger.Info(jobName, zaplogger.Fields{
+			"step":        "SubscribeInstruments",
+			"mode":        mode,
+			"inserted":    result.Inserted,
+			"updated":     result.Updated,
+			"blacklisted": result.Blacklisted,
+		})
+	}
+	return nil
+}
+
+// DatabaseMaintenanceJob prunes, vacuums and reindexes the database tables
+func (cs *CronService) DatabaseMaintenanceJob() error {
+	jobName := "Database MAINTENANCE Job "
+	result, err := cs.maintenance.RunMaintenance()
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	for _, table := range result.Tables {
+		fields := zaplogger.Fields{
+			"table":           table.Table,
+			"pruned":          table.Pruned,
+			"vacuumed":        table.Vacuumed,
+			"reindexed":       table.Reindexed,
+			"size_before":     table.SizeBefore,
+			"size_after":      table.SizeAfter,
+			"bytes_reclaimed": table.BytesReclaimed,
+		}
+		if table.Error != "" {
+			fields["error"] = table.Error
+			zaplogger.Error(jobName, fields)
+			continue
+		}
+		zaplogger.Info(jobName, fields)
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"pruned":          result.Pruned,
+		"bytes_reclaimed": result.BytesReclaimed,
+		"duration_ms":     result.DurationMs,
+	})
+
+	// the nightly table sizes are the basis of the storage growth rates
+	if _, err := NewStorageService(cs.db).SnapshotStorage(); err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "SnapshotStorage",
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// CandleBackfillJob backfills the gaps of today and the candleBackfillSessions sessions before in the 1 minute candles
+// of the ticker instruments from the kite historical API using the ticker user's session
+func (cs *CronService) CandleBackfillJob() error {
+	jobName := "Candle BACKFILL Job "
+	session, err := cs.sessionService.GetSession(cs.cfg.KitetickerUserID)
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "GetSession",
+			"error": err.Error(),
+		})
+		return err
+	}
+	tickerInstruments, err := cs.tickerService.GetTickerInstruments(cs.cfg.KitetickerUserID)
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "GetTickerInstruments",
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	// validated when the config is loaded
+	minMinutes, _ := strconv.Atoi(cs.cfg.CandleGapMinMinutes)
+	// the gaps of the earlier sessions are filled too, e.g. of a day the job failed or did not run
+	now := time.Now().In(MarketLocation)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, MarketLocation)
+	calendar := getTickSessionCalendar()
+	for i := 0; i < candleBackfillSessions; i++ {
+		from = calendar.previousTradingDay(from)
+	}
+
+	var gaps, failed int
+	var inserted int64
+	for _, tickerInstrument := range tickerInstruments {
+		result, err := cs.candleService.BackfillCandles(context.Background(), session.UserId, session.Enctoken, tickerInstrument.Instrument, from, now, minMinutes, HistoricalPriorityBackfill)
+		if err != nil {
+			failed++
+			zaplogger.Warn(jobName, zaplogger.Fields{
+				"step":       "BackfillCandles",
+				"instrument": tickerInstrument.Instrument,
+				"error":      err.Error(),
+			})
+			continue
+		}
+		gaps += result.Gaps
+		inserted += result.Inserted
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"instruments": len(tickerInstruments),
+		"gaps":        gaps,
+		"inserted":    inserted,
+		"failed":      failed,
+	})
+	if failed > 0 {
+		return fmt.Errorf("failed to backfill %d of %d instruments", failed, len(tickerInstruments))
+	}
+	return nil
+}
+
+// GreeksBackfillJob computes the historical greeks of the options of the recent sessions from their candles
+func (cs *CronService) GreeksBackfillJob() error {
+	jobName := "Greeks BACKFILL Job "
+	results, err := cs.greeksService.BackfillSessions()
+	for _, result := range results {
+		zaplogger.Info(jobName, zaplogger.Fields{
+			"date":      result.Date,
+			"contracts": result.Contracts,
+			"samples":   result.Samples,
+			"computed":  result.Computed,
+			"skipped":   result.Skipped,
+		})
+	}
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "BackfillSessions",
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"sessions": len(results),
+	})
+	return nil
+}
+
+// SessionKeepAliveJob exercises the stored enctokens and alerts the users whose session has died since
+// the last check, before the next ticker restart finds out
+func (cs *CronService) SessionKeepAliveJob() error {
+	jobName := "Session KEEP ALIVE Job "
+	transitions, err := cs.sessionService.PingSessions()
+	for _, check := range transitions {
+		zaplogger.Info(jobName, zaplogger.Fields{
+			"user_id":    check.UserId,
+			"valid":      check.Valid,
+			"login_time": check.LoginTime,
+		})
+		if !check.Valid {
+			cs.notifier.NotifyUser(check.UserId, models.NotificationCategorySessionExpiry, "Session expired",
+				"The session of "+check.UserId+" is no longer valid, it has to be regenerated")
+		}
+	}
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "PingSessions",
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// BackupJob writes the backup of the critical tables to the backup store, skipped when no store is configured
+func (cs *CronService) BackupJob() error {
+	jobName := "Tables BACKUP Job "
+	if cs.cfg.BackupURL == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cronJobTimeout)
+	defer cancel()
+	result, err := cs.backupService.Backup(ctx)
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"error": err.Error(),
+		})
+		return err
+	}
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"location": result.Location,
+		"bytes":    result.Bytes,
+		"rows":     result.Rows,
+	})
+	return nil
+}
+
+// eodReportMaxSilent is the maximum number of instruments without ticks listed in the end of day report
+const eodReportMaxSilent = 20
+
+// EODReportJob sends the end of day report of the ticker and the cron jobs to the users subscribed to reports
+func (cs *CronService) EODReportJob() error {
+	jobName := "EOD REPORT Job "
+	today := MarketToday()
+
+	tickerInstruments, err := cs.tickerService.GetTickerInstruments(cs.cfg.KitetickerUserID)
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "GetTickerInstruments",
+			"error": err.Error(),
+		})
+		return err
+	}
+	stats, err := repository.NewTickStatsRepository(cs.db).GetTickStats(today)
+	if err != nil {
+		zaplogger.Error(jobName, zaplogger.Fields{
+			"step":  "GetTickStats",
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	ticked := make(map[string]bool, len(stats))
+	var totalTicks int64
+	for _, stat := range stats {
+		ticked[stat.Instrument] = true
+		totalTicks += stat.Ticks
+	}
+	silent := make([]string, 0)
+	for _, tickerInstrument := range tickerInstruments {
+		if !ticked[tickerInstrument.Instrument] {
+			silent = append(silent, tickerInstrument.Instrument)
+		}
+	}
+	sort.Strings(silent)
+
+	now := time.Now().In(MarketLocation)
+	failedJobs := cs.failedJobsSince(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, MarketLocation))
+
+	// rendered for each user in their time zone and number format
+	cs.notifier.NotifyReport(models.NotificationCategoryReports, "End of day report "+today, func(f reportFormat) string {
+		var report strings.Builder
+		fmt.Fprintf(&report, "Ticker instruments: %s\n", f.Int(int64(len(tickerInstruments))))
+		fmt.Fprintf(&report, "Instruments ticked: %s\n", f.Int(int64(len(stats))))
+		fmt.Fprintf(&report, "Total ticks: %s\n", f.Int(totalTicks))
+		fmt.Fprintf(&report, "Instruments without ticks: %s\n", f.Int(int64(len(silent))))
+		for i, instrument := range silent {
+			if i == eodReportMaxSilent {
+				fmt.Fprintf(&report, "  ... and %d more\n", len(silent)-eodReportMaxSilent)
+				break
+			}
+			fmt.Fprintf(&report, "  %s\n", instrument)
+		}
+		fmt.Fprintf(&report, "Failed jobs: %d\n", len(failedJobs))
+		for _, failedJob := range failedJobs {
+			fmt.Fprintf(&report, "  %s at %s: %s\n", failedJob.name, f.Time(failedJob.startedAt), failedJob.err)
+		}
+		return report.String()
+	})
+	zaplogger.Info(jobName, zaplogger.Fields{
+		"ticked":      len(stats),
+		"silent":      len(silent),
+		"failed_jobs": len(failedJobs),
+	})
+	return nil
+}
+
+// cronJobFailure is a failed run of a job
+type cronJobFailure struct {
+	name      string
+	startedAt time.Time
+	err       string
+}
+
+// failedJobsSince returns the jobs whose latest run started after the time and failed, sorted by name
+func (cs *CronService) failedJobsSince(since time.Time) []cronJobFailure {
+	cs.runsMu.Lock()
+	defer cs.runsMu.Unlock()
+	failed := make([]cronJobFailure, 0)
+	for name, run := range cs.runs {
+		if run.startedAt.Before(since) {
+			continue
+		}
+		select {
+		case <-run.done:
+			if run.err != nil {
+				failed = append(failed, cronJobFailure{name: name, startedAt: run.startedAt, err: run.err.Error()})
+			}
+		default:
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].name < failed[j].name })
+	return failed
 }
 
 // // getNFOFilterMonths gets the NFO filter months
@@ -0,0 +1,50 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// simulatedScheduleInterval is how often, in real time, the simulated schedule checks for due jobs
+const simulatedScheduleInterval = 100 * time.Millisecond
+
+// simulatedCronJob is a scheduled job run on the simulated market clock
+type simulatedCronJob struct {
+	schedule cron.Schedule
+	next     time.Time
+	run      func()
+}
+
+// addSimulatedJob schedules the job on the simulated market clock instead of the cron scheduler
+func (cs *CronService) addSimulatedJob(schedule string, run func()) error {
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return err
+	}
+	cs.simulatedJobs = append(cs.simulatedJobs, &simulatedCronJob{
+		schedule: parsed,
+		next:     parsed.Next(MarketNow()),
+		run:      run,
+	})
+	return nil
+}
+
+// runSimulatedSchedule runs the jobs whose time has come on the simulated market clock, jobs due at the same
+// time start in the order they were scheduled, like the cron scheduler each run is on its own goroutine
+func (cs *CronService) runSimulatedSchedule() {
+	ticker := time.NewTicker(simulatedScheduleInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := MarketNow()
+		for _, job := range cs.simulatedJobs {
+			if job.next.After(now) {
+				continue
+			}
+			go job.run()
+			job.next = job.schedule.Next(now)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// ErrRealTimeDataRestricted is returned for real-time data requested by a user restricted to delayed data
+var ErrRealTimeDataRestricted = errors.New("real-time market data is not permitted for this user, use the delayed quotes")
+
+// DataDelays is the process wide delay of the users restricted to delayed market data
+type DataDelays struct {
+	mu     sync.RWMutex
+	delays map[string]time.Duration
+}
+
+var (
+	dataDelays     *DataDelays
+	dataDelaysOnce sync.Once
+)
+
+// GetDataDelays returns the process wide data delays
+func GetDataDelays() *DataDelays {
+	dataDelaysOnce.Do(func() {
+		dataDelays = &DataDelays{delays: make(map[string]time.Duration)}
+	})
+	return dataDelays
+}
+
+// Delay returns the delay of the market data of the user, 0 for real-time data
+func (d *DataDelays) Delay(userID string) time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.delays[userID]
+}
+
+// set replaces the data delays
+func (d *DataDelays) set(delays []models.DataDelayModel) {
+	byUser := make(map[string]time.Duration, len(delays))
+	for _, delay := range delays {
+		byUser[delay.UserID] = time.Duration(delay.DelayMinutes) * time.Minute
+	}
+	d.mu.Lock()
+	d.delays = byUser
+	d.mu.Unlock()
+}
+
+// update sets the delay of the user, a zero delay removes it
+func (d *DataDelays) update(userID string, delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if delay <= 0 {
+		delete(d.delays, userID)
+		return
+	}
+	d.delays[userID] = delay
+}
+
+// getDefaultDataDelayMinutes returns the delay of a user restricted without an explicit delay
+func getDefaultDataDelayMinutes() int {
+	cfg, err := config.Get()
+	if err != nil {
+		return 15
+	}
+	// validated when the config is loaded
+	minutes, _ := strconv.Atoi(cfg.DataDelayMinutes)
+	return minutes
+}
+
+// DataDelayService is the service for restricting users to delayed market data
+type DataDelayService struct {
+	repo *repository.DataDelayRepository
+}
+
+// NewDataDelayService creates a new data delay service
+func NewDataDelayService(db *gorm.DB) *DataDelayService {
+	return &DataDelayService{repo: repository.NewDataDelayRepository(db)}
+}
+
+// Load loads the data delays into the process wide state
+func (s *DataDelayService) Load() (int, error) {
+	delays, err := s.repo.GetDataDelays()
+	if err != nil {
+		return 0, err
+	}
+	GetDataDelays().set(delays)
+	return len(delays), nil
+}
+
+// GetDataDelays returns the users restricted to delayed market data
+func (s *DataDelayService) GetDataDelays() ([]models.DataDelayModel, error) {
+	return s.repo.GetDataDelays()
+}
+
+// SetDataDelay restricts the user to market data delayed by the minutes, the configured default delay
+// when minutes is 0. It applies to the user's next requests, running streams are not stopped
+func (s *DataDelayService) SetDataDelay(userID string, minutes int, reason, actor string) (models.DataDelayModel, error) {
+	if minutes <= 0 {
+		minutes = getDefaultDataDelayMinutes()
+	}
+	delay := models.DataDelayModel{UserID: userID, DelayMinutes: minutes, Reason: reason, SetBy: actor}
+	if err := s.repo.UpsertDataDelay(&delay); err != nil {
+		return delay, err
+	}
+	GetDataDelays().update(userID, time.Duration(minutes)*time.Minute)
+	zaplogger.Info("Data delay set", zaplogger.Fields{
+		"user_id":       userID,
+		"delay_minutes": minutes,
+		"actor":         actor,
+		"reason":        reason,
+	})
+	return delay, nil
+}
+
+// RemoveDataDelay gives the user real-time market data again, gorm.ErrRecordNotFound is returned when
+// the user has no data delay
+func (s *DataDelayService) RemoveDataDelay(userID, actor string) error {
+	removed, err := s.repo.DeleteDataDelay(userID)
+	if err != nil {
+		return err
+	}
+	GetDataDelays().update(userID, 0)
+	if !removed {
+		return gorm.ErrRecordNotFound
+	}
+	zaplogger.Info("Data delay removed", zaplogger.Fields{
+		"user_id": userID,
+		"actor":   actor,
+	})
+	return nil
+}
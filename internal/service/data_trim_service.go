@@ -0,0 +1,194 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// dataTrimTokenTTL is how long the confirmation token of a dry run is valid
+const dataTrimTokenTTL = 5 * time.Minute
+
+// dataTrimAction is the activity action of a trim
+const dataTrimAction = "admin.data.trim"
+
+var (
+	// ErrDataTrimInvalidToken is returned when the confirmation token is unknown, expired or for another trim
+	ErrDataTrimInvalidToken = errors.New("invalid or expired confirmation token, run the dry run again")
+	// ErrDataTrimRowsChanged is returned when the rows to delete changed since the dry run
+	ErrDataTrimRowsChanged = errors.New("the rows to delete changed since the dry run, run the dry run again")
+)
+
+// dataTrimColumn is the column a table is trimmed by, date columns hold YYYY-MM-DD strings
+type dataTrimColumn struct {
+	name string
+	date bool
+}
+
+// dataTrimTables are the tables that can be trimmed with the column their rows are aged by, the audit tables
+// of the activity and kill switch events are never trimmed
+var dataTrimTables = map[string]dataTrimColumn{
+	models.CandlesTableName:              {"timestamp", false},
+	models.FuturesBasisTableName:         {"timestamp", false},
+	models.PrevClosesTableName:           {"date", true},
+	models.PriceBandsTableName:           {"date", true},
+	models.DealsTableName:                {"date", true},
+	models.CloseReconciliationsTableName: {"date", true},
+	models.CloseDivergencesTableName:     {"date", true},
+	models.AnnouncementsTableName:        {"published_at", false},
+	models.TickStatsTableName:            {"date", true},
+	models.StreamUsageTableName:          {"date", true},
+	models.InstrumentAccessTableName:     {"date", true},
+	models.StorageSnapshotsTableName:     {"date", true},
+	models.TickerLogTableName:            {"timestamp", false},
+	models.JobsTableName:                 {"created_at", false},
+	models.SessionChecksTableName:        {"checked_at", false},
+}
+
+// DataTrimTables returns the names of the tables that can be trimmed
+func DataTrimTables() []string {
+	tables := make([]string, 0, len(dataTrimTables))
+	for table := range dataTrimTables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// IsDataTrimTable returns true if the table can be trimmed
+func IsDataTrimTable(table string) bool {
+	_, ok := dataTrimTables[table]
+	return ok
+}
+
+// dataTrimConfirmation is the pending deletion confirmed by a token
+type dataTrimConfirmation struct {
+	table     string
+	before    string
+	rows      int64
+	expiresAt time.Time
+}
+
+// DataTrimService is the service for deleting old rows of the historical tables, a deletion needs the token
+// of a dry run that counted the same rows. Every deletion is recorded in the activity of the admin
+type DataTrimService struct {
+	repo          *repository.MaintenanceRepository
+	activity      *ActivityService
+	mu            sync.Mutex
+	confirmations map[string]dataTrimConfirmation
+}
+
+// NewDataTrimService creates a new data trim service
+func NewDataTrimService(db *gorm.DB) *DataTrimService {
+	return &DataTrimService{
+		repo:          repository.NewMaintenanceRepository(db),
+		activity:      NewActivityService(db),
+		confirmations: make(map[string]dataTrimConfirmation),
+	}
+}
+
+// DryRun counts the rows of the table before the date and returns a token confirming their deletion
+func (s *DataTrimService) DryRun(table string, before time.Time) (models.DataTrimResult, error) {
+	result, cutoff := newDataTrimResult(table, before)
+	result.DryRun = true
+
+	rows, err := s.repo.CountOlderThan(table, result.Column, cutoff)
+	if err != nil {
+		return result, err
+	}
+	result.Rows = rows
+
+	token, err := newDataTrimToken()
+	if err != nil {
+		return result, err
+	}
+	expiresAt := time.Now().Add(dataTrimTokenTTL)
+
+	s.mu.Lock()
+	for t, confirmation := range s.confirmations {
+		if time.Now().After(confirmation.expiresAt) {
+			delete(s.confirmations, t)
+		}
+	}
+	s.confirmations[token] = dataTrimConfirmation{table: table, before: result.Before, rows: rows, expiresAt: expiresAt}
+	s.mu.Unlock()
+
+	result.ConfirmToken = token
+	result.ExpiresAt = &expiresAt
+	return result, nil
+}
+
+// Trim deletes the rows of the table before the date, the token must come from a dry run of the same trim
+// and the rows must not have changed since
+func (s *DataTrimService) Trim(table string, before time.Time, token, actor string) (models.DataTrimResult, error) {
+	result, cutoff := newDataTrimResult(table, before)
+
+	s.mu.Lock()
+	confirmation, ok := s.confirmations[token]
+	if ok {
+		delete(s.confirmations, token)
+	}
+	s.mu.Unlock()
+	if !ok || time.Now().After(confirmation.expiresAt) || confirmation.table != table || confirmation.before != result.Before {
+		return result, ErrDataTrimInvalidToken
+	}
+
+	rows, err := s.repo.CountOlderThan(table, result.Column, cutoff)
+	if err != nil {
+		return result, err
+	}
+	result.Rows = rows
+	if rows != confirmation.rows {
+		return result, ErrDataTrimRowsChanged
+	}
+
+	deleted, err := s.repo.PruneOlderThan(table, result.Column, cutoff)
+	result.Deleted = deleted
+	details := map[string]interface{}{
+		"table":   table,
+		"before":  result.Before,
+		"rows":    rows,
+		"deleted": deleted,
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	s.activity.Record(models.ActivityEventModel{UserID: actor, Action: dataTrimAction}, details)
+	details["actor"] = actor
+	zaplogger.Info("data trimmed", details)
+	return result, err
+}
+
+// newDataTrimResult returns the result of a trim of the table with the cutoff for its column,
+// the table must be one of the trimmable tables
+func newDataTrimResult(table string, before time.Time) (models.DataTrimResult, interface{}) {
+	column := dataTrimTables[table]
+	result := models.DataTrimResult{
+		Table:  table,
+		Column: column.name,
+		Before: before.Format("2006-01-02"),
+	}
+	if column.date {
+		return result, result.Before
+	}
+	return result, time.Date(before.Year(), before.Month(), before.Day(), 0, 0, 0, 0, MarketLocation)
+}
+
+// newDataTrimToken returns a random confirmation token
+func newDataTrimToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate confirmation token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
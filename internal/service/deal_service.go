@@ -0,0 +1,122 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// DealService is the service for the block and bulk deals
+type DealService struct {
+	repo *repository.DealRepository
+}
+
+// NewDealService creates a new deal service
+func NewDealService(db *gorm.DB) *DealService {
+	return &DealService{
+		repo: repository.NewDealRepository(db),
+	}
+}
+
+// UpdateDeals downloads the NSE bulk and block deal files and stores their deals, a blank url skips the file
+func (s *DealService) UpdateDeals() (map[string]int64, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64)
+	for dealType, url := range map[string]string{
+		models.DealTypeBulk:  cfg.BulkDealsURL,
+		models.DealTypeBlock: cfg.BlockDealsURL,
+	} {
+		if url == "" {
+			continue
+		}
+		records, err := fetchExchangeCSV(url)
+		if err != nil {
+			return counts, fmt.Errorf("failed to update %s deals: %v", dealType, err)
+		}
+		deals, err := parseDealRecords(dealType, records)
+		if err != nil {
+			return counts, fmt.Errorf("failed to update %s deals: %v", dealType, err)
+		}
+		count, err := s.repo.ReplaceDeals(dealType, deals)
+		if err != nil {
+			return counts, err
+		}
+		counts[dealType] = count
+	}
+	return counts, nil
+}
+
+// parseDealRecords parses the rows of a deal file, the columns are located by their header
+func parseDealRecords(dealType string, records [][]string) ([]models.DealModel, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := csvColumns(records[0])
+	for name := range columns {
+		// the price column is titled "Trade Price / Wght. Avg. Price"
+		if strings.HasPrefix(name, "trade price") {
+			columns["price"] = columns[name]
+		}
+	}
+	for _, name := range []string{"date", "symbol", "client name", "buy/sell", "quantity traded", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("deal file has no %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	deals := make([]models.DealModel, 0, len(records)-1)
+	for _, record := range records[1:] {
+		symbol := field(record, "symbol")
+		if symbol == "" {
+			continue
+		}
+		// dates are published as 14-OCT-2026, month names are parsed case insensitively
+		date, err := time.Parse("02-Jan-2006", field(record, "date"))
+		if err != nil {
+			continue
+		}
+		quantity, err := strconv.ParseInt(strings.ReplaceAll(field(record, "quantity traded"), ",", ""), 10, 64)
+		if err != nil {
+			continue
+		}
+		price, err := strconv.ParseFloat(strings.ReplaceAll(field(record, "price"), ",", ""), 64)
+		if err != nil {
+			continue
+		}
+		deals = append(deals, models.DealModel{
+			Type:         dealType,
+			Date:         date.Format("2006-01-02"),
+			Symbol:       symbol,
+			SecurityName: field(record, "security name"),
+			ClientName:   field(record, "client name"),
+			Side:         strings.ToUpper(field(record, "buy/sell")),
+			Quantity:     quantity,
+			Price:        price,
+			Remarks:      field(record, "remarks"),
+		})
+	}
+	return deals, nil
+}
+
+// GetDeals gets the deals of a date, optionally for a symbol and a type
+func (s *DealService) GetDeals(date, symbol, dealType string) ([]models.DealModel, error) {
+	return s.repo.GetDeals(date, strings.ToUpper(symbol), dealType)
+}
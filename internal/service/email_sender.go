@@ -0,0 +1,126 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// emailTemplates are the email bodies of the notification categories, other categories use the default template
+var emailTemplates = map[string]*template.Template{
+	models.NotificationCategoryReports: template.Must(template.New("report").Parse(
+		`End of day report for {{.UserID}}, {{.Timestamp.Format "02 Jan 2006"}}
+
+{{.Message}}
+`)),
+	models.NotificationCategorySessionExpiry: template.Must(template.New("session_expiry").Parse(
+		`Session expiry warning for {{.UserID}}
+
+{{.Message}}
+
+Log in again or restart the ticker to resume the market data feed.
+Sent at {{.Timestamp.Format "15:04:05 MST, 02 Jan 2006"}}
+`)),
+	models.NotificationCategoryAlerts: template.Must(template.New("alert").Parse(
+		`Alert triggered: {{.Subject}}
+
+{{.Message}}
+
+Triggered at {{.Timestamp.Format "15:04:05 MST, 02 Jan 2006"}}
+`)),
+}
+
+// defaultEmailTemplate is the email body of notifications without a category template
+var defaultEmailTemplate = template.Must(template.New("default").Parse(
+	`{{.Subject}}
+
+{{.Message}}
+
+Sent at {{.Timestamp.Format "15:04:05 MST, 02 Jan 2006"}}
+`))
+
+// emailSender sends notifications by email over SMTP, Amazon SES is used through its SMTP interface
+type emailSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// newEmailSender creates an email sender from the config, the sender is disabled without an SMTP host and sender address
+func newEmailSender() *emailSender {
+	cfg, err := config.Get()
+	if err != nil {
+		return &emailSender{}
+	}
+	return &emailSender{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+		from:     cfg.EmailFrom,
+	}
+}
+
+// Channel returns the channel of the sender
+func (e *emailSender) Channel() string {
+	return models.NotificationChannelEmail
+}
+
+// Send emails the notification to the user
+func (e *emailSender) Send(preferences models.NotificationPreferencesModel, notification models.Notification) error {
+	if preferences.Email == "" || e.host == "" || e.from == "" {
+		return errNotificationChannelDisabled
+	}
+	from, err := mail.ParseAddress(e.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address %s: %v", e.from, err)
+	}
+
+	body, err := renderEmailBody(notification)
+	if err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", preferences.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.ReplaceAll(notification.Subject, "\n", " "))
+	fmt.Fprintf(&msg, "Date: %s\r\n", notification.Timestamp.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if e.username != "" {
+		auth = smtp.PlainAuth("", e.username, e.password, e.host)
+	}
+	if err := smtp.SendMail(net.JoinHostPort(e.host, e.port), auth, from.Address, []string{preferences.Email}, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+// renderEmailBody renders the email body of the notification with the template of its category, the
+// timestamp is shown in the time zone the notification was rendered in
+func renderEmailBody(notification models.Notification) (string, error) {
+	tmpl, ok := emailTemplates[notification.Category]
+	if !ok {
+		tmpl = defaultEmailTemplate
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, notification); err != nil {
+		return "", fmt.Errorf("failed to render email: %v", err)
+	}
+	return body.String(), nil
+}
@@ -0,0 +1,57 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exchangeUserAgent is sent with exchange file requests, the exchange rejects requests without a browser user agent
+const exchangeUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+
+// fetchExchangeCSV downloads and parses a CSV file published by the exchange
+func fetchExchangeCSV(url string) ([][]string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", exchangeUserAgent)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+
+	reader := csv.NewReader(resp.Body)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %v", err)
+	}
+	return records, nil
+}
+
+// csvColumns maps the lower cased header names of a CSV file to their index
+func csvColumns(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return columns
+}
+
+// nseInstrument returns the NSE:tradingsymbol of a symbol and series of an exchange file,
+// kite tradingsymbols carry the series for everything but EQ
+func nseInstrument(symbol, series string) string {
+	if series != "" && series != "EQ" {
+		return "NSE:" + symbol + "-" + series
+	}
+	return "NSE:" + symbol
+}
@@ -0,0 +1,218 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/greeks"
+	"gorm.io/gorm"
+)
+
+// greeksContractBatch is how many options have their candles loaded and greeks stored at once
+const greeksContractBatch = 500
+
+// GreeksService is the service for the historical option greeks
+type GreeksService struct {
+	repo       *repository.GreeksRepository
+	candleRepo *repository.CandleRepository
+}
+
+// NewGreeksService creates a new greeks service
+func NewGreeksService(db *gorm.DB) *GreeksService {
+	return &GreeksService{
+		repo:       repository.NewGreeksRepository(db),
+		candleRepo: repository.NewCandleRepository(db),
+	}
+}
+
+// greeksSettings returns the sampling interval, risk free rate and lookback days of the backfill, the defaults
+// when the config can not be loaded
+func greeksSettings() (int, float64, int) {
+	cfg, err := config.Get()
+	if err != nil {
+		return 15, 0.065, 30
+	}
+	// validated when the config is loaded
+	interval, _ := strconv.Atoi(cfg.GreeksIntervalMinutes)
+	rate, _ := strconv.ParseFloat(cfg.GreeksRiskFreeRate, 64)
+	days, _ := strconv.Atoi(cfg.GreeksBackfillDays)
+	return interval, rate, days
+}
+
+// BackfillSessions computes the greeks of the sessions in the lookback days, today included, with candles but
+// without greeks, oldest first
+func (s *GreeksService) BackfillSessions() ([]models.GreeksBackfillResult, error) {
+	_, _, lookbackDays := greeksSettings()
+	now := time.Now().In(MarketLocation)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, MarketLocation).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -lookbackDays)
+
+	_, offset := from.Zone()
+	candleDays, err := s.candleRepo.GetCandleDays(from, to, offset)
+	if err != nil {
+		return nil, err
+	}
+	greeksDays, err := s.repo.GetGreeksDays(from, to, offset)
+	if err != nil {
+		return nil, err
+	}
+	computed := make(map[int64]bool, len(greeksDays))
+	for _, day := range greeksDays {
+		computed[day] = true
+	}
+	sort.Slice(candleDays, func(i, j int) bool { return candleDays[i] < candleDays[j] })
+
+	results := make([]models.GreeksBackfillResult, 0)
+	for _, day := range candleDays {
+		if computed[day] {
+			continue
+		}
+		date := time.Unix(day*86400-int64(offset), 0).In(MarketLocation)
+		result, err := s.BackfillGreeks(date)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// BackfillGreeks computes the implied volatility and greeks of the options with candles on the date, at the close
+// of their last candle in every sampling interval, from the underlying close at the same minute. Samples without
+// an underlying price or whose price has no implied volatility, e.g. below the intrinsic value, are skipped
+func (s *GreeksService) BackfillGreeks(date time.Time) (models.GreeksBackfillResult, error) {
+	interval, rate, _ := greeksSettings()
+	local := date.In(MarketLocation)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, MarketLocation)
+	to := from.AddDate(0, 0, 1)
+	result := models.GreeksBackfillResult{Date: from.Format("2006-01-02")}
+
+	contracts, err := s.repo.GetOptionContracts(result.Date, from, to)
+	if err != nil {
+		return result, err
+	}
+	result.Contracts = len(contracts)
+
+	for start := 0; start < len(contracts); start += greeksContractBatch {
+		batch := contracts[start:min(start+greeksContractBatch, len(contracts))]
+		rows, samples, err := s.computeGreeks(batch, from, to, interval, rate)
+		if err != nil {
+			return result, err
+		}
+		if err := s.repo.UpsertOptionGreeks(rows); err != nil {
+			return result, err
+		}
+		result.Samples += samples
+		result.Computed += len(rows)
+	}
+	result.Skipped = result.Samples - result.Computed
+	return result, nil
+}
+
+// computeGreeks computes the greeks of the sampled candles of the options, it returns the greeks and the number
+// of samples
+func (s *GreeksService) computeGreeks(contracts []models.OptionContract, from, to time.Time, interval int, rate float64) ([]models.OptionGreeksModel, int, error) {
+	byInstrument := make(map[string]models.OptionContract, len(contracts))
+	underlyingOf := make(map[string]string, len(contracts))
+	optionInstruments := make([]string, 0, len(contracts))
+	underlyingSet := make(map[string]bool)
+	for _, contract := range contracts {
+		underlying := futuresSpotInstrument(models.InstrumentModel{Name: contract.Name, Exchange: contract.Exchange})
+		byInstrument[contract.Instrument] = contract
+		underlyingOf[contract.Instrument] = underlying
+		optionInstruments = append(optionInstruments, contract.Instrument)
+		underlyingSet[underlying] = true
+	}
+	underlyings := make([]string, 0, len(underlyingSet))
+	for underlying := range underlyingSet {
+		underlyings = append(underlyings, underlying)
+	}
+
+	optionCandles, err := s.candleRepo.GetSampledCandles(optionInstruments, from, to, interval)
+	if err != nil {
+		return nil, 0, err
+	}
+	// every 1 minute candle of the underlyings, ordered by timestamp
+	underlyingCandles, err := s.candleRepo.GetSampledCandles(underlyings, from, to, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	underlyingSeries := make(map[string][]models.CandleModel, len(underlyings))
+	for _, candle := range underlyingCandles {
+		underlyingSeries[candle.Instrument] = append(underlyingSeries[candle.Instrument], candle)
+	}
+
+	rows := make([]models.OptionGreeksModel, 0, len(optionCandles))
+	for _, candle := range optionCandles {
+		contract := byInstrument[candle.Instrument]
+		underlying := underlyingOf[candle.Instrument]
+		spot, ok := closeAtOrBefore(underlyingSeries[underlying], candle.Timestamp)
+		if !ok {
+			continue
+		}
+		expiryAt, err := optionExpiryTime(contract)
+		if err != nil {
+			continue
+		}
+		// the close is the price at the end of the minute, the pricing model runs on floats
+		years := expiryAt.Sub(candle.Timestamp.Add(time.Minute)).Hours() / 24 / 365
+		iv, ok := greeks.ImpliedVolatility(contract.InstrumentType, candle.Close.Float64(), spot.Float64(), contract.Strike.Float64(), years, rate)
+		if !ok {
+			continue
+		}
+		g := greeks.Compute(contract.InstrumentType, spot.Float64(), contract.Strike.Float64(), years, rate, iv)
+		rows = append(rows, models.OptionGreeksModel{
+			InstrumentToken:      candle.InstrumentToken,
+			Timestamp:            candle.Timestamp,
+			Instrument:           candle.Instrument,
+			Name:                 contract.Name,
+			Expiry:               contract.Expiry,
+			Strike:               contract.Strike,
+			OptionType:           contract.InstrumentType,
+			UnderlyingInstrument: underlying,
+			UnderlyingPrice:      spot,
+			OptionPrice:          candle.Close,
+			IV:                   iv,
+			Delta:                g.Delta,
+			Gamma:                g.Gamma,
+			Theta:                g.Theta,
+			Vega:                 g.Vega,
+		})
+	}
+	return rows, len(optionCandles), nil
+}
+
+// closeAtOrBefore returns the close of the last candle starting at or before the timestamp
+func closeAtOrBefore(candles []models.CandleModel, timestamp time.Time) (decimal.Decimal, bool) {
+	i := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp.After(timestamp) })
+	if i == 0 {
+		return decimal.Zero, false
+	}
+	return candles[i-1].Close, true
+}
+
+// optionExpiryTime returns the close of the trading session of the option exchange on its expiry date
+func optionExpiryTime(contract models.OptionContract) (time.Time, error) {
+	expiry, err := time.ParseInLocation("2006-01-02", contract.Expiry, MarketLocation)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry %q of %s", contract.Expiry, contract.Instrument)
+	}
+	session, ok := tradingSessions[contract.Exchange]
+	if !ok {
+		session = tradingSessions["NSE"]
+	}
+	return expiry.Add(time.Duration(session.close) * time.Minute), nil
+}
+
+// GetOptionGreeks gets the historical greeks of an option, or of the options of name optionally for an expiry,
+// between from and to
+func (s *GreeksService) GetOptionGreeks(instrument, name, expiry string, from, to time.Time) ([]models.OptionGreeksModel, error) {
+	return s.repo.GetOptionGreeks(instrument, name, expiry, from, to)
+}
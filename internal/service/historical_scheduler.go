@@ -0,0 +1,196 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// Historical request priorities, interactive requests are served before any queued backfill request
+const (
+	HistoricalPriorityInteractive = "interactive"
+	HistoricalPriorityBackfill    = "backfill"
+)
+
+// historicalPriorities are the priorities in the order they are served
+var historicalPriorities = []string{HistoricalPriorityInteractive, HistoricalPriorityBackfill}
+
+const (
+	// historicalMaxQueued is the most requests queued per priority
+	historicalMaxQueued = 10000
+	// historicalThrottleBackoff pauses the requests after kite rate limits one
+	historicalThrottleBackoff = 10 * time.Second
+	// historicalMaxThrottleRetries is how often a rate limited request is retried
+	historicalMaxThrottleRetries = 3
+)
+
+// ErrHistoricalQueueFull is returned when too many historical requests are queued
+var ErrHistoricalQueueFull = errors.New("historical request queue full")
+
+// historicalRequest is a queued kite historical request
+type historicalRequest struct {
+	priority string
+	fn       func() error
+	done     chan error
+	retries  int
+}
+
+// HistoricalScheduler queues the kite historical requests and paces them within the kite rate limit,
+// so concurrent backfills share the limit instead of getting blocked
+type HistoricalScheduler struct {
+	mu            sync.Mutex
+	queues        map[string][]*historicalRequest
+	wake          chan struct{}
+	processed     map[string]int64
+	failed        map[string]int64
+	throttled     int64
+	lastRequestAt time.Time
+	pausedUntil   time.Time
+}
+
+var (
+	historicalScheduler     *HistoricalScheduler
+	historicalSchedulerOnce sync.Once
+)
+
+// GetHistoricalScheduler returns the process wide historical request scheduler
+func GetHistoricalScheduler() *HistoricalScheduler {
+	historicalSchedulerOnce.Do(func() {
+		historicalScheduler = &HistoricalScheduler{
+			queues:    make(map[string][]*historicalRequest),
+			wake:      make(chan struct{}, 1),
+			processed: make(map[string]int64),
+			failed:    make(map[string]int64),
+		}
+		recovery.Go("historical.run", historicalScheduler.run)
+	})
+	return historicalScheduler
+}
+
+// getHistoricalRequestInterval returns the interval between two historical requests
+func getHistoricalRequestInterval() time.Duration {
+	return time.Duration(float64(time.Second) / getHistoricalRequestsPerSecond())
+}
+
+// getHistoricalRequestsPerSecond returns the configured historical request rate
+func getHistoricalRequestsPerSecond() float64 {
+	cfg, err := config.Get()
+	if err != nil {
+		return 3
+	}
+	// validated when the config is loaded
+	rate, _ := strconv.ParseFloat(cfg.KiteHistoricalRequestsPerSecond, 64)
+	return rate
+}
+
+// Do queues fn at the priority and waits for it to run, a request kite rate limits is retried after a pause
+func (s *HistoricalScheduler) Do(priority string, fn func() error) error {
+	req := &historicalRequest{priority: priority, fn: fn, done: make(chan error, 1)}
+
+	s.mu.Lock()
+	if len(s.queues[priority]) >= historicalMaxQueued {
+		s.mu.Unlock()
+		return ErrHistoricalQueueFull
+	}
+	s.queues[priority] = append(s.queues[priority], req)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return <-req.done
+}
+
+// next removes and returns the first request of the highest priority, nil when nothing is queued
+func (s *HistoricalScheduler) next() *historicalRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, priority := range historicalPriorities {
+		if queue := s.queues[priority]; len(queue) > 0 {
+			s.queues[priority] = queue[1:]
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+// run serves the queued requests one at a time, spaced by the request interval
+func (s *HistoricalScheduler) run() {
+	for {
+		req := s.next()
+		if req == nil {
+			<-s.wake
+			continue
+		}
+
+		s.mu.Lock()
+		readyAt := s.lastRequestAt.Add(getHistoricalRequestInterval())
+		if s.pausedUntil.After(readyAt) {
+			readyAt = s.pausedUntil
+		}
+		s.mu.Unlock()
+		if wait := time.Until(readyAt); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		err := req.fn()
+
+		s.mu.Lock()
+		s.lastRequestAt = time.Now()
+		if errors.Is(err, errKiteHistoricalRateLimited) {
+			s.throttled++
+			s.pausedUntil = s.lastRequestAt.Add(historicalThrottleBackoff)
+			if req.retries < historicalMaxThrottleRetries {
+				// retried first once the pause is over
+				req.retries++
+				s.queues[req.priority] = append([]*historicalRequest{req}, s.queues[req.priority]...)
+				s.mu.Unlock()
+				zaplogger.Warn("Kite historical request rate limited", zaplogger.Fields{"priority": req.priority, "retry": req.retries})
+				continue
+			}
+		}
+		if err != nil {
+			s.failed[req.priority]++
+		} else {
+			s.processed[req.priority]++
+		}
+		s.mu.Unlock()
+		req.done <- err
+	}
+}
+
+// Status returns the queue depth per priority with the request counters
+func (s *HistoricalScheduler) Status() models.HistoricalQueueStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := models.HistoricalQueueStatus{
+		RequestsPerSecond: getHistoricalRequestsPerSecond(),
+		Queued:            make(map[string]int, len(historicalPriorities)),
+		Processed:         make(map[string]int64, len(historicalPriorities)),
+		Failed:            make(map[string]int64, len(historicalPriorities)),
+		Throttled:         s.throttled,
+	}
+	for _, priority := range historicalPriorities {
+		status.Queued[priority] = len(s.queues[priority])
+		status.Processed[priority] = s.processed[priority]
+		status.Failed[priority] = s.failed[priority]
+	}
+	if !s.lastRequestAt.IsZero() {
+		lastRequestAt := s.lastRequestAt
+		status.LastRequestAt = &lastRequestAt
+	}
+	if s.pausedUntil.After(time.Now()) {
+		pausedUntil := s.pausedUntil
+		status.PausedUntil = &pausedUntil
+	}
+	return status
+}
@@ -2,6 +2,7 @@
 package service
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/requestid"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/state"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 	"gorm.io/gorm"
@@ -48,6 +50,7 @@ type IndexService struct {
 	client         *http.Client
 	repo           *repository.IndexRepository
 	instrumentRepo *repository.InstrumentRepository
+	tickerRepo     *repository.TickerRepository
 	state          *state.State
 }
 
@@ -62,6 +65,7 @@ func NewIndexService(db *gorm.DB) *IndexService {
 		client:         &http.Client{},
 		repo:           repository.NewIndexRepository(db),
 		instrumentRepo: repository.NewInstrumentRepository(db),
+		tickerRepo:     repository.NewTickerRepository(db),
 		state:          stateManager,
 	}
 }
@@ -95,11 +99,27 @@ func (s *IndexService) GetIndexInstruments(exchange, index string) ([]models.Ins
 	return instruments, nil
 }
 
-// UpdateIndices updates the indices in the database
-func (s *IndexService) UpdateIndices() (int64, error) {
+// GetIndexLTP returns the last price of the index from its latest tick,
+// gorm.ErrRecordNotFound when the ticker has not streamed the index
+func (s *IndexService) GetIndexLTP(exchange, index string) (models.IndexLTP, error) {
+	indexTick, err := s.tickerRepo.GetIndexTick(exchange + ":" + index)
+	if err != nil {
+		return models.IndexLTP{}, err
+	}
+	return models.IndexLTP{
+		Instrument:      indexTick.Instrument,
+		InstrumentToken: indexTick.InstrumentToken,
+		LastPrice:       indexTick.LastPrice,
+		NetChange:       indexTick.NetChange,
+		Timestamp:       indexTick.Timestamp,
+	}, nil
+}
+
+// UpdateIndices updates the indices in the database, the request id of the context is sent with the downloads
+func (s *IndexService) UpdateIndices(ctx context.Context) (int64, error) {
 	var grandTotalInserted int64
 	// update NSE indices
-	totalInserted, err := s.updateNSEIndices()
+	totalInserted, err := s.updateNSEIndices(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to update NSE indices: %v", err)
 	}
@@ -112,7 +132,7 @@ func (s *IndexService) UpdateIndices() (int64, error) {
 }
 
 // UpdateNSEIndices fetches the instruments for a given NSE index and updates the database
-func (s *IndexService) updateNSEIndices() (int64, error) {
+func (s *IndexService) updateNSEIndices(ctx context.Context) (int64, error) {
 
 	// check if update is required
 	nseIndicesUpdatedAtValue, err := s.state.Get(nseIndicesUpdatedAtKey)
@@ -149,7 +169,7 @@ func (s *IndexService) updateNSEIndices() (int64, error) {
 	// update indices
 	for _, index := range indices {
 		// get records for index
-		indexRecords, err := s.fetchNSEIndexInstruments(index)
+		indexRecords, err := s.fetchNSEIndexInstruments(ctx, index)
 		if err != nil {
 			return 0, fmt.Errorf("failed to get instruments for index %s: %v", index, err)
 		}
@@ -163,7 +183,7 @@ func (s *IndexService) updateNSEIndices() (int64, error) {
 	}
 
 	// update state after all indices have been updated
-	if err := s.state.Set(nseIndicesUpdatedAtKey, time.Now().Format("2006-01-02 15:04:05")); err != nil {
+	if err := s.state.Set(nseIndicesUpdatedAtKey, time.Now().In(MarketLocation).Format("2006-01-02 15:04:05")); err != nil {
 		return 0, fmt.Errorf("failed to update state: %v", err)
 	}
 
@@ -175,25 +195,21 @@ func (s *IndexService) updateNSEIndices() (int64, error) {
 }
 
 // isUpdateIndicesRequired checks if the indices need to be updated
-// if last update time is not today, return true
+// if last update date is not today in the market time zone, return true
 func (s *IndexService) isUpdateIndicesRequired(lastUpdatedAt string) bool {
 
-	// parse last updated at time
-	lastUpdatedAtTime, err := time.Parse("2006-01-02 15:04:05", lastUpdatedAt)
+	// parse last updated at time, it is stored in the market time zone
+	lastUpdatedAtTime, err := time.ParseInLocation("2006-01-02 15:04:05", lastUpdatedAt, MarketLocation)
 	if err != nil {
 		return true // If we can't parse the time, assume update is needed
 	}
 
 	// check if last update date is today return false
-	if lastUpdatedAtTime.Day() == time.Now().Day() {
-		return false
-	}
-
-	return true
+	return lastUpdatedAtTime.Format("2006-01-02") != MarketToday()
 }
 
 // fetchNSEIndexInstruments fetches the instruments for a given NSE index
-func (s *IndexService) fetchNSEIndexInstruments(index string) ([]models.IndexModel, error) {
+func (s *IndexService) fetchNSEIndexInstruments(ctx context.Context, index string) ([]models.IndexModel, error) {
 
 	// -------------------------------------------------------------------------------------------------
 	// make request to index url
@@ -218,6 +234,7 @@ func (s *IndexService) fetchNSEIndexInstruments(index string) ([]models.IndexMod
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Referer", "https://niftyindices.com/")
+	requestid.SetHeader(req, ctx)
 
 	// make request
 	resp, err := s.client.Do(req)
@@ -0,0 +1,127 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// instrumentAccessPersistInterval is how often the in-memory access counters are added to the database
+const instrumentAccessPersistInterval = time.Minute
+
+// PopularInstrumentsMaxDays is the longest range of days the popular instruments are summed over
+const PopularInstrumentsMaxDays = 90
+
+// InstrumentAccessTracker counts how often instruments are queried and streamed, the counters
+// are aggregated in memory and only the increments since the last persist are written
+type InstrumentAccessTracker struct {
+	mu       sync.Mutex
+	counters map[string]*models.InstrumentAccessModel
+}
+
+var (
+	instrumentAccessTracker     *InstrumentAccessTracker
+	instrumentAccessTrackerOnce sync.Once
+)
+
+// GetInstrumentAccessTracker returns the process wide instrument access tracker
+func GetInstrumentAccessTracker() *InstrumentAccessTracker {
+	instrumentAccessTrackerOnce.Do(func() {
+		instrumentAccessTracker = &InstrumentAccessTracker{counters: make(map[string]*models.InstrumentAccessModel)}
+	})
+	return instrumentAccessTracker
+}
+
+// counter returns the counter of the instrument for the date, counters are keyed by date
+// so the increments of the previous day not yet persisted keep their date. The caller must hold the lock
+func (t *InstrumentAccessTracker) counter(date, instrument string) *models.InstrumentAccessModel {
+	key := date + "|" + instrument
+	counter, ok := t.counters[key]
+	if !ok {
+		counter = &models.InstrumentAccessModel{Date: date, Instrument: instrument}
+		t.counters[key] = counter
+	}
+	return counter
+}
+
+// RecordQuery counts a query of each instrument
+func (t *InstrumentAccessTracker) RecordQuery(instruments ...string) {
+	today := MarketToday()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, instrument := range instruments {
+		t.counter(today, instrument).Queries++
+	}
+}
+
+// RecordStream counts a stream of each instrument
+func (t *InstrumentAccessTracker) RecordStream(instruments ...string) {
+	today := MarketToday()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, instrument := range instruments {
+		t.counter(today, instrument).Streams++
+	}
+}
+
+// Persist adds the counters recorded since the last persist to the database
+func (t *InstrumentAccessTracker) Persist(repo *repository.InstrumentAccessRepository) error {
+	t.mu.Lock()
+	counters := make([]models.InstrumentAccessModel, 0, len(t.counters))
+	for _, counter := range t.counters {
+		counters = append(counters, *counter)
+	}
+	t.counters = make(map[string]*models.InstrumentAccessModel)
+	t.mu.Unlock()
+
+	if err := repo.IncrementInstrumentAccess(counters); err != nil {
+		// add the increments back so the next persist retries them
+		t.mu.Lock()
+		for _, c := range counters {
+			counter := t.counter(c.Date, c.Instrument)
+			counter.Queries += c.Queries
+			counter.Streams += c.Streams
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// InstrumentAccessService is the service for the instrument access statistics
+type InstrumentAccessService struct {
+	repo *repository.InstrumentAccessRepository
+}
+
+// NewInstrumentAccessService creates a new instrument access service
+func NewInstrumentAccessService(db *gorm.DB) *InstrumentAccessService {
+	return &InstrumentAccessService{repo: repository.NewInstrumentAccessRepository(db)}
+}
+
+// PersistAccessCounters periodically adds the access counters to the database
+func (s *InstrumentAccessService) PersistAccessCounters() {
+	ticker := time.NewTicker(instrumentAccessPersistInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := GetInstrumentAccessTracker().Persist(s.repo); err != nil {
+			zaplogger.Error("Failed to persist instrument access counters", zaplogger.Fields{"error": err.Error()})
+		}
+	}
+}
+
+// GetPopularInstruments returns the most accessed instruments over the last days, today included
+func (s *InstrumentAccessService) GetPopularInstruments(days int, sortBy string, limit int) ([]models.PopularInstrument, error) {
+	// counters not yet persisted are included
+	if err := GetInstrumentAccessTracker().Persist(s.repo); err != nil {
+		return nil, err
+	}
+	today := time.Now().In(MarketLocation)
+	fromDate := today.AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	return s.repo.GetPopularInstruments(fromDate, today.Format("2006-01-02"), sortBy, limit)
+}
@@ -0,0 +1,181 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// InstrumentBlacklist is the process wide set of the instruments that are never subscribed or streamed
+type InstrumentBlacklist struct {
+	mu          sync.RWMutex
+	instruments map[string]bool
+	// watchers are called with the instruments once they are blacklisted
+	watchers []func(instruments []string)
+}
+
+var (
+	instrumentBlacklist     *InstrumentBlacklist
+	instrumentBlacklistOnce sync.Once
+)
+
+// GetInstrumentBlacklist returns the process wide instrument blacklist
+func GetInstrumentBlacklist() *InstrumentBlacklist {
+	instrumentBlacklistOnce.Do(func() {
+		instrumentBlacklist = &InstrumentBlacklist{instruments: make(map[string]bool)}
+	})
+	return instrumentBlacklist
+}
+
+// Contains returns true if the instrument, in the EXCHANGE:TRADINGSYMBOL format, is blacklisted
+func (b *InstrumentBlacklist) Contains(instrument string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.instruments[instrument]
+}
+
+// Filter splits the instruments into the allowed ones and the blacklisted instruments
+func (b *InstrumentBlacklist) Filter(instruments []models.InstrumentModel) ([]models.InstrumentModel, []string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.instruments) == 0 {
+		return instruments, nil
+	}
+	allowed := make([]models.InstrumentModel, 0, len(instruments))
+	var blocked []string
+	for _, instrument := range instruments {
+		symbol := instrument.Exchange + ":" + instrument.Tradingsymbol
+		if b.instruments[symbol] {
+			blocked = append(blocked, symbol)
+			continue
+		}
+		allowed = append(allowed, instrument)
+	}
+	return allowed, blocked
+}
+
+// FilterSymbols splits the instruments, in the EXCHANGE:TRADINGSYMBOL format, into the allowed and the
+// blacklisted ones
+func (b *InstrumentBlacklist) FilterSymbols(instruments []string) ([]string, []string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.instruments) == 0 {
+		return instruments, nil
+	}
+	allowed := make([]string, 0, len(instruments))
+	var blocked []string
+	for _, instrument := range instruments {
+		if b.instruments[instrument] {
+			blocked = append(blocked, instrument)
+			continue
+		}
+		allowed = append(allowed, instrument)
+	}
+	return allowed, blocked
+}
+
+// OnBlacklisted registers fn to be called with the instruments once they are blacklisted, the running
+// connections drop them with it. fn is called without the lock held
+func (b *InstrumentBlacklist) OnBlacklisted(fn func(instruments []string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.watchers = append(b.watchers, fn)
+}
+
+// notify calls the watchers with the instruments newly blacklisted
+func (b *InstrumentBlacklist) notify(instruments []string) {
+	b.mu.RLock()
+	watchers := append([]func([]string){}, b.watchers...)
+	b.mu.RUnlock()
+	for _, fn := range watchers {
+		fn(instruments)
+	}
+}
+
+// set replaces the blacklisted instruments
+func (b *InstrumentBlacklist) set(instruments []models.InstrumentBlacklistModel) {
+	blacklisted := make(map[string]bool, len(instruments))
+	for _, instrument := range instruments {
+		blacklisted[instrument.Instrument] = true
+	}
+	b.mu.Lock()
+	b.instruments = blacklisted
+	b.mu.Unlock()
+}
+
+// update adds the instruments to the blacklist or removes them from it
+func (b *InstrumentBlacklist) update(instruments []string, blacklisted bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, instrument := range instruments {
+		if blacklisted {
+			b.instruments[instrument] = true
+		} else {
+			delete(b.instruments, instrument)
+		}
+	}
+}
+
+// InstrumentBlacklistService is the service for the instruments that are never subscribed or streamed
+type InstrumentBlacklistService struct {
+	repo *repository.InstrumentBlacklistRepository
+}
+
+// NewInstrumentBlacklistService creates a new instrument blacklist service
+func NewInstrumentBlacklistService(db *gorm.DB) *InstrumentBlacklistService {
+	return &InstrumentBlacklistService{repo: repository.NewInstrumentBlacklistRepository(db)}
+}
+
+// Load loads the blacklisted instruments into the process wide blacklist
+func (s *InstrumentBlacklistService) Load() (int, error) {
+	instruments, err := s.repo.GetBlacklistedInstruments()
+	if err != nil {
+		return 0, err
+	}
+	GetInstrumentBlacklist().set(instruments)
+	return len(instruments), nil
+}
+
+// GetBlacklistedInstruments returns the blacklisted instruments
+func (s *InstrumentBlacklistService) GetBlacklistedInstruments() ([]models.InstrumentBlacklistModel, error) {
+	return s.repo.GetBlacklistedInstruments()
+}
+
+// BlacklistInstruments blacklists the instruments. They are refused by the subscription paths, the streams and
+// the quotes from now on, the running ticker and streams drop them right away
+func (s *InstrumentBlacklistService) BlacklistInstruments(instruments []string, reason, actor string) ([]models.InstrumentBlacklistModel, error) {
+	blacklisted := make([]models.InstrumentBlacklistModel, len(instruments))
+	for i, instrument := range instruments {
+		blacklisted[i] = models.InstrumentBlacklistModel{Instrument: instrument, Reason: reason, AddedBy: actor}
+	}
+	if err := s.repo.UpsertBlacklistedInstruments(blacklisted); err != nil {
+		return nil, err
+	}
+	GetInstrumentBlacklist().update(instruments, true)
+	GetInstrumentBlacklist().notify(instruments)
+	zaplogger.Info("Instruments blacklisted", zaplogger.Fields{
+		"instruments": instruments,
+		"actor":       actor,
+		"reason":      reason,
+	})
+	return blacklisted, nil
+}
+
+// RemoveBlacklistedInstruments allows the instruments again, it returns the number removed
+func (s *InstrumentBlacklistService) RemoveBlacklistedInstruments(instruments []string, actor string) (int64, error) {
+	removed, err := s.repo.DeleteBlacklistedInstruments(instruments)
+	if err != nil {
+		return 0, err
+	}
+	GetInstrumentBlacklist().update(instruments, false)
+	zaplogger.Info("Blacklisted instruments removed", zaplogger.Fields{
+		"instruments": instruments,
+		"removed":     removed,
+		"actor":       actor,
+	})
+	return removed, nil
+}
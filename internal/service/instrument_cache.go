@@ -0,0 +1,134 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+)
+
+// InstrumentCache is an in-memory lookup of instruments by token and by exchange:tradingsymbol
+type InstrumentCache struct {
+	mu       sync.RWMutex
+	byToken  map[uint32]models.InstrumentModel
+	bySymbol map[string]uint32
+	ids      map[uint32]uint64
+	loadedAt time.Time
+}
+
+var (
+	instrumentCache     *InstrumentCache
+	instrumentCacheOnce sync.Once
+)
+
+// GetInstrumentCache returns the process wide instrument cache
+func GetInstrumentCache() *InstrumentCache {
+	instrumentCacheOnce.Do(func() {
+		instrumentCache = &InstrumentCache{
+			byToken:  make(map[uint32]models.InstrumentModel),
+			bySymbol: make(map[string]uint32),
+			ids:      make(map[uint32]uint64),
+		}
+	})
+	return instrumentCache
+}
+
+// Load replaces the cache contents with all instruments from the database
+func (c *InstrumentCache) Load(repo *repository.InstrumentRepository) (int, error) {
+	instruments, err := repo.GetAllInstruments()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load instruments: %v", err)
+	}
+
+	ids, err := repo.GetCurrentInstrumentIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	byToken := make(map[uint32]models.InstrumentModel, len(instruments))
+	bySymbol := make(map[string]uint32, len(instruments))
+	for _, instrument := range instruments {
+		byToken[instrument.InstrumentToken] = instrument
+		bySymbol[instrument.Exchange+":"+instrument.Tradingsymbol] = instrument.InstrumentToken
+	}
+
+	c.mu.Lock()
+	c.byToken = byToken
+	c.bySymbol = bySymbol
+	c.ids = ids
+	c.loadedAt = time.Now()
+	c.mu.Unlock()
+
+	return len(instruments), nil
+}
+
+// Invalidate clears the cache, lookups fall back to the database until the next Load
+func (c *InstrumentCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byToken = make(map[uint32]models.InstrumentModel)
+	c.bySymbol = make(map[string]uint32)
+	c.ids = make(map[uint32]uint64)
+	c.loadedAt = time.Time{}
+}
+
+// IsLoaded returns true if the cache has been loaded since the last invalidation
+func (c *InstrumentCache) IsLoaded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.loadedAt.IsZero()
+}
+
+// LoadedAt returns the time of the last load
+func (c *InstrumentCache) LoadedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loadedAt
+}
+
+// Len returns the number of cached instruments
+func (c *InstrumentCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.byToken)
+}
+
+// GetByToken returns the instrument for the given token
+func (c *InstrumentCache) GetByToken(token uint32) (models.InstrumentModel, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	instrument, ok := c.byToken[token]
+	return instrument, ok
+}
+
+// GetInstrumentID returns the stable instrument id of the token, 0 when the token has no id
+func (c *InstrumentCache) GetInstrumentID(token uint32) uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ids[token]
+}
+
+// GetBySymbol returns the instrument for the given exchange:tradingsymbol
+func (c *InstrumentCache) GetBySymbol(symbol string) (models.InstrumentModel, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	token, ok := c.bySymbol[strings.TrimSpace(symbol)]
+	if !ok {
+		return models.InstrumentModel{}, false
+	}
+	instrument, ok := c.byToken[token]
+	return instrument, ok
+}
+
+// ForEach calls fn for every cached instrument, fn must not use the cache
+func (c *InstrumentCache) ForEach(fn func(models.InstrumentModel)) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, instrument := range c.byToken {
+		fn(instrument)
+	}
+}
@@ -2,14 +2,26 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/requestid"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/state"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 	"gorm.io/gorm"
@@ -17,10 +29,21 @@ import (
 
 var instrumentsUpdatedAtKey = "INSTRUMENTS_UPDATED_AT"
 
+// ErrNoActiveFutures is returned when a name has no futures expiring today or later
+var ErrNoActiveFutures = errors.New("no active futures found")
+
+const (
+	instrumentsInsertBatchSize   = 500
+	instrumentsUpdateWorkers     = 4
+	instrumentsUpdateMaxAttempts = 3
+)
+
 // InstrumentService is the service for managing instruments
 type InstrumentService struct {
-	repo  *repository.InstrumentRepository
-	state *state.State
+	repo       *repository.InstrumentRepository
+	tickerRepo *repository.TickerRepository
+	state      *state.State
+	cache      *InstrumentCache
 }
 
 // NewInstrumentService creates a new instrument service
@@ -30,13 +53,15 @@ func NewInstrumentService(db *gorm.DB) *InstrumentService {
 		zaplogger.Fatal("failed to create state manager", zaplogger.Fields{"error": err})
 	}
 	return &InstrumentService{
-		repo:  repository.NewInstrumentRepository(db),
-		state: stateManager,
+		repo:       repository.NewInstrumentRepository(db),
+		tickerRepo: repository.NewTickerRepository(db),
+		state:      stateManager,
+		cache:      GetInstrumentCache(),
 	}
 }
 
-// UpdateInstruments updates the instruments in the database
-func (s *InstrumentService) UpdateInstruments() (int64, error) {
+// UpdateInstruments updates the instruments in the database, the request id of the context is sent with the download
+func (s *InstrumentService) UpdateInstruments(ctx context.Context) (int64, error) {
 	// check if update is required
 	instrumentsUpdatedAtValue, err := s.state.Get(instrumentsUpdatedAtKey)
 	if err == nil {
@@ -53,46 +78,84 @@ func (s *InstrumentService) UpdateInstruments() (int64, error) {
 	})
 
 	// get instruments from kite
-	resp, err := http.Get("https://api.kite.trade/instruments")
+	records, err := fetchInstrumentRecords(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch instruments: %v", err)
+		return 0, err
 	}
-	defer resp.Body.Close()
 
-	// parse response body to csv
-	reader := csv.NewReader(resp.Body)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse CSV: %v", err)
+	// parse the records strictly, rows with unparsable values are reported and skipped
+	instruments, badRows := parseInstrumentRecords(records)
+	if err := s.repo.ReplaceInstrumentBadRows(badRows); err != nil {
+		return 0, err
+	}
+	badRowCount := countInstrumentBadRows(badRows)
+	if badRowCount > 0 {
+		zaplogger.Warn("Instruments dump has bad rows", zaplogger.Fields{
+			"bad_rows": badRowCount,
+			"records":  len(records),
+		})
+	}
+	tolerance := getInstrumentsBadRowsTolerance()
+	if len(records) > 0 && float64(badRowCount)/float64(len(records)) > tolerance {
+		return 0, fmt.Errorf("instruments load aborted, %d of %d rows are bad, tolerance is %.4f", badRowCount, len(records), tolerance)
 	}
 
-	records = records[1:] // Skip header row
+	// derive the weekly/monthly expiry classification
+	classifyInstrumentExpiries(instruments)
 
-	// truncate instruments table
-	if err := s.repo.TruncateInstrumentsTable(); err != nil {
-		return 0, fmt.Errorf("failed to truncate table: %v", err)
+	// partition the instruments by exchange
+	exchangeInstruments := make(map[string][]models.InstrumentModel)
+	for _, instrument := range instruments {
+		exchangeInstruments[instrument.Exchange] = append(exchangeInstruments[instrument.Exchange], instrument)
 	}
 
-	// insert instruments in batches
-	batchSize := 500
-	var totalInserted int64 = 0
-	for i := 0; i < len(records); i += batchSize {
-		end := i + batchSize
-		if end > len(records) {
-			end = len(records)
-		}
+	// cached instruments are stale from here on
+	s.cache.Invalidate()
+	GetOptionChainCache().Invalidate()
 
-		// insert instruments in batch
-		inserted, err := s.repo.InsertInstruments(records[i:end])
+	// replace the instruments of each exchange in parallel
+	totalInserted, err := s.replaceExchangeInstruments(exchangeInstruments)
+	if err != nil {
+		return totalInserted, err
+	}
 
-		if err != nil {
-			return totalInserted, fmt.Errorf("failed to insert batch starting at index %d: %v", i, err)
-		}
-		totalInserted += inserted
+	// remove instruments of exchanges which are no longer in the dump
+	exchanges := make([]string, 0, len(exchangeInstruments))
+	for exchange := range exchangeInstruments {
+		exchanges = append(exchanges, exchange)
+	}
+	if _, err := s.repo.DeleteInstrumentsNotInExchanges(exchanges); err != nil {
+		return totalInserted, err
+	}
+
+	// keep the stable instrument ids in step with the new tokens
+	today := MarketToday()
+	syncResult, err := s.repo.SyncInstrumentIDs(today)
+	if err != nil {
+		return totalInserted, err
+	}
+	zaplogger.Info("Instrument ids synced", zaplogger.Fields{
+		"new_ids":    syncResult.NewIDs,
+		"remapped":   syncResult.Remapped,
+		"backfilled": syncResult.Backfilled,
+	})
+
+	// record the changed definitions in the instrument history
+	closed, opened, err := s.repo.SyncInstrumentHistory(today)
+	if err != nil {
+		return totalInserted, err
+	}
+	zaplogger.Info("Instrument history synced", zaplogger.Fields{
+		"closed": closed,
+		"opened": opened,
+	})
+
+	if totalInserted != int64(len(instruments)) {
+		return totalInserted, fmt.Errorf("instruments count mismatch, parsed: %d, inserted: %d", len(instruments), totalInserted)
 	}
 
 	// update state after all instruments have been updated
-	if err := s.state.Set(instrumentsUpdatedAtKey, time.Now().Format("2006-01-02 15:04:05")); err != nil {
+	if err := s.state.Set(instrumentsUpdatedAtKey, time.Now().In(MarketLocation).Format("2006-01-02 15:04:05")); err != nil {
 		return 0, fmt.Errorf("failed to update state: %v", err)
 	}
 
@@ -109,17 +172,349 @@ func (s *InstrumentService) UpdateInstruments() (int64, error) {
 	return recordCount, nil
 }
 
+// SyncNewInstruments inserts the instruments listed in the kite instruments dump since the last full load,
+// e.g. weekly option contracts added intraday, and returns them. Existing instruments are not touched
+func (s *InstrumentService) SyncNewInstruments(ctx context.Context) ([]models.InstrumentModel, error) {
+	records, err := fetchInstrumentRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// rows with unparsable values are skipped, the bad rows report belongs to the full load
+	instruments, badRows := parseInstrumentRecords(records)
+	if badRowCount := countInstrumentBadRows(badRows); badRowCount > 0 {
+		zaplogger.Warn("Instruments dump has bad rows", zaplogger.Fields{
+			"bad_rows": badRowCount,
+			"records":  len(records),
+		})
+	}
+
+	// the expiry classification needs all expiries of an underlying
+	classifyInstrumentExpiries(instruments)
+
+	newInstruments, err := s.repo.InsertNewInstruments(instruments, instrumentsInsertBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(newInstruments) == 0 {
+		return newInstruments, nil
+	}
+
+	today := MarketToday()
+	if _, err := s.repo.SyncInstrumentIDs(today); err != nil {
+		return newInstruments, err
+	}
+	if _, _, err := s.repo.SyncInstrumentHistory(today); err != nil {
+		return newInstruments, err
+	}
+
+	GetOptionChainCache().Invalidate()
+	if s.cache.IsLoaded() {
+		if _, err := s.cache.Load(s.repo); err != nil {
+			s.cache.Invalidate()
+			return newInstruments, err
+		}
+	}
+
+	return newInstruments, nil
+}
+
+// fetchInstrumentRecords fetches the kite instruments dump without its header row
+func fetchInstrumentRecords(ctx context.Context) ([][]string, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.kite.trade/instruments", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	requestid.SetHeader(req, ctx)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch instruments: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// parse response body to csv
+	reader := csv.NewReader(resp.Body)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	return records[1:], nil // Skip header row
+}
+
+// replaceExchangeInstruments replaces the instruments of each exchange using parallel workers,
+// an exchange whose insert fails or does not verify is retried and otherwise keeps its previous rows
+func (s *InstrumentService) replaceExchangeInstruments(exchangeInstruments map[string][]models.InstrumentModel) (int64, error) {
+	var (
+		wg            sync.WaitGroup
+		mu            sync.Mutex
+		totalInserted int64
+		errs          []string
+	)
+
+	sem := make(chan struct{}, instrumentsUpdateWorkers)
+	for exchange, instruments := range exchangeInstruments {
+		wg.Add(1)
+		go func(exchange string, instruments []models.InstrumentModel) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var inserted int64
+			var err error
+			for attempt := 1; attempt <= instrumentsUpdateMaxAttempts; attempt++ {
+				inserted, err = s.repo.ReplaceExchangeInstruments(exchange, instruments, instrumentsInsertBatchSize)
+				if err == nil {
+					break
+				}
+				zaplogger.Warn("Instruments exchange update failed", zaplogger.Fields{
+					"exchange": exchange,
+					"attempt":  attempt,
+					"error":    err.Error(),
+				})
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err.Error())
+				return
+			}
+			totalInserted += inserted
+		}(exchange, instruments)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return totalInserted, fmt.Errorf("failed to update instruments: %s", strings.Join(errs, "; "))
+	}
+	return totalInserted, nil
+}
+
+// GetInstrumentSync returns the instruments added, changed and removed since the instrument history version,
+// so clients caching the instruments only download the difference. Every instrument is returned when the version is 0
+func (s *InstrumentService) GetInstrumentSync(sinceVersion uint64) (models.InstrumentSync, error) {
+	return s.repo.GetInstrumentSync(sinceVersion)
+}
+
+// GetInstrumentBadRows returns the bad rows report of the last instruments load
+func (s *InstrumentService) GetInstrumentBadRows() ([]models.InstrumentBadRow, error) {
+	return s.repo.GetInstrumentBadRows()
+}
+
+// GetInstrumentStats returns the number of instruments by exchange, segment and instrument type with the last update time
+func (s *InstrumentService) GetInstrumentStats() (models.InstrumentStats, error) {
+	stats := models.InstrumentStats{Exchanges: make(map[string]int64)}
+	groups, err := s.repo.GetInstrumentStatsGroups()
+	if err != nil {
+		return stats, err
+	}
+	stats.Segments = groups
+	for _, group := range groups {
+		stats.Total += group.Count
+		stats.Exchanges[group.Exchange] += group.Count
+		if group.UpdatedAt.After(stats.UpdatedAt) {
+			stats.UpdatedAt = group.UpdatedAt
+		}
+	}
+	return stats, nil
+}
+
+// GetInstrumentTokenHistory returns the stable id of an exchange:tradingsymbol and the tokens it had
+func (s *InstrumentService) GetInstrumentTokenHistory(instrument string) (models.InstrumentTokenHistory, error) {
+	instrumentID, err := s.repo.GetInstrumentID(instrument)
+	if err != nil {
+		return models.InstrumentTokenHistory{}, err
+	}
+	tokens, err := s.repo.GetInstrumentTokenHistory(instrumentID.ID)
+	if err != nil {
+		return models.InstrumentTokenHistory{}, err
+	}
+	return models.InstrumentTokenHistory{
+		InstrumentID: instrumentID.ID,
+		Instrument:   instrumentID.Instrument,
+		Tokens:       tokens,
+	}, nil
+}
+
+// parseInstrumentRecords parses the instruments dump records, returning the valid instruments
+// and a bad row entry for every value that failed to parse
+func parseInstrumentRecords(records [][]string) ([]models.InstrumentModel, []models.InstrumentBadRow) {
+	instruments := make([]models.InstrumentModel, 0, len(records))
+	badRows := make([]models.InstrumentBadRow, 0)
+
+	for i, record := range records {
+		row := i + 2 // 1-based line number in the dump, after the header
+
+		if len(record) < 12 {
+			badRows = append(badRows, models.InstrumentBadRow{
+				Row:    row,
+				Column: "*",
+				Value:  strings.Join(record, ","),
+				Error:  fmt.Sprintf("expected 12 columns, got %d", len(record)),
+			})
+			continue
+		}
+
+		rowBad := false
+		addBad := func(column, value string, err error) {
+			rowBad = true
+			badRows = append(badRows, models.InstrumentBadRow{Row: row, Column: column, Value: value, Error: err.Error()})
+		}
+
+		instrumentToken, err := strconv.ParseUint(record[0], 10, 32)
+		if err != nil {
+			addBad("instrument_token", record[0], err)
+		}
+		exchangeToken, err := strconv.ParseUint(record[1], 10, 32)
+		if err != nil {
+			addBad("exchange_token", record[1], err)
+		}
+		lastPrice, err := decimal.NewFromString(record[4])
+		if err != nil {
+			addBad("last_price", record[4], err)
+		}
+		strike, err := decimal.NewFromString(record[6])
+		if err != nil {
+			addBad("strike", record[6], err)
+		}
+		tickSize, err := decimal.NewFromString(record[7])
+		if err != nil {
+			addBad("tick_size", record[7], err)
+		}
+		lotSize, err := strconv.ParseUint(record[8], 10, 32)
+		if err != nil {
+			addBad("lot_size", record[8], err)
+		}
+		if rowBad {
+			continue
+		}
+
+		instruments = append(instruments, models.InstrumentModel{
+			InstrumentToken: uint32(instrumentToken),
+			ExchangeToken:   uint32(exchangeToken),
+			Tradingsymbol:   record[2],
+			Name:            record[3],
+			LastPrice:       lastPrice,
+			Expiry:          record[5],
+			Strike:          strike,
+			TickSize:        tickSize,
+			LotSize:         uint(lotSize),
+			InstrumentType:  record[9],
+			Segment:         record[10],
+			Exchange:        record[11],
+		})
+	}
+
+	return instruments, badRows
+}
+
+// classifyInstrumentExpiries sets the expiry series of every instrument with an expiry,
+// the last expiry of a calendar month for an exchange and name is monthly, all others are weekly
+func classifyInstrumentExpiries(instruments []models.InstrumentModel) {
+	// last expiry per exchange, name and month, expiries are yyyy-mm-dd so they compare as strings
+	monthlyExpiries := make(map[string]string)
+	for _, instrument := range instruments {
+		if len(instrument.Expiry) < 7 {
+			continue
+		}
+		key := instrument.Exchange + ":" + instrument.Name + ":" + instrument.Expiry[:7]
+		if instrument.Expiry > monthlyExpiries[key] {
+			monthlyExpiries[key] = instrument.Expiry
+		}
+	}
+
+	for i := range instruments {
+		instrument := &instruments[i]
+		if len(instrument.Expiry) < 7 {
+			continue
+		}
+		key := instrument.Exchange + ":" + instrument.Name + ":" + instrument.Expiry[:7]
+		if instrument.Expiry == monthlyExpiries[key] {
+			instrument.ExpirySeries = models.ExpirySeriesMonthly
+		} else {
+			instrument.ExpirySeries = models.ExpirySeriesWeekly
+			instrument.IsWeeklyExpiry = true
+		}
+	}
+}
+
+// countInstrumentBadRows returns the number of distinct rows in the bad rows report
+func countInstrumentBadRows(badRows []models.InstrumentBadRow) int {
+	rows := make(map[int]struct{}, len(badRows))
+	for _, badRow := range badRows {
+		rows[badRow.Row] = struct{}{}
+	}
+	return len(rows)
+}
+
+// getInstrumentsBadRowsTolerance returns the configured fraction of bad rows tolerated by the instruments load
+func getInstrumentsBadRowsTolerance() float64 {
+	cfg, err := config.Get()
+	if err != nil {
+		return 0
+	}
+	tolerance, err := strconv.ParseFloat(cfg.InstrumentsBadRowsTolerance, 64)
+	if err != nil {
+		return 0
+	}
+	return tolerance
+}
+
+// GetInstrumentTokenMap returns the token to exchange:tradingsymbol mapping of all instruments,
+// or of the instruments the ticker connection streams when subscribed is true
+func (s *InstrumentService) GetInstrumentTokenMap(subscribed bool) (models.InstrumentTokenMap, error) {
+	if !s.cache.IsLoaded() {
+		if _, err := s.cache.Load(s.repo); err != nil {
+			return models.InstrumentTokenMap{}, err
+		}
+	}
+
+	tokens := make(map[string]string)
+	if subscribed {
+		for _, token := range GetTickHub().Tokens() {
+			if instrument, ok := s.cache.GetByToken(token); ok {
+				tokens[strconv.FormatUint(uint64(token), 10)] = instrument.Exchange + ":" + instrument.Tradingsymbol
+			}
+		}
+	} else {
+		s.cache.ForEach(func(instrument models.InstrumentModel) {
+			tokens[strconv.FormatUint(uint64(instrument.InstrumentToken), 10)] = instrument.Exchange + ":" + instrument.Tradingsymbol
+		})
+	}
+
+	// map keys are marshaled sorted, so the same mapping always has the same hash
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return models.InstrumentTokenMap{}, fmt.Errorf("failed to marshal token map: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return models.InstrumentTokenMap{
+		Tokens: tokens,
+		Count:  len(tokens),
+		ETag:   `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}, nil
+}
+
+// WarmInstrumentCache loads all instruments into the in-memory cache
+func (s *InstrumentService) WarmInstrumentCache() (int, error) {
+	return s.cache.Load(s.repo)
+}
+
 // isUpdateInstrumentsRequired checks if the instruments need to be updated
 func (s *InstrumentService) isUpdateInstrumentsRequired(lastUpdatedAt string) bool {
 
 	// parse last updated at time
-	lastUpdatedAtTime, err := time.Parse("2006-01-02 15:04:05", lastUpdatedAt)
+	lastUpdatedAtTime, err := time.ParseInLocation("2006-01-02 15:04:05", lastUpdatedAt, MarketLocation)
 	if err != nil {
 		return true // If we can't parse the time, assume update is needed
 	}
 
-	// false only if last update is today and after 08:15am
-	if lastUpdatedAtTime.Day() == time.Now().Day() {
+	// false only if last update is today and after 08:15am market time
+	if lastUpdatedAtTime.Format("2006-01-02") == MarketToday() {
 		if lastUpdatedAtTime.Hour() == 8 && lastUpdatedAtTime.Minute() >= 15 {
 			return false
 		}
@@ -143,6 +538,14 @@ func (s *InstrumentService) GetInstrumentsInfoBySymbols(symbols []string) ([]mod
 		exchange := strings.TrimSpace(parts[0])
 		tradingsymbol := strings.TrimSpace(parts[1])
 
+		// use the cache when it has been warmed
+		if s.cache.IsLoaded() {
+			if instrument, ok := s.cache.GetBySymbol(exchange + ":" + tradingsymbol); ok {
+				instrumentsResponse = append(instrumentsResponse, instrument)
+			}
+			continue
+		}
+
 		instrument, err := s.repo.GetInstrumentByExchangeTradingsymbol(exchange, tradingsymbol)
 		if err != nil {
 			// Skip instruments that are not found
@@ -195,3 +598,289 @@ func (s *InstrumentService) GetFNOSegmentWiseName(expiry string) ([]models.Instr
 func (s *InstrumentService) GetFNOSegmentWiseExpiry(name string, limit, offset int) ([]models.InstrumentModel, error) {
 	return s.repo.GetFNOSegmentWiseExpiry(name, limit, offset)
 }
+
+// GetFNOStrikeIntervals returns the strike interval per segment and expiry for a given name,
+// the interval is the most common gap between consecutive strikes as far strikes are spaced wider
+func (s *InstrumentService) GetFNOStrikeIntervals(name, expiry string) ([]models.StrikeInterval, error) {
+	instruments, err := s.repo.GetOptionStrikes(name, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	strikeIntervals := make([]models.StrikeInterval, 0)
+	for start := 0; start < len(instruments); {
+		// instruments are ordered by segment, expiry and strike
+		end := start
+		for end < len(instruments) && instruments[end].Segment == instruments[start].Segment && instruments[end].Expiry == instruments[start].Expiry {
+			end++
+		}
+		strikeIntervals = append(strikeIntervals, models.StrikeInterval{
+			Segment:        instruments[start].Segment,
+			Expiry:         instruments[start].Expiry,
+			StrikeInterval: mostCommonStrikeGap(instruments[start:end]),
+			Strikes:        end - start,
+		})
+		start = end
+	}
+
+	return strikeIntervals, nil
+}
+
+// mostCommonStrikeGap returns the most common gap between consecutive strikes, the smaller gap wins ties
+func mostCommonStrikeGap(instruments []models.InstrumentModel) decimal.Decimal {
+	gapCounts := make(map[decimal.Decimal]int)
+	for i := 1; i < len(instruments); i++ {
+		gap := instruments[i].Strike.Sub(instruments[i-1].Strike)
+		if gap.Sign() > 0 {
+			gapCounts[gap]++
+		}
+	}
+
+	var strikeGap decimal.Decimal
+	var maxCount int
+	for gap, count := range gapCounts {
+		if count > maxCount || (count == maxCount && gap.LessThan(strikeGap)) {
+			strikeGap = gap
+			maxCount = count
+		}
+	}
+	return strikeGap
+}
+
+// GetFNOOptionChain returns the full option chain of a name for an option expiry,
+// the underlying is the future for futExpiry or the nearest future if futExpiry is blank
+func (s *InstrumentService) GetFNOOptionChain(exchange, name, futExpiry, optExpiry string) (models.OptionChain, error) {
+	cacheKey := optionChainCacheKey(exchange, name, futExpiry, optExpiry)
+	if chain, ok := GetOptionChainCache().Get(cacheKey); ok {
+		return chain, nil
+	}
+
+	chain := models.OptionChain{
+		Exchange:  exchange,
+		Name:      name,
+		FutExpiry: futExpiry,
+		OptExpiry: optExpiry,
+		Strikes:   make([]models.OptionChainStrike, 0),
+	}
+
+	future, err := s.repo.GetNearestFuture(exchange, name, futExpiry, MarketToday())
+	if err == nil {
+		chain.FutExpiry = future.Expiry
+		chain.UnderlyingInstrument = future.Exchange + ":" + future.Tradingsymbol
+	} else if err != gorm.ErrRecordNotFound {
+		return chain, err
+	}
+
+	instruments, err := s.repo.GetOptionChainInstruments(exchange, name, optExpiry)
+	if err != nil {
+		return chain, err
+	}
+
+	// instruments are ordered by strike
+	for i := range instruments {
+		instrument := &instruments[i]
+		if len(chain.Strikes) == 0 || chain.Strikes[len(chain.Strikes)-1].Strike != instrument.Strike {
+			chain.Strikes = append(chain.Strikes, models.OptionChainStrike{Strike: instrument.Strike})
+		}
+		strike := &chain.Strikes[len(chain.Strikes)-1]
+		switch instrument.InstrumentType {
+		case "CE":
+			strike.CE = instrument
+		case "PE":
+			strike.PE = instrument
+		}
+	}
+	chain.TotalStrikes = len(chain.Strikes)
+
+	GetOptionChainCache().Set(cacheKey, chain)
+	return chain, nil
+}
+
+// ResolveOptionChainATM sets the underlying last price from the ticker data and the strike closest to it
+func (s *InstrumentService) ResolveOptionChainATM(chain *models.OptionChain) error {
+	if chain.UnderlyingInstrument == "" {
+		return fmt.Errorf("no future found for %s", chain.Name)
+	}
+	tickerData, err := s.tickerRepo.GetTickerDataByInstrument(chain.UnderlyingInstrument)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("no tick data for %s", chain.UnderlyingInstrument)
+		}
+		return err
+	}
+	chain.UnderlyingLastPrice = tickerData.LastPrice
+
+	for i, strike := range chain.Strikes {
+		if i == 0 || strike.Strike.Sub(chain.UnderlyingLastPrice).Abs().LessThan(chain.ATMStrike.Sub(chain.UnderlyingLastPrice).Abs()) {
+			chain.ATMStrike = strike.Strike
+		}
+	}
+	return nil
+}
+
+// WindowOptionChain returns a copy of the chain limited to the strikes around the ATM strike,
+// negative strikesBelow or strikesAbove keep all strikes on that side, and then paginated when pageSize is positive
+func WindowOptionChain(chain models.OptionChain, strikesBelow, strikesAbove, page, pageSize int) models.OptionChain {
+	strikes := chain.Strikes
+
+	if strikesBelow >= 0 || strikesAbove >= 0 {
+		atmIndex := 0
+		for i, strike := range strikes {
+			if strike.Strike == chain.ATMStrike {
+				atmIndex = i
+				break
+			}
+		}
+		start, end := 0, len(strikes)
+		if strikesBelow >= 0 && atmIndex-strikesBelow > 0 {
+			start = atmIndex - strikesBelow
+		}
+		if strikesAbove >= 0 && atmIndex+strikesAbove+1 < len(strikes) {
+			end = atmIndex + strikesAbove + 1
+		}
+		strikes = strikes[start:end]
+	}
+	chain.TotalStrikes = len(strikes)
+
+	if pageSize > 0 {
+		if page < 1 {
+			page = 1
+		}
+		// compared by pages so a large page or page size can not overflow the offsets
+		pages := len(strikes) / pageSize
+		if len(strikes)%pageSize != 0 {
+			pages++
+		}
+		start := len(strikes)
+		if page-1 < pages {
+			start = (page - 1) * pageSize
+		}
+		end := len(strikes)
+		if pageSize < end-start {
+			end = start + pageSize
+		}
+		strikes = strikes[start:end]
+		chain.Page = page
+		chain.PageSize = pageSize
+	}
+
+	chain.Strikes = strikes
+	return chain
+}
+
+// GetFNORollover computes the rollover of the futures of a name from the open interest in the ticker data,
+// the rollover is the share of the open interest held in the expiries after the near expiry
+func (s *InstrumentService) GetFNORollover(exchange, name string) (models.FuturesRollover, error) {
+	rollover := models.FuturesRollover{
+		Exchange: exchange,
+		Name:     name,
+		Futures:  make([]models.FutureOI, 0),
+	}
+
+	futures, err := s.repo.GetActiveFutures(exchange, name, MarketToday())
+	if err != nil {
+		return rollover, fmt.Errorf("failed to get futures: %v", err)
+	}
+	if len(futures) == 0 {
+		return rollover, fmt.Errorf("%w for %s:%s", ErrNoActiveFutures, exchange, name)
+	}
+
+	instruments := make([]string, len(futures))
+	for i, future := range futures {
+		instruments[i] = future.Exchange + ":" + future.Tradingsymbol
+	}
+	tickerData, err := s.tickerRepo.GetTickerDataByInstruments(instruments)
+	if err != nil {
+		return rollover, err
+	}
+	tickerDataMap := make(map[string]models.TickerData, len(tickerData))
+	for _, td := range tickerData {
+		tickerDataMap[td.Instrument] = td
+	}
+
+	rollover.NearExpiry = futures[0].Expiry
+	for i, future := range futures {
+		td, ok := tickerDataMap[instruments[i]]
+		if !ok {
+			continue
+		}
+		rollover.Futures = append(rollover.Futures, models.FutureOI{
+			Instrument: instruments[i],
+			Expiry:     future.Expiry,
+			LastPrice:  td.LastPrice,
+			OI:         td.OI,
+		})
+		rollover.TotalOI += uint64(td.OI)
+		if future.Expiry == rollover.NearExpiry {
+			rollover.NearOI += uint64(td.OI)
+		}
+	}
+
+	if rollover.TotalOI > 0 {
+		rollover.RolloverPercent = math.Round(float64(rollover.TotalOI-rollover.NearOI)/float64(rollover.TotalOI)*100*100) / 100
+	}
+	return rollover, nil
+}
+
+// isinRegexp matches an ISIN, 2 letter country code, 9 alphanumerics and a check digit
+var isinRegexp = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{9}[0-9]$`)
+
+// ResolveInstruments resolves a mixed list of instrument tokens and exchange:tradingsymbol identifiers,
+// each identifier gets its own resolution with the reason when it did not resolve
+func (s *InstrumentService) ResolveInstruments(identifiers []string) (map[string]models.InstrumentResolution, error) {
+	if !s.cache.IsLoaded() {
+		if _, err := s.cache.Load(s.repo); err != nil {
+			return nil, err
+		}
+	}
+
+	resolutions := make(map[string]models.InstrumentResolution, len(identifiers))
+	for _, identifier := range identifiers {
+		value := strings.TrimSpace(identifier)
+		resolution := models.InstrumentResolution{Type: models.IdentifierTypeUnknown}
+
+		var instrument models.InstrumentModel
+		var ok bool
+		switch {
+		case value == "":
+			resolution.Error = "empty identifier"
+		case isDigits(value):
+			resolution.Type = models.IdentifierTypeToken
+			token, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				resolution.Error = "instrument token out of range"
+				break
+			}
+			if instrument, ok = s.cache.GetByToken(uint32(token)); !ok {
+				resolution.Error = "instrument token not found"
+			}
+		case strings.Contains(value, ":"):
+			resolution.Type = models.IdentifierTypeSymbol
+			exchange, tradingsymbol, _ := strings.Cut(value, ":")
+			if instrument, ok = s.cache.GetBySymbol(strings.ToUpper(strings.TrimSpace(exchange)) + ":" + strings.TrimSpace(tradingsymbol)); !ok {
+				resolution.Error = "exchange:tradingsymbol not found"
+			}
+		case isinRegexp.MatchString(strings.ToUpper(value)):
+			resolution.Type = models.IdentifierTypeISIN
+			resolution.Error = "ISIN resolution is not supported, the instruments dump carries no ISINs"
+		default:
+			resolution.Error = "unrecognized identifier, expected an instrument token or exchange:tradingsymbol"
+		}
+
+		if ok {
+			resolution.Instrument = &instrument
+		}
+		resolutions[identifier] = resolution
+	}
+	return resolutions, nil
+}
+
+// isDigits returns true if the value only consists of digits
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
@@ -0,0 +1,97 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// GlobalTagOwner is the user id of the admin defined tags visible to all users
+const GlobalTagOwner = ""
+
+// instrumentTagPattern is a lowercase tag of up to 50 letters, digits, dots, dashes and underscores
+var instrumentTagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,49}$`)
+
+// ErrInvalidInstrumentTag is returned for a tag not matching the tag pattern
+var ErrInvalidInstrumentTag = errors.New("invalid tag, must be 1 to 50 letters, digits, dots, dashes or underscores")
+
+// NormalizeInstrumentTag returns the tag in lowercase, ErrInvalidInstrumentTag if it is not a valid tag
+func NormalizeInstrumentTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if !instrumentTagPattern.MatchString(tag) {
+		return "", ErrInvalidInstrumentTag
+	}
+	return tag, nil
+}
+
+// InstrumentTagService is the service for the tags grouping instruments, a lighter alternative to watchlists
+type InstrumentTagService struct {
+	repo *repository.InstrumentTagRepository
+}
+
+// NewInstrumentTagService creates a new instrument tag service
+func NewInstrumentTagService(db *gorm.DB) *InstrumentTagService {
+	return &InstrumentTagService{repo: repository.NewInstrumentTagRepository(db)}
+}
+
+// TagInstruments tags the instruments for the owner, GlobalTagOwner for a global tag. It returns the number of
+// instruments newly tagged
+func (s *InstrumentTagService) TagInstruments(owner, tag string, instruments []string) (int64, error) {
+	tags := make([]models.InstrumentTagModel, len(instruments))
+	for i, instrument := range instruments {
+		tags[i] = models.InstrumentTagModel{UserId: owner, Tag: tag, Instrument: instrument}
+	}
+	tagged, err := s.repo.InsertInstrumentTags(tags)
+	if err != nil {
+		return 0, err
+	}
+	zaplogger.Info("Instruments tagged", zaplogger.Fields{
+		"owner":  owner,
+		"tag":    tag,
+		"tagged": tagged,
+	})
+	return tagged, nil
+}
+
+// UntagInstruments removes the tag of the owner from the instruments, from all its instruments when none are given
+func (s *InstrumentTagService) UntagInstruments(owner, tag string, instruments []string) (int64, error) {
+	return s.repo.DeleteInstrumentTags(owner, tag, instruments)
+}
+
+// GetTags returns the tags of the user and the global tags
+func (s *InstrumentTagService) GetTags(userId string) ([]models.InstrumentTagSummary, error) {
+	return s.repo.GetInstrumentTagSummaries(userId)
+}
+
+// ResolveTags returns the instruments carrying one of the tags of the user or the global tags, sorted
+func (s *InstrumentTagService) ResolveTags(userId string, tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return s.repo.GetTaggedInstruments(userId, tags)
+}
+
+// ExpandInstrumentTags returns the instruments followed by those carrying one of the tags and not already listed
+func (s *InstrumentTagService) ExpandInstrumentTags(userId string, instruments, tags []string) ([]string, error) {
+	tagged, err := s.ResolveTags(userId, tags)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(instruments))
+	expanded := make([]string, 0, len(instruments)+len(tagged))
+	for _, list := range [][]string{instruments, tagged} {
+		for _, instrument := range list {
+			if !seen[instrument] {
+				seen[instrument] = true
+				expanded = append(expanded, instrument)
+			}
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,115 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// JobService runs long running operations in the background and tracks them in the jobs table
+type JobService struct {
+	repo *repository.JobRepository
+}
+
+// NewJobService creates a new job service
+func NewJobService(db *gorm.DB) *JobService {
+	return &JobService{
+		repo: repository.NewJobRepository(db),
+	}
+}
+
+// Submit starts run in the background and returns the job tracking it,
+// if a job with the same name is already active that job is returned instead
+func (s *JobService) Submit(userID, name string, run func() (interface{}, error)) (*models.JobModel, error) {
+	activeJob, err := s.repo.GetActiveJob(name)
+	if err == nil {
+		return activeJob, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to check active jobs: %v", err)
+	}
+
+	job := &models.JobModel{
+		Name:   name,
+		UserID: userID,
+		Status: models.JobStatusPending,
+	}
+	// a concurrent submit may have created the job since, the unique index of the active names rejects this one
+	if err := s.repo.CreateJob(job); err != nil {
+		if !errors.Is(err, repository.ErrJobActive) {
+			return nil, err
+		}
+		activeJob, err := s.repo.GetActiveJob(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the active job: %v", err)
+		}
+		return activeJob, nil
+	}
+
+	recovery.Go("job."+name, func() { s.execute(job.ID, name, run) })
+
+	return job, nil
+}
+
+// execute runs the job and records its outcome
+func (s *JobService) execute(id uint64, name string, run func() (interface{}, error)) {
+	startedAt := time.Now()
+	if err := s.repo.UpdateJob(id, map[string]interface{}{"status": models.JobStatusRunning, "started_at": startedAt}); err != nil {
+		zaplogger.Error("Job update failed", zaplogger.Fields{"job_id": id, "job": name, "error": err.Error()})
+	}
+
+	updates := map[string]interface{}{"status": models.JobStatusSucceeded}
+	result, err := runJob(name, run)
+	if err != nil {
+		updates["status"] = models.JobStatusFailed
+		updates["error"] = err.Error()
+	}
+	if result != nil {
+		if resultJSON, err := json.Marshal(result); err == nil {
+			updates["result"] = resultJSON
+		}
+	}
+	finishedAt := time.Now()
+	updates["finished_at"] = finishedAt
+
+	if err := s.repo.UpdateJob(id, updates); err != nil {
+		zaplogger.Error("Job update failed", zaplogger.Fields{"job_id": id, "job": name, "error": err.Error()})
+	}
+	zaplogger.Info("Job finished", zaplogger.Fields{
+		"job_id":   id,
+		"job":      name,
+		"status":   updates["status"],
+		"duration": finishedAt.Sub(startedAt).String(),
+	})
+}
+
+// runJob runs the job, a panic is reported and returned as the error of the job so the job does not stay
+// running and block the later runs of its name
+func runJob(name string, run func() (interface{}, error)) (result interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			recovery.Report("job."+name, recovered, debug.Stack())
+			result, err = nil, fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return run()
+}
+
+// GetJob gets a job by id
+func (s *JobService) GetJob(id uint64) (*models.JobModel, error) {
+	return s.repo.GetJob(id)
+}
+
+// FailInterruptedJobs marks jobs left active by a previous process as failed
+func (s *JobService) FailInterruptedJobs() (int64, error) {
+	return s.repo.FailActiveJobs("interrupted by server restart")
+}
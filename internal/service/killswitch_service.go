@@ -0,0 +1,212 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// ErrKillSwitchEngaged is returned for actions blocked by an engaged kill switch
+var ErrKillSwitchEngaged = errors.New("kill switch engaged")
+
+// killSwitchEventsLimit is the number of audit events returned with the kill switch status
+const killSwitchEventsLimit = 50
+
+// killSwitchHook stops an activity of a user when a kill switch covering the user is engaged
+type killSwitchHook struct {
+	userID string
+	name   string
+	stop   func()
+}
+
+// KillSwitch is the process wide state of the kill switches with the running activities they stop
+type KillSwitch struct {
+	mu       sync.RWMutex
+	engaged  map[string]models.KillSwitchModel
+	hooks    map[uint64]killSwitchHook
+	nextHook uint64
+}
+
+var (
+	killSwitch     *KillSwitch
+	killSwitchOnce sync.Once
+)
+
+// GetKillSwitch returns the process wide kill switch state
+func GetKillSwitch() *KillSwitch {
+	killSwitchOnce.Do(func() {
+		killSwitch = &KillSwitch{
+			engaged: make(map[string]models.KillSwitchModel),
+			hooks:   make(map[uint64]killSwitchHook),
+		}
+	})
+	return killSwitch
+}
+
+// Check returns ErrKillSwitchEngaged when the global kill switch or the user's kill switch is engaged
+func (k *KillSwitch) Check(userID string) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if engaged, ok := k.engaged[models.KillSwitchGlobal]; ok {
+		return fmt.Errorf("%w globally: %s", ErrKillSwitchEngaged, engaged.Reason)
+	}
+	if engaged, ok := k.engaged[userID]; ok && userID != "" {
+		return fmt.Errorf("%w for %s: %s", ErrKillSwitchEngaged, userID, engaged.Reason)
+	}
+	return nil
+}
+
+// IsGlobalEngaged returns true if the global kill switch is engaged
+func (k *KillSwitch) IsGlobalEngaged() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, ok := k.engaged[models.KillSwitchGlobal]
+	return ok
+}
+
+// Register registers the stop function of a running activity of the user, such as the ticker or a stream,
+// the returned function unregisters it once the activity has ended
+func (k *KillSwitch) Register(userID, name string, stop func()) func() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.nextHook++
+	id := k.nextHook
+	k.hooks[id] = killSwitchHook{userID: userID, name: name, stop: stop}
+	return func() {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		delete(k.hooks, id)
+	}
+}
+
+// set replaces the engaged kill switches
+func (k *KillSwitch) set(killSwitches []models.KillSwitchModel) {
+	engaged := make(map[string]models.KillSwitchModel, len(killSwitches))
+	for _, killSwitch := range killSwitches {
+		engaged[killSwitch.Scope] = killSwitch
+	}
+	k.mu.Lock()
+	k.engaged = engaged
+	k.mu.Unlock()
+}
+
+// engage marks the kill switch engaged and stops the running activities it covers, it returns the number stopped
+func (k *KillSwitch) engage(killSwitch models.KillSwitchModel) int {
+	k.mu.Lock()
+	k.engaged[killSwitch.Scope] = killSwitch
+	hooks := make([]killSwitchHook, 0)
+	for _, hook := range k.hooks {
+		if killSwitch.Scope == models.KillSwitchGlobal || hook.userID == killSwitch.Scope {
+			hooks = append(hooks, hook)
+		}
+	}
+	k.mu.Unlock()
+
+	// the stop functions unregister their hooks, they run without the lock
+	for _, hook := range hooks {
+		zaplogger.Warn("Kill switch stopping", zaplogger.Fields{
+			"scope":    killSwitch.Scope,
+			"user_id":  hook.userID,
+			"activity": hook.name,
+		})
+		hook.stop()
+	}
+	return len(hooks)
+}
+
+// release marks the kill switch released, it returns false when it was not engaged
+func (k *KillSwitch) release(scope string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	_, ok := k.engaged[scope]
+	delete(k.engaged, scope)
+	return ok
+}
+
+// KillSwitchService is the service for engaging and releasing the kill switches
+type KillSwitchService struct {
+	repo *repository.KillSwitchRepository
+}
+
+// NewKillSwitchService creates a new kill switch service
+func NewKillSwitchService(db *gorm.DB) *KillSwitchService {
+	return &KillSwitchService{repo: repository.NewKillSwitchRepository(db)}
+}
+
+// Load loads the engaged kill switches into the process wide state, kill switches stay engaged across restarts
+func (s *KillSwitchService) Load() (int, error) {
+	killSwitches, err := s.repo.GetKillSwitches()
+	if err != nil {
+		return 0, err
+	}
+	GetKillSwitch().set(killSwitches)
+	return len(killSwitches), nil
+}
+
+// Engage engages the kill switch of the user, or the global kill switch for a blank user, and immediately
+// stops the ticker and the streams it covers. The global kill switch also pauses the cron jobs
+func (s *KillSwitchService) Engage(userID, actor, reason string) (models.KillSwitchEventModel, error) {
+	scope := userID
+	if scope == "" {
+		scope = models.KillSwitchGlobal
+	}
+	killSwitch := models.KillSwitchModel{Scope: scope, Reason: reason, EngagedBy: actor, EngagedAt: time.Now()}
+	event := models.KillSwitchEventModel{Scope: scope, Action: models.KillSwitchActionEngage, Reason: reason, Actor: actor}
+
+	// stop first, a failed write must not delay the kill
+	event.Stopped = GetKillSwitch().engage(killSwitch)
+	if err := s.repo.EngageKillSwitch(&killSwitch, &event); err != nil {
+		return event, fmt.Errorf("kill switch engaged but not persisted: %v", err)
+	}
+	zaplogger.Warn("Kill switch engaged", zaplogger.Fields{
+		"scope":   scope,
+		"actor":   actor,
+		"reason":  reason,
+		"stopped": event.Stopped,
+	})
+	return event, nil
+}
+
+// Release re-enables the user, or everything for a blank user, recording who released it and why. Stopped
+// activities are not restarted, gorm.ErrRecordNotFound is returned when the kill switch is not engaged
+func (s *KillSwitchService) Release(userID, actor, reason string) (models.KillSwitchEventModel, error) {
+	scope := userID
+	if scope == "" {
+		scope = models.KillSwitchGlobal
+	}
+	event := models.KillSwitchEventModel{Scope: scope, Action: models.KillSwitchActionRelease, Reason: reason, Actor: actor}
+	released, err := s.repo.ReleaseKillSwitch(&event)
+	if err != nil {
+		return event, err
+	}
+	// a kill switch whose engage was not persisted is only engaged in memory
+	if !GetKillSwitch().release(scope) && !released {
+		return event, gorm.ErrRecordNotFound
+	}
+	zaplogger.Warn("Kill switch released", zaplogger.Fields{
+		"scope":  scope,
+		"actor":  actor,
+		"reason": reason,
+	})
+	return event, nil
+}
+
+// GetStatus returns the engaged kill switches and the latest audit events
+func (s *KillSwitchService) GetStatus() (models.KillSwitchStatus, error) {
+	status := models.KillSwitchStatus{}
+	var err error
+	if status.Engaged, err = s.repo.GetKillSwitches(); err != nil {
+		return status, err
+	}
+	if status.Events, err = s.repo.GetKillSwitchEvents(killSwitchEventsLimit); err != nil {
+		return status, err
+	}
+	return status, nil
+}
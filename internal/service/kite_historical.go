@@ -0,0 +1,119 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/requestid"
+)
+
+// kiteHistoricalURL is the kite web historical candles endpoint which accepts an enctoken
+const kiteHistoricalURL = "https://kite.zerodha.com/oms/instruments/historical/%d/%s"
+
+// errKiteHistoricalRateLimited is returned when kite rejects a historical request for going over its rate limit
+var errKiteHistoricalRateLimited = errors.New("kite historical rate limit exceeded")
+
+// kiteHistoricalResponse is the response of the kite historical endpoint, each candle is
+// [timestamp, open, high, low, close, volume, oi]
+type kiteHistoricalResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Candles [][]interface{} `json:"candles"`
+	} `json:"data"`
+}
+
+// fetchKiteMinuteCandles fetches the 1 minute candles of the instrument from from up to and including to,
+// the request id of the context is sent along
+func fetchKiteMinuteCandles(ctx context.Context, userID, enctoken string, instrumentToken uint32, instrument string, from, to time.Time) ([]models.CandleModel, error) {
+	params := url.Values{}
+	params.Set("user_id", userID)
+	params.Set("oi", "1")
+	params.Set("from", from.In(MarketLocation).Format("2006-01-02 15:04:05"))
+	params.Set("to", to.In(MarketLocation).Format("2006-01-02 15:04:05"))
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(kiteHistoricalURL, instrumentToken, "minute")+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "enctoken "+enctoken)
+	requestid.SetHeader(req, ctx)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch historical candles: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, errKiteHistoricalRateLimited
+	}
+
+	// the numbers are kept as decoded text so the prices are not rounded through a float
+	var body kiteHistoricalResponse
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode historical candles: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Status != "success" {
+		return nil, fmt.Errorf("failed to fetch historical candles: %s %s", resp.Status, body.Message)
+	}
+
+	candles := make([]models.CandleModel, 0, len(body.Data.Candles))
+	for _, row := range body.Data.Candles {
+		if len(row) < 6 {
+			continue
+		}
+		timestamp, ok := row[0].(string)
+		if !ok {
+			continue
+		}
+		at, err := time.Parse("2006-01-02T15:04:05-0700", timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse candle timestamp %s: %v", timestamp, err)
+		}
+		candle := models.CandleModel{
+			InstrumentToken: instrumentToken,
+			InstrumentID:    GetInstrumentCache().GetInstrumentID(instrumentToken),
+			Timestamp:       at,
+			Instrument:      instrument,
+			Open:            kitePrice(row[1]),
+			High:            kitePrice(row[2]),
+			Low:             kitePrice(row[3]),
+			Close:           kitePrice(row[4]),
+			Volume:          kiteCount(row[5]),
+			Source:          models.CandleSourceBackfill,
+		}
+		if len(row) > 6 {
+			candle.OI = kiteCount(row[6])
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// kitePrice returns the price of a decoded json number, 0 for any other value
+func kitePrice(value interface{}) decimal.Decimal {
+	number, _ := value.(json.Number)
+	price, _ := decimal.NewFromString(number.String())
+	return price
+}
+
+// kiteCount returns the count of a decoded json number, 0 for any other value
+func kiteCount(value interface{}) int64 {
+	number, _ := value.(json.Number)
+	count, err := number.Int64()
+	if err != nil {
+		f, _ := number.Float64()
+		return int64(f)
+	}
+	return count
+}
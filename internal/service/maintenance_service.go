@@ -0,0 +1,199 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// maintenanceArchiveBatchSize is the number of rows written to an archive object
+const maintenanceArchiveBatchSize = 50000
+
+// tickerLogErrorLevels is the condition of the ticker logs kept for the error retention days
+var tickerLogErrorLevels = fmt.Sprintf("level IN ('%s', '%s')", models.ERROR, models.FATAL)
+
+// maintenanceRetention is a table, or the rows of a table matching the SQL condition, pruned of rows older than
+// the retention days of the config, 0 keeps all rows. The pruned rows are archived to the backup store under the
+// name first when archive returns true
+type maintenanceRetention struct {
+	name      string
+	table     string
+	column    string
+	condition string
+	days      func(cfg *config.Config) string
+	archive   func(cfg *config.Config) bool
+}
+
+// maintenanceRetentions are the tables pruned by the maintenance
+var maintenanceRetentions = []maintenanceRetention{
+	{"ticker_logs", models.TickerLogTableName, "timestamp", "level IS NULL OR NOT (" + tickerLogErrorLevels + ")",
+		func(cfg *config.Config) string { return cfg.RetentionTickerLogsDays }, archiveTickerLogs},
+	{"ticker_logs_errors", models.TickerLogTableName, "timestamp", tickerLogErrorLevels,
+		func(cfg *config.Config) string { return cfg.RetentionTickerLogsErrorDays }, archiveTickerLogs},
+	{models.JobsTableName, models.JobsTableName, "created_at", "TRUE", func(cfg *config.Config) string { return cfg.RetentionJobsDays }, nil},
+	{models.ActivityEventsTableName, models.ActivityEventsTableName, "created_at", "TRUE", func(cfg *config.Config) string { return cfg.RetentionActivityDays }, nil},
+}
+
+// archiveTickerLogs returns true if the pruned ticker logs are archived
+func archiveTickerLogs(cfg *config.Config) bool {
+	// validated when the config is loaded
+	archive, _ := strconv.ParseBool(cfg.RetentionTickerLogsArchive)
+	return archive
+}
+
+// maintenanceVacuumTables are the hot tables vacuumed and analyzed by the maintenance
+var maintenanceVacuumTables = []string{
+	models.TickerDataTableName,
+	models.IndexTicksTableName,
+	models.TickerInstrumentsTableName,
+	models.InstrumentsTableName,
+	models.PrevClosesTableName,
+	models.TickStatsTableName,
+}
+
+// maintenanceReindexTables are the tables whose indexes are rebuilt by the maintenance
+var maintenanceReindexTables = []string{
+	models.TickerDataTableName,
+}
+
+// MaintenanceService is the service for the database housekeeping
+type MaintenanceService struct {
+	repo *repository.MaintenanceRepository
+}
+
+// NewMaintenanceService creates a new maintenance service
+func NewMaintenanceService(db *gorm.DB) *MaintenanceService {
+	return &MaintenanceService{repo: repository.NewMaintenanceRepository(db)}
+}
+
+// RunMaintenance prunes the tables with a retention, vacuums and analyzes the hot and pruned tables and
+// reindexes the ticker data. A failing table is reported in its result and does not stop the run
+func (s *MaintenanceService) RunMaintenance() (models.MaintenanceResult, error) {
+	start := time.Now()
+	result := models.MaintenanceResult{Tables: make([]models.MaintenanceTableResult, 0)}
+
+	cfg, err := config.Get()
+	if err != nil {
+		return result, err
+	}
+
+	tables := make(map[string]*models.MaintenanceTableResult)
+	order := make([]string, 0)
+	tableResult := func(table string) *models.MaintenanceTableResult {
+		if t, ok := tables[table]; ok {
+			return t
+		}
+		t := &models.MaintenanceTableResult{Table: table}
+		t.SizeBefore, _ = s.repo.GetTableSize(table)
+		tables[table] = t
+		order = append(order, table)
+		return t
+	}
+
+	for _, retention := range maintenanceRetentions {
+		// values are validated when the config is loaded
+		days, _ := strconv.Atoi(retention.days(cfg))
+		if days <= 0 {
+			continue
+		}
+		t := tableResult(retention.table)
+		cutoff := time.Now().AddDate(0, 0, -days)
+		if retention.archive != nil && retention.archive(cfg) {
+			archived, err := s.archive(cfg, retention, cutoff)
+			t.Archived += archived
+			// rows are only pruned once they are archived
+			if err != nil {
+				t.Error = err.Error()
+				continue
+			}
+		}
+		pruned, err := s.repo.PruneOlderThanWhere(retention.table, retention.column, retention.condition, cutoff)
+		t.Pruned += pruned
+		if err != nil {
+			t.Error = err.Error()
+		}
+	}
+
+	for _, table := range maintenanceVacuumTables {
+		tableResult(table)
+	}
+	for _, table := range order {
+		t := tables[table]
+		if err := s.repo.VacuumAnalyze(table); err != nil {
+			t.Error = err.Error()
+			continue
+		}
+		t.Vacuumed = true
+	}
+
+	for _, table := range maintenanceReindexTables {
+		t := tableResult(table)
+		if err := s.repo.Reindex(table); err != nil {
+			t.Error = err.Error()
+			continue
+		}
+		t.Reindexed = true
+	}
+
+	for _, table := range order {
+		t := tables[table]
+		t.SizeAfter, _ = s.repo.GetTableSize(table)
+		if t.SizeBefore > t.SizeAfter {
+			t.BytesReclaimed = t.SizeBefore - t.SizeAfter
+		}
+		result.Pruned += t.Pruned
+		result.BytesReclaimed += t.BytesReclaimed
+		result.Tables = append(result.Tables, *t)
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// archive writes the rows of the retention older than the cutoff to the backup store as gzipped JSON arrays
+// of at most maintenanceArchiveBatchSize rows, it returns the number of archived rows
+func (s *MaintenanceService) archive(cfg *config.Config, retention maintenanceRetention, cutoff time.Time) (int64, error) {
+	store, err := newObjectStore(cfg, cfg.BackupURL)
+	if err != nil {
+		return 0, err
+	}
+	runAt := time.Now().UTC().Format("20060102T150405Z")
+	var archived int64
+	for part := 0; ; part++ {
+		rows, count, err := s.repo.DumpOlderThanWhere(retention.table, retention.column, retention.condition, cutoff, part*maintenanceArchiveBatchSize, maintenanceArchiveBatchSize)
+		if err != nil {
+			return archived, err
+		}
+		if count == 0 {
+			return archived, nil
+		}
+
+		var data bytes.Buffer
+		writer := gzip.NewWriter(&data)
+		if _, err := writer.Write(rows); err != nil {
+			return archived, fmt.Errorf("failed to compress archive of %s: %v", retention.table, err)
+		}
+		if err := writer.Close(); err != nil {
+			return archived, fmt.Errorf("failed to compress archive of %s: %v", retention.table, err)
+		}
+		key := fmt.Sprintf("archive/%s/%s-%04d.json.gz", retention.name, runAt, part)
+		ctx, cancel := context.WithTimeout(context.Background(), objectStoreTimeout)
+		err = store.put(ctx, key, data.Bytes())
+		cancel()
+		if err != nil {
+			return archived, err
+		}
+		archived += count
+		if count < maintenanceArchiveBatchSize {
+			return archived, nil
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// marketClock is the clock of the scheduler and the trading hour checks, it runs simulated from a start time
+// at a speed so the market lifecycle can be exercised outside trading hours
+type marketClock struct {
+	mu        sync.RWMutex
+	simulated bool
+	realStart time.Time
+	simStart  time.Time
+	speed     float64
+}
+
+var clock = &marketClock{speed: 1}
+
+// SetSimulatedClock runs the market clock from start, advancing speed times faster than real time,
+// it must be called before the services are created
+func SetSimulatedClock(start time.Time, speed float64) {
+	if speed <= 0 {
+		speed = 1
+	}
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.simulated = true
+	clock.realStart = time.Now()
+	clock.simStart = start
+	clock.speed = speed
+}
+
+// IsSimulatedClock returns true if the market clock is simulated
+func IsSimulatedClock() bool {
+	clock.mu.RLock()
+	defer clock.mu.RUnlock()
+	return clock.simulated
+}
+
+// MarketNow returns the current time of the market clock in the market time zone, the real time
+// unless the clock is simulated
+func MarketNow() time.Time {
+	clock.mu.RLock()
+	defer clock.mu.RUnlock()
+	now := time.Now()
+	if !clock.simulated {
+		return now.In(MarketLocation)
+	}
+	elapsed := time.Duration(float64(now.Sub(clock.realStart)) * clock.speed)
+	return clock.simStart.Add(elapsed).In(MarketLocation)
+}
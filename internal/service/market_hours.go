@@ -0,0 +1,113 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+)
+
+// MarketLocation is the time zone of the exchanges, all market time logic uses it regardless of the server time zone
+var MarketLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// SetMarketLocation sets the market time zone from an IANA time zone name, it must be called before the services are created
+func SetMarketLocation(name string) error {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("failed to load market time zone %q: %v", name, err)
+	}
+	MarketLocation = location
+	// the timestamps of the responses are formatted without a zone in the market time zone
+	response.SetTimestampLocation(location)
+	return nil
+}
+
+// MarketToday returns the current date of the market clock in the market time zone, the simulated date
+// while the clock is simulated
+func MarketToday() string {
+	return MarketNow().Format("2006-01-02")
+}
+
+// Market phases
+const (
+	MarketPhasePreOpen       = "pre_open"
+	MarketPhasePreOpenClosed = "pre_open_closed"
+	MarketPhaseOpen          = "open"
+	MarketPhaseClosed        = "closed"
+)
+
+// tradingSession is the regular trading session of an exchange in minutes after midnight,
+// exchanges with a pre-open call auction have a non zero preOpen and preOpenEnd
+type tradingSession struct {
+	open       int
+	close      int
+	preOpen    int
+	preOpenEnd int
+}
+
+// tradingSessions are the regular trading sessions by exchange, exchange holidays are not considered
+var tradingSessions = map[string]tradingSession{
+	"NSE": {9*60 + 15, 15*60 + 30, 9 * 60, 9*60 + 8},
+	"BSE": {9*60 + 15, 15*60 + 30, 9 * 60, 9*60 + 8},
+	"NFO": {9*60 + 15, 15*60 + 30, 0, 0},
+	"BFO": {9*60 + 15, 15*60 + 30, 0, 0},
+	"CDS": {9 * 60, 17 * 60, 0, 0},
+	"BCD": {9 * 60, 17 * 60, 0, 0},
+	"MCX": {9 * 60, 23*60 + 30, 0, 0},
+}
+
+// defaultQuoteStaleAfter is used when the config can not be loaded
+const defaultQuoteStaleAfter = 60 * time.Second
+
+// IsMarketOpen returns true if t is within the regular trading session of the exchange
+func IsMarketOpen(exchange string, t time.Time) bool {
+	return GetMarketPhase(exchange, t) == MarketPhaseOpen
+}
+
+// GetMarketPhase returns the market phase of the exchange at t
+func GetMarketPhase(exchange string, t time.Time) string {
+	session, ok := tradingSessions[exchange]
+	if !ok {
+		session = tradingSessions["NSE"]
+	}
+	t = t.In(MarketLocation)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return MarketPhaseClosed
+	}
+	minutes := t.Hour()*60 + t.Minute()
+	switch {
+	case minutes >= session.open && minutes < session.close:
+		return MarketPhaseOpen
+	case session.preOpenEnd > 0 && minutes >= session.preOpen && minutes < session.preOpenEnd:
+		return MarketPhasePreOpen
+	case session.preOpenEnd > 0 && minutes >= session.preOpenEnd && minutes < session.open:
+		return MarketPhasePreOpenClosed
+	default:
+		return MarketPhaseClosed
+	}
+}
+
+// IsQuoteStale returns true if a quote of the instrument (exchange:tradingsymbol) as of asOf
+// is not live, either because the market is closed or because the last update is too old
+func IsQuoteStale(instrument string, asOf, now time.Time) bool {
+	exchange, _, _ := strings.Cut(instrument, ":")
+	if !IsMarketOpen(exchange, now) {
+		return true
+	}
+	return now.Sub(asOf) > quoteStaleAfter()
+}
+
+// quoteStaleAfter returns the age after which a quote is stale during market hours
+func quoteStaleAfter() time.Duration {
+	cfg, err := config.Get()
+	if err != nil {
+		return defaultQuoteStaleAfter
+	}
+	// validated when the config is loaded
+	seconds, _ := strconv.Atoi(cfg.QuoteStaleSeconds)
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,206 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// newsDefaultLimit is the maximum number of announcements returned by a query
+const newsDefaultLimit = 500
+
+// companyNameSuffixes are stripped from company names before they are matched to instruments
+var companyNameSuffixes = []string{" LIMITED", " LTD.", " LTD"}
+
+// NewsService is the service for the exchange announcements
+type NewsService struct {
+	repo           *repository.AnnouncementRepository
+	instrumentRepo *repository.InstrumentRepository
+	cache          *InstrumentCache
+}
+
+// NewNewsService creates a new news service
+func NewNewsService(db *gorm.DB) *NewsService {
+	return &NewsService{
+		repo:           repository.NewAnnouncementRepository(db),
+		instrumentRepo: repository.NewInstrumentRepository(db),
+		cache:          GetInstrumentCache(),
+	}
+}
+
+// announcementsFeed is the RSS feed of the exchange announcements
+type announcementsFeed struct {
+	Items []struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		PubDate     string `xml:"pubDate"`
+		GUID        string `xml:"guid"`
+	} `xml:"channel>item"`
+}
+
+// UpdateAnnouncements fetches the exchange announcements feed, stores the new announcements and
+// publishes them to the news subscribers
+func (s *NewsService) UpdateAnnouncements() (int, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return 0, err
+	}
+	if cfg.AnnouncementsURL == "" {
+		return 0, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, cfg.AnnouncementsURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create announcements request: %v", err)
+	}
+	req.Header.Set("User-Agent", exchangeUserAgent)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch announcements: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to fetch announcements: %s", resp.Status)
+	}
+
+	var feed announcementsFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return 0, fmt.Errorf("failed to parse announcements: %v", err)
+	}
+
+	if !s.cache.IsLoaded() {
+		if _, err := s.cache.Load(s.instrumentRepo); err != nil {
+			return 0, err
+		}
+	}
+	companies := s.companyIndex()
+
+	announcements := make([]models.AnnouncementModel, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		publishedAt, err := parseAnnouncementTime(item.PubDate)
+		if err != nil {
+			continue
+		}
+		guid := strings.TrimSpace(item.GUID)
+		if guid == "" {
+			guid = strings.TrimSpace(item.Link) + "|" + publishedAt.Format(time.RFC3339)
+		}
+		company := strings.TrimSpace(item.Title)
+		announcement := models.AnnouncementModel{
+			GUID:        guid,
+			Company:     company,
+			Subject:     strings.TrimSpace(item.Description),
+			Link:        strings.TrimSpace(item.Link),
+			PublishedAt: publishedAt,
+		}
+		if instrument, ok := companies[normalizeCompanyName(company)]; ok {
+			announcement.Symbol = instrument.Tradingsymbol
+			announcement.Instrument = instrument.Exchange + ":" + instrument.Tradingsymbol
+		}
+		announcements = append(announcements, announcement)
+	}
+
+	inserted, err := s.repo.InsertAnnouncements(announcements)
+	if err != nil {
+		return 0, err
+	}
+	for _, announcement := range inserted {
+		GetNewsHub().Publish(announcement)
+	}
+	return len(inserted), nil
+}
+
+// companyIndex maps the normalized names and tradingsymbols of the NSE equities to the instrument
+func (s *NewsService) companyIndex() map[string]models.InstrumentModel {
+	companies := make(map[string]models.InstrumentModel)
+	s.cache.ForEach(func(instrument models.InstrumentModel) {
+		if instrument.Exchange != "NSE" || instrument.InstrumentType != "EQ" {
+			return
+		}
+		companies[normalizeCompanyName(instrument.Tradingsymbol)] = instrument
+		if instrument.Name != "" {
+			companies[normalizeCompanyName(instrument.Name)] = instrument
+		}
+	})
+	return companies
+}
+
+// normalizeCompanyName upper cases the name and strips the company suffix
+func normalizeCompanyName(name string) string {
+	name = strings.ToUpper(strings.Join(strings.Fields(name), " "))
+	for _, suffix := range companyNameSuffixes {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return name
+}
+
+// parseAnnouncementTime parses the publication time of an announcement in the market time zone
+func parseAnnouncementTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, "02-Jan-2006 15:04:05", "02-Jan-2006 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, MarketLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid announcement time: %s", value)
+}
+
+// GetAnnouncements gets the announcements published since, optionally for a symbol
+func (s *NewsService) GetAnnouncements(symbol string, since time.Time) ([]models.AnnouncementModel, error) {
+	return s.repo.GetAnnouncements(strings.ToUpper(symbol), since, newsDefaultLimit)
+}
+
+// NewsHub fans out new announcements to the news stream subscribers
+type NewsHub struct {
+	mu          sync.RWMutex
+	subscribers map[chan models.AnnouncementModel]struct{}
+}
+
+var (
+	newsHub     *NewsHub
+	newsHubOnce sync.Once
+)
+
+// GetNewsHub returns the process wide news hub
+func GetNewsHub() *NewsHub {
+	newsHubOnce.Do(func() {
+		newsHub = &NewsHub{subscribers: make(map[chan models.AnnouncementModel]struct{})}
+	})
+	return newsHub
+}
+
+// Subscribe returns a channel receiving new announcements and a function to unsubscribe
+func (h *NewsHub) Subscribe() (<-chan models.AnnouncementModel, func()) {
+	ch := make(chan models.AnnouncementModel, 100)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Publish sends the announcement to every subscriber, slow subscribers miss it
+func (h *NewsHub) Publish(announcement models.AnnouncementModel) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- announcement:
+		default:
+		}
+	}
+}
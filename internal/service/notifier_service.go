@@ -0,0 +1,375 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// notificationCategories are the accepted notification categories
+var notificationCategories = []string{
+	models.NotificationCategoryAlerts,
+	models.NotificationCategoryJobFailures,
+	models.NotificationCategorySessionExpiry,
+	models.NotificationCategoryReports,
+}
+
+// telegramChatIDRegexp matches a numeric telegram chat id or a public @channel name
+var telegramChatIDRegexp = regexp.MustCompile(`^(-?\d+|@\w{5,})$`)
+
+// notificationTimeout is the timeout of a single outbound notification
+const notificationTimeout = 10 * time.Second
+
+// errNotificationChannelDisabled is returned by a sender when the user has no address for its channel
+var errNotificationChannelDisabled = errors.New("channel not configured")
+
+// errWebhookAddressBlocked is returned when a webhook resolves to an address of the server's own networks
+var errWebhookAddressBlocked = errors.New("webhook address is not a public address")
+
+// notificationSender delivers a notification over one channel
+type notificationSender interface {
+	Channel() string
+	Send(preferences models.NotificationPreferencesModel, notification models.Notification) error
+}
+
+// NotifierService sends all outbound messages to users over the channels of their notification preferences,
+// rendered in the time zone and number format of their user settings
+type NotifierService struct {
+	repo         *repository.NotificationRepository
+	userSettings *UserService
+	senders      []notificationSender
+}
+
+// NewNotifierService creates a new notifier service
+func NewNotifierService(db *gorm.DB) *NotifierService {
+	httpClient := &http.Client{Timeout: notificationTimeout}
+	var botToken string
+	if cfg, err := config.Get(); err == nil {
+		botToken = cfg.TelegramBotToken
+	}
+	return &NotifierService{
+		repo:         repository.NewNotificationRepository(db),
+		userSettings: NewUserService(db),
+		senders: []notificationSender{
+			&telegramSender{httpClient: httpClient, botToken: botToken},
+			&webhookSender{httpClient: newWebhookHTTPClient()},
+			newEmailSender(),
+		},
+	}
+}
+
+// GetNotificationPreferences gets the notification preferences of a user, a user without preferences gets empty preferences
+func (s *NotifierService) GetNotificationPreferences(userID string) (models.NotificationPreferences, error) {
+	preferences := models.NotificationPreferences{Categories: make([]string, 0)}
+	model, err := s.repo.GetNotificationPreferences(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return preferences, nil
+		}
+		return preferences, fmt.Errorf("failed to get notification preferences: %v", err)
+	}
+	return notificationPreferencesFromModel(*model), nil
+}
+
+// SaveNotificationPreferences validates and stores the notification preferences of a user
+func (s *NotifierService) SaveNotificationPreferences(userID string, preferences models.NotificationPreferences) error {
+	if err := ValidateNotificationPreferences(preferences); err != nil {
+		return err
+	}
+	if preferences.Categories == nil {
+		preferences.Categories = make([]string, 0)
+	}
+	categories, err := json.Marshal(preferences.Categories)
+	if err != nil {
+		return fmt.Errorf("failed to encode categories: %v", err)
+	}
+	return s.repo.UpsertNotificationPreferences(&models.NotificationPreferencesModel{
+		UserID:         userID,
+		TelegramChatID: preferences.TelegramChatID,
+		WebhookURL:     preferences.WebhookURL,
+		Email:          preferences.Email,
+		Categories:     categories,
+	})
+}
+
+// DeleteNotificationPreferences deletes the notification preferences of a user
+func (s *NotifierService) DeleteNotificationPreferences(userID string) (int64, error) {
+	return s.repo.DeleteNotificationPreferences(userID)
+}
+
+// ValidateNotificationPreferences validates the channel addresses and categories of the preferences
+func ValidateNotificationPreferences(preferences models.NotificationPreferences) error {
+	if preferences.TelegramChatID != "" && !telegramChatIDRegexp.MatchString(preferences.TelegramChatID) {
+		return fmt.Errorf("invalid `telegram_chat_id` %s, must be a chat id or @channel", preferences.TelegramChatID)
+	}
+	if preferences.WebhookURL != "" {
+		webhookURL, err := url.Parse(preferences.WebhookURL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			return fmt.Errorf("invalid `webhook_url` %s, must be an http or https url", preferences.WebhookURL)
+		}
+		if err := checkWebhookHost(webhookURL.Hostname()); err != nil {
+			return fmt.Errorf("invalid `webhook_url` %s, %v", preferences.WebhookURL, err)
+		}
+	}
+	if preferences.Email != "" {
+		if _, err := mail.ParseAddress(preferences.Email); err != nil {
+			return fmt.Errorf("invalid `email` %s", preferences.Email)
+		}
+	}
+	for _, category := range preferences.Categories {
+		if !containsString(notificationCategories, category) {
+			return fmt.Errorf("invalid `categories` value %s, must be one of %v", category, notificationCategories)
+		}
+	}
+	return nil
+}
+
+// Notify sends the operational notification in the background to every admin subscribed to the category
+func (s *NotifierService) Notify(category, subject, message string) {
+	s.NotifyReport(category, subject, func(reportFormat) string { return message })
+}
+
+// NotifyReport sends the operational notification in the background to every admin subscribed to the
+// category, the message is rendered for each admin in their format. The messages carry internal errors and
+// state so other users never get them
+func (s *NotifierService) NotifyReport(category, subject string, render reportRenderer) {
+	recovery.Go("notifier.Notify", func() {
+		cfg, err := config.Get()
+		if err != nil {
+			zaplogger.Error("Notify", zaplogger.Fields{"category": category, "error": err.Error()})
+			return
+		}
+		preferences, err := s.repo.GetNotificationPreferencesByCategory(category)
+		if err != nil {
+			zaplogger.Error("Notify", zaplogger.Fields{"category": category, "error": err.Error()})
+			return
+		}
+		for _, p := range preferences {
+			if cfg.IsAdmin(p.UserID) {
+				s.send(p, s.notification(p.UserID, category, subject, render))
+			}
+		}
+	})
+}
+
+// NotifyUser sends the notification in the background to the user if they are subscribed to the category
+func (s *NotifierService) NotifyUser(userID, category, subject, message string) {
+	s.NotifyUserReport(userID, category, subject, func(reportFormat) string { return message })
+}
+
+// NotifyUserReport sends the notification in the background to the user if they are subscribed to the
+// category, the message is rendered in the format of the user
+func (s *NotifierService) NotifyUserReport(userID, category, subject string, render reportRenderer) {
+	recovery.Go("notifier.NotifyUser", func() {
+		preferences, err := s.repo.GetNotificationPreferences(userID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				zaplogger.Error("NotifyUser", zaplogger.Fields{"user_id": userID, "error": err.Error()})
+			}
+			return
+		}
+		if !containsString(notificationPreferencesFromModel(*preferences).Categories, category) {
+			return
+		}
+		s.send(*preferences, s.notification(userID, category, subject, render))
+	})
+}
+
+// SendTestNotification sends a test notification over every configured channel of the user and returns
+// the outcome of each channel
+func (s *NotifierService) SendTestNotification(userID string) (map[string]string, error) {
+	preferences, err := s.repo.GetNotificationPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.send(*preferences, s.notification(userID, "test", "Test notification", func(f reportFormat) string {
+		return "Notifications from the Moneybots API are set up for " + userID + ", sent at " + f.Time(time.Now())
+	})), nil
+}
+
+// notification renders the notification for the user, with the timestamp in their time zone. The market
+// time zone and plain numbers are used when the user settings cannot be read
+func (s *NotifierService) notification(userID, category, subject string, render reportRenderer) models.Notification {
+	settings, err := s.userSettings.GetUserSettings(userID)
+	if err != nil {
+		zaplogger.Warn("Notification user settings", zaplogger.Fields{"user_id": userID, "error": err.Error()})
+	}
+	f := newReportFormat(settings)
+	return models.Notification{
+		UserID:    userID,
+		Category:  category,
+		Subject:   subject,
+		Message:   render(f),
+		Timestamp: time.Now().In(f.location),
+	}
+}
+
+// send delivers the notification over every channel of the preferences, returning the outcome of each channel
+func (s *NotifierService) send(preferences models.NotificationPreferencesModel, notification models.Notification) map[string]string {
+	results := make(map[string]string, len(s.senders))
+	for _, sender := range s.senders {
+		err := sender.Send(preferences, notification)
+		switch {
+		case errors.Is(err, errNotificationChannelDisabled):
+			results[sender.Channel()] = "disabled"
+		case err != nil:
+			results[sender.Channel()] = err.Error()
+			zaplogger.Error("Notification failed", zaplogger.Fields{
+				"user_id":  notification.UserID,
+				"channel":  sender.Channel(),
+				"category": notification.Category,
+				"error":    err.Error(),
+			})
+		default:
+			results[sender.Channel()] = "sent"
+		}
+	}
+	return results
+}
+
+// notificationPreferencesFromModel decodes the stored preferences
+func notificationPreferencesFromModel(model models.NotificationPreferencesModel) models.NotificationPreferences {
+	preferences := models.NotificationPreferences{
+		TelegramChatID: model.TelegramChatID,
+		WebhookURL:     model.WebhookURL,
+		Email:          model.Email,
+		Categories:     make([]string, 0),
+	}
+	if len(model.Categories) > 0 {
+		_ = json.Unmarshal(model.Categories, &preferences.Categories)
+	}
+	return preferences
+}
+
+// telegramSender sends notifications with the telegram bot api
+type telegramSender struct {
+	httpClient *http.Client
+	botToken   string
+}
+
+// Channel returns the channel of the sender
+func (t *telegramSender) Channel() string {
+	return models.NotificationChannelTelegram
+}
+
+// Send sends the notification to the telegram chat of the user
+func (t *telegramSender) Send(preferences models.NotificationPreferencesModel, notification models.Notification) error {
+	if preferences.TelegramChatID == "" || t.botToken == "" {
+		return errNotificationChannelDisabled
+	}
+	body, err := json.Marshal(map[string]string{
+		"chat_id": preferences.TelegramChatID,
+		"text":    notification.Subject + "\n\n" + notification.Message,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode telegram message: %v", err)
+	}
+	resp, err := t.httpClient.Post("https://api.telegram.org/bot"+t.botToken+"/sendMessage", "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the error carries the url, keep the bot token out of the logs
+		return fmt.Errorf("failed to send telegram message")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookAddressBlocked returns true for the addresses of the server's own networks, loopback, private,
+// link-local (cloud metadata), unspecified and multicast addresses
+func webhookAddressBlocked(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// checkWebhookHost resolves the webhook host and fails when it has no address or any blocked address
+func checkWebhookHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("host %s can not be resolved", host)
+	}
+	for _, addr := range addrs {
+		if webhookAddressBlocked(addr.IP) {
+			return errWebhookAddressBlocked
+		}
+	}
+	return nil
+}
+
+// newWebhookHTTPClient returns the client of the webhooks, the address is checked again when it is dialed so a
+// host resolving differently since it was saved, or a redirect, can not reach the server's own networks.
+// Webhooks are dialed directly, not through a proxy of the environment
+func newWebhookHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: notificationTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || webhookAddressBlocked(ip) {
+				return errWebhookAddressBlocked
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: notificationTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: notificationTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// webhookSender posts notifications as JSON to the webhook of the user
+type webhookSender struct {
+	httpClient *http.Client
+}
+
+// Channel returns the channel of the sender
+func (w *webhookSender) Channel() string {
+	return models.NotificationChannelWebhook
+}
+
+// Send posts the notification to the webhook url of the user
+func (w *webhookSender) Send(preferences models.NotificationPreferencesModel, notification models.Notification) error {
+	if preferences.WebhookURL == "" {
+		return errNotificationChannelDisabled
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %v", err)
+	}
+	resp, err := w.httpClient.Post(preferences.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
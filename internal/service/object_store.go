@@ -0,0 +1,224 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+// objectStoreTimeout is how long a single object upload or download may take
+const objectStoreTimeout = 2 * time.Minute
+
+// objectStore stores objects by key, a key is a slash separated path under the prefix of the store
+type objectStore interface {
+	put(ctx context.Context, key string, data []byte) error
+	get(ctx context.Context, key string) ([]byte, error)
+	location(key string) string
+}
+
+// newObjectStore returns the store of the url, s3://bucket/prefix for an S3 compatible store or
+// file:///directory for a local directory, e.g. a mounted network volume
+func newObjectStore(cfg *config.Config, rawURL string) (objectStore, error) {
+	storeURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object store url: %v", err)
+	}
+	switch storeURL.Scheme {
+	case "file":
+		return &fileObjectStore{dir: storeURL.Path}, nil
+	case "s3":
+		if storeURL.Host == "" {
+			return nil, fmt.Errorf("object store url %q has no bucket", rawURL)
+		}
+		endpoint := cfg.BackupS3Endpoint
+		if endpoint == "" {
+			endpoint = "https://s3." + cfg.BackupS3Region + ".amazonaws.com"
+		}
+		return &s3ObjectStore{
+			client:    &http.Client{Timeout: objectStoreTimeout},
+			endpoint:  strings.TrimSuffix(endpoint, "/"),
+			region:    cfg.BackupS3Region,
+			bucket:    storeURL.Host,
+			prefix:    strings.Trim(storeURL.Path, "/"),
+			accessKey: cfg.BackupS3AccessKey,
+			secretKey: cfg.BackupS3SecretKey,
+		}, nil
+	default:
+		return nil, fmt.Errorf("object store url %q must start with s3:// or file://", rawURL)
+	}
+}
+
+// fileObjectStore stores the objects as files in a directory
+type fileObjectStore struct {
+	dir string
+}
+
+func (s *fileObjectStore) put(ctx context.Context, key string, data []byte) error {
+	path := s.location(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create object directory: %v", err)
+	}
+	// written to a temporary file first so a crash never leaves a partial object
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write object %s: %v", key, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write object %s: %v", key, err)
+	}
+	return nil
+}
+
+func (s *fileObjectStore) get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.location(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %v", key, err)
+	}
+	return data, nil
+}
+
+func (s *fileObjectStore) location(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// s3ObjectStore stores the objects in a bucket of an S3 compatible store, the requests are path style
+// and signed with AWS signature version 4
+type s3ObjectStore struct {
+	client    *http.Client
+	endpoint  string
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+}
+
+func (s *s3ObjectStore) put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to put object %s: status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (s *s3ObjectStore) get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to get object %s: status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %v", key, err)
+	}
+	return data, nil
+}
+
+func (s *s3ObjectStore) location(key string) string {
+	return "s3://" + s.bucket + "/" + s.objectKey(key)
+}
+
+// objectKey returns the key of the object in the bucket
+func (s *s3ObjectStore) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+// do sends a signed request for the object
+func (s *s3ObjectStore) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	path := "/" + s3URIEncode(s.bucket+"/"+s.objectKey(key))
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object request: %v", err)
+	}
+	s.sign(req, path, body, time.Now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send object request: %v", err)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS signature version 4 authorization of the request
+func (s *s3ObjectStore) sign(req *http.Request, path string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// s3URIEncode encodes the path as the signature requires, every byte except the unreserved characters
+// and the slashes is percent encoded
+func s3URIEncode(value string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			encoded.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&encoded, "%%%02X", c)
+	}
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,116 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// optionChainCacheCapacity is the maximum number of option chains kept in the cache
+const optionChainCacheCapacity = 128
+
+// OptionChainCache is a short lived in-memory LRU of option chains keyed by exchange, name, future and option expiry,
+// the cached chains are shared and must not be modified
+type OptionChainCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// optionChainCacheEntry is a cached option chain with its expiry
+type optionChainCacheEntry struct {
+	key       string
+	chain     models.OptionChain
+	expiresAt time.Time
+}
+
+var (
+	optionChainCache     *OptionChainCache
+	optionChainCacheOnce sync.Once
+)
+
+// GetOptionChainCache returns the process wide option chain cache
+func GetOptionChainCache() *OptionChainCache {
+	optionChainCacheOnce.Do(func() {
+		optionChainCache = &OptionChainCache{
+			ttl:     getOptionChainCacheTTL(),
+			entries: make(map[string]*list.Element),
+			lru:     list.New(),
+		}
+	})
+	return optionChainCache
+}
+
+// getOptionChainCacheTTL returns the configured time to live of cached option chains, 0 disables the cache
+func getOptionChainCacheTTL() time.Duration {
+	cfg, err := config.Get()
+	if err != nil {
+		return 0
+	}
+	// validated when the config is loaded
+	seconds, _ := strconv.Atoi(cfg.OptionChainCacheSeconds)
+	return time.Duration(seconds) * time.Second
+}
+
+// optionChainCacheKey returns the cache key of an option chain, a blank future expiry resolves to the
+// nearest future which changes with the date
+func optionChainCacheKey(exchange, name, futExpiry, optExpiry string) string {
+	if futExpiry == "" {
+		futExpiry = "nearest@" + MarketToday()
+	}
+	return exchange + "|" + name + "|" + futExpiry + "|" + optExpiry
+}
+
+// Get returns the cached option chain of the key if it has not expired
+func (c *OptionChainCache) Get(key string) (models.OptionChain, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return models.OptionChain{}, false
+	}
+	entry := element.Value.(*optionChainCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return models.OptionChain{}, false
+	}
+	c.lru.MoveToFront(element)
+	return entry.chain, true
+}
+
+// Set caches the option chain of the key, evicting the least recently used chain when full
+func (c *OptionChainCache) Set(key string, chain models.OptionChain) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*optionChainCacheEntry)
+		entry.chain = chain
+		entry.expiresAt = time.Now().Add(c.ttl)
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&optionChainCacheEntry{key: key, chain: chain, expiresAt: time.Now().Add(c.ttl)})
+	if c.lru.Len() > optionChainCacheCapacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*optionChainCacheEntry).key)
+	}
+}
+
+// Invalidate clears the cache, called when the instruments are reloaded
+func (c *OptionChainCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
@@ -2,33 +2,51 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
 	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 var PostgresChannel = "CH:API:TICKER:DATA"
-var RedisChannel = "CH:API:TICKER:DATA"
+var RedisChannel = redisChannelName
 
 type PublishService struct {
 	db          *gorm.DB
-	redisClient *redis.Client
+	redisClient redis.UniversalClient
 	pgConnStr   string
+	enrich      bool
 }
 
-func NewPublishService(db *gorm.DB, redisClient *redis.Client, pgConnStr string) *PublishService {
+func NewPublishService(db *gorm.DB, redisClient redis.UniversalClient, pgConnStr string) *PublishService {
 
 	return &PublishService{
 		db:          db,
 		redisClient: redisClient,
 		pgConnStr:   pgConnStr,
+		enrich:      getRedisTickEnrichment(),
 	}
 }
 
+// getRedisTickEnrichment returns true if the published ticks carry the instrument metadata
+func getRedisTickEnrichment() bool {
+	cfg, err := config.Get()
+	if err != nil {
+		return false
+	}
+	// validated when the config is loaded
+	enrich, _ := strconv.ParseBool(cfg.RedisTickEnrichment)
+	return enrich
+}
+
 func (s *PublishService) PublishTicksToRedisChannel() {
 
 	// Create a PostgreSQL listener
@@ -40,11 +58,22 @@ func (s *PublishService) PublishTicksToRedisChannel() {
 
 	ctx := context.Background()
 
+	cache := GetInstrumentCache()
+	if s.enrich && !cache.IsLoaded() {
+		if _, err := cache.Load(repository.NewInstrumentRepository(s.db)); err != nil {
+			zaplogger.Error("Failed to load instruments for the tick enrichment", zaplogger.Fields{"error": err})
+		}
+	}
+
 	for {
 		select {
 		case n := <-listener.Notify:
+			payload := n.Extra
+			if s.enrich {
+				payload = enrichTickPayload(cache, payload)
+			}
 			// Publish the notification to Redis
-			err := s.redisClient.Publish(ctx, RedisChannel, n.Extra).Err()
+			err := s.redisClient.Publish(ctx, RedisChannel, payload).Err()
 			if err != nil {
 				zaplogger.Error("Failed to publish to Redis", zaplogger.Fields{"error": err})
 			}
@@ -59,3 +88,36 @@ func (s *PublishService) PublishTicksToRedisChannel() {
 		}
 	}
 }
+
+// enrichTickPayload adds the exchange, tradingsymbol, segment and lot size of the tick's instrument to the
+// JSON payload, the payload is returned unchanged when it is not a tick or the instrument is not cached
+func enrichTickPayload(cache *InstrumentCache, payload string) string {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(payload)))
+	decoder.UseNumber()
+	var tick map[string]interface{}
+	if err := decoder.Decode(&tick); err != nil {
+		return payload
+	}
+	tokenNumber, ok := tick["instrument_token"].(json.Number)
+	if !ok {
+		return payload
+	}
+	token, err := strconv.ParseUint(tokenNumber.String(), 10, 32)
+	if err != nil {
+		return payload
+	}
+	instrument, ok := cache.GetByToken(uint32(token))
+	if !ok {
+		return payload
+	}
+
+	tick["exchange"] = instrument.Exchange
+	tick["tradingsymbol"] = instrument.Tradingsymbol
+	tick["segment"] = instrument.Segment
+	tick["lot_size"] = instrument.LotSize
+	enriched, err := json.Marshal(tick)
+	if err != nil {
+		return payload
+	}
+	return string(enriched)
+}
@@ -4,25 +4,42 @@ package service
 import (
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
+	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
 	"gorm.io/gorm"
 )
 
 // QuoteService is the service for the quote API
 type QuoteService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	tickerRepo    *repository.TickerRepository
+	prevCloseRepo *repository.PrevCloseRepository
+	bandRepo      *repository.BandRepository
+	candleRepo    *repository.CandleRepository
 }
 
 // NewQuoteService creates a new quote service
 func NewQuoteService(db *gorm.DB) *QuoteService {
-	return &QuoteService{db: db}
+	return &QuoteService{
+		db:            db,
+		tickerRepo:    repository.NewTickerRepository(db),
+		prevCloseRepo: repository.NewPrevCloseRepository(db),
+		bandRepo:      repository.NewBandRepository(db),
+		candleRepo:    repository.NewCandleRepository(db),
+	}
 }
 
-// GetTickData gets the tick data for the given instruments
+// GetTickData gets the tick data for the given instruments, indices included, blacklisted instruments have none
 func (s *QuoteService) GetTickData(instruments []string) (map[string]*models.TickerData, error) {
-	var tickerData []models.TickerData
-	err := s.db.Where("instrument IN ?", instruments).Find(&tickerData).Error
+	allowed, _ := GetInstrumentBlacklist().FilterSymbols(instruments)
+	if len(allowed) == 0 {
+		return s.createTickerDataMap(nil, instruments)
+	}
+	tickerData, err := s.tickerRepo.GetTickerDataByInstruments(allowed)
 	if err != nil {
 		log.Printf("Database query error: %v", err)
 		return nil, fmt.Errorf("error fetching tick data from database: %v", err)
@@ -56,3 +73,78 @@ func (s *QuoteService) createTickerDataMap(tickerData []models.TickerData, instr
 
 	return tickerDataMap, nil
 }
+
+// GetPrevCloses gets the latest previous close for the given instruments
+func (s *QuoteService) GetPrevCloses(instruments []string) (map[string]models.PrevCloseModel, error) {
+	prevCloses, err := s.prevCloseRepo.GetLatestPrevCloses(instruments)
+	if err != nil {
+		return nil, err
+	}
+
+	prevCloseMap := make(map[string]models.PrevCloseModel, len(prevCloses))
+	for _, prevClose := range prevCloses {
+		prevCloseMap[prevClose.Instrument] = prevClose
+	}
+	return prevCloseMap, nil
+}
+
+// GetPriceBands gets the price bands of the given instruments
+func (s *QuoteService) GetPriceBands(instruments []string) (map[string]models.PriceBandModel, error) {
+	bands, err := s.bandRepo.GetPriceBands(instruments)
+	if err != nil {
+		return nil, err
+	}
+
+	bandMap := make(map[string]models.PriceBandModel, len(bands))
+	for _, band := range bands {
+		bandMap[band.Instrument] = band
+	}
+	return bandMap, nil
+}
+
+// delayedCandleLookback is how far back the last candle of a delayed quote is looked for, covering weekends and holidays
+const delayedCandleLookback = 7 * 24 * time.Hour
+
+// GetPublicQuoteDelay returns the delay of the public quotes
+func GetPublicQuoteDelay() time.Duration {
+	cfg, err := config.Get()
+	if err != nil {
+		return 15 * time.Minute
+	}
+	// validated when the config is loaded
+	minutes, _ := strconv.Atoi(cfg.PublicQuoteDelayMinutes)
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetDelayedCandles returns the day candle of each instrument as it was the delay ago, keyed by instrument.
+// It aggregates the 1 minute candles completed by then on the day of the last of them, the close is the
+// close of the last one. Blacklisted instruments have none
+func (s *QuoteService) GetDelayedCandles(instruments []string, delay time.Duration) (map[string]models.CandleModel, error) {
+	instruments, _ = GetInstrumentBlacklist().FilterSymbols(instruments)
+	// a candle is complete a minute after its timestamp
+	upTo := MarketNow().Add(-delay).Truncate(time.Minute).Add(-time.Minute)
+	lastCandles, err := s.candleRepo.GetLastCandles(instruments, upTo.Add(-delayedCandleLookback), upTo)
+	if err != nil {
+		return nil, err
+	}
+
+	// the instruments are aggregated per day of their last candle, usually all on the same day
+	days := make(map[time.Time][]string)
+	for _, candle := range lastCandles {
+		at := candle.Timestamp.In(MarketLocation)
+		day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, MarketLocation)
+		days[day] = append(days[day], candle.Instrument)
+	}
+
+	candleMap := make(map[string]models.CandleModel, len(lastCandles))
+	for day, dayInstruments := range days {
+		candles, err := s.candleRepo.GetDayCandles(dayInstruments, day, upTo)
+		if err != nil {
+			return nil, err
+		}
+		for _, candle := range candles {
+			candleMap[candle.Instrument] = candle
+		}
+	}
+	return candleMap, nil
+}
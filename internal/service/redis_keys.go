@@ -0,0 +1,182 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Names of the redis keys and channels before the prefix
+const (
+	redisCloseKeyName = "API:TICKER:CLOSE"
+	redisChannelName  = "CH:API:TICKER:DATA"
+)
+
+// defaultRedisCloseTTL is used when the config can not be loaded
+const defaultRedisCloseTTL = 36 * time.Hour
+
+// redisKeysScanLimit is the maximum number of keys a report scans, SCAN is used so redis is never blocked
+const redisKeysScanLimit = 10000
+
+// redisKeyPrefix is prepended to every redis key and channel written by the services
+var redisKeyPrefix string
+
+// SetRedisKeyPrefix sets the prefix of the redis keys and channels, e.g. "staging:", so environments sharing
+// a redis do not collide. It must be called before the services are created
+func SetRedisKeyPrefix(prefix string) {
+	redisKeyPrefix = prefix
+	RedisCloseKey = prefix + redisCloseKeyName
+	RedisChannel = prefix + redisChannelName
+}
+
+// RedisKeyPrefix returns the prefix of the redis keys and channels
+func RedisKeyPrefix() string {
+	return redisKeyPrefix
+}
+
+// redisCloseTTL returns how long the primed previous closes live
+func redisCloseTTL() time.Duration {
+	cfg, err := config.Get()
+	if err != nil {
+		return defaultRedisCloseTTL
+	}
+	// validated when the config is loaded
+	hours, _ := strconv.Atoi(cfg.RedisCloseTTLHours)
+	return time.Duration(hours) * time.Hour
+}
+
+// RedisKeyPolicies returns the lifecycle of every redis key and channel the services write. Keys are written
+// together with their TTL in one transaction so a key never outlives its policy
+func RedisKeyPolicies() []models.RedisKeyPolicy {
+	return []models.RedisKeyPolicy{
+		{
+			Name:        "ticker_closes",
+			Key:         RedisCloseKey,
+			Type:        "hash",
+			TTLSeconds:  int64(redisCloseTTL().Seconds()),
+			Description: "last close of each instrument, replaced by the market warmup every morning",
+		},
+		{
+			Name:        "ticker_data_channel",
+			Key:         RedisChannel,
+			Type:        "channel",
+			Description: "pub/sub channel of the ticks, holds no data",
+		},
+	}
+}
+
+// RedisKeyService is the service for the redis key lifecycle
+type RedisKeyService struct {
+	redisClient redis.UniversalClient
+}
+
+// NewRedisKeyService creates a new redis key service
+func NewRedisKeyService(redisClient redis.UniversalClient) *RedisKeyService {
+	return &RedisKeyService{redisClient: redisClient}
+}
+
+// GetRedisKeysReport scans the keys under the key prefix and reports their type, TTL and policy. Keys without
+// a policy are unmanaged, keys of a policy with a TTL but without expiry are missing their TTL and are given it
+// when expire is set
+func (s *RedisKeyService) GetRedisKeysReport(ctx context.Context, expire bool) (models.RedisKeysReport, error) {
+	report := models.RedisKeysReport{
+		Prefix:   redisKeyPrefix,
+		Policies: RedisKeyPolicies(),
+		Keys:     make([]models.RedisKeyInfo, 0),
+	}
+	policies := make(map[string]models.RedisKeyPolicy, len(report.Policies))
+	for _, policy := range report.Policies {
+		policies[policy.Key] = policy
+	}
+
+	keys, truncated, err := s.scanKeys(ctx, redisKeyPrefix+"*")
+	if err != nil {
+		return report, err
+	}
+	report.Truncated = truncated
+	if len(keys) == 0 {
+		return report, nil
+	}
+
+	pipe := s.redisClient.Pipeline()
+	typeCmds := make([]*redis.StatusCmd, len(keys))
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		typeCmds[i] = pipe.Type(ctx, key)
+		ttlCmds[i] = pipe.TTL(ctx, key)
+	}
+	// a key expiring between the scan and the pipeline is reported as type none
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return report, fmt.Errorf("failed to get redis key types: %v", err)
+	}
+
+	for i, key := range keys {
+		info := models.RedisKeyInfo{
+			Key:        key,
+			Type:       typeCmds[i].Val(),
+			TTLSeconds: -1,
+		}
+		if ttl := ttlCmds[i].Val(); ttl > 0 {
+			info.TTLSeconds = int64(ttl.Seconds())
+		}
+		if policy, ok := policies[key]; ok {
+			info.Policy = policy.Name
+			if policy.TTLSeconds > 0 && info.TTLSeconds < 0 {
+				info.MissingTTL = true
+				report.MissingTTL++
+				if expire {
+					if err := s.redisClient.Expire(ctx, key, time.Duration(policy.TTLSeconds)*time.Second).Err(); err != nil {
+						return report, fmt.Errorf("failed to expire redis key %s: %v", key, err)
+					}
+					info.TTLSeconds = policy.TTLSeconds
+					report.Expired++
+				}
+			}
+		} else {
+			report.Unmanaged++
+		}
+		report.Keys = append(report.Keys, info)
+	}
+	return report, nil
+}
+
+// scanKeys returns the keys matching the pattern, on every master of a cluster, up to the scan limit
+func (s *RedisKeyService) scanKeys(ctx context.Context, match string) ([]string, bool, error) {
+	var mu sync.Mutex
+	keys := make([]string, 0)
+	truncated := false
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, match, 1000).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			if len(keys) >= redisKeysScanLimit {
+				truncated = true
+				mu.Unlock()
+				return nil
+			}
+			keys = append(keys, iter.Val())
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if clusterClient, ok := s.redisClient.(*redis.ClusterClient); ok {
+		err = clusterClient.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client)
+		})
+	} else {
+		err = scan(ctx, s.redisClient)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to scan redis keys: %v", err)
+	}
+	return keys, truncated, nil
+}
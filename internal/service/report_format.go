@@ -0,0 +1,86 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// reportFormat is the display time zone and number format a report or notification is rendered with
+type reportFormat struct {
+	location     *time.Location
+	numberFormat string
+}
+
+// reportRenderer renders the message of a report in the format of its recipient
+type reportRenderer func(f reportFormat) string
+
+// newReportFormat returns the format of the user settings, the market time zone and plain numbers by default
+func newReportFormat(settings models.UserSettings) reportFormat {
+	f := reportFormat{location: MarketLocation, numberFormat: settings.NumberFormat}
+	if settings.Timezone != "" {
+		// validated when the settings are saved
+		if location, err := time.LoadLocation(settings.Timezone); err == nil {
+			f.location = location
+		}
+	}
+	return f
+}
+
+// Int formats an integer
+func (f reportFormat) Int(value int64) string {
+	return f.group(strconv.FormatInt(value, 10))
+}
+
+// Float formats a number with the decimals
+func (f reportFormat) Float(value float64, decimals int) string {
+	return f.group(strconv.FormatFloat(value, 'f', decimals, 64))
+}
+
+// Decimal formats a price
+func (f reportFormat) Decimal(value decimal.Decimal) string {
+	return f.group(value.String())
+}
+
+// Time formats a time in the time zone of the format, with the zone abbreviation
+func (f reportFormat) Time(t time.Time) string {
+	return t.In(f.location).Format("15:04:05 MST")
+}
+
+// group separates the thousands of the integer part of a formatted number, in lakhs and crores for the
+// indian format
+func (f reportFormat) group(number string) string {
+	if f.numberFormat != models.NumberFormatIndian && f.numberFormat != models.NumberFormatInternational {
+		return number
+	}
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(number, ".")
+	if len(integer) <= 3 {
+		return sign + number
+	}
+
+	// the last three digits form the first group, the indian format groups the rest in pairs
+	head, groups := integer[:len(integer)-3], []string{integer[len(integer)-3:]}
+	size := 3
+	if f.numberFormat == models.NumberFormatIndian {
+		size = 2
+	}
+	for len(head) > size {
+		groups = append([]string{head[len(head)-size:]}, groups...)
+		head = head[:len(head)-size]
+	}
+	groups = append([]string{head}, groups...)
+
+	grouped := sign + strings.Join(groups, ",")
+	if hasFraction {
+		grouped += "." + fraction
+	}
+	return grouped
+}
@@ -0,0 +1,195 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"gorm.io/gorm"
+)
+
+// ErrRiskLimitBreached is returned for orders which would breach a limit of the user
+var ErrRiskLimitBreached = errors.New("risk limit breached")
+
+// RiskService enforces the per user order and loss limits, the order subsystem calls AuthorizeOrder before
+// forwarding an order to kite and RecordPnL as trades are realised
+type RiskService struct {
+	repo     *repository.RiskRepository
+	notifier *NotifierService
+}
+
+// NewRiskService creates a new risk service
+func NewRiskService(db *gorm.DB) *RiskService {
+	return &RiskService{
+		repo:     repository.NewRiskRepository(db),
+		notifier: NewNotifierService(db),
+	}
+}
+
+// ValidateRiskLimits checks that the limits are not negative
+func ValidateRiskLimits(limits models.RiskLimitsModel) error {
+	if limits.MaxOrdersPerDay < 0 {
+		return fmt.Errorf("invalid `max_orders_per_day` %d, must not be negative", limits.MaxOrdersPerDay)
+	}
+	if limits.MaxQuantityPerInstrument < 0 {
+		return fmt.Errorf("invalid `max_quantity_per_instrument` %d, must not be negative", limits.MaxQuantityPerInstrument)
+	}
+	if limits.MaxDailyLoss.Sign() < 0 {
+		return fmt.Errorf("invalid `max_daily_loss` %s, must not be negative", limits.MaxDailyLoss)
+	}
+	return nil
+}
+
+// GetLimits gets the limits of the user, a user without limits gets unlimited limits
+func (s *RiskService) GetLimits(userID string) (models.RiskLimitsModel, error) {
+	limits, err := s.repo.GetRiskLimits(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.RiskLimitsModel{UserID: userID}, nil
+		}
+		return models.RiskLimitsModel{}, err
+	}
+	return *limits, nil
+}
+
+// SaveLimits replaces the limits of the user
+func (s *RiskService) SaveLimits(userID string, limits models.RiskLimitsModel) (models.RiskLimitsModel, error) {
+	limits.UserID = userID
+	if err := s.repo.UpsertRiskLimits(&limits); err != nil {
+		return limits, err
+	}
+	return limits, nil
+}
+
+// DeleteLimits removes the limits of the user
+func (s *RiskService) DeleteLimits(userID string) (int64, error) {
+	return s.repo.DeleteRiskLimits(userID)
+}
+
+// AuthorizeOrder checks an order of quantity in the instrument against the kill switch and the limits of the
+// user and counts it towards the day's usage when it passes. A breach is notified as an alert and returned
+// wrapping ErrRiskLimitBreached
+func (s *RiskService) AuthorizeOrder(userID, instrument string, quantity int64) error {
+	if err := GetKillSwitch().Check(userID); err != nil {
+		return err
+	}
+	limits, err := s.GetLimits(userID)
+	if err != nil {
+		return err
+	}
+
+	usage := models.RiskUsageModel{UserID: userID, Date: MarketToday(), Instrument: instrument, Orders: 1, Quantity: quantity}
+	var breach string
+	err = s.repo.ReserveRiskUsage(usage, func(current []models.RiskUsageModel) error {
+		breach = orderBreach(limits, current, instrument, quantity)
+		if breach != "" {
+			return ErrRiskLimitBreached
+		}
+		return nil
+	})
+	if errors.Is(err, ErrRiskLimitBreached) {
+		s.notifier.NotifyUserReport(userID, models.NotificationCategoryAlerts, "Order rejected by risk limits", func(f reportFormat) string {
+			return fmt.Sprintf("An order for %s %s was rejected: %s", f.Int(int64(quantity)), instrument, breach)
+		})
+		return fmt.Errorf("%w: %s", ErrRiskLimitBreached, breach)
+	}
+	return err
+}
+
+// orderBreach returns the limit an order would breach given the day's usage, blank if it breaches none
+func orderBreach(limits models.RiskLimitsModel, current []models.RiskUsageModel, instrument string, quantity int64) string {
+	state := summariseRiskUsage(current)
+	if lossLimitReached(limits, state.PnL.Neg()) {
+		return fmt.Sprintf("daily loss limit %s reached", limits.MaxDailyLoss)
+	}
+	if limits.MaxOrdersPerDay > 0 && state.Orders >= limits.MaxOrdersPerDay {
+		return fmt.Sprintf("daily order limit %d reached", limits.MaxOrdersPerDay)
+	}
+	if limits.MaxQuantityPerInstrument > 0 {
+		used := int64(0)
+		for _, usage := range current {
+			if usage.Instrument == instrument {
+				used = usage.Quantity
+			}
+		}
+		if used+quantity > limits.MaxQuantityPerInstrument {
+			return fmt.Sprintf("quantity limit %d of %s exceeded", limits.MaxQuantityPerInstrument, instrument)
+		}
+	}
+	return ""
+}
+
+// RecordPnL adds realised P&L of the instrument to the user's day, crossing the daily loss limit is notified as an alert
+func (s *RiskService) RecordPnL(userID, instrument string, pnl decimal.Decimal) error {
+	limits, err := s.GetLimits(userID)
+	if err != nil {
+		return err
+	}
+	today := MarketToday()
+	before, err := s.repo.GetRiskUsage(userID, today)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.AddRiskUsage(models.RiskUsageModel{UserID: userID, Date: today, Instrument: instrument, PnL: pnl}); err != nil {
+		return err
+	}
+
+	lossBefore := summariseRiskUsage(before).PnL.Neg()
+	loss := lossBefore.Sub(pnl)
+	if lossLimitReached(limits, loss) && !lossLimitReached(limits, lossBefore) {
+		s.notifier.NotifyUserReport(userID, models.NotificationCategoryAlerts, "Daily loss limit reached", func(f reportFormat) string {
+			return fmt.Sprintf("The loss of the day %s has reached the limit of %s, new orders are rejected",
+				f.Decimal(loss.Round(2)), f.Decimal(limits.MaxDailyLoss.Round(2)))
+		})
+	}
+	return nil
+}
+
+// GetState returns the limits of the user with the usage of the day and the limits currently breached
+func (s *RiskService) GetState(userID string) (models.RiskState, error) {
+	limits, err := s.GetLimits(userID)
+	if err != nil {
+		return models.RiskState{}, err
+	}
+	usage, err := s.repo.GetRiskUsage(userID, MarketToday())
+	if err != nil {
+		return models.RiskState{}, err
+	}
+
+	state := summariseRiskUsage(usage)
+	state.Date = MarketToday()
+	state.Limits = limits
+	if lossLimitReached(limits, state.PnL.Neg()) {
+		state.Breaches = append(state.Breaches, "max_daily_loss")
+	}
+	if limits.MaxOrdersPerDay > 0 && state.Orders >= limits.MaxOrdersPerDay {
+		state.Breaches = append(state.Breaches, "max_orders_per_day")
+	}
+	for _, instrumentUsage := range usage {
+		if limits.MaxQuantityPerInstrument > 0 && instrumentUsage.Quantity >= limits.MaxQuantityPerInstrument {
+			state.Breaches = append(state.Breaches, "max_quantity_per_instrument:"+instrumentUsage.Instrument)
+		}
+	}
+	return state, nil
+}
+
+// summariseRiskUsage totals the usage of a day
+func summariseRiskUsage(usage []models.RiskUsageModel) models.RiskState {
+	state := models.RiskState{Instruments: usage, Breaches: make([]string, 0)}
+	if state.Instruments == nil {
+		state.Instruments = make([]models.RiskUsageModel, 0)
+	}
+	for _, instrumentUsage := range usage {
+		state.Orders += instrumentUsage.Orders
+		state.PnL = state.PnL.Add(instrumentUsage.PnL)
+	}
+	return state
+}
+
+// lossLimitReached reports whether the loss of the day has reached the daily loss limit, a zero limit is never reached
+func lossLimitReached(limits models.RiskLimitsModel, loss decimal.Decimal) bool {
+	return limits.MaxDailyLoss.Sign() > 0 && !loss.LessThan(limits.MaxDailyLoss)
+}
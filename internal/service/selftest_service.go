@@ -0,0 +1,188 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/response"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+	"gorm.io/gorm"
+)
+
+// selfTestRemoteAddr is the address the self test requests come from
+const selfTestRemoteAddr = "127.0.0.1:0"
+
+// selfTestCheckTimeout is how long a check other than the stream may take
+const selfTestCheckTimeout = 30 * time.Second
+
+// SelfTestService runs the self test of the critical endpoints, session validation, an instrument query, a quote
+// and a short stream subscription. The requests are served by the routes of the running instance in process, so
+// they go through the same middlewares as the requests of the users
+type SelfTestService struct {
+	e              *echo.Echo
+	sessionService *SessionService
+	notifier       *NotifierService
+}
+
+// NewSelfTestService creates a new self test service
+func NewSelfTestService(e *echo.Echo, db *gorm.DB) *SelfTestService {
+	return &SelfTestService{
+		e:              e,
+		sessionService: NewSessionService(db),
+		notifier:       NewNotifierService(db),
+	}
+}
+
+// selfTestSettings returns the base path, the instrument and the stream duration of the self test, the defaults
+// when the config can not be loaded
+func selfTestSettings() (string, string, time.Duration) {
+	cfg, err := config.Get()
+	if err != nil {
+		return "", "NSE:INFY", 3 * time.Second
+	}
+	// validated when the config is loaded
+	seconds, _ := strconv.Atoi(cfg.SelfTestStreamSeconds)
+	return cfg.BasePath, cfg.SelfTestInstrument, time.Duration(seconds) * time.Second
+}
+
+// RunStartup runs the self test with the stored session of the ticker user, failures are logged and sent to the
+// users subscribed to the alerts
+func (s *SelfTestService) RunStartup(userId string) {
+	session, err := s.sessionService.GetSession(userId)
+	if err != nil {
+		zaplogger.Error("Self test skipped", zaplogger.Fields{"user_id": userId, "error": err.Error()})
+		return
+	}
+	s.Run(session.UserId, session.Enctoken)
+}
+
+// Run runs the checks with the session of the user and returns the report, a failed run is logged and sent to
+// the users subscribed to the alerts
+func (s *SelfTestService) Run(userId, enctoken string) models.SelfTestReport {
+	basePath, instrument, streamDuration := selfTestSettings()
+	exchange, tradingsymbol, _ := strings.Cut(instrument, ":")
+
+	report := models.SelfTestReport{UserId: userId, Passed: true, StartedAt: time.Now()}
+	sessionForm := url.Values{"enctoken": {enctoken}}
+	instrumentQuery := url.Values{"exchange": {exchange}, "tradingsymbol": {tradingsymbol}}
+	quoteQuery := url.Values{"i": {instrument}}
+	streamBody, _ := json.Marshal(map[string]interface{}{"instruments": []string{instrument}})
+
+	checks := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		timeout     time.Duration
+	}{
+		{"session", http.MethodPost, "/session/valid", echo.MIMEApplicationForm, sessionForm.Encode(), selfTestCheckTimeout},
+		{"instrument_query", http.MethodGet, "/instruments/query?" + instrumentQuery.Encode(), "", "", selfTestCheckTimeout},
+		{"quote", http.MethodGet, "/quote/ltp?" + quoteQuery.Encode(), "", "", selfTestCheckTimeout},
+		// the stream runs until the timeout ends the subscription
+		{"stream", http.MethodPost, "/stream/ticks", echo.MIMEApplicationJSON, string(streamBody), streamDuration},
+	}
+	for _, check := range checks {
+		result, body := s.runCheck(userId, enctoken, check.name, check.method, basePath+check.path, check.contentType, check.body, check.timeout)
+		// the session check answers whether the enctoken is valid
+		if result.Name == "session" && result.Passed && !enctokenValid(body) {
+			result.Passed = false
+			result.Error = "enctoken is not valid"
+		}
+		// the stream answers 200 before any tick, it passes once a tick was streamed
+		if result.Name == "stream" && result.Passed && streamTickEvents(body) == 0 {
+			result.Passed = false
+			result.Error = "no tick streamed within " + streamDuration.String()
+		}
+		report.Checks = append(report.Checks, result)
+		report.Passed = report.Passed && result.Passed
+	}
+	report.FinishedAt = time.Now()
+
+	fields := zaplogger.Fields{"user_id": userId, "duration_ms": report.FinishedAt.Sub(report.StartedAt).Milliseconds()}
+	if report.Passed {
+		zaplogger.Info("Self test passed", fields)
+		return report
+	}
+	failed := make([]string, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		if !check.Passed {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Error))
+		}
+	}
+	fields["failed"] = failed
+	zaplogger.Error("Self test failed", fields)
+	s.notifier.Notify(models.NotificationCategoryAlerts, "Self test failed", strings.Join(failed, "\n"))
+	return report
+}
+
+// runCheck serves the request with the routes of the running instance, the check passes on a 200 response. It
+// returns the check and the response body for the checks of the content
+func (s *SelfTestService) runCheck(userId, enctoken, name, method, path, contentType, body string, timeout time.Duration) (models.SelfTestCheck, []byte) {
+	check := models.SelfTestCheck{Name: name, Method: method, Path: path}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(ctx)
+	req.RemoteAddr = selfTestRemoteAddr
+	req.Header.Set(echo.HeaderAuthorization, userId+":"+enctoken)
+	if contentType != "" {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	s.e.ServeHTTP(rec, req)
+	check.DurationMs = time.Since(start).Milliseconds()
+	check.Status = rec.Code
+	check.Passed = rec.Code == http.StatusOK
+	if !check.Passed {
+		var errorResponse response.Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &errorResponse); err == nil && errorResponse.Message != "" {
+			check.Error = errorResponse.Message
+		} else {
+			check.Error = http.StatusText(rec.Code)
+		}
+	}
+	return check, rec.Body.Bytes()
+}
+
+// streamTickEvents returns the number of tick events in the body of a stream, the events without a name carry
+// a tick as do the snapshot and delta events of the delta mode
+func streamTickEvents(body []byte) int {
+	ticks := 0
+	for _, message := range strings.Split(string(body), "\n\n") {
+		event, hasData := "", false
+		for _, line := range strings.Split(message, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			case strings.HasPrefix(line, "data:"):
+				hasData = true
+			}
+		}
+		if hasData && (event == "" || event == "snapshot" || event == "delta") {
+			ticks++
+		}
+	}
+	return ticks
+}
+
+// enctokenValid returns true if the body is the response of a valid enctoken
+func enctokenValid(body []byte) bool {
+	var validResponse struct {
+		Data bool `json:"data"`
+	}
+	return json.Unmarshal(body, &validResponse) == nil && validResponse.Data
+}
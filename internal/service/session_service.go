@@ -2,7 +2,10 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	kitesession "github.com/nsvirk/gokitesession"
 	"github.com/nsvirk/moneybotsapi/internal/models"
@@ -11,6 +14,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// kiteSessionExpiryHour is the hour of the market day kite expires the sessions of the previous day at
+const kiteSessionExpiryHour = 6
+
+// ErrSessionMismatch is returned when the user has no stored session or the enctoken is not the stored one
+var ErrSessionMismatch = errors.New("`enctoken` is invalid for the user")
+
 type SessionService struct {
 	repo        *repository.SessionRepository
 	kiteSession *kitesession.Client
@@ -71,6 +80,115 @@ func (s *SessionService) GenerateTOTP(totpSecret string) (string, error) {
 	return kitesession.GenerateTOTPValue(totpSecret)
 }
 
+// GetSession returns the stored session of the given user
+func (s *SessionService) GetSession(userId string) (*models.SessionModel, error) {
+	return s.repo.GetSessionByUserId(userId)
+}
+
+// PingSessions checks every stored enctoken with kite and records the sessions whose validity changed,
+// or which were regenerated, since the last check. It returns the recorded transitions, sessions that
+// could not be checked are skipped
+func (s *SessionService) PingSessions() ([]models.SessionCheckModel, error) {
+	sessions, err := s.repo.GetAllSessions()
+	if err != nil {
+		return nil, err
+	}
+	lastChecks, err := s.repo.GetLastSessionChecks()
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]models.SessionCheckModel, len(lastChecks))
+	for _, check := range lastChecks {
+		last[check.UserId] = check
+	}
+
+	transitions := make([]models.SessionCheckModel, 0)
+	var errs []string
+	for _, session := range sessions {
+		valid, err := s.kiteSession.CheckEnctokenValid(session.Enctoken)
+		// a failed request says nothing about the session
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", session.UserId, err))
+			continue
+		}
+		previous, ok := last[session.UserId]
+		if ok && previous.Valid == valid && previous.LoginTime == session.LoginTime {
+			continue
+		}
+		check := models.SessionCheckModel{
+			UserId:    session.UserId,
+			Valid:     valid,
+			LoginTime: session.LoginTime,
+			CheckedAt: time.Now(),
+		}
+		if err := s.repo.InsertSessionCheck(&check); err != nil {
+			return transitions, err
+		}
+		transitions = append(transitions, check)
+	}
+	if len(errs) > 0 {
+		return transitions, fmt.Errorf("failed to check sessions: %s", strings.Join(errs, "; "))
+	}
+	return transitions, nil
+}
+
+// GetSessionInfo returns the login time of the user's stored session, its estimated expiry and whether kite
+// still accepts it. The expiry is an estimate, kite expires the sessions at about 6am of the next market day
+func (s *SessionService) GetSessionInfo(userId, enctoken string) (models.SessionInfo, error) {
+	var info models.SessionInfo
+	session, err := s.repo.GetSessionByUserId(userId)
+	if err != nil {
+		// an unknown user is not told apart from a wrong enctoken
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return info, ErrSessionMismatch
+		}
+		return info, err
+	}
+	if enctoken != session.Enctoken {
+		return info, ErrSessionMismatch
+	}
+
+	now := time.Now()
+	info = models.SessionInfo{
+		UserId:    session.UserId,
+		LoginTime: session.LoginTime,
+		ExpiresAt: estimateSessionExpiry(session.LoginTime, session.UpdatedAt),
+		CheckedAt: now,
+	}
+	if remaining := info.ExpiresAt.Sub(now); remaining > 0 {
+		info.ExpiresInSeconds = int64(remaining.Seconds())
+	}
+	// a failed request says nothing about the session
+	valid, err := s.kiteSession.CheckEnctokenValid(enctoken)
+	if err != nil {
+		info.CheckError = err.Error()
+	} else {
+		info.Valid = &valid
+	}
+	return info, nil
+}
+
+// estimateSessionExpiry returns the first expiry hour in the market time zone after the login, the login
+// time is in the local time of the server that generated the session, the time the session was stored
+// stands in when it cannot be parsed
+func estimateSessionExpiry(loginTime string, storedAt time.Time) time.Time {
+	loginAt, err := time.ParseInLocation("2006-01-02 15:04:05", loginTime, time.Local)
+	if err != nil {
+		loginAt = storedAt
+	}
+	loginAt = loginAt.In(MarketLocation)
+	expiry := time.Date(loginAt.Year(), loginAt.Month(), loginAt.Day(), kiteSessionExpiryHour, 0, 0, 0, MarketLocation)
+	if !expiry.After(loginAt) {
+		expiry = expiry.AddDate(0, 0, 1)
+	}
+	return expiry
+}
+
+// GetSessionChecks returns the recorded validity transitions of the user's session, newest first
+func (s *SessionService) GetSessionChecks(userId string, limit int) ([]models.SessionCheckModel, error) {
+	return s.repo.GetSessionChecks(userId, limit)
+}
+
 // DeleteSession deletes the session for the given user
 func (s *SessionService) DeleteSession(userId, enctoken string) (int64, error) {
 	return s.repo.DeleteSession(userId, enctoken)
@@ -0,0 +1,119 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// StorageService is the service for the storage usage and capacity planning
+type StorageService struct {
+	repo *repository.StorageRepository
+}
+
+// NewStorageService creates a new storage service
+func NewStorageService(db *gorm.DB) *StorageService {
+	return &StorageService{repo: repository.NewStorageRepository(db)}
+}
+
+// SnapshotStorage records today's table sizes, growth rates are computed from these snapshots
+func (s *StorageService) SnapshotStorage() ([]models.StorageTable, error) {
+	tables, err := s.repo.GetTableSizes()
+	if err != nil {
+		return nil, err
+	}
+	today := MarketToday()
+	snapshots := make([]models.StorageSnapshotModel, 0, len(tables))
+	for _, table := range tables {
+		snapshots = append(snapshots, models.StorageSnapshotModel{
+			Date:       today,
+			Table:      table.Table,
+			TableBytes: table.TableBytes,
+			IndexBytes: table.IndexBytes,
+			TotalBytes: table.TotalBytes,
+			Rows:       table.Rows,
+		})
+	}
+	return tables, s.repo.UpsertStorageSnapshots(snapshots)
+}
+
+// GetStorageReport returns the table sizes, their daily growth over the growth window and the projected
+// days until the disk usage thresholds are reached
+func (s *StorageService) GetStorageReport() (models.StorageReport, error) {
+	report := models.StorageReport{
+		Tables:     make([]models.StorageTable, 0),
+		Thresholds: make([]models.StorageThreshold, 0),
+	}
+	cfg, err := config.Get()
+	if err != nil {
+		return report, err
+	}
+	// values are validated when the config is loaded
+	report.CapacityBytes, _ = strconv.ParseInt(cfg.StorageCapacityBytes, 10, 64)
+	windowDays, _ := strconv.Atoi(cfg.StorageGrowthWindowDays)
+	percents, _ := config.ParsePercents(cfg.StorageThresholdPercent)
+
+	tables, err := s.SnapshotStorage()
+	if err != nil {
+		return report, err
+	}
+	report.DatabaseBytes, err = s.repo.GetDatabaseSize()
+	if err != nil {
+		return report, err
+	}
+
+	// growth is measured from the oldest snapshot in the window
+	today, _ := time.ParseInLocation("2006-01-02", MarketToday(), MarketLocation)
+	since := today.AddDate(0, 0, -windowDays).Format("2006-01-02")
+	oldestDate, err := s.repo.GetOldestStorageSnapshotDate(since)
+	if err != nil {
+		return report, err
+	}
+	oldestTotals := make(map[string]int64)
+	if oldest, err := time.ParseInLocation("2006-01-02", oldestDate, MarketLocation); err == nil {
+		report.GrowthWindowDays = today.Sub(oldest).Hours() / 24
+		snapshots, err := s.repo.GetStorageSnapshots(oldestDate)
+		if err != nil {
+			return report, err
+		}
+		for _, snapshot := range snapshots {
+			oldestTotals[snapshot.Table] = snapshot.TotalBytes
+		}
+	}
+
+	for _, table := range tables {
+		if old, ok := oldestTotals[table.Table]; ok && report.GrowthWindowDays > 0 {
+			table.DailyGrowthBytes = math.Round(float64(table.TotalBytes-old) / report.GrowthWindowDays)
+		}
+		report.DailyGrowthBytes += table.DailyGrowthBytes
+		report.Tables = append(report.Tables, table)
+	}
+
+	if report.CapacityBytes <= 0 {
+		return report, nil
+	}
+	for _, percent := range percents {
+		threshold := models.StorageThreshold{
+			Percent: percent,
+			Bytes:   report.CapacityBytes * int64(percent) / 100,
+		}
+		threshold.Reached = report.DatabaseBytes >= threshold.Bytes
+		if !threshold.Reached && report.DailyGrowthBytes > 0 {
+			days := math.Round(float64(threshold.Bytes-report.DatabaseBytes)/report.DailyGrowthBytes*10) / 10
+			threshold.DaysUntil = &days
+		}
+		report.Thresholds = append(report.Thresholds, threshold)
+	}
+	return report, nil
+}
+
+// GetDBMetrics returns the database connection pool and the statement counts, durations and error rates per table
+func (s *StorageService) GetDBMetrics() (models.DBMetrics, error) {
+	return s.repo.GetDBMetrics()
+}
@@ -0,0 +1,74 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+)
+
+// Stream modes, compact sends the price and volume, full adds the quantities, open interest, OHLC and depth
+const (
+	StreamModeCompact = "compact"
+	StreamModeFull    = "full"
+)
+
+// defaultStreamResnapshotInterval is how often a delta client gets a full snapshot of each instrument
+const defaultStreamResnapshotInterval = 60 * time.Second
+
+// StreamOptions are the options of a stream client
+type StreamOptions struct {
+	Mode string
+	// Deltas sends a snapshot of each instrument followed by only the fields changed since the last message
+	Deltas bool
+	// ResnapshotInterval is how often a delta client is sent a full snapshot again to resync
+	ResnapshotInterval time.Duration
+}
+
+// streamDeltaState is the last state sent to a delta client for an instrument
+type streamDeltaState struct {
+	fields         map[string]interface{}
+	lastSnapshotAt time.Time
+}
+
+// streamTickFields returns the fields of a tick sent on the stream for the mode,
+// all values are comparable so that changes can be detected with ==
+func streamTickFields(tick kiteticker.Tick, exchange, tradingsymbol, mode string) map[string]interface{} {
+	fields := map[string]interface{}{
+		"exchange":      exchange,
+		"tradingsymbol": tradingsymbol,
+		"last_price":    tick.LastPrice,
+		"volume":        tick.VolumeTraded,
+		"avg_price":     tick.AverageTradePrice,
+	}
+	if mode != StreamModeFull {
+		return fields
+	}
+
+	fields["timestamp"] = tick.Timestamp.Format("2006-01-02 15:04:05")
+	fields["last_trade_time"] = tick.LastTradeTime.Format("2006-01-02 15:04:05")
+	fields["last_traded_quantity"] = tick.LastTradedQuantity
+	fields["total_buy_quantity"] = tick.TotalBuyQuantity
+	fields["total_sell_quantity"] = tick.TotalSellQuantity
+	fields["oi"] = tick.OI
+	fields["oi_day_high"] = tick.OIDayHigh
+	fields["oi_day_low"] = tick.OIDayLow
+	fields["net_change"] = tick.NetChange
+	fields["ohlc"] = tick.OHLC
+	fields["depth"] = tick.Depth
+	return fields
+}
+
+// streamTickDelta returns the fields changed since the previous state, the instrument identity is always included
+func streamTickDelta(previous, current map[string]interface{}) map[string]interface{} {
+	delta := map[string]interface{}{
+		"exchange":      current["exchange"],
+		"tradingsymbol": current["tradingsymbol"],
+	}
+	for key, value := range current {
+		if previousValue, ok := previous[key]; !ok || previousValue != value {
+			delta[key] = value
+		}
+	}
+	return delta
+}
@@ -0,0 +1,85 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Codes of the error events sent to the stream clients, the affected tokens no longer stream until the client
+// resubscribes or the server recovers them
+const (
+	// StreamErrorSubscribeFailed is sent when the tokens could not be subscribed on the direct connection
+	StreamErrorSubscribeFailed = "subscribe_failed"
+	// StreamErrorTokenBudget is sent when the tokens could not be moved to the direct connection within the token budget
+	StreamErrorTokenBudget = "token_budget_exceeded"
+	// StreamErrorConnection is sent when the direct connection fails, e.g. the session token is no longer valid
+	StreamErrorConnection = "connection_error"
+	// StreamErrorConnectionLost is sent when the direct connection has given up reconnecting
+	StreamErrorConnectionLost = "connection_lost"
+	// StreamErrorBlacklisted is sent when the instruments were blacklisted, they are dropped from the client
+	StreamErrorBlacklisted = "instrument_blacklisted"
+)
+
+// errInstrumentBlacklisted is the error of the StreamErrorBlacklisted event
+var errInstrumentBlacklisted = errors.New("instrument blacklisted")
+
+// streamErrorMessage is the error event of a client, the tokens are those of the client the error affects
+func streamErrorMessage(code, message string, client *StreamClient, tokens []uint32) []byte {
+	instruments := make([]string, len(tokens))
+	for i, token := range tokens {
+		instruments[i] = client.TokenMap[token]
+	}
+	return streamMessage("error", map[string]interface{}{
+		"code":        code,
+		"message":     message,
+		"tokens":      tokens,
+		"instruments": instruments,
+		"timestamp":   time.Now().In(MarketLocation).Format("2006-01-02 15:04:05"),
+	})
+}
+
+// broadcastTokenError sends an error event to every client streaming one of the tokens, listing the tokens of
+// the client it affects. A client whose channel is full is disconnected with the event instead of losing it
+func (s *StreamService) broadcastTokenError(code string, err error, tokens []uint32) {
+	if len(tokens) == 0 {
+		return
+	}
+	affected := make(map[uint32]struct{}, len(tokens))
+	for _, token := range tokens {
+		affected[token] = struct{}{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, client := range s.clients {
+		var clientTokens []uint32
+		for token := range client.TokenMap {
+			if _, ok := affected[token]; ok {
+				clientTokens = append(clientTokens, token)
+			}
+		}
+		if len(clientTokens) == 0 {
+			continue
+		}
+		sort.Slice(clientTokens, func(i, j int) bool { return clientTokens[i] < clientTokens[j] })
+		message := streamErrorMessage(code, err.Error(), client, clientTokens)
+		select {
+		case client.Channel <- message:
+		default:
+			client.drop(message)
+		}
+	}
+}
+
+// directTokenList returns the tokens on the direct connection
+func (s *StreamService) directTokenList() []uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := make([]uint32, 0, len(s.directTokens))
+	for token := range s.directTokens {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
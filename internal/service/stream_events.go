@@ -0,0 +1,166 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// marketPhaseCheckInterval is how often the market phases are checked for changes
+const marketPhaseCheckInterval = 5 * time.Second
+
+// circuitLimitsRefreshInterval is how often the circuit limits of the streamed instruments are reloaded
+const circuitLimitsRefreshInterval = 5 * time.Minute
+
+// Circuit states inferred from the price bands
+const (
+	CircuitNone  = "none"
+	CircuitUpper = "upper"
+	CircuitLower = "lower"
+)
+
+// watchMarketPhases sends a market_phase event to the clients subscribed to an exchange when its phase changes
+func (s *StreamService) watchMarketPhases() {
+	phases := make(map[string]string)
+	ticker := time.NewTicker(marketPhaseCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := MarketNow()
+		for exchange := range tradingSessions {
+			phase := GetMarketPhase(exchange, now)
+			previousPhase, ok := phases[exchange]
+			phases[exchange] = phase
+			// the first check only records the current phases
+			if !ok || phase == previousPhase {
+				continue
+			}
+			s.broadcastExchangeEvent(exchange, streamMessage("market_phase", map[string]interface{}{
+				"exchange":       exchange,
+				"phase":          phase,
+				"previous_phase": previousPhase,
+				"timestamp":      now.In(MarketLocation).Format("2006-01-02 15:04:05"),
+			}))
+		}
+	}
+}
+
+// broadcastExchangeEvent sends the message to the clients with an instrument on the exchange
+func (s *StreamService) broadcastExchangeEvent(exchange string, data []byte) {
+	if data == nil {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, client := range s.clients {
+		for _, instrument := range client.TokenMap {
+			if strings.HasPrefix(instrument, exchange+":") {
+				select {
+				case client.Channel <- data:
+				default:
+				}
+				break
+			}
+		}
+	}
+}
+
+// circuitLimit is the lower and upper circuit limit of an instrument from its price band
+type circuitLimit struct {
+	lower decimal.Decimal
+	upper decimal.Decimal
+}
+
+// watchCircuitLimits reloads the circuit limits of the streamed instruments from their price bands, periodically
+// and when a client adds instruments
+func (s *StreamService) watchCircuitLimits() {
+	ticker := time.NewTicker(circuitLimitsRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.refreshCircuitLimits(); err != nil {
+			log.Printf("Error refreshing the stream circuit limits: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-s.circuitLimitsChan:
+		}
+	}
+}
+
+// refreshCircuitLimits replaces the circuit limits of the fan-out with the PriceBandLimits of the streamed
+// instruments, instruments without a band or a previous close have no limits
+func (s *StreamService) refreshCircuitLimits() error {
+	s.mu.RLock()
+	tokens := make(map[string]uint32, len(s.globalTokenMap))
+	instruments := make([]string, 0, len(s.globalTokenMap))
+	for token, instrument := range s.globalTokenMap {
+		tokens[instrument] = token
+		instruments = append(instruments, instrument)
+	}
+	s.mu.RUnlock()
+
+	limits := make(map[uint32]circuitLimit)
+	if len(instruments) > 0 {
+		bands, err := s.bandService.GetPriceBands(instruments)
+		if err != nil {
+			return err
+		}
+		for instrument, band := range bands {
+			if band.UpperCircuitLimit.Sign() > 0 {
+				limits[tokens[instrument]] = circuitLimit{lower: band.LowerCircuitLimit, upper: band.UpperCircuitLimit}
+			}
+		}
+	}
+	s.fanout.circuitLimits.Store(&limits)
+	return nil
+}
+
+// inferCircuit infers whether an instrument trades at a circuit limit of its price band, the last price
+// at or beyond the upper limit is an upper circuit and at or below the lower limit a lower circuit.
+// Instruments without a band, e.g. derivatives, have no circuit state
+func inferCircuit(tick kiteticker.Tick, limits map[uint32]circuitLimit) (string, bool) {
+	limit, ok := limits[tick.InstrumentToken]
+	if !ok || tick.IsIndex || tick.LastPrice <= 0 {
+		return "", false
+	}
+	price := decimal.NewFromFloat(tick.LastPrice)
+	switch {
+	case !price.LessThan(limit.upper):
+		return CircuitUpper, true
+	case !price.GreaterThan(limit.lower):
+		return CircuitLower, true
+	default:
+		return CircuitNone, true
+	}
+}
+
+// circuitMessage returns a circuit event when the inferred circuit state of the instrument changed, nil otherwise,
+// circuits is only touched by the shard's worker
+func (sh *streamShard) circuitMessage(tick kiteticker.Tick, exchange, tradingsymbol string) []byte {
+	var limits map[uint32]circuitLimit
+	if current := sh.limits.Load(); current != nil {
+		limits = *current
+	}
+	circuit, ok := inferCircuit(tick, limits)
+	if !ok {
+		return nil
+	}
+	previous, seen := sh.circuits[tick.InstrumentToken]
+	sh.circuits[tick.InstrumentToken] = circuit
+	if circuit == previous || (!seen && circuit == CircuitNone) {
+		return nil
+	}
+	return streamMessage("circuit", map[string]interface{}{
+		"exchange":      exchange,
+		"tradingsymbol": tradingsymbol,
+		"circuit":       circuit,
+		"last_price":    tick.LastPrice,
+		"timestamp":     tick.Timestamp.Format("2006-01-02 15:04:05"),
+	})
+}
@@ -0,0 +1,256 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+)
+
+// streamFanoutQueueSize is the number of ticks each fan-out worker buffers
+const streamFanoutQueueSize = 4096
+
+// streamGroup is the clients streaming the same tokens in the same mode, a tick is serialized once per group.
+// Delta clients keep their own group since each has its own delta state
+type streamGroup struct {
+	key     string
+	tokens  map[uint32]string
+	options StreamOptions
+	mu      sync.RWMutex
+	members map[string]*StreamClient
+}
+
+// streamGroupKey returns the subscription group key of a client, a hash of its sorted tokens and mode
+func streamGroupKey(client *StreamClient) string {
+	if client.Options.Deltas {
+		return "client:" + client.ID
+	}
+	tokens := make([]uint32, 0, len(client.TokenMap))
+	for token := range client.TokenMap {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+
+	var b strings.Builder
+	b.WriteString(client.Options.Mode)
+	for _, token := range tokens {
+		b.WriteByte(',')
+		b.WriteString(strconv.FormatUint(uint64(token), 10))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return client.Options.Mode + ":" + hex.EncodeToString(sum[:8])
+}
+
+// streamSubscriber is a group subscribed to a token of a fan-out shard
+type streamSubscriber struct {
+	group      *streamGroup
+	instrument string
+	// delta is the last state sent to a delta group, nil until the first snapshot
+	delta *streamDeltaState
+}
+
+// streamShard holds the subscribers of the tokens hashed to it and delivers their ticks on its own worker,
+// the ticks of a token always go through the same worker so they stay in order
+type streamShard struct {
+	mu          sync.RWMutex
+	subscribers map[uint32]map[string]*streamSubscriber
+	// circuits is the last inferred circuit of each token, only touched by the worker
+	circuits map[uint32]string
+	// limits are the circuit limits of the fan-out the circuits are inferred from
+	limits *atomic.Pointer[map[uint32]circuitLimit]
+	ticks  chan kiteticker.Tick
+}
+
+// streamFanout is the stream client registry sharded by token with a worker per shard,
+// a tick only locks the shard of its token instead of all the clients
+type streamFanout struct {
+	shards []*streamShard
+	wg     sync.WaitGroup
+	// groups are the subscription groups by key, guarded by groupsMu
+	groupsMu sync.Mutex
+	groups   map[string]*streamGroup
+	// circuitLimits are the circuit limits of the banded instruments by token, replaced as the bands are refreshed
+	circuitLimits atomic.Pointer[map[uint32]circuitLimit]
+}
+
+// newStreamFanout creates the shards and starts their workers, one per CPU when workers is not positive
+func newStreamFanout(workers int) *streamFanout {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	f := &streamFanout{
+		shards: make([]*streamShard, workers),
+		groups: make(map[string]*streamGroup),
+	}
+	for i := range f.shards {
+		shard := &streamShard{
+			subscribers: make(map[uint32]map[string]*streamSubscriber),
+			circuits:    make(map[uint32]string),
+			limits:      &f.circuitLimits,
+			ticks:       make(chan kiteticker.Tick, streamFanoutQueueSize),
+		}
+		f.shards[i] = shard
+		f.wg.Add(1)
+		recovery.Go(fmt.Sprintf("stream.fanout.%d", i), func() {
+			defer f.wg.Done()
+			shard.run()
+		})
+	}
+	return f
+}
+
+// shard returns the shard of the token
+func (f *streamFanout) shard(token uint32) *streamShard {
+	return f.shards[token%uint32(len(f.shards))]
+}
+
+// add joins the client to the subscription group of its tokens and mode, a new group is subscribed to the tokens
+func (f *streamFanout) add(client *StreamClient) {
+	f.groupsMu.Lock()
+	defer f.groupsMu.Unlock()
+
+	client.GroupKey = streamGroupKey(client)
+	if group, ok := f.groups[client.GroupKey]; ok {
+		group.mu.Lock()
+		group.members[client.ID] = client
+		group.mu.Unlock()
+		return
+	}
+
+	group := &streamGroup{
+		key:     client.GroupKey,
+		tokens:  client.TokenMap,
+		options: client.Options,
+		members: map[string]*StreamClient{client.ID: client},
+	}
+	f.groups[group.key] = group
+	for token, instrument := range group.tokens {
+		shard := f.shard(token)
+		shard.mu.Lock()
+		if shard.subscribers[token] == nil {
+			shard.subscribers[token] = make(map[string]*streamSubscriber)
+		}
+		shard.subscribers[token][group.key] = &streamSubscriber{group: group, instrument: instrument}
+		shard.mu.Unlock()
+	}
+}
+
+// remove takes the client out of its group and unsubscribes the group once it is empty,
+// no worker sends to the client's channel once it returns
+func (f *streamFanout) remove(client *StreamClient) {
+	f.groupsMu.Lock()
+	defer f.groupsMu.Unlock()
+
+	group, ok := f.groups[client.GroupKey]
+	if !ok {
+		return
+	}
+	group.mu.Lock()
+	delete(group.members, client.ID)
+	empty := len(group.members) == 0
+	group.mu.Unlock()
+	if !empty {
+		return
+	}
+
+	delete(f.groups, group.key)
+	for token := range group.tokens {
+		shard := f.shard(token)
+		shard.mu.Lock()
+		delete(shard.subscribers[token], group.key)
+		if len(shard.subscribers[token]) == 0 {
+			delete(shard.subscribers, token)
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// dispatch queues the tick on the worker of its token
+func (f *streamFanout) dispatch(tick kiteticker.Tick) {
+	f.shard(tick.InstrumentToken).ticks <- tick
+}
+
+// stop stops the workers once they delivered the queued ticks
+func (f *streamFanout) stop() {
+	for _, shard := range f.shards {
+		close(shard.ticks)
+	}
+	f.wg.Wait()
+}
+
+// run delivers the ticks queued on the shard until it is stopped
+func (sh *streamShard) run() {
+	for tick := range sh.ticks {
+		sh.deliver(tick)
+	}
+}
+
+// deliver sends the tick to the subscribers of its token
+func (sh *streamShard) deliver(tick kiteticker.Tick) {
+	defer recovery.Guard("stream.fanout.deliver")
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	subscribers := sh.subscribers[tick.InstrumentToken]
+	if len(subscribers) == 0 {
+		return
+	}
+
+	var exchange, tradingsymbol string
+	for _, subscriber := range subscribers {
+		exchange, tradingsymbol, _ = strings.Cut(subscriber.instrument, ":")
+		break
+	}
+
+	// messages are marshaled once per mode and shared by the clients without deltas
+	messages := make(map[string][]byte)
+	now := time.Now()
+	circuit := sh.circuitMessage(tick, exchange, tradingsymbol)
+
+	for _, subscriber := range subscribers {
+		group := subscriber.group
+		var data []byte
+		if group.options.Deltas {
+			data = subscriber.deltaMessage(tick, exchange, tradingsymbol, now)
+		} else {
+			var ok bool
+			data, ok = messages[group.options.Mode]
+			if !ok {
+				data = streamMessage("", streamTickFields(tick, exchange, tradingsymbol, group.options.Mode))
+				messages[group.options.Mode] = data
+			}
+		}
+
+		group.mu.RLock()
+		for _, client := range group.members {
+			if circuit != nil {
+				select {
+				case client.Channel <- circuit:
+				default:
+				}
+			}
+			if data == nil {
+				continue
+			}
+			select {
+			case client.Channel <- data:
+			default:
+				log.Printf("Skipping slow client: %s", client.ID)
+				// the client missed a message, resync it with a snapshot
+				subscriber.delta = nil
+			}
+		}
+		group.mu.RUnlock()
+	}
+}
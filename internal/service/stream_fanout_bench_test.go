@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// benchStreamClients is the number of stream clients subscribed to every instrument in the benchmarks
+const benchStreamClients = 10
+
+// BenchmarkFanoutDispatch measures delivering the ticks to the stream clients through the fan-out workers,
+// the clients drain their channels like the SSE writers do
+func BenchmarkFanoutDispatch(b *testing.B) {
+	for _, deltas := range []bool{false, true} {
+		b.Run(fmt.Sprintf("deltas=%t", deltas), func(b *testing.B) {
+			benchmarkFanoutDispatch(b, deltas)
+		})
+	}
+}
+
+func benchmarkFanoutDispatch(b *testing.B, deltas bool) {
+	// clients falling behind are logged per message
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	fanout := newStreamFanout(0)
+	tokens := make([]uint32, benchInstruments)
+	tokenMap := make(map[uint32]string, benchInstruments)
+	for i := range tokens {
+		tokens[i] = uint32(4000000000 + i)
+		tokenMap[tokens[i]] = fmt.Sprintf("BENCH:SYN%06d", i)
+	}
+
+	done := make(chan struct{})
+	channels := make([]chan []byte, benchStreamClients)
+	for i := range channels {
+		channels[i] = make(chan []byte, 1000)
+		fanout.add(&StreamClient{
+			ID:       fmt.Sprintf("bench-%d", i),
+			Tokens:   tokens,
+			TokenMap: tokenMap,
+			Channel:  channels[i],
+			Options:  StreamOptions{Mode: StreamModeCompact, Deltas: deltas, ResnapshotInterval: time.Minute},
+		})
+		go func(ch chan []byte) {
+			for range ch {
+			}
+			done <- struct{}{}
+		}(channels[i])
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fanout.dispatch(syntheticTick(tokens[i%benchInstruments], 100+float64(i%500)*0.05, i))
+	}
+	// the workers deliver the queued ticks before the clients stop draining
+	fanout.stop()
+	b.StopTimer()
+
+	for _, ch := range channels {
+		close(ch)
+		<-done
+	}
+}
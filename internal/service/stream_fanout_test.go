@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+)
+
+// fanoutPanics counts the panics recovered by the fan-out workers, e.g. a send on a closed client channel
+var fanoutPanics atomic.Int64
+
+func init() {
+	recovery.AddReporter(func(source string, recovered interface{}, stack []byte) {
+		fanoutPanics.Add(1)
+	})
+}
+
+// TestFanoutConcurrentClients adds and removes clients while ticks are dispatched, the clients close their
+// channels right after they are removed like the stream handler does. Run it with -race
+func TestFanoutConcurrentClients(t *testing.T) {
+	// clients falling behind are logged per message
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const (
+		instruments = 20
+		clients     = 16
+		rounds      = 20
+	)
+	panics := fanoutPanics.Load()
+	fanout := newStreamFanout(4)
+
+	tokens := make([]uint32, instruments)
+	tokenMap := make(map[uint32]string, instruments)
+	for i := range tokens {
+		tokens[i] = uint32(4000000000 + i)
+		tokenMap[tokens[i]] = fmt.Sprintf("TEST:SYN%06d", i)
+	}
+
+	stop := make(chan struct{})
+	var dispatchWG sync.WaitGroup
+	for d := 0; d < 2; d++ {
+		dispatchWG.Add(1)
+		go func(d int) {
+			defer dispatchWG.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				fanout.dispatch(syntheticTick(tokens[(i+d)%instruments], 100+float64(i%500)*0.05, i))
+				// a burst per instrument, the pause lets the clients come and go between the bursts
+				if i%instruments == 0 {
+					time.Sleep(100 * time.Microsecond)
+				}
+			}
+		}(d)
+	}
+
+	var clientWG sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		clientWG.Add(1)
+		go func(c int) {
+			defer clientWG.Done()
+			for r := 0; r < rounds; r++ {
+				// half the clients share the groups, the rest have groups of their own
+				clientTokens := tokenMap
+				if c%2 == 1 {
+					clientTokens = map[uint32]string{tokens[c%instruments]: tokenMap[tokens[c%instruments]], tokens[r%instruments]: tokenMap[tokens[r%instruments]]}
+				}
+				channel := make(chan []byte, 4)
+				client := &StreamClient{
+					ID:       fmt.Sprintf("test-%d-%d", c, r),
+					TokenMap: clientTokens,
+					Channel:  channel,
+					Options:  StreamOptions{Mode: StreamModeCompact, Deltas: c%4 == 3, ResnapshotInterval: time.Minute},
+				}
+				for token := range clientTokens {
+					client.Tokens = append(client.Tokens, token)
+				}
+				fanout.add(client)
+				for i := 0; i < 2; i++ {
+					select {
+					case <-channel:
+					case <-time.After(time.Millisecond):
+					}
+				}
+				fanout.remove(client)
+				close(channel)
+			}
+		}(c)
+	}
+
+	clientWG.Wait()
+	close(stop)
+	dispatchWG.Wait()
+	fanout.stop()
+
+	if got := fanoutPanics.Load() - panics; got != 0 {
+		t.Fatalf("fan-out workers recovered %d panics", got)
+	}
+	if len(fanout.groups) != 0 {
+		t.Errorf("fan-out kept %d groups after all clients were removed", len(fanout.groups))
+	}
+	for i, shard := range fanout.shards {
+		if len(shard.subscribers) != 0 {
+			t.Errorf("shard %d kept %d subscribed tokens after all clients were removed", i, len(shard.subscribers))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+// ErrStreamClientNotFound is returned when an ack names a stream client the user does not have
+var ErrStreamClientNotFound = errors.New("stream client not found")
+
+// maxStreamPingInterval caps the interval between pings for long ack timeouts
+const maxStreamPingInterval = 30 * time.Second
+
+// getStreamAckTimeout returns how long a client may go without acking, 0 when acks are not required
+func getStreamAckTimeout() time.Duration {
+	cfg, err := config.Get()
+	if err != nil {
+		return 0
+	}
+	// validated when the config is loaded
+	seconds, _ := strconv.Atoi(cfg.StreamAckTimeoutSeconds)
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// streamPingInterval returns the ping interval for the ack timeout, a client gets about three pings before it is dropped
+func streamPingInterval(ackTimeout time.Duration) time.Duration {
+	interval := ackTimeout / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > maxStreamPingInterval {
+		interval = maxStreamPingInterval
+	}
+	return interval
+}
+
+// AckClient records a heartbeat acknowledgement of the user's stream client
+func (s *StreamService) AckClient(userID, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client, ok := s.clients[clientID]
+	if !ok || client.UserID != userID {
+		return ErrStreamClientNotFound
+	}
+	client.lastAckAt = time.Now()
+	return nil
+}
+
+// isClientStale returns true when the client has not acked within the ack timeout
+func (s *StreamService) isClientStale(clientID string, ackTimeout time.Duration, now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, ok := s.clients[clientID]
+	if !ok {
+		return false
+	}
+	return now.Sub(client.lastAckAt) > ackTimeout
+}
+
+// streamPingMessage is the ping event a client acks with POST /stream/ack
+func streamPingMessage(clientID string, ackTimeout time.Duration, now time.Time) []byte {
+	return streamMessage("ping", map[string]interface{}{
+		"client_id":           clientID,
+		"ack_timeout_seconds": int(ackTimeout.Seconds()),
+		"timestamp":           now.In(MarketLocation).Format("2006-01-02 15:04:05"),
+	})
+}
+
+// streamStaleMessage is the last event sent to a client dropped for not acking
+func streamStaleMessage(clientID string, ackTimeout time.Duration) []byte {
+	return streamMessage("stale", map[string]interface{}{
+		"client_id": clientID,
+		"message":   "no ack received within " + ackTimeout.String(),
+	})
+}
+
+// releaseExclusiveTokens releases the direct tokens of the removed client that no remaining client streams
+// and returns them, the caller must hold the lock and have rebuilt the global token map
+func (s *StreamService) releaseExclusiveTokens(client *StreamClient) []uint32 {
+	var tokens []uint32
+	for _, token := range client.Tokens {
+		_, streamed := s.globalTokenMap[token]
+		if _, direct := s.directTokens[token]; direct && !streamed {
+			delete(s.directTokens, token)
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// unsubscribableTokens filters out the tokens claimed for the direct connection again after they were released
+func (s *StreamService) unsubscribableTokens(tokens []uint32) []uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		if _, ok := s.directTokens[token]; !ok {
+			result = append(result, token)
+		}
+	}
+	return result
+}
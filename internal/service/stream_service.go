@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
 
 	"gorm.io/gorm"
 )
@@ -20,21 +22,46 @@ import (
 // StreamClient is a client that is subscribed to the stream
 type StreamClient struct {
 	ID          string
+	UserID      string
 	Instruments []string
 	Tokens      []uint32
 	TokenMap    map[uint32]string
 	Channel     chan<- []byte
+	Options     StreamOptions
+	// GroupKey is the subscription group of the client, clients streaming the same tokens in the same mode share it
+	GroupKey string
+	// lastAckAt is the last heartbeat acknowledgement, guarded by the service lock
+	lastAckAt time.Time
+	// dropped is closed once an error event did not fit the full channel, the stream then writes droppedEvent
+	// itself and ends instead of losing it
+	dropped      chan struct{}
+	dropOnce     sync.Once
+	droppedEvent []byte
+}
+
+// drop ends the stream of the client with the event it could not be sent on its channel
+func (c *StreamClient) drop(event []byte) {
+	if c.dropped == nil {
+		return
+	}
+	c.dropOnce.Do(func() {
+		c.droppedEvent = event
+		close(c.dropped)
+	})
 }
 
 // StreamSubscriptionRequest is a request to subscribe to a list of tokens
 type StreamSubscriptionRequest struct {
-	tokens []uint32
-	respCh chan error
+	tokens      []uint32
+	unsubscribe bool
+	respCh      chan error
 }
 
 // StreamService is the service for the stream API
 type StreamService struct {
 	instrumentService *InstrumentService
+	bandService       *BandService
+	usageRepo         *repository.StreamUsageRepository
 	ticker            *kiteticker.Ticker
 	globalTokenMap    map[uint32]string
 	mu                sync.RWMutex
@@ -42,23 +69,43 @@ type StreamService struct {
 	isConnected       bool
 	connectChan       chan struct{}
 	subscriptionChan  chan StreamSubscriptionRequest
+	// fanout delivers the ticks to the clients, sharded by token
+	fanout *streamFanout
+	// directTokens are streamed on the service's own connection, the rest come from the ticker connection
+	directTokens   map[uint32]struct{}
+	directUserID   string
+	directEnctoken string
+	// directErrorSent is set once the clients got an error event for the direct connection, until it reconnects
+	directErrorSent bool
+	// circuitLimitsChan asks watchCircuitLimits for a reload once a client brings new instruments
+	circuitLimitsChan chan struct{}
 }
 
 // NewStreamService creates a new service for the stream API
 func NewStreamService(db *gorm.DB) *StreamService {
 	s := &StreamService{
 		instrumentService: NewInstrumentService(db),
+		bandService:       NewBandService(db),
+		usageRepo:         repository.NewStreamUsageRepository(db),
 		globalTokenMap:    make(map[uint32]string),
 		clients:           make(map[string]*StreamClient),
 		connectChan:       make(chan struct{}),
 		subscriptionChan:  make(chan StreamSubscriptionRequest),
+		fanout:            newStreamFanout(0),
+		directTokens:      make(map[uint32]struct{}),
+		circuitLimitsChan: make(chan struct{}, 1),
 	}
-	go s.subscriptionHandler()
+	recovery.Go("stream.subscriptionHandler", s.subscriptionHandler)
+	recovery.Go("stream.watchMarketPhases", s.watchMarketPhases)
+	recovery.Go("stream.watchTickSources", s.watchTickSources)
+	recovery.Go("stream.watchCircuitLimits", s.watchCircuitLimits)
+	GetTickHub().Subscribe(s.broadcastSharedTick)
+	GetInstrumentBlacklist().OnBlacklisted(s.dropInstruments)
 	return s
 }
 
 // RunTickerStream runs the ticker stream for the given client
-func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, userId, enctoken string, instruments []string, errChan chan<- error) {
+func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, userId, enctoken string, instruments []string, options StreamOptions, errChan chan<- error) {
 	clientID := c.Response().Header().Get(echo.HeaderXRequestID)
 	if clientID == "" {
 		clientID = fmt.Sprintf("client-%d", time.Now().UnixNano())
@@ -77,34 +124,53 @@ func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, use
 		tokens = append(tokens, token)
 	}
 
+	// Meter the usage against the daily quota
+	meter, err := newStreamUsageMeter(s.usageRepo, userId)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	defer meter.close()
+	if meter.exceeded() {
+		errChan <- ErrStreamQuotaExceeded
+		return
+	}
+
+	if options.Mode == "" {
+		options.Mode = StreamModeCompact
+	}
+	if options.ResnapshotInterval <= 0 {
+		options.ResnapshotInterval = defaultStreamResnapshotInterval
+	}
+
 	clientChan := make(chan []byte, 100)
 	client := &StreamClient{
 		ID:          clientID,
+		UserID:      userId,
 		Instruments: instruments,
 		Tokens:      tokens,
 		TokenMap:    tokenMap,
 		Channel:     clientChan,
+		Options:     options,
+		lastAckAt:   time.Now(),
+		dropped:     make(chan struct{}),
 	}
 
-	s.addClient(client)
-	defer s.removeClient(clientID)
-
-	s.mu.Lock()
-	if s.ticker == nil {
-		if err := s.initTicker(userId, enctoken); err != nil {
-			s.mu.Unlock()
-			errChan <- fmt.Errorf("failed to initialize ticker: %v", err)
-			return
-		}
+	directTokens, err := s.addClient(client, userId, enctoken)
+	if err != nil {
+		errChan <- err
+		return
 	}
-	s.mu.Unlock()
+	defer s.removeClient(clientID)
 
-	if err := s.waitForConnection(ctx); err != nil {
-		errChan <- fmt.Errorf("connection timeout: %v", err)
-		return
+	streamed := make([]string, 0, len(tokenMap))
+	for _, instrument := range tokenMap {
+		streamed = append(streamed, instrument)
 	}
+	GetInstrumentAccessTracker().RecordStream(streamed...)
 
-	if err := s.subscribeClientTokens(client.Tokens); err != nil {
+	// tokens the ticker connection streams are shared, only the rest need the direct connection
+	if err := s.subscribeDirectTokens(ctx, directTokens); err != nil {
 		errChan <- fmt.Errorf("failed to subscribe client tokens: %v", err)
 		return
 	}
@@ -125,30 +191,82 @@ func (s *StreamService) RunTickerStream(ctx context.Context, c echo.Context, use
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	// clients that stop acking pings are dropped so their tokens are released promptly
+	var pingChan <-chan time.Time
+	ackTimeout := getStreamAckTimeout()
+	if ackTimeout > 0 {
+		pingTicker := time.NewTicker(streamPingInterval(ackTimeout))
+		defer pingTicker.Stop()
+		pingChan = pingTicker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-client.dropped:
+			log.Printf("Dropping stream client %s: error event did not fit its channel", clientID)
+			c.Response().Write(client.droppedEvent)
+			c.Response().Flush()
+			return
 		case data := <-clientChan:
 			if _, err := c.Response().Write(data); err != nil {
 				log.Printf("Error writing to client %s: %v", clientID, err)
 				return
 			}
 			c.Response().Flush()
+			meter.addMessage(len(data))
+			if meter.exceeded() {
+				// Notify the client before closing the stream
+				c.Response().Write([]byte("event: quota_exceeded\ndata: " + ErrStreamQuotaExceeded.Error() + "\n\n"))
+				c.Response().Flush()
+				return
+			}
 		case <-ticker.C:
+			meter.flush()
+			if meter.exceeded() {
+				c.Response().Write([]byte("event: quota_exceeded\ndata: " + ErrStreamQuotaExceeded.Error() + "\n\n"))
+				c.Response().Flush()
+				return
+			}
 			// Send a keep-alive message every 30 seconds
 			if _, err := c.Response().Write([]byte(": keep-alive\n\n")); err != nil {
 				log.Printf("Error writing keep-alive: %v", err)
 				return
 			}
 			c.Response().Flush()
+		case now := <-pingChan:
+			if s.isClientStale(clientID, ackTimeout, now) {
+				log.Printf("Dropping stream client %s: no ack within %s", clientID, ackTimeout)
+				c.Response().Write(streamStaleMessage(clientID, ackTimeout))
+				c.Response().Flush()
+				return
+			}
+			if _, err := c.Response().Write(streamPingMessage(clientID, ackTimeout, now)); err != nil {
+				log.Printf("Error writing ping: %v", err)
+				return
+			}
+			c.Response().Flush()
 		}
 	}
 }
 
+// GetStreamUsage gets the stream usage of the user for today along with the daily quota
+func (s *StreamService) GetStreamUsage(userID string) (models.StreamUsageData, error) {
+	usage, err := s.usageRepo.GetStreamUsage(userID, MarketToday())
+	if err != nil {
+		return models.StreamUsageData{}, err
+	}
+	return models.StreamUsageData{Usage: usage, Quota: getStreamQuota()}, nil
+}
+
 // subscriptionHandler handles the subscription requests
 func (s *StreamService) subscriptionHandler() {
 	for req := range s.subscriptionChan {
+		if req.unsubscribe {
+			req.respCh <- s.ticker.Unsubscribe(s.unsubscribableTokens(req.tokens))
+			continue
+		}
 		err := s.ticker.Subscribe(req.tokens)
 		if err == nil {
 			err = s.ticker.SetMode(kiteticker.ModeFull, req.tokens)
@@ -164,6 +282,13 @@ func (s *StreamService) subscribeClientTokens(tokens []uint32) error {
 	return <-respCh
 }
 
+// unsubscribeClientTokens unsubscribes the given tokens unless a client has subscribed to them since
+func (s *StreamService) unsubscribeClientTokens(tokens []uint32) error {
+	respCh := make(chan error)
+	s.subscriptionChan <- StreamSubscriptionRequest{tokens: tokens, unsubscribe: true, respCh: respCh}
+	return <-respCh
+}
+
 // waitForConnection waits for the ticker to connect
 func (s *StreamService) waitForConnection(ctx context.Context) error {
 	s.mu.RLock()
@@ -183,25 +308,120 @@ func (s *StreamService) waitForConnection(ctx context.Context) error {
 	}
 }
 
-// addClient adds a client to the service
-func (s *StreamService) addClient(client *StreamClient) {
+// addClient adds a client to the service and returns its tokens that need the direct connection,
+// failing when they do not fit the stream token budget. The user's enctoken opens the direct connection
+func (s *StreamService) addClient(client *StreamClient, userId, enctoken string) ([]uint32, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	directTokens, err := s.claimDirectTokens(client.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	s.directUserID = userId
+	s.directEnctoken = enctoken
 	s.clients[client.ID] = client
+	added := false
 	for token, instrument := range client.TokenMap {
+		if _, ok := s.globalTokenMap[token]; !ok {
+			added = true
+		}
 		s.globalTokenMap[token] = instrument
 	}
+	if added {
+		select {
+		case s.circuitLimitsChan <- struct{}{}:
+		default:
+		}
+	}
+	s.fanout.add(client)
+	return directTokens, nil
 }
 
-// removeClient removes a client from the service
+// removeClient removes a client from the service and unsubscribes the tokens only it streamed
 func (s *StreamService) removeClient(clientID string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	if client, ok := s.clients[clientID]; ok {
+	client, ok := s.clients[clientID]
+	if ok {
+		s.fanout.remove(client)
 		close(client.Channel)
 		delete(s.clients, clientID)
 	}
 	s.cleanupGlobalTokenMap()
+	var tokens []uint32
+	if ok {
+		tokens = s.releaseExclusiveTokens(client)
+		GetTokenBudget().Release(models.TokenBudgetSourceStream, tokens)
+	}
+	connected := s.ticker != nil && s.isConnected
+	s.mu.Unlock()
+
+	if len(tokens) == 0 || !connected {
+		return
+	}
+	if err := s.unsubscribeClientTokens(tokens); err != nil {
+		log.Printf("Error unsubscribing tokens of client %s: %v", clientID, err)
+	}
+}
+
+// dropInstruments stops streaming the blacklisted instruments, their clients get an error event listing them
+// and the tokens are unsubscribed from the direct connection
+func (s *StreamService) dropInstruments(instruments []string) {
+	blacklisted := make(map[string]bool, len(instruments))
+	for _, instrument := range instruments {
+		blacklisted[instrument] = true
+	}
+
+	var tokens []uint32
+	s.mu.RLock()
+	for token, instrument := range s.globalTokenMap {
+		if blacklisted[instrument] {
+			tokens = append(tokens, token)
+		}
+	}
+	s.mu.RUnlock()
+	if len(tokens) == 0 {
+		return
+	}
+	s.broadcastTokenError(StreamErrorBlacklisted, errInstrumentBlacklisted, tokens)
+
+	s.mu.Lock()
+	for _, client := range s.clients {
+		tokenMap := make(map[uint32]string, len(client.TokenMap))
+		for token, instrument := range client.TokenMap {
+			if !blacklisted[instrument] {
+				tokenMap[token] = instrument
+			}
+		}
+		if len(tokenMap) == len(client.TokenMap) {
+			continue
+		}
+		// the group of the client shares its token map, the client moves to the group of its remaining tokens
+		s.fanout.remove(client)
+		client.TokenMap = tokenMap
+		client.Tokens = make([]uint32, 0, len(tokenMap))
+		for token := range tokenMap {
+			client.Tokens = append(client.Tokens, token)
+		}
+		s.fanout.add(client)
+	}
+	s.cleanupGlobalTokenMap()
+	var released []uint32
+	for _, token := range tokens {
+		if _, direct := s.directTokens[token]; direct {
+			delete(s.directTokens, token)
+			released = append(released, token)
+		}
+	}
+	GetTokenBudget().Release(models.TokenBudgetSourceStream, released)
+	connected := s.ticker != nil && s.isConnected
+	s.mu.Unlock()
+
+	if len(released) == 0 || !connected {
+		return
+	}
+	if err := s.unsubscribeClientTokens(released); err != nil {
+		log.Printf("Error unsubscribing %d blacklisted tokens: %v", len(released), err)
+	}
 }
 
 // cleanupGlobalTokenMap cleans up the global token map
@@ -221,6 +441,8 @@ func (s *StreamService) prepareTokenMap(instrumentsStr []string) (map[uint32]str
 	if err != nil {
 		return nil, fmt.Errorf("failed to get instrument token: %w", err)
 	}
+	// blacklisted instruments are never streamed
+	instruments, _ = GetInstrumentBlacklist().Filter(instruments)
 	tokenMap := make(map[uint32]string)
 	for _, instrument := range instruments {
 		tokenMap[instrument.InstrumentToken] = fmt.Sprintf("%s:%s", instrument.Exchange, instrument.Tradingsymbol)
@@ -248,6 +470,13 @@ func (s *StreamService) initTicker(userId, enctoken string) error {
 func (s *StreamService) setupCallbacks() {
 	s.ticker.OnError(func(err error) {
 		log.Printf("Ticker error: %v", err)
+		s.mu.Lock()
+		sent := s.directErrorSent
+		s.directErrorSent = true
+		s.mu.Unlock()
+		if !sent {
+			s.broadcastTokenError(StreamErrorConnection, err, s.directTokenList())
+		}
 	})
 
 	s.ticker.OnClose(func(code int, reason string) {
@@ -262,6 +491,7 @@ func (s *StreamService) setupCallbacks() {
 		log.Println("Ticker connected")
 		s.mu.Lock()
 		s.isConnected = true
+		s.directErrorSent = false
 		close(s.connectChan)
 		s.mu.Unlock()
 	})
@@ -272,48 +502,46 @@ func (s *StreamService) setupCallbacks() {
 
 	s.ticker.OnNoReconnect(func(attempt int) {
 		log.Printf("Ticker max reconnect attempts reached: attempt=%d", attempt)
+		s.broadcastTokenError(StreamErrorConnectionLost, fmt.Errorf("no reconnect after %d attempts", attempt), s.directTokenList())
 	})
 
 	s.ticker.OnTick(func(tick kiteticker.Tick) {
-		s.broadcastTick(tick)
+		s.broadcastTick(getTickNormalizer().normalize(tick))
 	})
 }
 
-// broadcastTick broadcasts the tick to all clients
+// broadcastTick queues the tick on the fan-out worker of its token
 func (s *StreamService) broadcastTick(tick kiteticker.Tick) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.fanout.dispatch(tick)
+}
 
-	symbolInfo, ok := s.globalTokenMap[tick.InstrumentToken]
-	if !ok {
-		return
+// deltaMessage returns the snapshot or delta message of the tick for a delta client,
+// nil when nothing changed since the last message
+func (sub *streamSubscriber) deltaMessage(tick kiteticker.Tick, exchange, tradingsymbol string, now time.Time) []byte {
+	fields := streamTickFields(tick, exchange, tradingsymbol, sub.group.options.Mode)
+	if sub.delta == nil || now.Sub(sub.delta.lastSnapshotAt) >= sub.group.options.ResnapshotInterval {
+		sub.delta = &streamDeltaState{fields: fields, lastSnapshotAt: now}
+		return streamMessage("snapshot", fields)
 	}
 
-	exchange, tradingsymbol, _ := strings.Cut(symbolInfo, ":")
-
-	tickData := map[string]interface{}{
-		"exchange":      exchange,
-		"tradingsymbol": tradingsymbol,
-		"last_price":    tick.LastPrice,
-		"volume":        tick.VolumeTraded,
-		"avg_price":     tick.AverageTradePrice,
+	delta := streamTickDelta(sub.delta.fields, fields)
+	sub.delta.fields = fields
+	// only the instrument identity, nothing changed
+	if len(delta) == 2 {
+		return nil
 	}
+	return streamMessage("delta", delta)
+}
 
-	jsonData, err := json.Marshal(tickData)
+// streamMessage formats a server-sent event, a blank event sends a plain data message
+func streamMessage(event string, fields map[string]interface{}) []byte {
+	jsonData, err := json.Marshal(fields)
 	if err != nil {
 		log.Printf("Error marshaling tick data: %v", err)
-		return
+		return nil
 	}
-
-	data := []byte(fmt.Sprintf("data: %s\n\n", jsonData))
-
-	for _, client := range s.clients {
-		if _, ok := client.TokenMap[tick.InstrumentToken]; ok {
-			select {
-			case client.Channel <- data:
-			default:
-				log.Printf("Skipping slow client: %s", client.ID)
-			}
-		}
+	if event == "" {
+		return []byte(fmt.Sprintf("data: %s\n\n", jsonData))
 	}
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, jsonData))
 }
@@ -0,0 +1,132 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// tickSourceCheckInterval is how often tokens left without a tick source are moved to the direct connection
+const tickSourceCheckInterval = 5 * time.Second
+
+// broadcastSharedTick broadcasts a tick of the ticker connection, tokens on the direct connection
+// are skipped so clients do not get them twice
+func (s *StreamService) broadcastSharedTick(tick kiteticker.Tick) {
+	s.mu.RLock()
+	_, direct := s.directTokens[tick.InstrumentToken]
+	s.mu.RUnlock()
+	if direct {
+		return
+	}
+	s.broadcastTick(tick)
+}
+
+// claimDirectTokens marks the tokens the ticker connection does not stream in full mode as direct
+// and returns them, failing when they do not fit the stream token budget. The caller must hold the lock
+func (s *StreamService) claimDirectTokens(tokens []uint32) ([]uint32, error) {
+	hub := GetTickHub()
+	var direct []uint32
+	for _, token := range tokens {
+		if _, ok := s.directTokens[token]; ok || !hub.Covers(token, kiteticker.ModeFull) {
+			direct = append(direct, token)
+		}
+	}
+	if err := GetTokenBudget().Reserve(models.TokenBudgetSourceStream, direct); err != nil {
+		return nil, err
+	}
+	for _, token := range direct {
+		s.directTokens[token] = struct{}{}
+	}
+	return direct, nil
+}
+
+// subscribeDirectTokens subscribes the tokens on the direct connection, opening it when needed
+func (s *StreamService) subscribeDirectTokens(ctx context.Context, tokens []uint32) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	if s.ticker == nil {
+		if err := s.initTicker(s.directUserID, s.directEnctoken); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+
+	if err := s.waitForConnection(ctx); err != nil {
+		return err
+	}
+	return s.subscribeClientTokens(tokens)
+}
+
+// watchTickSources moves the tokens the ticker connection stopped streaming to the direct connection,
+// the clients of the tokens that could not be moved get an error event
+func (s *StreamService) watchTickSources() {
+	ticker := time.NewTicker(tickSourceCheckInterval)
+	defer ticker.Stop()
+
+	hub := GetTickHub()
+	// the tokens left over the budget are retried on every check, the error is only sent once per token
+	overBudget := make(map[uint32]struct{})
+	for range ticker.C {
+		s.mu.Lock()
+		var orphans []uint32
+		for token, instrument := range s.globalTokenMap {
+			// blacklisted tokens dropped by the ticker connection are being dropped from the clients too
+			if GetInstrumentBlacklist().Contains(instrument) {
+				continue
+			}
+			if _, ok := s.directTokens[token]; !ok && !hub.Covers(token, kiteticker.ModeFull) {
+				orphans = append(orphans, token)
+			}
+		}
+		var budgetErr error
+		var unreported []uint32
+		if len(orphans) > 0 {
+			if err := GetTokenBudget().Reserve(models.TokenBudgetSourceStream, orphans); err != nil {
+				log.Printf("Error moving %d tokens to the direct connection: %v", len(orphans), err)
+				budgetErr = err
+				unreported = unreportedTokens(overBudget, orphans)
+				orphans = nil
+			}
+			for _, token := range orphans {
+				s.directTokens[token] = struct{}{}
+			}
+		}
+		if budgetErr == nil {
+			clear(overBudget)
+		}
+		s.mu.Unlock()
+
+		if budgetErr != nil {
+			s.broadcastTokenError(StreamErrorTokenBudget, budgetErr, unreported)
+		}
+		if len(orphans) == 0 {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := s.subscribeDirectTokens(ctx, orphans); err != nil {
+			log.Printf("Error subscribing %d tokens on the direct connection: %v", len(orphans), err)
+			s.broadcastTokenError(StreamErrorSubscribeFailed, err, orphans)
+		}
+		cancel()
+	}
+}
+
+// unreportedTokens returns the tokens not in the reported set and adds them to it
+func unreportedTokens(reported map[uint32]struct{}, tokens []uint32) []uint32 {
+	var unreported []uint32
+	for _, token := range tokens {
+		if _, ok := reported[token]; !ok {
+			reported[token] = struct{}{}
+			unreported = append(unreported, token)
+		}
+	}
+	return unreported
+}
@@ -0,0 +1,183 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// ErrStreamQuotaExceeded is returned when a user has used up the daily stream quota
+var ErrStreamQuotaExceeded = errors.New("daily stream quota exceeded")
+
+// getStreamQuota returns the daily stream quota from the config
+func getStreamQuota() models.StreamQuota {
+	var quota models.StreamQuota
+	cfg, err := config.Get()
+	if err != nil {
+		return quota
+	}
+	// values are validated when the config is loaded
+	quota.Minutes, _ = strconv.ParseInt(cfg.StreamQuotaDailyMinutes, 10, 64)
+	quota.Messages, _ = strconv.ParseInt(cfg.StreamQuotaDailyMessages, 10, 64)
+	quota.Bytes, _ = strconv.ParseInt(cfg.StreamQuotaDailyBytes, 10, 64)
+	return quota
+}
+
+// streamUserUsage is the usage of a user on a day shared by all the user's streams, so concurrent streams
+// draw on one quota. base is the persisted usage and pending the usage not persisted yet
+type streamUserUsage struct {
+	mu      sync.Mutex
+	base    models.StreamUsageModel
+	pending models.StreamUsageModel
+	streams int
+}
+
+var (
+	streamUsagesMu sync.Mutex
+	streamUsages   = make(map[string]*streamUserUsage)
+)
+
+// acquireStreamUsage returns the shared usage of the user for today, loading it when the user has no open
+// stream or the day changed, and counts the stream in
+func acquireStreamUsage(repo *repository.StreamUsageRepository, userID string) (*streamUserUsage, error) {
+	date := MarketToday()
+	streamUsagesMu.Lock()
+	defer streamUsagesMu.Unlock()
+
+	usage, ok := streamUsages[userID]
+	if ok {
+		usage.mu.Lock()
+		current := usage.pending.Date == date
+		usage.mu.Unlock()
+		if current {
+			usage.streams++
+			return usage, nil
+		}
+	}
+	base, err := repo.GetStreamUsage(userID, date)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		usage = &streamUserUsage{}
+		streamUsages[userID] = usage
+	}
+	usage.mu.Lock()
+	// the unpersisted usage of the previous day is dropped with it
+	usage.base = base
+	usage.pending = models.StreamUsageModel{UserID: userID, Date: date}
+	usage.mu.Unlock()
+	usage.streams++
+	return usage, nil
+}
+
+// releaseStreamUsage counts a stream of the user out, the shared usage is dropped with the last stream
+func releaseStreamUsage(userID string, usage *streamUserUsage) {
+	streamUsagesMu.Lock()
+	defer streamUsagesMu.Unlock()
+	usage.streams--
+	if usage.streams <= 0 && streamUsages[userID] == usage {
+		delete(streamUsages, userID)
+	}
+}
+
+// streamUsageMeter meters the usage of one stream into the shared usage of its user, the usage is persisted on flush
+type streamUsageMeter struct {
+	repo      *repository.StreamUsageRepository
+	quota     models.StreamQuota
+	userID    string
+	usage     *streamUserUsage
+	lastFlush time.Time
+}
+
+// newStreamUsageMeter creates a meter on the user's usage of today shared with the user's other streams,
+// close releases it
+func newStreamUsageMeter(repo *repository.StreamUsageRepository, userID string) (*streamUsageMeter, error) {
+	usage, err := acquireStreamUsage(repo, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &streamUsageMeter{
+		repo:      repo,
+		quota:     getStreamQuota(),
+		userID:    userID,
+		usage:     usage,
+		lastFlush: time.Now(),
+	}, nil
+}
+
+// addMessage meters a message of n bytes sent to the client
+func (m *streamUsageMeter) addMessage(n int) {
+	m.usage.mu.Lock()
+	defer m.usage.mu.Unlock()
+	m.usage.pending.Messages++
+	m.usage.pending.Bytes += int64(n)
+}
+
+// exceeded returns true if the usage of the user including the unflushed usage is over the quota
+func (m *streamUsageMeter) exceeded() bool {
+	m.usage.mu.Lock()
+	defer m.usage.mu.Unlock()
+	base, pending := m.usage.base, m.usage.pending
+	seconds := base.Seconds + pending.Seconds + int64(time.Since(m.lastFlush).Seconds())
+	if m.quota.Minutes > 0 && seconds >= m.quota.Minutes*60 {
+		return true
+	}
+	if m.quota.Messages > 0 && base.Messages+pending.Messages >= m.quota.Messages {
+		return true
+	}
+	if m.quota.Bytes > 0 && base.Bytes+pending.Bytes >= m.quota.Bytes {
+		return true
+	}
+	return false
+}
+
+// flush adds the stream's time since the last flush to the user's usage and persists the pending usage
+func (m *streamUsageMeter) flush() {
+	// carry the fractional second over to the next flush
+	elapsed := int64(time.Since(m.lastFlush).Seconds())
+	m.lastFlush = m.lastFlush.Add(time.Duration(elapsed) * time.Second)
+
+	m.usage.mu.Lock()
+	m.usage.pending.Seconds += elapsed
+	pending := m.usage.pending
+	if pending.Seconds == 0 && pending.Messages == 0 && pending.Bytes == 0 {
+		m.usage.mu.Unlock()
+		return
+	}
+	// the pending usage is taken so the user's other streams do not persist it again
+	m.usage.pending.Seconds, m.usage.pending.Messages, m.usage.pending.Bytes = 0, 0, 0
+	m.usage.mu.Unlock()
+
+	err := m.repo.AddStreamUsage(pending)
+
+	m.usage.mu.Lock()
+	defer m.usage.mu.Unlock()
+	if err != nil {
+		zaplogger.Error("Failed to persist stream usage", zaplogger.Fields{
+			"user_id": pending.UserID,
+			"error":   err.Error(),
+		})
+		// put the usage back for the next flush
+		m.usage.pending.Seconds += pending.Seconds
+		m.usage.pending.Messages += pending.Messages
+		m.usage.pending.Bytes += pending.Bytes
+		return
+	}
+	m.usage.base.Seconds += pending.Seconds
+	m.usage.base.Messages += pending.Messages
+	m.usage.base.Bytes += pending.Bytes
+}
+
+// close flushes the usage of the stream and releases the user's shared usage
+func (m *streamUsageMeter) close() {
+	m.flush()
+	releaseStreamUsage(m.userID, m.usage)
+}
@@ -0,0 +1,223 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	kitemodels "github.com/nsvirk/gokiteticker/models"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// TickBenchOptions configures a synthetic run of the tick pipeline
+type TickBenchOptions struct {
+	// Instruments is the number of synthetic instruments
+	Instruments int
+	// Rate is the number of ticks per second, 0 pushes ticks as fast as possible
+	Rate int
+	// Duration is how long ticks are pushed
+	Duration time.Duration
+	// Clients is the number of stream clients subscribed to every instrument
+	Clients int
+	// Deltas makes the stream clients delta clients
+	Deltas bool
+	// DB flushes the ticks to Postgres when set, the flush stage is skipped otherwise
+	DB *gorm.DB
+}
+
+// TickBenchStage is the latency distribution of a pipeline stage
+type TickBenchStage struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// TickBenchReport is the outcome of a synthetic run of the tick pipeline
+type TickBenchReport struct {
+	Ticks            int            `json:"ticks"`
+	Elapsed          time.Duration  `json:"elapsed"`
+	TicksPerSecond   float64        `json:"ticks_per_second"`
+	MessagesSent     int64          `json:"messages_sent"`
+	MessagesReceived int64          `json:"messages_received"`
+	Process          TickBenchStage `json:"process"`
+	Broadcast        TickBenchStage `json:"broadcast"`
+	Flush            TickBenchStage `json:"flush"`
+}
+
+// RunTickBench pushes synthetic ticks through processTick, flushData and broadcastTick and reports
+// the throughput and the latency of each stage, the ticker connection is never used
+func RunTickBench(opts TickBenchOptions) (TickBenchReport, error) {
+	if opts.Instruments <= 0 {
+		return TickBenchReport{}, fmt.Errorf("instruments must be positive")
+	}
+	if opts.Duration <= 0 {
+		return TickBenchReport{}, fmt.Errorf("duration must be positive")
+	}
+
+	tickerService := &TickerService{
+		repo:          repository.NewTickerRepository(opts.DB),
+		instruments:   make(map[uint32]string, opts.Instruments),
+		prevCloseSeen: make(map[uint32]bool),
+	}
+	streamService := &StreamService{
+		globalTokenMap: make(map[uint32]string, opts.Instruments),
+		clients:        make(map[string]*StreamClient),
+		fanout:         newStreamFanout(0),
+	}
+
+	// synthetic instruments with tokens well outside kite's ranges
+	tokens := make([]uint32, opts.Instruments)
+	prices := make([]float64, opts.Instruments)
+	tokenMap := make(map[uint32]string, opts.Instruments)
+	for i := range tokens {
+		tokens[i] = uint32(4000000000 + i)
+		prices[i] = 100 + float64(rand.Intn(10000))
+		instrument := fmt.Sprintf("BENCH:SYN%06d", i)
+		tickerService.instruments[tokens[i]] = instrument
+		streamService.globalTokenMap[tokens[i]] = instrument
+		tokenMap[tokens[i]] = instrument
+	}
+
+	// stream clients drain their channels like the SSE writers do
+	var received int64
+	var wg sync.WaitGroup
+	channels := make([]chan []byte, opts.Clients)
+	for i := range channels {
+		channels[i] = make(chan []byte, 1000)
+		client := &StreamClient{
+			ID:       fmt.Sprintf("bench-%d", i),
+			Tokens:   tokens,
+			TokenMap: tokenMap,
+			Channel:  channels[i],
+			Options:  StreamOptions{Mode: StreamModeCompact, Deltas: opts.Deltas, ResnapshotInterval: time.Minute},
+		}
+		streamService.clients[client.ID] = client
+		streamService.fanout.add(client)
+		wg.Add(1)
+		go func(ch chan []byte) {
+			defer wg.Done()
+			for range ch {
+				atomic.AddInt64(&received, 1)
+			}
+		}(channels[i])
+	}
+
+	var interval time.Duration
+	if opts.Rate > 0 {
+		interval = time.Second / time.Duration(opts.Rate)
+	}
+
+	processLatencies := make([]time.Duration, 0)
+	broadcastLatencies := make([]time.Duration, 0)
+	flushLatencies := make([]time.Duration, 0)
+	postgresData := make([]models.TickerData, 0, batchSize)
+	flush := func() {
+		if opts.DB == nil {
+			postgresData = postgresData[:0]
+			return
+		}
+		start := time.Now()
+		tickerService.flushData(&postgresData)
+		flushLatencies = append(flushLatencies, time.Since(start))
+	}
+
+	started := time.Now()
+	next := started
+	ticks := 0
+	for time.Since(started) < opts.Duration {
+		i := ticks % opts.Instruments
+		prices[i] += (rand.Float64() - 0.5) * prices[i] * 0.001
+		tick := syntheticTick(tokens[i], prices[i], ticks)
+
+		start := time.Now()
+		tickerService.processTick(tick, &postgresData)
+		processLatencies = append(processLatencies, time.Since(start))
+
+		start = time.Now()
+		streamService.broadcastTick(tick)
+		broadcastLatencies = append(broadcastLatencies, time.Since(start))
+
+		if len(postgresData) >= batchSize {
+			flush()
+		}
+		ticks++
+
+		if interval > 0 {
+			next = next.Add(interval)
+			if wait := time.Until(next); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+	}
+	flush()
+	// the fan-out workers deliver the queued ticks before the channels are closed
+	streamService.fanout.stop()
+	elapsed := time.Since(started)
+
+	for _, ch := range channels {
+		close(ch)
+	}
+	wg.Wait()
+
+	return TickBenchReport{
+		Ticks:            ticks,
+		Elapsed:          elapsed,
+		TicksPerSecond:   float64(ticks) / elapsed.Seconds(),
+		MessagesSent:     int64(ticks) * int64(opts.Clients),
+		MessagesReceived: received,
+		Process:          tickBenchStage(processLatencies),
+		Broadcast:        tickBenchStage(broadcastLatencies),
+		Flush:            tickBenchStage(flushLatencies),
+	}, nil
+}
+
+// syntheticTick returns a full mode tick with a depth around the price
+func syntheticTick(token uint32, price float64, seq int) kiteticker.Tick {
+	now := time.Now()
+	tick := kiteticker.Tick{
+		Mode:               string(kiteticker.ModeFull),
+		InstrumentToken:    token,
+		IsTradable:         true,
+		Timestamp:          kitemodels.Time{Time: now},
+		LastTradeTime:      kitemodels.Time{Time: now},
+		LastPrice:          price,
+		LastTradedQuantity: uint32(1 + seq%100),
+		TotalBuyQuantity:   uint32(10000 + seq%1000),
+		TotalSellQuantity:  uint32(10000 + seq%777),
+		VolumeTraded:       uint32(seq),
+		AverageTradePrice:  price,
+	}
+	tick.OHLC.Open, tick.OHLC.High, tick.OHLC.Low, tick.OHLC.Close = price, price*1.01, price*0.99, price
+	for i := range tick.Depth.Buy {
+		tick.Depth.Buy[i] = kiteticker.DepthItem{Price: price - 0.05*float64(i+1), Quantity: 100, Orders: 1}
+		tick.Depth.Sell[i] = kiteticker.DepthItem{Price: price + 0.05*float64(i+1), Quantity: 100, Orders: 1}
+	}
+	return tick
+}
+
+// tickBenchStage computes the latency distribution of the samples
+func tickBenchStage(samples []time.Duration) TickBenchStage {
+	if len(samples) == 0 {
+		return TickBenchStage{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	percentile := func(p float64) time.Duration {
+		return samples[int(float64(len(samples)-1)*p)]
+	}
+	return TickBenchStage{
+		Count: len(samples),
+		P50:   percentile(0.50),
+		P90:   percentile(0.90),
+		P99:   percentile(0.99),
+		Max:   samples[len(samples)-1],
+	}
+}
@@ -0,0 +1,130 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"sync"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+)
+
+// TickHub shares the ticks of the ticker connection with the other consumers in the process,
+// so they only open their own Kite connection for the tokens the ticker does not subscribe
+type TickHub struct {
+	mu          sync.RWMutex
+	modes       map[uint32]kiteticker.Mode
+	subscribers map[int]func(kiteticker.Tick)
+	nextID      int
+}
+
+var (
+	tickHub     *TickHub
+	tickHubOnce sync.Once
+)
+
+// GetTickHub returns the process wide tick hub
+func GetTickHub() *TickHub {
+	tickHubOnce.Do(func() {
+		tickHub = &TickHub{
+			modes:       make(map[uint32]kiteticker.Mode),
+			subscribers: make(map[int]func(kiteticker.Tick)),
+		}
+	})
+	return tickHub
+}
+
+// Subscribe registers fn for every tick of the ticker connection and returns the unsubscribe func,
+// fn runs on the ticker's read loop and must not block
+func (h *TickHub) Subscribe(fn func(kiteticker.Tick)) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := h.nextID
+	h.nextID++
+	h.subscribers[id] = fn
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers, id)
+	}
+}
+
+// Publish hands the tick to the subscribers, they are called without the lock held
+// as they take their own locks which are held while checking Covers
+func (h *TickHub) Publish(tick kiteticker.Tick) {
+	defer recovery.Guard("tickHub.Publish")
+	h.mu.RLock()
+	subscribers := make([]func(kiteticker.Tick), 0, len(h.subscribers))
+	for _, fn := range h.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	h.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(tick)
+	}
+}
+
+// SetTokens replaces the tokens the ticker connection streams with their modes, nil when it stops
+func (h *TickHub) SetTokens(modeTokens map[kiteticker.Mode][]uint32) {
+	modes := make(map[uint32]kiteticker.Mode)
+	for mode, tokens := range modeTokens {
+		for _, token := range tokens {
+			modes[token] = mode
+		}
+	}
+	h.mu.Lock()
+	h.modes = modes
+	h.mu.Unlock()
+}
+
+// AddTokens records tokens the ticker connection subscribed while running
+func (h *TickHub) AddTokens(mode kiteticker.Mode, tokens []uint32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, token := range tokens {
+		h.modes[token] = mode
+	}
+}
+
+// RemoveTokens forgets tokens the ticker connection unsubscribed while running
+func (h *TickHub) RemoveTokens(tokens []uint32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, token := range tokens {
+		delete(h.modes, token)
+	}
+}
+
+// Covers returns true when the ticker connection streams the token in the given mode or a richer one
+func (h *TickHub) Covers(token uint32, mode kiteticker.Mode) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	tokenMode, ok := h.modes[token]
+	if !ok {
+		return false
+	}
+	return tickModeRank(tokenMode) >= tickModeRank(mode)
+}
+
+// Tokens returns the tokens the ticker connection streams
+func (h *TickHub) Tokens() []uint32 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	tokens := make([]uint32, 0, len(h.modes))
+	for token := range h.modes {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// tickModeRank orders the ticker modes by the fields they carry
+func tickModeRank(mode kiteticker.Mode) int {
+	switch mode {
+	case kiteticker.ModeFull:
+		return 3
+	case kiteticker.ModeQuote:
+		return 2
+	case kiteticker.ModeLTP:
+		return 1
+	}
+	return 0
+}
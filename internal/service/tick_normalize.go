@@ -0,0 +1,98 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"math"
+	"sync"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// defaultTickPrecisions are the precisions of the segments whose instruments have no tick size, indices move in
+// hundredths
+var defaultTickPrecisions = map[string]int{
+	"INDICES": 2,
+}
+
+// tickNormalizer rounds the prices of the ticks to the precision of their instrument, the configured precision of
+// the segment or the decimals of the tick size. Prices of unknown instruments keep the decimal places of a Decimal
+type tickNormalizer struct {
+	segments map[string]int
+	cache    *InstrumentCache
+}
+
+var (
+	tickNormalizerInstance *tickNormalizer
+	tickNormalizerOnce     sync.Once
+)
+
+// getTickNormalizer returns the process wide tick normalizer with the configured precisions
+func getTickNormalizer() *tickNormalizer {
+	tickNormalizerOnce.Do(func() {
+		segments := make(map[string]int, len(defaultTickPrecisions))
+		for segment, places := range defaultTickPrecisions {
+			segments[segment] = places
+		}
+		// the default precisions are used when the config can not be loaded
+		if cfg, err := config.Get(); err == nil {
+			// validated when the config is loaded
+			precisions, _ := config.ParsePrecisions(cfg.TickPrecisions)
+			for segment, places := range precisions {
+				segments[segment] = places
+			}
+		}
+		tickNormalizerInstance = &tickNormalizer{segments: segments, cache: GetInstrumentCache()}
+	})
+	return tickNormalizerInstance
+}
+
+// precision returns the decimal places of the prices of the token
+func (n *tickNormalizer) precision(token uint32) int {
+	instrument, ok := n.cache.GetByToken(token)
+	if !ok {
+		return decimal.Places
+	}
+	if places, ok := n.segments[instrument.Segment]; ok {
+		return places
+	}
+	return tickSizeDecimals(instrument.TickSize)
+}
+
+// normalize returns the tick with its prices, net change and depth rounded to the precision of the instrument
+func (n *tickNormalizer) normalize(tick kiteticker.Tick) kiteticker.Tick {
+	places := n.precision(tick.InstrumentToken)
+	tick.LastPrice = roundPlaces(tick.LastPrice, places)
+	tick.AverageTradePrice = roundPlaces(tick.AverageTradePrice, places)
+	tick.NetChange = roundPlaces(tick.NetChange, places)
+	tick.OHLC.Open = roundPlaces(tick.OHLC.Open, places)
+	tick.OHLC.High = roundPlaces(tick.OHLC.High, places)
+	tick.OHLC.Low = roundPlaces(tick.OHLC.Low, places)
+	tick.OHLC.Close = roundPlaces(tick.OHLC.Close, places)
+	for i := range tick.Depth.Buy {
+		tick.Depth.Buy[i].Price = roundPlaces(tick.Depth.Buy[i].Price, places)
+		tick.Depth.Sell[i].Price = roundPlaces(tick.Depth.Sell[i].Price, places)
+	}
+	return tick
+}
+
+// tickSizeDecimals returns the decimal places of a tick size, e.g. 2 for 0.05 and 4 for 0.0025. Instruments
+// without a tick size keep the decimal places of a Decimal
+func tickSizeDecimals(tickSize decimal.Decimal) int {
+	if tickSize.Sign() <= 0 {
+		return decimal.Places
+	}
+	for places := 0; places < decimal.Places; places++ {
+		if tickSize.Round(places).Equal(tickSize) {
+			return places
+		}
+	}
+	return decimal.Places
+}
+
+// roundPlaces rounds the value half away from zero to the decimal places
+func roundPlaces(value float64, places int) float64 {
+	factor := math.Pow10(places)
+	return math.Round(value*factor) / factor
+}
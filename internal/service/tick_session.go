@@ -0,0 +1,201 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+)
+
+// tickSessionGraceMinutes is how long the default windows stay open after the close, covering the closing session
+const tickSessionGraceMinutes = 30
+
+// tickSessionCalendar is the exchange session calendar ticks are expected in
+type tickSessionCalendar struct {
+	windows  map[string]config.SessionWindow
+	holidays map[string]bool
+}
+
+// newTickSessionCalendar returns the default windows of the trading sessions with the configured windows and holidays
+func newTickSessionCalendar(cfg *config.Config) *tickSessionCalendar {
+	calendar := &tickSessionCalendar{
+		windows:  make(map[string]config.SessionWindow, len(tradingSessions)),
+		holidays: make(map[string]bool),
+	}
+	for exchange, session := range tradingSessions {
+		window := config.SessionWindow{Start: session.open, End: min(session.close+tickSessionGraceMinutes, 24*60)}
+		if session.preOpenEnd > 0 {
+			window.Start = session.preOpen
+		}
+		calendar.windows[exchange] = window
+	}
+	if cfg == nil {
+		return calendar
+	}
+	// validated when the config is loaded
+	windows, _ := config.ParseSessionWindows(cfg.TickSessionWindows)
+	for exchange, window := range windows {
+		calendar.windows[exchange] = window
+	}
+	holidays, _ := config.ParseDates(cfg.TickSessionHolidays)
+	for _, date := range holidays {
+		calendar.holidays[date] = true
+	}
+	return calendar
+}
+
+// inSession returns true if t is within the session window of the exchange on a trading day, unknown exchanges
+// use the NSE window
+func (c *tickSessionCalendar) inSession(exchange string, t time.Time) bool {
+	t = t.In(MarketLocation)
+	if !c.isTradingDay(t) {
+		return false
+	}
+	window, ok := c.windows[exchange]
+	if !ok {
+		window = c.windows["NSE"]
+	}
+	minutes := t.Hour()*60 + t.Minute()
+	return minutes >= window.Start && minutes < window.End
+}
+
+// isTradingDay returns true if the date, in the market time zone, is a weekday and not a holiday
+func (c *tickSessionCalendar) isTradingDay(t time.Time) bool {
+	t = t.In(MarketLocation)
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday && !c.holidays[t.Format("2006-01-02")]
+}
+
+// nextTradingDay returns the midnight of the first trading day after the date of t
+func (c *tickSessionCalendar) nextTradingDay(t time.Time) time.Time {
+	t = t.In(MarketLocation)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, MarketLocation).AddDate(0, 0, 1)
+	for !c.isTradingDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// previousTradingDay returns the midnight of the last trading day before the date of t
+func (c *tickSessionCalendar) previousTradingDay(t time.Time) time.Time {
+	t = t.In(MarketLocation)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, MarketLocation).AddDate(0, 0, -1)
+	for !c.isTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// getTickSessionCalendar returns the calendar of the configured windows and holidays, the default one when the
+// config can not be loaded
+func getTickSessionCalendar() *tickSessionCalendar {
+	cfg, err := config.Get()
+	if err != nil {
+		cfg = nil
+	}
+	return newTickSessionCalendar(cfg)
+}
+
+// tickSessionAlarm detects the ticks timestamped outside the session of their exchange, which usually means a clock
+// or feed problem, and alerts the users at most once per exchange every cooldown
+type tickSessionAlarm struct {
+	calendar *tickSessionCalendar
+	cooldown time.Duration
+	repo     *repository.TickerRepository
+	notifier *NotifierService
+
+	mu sync.Mutex
+	// snapshotSeen are the tokens whose subscription snapshot has been received since the ticker started
+	snapshotSeen map[uint32]bool
+	// lastAlertAt and count are the last alert of each exchange and the ticks seen since
+	lastAlertAt map[string]time.Time
+	count       map[string]int64
+}
+
+// newTickSessionAlarm creates the alarm from the configured session calendar
+func newTickSessionAlarm(repo *repository.TickerRepository, notifier *NotifierService) *tickSessionAlarm {
+	// the default calendar and cooldown are used when the config can not be loaded
+	cooldown := 30 * time.Minute
+	cfg, err := config.Get()
+	if err != nil {
+		cfg = nil
+	} else {
+		// validated when the config is loaded
+		minutes, _ := strconv.Atoi(cfg.TickSessionAlertMinutes)
+		cooldown = time.Duration(minutes) * time.Minute
+	}
+	return &tickSessionAlarm{
+		calendar:     newTickSessionCalendar(cfg),
+		cooldown:     cooldown,
+		repo:         repo,
+		notifier:     notifier,
+		snapshotSeen: make(map[uint32]bool),
+		lastAlertAt:  make(map[string]time.Time),
+		count:        make(map[string]int64),
+	}
+}
+
+// reset forgets the received snapshots, it is called when the ticker starts and subscribes again
+func (a *tickSessionAlarm) reset() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.snapshotSeen = make(map[uint32]bool)
+	a.mu.Unlock()
+}
+
+// check returns true if the tick of the instrument (exchange:tradingsymbol) is out of session. The exchange
+// timestamp of the tick is checked, falling back to the last trade time and the arrival time. The first tick of
+// a token is the snapshot kite sends on subscribing, also outside the session, and is not checked. A nil alarm,
+// e.g. of the tick bench, checks nothing
+func (a *tickSessionAlarm) check(tick kiteticker.Tick, instrument string) bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	snapshot := !a.snapshotSeen[tick.InstrumentToken]
+	a.snapshotSeen[tick.InstrumentToken] = true
+	a.mu.Unlock()
+	if snapshot {
+		return false
+	}
+
+	now := time.Now()
+	tickTime := tick.Timestamp.Time
+	if tickTime.IsZero() {
+		tickTime = tick.LastTradeTime.Time
+	}
+	if tickTime.IsZero() {
+		tickTime = now
+	}
+	exchange, _, _ := strings.Cut(instrument, ":")
+	if a.calendar.inSession(exchange, tickTime) {
+		return false
+	}
+
+	a.mu.Lock()
+	a.count[exchange]++
+	count := a.count[exchange]
+	alert := now.Sub(a.lastAlertAt[exchange]) >= a.cooldown
+	if alert {
+		a.lastAlertAt[exchange] = now
+		a.count[exchange] = 0
+	}
+	a.mu.Unlock()
+
+	if alert {
+		message := fmt.Sprintf("%d ticks on %s outside its session since the last alert, the latest %s timestamped %s at %s",
+			count, exchange, instrument, tickTime.In(MarketLocation).Format("2006-01-02 15:04:05"),
+			now.In(MarketLocation).Format("15:04:05"))
+		a.repo.Warn("OutOfSessionTick", message)
+		a.notifier.Notify(models.NotificationCategoryAlerts, "Out of session ticks on "+exchange, message)
+	}
+	return true
+}
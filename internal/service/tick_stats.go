@@ -0,0 +1,291 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// tickStatsPersistInterval is how often the in-memory tick statistics are written to the database
+const tickStatsPersistInterval = time.Minute
+
+// tickStat is the in-memory tick statistics of an instrument
+type tickStat struct {
+	models.TickStatsModel
+	minute          time.Time
+	minuteTicks     int64
+	lastMinuteTicks int64
+	dirty           bool
+}
+
+// TickStatsTracker keeps per instrument tick counters for the day in memory
+type TickStatsTracker struct {
+	mu      sync.Mutex
+	date    string
+	byToken map[uint32]*tickStat
+	// loaded is set once the statistics persisted earlier in the day are seeded, the day can roll over
+	// on a tick or a read before they are
+	loaded bool
+}
+
+var (
+	tickStatsTracker     *TickStatsTracker
+	tickStatsTrackerOnce sync.Once
+)
+
+// GetTickStatsTracker returns the process wide tick statistics tracker
+func GetTickStatsTracker() *TickStatsTracker {
+	tickStatsTrackerOnce.Do(func() {
+		tickStatsTracker = &TickStatsTracker{byToken: make(map[uint32]*tickStat)}
+	})
+	return tickStatsTracker
+}
+
+// rollover resets the counters when the market day changes, the caller must hold the lock
+func (t *TickStatsTracker) rollover(today string) {
+	if t.date != today {
+		t.date = today
+		t.byToken = make(map[uint32]*tickStat)
+		t.loaded = false
+	}
+}
+
+// Load seeds the tracker with the statistics persisted earlier in the day, it is a no-op once the day is loaded
+func (t *TickStatsTracker) Load(repo *repository.TickStatsRepository) error {
+	today := MarketToday()
+	t.mu.Lock()
+	loaded := t.date == today && t.loaded
+	t.mu.Unlock()
+	if loaded {
+		return nil
+	}
+
+	stats, err := repo.GetTickStats(today)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover(today)
+	if t.loaded {
+		return nil
+	}
+	for _, stat := range stats {
+		current, ok := t.byToken[stat.InstrumentToken]
+		if !ok {
+			t.byToken[stat.InstrumentToken] = &tickStat{TickStatsModel: stat}
+			continue
+		}
+		// ticks recorded before the load are added to the persisted ones
+		current.Ticks += stat.Ticks
+		current.OutOfSessionTicks += stat.OutOfSessionTicks
+		if !stat.FirstTickAt.IsZero() && stat.FirstTickAt.Before(current.FirstTickAt) {
+			current.FirstTickAt = stat.FirstTickAt
+		}
+		if stat.LastTickAt.After(current.LastTickAt) {
+			current.LastTickAt = stat.LastTickAt
+		}
+		current.MaxSpread = decimal.Max(current.MaxSpread, stat.MaxSpread)
+		current.dirty = true
+	}
+	t.loaded = true
+	return nil
+}
+
+// Record counts a tick of the instrument, and whether it was out of session
+func (t *TickStatsTracker) Record(tick kiteticker.Tick, instrument string, outOfSession bool) {
+	now := time.Now()
+	minute := now.Truncate(time.Minute)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover(MarketToday())
+
+	stat, ok := t.byToken[tick.InstrumentToken]
+	if !ok {
+		stat = &tickStat{TickStatsModel: models.TickStatsModel{
+			Date:            t.date,
+			InstrumentToken: tick.InstrumentToken,
+			FirstTickAt:     now,
+		}}
+		t.byToken[tick.InstrumentToken] = stat
+	}
+	stat.Instrument = instrument
+	stat.Ticks++
+	stat.LastTickAt = now
+	if !minute.Equal(stat.minute) {
+		stat.lastMinuteTicks = 0
+		if minute.Sub(stat.minute) == time.Minute {
+			stat.lastMinuteTicks = stat.minuteTicks
+		}
+		stat.minute = minute
+		stat.minuteTicks = 0
+	}
+	stat.minuteTicks++
+	if outOfSession {
+		stat.OutOfSessionTicks++
+	}
+
+	bid, ask := tick.Depth.Buy[0].Price, tick.Depth.Sell[0].Price
+	if bid > 0 && ask > 0 {
+		stat.MaxSpread = decimal.Max(stat.MaxSpread, decimal.NewFromFloat(ask).Sub(decimal.NewFromFloat(bid)))
+	}
+	stat.dirty = true
+}
+
+// Persist writes the statistics changed since the last persist to the database
+func (t *TickStatsTracker) Persist(repo *repository.TickStatsRepository) error {
+	t.mu.Lock()
+	stats := make([]models.TickStatsModel, 0)
+	for _, stat := range t.byToken {
+		if stat.dirty {
+			stats = append(stats, stat.TickStatsModel)
+			stat.dirty = false
+		}
+	}
+	t.mu.Unlock()
+
+	if err := repo.UpsertTickStats(stats); err != nil {
+		// mark the statistics dirty again so the next persist retries them
+		t.mu.Lock()
+		for _, s := range stats {
+			if stat, ok := t.byToken[s.InstrumentToken]; ok {
+				stat.dirty = true
+			}
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Get returns the statistics of the given tokens, or of all instruments when no tokens are given,
+// sorted by instrument
+func (t *TickStatsTracker) Get(tokens []uint32) []models.TickStats {
+	now := time.Now()
+	minute := now.Truncate(time.Minute)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover(MarketToday())
+
+	if len(tokens) == 0 {
+		for token := range t.byToken {
+			tokens = append(tokens, token)
+		}
+	}
+
+	stats := make([]models.TickStats, 0, len(tokens))
+	for _, token := range tokens {
+		stat, ok := t.byToken[token]
+		if !ok {
+			continue
+		}
+		result := models.TickStats{TickStatsModel: stat.TickStatsModel}
+		switch {
+		case minute.Equal(stat.minute):
+			result.LastMinuteTicks = stat.lastMinuteTicks
+		case minute.Sub(stat.minute) == time.Minute:
+			result.LastMinuteTicks = stat.minuteTicks
+		}
+		if minutes := now.Sub(stat.FirstTickAt).Minutes(); minutes >= 1 {
+			result.UpdatesPerMinute = math.Round(float64(stat.Ticks)/minutes*100) / 100
+		} else {
+			result.UpdatesPerMinute = float64(stat.Ticks)
+		}
+		if !stat.LastTickAt.IsZero() {
+			result.SecondsSinceLastTick = math.Round(now.Sub(stat.LastTickAt).Seconds()*10) / 10
+		}
+		stats = append(stats, result)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Instrument < stats[j].Instrument })
+	return stats
+}
+
+// persistTickStats periodically writes the tick statistics to the database
+func (s *TickerService) persistTickStats() {
+	ticker := time.NewTicker(tickStatsPersistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := GetTickStatsTracker().Persist(s.tickStatsRepo); err != nil {
+				s.repo.Error("persistTickStats", err.Error())
+			}
+		}
+	}
+}
+
+// GetTickStats returns the tick statistics of the given instruments, or of all instruments seen today when
+// none are given. A requested instrument without ticks today is returned with zero counters
+func (s *TickerService) GetTickStats(instruments []string) ([]models.TickStats, error) {
+	subscribed := s.subscribedInstruments()
+	tracker := GetTickStatsTracker()
+
+	if len(instruments) == 0 {
+		stats := tracker.Get(nil)
+		for i := range stats {
+			stats[i].Subscribed = subscribed[stats[i].Instrument]
+		}
+		return stats, nil
+	}
+
+	stats := make([]models.TickStats, 0, len(instruments))
+	for _, instrument := range instruments {
+		token, err := s.instrumentToken(instrument)
+		if err != nil {
+			return nil, err
+		}
+		stat := models.TickStats{TickStatsModel: models.TickStatsModel{
+			Date:            MarketToday(),
+			InstrumentToken: token,
+			Instrument:      instrument,
+		}}
+		if found := tracker.Get([]uint32{token}); len(found) > 0 {
+			stat = found[0]
+		}
+		stat.Subscribed = subscribed[instrument]
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// instrumentToken returns the token of an exchange:tradingsymbol
+func (s *TickerService) instrumentToken(instrument string) (uint32, error) {
+	found, err := s.instrumentService.GetInstrumentsInfoBySymbols([]string{instrument})
+	if err != nil {
+		return 0, err
+	}
+	if len(found) == 0 || found[0].InstrumentToken == 0 {
+		return 0, fmt.Errorf("instrument %s not found", instrument)
+	}
+	return found[0].InstrumentToken, nil
+}
+
+// subscribedInstruments returns the instruments subscribed by the running ticker
+func (s *TickerService) subscribedInstruments() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isRunning.Load() {
+		return make(map[string]bool)
+	}
+	s.instrumentsMu.RLock()
+	defer s.instrumentsMu.RUnlock()
+	subscribed := make(map[string]bool, len(s.instruments))
+	for _, instrument := range s.instruments {
+		subscribed[instrument] = true
+	}
+	return subscribed
+}
@@ -0,0 +1,261 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// tickJournalExt is the extension of the journal segment files
+const tickJournalExt = ".journal"
+
+// queuedTick is a received tick with the journal segment it was written to, 0 when the journal is disabled
+type queuedTick struct {
+	tick    kiteticker.Tick
+	segment uint64
+}
+
+// tickJournal is the write-ahead journal of the received ticks. Ticks are appended to the current segment
+// as they are received and synced to disk every sync interval. At a checkpoint, once everything handled has
+// been written to postgres, the current segment is sealed and the sealed segments whose ticks have all been
+// handled are deleted. The segments left by a crash are replayed when the ticker starts
+type tickJournal struct {
+	dir       string
+	mu        sync.Mutex
+	file      *os.File
+	writer    *bufio.Writer
+	encoder   *gob.Encoder
+	segment   uint64
+	written   map[uint64]int64
+	handled   map[uint64]int64
+	recovered []string
+}
+
+var (
+	tickJournalInstance *tickJournal
+	tickJournalOnce     sync.Once
+)
+
+// getTickJournal returns the process wide tick journal, nil when the journal is disabled or can not be opened
+func getTickJournal() *tickJournal {
+	tickJournalOnce.Do(func() {
+		cfg, err := config.Get()
+		if err != nil || cfg.TickerJournalDir == "" {
+			return
+		}
+		// validated when the config is loaded
+		syncMs, _ := strconv.Atoi(cfg.TickerJournalSyncMs)
+		journal, err := openTickJournal(cfg.TickerJournalDir)
+		if err != nil {
+			zaplogger.Error("failed to open the tick journal, ticks are not journaled", zaplogger.Fields{
+				"dir":   cfg.TickerJournalDir,
+				"error": err.Error(),
+			})
+			return
+		}
+		tickJournalInstance = journal
+		recovery.Go("ticker.journal.sync", func() {
+			journal.syncLoop(time.Duration(syncMs) * time.Millisecond)
+		})
+	})
+	return tickJournalInstance
+}
+
+// openTickJournal opens the journal in the directory, the segments already in it are kept for the replay
+// and new segments are numbered after them
+func openTickJournal(dir string) (*tickJournal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %v", err)
+	}
+	recovered, err := filepath.Glob(filepath.Join(dir, "*"+tickJournalExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list journal segments: %v", err)
+	}
+	sort.Strings(recovered)
+
+	j := &tickJournal{
+		dir:       dir,
+		written:   make(map[uint64]int64),
+		handled:   make(map[uint64]int64),
+		recovered: recovered,
+	}
+	for _, path := range recovered {
+		if segment, ok := tickJournalSegment(path); ok && segment > j.segment {
+			j.segment = segment
+		}
+	}
+	if err := j.rotate(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// tickJournalSegment returns the number of a segment file
+func tickJournalSegment(path string) (uint64, bool) {
+	segment, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), tickJournalExt), 10, 64)
+	return segment, err == nil
+}
+
+// segmentPath returns the file of a segment, zero padded so the files sort by number
+func (j *tickJournal) segmentPath(segment uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%020d%s", segment, tickJournalExt))
+}
+
+// rotate seals the current segment and opens the next one. The caller must hold mu unless the journal is being opened
+func (j *tickJournal) rotate() error {
+	if j.file != nil {
+		if err := j.syncLocked(); err != nil {
+			return err
+		}
+		j.file.Close()
+	}
+	j.segment++
+	file, err := os.OpenFile(j.segmentPath(j.segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		j.file = nil
+		return fmt.Errorf("failed to open journal segment: %v", err)
+	}
+	j.file = file
+	j.writer = bufio.NewWriter(file)
+	j.encoder = gob.NewEncoder(j.writer)
+	j.written[j.segment] = 0
+	return nil
+}
+
+// append writes the tick to the current segment and returns the segment, 0 if the tick could not be written
+func (j *tickJournal) append(tick kiteticker.Tick) uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return 0
+	}
+	if err := j.encoder.Encode(&tick); err != nil {
+		zaplogger.Error("failed to journal tick", zaplogger.Fields{"error": err.Error()})
+		return 0
+	}
+	j.written[j.segment]++
+	return j.segment
+}
+
+// discard forgets a journaled tick that was dropped before it was queued
+func (j *tickJournal) discard(segment uint64) {
+	j.done(segment)
+}
+
+// done records that a journaled tick of the segment has been handled, a nil journal is disabled
+func (j *tickJournal) done(segment uint64) {
+	if j == nil || segment == 0 {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.handled[segment]++
+}
+
+// checkpoint is called once every handled tick has been written to postgres, it seals the current segment
+// and deletes the sealed segments whose ticks have all been handled, a nil journal is disabled
+func (j *tickJournal) checkpoint() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return
+	}
+	if j.written[j.segment] > 0 {
+		if err := j.rotate(); err != nil {
+			zaplogger.Error("failed to rotate the tick journal", zaplogger.Fields{"error": err.Error()})
+			return
+		}
+	}
+	for segment, written := range j.written {
+		if segment == j.segment || j.handled[segment] < written {
+			continue
+		}
+		if err := os.Remove(j.segmentPath(segment)); err != nil && !os.IsNotExist(err) {
+			zaplogger.Error("failed to remove journal segment", zaplogger.Fields{"segment": segment, "error": err.Error()})
+			continue
+		}
+		delete(j.written, segment)
+		delete(j.handled, segment)
+	}
+}
+
+// syncLoop writes the buffered ticks to disk and syncs the segment every interval,
+// a crash loses at most the ticks of one interval
+func (j *tickJournal) syncLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		j.mu.Lock()
+		err := j.syncLocked()
+		j.mu.Unlock()
+		if err != nil {
+			zaplogger.Error("failed to sync the tick journal", zaplogger.Fields{"error": err.Error()})
+		}
+	}
+}
+
+// syncLocked flushes and syncs the current segment, the caller must hold mu
+func (j *tickJournal) syncLocked() error {
+	if j.file == nil || j.writer.Buffered() == 0 {
+		return nil
+	}
+	if err := j.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write journal segment: %v", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal segment: %v", err)
+	}
+	return nil
+}
+
+// takeRecovered returns the ticks of the segments left by the previous process, once. A segment cut short
+// by the crash is read up to its last complete tick. A segment last written before the given market day is
+// removed unread, the ticker data of that day has been truncated since
+func (j *tickJournal) takeRecovered(today string) ([]kiteticker.Tick, []string) {
+	j.mu.Lock()
+	recovered := j.recovered
+	j.recovered = nil
+	j.mu.Unlock()
+
+	var ticks []kiteticker.Tick
+	var segments []string
+	for _, path := range recovered {
+		if info, err := os.Stat(path); err == nil && info.ModTime().In(MarketLocation).Format("2006-01-02") != today {
+			zaplogger.Warn("dropped journal segment of an earlier day", zaplogger.Fields{"path": path, "modified": info.ModTime()})
+			os.Remove(path)
+			continue
+		}
+		segments = append(segments, path)
+		file, err := os.Open(path)
+		if err != nil {
+			zaplogger.Error("failed to open journal segment", zaplogger.Fields{"path": path, "error": err.Error()})
+			continue
+		}
+		decoder := gob.NewDecoder(bufio.NewReader(file))
+		for {
+			var tick kiteticker.Tick
+			if err := decoder.Decode(&tick); err != nil {
+				break
+			}
+			ticks = append(ticks, tick)
+		}
+		file.Close()
+	}
+	return ticks, segments
+}
@@ -0,0 +1,74 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+)
+
+// tickerInstrumentPreset is an instruments query subscribed by the ticker in the given mode
+type tickerInstrumentPreset struct {
+	exchange       string
+	tradingsymbol  string
+	name           string
+	expiry         string
+	strike         string
+	segment        string
+	instrumentType string
+	mode           string
+	description    string
+}
+
+// tickerInstrumentPresets are the instruments subscribed by the daily ticker instruments refresh
+var tickerInstrumentPresets = []tickerInstrumentPreset{
+	// index ticks carry OHLC and the net change in quote mode only, LTP mode sends the last price alone
+	{"", "", "", "", "", "INDICES", "", string(kiteticker.ModeQuote), "ALL:INDICES"}, // ALL:INDICES - ~144
+	{"NFO", "", "", "", "", "", "FUT", string(kiteticker.ModeQuote), "NFO:FUTURES"},  // NFO All Futures - ~553
+	{"MCX", "", "", "", "", "", "FUT", string(kiteticker.ModeQuote), "MCX:FUTURES"},  // MCX All Futures - ~118
+
+	// // NIFTY and BANKNIFTY Options for the next 3 months
+	// {"NFO", m0NFOFutFilter, "", "", "", "NFO ALL FUT - m0 [" + m0NFO + "]"},
+	// {"NFO", m0NFONiftyOptFilter, "", "", "", "NFO NIFTY OPT - m0 [" + m0NFO + "]"},
+	// {"NFO", m0NFOBankNiftyOptFilter, "", "", "", "NFO BANKNIFTY OPT - m0 [" + m0NFO + "]"},
+
+	// {"NFO", m1NFOFutFilter, "", "", "", "NFO ALL FUT - m1 [" + m1NFO + "]"},
+	// {"NFO", m1NFONiftyOptFilter, "", "", "", "NFO NIFTY OPT - m1 [" + m1NFO + "]"},
+	// {"NFO", m1NFOBankNiftyOptFilter, "", "", "", "NFO BANKNIFTY OPT - m1 [" + m1NFO + "]"},
+
+	// {"NFO", m2NFOFutFilter, "", "", "", "NFO ALL FUT - m2 [" + m2NFO + "]"},
+	// {"NFO", m2NFONiftyOptFilter, "", "", "", "NFO NIFTY OPT - m2 [" + m2NFO + "]"},
+	// {"NFO", m2NFOBankNiftyOptFilter, "", "", "", "NFO BANKNIFTY OPT - m2 [" + m2NFO + "]"},
+}
+
+// matches returns true if the instrument satisfies the filters of the preset, the same filters the
+// instruments query applies
+func (p tickerInstrumentPreset) matches(instrument models.InstrumentModel) bool {
+	if p.strike != "" {
+		strike, err := decimal.NewFromString(p.strike)
+		if err != nil || !strike.Equal(instrument.Strike) {
+			return false
+		}
+	}
+	return (p.exchange == "" || p.exchange == instrument.Exchange) &&
+		(p.tradingsymbol == "" || p.tradingsymbol == instrument.Tradingsymbol) &&
+		(p.name == "" || p.name == instrument.Name) &&
+		(p.expiry == "" || p.expiry == instrument.Expiry) &&
+		(p.segment == "" || p.segment == instrument.Segment) &&
+		(p.instrumentType == "" || p.instrumentType == instrument.InstrumentType)
+}
+
+// matchTickerInstrumentPresets groups the instruments by the mode of the first preset they match,
+// instruments matching no preset are left out
+func matchTickerInstrumentPresets(instruments []models.InstrumentModel) map[string][]models.InstrumentModel {
+	matched := make(map[string][]models.InstrumentModel)
+	for _, instrument := range instruments {
+		for _, preset := range tickerInstrumentPresets {
+			if preset.matches(instrument) {
+				matched[preset.mode] = append(matched[preset.mode], instrument)
+				break
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,45 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+
+	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// setPriorityTokens replaces the tokens whose ticks take the priority path
+func (s *TickerService) setPriorityTokens(tokens map[uint32]bool) {
+	s.priorityMu.Lock()
+	defer s.priorityMu.Unlock()
+	s.priorityTokens = tokens
+}
+
+// updatePriorityTokens adds the tokens to or removes them from the priority path
+func (s *TickerService) updatePriorityTokens(tokens []uint32, priority bool) {
+	s.priorityMu.Lock()
+	defer s.priorityMu.Unlock()
+	for _, token := range tokens {
+		if priority {
+			s.priorityTokens[token] = true
+		} else {
+			delete(s.priorityTokens, token)
+		}
+	}
+}
+
+// isPriorityToken returns true if the ticks of the token take the priority path
+func (s *TickerService) isPriorityToken(token uint32) bool {
+	s.priorityMu.RLock()
+	defer s.priorityMu.RUnlock()
+	return s.priorityTokens[token]
+}
+
+// handlePriorityTick processes a priority tick and writes it right away instead of with the next batch,
+// so readers of the ticker data see the price even while the shared channel has a backlog
+func (s *TickerService) handlePriorityTick(tick kiteticker.Tick, prevCloseData *[]models.PrevCloseModel) error {
+	var postgresData []models.TickerData
+	var indexData []models.IndexTickModel
+	s.handleTick(tick, &postgresData, &indexData, prevCloseData)
+	return errors.Join(s.flushData(&postgresData), s.flushIndexTicks(&indexData))
+}
@@ -4,65 +4,119 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math"
+	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	kiteticker "github.com/nsvirk/gokiteticker"
+	"github.com/nsvirk/moneybotsapi/internal/config"
 	"github.com/nsvirk/moneybotsapi/internal/models"
 	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/decimal"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/recovery"
 	"github.com/redis/go-redis/v9"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 const tickerReconnectMaxRetries = 10 // 10 retries
 
+// RedisCloseKey is the redis hash holding the last close of each instrument, keyed by exchange:tradingsymbol
+var RedisCloseKey = redisCloseKeyName
+
 // TickerService
 const (
 	batchSize                       = 1000
 	flushInterval                   = 100 * time.Microsecond
 	channelCapacity                 = 100000
+	priorityChannelCapacity         = 10000
 	channelCapacityWarningThreshold = 0.5 // 50% full
 	monitorInterval                 = 10 * time.Second
 )
 
 type UpsertQueriedInstrumentsResult struct {
-	Queried  int64
-	Inserted int64
-	Updated  int64
-	Total    int64
+	Queried     int64
+	Inserted    int64
+	Updated     int64
+	Total       int64
+	Blacklisted int64
 }
 
+// TickerService streams the ticks of the ticker instruments into the ticker data. Each run derives its own
+// context from ctx, runCancel cancels the goroutines of the current run and runWG waits for them.
+// runCancel is nil while no run is active and is guarded by mu. The instruments of the tokens are read by the
+// tick processing while subscriptions add to them and are guarded by instrumentsMu
 type TickerService struct {
 	repo              *repository.TickerRepository
-	redisClient       *redis.Client
+	prevCloseRepo     *repository.PrevCloseRepository
+	tickStatsRepo     *repository.TickStatsRepository
+	candleRepo        *repository.CandleRepository
+	candles           *candleBuilder
+	tickStatsOnce     sync.Once
+	redisClient       redis.UniversalClient
 	ticker            *kiteticker.Ticker
 	mu                sync.Mutex
-	isRunning         bool
+	isRunning         atomic.Bool
+	instrumentsMu     sync.RWMutex
 	instruments       map[uint32]string
-	tickChannel       chan kiteticker.Tick
+	prevCloseSeen     map[uint32]bool
+	tickChannel       chan queuedTick
+	priorityChannel   chan queuedTick
+	priorityMu        sync.RWMutex
+	priorityTokens    map[uint32]bool
 	ctx               context.Context
 	cancel            context.CancelFunc
+	runCancel         context.CancelFunc
+	runWG             sync.WaitGroup
 	instrumentService *InstrumentService
 	indexService      *IndexService
+	notifier          *NotifierService
+	userID            string
+	sessionExpired    bool
+	killSwitchRelease func()
+	dataStorage       string
+	sessionAlarm      *tickSessionAlarm
 }
 
 // NewService creates a new TickerService
-func NewTickerService(db *gorm.DB, redisClient *redis.Client) *TickerService {
+func NewTickerService(db *gorm.DB, redisClient redis.UniversalClient) *TickerService {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &TickerService{
+	s := &TickerService{
 		repo:              repository.NewTickerRepository(db),
+		prevCloseRepo:     repository.NewPrevCloseRepository(db),
+		tickStatsRepo:     repository.NewTickStatsRepository(db),
+		candleRepo:        repository.NewCandleRepository(db),
+		candles:           newCandleBuilder(),
 		redisClient:       redisClient,
-		isRunning:         false,
 		instruments:       make(map[uint32]string),
-		tickChannel:       make(chan kiteticker.Tick, channelCapacity),
+		prevCloseSeen:     make(map[uint32]bool),
+		tickChannel:       make(chan queuedTick, channelCapacity),
+		priorityChannel:   make(chan queuedTick, priorityChannelCapacity),
+		priorityTokens:    make(map[uint32]bool),
 		ctx:               ctx,
 		cancel:            cancel,
 		instrumentService: NewInstrumentService(db),
 		indexService:      NewIndexService(db),
+		notifier:          NewNotifierService(db),
+		dataStorage:       getTickerDataStorage(),
+	}
+	s.sessionAlarm = newTickSessionAlarm(s.repo, s.notifier)
+	GetInstrumentBlacklist().OnBlacklisted(s.dropInstruments)
+	return s
+}
+
+// getTickerDataStorage returns the configured storage format of the tick OHLC and depth
+func getTickerDataStorage() string {
+	cfg, err := config.Get()
+	if err != nil || cfg.TickerDataStorage == "" {
+		return models.TickerDataStorageJSONB
 	}
+	return cfg.TickerDataStorage
 }
 
 // Start starts the ticker service
@@ -70,10 +124,13 @@ func (s *TickerService) Start(userID, enctoken string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Stop the ticker if already runnin
-	if s.isRunning {
-		s.Stop(userID)
-		time.Sleep(2 * time.Second)
+	if err := GetKillSwitch().Check(userID); err != nil {
+		return err
+	}
+
+	// Stop the previous run first, its goroutines have returned once stop does
+	if s.runCancel != nil {
+		s.stop(s.userID)
 	}
 
 	// Get all ticker instruments
@@ -81,20 +138,66 @@ func (s *TickerService) Start(userID, enctoken string) error {
 	if err != nil {
 		return err
 	}
-	tickerInstrumentTokens := make([]uint32, len(tickerInstruments))
-	for i, tickerInstrument := range tickerInstruments {
+	tickerInstrumentTokens := make([]uint32, 0, len(tickerInstruments))
+	modeTokens := make(map[kiteticker.Mode][]uint32)
+	priorityTokens := make(map[uint32]bool)
+	blacklist := GetInstrumentBlacklist()
+	for _, tickerInstrument := range tickerInstruments {
 		instrumentToken := tickerInstrument.InstrumentToken
 		instrument := tickerInstrument.Instrument
-		tickerInstrumentTokens[i] = instrumentToken
-		s.instruments[instrumentToken] = instrument
+		// instruments blacklisted after they were added are left out
+		if blacklist.Contains(instrument) {
+			continue
+		}
+		tickerInstrumentTokens = append(tickerInstrumentTokens, instrumentToken)
+		s.setInstrument(instrumentToken, instrument)
+		mode := tickerMode(tickerInstrument.Mode)
+		modeTokens[mode] = append(modeTokens[mode], instrumentToken)
+		if tickerInstrument.Priority {
+			priorityTokens[instrumentToken] = true
+		}
 	}
+	s.setPriorityTokens(priorityTokens)
 
 	if len(tickerInstrumentTokens) == 0 {
 		return fmt.Errorf("no instruments to subscribe")
 	}
 
+	// The ticker connection holds at most the budgeted tokens, released again if the start fails
+	budget := GetTokenBudget()
+	budget.Set(models.TokenBudgetSourceTicker, nil)
+	if err := budget.Reserve(models.TokenBudgetSourceTicker, tickerInstrumentTokens); err != nil {
+		return err
+	}
+	// The goroutines and callbacks of this run stop when its context is cancelled
+	ctx, cancel := context.WithCancel(s.ctx)
+	started := false
+	defer func() {
+		if !started {
+			budget.Set(models.TokenBudgetSourceTicker, nil)
+			cancel()
+			if s.ticker != nil {
+				s.ticker.Stop()
+				s.ticker = nil
+			}
+		}
+	}()
+
+	// Previous closes are recorded once per instrument per run
+	s.prevCloseSeen = make(map[uint32]bool)
+	// the subscription snapshots of the run are not checked against the session calendar
+	s.sessionAlarm.reset()
+
+	// Continue the day's tick statistics from the last persisted counters
+	if err := GetTickStatsTracker().Load(s.tickStatsRepo); err != nil {
+		s.repo.Error("Start", err.Error())
+	}
+
+	// Write the ticks journaled but not written before the previous process stopped
+	s.replayTickJournal()
+
 	// Initialize ticker
-	if err := s.initializeTicker(userID, enctoken); err != nil {
+	if err := s.initializeTicker(ctx, userID, enctoken); err != nil {
 		return err
 	}
 
@@ -103,76 +206,173 @@ func (s *TickerService) Start(userID, enctoken string) error {
 		return err
 	}
 
-	// Set ticker mode to full
-	if err := s.ticker.SetMode(kiteticker.ModeFull, tickerInstrumentTokens); err != nil {
-		return err
+	// Set the ticker mode of each group of instruments
+	for mode, tokens := range modeTokens {
+		if err := s.ticker.SetMode(mode, tokens); err != nil {
+			return err
+		}
 	}
+	GetTickHub().SetTokens(modeTokens)
 
-	go s.processTicks()
-	go s.flushTicks()
-	go s.monitorTickerChannel()
+	s.startRun(ctx, cancel)
+	s.tickStatsOnce.Do(func() {
+		recovery.Go("ticker.persistTickStats", s.persistTickStats)
+	})
 
 	s.repo.Info("Start", "Ticker started successfully")
-	s.isRunning = true
+	s.isRunning.Store(true)
+	started = true
+	s.killSwitchRelease = GetKillSwitch().Register(userID, "ticker", func() {
+		s.Stop(userID)
+	})
 
 	return nil
 }
 
+// setInstrument sets the instrument (exchange:tradingsymbol) of the token
+func (s *TickerService) setInstrument(token uint32, instrument string) {
+	s.instrumentsMu.Lock()
+	defer s.instrumentsMu.Unlock()
+	s.instruments[token] = instrument
+}
+
+// lookupInstrument returns the instrument (exchange:tradingsymbol) of the token
+func (s *TickerService) lookupInstrument(token uint32) (string, bool) {
+	s.instrumentsMu.RLock()
+	defer s.instrumentsMu.RUnlock()
+	instrument, ok := s.instruments[token]
+	return instrument, ok
+}
+
+// startRun starts the goroutines of the run with its context, stop cancels and waits for them.
+// The caller must hold mu
+func (s *TickerService) startRun(ctx context.Context, cancel context.CancelFunc) {
+	s.runCancel = cancel
+	s.goRun(ctx, "ticker.processTicks", s.processTicks)
+	s.goRun(ctx, "ticker.flushTicks", s.flushTicks)
+	s.goRun(ctx, "ticker.monitorTickerChannel", s.monitorTickerChannel)
+}
+
+// goRun runs fn in a goroutine of the run with the context, stop waits for it to return. A panic is reported
+// and fn is started again after a second until the run is cancelled
+func (s *TickerService) goRun(ctx context.Context, source string, fn func(context.Context)) {
+	s.runWG.Add(1)
+	go func() {
+		defer s.runWG.Done()
+		for !recovery.Run(source, func() { fn(ctx) }) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
 // Stop stops the ticker service
 func (s *TickerService) Stop(userID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.isRunning {
+	if s.runCancel == nil {
 		return fmt.Errorf("ticker is not running")
 	}
+	return s.stop(userID)
+}
 
-	// Get all ticker instruments
-	tickerInstruments, err := s.repo.GetTickerInstruments(userID)
-	if err != nil {
-		return err
+// stop cancels the run and closes the ticker, it returns once the goroutines of the run have returned.
+// The caller must hold mu
+func (s *TickerService) stop(userID string) error {
+	// Cancel the run and wait for its goroutines first so nothing of the run is left when the ticker closes,
+	// processTicks flushes the ticks it buffered before returning
+	s.runCancel()
+	s.runCancel = nil
+	s.runWG.Wait()
+
+	if s.ticker != nil {
+		// Unsubscribe from instruments, the ticker is closed even if they can't be read
+		tickerInstruments, err := s.repo.GetTickerInstruments(userID)
+		if err != nil {
+			s.repo.Error("Stop", err.Error())
+		} else {
+			tickerInstrumentTokens := make([]uint32, len(tickerInstruments))
+			for i, tickerInstrument := range tickerInstruments {
+				tickerInstrumentTokens[i] = tickerInstrument.InstrumentToken
+			}
+			// the close message follows the unsubscribe on the same connection
+			s.ticker.Unsubscribe(tickerInstrumentTokens)
+		}
+
+		// Stop the ticker
+		s.ticker.Close()
+		s.ticker.Stop()
+		s.ticker = nil
 	}
-	tickerInstrumentTokens := make([]uint32, len(tickerInstruments))
-	for i, tickerInstrument := range tickerInstruments {
-		instrumentToken := tickerInstrument.InstrumentToken
-		instrument := tickerInstrument.Instrument
-		tickerInstrumentTokens[i] = instrumentToken
-		s.instruments[instrumentToken] = instrument
+	s.isRunning.Store(false)
+	GetTokenBudget().Set(models.TokenBudgetSourceTicker, nil)
+	GetTickHub().SetTokens(nil)
+	if s.killSwitchRelease != nil {
+		s.killSwitchRelease()
+		s.killSwitchRelease = nil
 	}
 
-	// Unsubscribe from instruments
-	s.ticker.Unsubscribe(tickerInstrumentTokens)
-	time.Sleep(1 * time.Second)
-
-	// Stop the ticker
-	s.ticker.Close()
-	s.ticker.Stop()
-	s.ticker = nil
-	s.isRunning = false
-
-	// s.cancel() // if this is enable then the ticker doesnt run on next start
+	if err := GetTickStatsTracker().Persist(s.tickStatsRepo); err != nil {
+		s.repo.Error("Stop", err.Error())
+	}
 
 	s.repo.Info("Stop", "Ticker stopped successfully")
+	s.publishTickerEvent("stopped", "")
 	return nil
 }
 
+// publishTickerEvent sends a ticker state change to the admin console clients
+func (s *TickerService) publishTickerEvent(state, details string) {
+	GetAdminEvents().Publish(AdminEventTicker, map[string]interface{}{
+		"user_id": s.userID,
+		"state":   state,
+		"details": details,
+	})
+}
+
 func (s *TickerService) Restart(userID, enctoken string) error {
 	return s.Start(userID, enctoken)
 }
 
+// tickerMode returns the kite ticker mode of a ticker instrument, instruments without a mode get full depth
+func tickerMode(mode string) kiteticker.Mode {
+	switch kiteticker.Mode(mode) {
+	case kiteticker.ModeLTP, kiteticker.ModeQuote:
+		return kiteticker.Mode(mode)
+	default:
+		return kiteticker.ModeFull
+	}
+}
+
+// IsValidTickerMode returns true if the mode is a kite ticker mode
+func IsValidTickerMode(mode string) bool {
+	switch kiteticker.Mode(mode) {
+	case kiteticker.ModeLTP, kiteticker.ModeQuote, kiteticker.ModeFull:
+		return true
+	default:
+		return false
+	}
+}
+
 // Status returns the current status of the ticker
 func (s *TickerService) Status() bool {
-	return s.isRunning
+	return s.isRunning.Load()
 }
 
-// initializeTicker initializes the ticker
-func (s *TickerService) initializeTicker(userID, enctoken string) error {
+// initializeTicker initializes the ticker, it serves until the context of the run is cancelled
+func (s *TickerService) initializeTicker(ctx context.Context, userID, enctoken string) error {
 	s.ticker = kiteticker.New(userID, enctoken)
+	s.userID = userID
+	s.sessionExpired = false
 
 	s.SetReconnectMaxRetries(tickerReconnectMaxRetries)
-	s.setupTickerCallbacks()
+	s.setupTickerCallbacks(ctx)
 
-	go s.ticker.Serve()
+	go s.ticker.ServeWithContext(ctx)
 
 	timeout := time.After(10 * time.Second)
 	ticker := time.NewTicker(100 * time.Millisecond)
@@ -181,7 +381,7 @@ func (s *TickerService) initializeTicker(userID, enctoken string) error {
 	for {
 		select {
 		case <-ticker.C:
-			if s.isRunning {
+			if s.isRunning.Load() {
 				return nil
 			}
 		case <-timeout:
@@ -193,65 +393,257 @@ func (s *TickerService) SetReconnectMaxRetries(retries int) {
 	s.ticker.SetReconnectMaxRetries(retries)
 }
 
-// setupTickerCallbacks sets up the ticker callbacks
-func (s *TickerService) setupTickerCallbacks() {
+// setupTickerCallbacks sets up the ticker callbacks, callbacks of a cancelled run no longer change the service
+func (s *TickerService) setupTickerCallbacks(ctx context.Context) {
 	s.ticker.OnTick(func(tick kiteticker.Tick) {
 		// fmt.Println(tick)
-		s.tickChannel <- tick
+		// the prices are rounded to the precision of the instrument before they are published, journaled and stored
+		tick = getTickNormalizer().normalize(tick)
+		GetTickHub().Publish(tick)
+		// ticks of priority instruments skip the backlog of the shared channel
+		channel := s.tickChannel
+		if s.isPriorityToken(tick.InstrumentToken) {
+			channel = s.priorityChannel
+		}
+		// the tick is journaled before it is queued, until it is written to postgres it only lives in memory
+		queued := queuedTick{tick: tick}
+		journal := getTickJournal()
+		if journal != nil {
+			queued.segment = journal.append(tick)
+		}
+		select {
+		case channel <- queued:
+		case <-ctx.Done():
+			if journal != nil {
+				journal.discard(queued.segment)
+			}
+		}
 	})
 
 	s.ticker.OnConnect(func() {
 		s.repo.Info("OnConnect", "Connected to ticker")
-		s.isRunning = true
+		if ctx.Err() == nil {
+			s.isRunning.Store(true)
+			s.publishTickerEvent("connected", "")
+		}
 	})
 
 	s.ticker.OnError(func(err error) {
 		s.repo.Error("OnError", err.Error())
+		// kite rejects the websocket handshake once the enctoken has expired, notify once per start
+		if strings.Contains(err.Error(), "bad handshake") && !s.sessionExpired {
+			s.sessionExpired = true
+			s.notifier.NotifyUser(s.userID, models.NotificationCategorySessionExpiry, "Ticker session expired",
+				"The ticker connection for "+s.userID+" was rejected, the session has to be regenerated")
+		}
 	})
 
 	s.ticker.OnClose(func(code int, reason string) {
 		s.repo.Warn("OnClose", fmt.Sprintf("Closed with code %d: %s", code, reason))
-		s.isRunning = false
+		if ctx.Err() == nil {
+			s.isRunning.Store(false)
+			s.publishTickerEvent("closed", fmt.Sprintf("code %d: %s", code, reason))
+		}
 	})
 
 	s.ticker.OnReconnect(func(attempt int, delay time.Duration) {
 		s.repo.Info("OnReconnect", fmt.Sprintf("Reconnecting attempt %d with delay %v", attempt, delay))
+		s.publishTickerEvent("reconnecting", fmt.Sprintf("attempt %d with delay %v", attempt, delay))
 	})
 
 	s.ticker.OnNoReconnect(func(attempt int) {
 		s.repo.Fatal("OnNoReconnect", fmt.Sprintf("No reconnect after %d attempts", attempt))
+		s.publishTickerEvent("disconnected", fmt.Sprintf("no reconnect after %d attempts", attempt))
 	})
 }
 
-func (s *TickerService) processTicks() {
+// processTicks handles the ticks of the run, the buffered ticks are flushed when the run is cancelled.
+// The tick journal is checkpointed whenever everything handled has been flushed
+func (s *TickerService) processTicks(ctx context.Context) {
+	journal := getTickJournal()
 	var postgresData []models.TickerData
+	var indexData []models.IndexTickModel
+	var prevCloseData []models.PrevCloseModel
 	ticker := time.NewTicker(flushInterval)
 	defer ticker.Stop()
+	candleTicker := time.NewTicker(candleFlushInterval)
+	defer candleTicker.Stop()
+	// once a flush failed the journal is no longer checkpointed, the ticks of the failed batch are only in the
+	// journal segments and the next start replays them
+	flushFailed := false
 
 	for {
+		// priority ticks are handled before any queued tick of the shared channel
 		select {
-		case <-s.ctx.Done():
+		case queued := <-s.priorityChannel:
+			flushFailed = s.handlePriorityTick(queued.tick, &prevCloseData) != nil || flushFailed
+			journal.done(queued.segment)
+			continue
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			flushFailed = s.flushBatches(&postgresData, &indexData, &prevCloseData, true) != nil || flushFailed
+			if !flushFailed {
+				journal.checkpoint()
+			}
 			return
-		case tick := <-s.tickChannel:
-			s.processTick(tick, &postgresData)
+		case queued := <-s.priorityChannel:
+			flushFailed = s.handlePriorityTick(queued.tick, &prevCloseData) != nil || flushFailed
+			journal.done(queued.segment)
+		case queued := <-s.tickChannel:
+			s.handleTick(queued.tick, &postgresData, &indexData, &prevCloseData)
+			journal.done(queued.segment)
 		case <-ticker.C:
-			s.flushData(&postgresData)
+			flushFailed = s.flushBatches(&postgresData, &indexData, &prevCloseData, false) != nil || flushFailed
+		case <-candleTicker.C:
+			// the candles are the last state flushed, everything handled is now in postgres
+			flushFailed = s.flushBatches(&postgresData, &indexData, &prevCloseData, true) != nil || flushFailed
+			if !flushFailed {
+				journal.checkpoint()
+			}
 		}
 
 		if len(postgresData) >= batchSize {
-			s.flushData(&postgresData)
+			flushFailed = s.flushData(&postgresData) != nil || flushFailed
+		}
+		if len(indexData) >= batchSize {
+			flushFailed = s.flushIndexTicks(&indexData) != nil || flushFailed
+		}
+		if len(prevCloseData) >= batchSize {
+			flushFailed = s.flushPrevCloses(&prevCloseData) != nil || flushFailed
+		}
+	}
+}
+
+// flushBatches flushes the ticker data, index ticks and previous closes, and the candles with candles,
+// every batch is flushed even if one fails and the errors are returned together
+func (s *TickerService) flushBatches(postgresData *[]models.TickerData, indexData *[]models.IndexTickModel, prevCloseData *[]models.PrevCloseModel, candles bool) error {
+	errs := []error{s.flushData(postgresData), s.flushIndexTicks(indexData), s.flushPrevCloses(prevCloseData)}
+	if candles {
+		errs = append(errs, s.flushCandles())
+	}
+	return errors.Join(errs...)
+}
+
+// handleTick processes a single tick, a panic only drops the tick instead of stopping the processing.
+// Index ticks carry no depth or open interest and are stored apart from the ticker data
+func (s *TickerService) handleTick(tick kiteticker.Tick, postgresData *[]models.TickerData, indexData *[]models.IndexTickModel, prevCloseData *[]models.PrevCloseModel) {
+	defer recovery.Guard("ticker.processTick")
+	if tick.IsIndex {
+		s.processIndexTick(tick, indexData)
+	} else {
+		s.processTick(tick, postgresData)
+	}
+	s.processPrevClose(tick, prevCloseData)
+	if instrument, ok := s.lookupInstrument(tick.InstrumentToken); ok {
+		s.candles.update(tick, instrument)
+	}
+}
+
+// replayTickJournal handles the ticks of the journal segments left by the previous process and writes them
+// to postgres, the segments are removed once written
+func (s *TickerService) replayTickJournal() {
+	journal := getTickJournal()
+	if journal == nil {
+		return
+	}
+	// the ticks of an earlier day do not belong in the ticker data truncated for today
+	today := MarketToday()
+	ticks, segments := journal.takeRecovered(today)
+	if len(segments) == 0 {
+		return
+	}
+
+	var postgresData []models.TickerData
+	var indexData []models.IndexTickModel
+	var prevCloseData []models.PrevCloseModel
+	stale := 0
+	for _, tick := range ticks {
+		// ticks of the ltp mode carry no timestamp and are dated by their segment
+		if !tick.Timestamp.Time.IsZero() && tick.Timestamp.Time.In(MarketLocation).Format("2006-01-02") != today {
+			stale++
+			continue
+		}
+		s.handleTick(tick, &postgresData, &indexData, &prevCloseData)
+	}
+	// the segments are kept for the next start when the ticks can not be written
+	if err := s.flushBatches(&postgresData, &indexData, &prevCloseData, true); err != nil {
+		s.repo.Error("replayTickJournal", fmt.Sprintf("Journaled ticks kept for the next start: %v", err))
+		return
+	}
+	if stale > 0 {
+		s.repo.Warn("replayTickJournal", fmt.Sprintf("Dropped %d journaled ticks not of %s", stale, today))
+	}
+
+	for _, segment := range segments {
+		if err := os.Remove(segment); err != nil {
+			s.repo.Error("replayTickJournal", err.Error())
 		}
 	}
+	s.repo.Info("replayTickJournal", fmt.Sprintf("Replayed %d journaled ticks of %d segments", len(ticks), len(segments)))
+}
+
+// flushCandles writes the closed and changed 1 minute candles to postgres
+func (s *TickerService) flushCandles() error {
+	if err := s.candleRepo.UpsertCandles(s.candles.flush()); err != nil {
+		s.repo.Error("flushCandles", fmt.Sprintf("Failed to save candles to Postgres: %v", err))
+		return err
+	}
+	return nil
+}
+
+// processPrevClose records the previous close carried in the first tick of each instrument,
+// kite sends the previous session's close as the OHLC close
+func (s *TickerService) processPrevClose(tick kiteticker.Tick, prevCloseData *[]models.PrevCloseModel) {
+	if s.prevCloseSeen[tick.InstrumentToken] || tick.OHLC.Close == 0 {
+		return
+	}
+	instrument, ok := s.lookupInstrument(tick.InstrumentToken)
+	if !ok {
+		return
+	}
+	s.prevCloseSeen[tick.InstrumentToken] = true
+
+	date := tick.Timestamp.Time
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	*prevCloseData = append(*prevCloseData, models.PrevCloseModel{
+		Instrument:      instrument,
+		Date:            date.In(MarketLocation).Format("2006-01-02"),
+		InstrumentToken: tick.InstrumentToken,
+		InstrumentID:    GetInstrumentCache().GetInstrumentID(tick.InstrumentToken),
+		PrevClose:       decimal.NewFromFloat(tick.OHLC.Close),
+		Source:          models.PrevCloseSourceTick,
+	})
+}
+
+// flushPrevCloses flushes the previous closes to postgres
+func (s *TickerService) flushPrevCloses(prevCloseData *[]models.PrevCloseModel) error {
+	if len(*prevCloseData) == 0 {
+		return nil
+	}
+	_, err := s.prevCloseRepo.InsertPrevCloses(*prevCloseData)
+	if err != nil {
+		s.repo.Error("flushPrevCloses", fmt.Sprintf("Failed to save previous closes to Postgres: %v", err))
+	}
+	*prevCloseData = (*prevCloseData)[:0]
+	return err
 }
 
 // processTick processes the tick
 func (s *TickerService) processTick(tick kiteticker.Tick, postgresData *[]models.TickerData) {
 
-	instrument, ok := s.instruments[tick.InstrumentToken]
+	instrument, ok := s.lookupInstrument(tick.InstrumentToken)
 	if !ok {
 		s.repo.Error("processTick", fmt.Sprintf("instrument not found for token %d", tick.InstrumentToken))
 		return
 	}
+	outOfSession := s.sessionAlarm.check(tick, instrument)
+	GetTickStatsTracker().Record(tick, instrument, outOfSession)
 
 	// convert kiteticker.Tick to JSON
 	// tickJson, err := json.Marshal(tick)
@@ -259,23 +651,11 @@ func (s *TickerService) processTick(tick kiteticker.Tick, postgresData *[]models
 	// 	s.repo.LogTickerEvent("processTick", fmt.Sprintf("error marshaling tick to JSON: %v", tick.InstrumentToken))
 	// }
 
-	tickOHLCJson, err := json.Marshal(tick.OHLC)
-	if err != nil {
-		s.repo.Error("processTick", fmt.Sprintf("error marshaling tick OHLC to JSON: %v", tick.InstrumentToken))
-
-	}
-	tickDepthJson, err := json.Marshal(tick.Depth)
-	if err != nil {
-		s.repo.Error("processTick", fmt.Sprintf("error marshaling tick Depth to JSON: %v", tick.InstrumentToken))
-	}
-
-	// Round NetChange to 2 decimal points
-	roundedNetChange := math.Round(tick.NetChange*100) / 100
-
 	// convert kiteticker.Tick type to ticker.TickerData tyep
 	tickerData := models.TickerData{
 		// custom
-		Instrument: instrument,
+		Instrument:   instrument,
+		InstrumentID: GetInstrumentCache().GetInstrumentID(tick.InstrumentToken),
 		// from kiteticker.Tick
 		Mode:               tick.Mode,
 		InstrumentToken:    tick.InstrumentToken,
@@ -283,22 +663,45 @@ func (s *TickerService) processTick(tick kiteticker.Tick, postgresData *[]models
 		IsIndex:            tick.IsIndex,
 		Timestamp:          tick.Timestamp.Time,
 		LastTradeTime:      tick.LastTradeTime.Time,
-		LastPrice:          tick.LastPrice,
+		LastPrice:          decimal.NewFromFloat(tick.LastPrice),
 		LastTradedQuantity: tick.LastTradedQuantity,
 		TotalBuyQuantity:   tick.TotalBuyQuantity,
 		TotalSellQuantity:  tick.TotalSellQuantity,
 		VolumeTraded:       tick.VolumeTraded,
 		// TotalBuy:           tick.TotalBuy,
 		// TotalSell:          tick.TotalSell,
-		AverageTradePrice: tick.AverageTradePrice,
+		AverageTradePrice: decimal.NewFromFloat(tick.AverageTradePrice),
 		OI:                tick.OI,
 		OIDayHigh:         tick.OIDayHigh,
 		OIDayLow:          tick.OIDayLow,
-		NetChange:         roundedNetChange,
-		OHLC:              tickOHLCJson,
-		Depth:             tickDepthJson,
+		NetChange:         decimal.NewFromFloat(tick.NetChange),
 		// Tick:               tickJson,
-		UpdatedAt: time.Now(),
+		OutOfSession: outOfSession,
+		UpdatedAt:    time.Now(),
+	}
+
+	// OHLC and depth as JSONB blobs, typed columns or both
+	if s.dataStorage != models.TickerDataStorageColumns {
+		tickOHLCJson, err := json.Marshal(tick.OHLC)
+		if err != nil {
+			s.repo.Error("processTick", fmt.Sprintf("error marshaling tick OHLC to JSON: %v", tick.InstrumentToken))
+		}
+		tickDepthJson, err := json.Marshal(tick.Depth)
+		if err != nil {
+			s.repo.Error("processTick", fmt.Sprintf("error marshaling tick Depth to JSON: %v", tick.InstrumentToken))
+		}
+		tickerData.OHLC = tickOHLCJson
+		tickerData.Depth = tickDepthJson
+	}
+	if s.dataStorage != models.TickerDataStorageJSONB {
+		tickerData.Open = decimal.NewFromFloat(tick.OHLC.Open)
+		tickerData.High = decimal.NewFromFloat(tick.OHLC.High)
+		tickerData.Low = decimal.NewFromFloat(tick.OHLC.Low)
+		tickerData.Close = decimal.NewFromFloat(tick.OHLC.Close)
+		tickerData.BidPrice = decimal.NewFromFloat(tick.Depth.Buy[0].Price)
+		tickerData.BidQuantity = tick.Depth.Buy[0].Quantity
+		tickerData.AskPrice = decimal.NewFromFloat(tick.Depth.Sell[0].Price)
+		tickerData.AskQuantity = tick.Depth.Sell[0].Quantity
 	}
 
 	// ---- SAVE TO POSTGRES -----------------------------------------
@@ -306,25 +709,67 @@ func (s *TickerService) processTick(tick kiteticker.Tick, postgresData *[]models
 	*postgresData = append(*postgresData, tickerData)
 }
 
-// flushData flushes the data to postgres
-func (s *TickerService) flushData(postgresData *[]models.TickerData) {
+// processIndexTick processes the tick of an index
+func (s *TickerService) processIndexTick(tick kiteticker.Tick, indexData *[]models.IndexTickModel) {
+	instrument, ok := s.lookupInstrument(tick.InstrumentToken)
+	if !ok {
+		s.repo.Error("processIndexTick", fmt.Sprintf("instrument not found for token %d", tick.InstrumentToken))
+		return
+	}
+	outOfSession := s.sessionAlarm.check(tick, instrument)
+	GetTickStatsTracker().Record(tick, instrument, outOfSession)
+
+	*indexData = append(*indexData, models.IndexTickModel{
+		Instrument:      instrument,
+		InstrumentToken: tick.InstrumentToken,
+		InstrumentID:    GetInstrumentCache().GetInstrumentID(tick.InstrumentToken),
+		Mode:            tick.Mode,
+		Timestamp:       tick.Timestamp.Time,
+		LastPrice:       decimal.NewFromFloat(tick.LastPrice),
+		Open:            decimal.NewFromFloat(tick.OHLC.Open),
+		High:            decimal.NewFromFloat(tick.OHLC.High),
+		Low:             decimal.NewFromFloat(tick.OHLC.Low),
+		Close:           decimal.NewFromFloat(tick.OHLC.Close),
+		NetChange:       decimal.NewFromFloat(tick.NetChange),
+		OutOfSession:    outOfSession,
+		UpdatedAt:       time.Now(),
+	})
+}
 
-	if len(*postgresData) > 0 {
-		if err := s.repo.UpsertTickerData(*postgresData); err != nil {
-			s.repo.Error("flushData", fmt.Sprintf("Failed to save ticks to Postgres: %v", err))
-		}
-		*postgresData = (*postgresData)[:0]
+// flushIndexTicks flushes the index ticks to postgres
+func (s *TickerService) flushIndexTicks(indexData *[]models.IndexTickModel) error {
+	if len(*indexData) == 0 {
+		return nil
+	}
+	err := s.repo.UpsertIndexTicks(*indexData)
+	if err != nil {
+		s.repo.Error("flushIndexTicks", fmt.Sprintf("Failed to save index ticks to Postgres: %v", err))
+	}
+	*indexData = (*indexData)[:0]
+	return err
+}
+
+// flushData flushes the data to postgres
+func (s *TickerService) flushData(postgresData *[]models.TickerData) error {
+	if len(*postgresData) == 0 {
+		return nil
+	}
+	err := s.repo.UpsertTickerData(*postgresData)
+	if err != nil {
+		s.repo.Error("flushData", fmt.Sprintf("Failed to save ticks to Postgres: %v", err))
 	}
+	*postgresData = (*postgresData)[:0]
+	return err
 }
 
 // flushTicks flushes the ticks to postgres
-func (s *TickerService) flushTicks() {
+func (s *TickerService) flushTicks(ctx context.Context) {
 	ticker := time.NewTicker(flushInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-s.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			s.flushData(&[]models.TickerData{})
@@ -332,13 +777,43 @@ func (s *TickerService) flushTicks() {
 	}
 }
 
+// PrimeRedisCloses copies the latest previous close of every instrument up to today into redis. Before the
+// ticker starts these are the official closes the close reconciliation stored for today
+func (s *TickerService) PrimeRedisCloses() (int, error) {
+	prevCloses, err := s.prevCloseRepo.GetAllLatestPrevCloses(MarketToday())
+	if err != nil {
+		return 0, err
+	}
+	if len(prevCloses) == 0 {
+		return 0, nil
+	}
+
+	closes := make(map[string]interface{}, len(prevCloses))
+	for _, prevClose := range prevCloses {
+		closes[prevClose.Instrument] = prevClose.PrevClose.String()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipe := s.redisClient.TxPipeline()
+	pipe.Del(ctx, RedisCloseKey)
+	pipe.HSet(ctx, RedisCloseKey, closes)
+	pipe.Expire(ctx, RedisCloseKey, redisCloseTTL())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to prime redis closes: %v", err)
+	}
+
+	return len(closes), nil
+}
+
 // TruncateTickerData truncates the ticker data
 func (s *TickerService) TruncateTickerData() error {
 	return s.repo.TruncateTickerData()
 }
 
-// AddTickerInstruments adds the ticker instruments
-func (s *TickerService) AddTickerInstruments(userID string, instrumentsStr []string) (map[string]interface{}, error) {
+// AddTickerInstruments adds the ticker instruments, a blank mode keeps the mode of instruments already added
+func (s *TickerService) AddTickerInstruments(userID string, instrumentsStr []string, mode string) (map[string]interface{}, error) {
 
 	// get instruments using instrument service
 	instruments, err := s.instrumentService.GetInstrumentsInfoBySymbols(instrumentsStr)
@@ -354,8 +829,11 @@ func (s *TickerService) AddTickerInstruments(userID string, instrumentsStr []str
 		}
 	}
 
+	// blacklisted instruments are never subscribed
+	instruments, blacklistedInstruments := GetInstrumentBlacklist().Filter(instruments)
+
 	// upsert the instruments
-	insertedCount, updatedCount, err := s.repo.UpsertTickerInstruments(userID, instruments)
+	insertedCount, updatedCount, err := s.repo.UpsertTickerInstruments(userID, instruments, mode)
 	if err != nil {
 		return nil, err
 	}
@@ -376,6 +854,11 @@ func (s *TickerService) AddTickerInstruments(userID string, instrumentsStr []str
 		response["missing_instruments"] = missingInstruments
 	}
 
+	if len(blacklistedInstruments) > 0 {
+		response["blacklisted"] = len(blacklistedInstruments)
+		response["blacklisted_instruments"] = blacklistedInstruments
+	}
+
 	return response, nil
 }
 
@@ -393,13 +876,69 @@ func (s *TickerService) GetTickerInstrumentCount(userID string) (int64, error) {
 	return s.repo.GetTickerInstrumentCount(userID)
 }
 
+// GetTickerLogs gets the ticker logs matching the filter, newest first
+func (s *TickerService) GetTickerLogs(filter models.TickerLogFilter) ([]models.TickerLog, error) {
+	return s.repo.GetTickerLogs(filter)
+}
+
 // TruncateTickerInstruments truncates the ticker instruments
 func (s *TickerService) TruncateTickerInstruments() (int64, error) {
 	return s.repo.TruncateTickerInstruments()
 }
 
-// UpsertQueriedInstruments upserts the queried instruments
-func (s *TickerService) UpsertQueriedInstruments(userID, exchange, tradingsymbol, name, expiry, strike, segment, instrumentType string) (UpsertQueriedInstrumentsResult, error) {
+// DeleteUnpinnedTickerInstruments deletes the ticker instruments which are not pinned
+func (s *TickerService) DeleteUnpinnedTickerInstruments() (int64, error) {
+	return s.repo.DeleteUnpinnedTickerInstruments()
+}
+
+// PinTickerInstruments pins the ticker instruments so they survive the daily ticker instruments refresh
+func (s *TickerService) PinTickerInstruments(userID string, instruments []string, metadata datatypes.JSON) (int64, error) {
+	return s.repo.SetTickerInstrumentsPinned(userID, instruments, true, metadata)
+}
+
+// SetTickerInstrumentsPriority marks the instruments as priority or not, a running ticker applies it right away
+func (s *TickerService) SetTickerInstrumentsPriority(userID string, instruments []string, priority bool) (int64, error) {
+	updated, err := s.repo.SetTickerInstrumentsPriority(userID, instruments, priority)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	running := s.isRunning.Load() && s.userID == userID
+	tokens := make([]uint32, 0, len(instruments))
+	if running {
+		wanted := make(map[string]bool, len(instruments))
+		for _, instrument := range instruments {
+			wanted[instrument] = true
+		}
+		s.instrumentsMu.RLock()
+		for token, instrument := range s.instruments {
+			if wanted[instrument] {
+				tokens = append(tokens, token)
+			}
+		}
+		s.instrumentsMu.RUnlock()
+	}
+	s.mu.Unlock()
+
+	if running {
+		s.updatePriorityTokens(tokens, priority)
+	}
+	return updated, nil
+}
+
+// SetTickerInstrumentsMode sets the ticker mode of the instruments, it applies on the next ticker start
+func (s *TickerService) SetTickerInstrumentsMode(userID string, instruments []string, mode string) (int64, error) {
+	return s.repo.SetTickerInstrumentsMode(userID, instruments, mode)
+}
+
+// UnpinTickerInstruments unpins the ticker instruments
+func (s *TickerService) UnpinTickerInstruments(userID string, instruments []string) (int64, error) {
+	return s.repo.SetTickerInstrumentsPinned(userID, instruments, false, nil)
+}
+
+// UpsertQueriedInstruments upserts the queried instruments with the given ticker mode
+func (s *TickerService) UpsertQueriedInstruments(userID, exchange, tradingsymbol, name, expiry, strike, segment, instrumentType, mode string) (UpsertQueriedInstrumentsResult, error) {
 
 	var result UpsertQueriedInstrumentsResult
 	// query instrumetns using instruments service
@@ -416,41 +955,184 @@ func (s *TickerService) UpsertQueriedInstruments(userID, exchange, tradingsymbol
 	if err != nil {
 		return result, err
 	}
+	// blacklisted instruments are never subscribed
+	allowedInstruments, blacklistedInstruments := GetInstrumentBlacklist().Filter(queriedInstruments)
 	// upsert the queried instruments
-	insertedCount, updatedCount, err := s.repo.UpsertTickerInstruments(userID, queriedInstruments)
+	insertedCount, updatedCount, err := s.repo.UpsertTickerInstruments(userID, allowedInstruments, mode)
 	if err != nil {
 		return result, err
 	}
 
 	result = UpsertQueriedInstrumentsResult{
-		Queried:  int64(len(queriedInstruments)),
-		Inserted: insertedCount,
-		Updated:  updatedCount,
-		Total:    insertedCount + updatedCount,
+		Queried:     int64(len(queriedInstruments)),
+		Inserted:    insertedCount,
+		Updated:     updatedCount,
+		Total:       insertedCount + updatedCount,
+		Blacklisted: int64(len(blacklistedInstruments)),
+	}
+
+	return result, nil
+}
+
+// SubscribeInstruments adds the instruments to the ticker instruments in the given mode and,
+// when the ticker is running, subscribes them without a restart
+func (s *TickerService) SubscribeInstruments(userID string, instruments []models.InstrumentModel, mode string) (UpsertQueriedInstrumentsResult, error) {
+	var result UpsertQueriedInstrumentsResult
+	queried := len(instruments)
+	// blacklisted instruments are never subscribed
+	instruments, blacklistedInstruments := GetInstrumentBlacklist().Filter(instruments)
+	insertedCount, updatedCount, err := s.repo.UpsertTickerInstruments(userID, instruments, mode)
+	if err != nil {
+		return result, err
+	}
+	result = UpsertQueriedInstrumentsResult{
+		Queried:     int64(queried),
+		Inserted:    insertedCount,
+		Updated:     updatedCount,
+		Total:       insertedCount + updatedCount,
+		Blacklisted: int64(len(blacklistedInstruments)),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isRunning.Load() || s.ticker == nil {
+		return result, nil
+	}
+
+	tokens := make([]uint32, 0, len(instruments))
+	for _, instrument := range instruments {
+		tokens = append(tokens, instrument.InstrumentToken)
+		s.setInstrument(instrument.InstrumentToken, instrument.Exchange+":"+instrument.Tradingsymbol)
+	}
+	if err := GetTokenBudget().Reserve(models.TokenBudgetSourceTicker, tokens); err != nil {
+		return result, err
+	}
+	if err := s.ticker.Subscribe(tokens); err != nil {
+		return result, err
+	}
+	if err := s.ticker.SetMode(tickerMode(mode), tokens); err != nil {
+		return result, err
+	}
+	GetTickHub().AddTokens(tickerMode(mode), tokens)
+	return result, nil
+}
+
+// dropInstruments unsubscribes the blacklisted instruments from the running ticker, their ticks are no longer
+// written or shared with the streams
+func (s *TickerService) dropInstruments(instruments []string) {
+	blacklisted := make(map[string]bool, len(instruments))
+	for _, instrument := range instruments {
+		blacklisted[instrument] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isRunning.Load() || s.ticker == nil {
+		return
+	}
+
+	var tokens []uint32
+	s.instrumentsMu.Lock()
+	for token, instrument := range s.instruments {
+		if blacklisted[instrument] {
+			tokens = append(tokens, token)
+			delete(s.instruments, token)
+		}
+	}
+	s.instrumentsMu.Unlock()
+	if len(tokens) == 0 {
+		return
+	}
+
+	s.updatePriorityTokens(tokens, false)
+	GetTickHub().RemoveTokens(tokens)
+	GetTokenBudget().Release(models.TokenBudgetSourceTicker, tokens)
+	if err := s.ticker.Unsubscribe(tokens); err != nil {
+		s.repo.Error("dropInstruments", fmt.Sprintf("Failed to unsubscribe blacklisted instruments: %v", err))
+		return
+	}
+	s.repo.Info("dropInstruments", fmt.Sprintf("Unsubscribed %d blacklisted instruments", len(tokens)))
+}
+
+// ReconcileTickerInstruments detects ticker instruments with stale tokens or no matching instrument,
+// updates the stale tokens and removes the orphans unless dryRun is set
+func (s *TickerService) ReconcileTickerInstruments(dryRun bool) (models.TickerInstrumentsReconcileResult, error) {
+	result := models.TickerInstrumentsReconcileResult{
+		DryRun:         dryRun,
+		TokensUpdated:  make([]models.TickerInstrumentMismatch, 0),
+		OrphansRemoved: make([]models.TickerInstrumentMismatch, 0),
+		PinnedOrphans:  make([]models.TickerInstrumentMismatch, 0),
+	}
+
+	checked, err := s.repo.GetAllTickerInstrumentCount()
+	if err != nil {
+		return result, err
+	}
+	result.Checked = checked
+
+	mismatches, err := s.repo.GetTickerInstrumentMismatches()
+	if err != nil {
+		return result, err
+	}
+
+	for _, mismatch := range mismatches {
+		if mismatch.InstrumentToken == 0 {
+			// pinned instruments are kept, their owner decides when to drop them
+			if mismatch.Pinned {
+				result.PinnedOrphans = append(result.PinnedOrphans, mismatch)
+				continue
+			}
+			if !dryRun {
+				if _, err := s.repo.DeleteTickerInstruments(mismatch.UserID, []string{mismatch.Instrument}); err != nil {
+					return result, fmt.Errorf("failed to remove orphan %s: %v", mismatch.Instrument, err)
+				}
+			}
+			result.OrphansRemoved = append(result.OrphansRemoved, mismatch)
+			continue
+		}
+
+		if !dryRun {
+			if err := s.repo.UpdateTickerInstrumentToken(mismatch.UserID, mismatch.Instrument, mismatch.InstrumentToken); err != nil {
+				return result, fmt.Errorf("failed to update token for %s: %v", mismatch.Instrument, err)
+			}
+		}
+		result.TokensUpdated = append(result.TokensUpdated, mismatch)
 	}
 
 	return result, nil
 }
 
 // monitorTickerChannel monitors the ticker channel
-func (s *TickerService) monitorTickerChannel() {
+func (s *TickerService) monitorTickerChannel(ctx context.Context) {
 	ticker := time.NewTicker(monitorInterval)
 	defer ticker.Stop()
 
+	// users are alerted once each time the channel crosses the threshold
+	alerted := false
 	for {
 		select {
-		case <-s.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			currentCapacity := len(s.tickChannel)
 			capacityPercentage := float64(currentCapacity) / float64(channelCapacity)
+			GetAdminEvents().Publish(AdminEventChannel, map[string]interface{}{
+				"length":            currentCapacity,
+				"capacity":          channelCapacity,
+				"percent":           capacityPercentage * 100,
+				"priority_length":   len(s.priorityChannel),
+				"priority_capacity": priorityChannelCapacity,
+			})
 
 			if capacityPercentage >= channelCapacityWarningThreshold {
 				warningMsg := fmt.Sprintf("Ticker channel is %.2f%% full (%d/%d)", capacityPercentage*100, currentCapacity, channelCapacity)
 				s.repo.Warn("ChannelWarning", warningMsg)
-
-				// You might want to take additional actions here, such as:
-				// - Alerting operations team
+				if !alerted {
+					alerted = true
+					s.notifier.Notify(models.NotificationCategoryAlerts, "Ticker channel backlog", warningMsg)
+				}
+			} else {
+				alerted = false
 			}
 		}
 	}
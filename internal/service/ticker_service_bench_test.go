@@ -0,0 +1,38 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+)
+
+// benchInstruments is the number of synthetic instruments the benchmarks tick
+const benchInstruments = 1000
+
+// BenchmarkProcessTicks measures converting the ticks to ticker data, the batches are dropped instead of
+// flushed so no database is needed. `go run ./cmd/tickbench -flush` measures the pipeline with the flush
+func BenchmarkProcessTicks(b *testing.B) {
+	tickerService := &TickerService{
+		repo:          repository.NewTickerRepository(nil),
+		instruments:   make(map[uint32]string, benchInstruments),
+		prevCloseSeen: make(map[uint32]bool),
+	}
+	tokens := make([]uint32, benchInstruments)
+	for i := range tokens {
+		tokens[i] = uint32(4000000000 + i)
+		tickerService.instruments[tokens[i]] = fmt.Sprintf("BENCH:SYN%06d", i)
+	}
+	postgresData := make([]models.TickerData, 0, batchSize)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tick := syntheticTick(tokens[i%benchInstruments], 100+float64(i%500)*0.05, i)
+		tickerService.processTick(tick, &postgresData)
+		if len(postgresData) >= batchSize {
+			postgresData = postgresData[:0]
+		}
+	}
+}
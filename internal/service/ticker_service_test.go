@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newDryRunTickerService returns a ticker service whose queries run against no database, the ticker
+// instruments are always empty and the writes are dropped
+func newDryRunTickerService(t *testing.T) *TickerService {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1 dbname=none"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("failed to open the dry run database: %v", err)
+	}
+	return NewTickerService(db, nil)
+}
+
+// startTestRun starts the goroutines of a run the way Start does once the ticker is connected
+func startTestRun(s *TickerService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.startRun(ctx, cancel)
+	s.isRunning.Store(true)
+}
+
+// TestTickerStopRestart starts runs and ends them with Stop or Restart, the goroutines of each run must have
+// returned once they do. Run it with -race
+func TestTickerStopRestart(t *testing.T) {
+	s := newDryRunTickerService(t)
+	userID := "TEST01"
+	baseline := runtime.NumGoroutine()
+
+	// the status and instruments are read while the runs come and go
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			s.Status()
+			s.lookupInstrument(1)
+			runtime.Gosched()
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		startTestRun(s)
+		if !s.Status() {
+			t.Fatalf("run %d: ticker is not running after the run started", i)
+		}
+		if i%2 == 0 {
+			if err := s.Stop(userID); err != nil {
+				t.Fatalf("run %d: Stop error: %v", i, err)
+			}
+		} else if err := s.Restart(userID, "enctoken"); err == nil {
+			// the restart stops the run and then finds no instruments to subscribe
+			t.Fatalf("run %d: Restart expected an error without instruments", i)
+		}
+		if s.Status() {
+			t.Fatalf("run %d: ticker is running after it was stopped", i)
+		}
+		if err := s.Stop(userID); err == nil {
+			t.Fatalf("run %d: Stop expected an error once the ticker is stopped", i)
+		}
+	}
+	close(done)
+	readers.Wait()
+
+	// the goroutines of the runs have returned by the time Stop and Restart do
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		t.Errorf("%d goroutines left after the runs stopped, %d before they started", n, baseline)
+	}
+}
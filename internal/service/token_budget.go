@@ -0,0 +1,157 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+)
+
+// ErrTokenBudgetExceeded is returned when a subscription would take a connection over its token limit
+var ErrTokenBudgetExceeded = errors.New("token budget exceeded")
+
+// tokenBudgetSources are the sources reported even when they have no subscriptions
+var tokenBudgetSources = []string{models.TokenBudgetSourceTicker, models.TokenBudgetSourceStream}
+
+// TokenBudget tracks the tokens subscribed by the ticker and stream services against the Kite limit
+type TokenBudget struct {
+	mu      sync.Mutex
+	sources map[string]map[uint32]struct{}
+}
+
+var (
+	tokenBudget     *TokenBudget
+	tokenBudgetOnce sync.Once
+)
+
+// GetTokenBudget returns the process wide token budget
+func GetTokenBudget() *TokenBudget {
+	tokenBudgetOnce.Do(func() {
+		tokenBudget = &TokenBudget{sources: make(map[string]map[uint32]struct{})}
+	})
+	return tokenBudget
+}
+
+// getTokenBudgetLimit returns the tokens a Kite connection may subscribe
+func getTokenBudgetLimit() int {
+	cfg, err := config.Get()
+	if err != nil {
+		return 0
+	}
+	// validated when the config is loaded
+	limit, _ := strconv.Atoi(cfg.KiteMaxTokensPerConnection)
+	return limit
+}
+
+// Reserve adds the tokens to the source, failing when the source would go over the limit,
+// tokens the source already has do not count again
+func (b *TokenBudget) Reserve(source string, tokens []uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.sources[source]
+	added := 0
+	for _, token := range uniqueTokens(tokens) {
+		if _, ok := current[token]; !ok {
+			added++
+		}
+	}
+	limit := getTokenBudgetLimit()
+	if limit > 0 && len(current)+added > limit {
+		return fmt.Errorf("%w: %s has %d of %d tokens, %d more requested", ErrTokenBudgetExceeded, source, len(current), limit, added)
+	}
+
+	if current == nil {
+		current = make(map[uint32]struct{}, added)
+		b.sources[source] = current
+	}
+	for _, token := range tokens {
+		current[token] = struct{}{}
+	}
+	return nil
+}
+
+// Set replaces the tokens of the source, used when the source knows its full subscription
+func (b *TokenBudget) Set(source string, tokens []uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	set := make(map[uint32]struct{}, len(tokens))
+	for _, token := range tokens {
+		set[token] = struct{}{}
+	}
+	b.sources[source] = set
+}
+
+// Release removes the tokens from the source
+func (b *TokenBudget) Release(source string, tokens []uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, token := range tokens {
+		delete(b.sources[source], token)
+	}
+}
+
+// Status returns the subscriptions per source with the overlap and headroom
+func (b *TokenBudget) Status() models.TokenBudgetStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	limit := getTokenBudgetLimit()
+	status := models.TokenBudgetStatus{
+		LimitPerConnection: limit,
+		Sources:            make([]models.TokenBudgetSource, 0, len(tokenBudgetSources)),
+	}
+	seen := make(map[uint32]int)
+	for _, source := range tokenBudgetSources {
+		tokens := b.sources[source]
+		for token := range tokens {
+			seen[token]++
+		}
+		status.Sources = append(status.Sources, models.TokenBudgetSource{
+			Source:   source,
+			Tokens:   len(tokens),
+			Headroom: tokenHeadroom(limit, len(tokens)),
+		})
+		status.Subscriptions += len(tokens)
+		status.Headroom += tokenHeadroom(limit, len(tokens))
+	}
+	if limit <= 0 {
+		status.Headroom = -1
+	}
+	status.UniqueTokens = len(seen)
+	for _, count := range seen {
+		if count > 1 {
+			status.OverlappingTokens++
+		}
+	}
+	return status
+}
+
+// tokenHeadroom returns the tokens left under the limit, -1 when there is no limit
+func tokenHeadroom(limit, tokens int) int {
+	if limit <= 0 {
+		return -1
+	}
+	if tokens > limit {
+		return 0
+	}
+	return limit - tokens
+}
+
+// uniqueTokens returns the tokens without duplicates, in their original order
+func uniqueTokens(tokens []uint32) []uint32 {
+	seen := make(map[uint32]struct{}, len(tokens))
+	result := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		result = append(result, token)
+	}
+	return result
+}
@@ -0,0 +1,231 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	kitesession "github.com/nsvirk/gokitesession"
+	"github.com/nsvirk/moneybotsapi/internal/config"
+)
+
+// TOTP providers
+const (
+	TOTPProviderSecret   = "secret"
+	TOTPProviderHTTP     = "http"
+	TOTPProviderTelegram = "telegram"
+)
+
+// totpCodeRegexp matches a 6 digit TOTP code
+var totpCodeRegexp = regexp.MustCompile(`^\d{6}$`)
+
+// TOTPProvider supplies the current TOTP code of a user for a kite login
+type TOTPProvider interface {
+	Name() string
+	GetTOTP(userID string) (string, error)
+}
+
+// NewTOTPProvider creates the TOTP provider selected in the config
+func NewTOTPProvider(cfg *config.Config) TOTPProvider {
+	// validated when the config is loaded
+	timeoutSeconds, _ := strconv.Atoi(cfg.KitetickerTotpTimeoutSeconds)
+	timeout := time.Duration(timeoutSeconds) * time.Second
+
+	switch cfg.KitetickerTotpProvider {
+	case TOTPProviderHTTP:
+		return &httpTOTPProvider{
+			httpClient: &http.Client{Timeout: timeout},
+			url:        cfg.KitetickerTotpURL,
+			token:      cfg.KitetickerTotpURLToken,
+		}
+	case TOTPProviderTelegram:
+		return &telegramTOTPProvider{
+			httpClient: &http.Client{Timeout: telegramPollSeconds*time.Second + 10*time.Second},
+			botToken:   cfg.TelegramBotToken,
+			chatID:     cfg.TelegramChatID,
+			timeout:    timeout,
+		}
+	default:
+		return &secretTOTPProvider{secret: cfg.KitetickerTotpSecret}
+	}
+}
+
+// secretTOTPProvider generates the code from a stored TOTP secret
+type secretTOTPProvider struct {
+	secret string
+}
+
+// Name returns the name of the provider
+func (p *secretTOTPProvider) Name() string {
+	return TOTPProviderSecret
+}
+
+// GetTOTP generates the current code from the secret
+func (p *secretTOTPProvider) GetTOTP(userID string) (string, error) {
+	if p.secret == "" {
+		return "", fmt.Errorf("no TOTP secret configured for %s", userID)
+	}
+	return kitesession.GenerateTOTPValue(p.secret)
+}
+
+// httpTOTPProvider fetches the code from an external service such as a secrets manager, the url may
+// contain {user_id} and responds with the code as plain text or as json {"totp": "123456"}
+type httpTOTPProvider struct {
+	httpClient *http.Client
+	url        string
+	token      string
+}
+
+// Name returns the name of the provider
+func (p *httpTOTPProvider) Name() string {
+	return TOTPProviderHTTP
+}
+
+// GetTOTP fetches the current code of the user
+func (p *httpTOTPProvider) GetTOTP(userID string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.ReplaceAll(p.url, "{user_id}", url.PathEscape(userID)), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create TOTP request: %v", err)
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch TOTP: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch TOTP: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read TOTP: %v", err)
+	}
+
+	code := strings.TrimSpace(string(body))
+	var payload struct {
+		TOTP string `json:"totp"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.TOTP != "" {
+		code = payload.TOTP
+	}
+	if !totpCodeRegexp.MatchString(code) {
+		return "", fmt.Errorf("TOTP service returned an invalid code")
+	}
+	return code, nil
+}
+
+// telegramPollSeconds is the long polling timeout of a single telegram getUpdates request
+const telegramPollSeconds = 20
+
+// telegramTOTPProvider asks for the code in the configured telegram chat and waits for a reply, it suits
+// hardware tokens and users who do not store the TOTP secret. It reads the bot updates with getUpdates,
+// so the bot must not have a webhook set
+type telegramTOTPProvider struct {
+	httpClient *http.Client
+	botToken   string
+	chatID     string
+	timeout    time.Duration
+}
+
+// telegramUpdates is the response of the telegram getUpdates method
+type telegramUpdates struct {
+	OK     bool `json:"ok"`
+	Result []struct {
+		UpdateID int64 `json:"update_id"`
+		Message  struct {
+			Chat struct {
+				ID       int64  `json:"id"`
+				Username string `json:"username"`
+			} `json:"chat"`
+			Text string `json:"text"`
+		} `json:"message"`
+	} `json:"result"`
+}
+
+// Name returns the name of the provider
+func (p *telegramTOTPProvider) Name() string {
+	return TOTPProviderTelegram
+}
+
+// GetTOTP sends the request for the code and returns the first 6 digit reply in the chat before the timeout
+func (p *telegramTOTPProvider) GetTOTP(userID string) (string, error) {
+	// skip the updates received before the request
+	updates, err := p.getUpdates(-1, 0)
+	if err != nil {
+		return "", err
+	}
+	var offset int64
+	for _, update := range updates.Result {
+		offset = update.UpdateID + 1
+	}
+
+	text := fmt.Sprintf("Kite login for %s: reply with the current TOTP code within %s", userID, p.timeout)
+	resp, err := p.httpClient.PostForm("https://api.telegram.org/bot"+p.botToken+"/sendMessage", url.Values{
+		"chat_id": {p.chatID},
+		"text":    {text},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send telegram TOTP request")
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(p.timeout)
+	for time.Now().Before(deadline) {
+		poll := int(time.Until(deadline) / time.Second)
+		if poll > telegramPollSeconds {
+			poll = telegramPollSeconds
+		}
+		updates, err := p.getUpdates(offset, poll)
+		if err != nil {
+			return "", err
+		}
+		for _, update := range updates.Result {
+			offset = update.UpdateID + 1
+			chat := update.Message.Chat
+			if strconv.FormatInt(chat.ID, 10) != p.chatID && "@"+chat.Username != p.chatID {
+				continue
+			}
+			if code := strings.TrimSpace(update.Message.Text); totpCodeRegexp.MatchString(code) {
+				// acknowledge the reply so it is not read again
+				p.getUpdates(offset, 0)
+				return code, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no TOTP reply received in telegram within %s", p.timeout)
+}
+
+// getUpdates reads the bot updates from offset, waiting up to timeout seconds for new updates
+func (p *telegramTOTPProvider) getUpdates(offset int64, timeout int) (telegramUpdates, error) {
+	var updates telegramUpdates
+	params := url.Values{
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"timeout":         {strconv.Itoa(timeout)},
+		"allowed_updates": {`["message"]`},
+	}
+	// the bot token is part of the url, errors are not wrapped to keep it out of the logs
+	resp, err := p.httpClient.Get("https://api.telegram.org/bot" + p.botToken + "/getUpdates?" + params.Encode())
+	if err != nil {
+		return updates, fmt.Errorf("failed to read telegram updates")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return updates, fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&updates); err != nil || !updates.OK {
+		return updates, fmt.Errorf("failed to decode telegram updates")
+	}
+	return updates, nil
+}
@@ -0,0 +1,135 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// userSettingsExchanges are the exchanges accepted as the default exchange
+var userSettingsExchanges = []string{"NSE", "BSE", "NFO", "BFO", "CDS", "BCD", "MCX"}
+
+// userSettingsStreamModes are the accepted stream modes
+var userSettingsStreamModes = []string{"ltp", "quote", "full"}
+
+// userSettingsNumberFormats are the accepted number formats
+var userSettingsNumberFormats = []string{
+	models.NumberFormatPlain,
+	models.NumberFormatIndian,
+	models.NumberFormatInternational,
+}
+
+// userSettingsMaxFavorites is the maximum number of favorite instruments
+const userSettingsMaxFavorites = 500
+
+var instrumentSymbolRegexp = regexp.MustCompile(`^[A-Z]+:\S.*$`)
+
+// UserService is the service for user data
+type UserService struct {
+	repo *repository.UserRepository
+}
+
+// NewUserService creates a new user service
+func NewUserService(db *gorm.DB) *UserService {
+	return &UserService{
+		repo: repository.NewUserRepository(db),
+	}
+}
+
+// GetUserSettings gets the settings of a user, a user without settings gets empty settings
+func (s *UserService) GetUserSettings(userID string) (models.UserSettings, error) {
+	var settings models.UserSettings
+	userSettings, err := s.repo.GetUserSettings(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return settings, nil
+		}
+		return settings, err
+	}
+	if err := json.Unmarshal(userSettings.Settings, &settings); err != nil {
+		return settings, fmt.Errorf("failed to parse settings: %v", err)
+	}
+	return settings, nil
+}
+
+// ParseUserSettings decodes and validates a settings document, unknown fields are rejected
+func ParseUserSettings(body []byte) (models.UserSettings, error) {
+	var settings models.UserSettings
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return settings, fmt.Errorf("invalid settings: %v", err)
+	}
+	if err := validateUserSettings(settings); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// SaveUserSettings validates and stores the settings of a user
+func (s *UserService) SaveUserSettings(userID string, settings models.UserSettings) error {
+	if err := validateUserSettings(settings); err != nil {
+		return err
+	}
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %v", err)
+	}
+	return s.repo.UpsertUserSettings(&models.UserSettingsModel{
+		UserID:   userID,
+		Settings: settingsJSON,
+	})
+}
+
+// DeleteUserSettings deletes the settings of a user
+func (s *UserService) DeleteUserSettings(userID string) (int64, error) {
+	return s.repo.DeleteUserSettings(userID)
+}
+
+// validateUserSettings validates the values of a settings document
+func validateUserSettings(settings models.UserSettings) error {
+	if settings.DefaultExchange != "" && !containsString(userSettingsExchanges, settings.DefaultExchange) {
+		return fmt.Errorf("invalid `default_exchange` %s, must be one of %v", settings.DefaultExchange, userSettingsExchanges)
+	}
+	for _, mode := range settings.StreamModes {
+		if !containsString(userSettingsStreamModes, mode) {
+			return fmt.Errorf("invalid `stream_modes` value %s, must be one of %v", mode, userSettingsStreamModes)
+		}
+	}
+	if len(settings.FavoriteInstruments) > userSettingsMaxFavorites {
+		return fmt.Errorf("too many `favorite_instruments`, maximum is %d", userSettingsMaxFavorites)
+	}
+	for _, instrument := range settings.FavoriteInstruments {
+		if !instrumentSymbolRegexp.MatchString(instrument) {
+			return fmt.Errorf("invalid `favorite_instruments` value %s, must be `exchange:tradingsymbol`", instrument)
+		}
+	}
+	if settings.Timezone != "" {
+		// Local is the time zone of the server, not one a user can rely on
+		if _, err := time.LoadLocation(settings.Timezone); err != nil || settings.Timezone == "Local" {
+			return fmt.Errorf("invalid `timezone` %s, must be an IANA time zone such as Asia/Kolkata", settings.Timezone)
+		}
+	}
+	if settings.NumberFormat != "" && !containsString(userSettingsNumberFormats, settings.NumberFormat) {
+		return fmt.Errorf("invalid `number_format` %s, must be one of %v", settings.NumberFormat, userSettingsNumberFormats)
+	}
+	return nil
+}
+
+// containsString returns true if the slice contains the value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
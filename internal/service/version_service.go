@@ -0,0 +1,63 @@
+// Package service contains the service layer for the Moneybots API
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/nsvirk/moneybotsapi/internal/config"
+	"github.com/nsvirk/moneybotsapi/internal/models"
+	"github.com/nsvirk/moneybotsapi/pkg/utils/buildinfo"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// kitetickerModule is the module path of the kite ticker library
+const kitetickerModule = "github.com/nsvirk/gokiteticker"
+
+// VersionService is the service for the version report
+type VersionService struct {
+	db          *gorm.DB
+	redisClient redis.UniversalClient
+}
+
+// NewVersionService creates a new version service
+func NewVersionService(db *gorm.DB, redisClient redis.UniversalClient) *VersionService {
+	return &VersionService{db: db, redisClient: redisClient}
+}
+
+// GetVersionInfo returns the build information and the versions of the connected Postgres and Redis servers,
+// a server version is blank when it could not be queried
+func (s *VersionService) GetVersionInfo() models.VersionInfo {
+	build := buildinfo.Get()
+	info := models.VersionInfo{
+		BuildVersion:      build.Version,
+		Commit:            build.Commit,
+		BuildTime:         build.BuildTime,
+		Modified:          build.Modified,
+		GoVersion:         build.GoVersion,
+		KitetickerVersion: buildinfo.DependencyVersion(kitetickerModule),
+	}
+	if cfg, err := config.Get(); err == nil {
+		info.APIName = cfg.APIName
+		info.APIVersion = cfg.APIVersion
+	}
+
+	var postgresVersion string
+	if err := s.db.Raw("SHOW server_version").Scan(&postgresVersion).Error; err == nil {
+		info.PostgresVersion = postgresVersion
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if serverInfo, err := s.redisClient.Info(ctx, "server").Result(); err == nil {
+		for _, line := range strings.Split(serverInfo, "\n") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+				info.RedisVersion = value
+				break
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,361 @@
+// Package decimal provides a fixed point decimal for prices
+package decimal
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Places is the number of decimal places a Decimal holds, enough for the 0.0025 tick of currency derivatives
+const Places = 4
+
+// scale is 10 to the power of Places
+const scale = 10000
+
+// Decimal is a fixed point number with Places decimal places, the zero value is 0.
+// Prices are stored as numeric columns and scanned into a Decimal so sums and differences
+// do not drift the way float64 arithmetic does
+type Decimal struct {
+	units int64
+}
+
+// Zero is the decimal 0
+var Zero = Decimal{}
+
+// New returns the decimal value * 10^-exp, e.g. New(12345, 2) is 123.45
+func New(value int64, exp int) Decimal {
+	if exp > Places {
+		return Decimal{units: clampDiv(big.NewInt(value), pow10(exp-Places))}
+	}
+	return Decimal{units: value * pow10(Places-exp).Int64()}
+}
+
+// NewFromFloat returns the float rounded to Places decimal places
+func NewFromFloat(value float64) Decimal {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Zero
+	}
+	return Decimal{units: int64(math.Round(value * scale))}
+}
+
+// NewFromString parses a decimal string, digits beyond Places decimal places are rounded half away from zero
+func NewFromString(value string) (Decimal, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return Zero, fmt.Errorf("invalid decimal %q", value)
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Zero, fmt.Errorf("invalid decimal %q", value)
+		}
+		return NewFromFloat(f), nil
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+	integer, fraction, _ := strings.Cut(s, ".")
+	if integer == "" && fraction == "" {
+		return Zero, fmt.Errorf("invalid decimal %q", value)
+	}
+	if integer == "" {
+		integer = "0"
+	}
+	digits := integer + fraction
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Zero, fmt.Errorf("invalid decimal %q", value)
+		}
+	}
+
+	n, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Zero, fmt.Errorf("invalid decimal %q", value)
+	}
+	if negative {
+		n.Neg(n)
+	}
+	var units int64
+	if len(fraction) > Places {
+		units, ok = roundDiv(n, pow10(len(fraction)-Places))
+	} else {
+		n.Mul(n, pow10(Places-len(fraction)))
+		units, ok = n.Int64(), n.IsInt64()
+	}
+	if !ok {
+		return Zero, fmt.Errorf("decimal %q out of range", value)
+	}
+	return Decimal{units: units}, nil
+}
+
+// RequireFromString parses a decimal string and panics on an invalid one, for constants
+func RequireFromString(value string) Decimal {
+	d, err := NewFromString(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// pow10 returns 10^n as a big.Int
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundDiv returns n / d rounded half away from zero, false when the quotient does not fit an int64
+func roundDiv(n, d *big.Int) (int64, bool) {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(new(big.Int).Abs(d)) >= 0 {
+		if n.Sign()*d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		return 0, false
+	}
+	return q.Int64(), true
+}
+
+// clampDiv returns n / d rounded half away from zero, clamped to the int64 range
+func clampDiv(n, d *big.Int) int64 {
+	if q, ok := roundDiv(n, d); ok {
+		return q
+	}
+	if n.Sign()*d.Sign() < 0 {
+		return math.MinInt64
+	}
+	return math.MaxInt64
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{units: d.units + other.units}
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{units: d.units - other.units}
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: -d.units}
+}
+
+// Abs returns |d|
+func (d Decimal) Abs() Decimal {
+	if d.units < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Mul returns d * other rounded to Places decimal places, clamped to the range of a Decimal
+func (d Decimal) Mul(other Decimal) Decimal {
+	n := new(big.Int).Mul(big.NewInt(d.units), big.NewInt(other.units))
+	return Decimal{units: clampDiv(n, big.NewInt(scale))}
+}
+
+// Div returns d / other rounded to Places decimal places, 0 when other is 0, clamped to the range of a
+// Decimal
+func (d Decimal) Div(other Decimal) Decimal {
+	if other.units == 0 {
+		return Zero
+	}
+	n := new(big.Int).Mul(big.NewInt(d.units), big.NewInt(scale))
+	return Decimal{units: clampDiv(n, big.NewInt(other.units))}
+}
+
+// Round returns d rounded half away from zero to the decimal places, 0 to Places
+func (d Decimal) Round(places int) Decimal {
+	if places >= Places {
+		return d
+	}
+	if places < 0 {
+		places = 0
+	}
+	step := pow10(Places - places)
+	units := clampDiv(big.NewInt(d.units), step)
+	return Decimal{units: units * step.Int64()}
+}
+
+// FloorTo returns d rounded down to a multiple of step, e.g. the 0.05 price tick, d when step is not positive
+func (d Decimal) FloorTo(step Decimal) Decimal {
+	if step.units <= 0 {
+		return d
+	}
+	q := d.units / step.units
+	if d.units%step.units != 0 && d.units < 0 {
+		q--
+	}
+	return Decimal{units: q * step.units}
+}
+
+// CeilTo returns d rounded up to a multiple of step, d when step is not positive
+func (d Decimal) CeilTo(step Decimal) Decimal {
+	if step.units <= 0 {
+		return d
+	}
+	q := d.units / step.units
+	if d.units%step.units != 0 && d.units > 0 {
+		q++
+	}
+	return Decimal{units: q * step.units}
+}
+
+// Cmp returns -1, 0 or 1 when d is less than, equal to or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	switch {
+	case d.units < other.units:
+		return -1
+	case d.units > other.units:
+		return 1
+	}
+	return 0
+}
+
+// Equal returns true when d equals other
+func (d Decimal) Equal(other Decimal) bool {
+	return d.units == other.units
+}
+
+// GreaterThan returns true when d is greater than other
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.units > other.units
+}
+
+// LessThan returns true when d is less than other
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.units < other.units
+}
+
+// Sign returns -1, 0 or 1 for a negative, zero or positive d
+func (d Decimal) Sign() int {
+	return d.Cmp(Zero)
+}
+
+// IsZero returns true when d is 0
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+// Max returns the larger of d and other
+func Max(d, other Decimal) Decimal {
+	if other.units > d.units {
+		return other
+	}
+	return d
+}
+
+// Min returns the smaller of d and other
+func Min(d, other Decimal) Decimal {
+	if other.units < d.units {
+		return other
+	}
+	return d
+}
+
+// Float64 returns the nearest float64 of d
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / scale
+}
+
+// String returns d without trailing zeros, e.g. 123.45 or 100
+func (d Decimal) String() string {
+	units := d.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	integer, fraction := units/scale, units%scale
+	if fraction == 0 {
+		return sign + strconv.FormatInt(integer, 10)
+	}
+	fractionStr := strings.TrimRight(fmt.Sprintf("%0*d", Places, fraction), "0")
+	return sign + strconv.FormatInt(integer, 10) + "." + fractionStr
+}
+
+// MarshalJSON writes d as a JSON number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads d from a JSON number or string, null is 0
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*d = Zero
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	parsed, err := NewFromString(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalText writes d as text, it is used for CSV cells and redis values
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText reads d from text
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := NewFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan reads d from a numeric, float or integer column, NULL is 0
+func (d *Decimal) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Zero
+	case []byte:
+		return d.UnmarshalText(v)
+	case string:
+		return d.UnmarshalText([]byte(v))
+	case float64:
+		*d = NewFromFloat(v)
+	case float32:
+		*d = NewFromFloat(float64(v))
+	case int64:
+		*d = New(v, 0)
+	default:
+		return fmt.Errorf("cannot scan %T into a decimal", value)
+	}
+	return nil
+}
+
+// Value writes d as a numeric string
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// GormDataType is the column type of a decimal without an explicit type tag
+func (Decimal) GormDataType() string {
+	return "numeric"
+}
@@ -0,0 +1,221 @@
+package decimal
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestNewFromString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0", "0"},
+		{"123.45", "123.45"},
+		{"-123.45", "-123.45"},
+		{"+1.5", "1.5"},
+		{".25", "0.25"},
+		{"100.", "100"},
+		{"0.00025", "0.0003"},
+		{"-0.00025", "-0.0003"},
+		{"0.00024", "0.0002"},
+		{"1.23e2", "123"},
+		{" 7.0025 ", "7.0025"},
+	}
+	for _, tt := range tests {
+		d, err := NewFromString(tt.in)
+		if err != nil {
+			t.Fatalf("NewFromString(%q) error: %v", tt.in, err)
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("NewFromString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewFromStringInvalid(t *testing.T) {
+	for _, in := range []string{"", "-", ".", "abc", "1.2.3", "1,5", "1e", "99999999999999999999", "9999999999999999.99999"} {
+		if _, err := NewFromString(in); err == nil {
+			t.Errorf("NewFromString(%q) expected an error", in)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		value int64
+		exp   int
+		want  string
+	}{
+		{12345, 2, "123.45"},
+		{5, 0, "5"},
+		{-125, 6, "-0.0001"},
+		{15, 5, "0.0002"},
+	}
+	for _, tt := range tests {
+		if got := New(tt.value, tt.exp).String(); got != tt.want {
+			t.Errorf("New(%d, %d) = %s, want %s", tt.value, tt.exp, got, tt.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := RequireFromString("10.5")
+	b := RequireFromString("-3")
+	if got := a.Add(b).String(); got != "7.5" {
+		t.Errorf("Add = %s, want 7.5", got)
+	}
+	if got := a.Sub(b).String(); got != "13.5" {
+		t.Errorf("Sub = %s, want 13.5", got)
+	}
+	if got := a.Neg().String(); got != "-10.5" {
+		t.Errorf("Neg = %s, want -10.5", got)
+	}
+	if got := b.Abs().String(); got != "3" {
+		t.Errorf("Abs = %s, want 3", got)
+	}
+	if got := a.Mul(b).String(); got != "-31.5" {
+		t.Errorf("Mul = %s, want -31.5", got)
+	}
+	if got := RequireFromString("0.0005").Mul(RequireFromString("0.5")).String(); got != "0.0003" {
+		t.Errorf("Mul rounding = %s, want 0.0003", got)
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		d, other string
+		want     string
+	}{
+		{"1", "3", "0.3333"},
+		{"1", "-3", "-0.3333"},
+		{"-1", "3", "-0.3333"},
+		{"-1", "-3", "0.3333"},
+		{"2", "3", "0.6667"},
+		{"2", "-3", "-0.6667"},
+		{"-2", "-3", "0.6667"},
+		{"1", "8", "0.125"},
+		{"0.0001", "-2", "-0.0001"},
+		{"10", "0", "0"},
+	}
+	for _, tt := range tests {
+		got := RequireFromString(tt.d).Div(RequireFromString(tt.other)).String()
+		if got != tt.want {
+			t.Errorf("%s / %s = %s, want %s", tt.d, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestDivClamps(t *testing.T) {
+	max := Decimal{units: math.MaxInt64}
+	if got := max.Div(RequireFromString("0.5")); got.units != math.MaxInt64 {
+		t.Errorf("Div overflow = %d, want %d", got.units, int64(math.MaxInt64))
+	}
+	if got := max.Div(RequireFromString("-0.5")); got.units != math.MinInt64 {
+		t.Errorf("Div negative overflow = %d, want %d", got.units, int64(math.MinInt64))
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int
+		want   string
+	}{
+		{"1.2345", 2, "1.23"},
+		{"1.235", 2, "1.24"},
+		{"-1.235", 2, "-1.24"},
+		{"2.5", 0, "3"},
+		{"-2.5", -1, "-3"},
+		{"1.2345", 4, "1.2345"},
+	}
+	for _, tt := range tests {
+		if got := RequireFromString(tt.in).Round(tt.places).String(); got != tt.want {
+			t.Errorf("Round(%s, %d) = %s, want %s", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestFloorCeilTo(t *testing.T) {
+	step := RequireFromString("0.05")
+	tests := []struct {
+		in          string
+		floor, ceil string
+	}{
+		{"101.23", "101.2", "101.25"},
+		{"-101.23", "-101.25", "-101.2"},
+		{"101.25", "101.25", "101.25"},
+	}
+	for _, tt := range tests {
+		d := RequireFromString(tt.in)
+		if got := d.FloorTo(step).String(); got != tt.floor {
+			t.Errorf("FloorTo(%s) = %s, want %s", tt.in, got, tt.floor)
+		}
+		if got := d.CeilTo(step).String(); got != tt.ceil {
+			t.Errorf("CeilTo(%s) = %s, want %s", tt.in, got, tt.ceil)
+		}
+	}
+	if got := RequireFromString("1.23").FloorTo(Zero).String(); got != "1.23" {
+		t.Errorf("FloorTo zero step = %s, want 1.23", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a, b := RequireFromString("1.5"), RequireFromString("2")
+	if a.Cmp(b) != -1 || b.Cmp(a) != 1 || a.Cmp(a) != 0 {
+		t.Error("Cmp mismatch")
+	}
+	if !a.LessThan(b) || !b.GreaterThan(a) || !a.Equal(RequireFromString("1.50")) {
+		t.Error("comparison mismatch")
+	}
+	if Max(a, b) != b || Min(a, b) != a {
+		t.Error("Max or Min mismatch")
+	}
+	if a.Neg().Sign() != -1 || !Zero.IsZero() || Zero.Sign() != 0 {
+		t.Error("Sign or IsZero mismatch")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Price Decimal `json:"price"`
+		Open  Decimal `json:"open"`
+		Close Decimal `json:"close"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 101.05, "open": "99.5", "close": null}`), &v); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if v.Price.String() != "101.05" || v.Open.String() != "99.5" || !v.Close.IsZero() {
+		t.Errorf("Unmarshal = %s %s %s", v.Price, v.Open, v.Close)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if got := string(data); got != `{"price":101.05,"open":99.5,"close":0}` {
+		t.Errorf("Marshal = %s", got)
+	}
+}
+
+func TestScanValue(t *testing.T) {
+	var d Decimal
+	for _, value := range []interface{}{[]byte("1.25"), "1.25", 1.25, float32(1.25)} {
+		if err := d.Scan(value); err != nil || d.String() != "1.25" {
+			t.Errorf("Scan(%v) = %s, %v", value, d, err)
+		}
+	}
+	if err := d.Scan(int64(3)); err != nil || d.String() != "3" {
+		t.Errorf("Scan(int64) = %s, %v", d, err)
+	}
+	if err := d.Scan(nil); err != nil || !d.IsZero() {
+		t.Errorf("Scan(nil) = %s, %v", d, err)
+	}
+	if err := d.Scan(true); err == nil {
+		t.Error("Scan(bool) expected an error")
+	}
+	value, err := RequireFromString("-0.05").Value()
+	if err != nil || value != "-0.05" {
+		t.Errorf("Value = %v, %v", value, err)
+	}
+}
@@ -0,0 +1,109 @@
+// Package greeks prices european options with the Black-Scholes model and derives their implied volatility and greeks
+package greeks
+
+import "math"
+
+// Option types
+const (
+	Call = "CE"
+	Put  = "PE"
+)
+
+// Volatility bounds of the implied volatility search, annualised
+const (
+	minVolatility = 0.0001
+	maxVolatility = 5.0
+)
+
+// ivTolerance is the price difference the implied volatility search stops at
+const ivTolerance = 1e-6
+
+// Greeks are the sensitivities of an option price. Theta is per calendar day and vega per volatility point
+type Greeks struct {
+	Delta float64
+	Gamma float64
+	Theta float64
+	Vega  float64
+}
+
+// Price returns the Black-Scholes price of the option on spot s with strike k, years to expiry t,
+// annual risk free rate r and annual volatility sigma
+func Price(optionType string, s, k, t, r, sigma float64) float64 {
+	d1, d2 := d1d2(s, k, t, r, sigma)
+	discount := k * math.Exp(-r*t)
+	if optionType == Put {
+		return discount*normCDF(-d2) - s*normCDF(-d1)
+	}
+	return s*normCDF(d1) - discount*normCDF(d2)
+}
+
+// ImpliedVolatility returns the volatility the option is priced at, false when the price is outside the
+// no-arbitrage bounds of the model or the volatility outside the searched range
+func ImpliedVolatility(optionType string, price, s, k, t, r float64) (float64, bool) {
+	if price <= 0 || s <= 0 || k <= 0 || t <= 0 {
+		return 0, false
+	}
+	discount := k * math.Exp(-r*t)
+	lower, upper := math.Max(s-discount, 0), s
+	if optionType == Put {
+		lower, upper = math.Max(discount-s, 0), discount
+	}
+	if price <= lower || price >= upper {
+		return 0, false
+	}
+
+	// the price increases with the volatility, bisect the bounds
+	low, high := minVolatility, maxVolatility
+	if price < Price(optionType, s, k, t, r, low) || price > Price(optionType, s, k, t, r, high) {
+		return 0, false
+	}
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		diff := Price(optionType, s, k, t, r, mid) - price
+		if math.Abs(diff) < ivTolerance {
+			return mid, true
+		}
+		if diff > 0 {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return (low + high) / 2, true
+}
+
+// Compute returns the greeks of the option at the volatility
+func Compute(optionType string, s, k, t, r, sigma float64) Greeks {
+	d1, d2 := d1d2(s, k, t, r, sigma)
+	sqrtT := math.Sqrt(t)
+	discount := k * math.Exp(-r*t)
+	decay := -s * normPDF(d1) * sigma / (2 * sqrtT)
+
+	g := Greeks{
+		Gamma: normPDF(d1) / (s * sigma * sqrtT),
+		Vega:  s * normPDF(d1) * sqrtT / 100,
+	}
+	if optionType == Put {
+		g.Delta = normCDF(d1) - 1
+		g.Theta = (decay + r*discount*normCDF(-d2)) / 365
+	} else {
+		g.Delta = normCDF(d1)
+		g.Theta = (decay - r*discount*normCDF(d2)) / 365
+	}
+	return g
+}
+
+func d1d2(s, k, t, r, sigma float64) (float64, float64) {
+	d1 := (math.Log(s/k) + (r+sigma*sigma/2)*t) / (sigma * math.Sqrt(t))
+	return d1, d1 - sigma*math.Sqrt(t)
+}
+
+// normCDF is the standard normal cumulative distribution
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// normPDF is the standard normal density
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
@@ -0,0 +1,67 @@
+// Package recovery recovers panics, logs them with their stack trace and passes them to the registered reporters
+package recovery
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/nsvirk/moneybotsapi/pkg/utils/zaplogger"
+)
+
+// Reporter receives every recovered panic, e.g. to forward it to an error tracker
+type Reporter func(source string, recovered interface{}, stack []byte)
+
+var (
+	reporters   []Reporter
+	reportersMu sync.RWMutex
+)
+
+// AddReporter registers a reporter for recovered panics
+func AddReporter(reporter Reporter) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	reporters = append(reporters, reporter)
+}
+
+// Report logs a recovered panic and passes it to the reporters
+func Report(source string, recovered interface{}, stack []byte) {
+	zaplogger.Error("PANIC recovered", zaplogger.Fields{
+		"source": source,
+		"panic":  fmt.Sprint(recovered),
+		"stack":  string(stack),
+	})
+
+	reportersMu.RLock()
+	defer reportersMu.RUnlock()
+	for _, reporter := range reporters {
+		reporter(source, recovered, stack)
+	}
+}
+
+// Guard recovers and reports a panic, it must be deferred directly: defer recovery.Guard("source")
+func Guard(source string) {
+	if recovered := recover(); recovered != nil {
+		Report(source, recovered, debug.Stack())
+	}
+}
+
+// Run runs fn and recovers and reports a panic, it returns false when fn panicked
+func Run(source string, fn func()) (ok bool) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			Report(source, recovered, debug.Stack())
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
+
+// Go runs fn in a goroutine whose panics are recovered and reported instead of killing the process
+func Go(source string, fn func()) {
+	go func() {
+		defer Guard(source)
+		fn()
+	}()
+}
@@ -0,0 +1,64 @@
+package recovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sentryTimeout is how long sending an event to Sentry may take
+const sentryTimeout = 5 * time.Second
+
+// NewSentryReporter creates a reporter sending recovered panics to the Sentry project of the DSN
+func NewSentryReporter(dsn, environment, release string) (Reporter, error) {
+	dsnURL, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sentry dsn: %v", err)
+	}
+	publicKey := dsnURL.User.Username()
+	projectID := strings.TrimPrefix(dsnURL.Path, "/")
+	if publicKey == "" || projectID == "" {
+		return nil, fmt.Errorf("invalid sentry dsn: missing key or project")
+	}
+	storeURL := fmt.Sprintf("%s://%s/api/%s/store/", dsnURL.Scheme, dsnURL.Host, projectID)
+	authHeader := fmt.Sprintf("Sentry sentry_version=7, sentry_client=moneybotsapi/1.0, sentry_key=%s", publicKey)
+	client := &http.Client{Timeout: sentryTimeout}
+
+	return func(source string, recovered interface{}, stack []byte) {
+		event := map[string]interface{}{
+			"timestamp":   time.Now().UTC().Format(time.RFC3339),
+			"level":       "fatal",
+			"logger":      source,
+			"platform":    "go",
+			"environment": environment,
+			"release":     release,
+			"message":     fmt.Sprintf("panic in %s: %v", source, recovered),
+			"extra": map[string]string{
+				"stack": string(stack),
+			},
+		}
+		body, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+
+		// do not block the recovering goroutine
+		go func() {
+			req, err := http.NewRequest(http.MethodPost, storeURL, bytes.NewReader(body))
+			if err != nil {
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Sentry-Auth", authHeader)
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+		}()
+	}, nil
+}
@@ -0,0 +1,66 @@
+// Package requestid carries the request id of an incoming request in its context, so outbound calls
+// and persisted logs made for the request can be traced back to it
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header is the header carrying the request id, in and out
+const Header = "X-Request-ID"
+
+// maxLength is the longest request id accepted from a client
+const maxLength = 64
+
+// contextKey is the context key of the request id
+type contextKey struct{}
+
+// New returns a random request id
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// IsValid returns true if the id can be used as a request id, at most 64 letters, digits, dashes,
+// underscores, dots or colons
+func IsValid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of the context carrying the request id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request id of the context, blank when it carries none
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// SetHeader sets the request id of the context on an outbound request
+func SetHeader(req *http.Request, ctx context.Context) {
+	if id := FromContext(ctx); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
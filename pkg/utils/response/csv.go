@@ -0,0 +1,221 @@
+package response
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMETextCSV is the content type of CSV responses, requested with the Accept header or the format query param
+const (
+	MIMETextCSV = "text/csv"
+	FormatParam = "format"
+	FormatCSV   = "csv"
+)
+
+// csvFlushRows is the number of rows written between flushes of a streamed CSV response
+const csvFlushRows = 1000
+
+// timeType is the type of time.Time, which is written as a timestamp instead of being flattened
+var timeType = reflect.TypeOf(time.Time{})
+
+// textMarshalerType is the interface of scalar structs like decimals, which are written as a value instead of being flattened
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// WantsCSV returns true if the request accepts CSV, the format query param takes precedence over the Accept header
+func WantsCSV(c echo.Context) bool {
+	if format := c.QueryParam(FormatParam); format != "" {
+		return strings.EqualFold(format, FormatCSV)
+	}
+	for _, accept := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(accept), ";")
+		if strings.EqualFold(mediaType, MIMETextCSV) {
+			return true
+		}
+	}
+	return false
+}
+
+// csvColumn is a column of a CSV response and the path to its struct field
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// CSV streams the rows, a slice of structs, as a CSV attachment. Columns are named after the json tags,
+// nested structs are flattened into parent_child columns and slices and maps are written as JSON
+func CSV(c echo.Context, filename string, rows interface{}) error {
+	return CSVKeyed(c, filename, "", nil, rows)
+}
+
+// CSVKeyed streams the rows as a CSV attachment like CSV, with the key of each row in a leading keyColumn.
+// The rows are a slice of structs in the order of the keys, or a map of the keys to structs
+func CSVKeyed(c echo.Context, filename, keyColumn string, keys []string, rows interface{}) error {
+	values := reflect.ValueOf(rows)
+	var items []reflect.Value
+	var itemKeys []string
+	switch values.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < values.Len(); i++ {
+			items = append(items, indirect(values.Index(i)))
+			if i < len(keys) {
+				itemKeys = append(itemKeys, keys[i])
+			} else {
+				itemKeys = append(itemKeys, "")
+			}
+		}
+	case reflect.Map:
+		for _, key := range keys {
+			if item := values.MapIndex(reflect.ValueOf(key)); item.IsValid() {
+				items = append(items, indirect(item))
+				itemKeys = append(itemKeys, key)
+			}
+		}
+	default:
+		return fmt.Errorf("csv rows must be a slice or a map, got %s", values.Kind())
+	}
+
+	var columns []csvColumn
+	if len(items) > 0 && items[0].Kind() == reflect.Struct {
+		columns = csvColumns(items[0].Type(), "", nil)
+	}
+	header := make([]string, 0, len(columns)+1)
+	if keyColumn != "" {
+		header = append(header, keyColumn)
+	}
+	for _, column := range columns {
+		// the key column replaces a field of the same name
+		if column.name != keyColumn {
+			header = append(header, column.name)
+		}
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, MIMETextCSV+"; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	res.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(res)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, 0, len(header))
+	for i, item := range items {
+		record = record[:0]
+		if keyColumn != "" {
+			record = append(record, itemKeys[i])
+		}
+		for _, column := range columns {
+			if column.name == keyColumn {
+				continue
+			}
+			record = append(record, csvValue(fieldByIndex(item, column.index)))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		if (i+1)%csvFlushRows == 0 {
+			writer.Flush()
+			res.Flush()
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvColumns returns the columns of a struct type, nested structs are flattened with the parent name as prefix
+func csvColumns(t reflect.Type, prefix string, index []int) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && fieldType != timeType && !fieldType.Implements(textMarshalerType) {
+			if field.Anonymous {
+				columns = append(columns, csvColumns(fieldType, prefix, fieldIndex)...)
+			} else {
+				columns = append(columns, csvColumns(fieldType, prefix+name+"_", fieldIndex)...)
+			}
+			continue
+		}
+		columns = append(columns, csvColumn{name: prefix + name, index: fieldIndex})
+	}
+	return columns
+}
+
+// fieldByIndex returns the nested field, or an invalid value when a pointer on the path is nil
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		v = indirect(v)
+		if !v.IsValid() || v.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// indirect dereferences pointers and interfaces, nil values become invalid values
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// csvValue formats a field value as a CSV cell
+func csvValue(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	default:
+		body, err := json.Marshal(v.Interface())
+		if err != nil {
+			return ""
+		}
+		return string(body)
+	}
+}
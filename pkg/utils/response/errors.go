@@ -0,0 +1,35 @@
+package response
+
+// Error types returned in the `error_type` field of error responses
+const (
+	AuthenticationException = "AuthenticationException"
+	AuthorizationException  = "AuthorizationException"
+	InputException          = "InputException"
+	DataNotFound            = "DataNotFound"
+	DatabaseException       = "DatabaseException"
+	TickerException         = "TickerException"
+	QuotaException          = "QuotaException"
+	RateLimitException      = "RateLimitException"
+	ServerException         = "ServerException"
+)
+
+// ErrorTypeInfo describes an error type of the API
+type ErrorTypeInfo struct {
+	ErrorType   string `json:"error_type"`
+	HTTPStatus  []int  `json:"http_status"`
+	Description string `json:"description"`
+	Retryable   bool   `json:"retryable"`
+}
+
+// ErrorCatalog is the registry of all error types returned by the API
+var ErrorCatalog = []ErrorTypeInfo{
+	{AuthenticationException, []int{401}, "Login with the given credentials failed", false},
+	{AuthorizationException, []int{401, 403}, "The authorization header is missing or invalid, the session has expired or access is denied", false},
+	{InputException, []int{400, 413}, "A request parameter or the request body is missing or invalid", false},
+	{DataNotFound, []int{404}, "No data exists for the requested instruments or resource", false},
+	{DatabaseException, []int{500}, "A database query failed", true},
+	{TickerException, []int{500}, "The ticker could not be controlled or queried", true},
+	{QuotaException, []int{429}, "A usage quota of the user has been used up for the day", false},
+	{RateLimitException, []int{429}, "Too many requests were made, retry after the time given in the Retry-After header", true},
+	{ServerException, []int{500, 503}, "An unexpected server side error occurred", true},
+}
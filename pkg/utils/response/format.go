@@ -0,0 +1,209 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Response format options, set per request with a header or a query param
+const (
+	CasingHeader          = "X-Response-Casing"
+	CasingParam           = "casing"
+	TimestampFormatHeader = "X-Timestamp-Format"
+	TimestampFormatParam  = "timestamp_format"
+
+	CasingSnake = "snake"
+	CasingCamel = "camel"
+
+	TimestampFormatDefault = "default"
+	TimestampFormatEpochMs = "epoch_ms"
+)
+
+// timestampLayouts are the layouts of the timestamps formatted by the API
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+}
+
+// timestampLocation is the time zone of the timestamps formatted without a zone, the market time zone
+var timestampLocation = time.Local
+
+// jsonMarshalerType is the type of the values encoding themselves, their keys are data and never converted
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// SetTimestampLocation sets the time zone of the timestamps formatted without a zone, it must be called
+// before the server starts
+func SetTimestampLocation(location *time.Location) {
+	timestampLocation = location
+}
+
+// Format is the requested serialization of the response
+type Format struct {
+	Casing          string
+	TimestampFormat string
+}
+
+// IsDefault returns true if the response is serialized as is
+func (f Format) IsDefault() bool {
+	return f.Casing != CasingCamel && f.TimestampFormat != TimestampFormatEpochMs
+}
+
+// GetFormat returns the response format requested, the query param takes precedence over the header
+func GetFormat(c echo.Context) Format {
+	format := Format{
+		Casing:          c.Request().Header.Get(CasingHeader),
+		TimestampFormat: c.Request().Header.Get(TimestampFormatHeader),
+	}
+	if casing := c.QueryParam(CasingParam); casing != "" {
+		format.Casing = casing
+	}
+	if timestampFormat := c.QueryParam(TimestampFormatParam); timestampFormat != "" {
+		format.TimestampFormat = timestampFormat
+	}
+	format.Casing = strings.ToLower(format.Casing)
+	format.TimestampFormat = strings.ToLower(format.TimestampFormat)
+	return format
+}
+
+// JSON sends a JSON response serialized in the requested format
+func JSON(c echo.Context, httpStatus int, data interface{}) error {
+	format := GetFormat(c)
+	if format.IsDefault() {
+		return c.JSON(httpStatus, data)
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	return c.JSON(httpStatus, format.apply(value, reflect.ValueOf(data)))
+}
+
+// apply converts the keys and the timestamps of the decoded JSON value of source. The keys of the objects
+// encoded from structs are field names and are converted, the keys of maps are data and kept as they are
+func (f Format) apply(value interface{}, source reflect.Value) interface{} {
+	source = indirect(source)
+	if source.IsValid() && (source.Type().Implements(jsonMarshalerType) || reflect.PointerTo(source.Type()).Implements(jsonMarshalerType)) {
+		// the structure of a value encoding itself is not known
+		source = reflect.Value{}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var fields map[string]reflect.Value
+		if source.IsValid() && source.Kind() == reflect.Struct {
+			fields = jsonFields(source)
+		}
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var child reflect.Value
+			if field, ok := fields[key]; ok {
+				child = field
+				if f.Casing == CasingCamel {
+					key = snakeToCamel(key)
+				}
+			} else if source.IsValid() && source.Kind() == reflect.Map && source.Type().Key().Kind() == reflect.String {
+				child = source.MapIndex(reflect.ValueOf(key).Convert(source.Type().Key()))
+			}
+			converted[key] = f.apply(item, child)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			var child reflect.Value
+			if source.IsValid() && (source.Kind() == reflect.Slice || source.Kind() == reflect.Array) && i < source.Len() {
+				child = source.Index(i)
+			}
+			v[i] = f.apply(item, child)
+		}
+		return v
+	case string:
+		if f.TimestampFormat == TimestampFormatEpochMs {
+			if t, ok := parseTimestamp(v); ok {
+				return t.UnixMilli()
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// jsonFields returns the fields of a struct by their json name, the fields of embedded structs are promoted
+// unless the struct has a field of the same name, as encoding/json does
+func jsonFields(v reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	var embedded []reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			if value := indirect(v.Field(i)); value.IsValid() && value.Kind() == reflect.Struct {
+				embedded = append(embedded, value)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = v.Field(i)
+	}
+	for _, value := range embedded {
+		for name, field := range jsonFields(value) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = field
+			}
+		}
+	}
+	return fields
+}
+
+// snakeToCamel converts a snake_case key to camelCase
+func snakeToCamel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+	parts := strings.Split(key, "_")
+	var sb strings.Builder
+	sb.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+// parseTimestamp parses a timestamp formatted by the API, timestamps without a zone are in the market time zone
+func parseTimestamp(value string) (time.Time, bool) {
+	// dates such as expiries are not timestamps
+	if len(value) < len("2006-01-02 15:04:05") {
+		return time.Time{}, false
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, timestampLocation); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
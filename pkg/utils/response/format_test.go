@@ -0,0 +1,83 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// decodeFormatted encodes the data and applies the format to it like JSON does
+func decodeFormatted(t *testing.T, format Format, data interface{}) map[string]interface{} {
+	t.Helper()
+	body, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	return format.apply(value, reflect.ValueOf(data)).(map[string]interface{})
+}
+
+func TestApplyCamelCaseFieldsOnly(t *testing.T) {
+	type quote struct {
+		LastPrice float64 `json:"last_price"`
+	}
+	type embedded struct {
+		UpdatedAt string `json:"updated_at"`
+	}
+	type payload struct {
+		embedded
+		UserID string            `json:"user_id"`
+		Quotes map[string]quote  `json:"quotes"`
+		Tags   map[string]string `json:"tags"`
+		Raw    json.RawMessage   `json:"raw"`
+	}
+	data := payload{
+		embedded: embedded{UpdatedAt: "x"},
+		UserID:   "AB1234",
+		Quotes:   map[string]quote{"NSE:M_M": {LastPrice: 1}},
+		Tags:     map[string]string{"sector_name": "auto"},
+		Raw:      json.RawMessage(`{"open_interest": 1}`),
+	}
+	got := decodeFormatted(t, Format{Casing: CasingCamel}, data)
+
+	for _, key := range []string{"updatedAt", "userId", "quotes", "tags", "raw"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("missing field %s in %v", key, got)
+		}
+	}
+	quotes := got["quotes"].(map[string]interface{})
+	if _, ok := quotes["NSE:M_M"]; !ok {
+		t.Fatalf("map key converted: %v", quotes)
+	}
+	if _, ok := quotes["NSE:M_M"].(map[string]interface{})["lastPrice"]; !ok {
+		t.Errorf("field of a map value not converted: %v", quotes)
+	}
+	if _, ok := got["tags"].(map[string]interface{})["sector_name"]; !ok {
+		t.Errorf("map key converted: %v", got["tags"])
+	}
+	if _, ok := got["raw"].(map[string]interface{})["open_interest"]; !ok {
+		t.Errorf("key of a raw value converted: %v", got["raw"])
+	}
+}
+
+func TestApplyEpochMsMarketTime(t *testing.T) {
+	defer SetTimestampLocation(timestampLocation)
+	location := time.FixedZone("IST", 5*60*60+30*60)
+	SetTimestampLocation(location)
+
+	got := decodeFormatted(t, Format{TimestampFormat: TimestampFormatEpochMs}, map[string]string{"timestamp": "2024-01-02 09:15:00", "expiry": "2024-01-25"})
+	want := time.Date(2024, 1, 2, 9, 15, 0, 0, location).UnixMilli()
+	if got["timestamp"] != want {
+		t.Errorf("timestamp = %v, want %d", got["timestamp"], want)
+	}
+	if got["expiry"] != "2024-01-25" {
+		t.Errorf("expiry = %v, want the date unchanged", got["expiry"])
+	}
+}
@@ -17,7 +17,7 @@ type Response struct {
 
 // SuccessResponse sends a successful JSON response
 func SuccessResponse(c echo.Context, data interface{}) error {
-	return c.JSON(http.StatusOK, Response{
+	return JSON(c, http.StatusOK, Response{
 		Status: "success",
 		Data:   data,
 	})
@@ -25,7 +25,7 @@ func SuccessResponse(c echo.Context, data interface{}) error {
 
 // ErrorResponse sends an error JSON response
 func ErrorResponse(c echo.Context, httpStatus int, errorType, message string) error {
-	return c.JSON(httpStatus, Response{
+	return JSON(c, httpStatus, Response{
 		Status:    "error",
 		ErrorType: errorType,
 		Message:   message,